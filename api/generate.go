@@ -0,0 +1,5 @@
+// Package api contains the protobuf definitions and generated gRPC bindings for
+// the sigmartc control API.
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto
@@ -0,0 +1,232 @@
+// Command loadtest soaks a running server with synthetic clients: it joins N
+// clients across M rooms, has some of them publish synthetic Opus, and reports
+// join latency, packet delivery ratio and the server's CPU and memory.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"sigmartc/pkg/client"
+)
+
+// opusFrame is a 20ms Opus packet carrying silence (TOC byte for a CELT
+// fullband 20ms frame) padded to a typical voice payload size, so the load is
+// realistic without an encoder.
+var opusFrame = append([]byte{0xfc}, make([]byte, 79)...)
+
+// maxRoomPeers mirrors the server's default room cap; more clients per room
+// than this are refused at join.
+const maxRoomPeers = 10
+
+type serverStats struct {
+	MemoryAllocMB float64 `json:"memory_alloc_mb"`
+	Goroutines    int     `json:"goroutines"`
+	CPUSeconds    float64 `json:"cpu_seconds"`
+	Users         int     `json:"users"`
+	at            time.Time
+}
+
+func main() {
+	serverURL := flag.String("url", "http://localhost:8080", "Server base URL")
+	rooms := flag.Int("rooms", 5, "Number of rooms")
+	clients := flag.Int("clients", 20, "Total number of clients, spread evenly across rooms")
+	publishers := flag.Int("publishers", 2, "Publishing clients per room; the rest only listen")
+	duration := flag.Duration("duration", time.Minute, "Measurement window once all clients have joined")
+	ramp := flag.Duration("ramp", 50*time.Millisecond, "Delay between client joins")
+	joinTimeout := flag.Duration("join-timeout", 15*time.Second, "How long a client may take to connect")
+	adminKey := flag.String("admin-key", "", "Admin key used to sample server CPU and memory from /admin?action=stats (empty skips them)")
+	prefix := flag.String("room-prefix", fmt.Sprintf("loadtest-%d", time.Now().Unix()), "Prefix for generated room names")
+	flag.Parse()
+
+	if *rooms < 1 || *clients < *rooms {
+		fmt.Fprintln(os.Stderr, "need at least one room and one client per room")
+		os.Exit(2)
+	}
+	if perRoom := (*clients + *rooms - 1) / *rooms; perRoom > maxRoomPeers {
+		fmt.Fprintf(os.Stderr, "warning: %d clients per room exceeds the default room cap of %d; extra joins will be refused\n", perRoom, maxRoomPeers)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	api, err := client.NewAPI()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "webrtc: %v\n", err)
+		os.Exit(1)
+	}
+
+	before, statsErr := fetchStats(*serverURL, *adminKey)
+	if *adminKey != "" && statsErr != nil {
+		fmt.Fprintf(os.Stderr, "warning: server stats unavailable: %v\n", statsErr)
+	}
+
+	// Join phase: dial with a ramp, then wait for every client to connect.
+	roomClients := make([][]*client.Client, *rooms)
+	var latencies []time.Duration
+	var latMu sync.Mutex
+	var wg sync.WaitGroup
+	failed := 0
+	for i := 0; i < *clients && ctx.Err() == nil; i++ {
+		room := i % *rooms
+		c, err := client.Connect(ctx, *serverURL, fmt.Sprintf("%s-%d", *prefix, room), client.Options{
+			Name:    fmt.Sprintf("bot-%d", i),
+			API:     api,
+			Publish: len(roomClients[room]) < *publishers,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "join bot-%d: %v\n", i, err)
+			failed++
+			continue
+		}
+		defer c.Close()
+		roomClients[room] = append(roomClients[room], c)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			joinCtx, cancel := context.WithTimeout(ctx, *joinTimeout)
+			defer cancel()
+			if err := c.WaitConnected(joinCtx); err == nil {
+				latMu.Lock()
+				latencies = append(latencies, c.JoinLatency())
+				latMu.Unlock()
+			}
+		}()
+		select {
+		case <-time.After(*ramp):
+		case <-ctx.Done():
+		}
+	}
+	wg.Wait()
+	dialed := 0
+	for _, cs := range roomClients {
+		dialed += len(cs)
+	}
+	failed += dialed - len(latencies)
+	fmt.Printf("joined %d/%d clients in %d rooms\n", len(latencies), *clients, *rooms)
+
+	// Publish phase: warm up so renegotiation for every track settles, then
+	// count the packets sent and received during the window.
+	pubCtx, stopPublishing := context.WithCancel(ctx)
+	for _, cs := range roomClients {
+		for _, c := range cs {
+			go publish(pubCtx, c)
+		}
+	}
+	sleep(ctx, 2*time.Second)
+	start, _ := fetchStats(*serverURL, *adminKey)
+	baseline := packetCounts(roomClients)
+	sleep(ctx, *duration)
+	counts := packetCounts(roomClients)
+	var sent, expected, received uint64
+	for _, cs := range roomClients {
+		// Every client in a room should receive each other publisher's packets.
+		var roomSent uint64
+		for _, c := range cs {
+			roomSent += counts[c].sent - baseline[c].sent
+		}
+		sent += roomSent
+		for _, c := range cs {
+			expected += roomSent - (counts[c].sent - baseline[c].sent)
+			received += counts[c].received - baseline[c].received
+		}
+	}
+	end, _ := fetchStats(*serverURL, *adminKey)
+	stopPublishing()
+
+	fmt.Printf("join failures: %d\n", failed)
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		fmt.Printf("join latency: p50=%v p95=%v p99=%v max=%v\n",
+			percentile(latencies, 50), percentile(latencies, 95), percentile(latencies, 99), latencies[len(latencies)-1])
+	}
+	fmt.Printf("packets: sent=%d expected=%d received=%d\n", sent, expected, received)
+	if expected > 0 {
+		fmt.Printf("delivery ratio: %.2f%%\n", 100*float64(received)/float64(expected))
+	}
+	if before != nil && start != nil && end != nil {
+		fmt.Printf("server before: users=%d memory=%vMB goroutines=%d\n", before.Users, before.MemoryAllocMB, before.Goroutines)
+		fmt.Printf("server under load: users=%d memory=%vMB goroutines=%d cpu=%.1f%%\n",
+			end.Users, end.MemoryAllocMB, end.Goroutines, 100*(end.CPUSeconds-start.CPUSeconds)/end.at.Sub(start.at).Seconds())
+	}
+}
+
+type packetCount struct {
+	sent, received uint64
+}
+
+func packetCounts(roomClients [][]*client.Client) map[*client.Client]packetCount {
+	counts := make(map[*client.Client]packetCount)
+	for _, cs := range roomClients {
+		for _, c := range cs {
+			counts[c] = packetCount{sent: c.PacketsSent(), received: c.PacketsReceived()}
+		}
+	}
+	return counts
+}
+
+// publish sends opusFrame every 20ms until ctx ends. Listeners return at once
+// with client.ErrNotPublishing.
+func publish(ctx context.Context, c *client.Client) {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := c.WriteOpus(opusFrame, 960); err != nil {
+			return
+		}
+	}
+}
+
+func fetchStats(serverURL, adminKey string) (*serverStats, error) {
+	if adminKey == "" {
+		return nil, nil
+	}
+	u, err := url.JoinPath(serverURL, "admin")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, u+"?action=stats", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+adminKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("stats: %s", resp.Status)
+	}
+	stats := &serverStats{at: time.Now()}
+	if err := json.NewDecoder(resp.Body).Decode(stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,31 @@
+// Command protocol-schema writes the JSON Schema of the signaling messages
+// defined in pkg/protocol, for clients written in other languages.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"sigmartc/pkg/protocol"
+)
+
+func main() {
+	out := flag.String("o", "", "Write the schema to this file instead of stdout")
+	flag.Parse()
+
+	schema, err := protocol.Schema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "protocol-schema:", err)
+		os.Exit(1)
+	}
+	schema = append(schema, '\n')
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*out, schema, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "protocol-schema:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigmartc/internal/server"
+)
+
+// A -config file sets flags, one "name = value" per line; blank lines and
+// lines starting with "#" are skipped. Flags given on the command line win
+// over the file. SIGHUP and admin action=reload re-read it: reloadableFlags
+// take effect at once, and changes to other flags are logged as needing a
+// restart.
+
+// reloadableFlags are the flags behind server.Settings.
+var reloadableFlags = []string{
+	"log-level", "max-peers-per-ip", "max-publish-bitrate",
+	"turn-server", "turn-user", "turn-pass",
+	"nickname-filter", "nickname-mask", "geoip-allow", "geoip-deny",
+}
+
+// configSource resolves flag values from the command line, the -config file
+// and the flags' defaults.
+type configSource struct {
+	flags *flag.FlagSet
+	path  string
+	// explicit are the flags set on the command line.
+	explicit map[string]bool
+	// started holds every flag's value at startup.
+	started map[string]string
+}
+
+// newConfigSource applies the -config file at path, when set, to the flags
+// not given on the command line.
+func newConfigSource(flags *flag.FlagSet, path string) (*configSource, error) {
+	c := &configSource{flags: flags, path: path, explicit: make(map[string]bool), started: make(map[string]string)}
+	flags.Visit(func(f *flag.Flag) { c.explicit[f.Name] = true })
+	if path != "" {
+		values, err := readConfigFile(path, flags)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range values {
+			if c.explicit[name] {
+				continue
+			}
+			if err := flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("%s: -%s: %w", path, name, err)
+			}
+		}
+	}
+	flags.VisitAll(func(f *flag.Flag) { c.started[f.Name] = f.Value.String() })
+	return c, nil
+}
+
+// readConfigFile parses a -config file into flag values.
+func readConfigFile(path string, flags *flag.FlagSet) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimLeft(strings.TrimSpace(name), "-")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want name = value", path, i+1)
+		}
+		if flags.Lookup(name) == nil || name == "config" {
+			return nil, fmt.Errorf("%s:%d: unknown flag %q", path, i+1, name)
+		}
+		values[name] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+// values returns every flag's value as a reload sees it: the command line's,
+// else the -config file's, else the default.
+func (c *configSource) values() (map[string]string, error) {
+	values := maps.Clone(c.started)
+	if c.path == "" {
+		return values, nil
+	}
+	file, err := readConfigFile(c.path, c.flags)
+	if err != nil {
+		return nil, err
+	}
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if c.explicit[f.Name] {
+			return
+		}
+		if value, ok := file[f.Name]; ok {
+			values[f.Name] = value
+		} else {
+			values[f.Name] = f.DefValue
+		}
+	})
+	return values, nil
+}
+
+// settings reads the reloadable settings, loading the nickname filter file
+// again. It is the handler's LoadSettings.
+func (c *configSource) settings() (server.Settings, error) {
+	values, err := c.values()
+	if err != nil {
+		return server.Settings{}, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if !slices.Contains(reloadableFlags, name) && values[name] != c.started[name] {
+			slog.Warn("Setting change needs a restart", "flag", name, "from", c.started[name], "to", values[name])
+		}
+	}
+
+	s := server.Settings{
+		LogLevel:   values["log-level"],
+		ICEServers: buildICEConfiguration(parseICEURLs(values["turn-server"]), values["turn-user"], values["turn-pass"]).ICEServers,
+		GeoAllow:   server.ParseCountryList(values["geoip-allow"]),
+		GeoDeny:    server.ParseCountryList(values["geoip-deny"]),
+	}
+	if s.MaxPeersPerIP, err = strconv.Atoi(values["max-peers-per-ip"]); err != nil {
+		return server.Settings{}, fmt.Errorf("-max-peers-per-ip: %w", err)
+	}
+	if s.MaxPublishBitrate, err = strconv.Atoi(values["max-publish-bitrate"]); err != nil {
+		return server.Settings{}, fmt.Errorf("-max-publish-bitrate: %w", err)
+	}
+	if path := values["nickname-filter"]; path != "" {
+		if s.NicknameFilter, err = server.LoadNicknameFilter(path); err != nil {
+			return server.Settings{}, fmt.Errorf("-nickname-filter: %w", err)
+		}
+		if s.NicknameFilter.Mask, err = strconv.ParseBool(values["nickname-mask"]); err != nil {
+			return server.Settings{}, fmt.Errorf("-nickname-mask: %w", err)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigSourceLayersFileUnderCommandLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sigmartc.conf")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("# limits\n-max-peers-per-ip = 3\nlog-level=debug\nturn-server = turn:relay.example.com:3478\n")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	maxPeers := flags.Int("max-peers-per-ip", 0, "")
+	logLevel := flags.String("log-level", "info", "")
+	flags.Int("max-publish-bitrate", 0, "")
+	for _, name := range []string{"turn-server", "turn-user", "turn-pass", "nickname-filter", "geoip-allow", "geoip-deny", "config"} {
+		flags.String(name, "", "")
+	}
+	flags.Bool("nickname-mask", false, "")
+	if err := flags.Parse([]string{"-log-level", "warn"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := newConfigSource(flags, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *maxPeers != 3 || *logLevel != "warn" {
+		t.Fatalf("max-peers-per-ip = %d, log-level = %q; want the file's 3 and the command line's warn", *maxPeers, *logLevel)
+	}
+
+	write("max-peers-per-ip = 5\nlog-level = error\n")
+	s, err := config.settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.MaxPeersPerIP != 5 || s.LogLevel != "warn" || len(s.ICEServers) != 1 {
+		t.Fatalf("settings = %+v; want the TURN server removed with the file line", s)
+	}
+
+	write("max-peers = 5\n")
+	if _, err := config.settings(); err == nil {
+		t.Fatal("expected an error for an unknown flag")
+	}
+}
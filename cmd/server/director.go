@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"sigmartc/internal/director"
+)
+
+// runDirector runs "sigmartc director": the room placement service in front
+// of a fleet of media nodes (see internal/director). It returns the exit code.
+func runDirector(args []string) int {
+	flags := flag.NewFlagSet("director", flag.ExitOnError)
+	listen := flags.String("listen", ":8090", "HTTP address serving /api/place, /r/{room} and /api/nodes")
+	nodes := flags.String("nodes", "", "Comma-separated public base URLs of the media nodes, e.g. https://media1.example.com,https://media2.example.com")
+	pollInterval := flags.Duration("poll-interval", director.DefaultPollInterval, "How often each node's /api/load is polled")
+	roomTTL := flags.Duration("room-ttl", director.DefaultRoomTTL, "How long a room stays placed on its node after its last lookup")
+	flags.Parse(args)
+
+	d, err := director.New(splitCommaList(*nodes))
+	if err != nil {
+		slog.Error("Invalid -nodes", "err", err)
+		return 2
+	}
+	d.PollInterval = *pollInterval
+	d.RoomTTL = *roomTTL
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go d.Run(ctx)
+
+	srv := &http.Server{Addr: *listen, Handler: d.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("Director started", "addr", *listen, "nodes", len(d.Nodes()), "version", Version)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Director failed", "err", err)
+		return 1
+	}
+	return 0
+}
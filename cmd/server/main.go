@@ -1,16 +1,25 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"sigmartc/internal/banstore"
+	"sigmartc/internal/eventdb"
 	"sigmartc/internal/logger"
 	"sigmartc/internal/server"
+	"sigmartc/internal/stt"
+	"sigmartc/internal/tts"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -32,16 +41,70 @@ type clientICEServer struct {
 }
 
 func parseICEURLs(raw string) []string {
+	return splitCommaList(raw)
+}
+
+func splitCommaList(raw string) []string {
 	parts := strings.Split(raw, ",")
-	urls := make([]string, 0, len(parts))
+	items := make([]string, 0, len(parts))
 	for _, part := range parts {
-		url := strings.TrimSpace(part)
-		if url == "" {
+		item := strings.TrimSpace(part)
+		if item == "" {
 			continue
 		}
-		urls = append(urls, url)
+		items = append(items, item)
+	}
+	return items
+}
+
+// newICEUDPMux listens for ICE on port. Without listenIPs it binds every
+// interface address of both families, as seen at startup. An unspecified IP
+// ("::" or "0.0.0.0") binds one wildcard socket instead, dual-stack for "::",
+// which also serves addresses that appear later; other IPs bind just those.
+func newICEUDPMux(port int, listenIPs []string) (ice.UDPMux, error) {
+	if len(listenIPs) == 0 {
+		return ice.NewMultiUDPMuxFromPort(port)
+	}
+	ips := make([]net.IP, 0, len(listenIPs))
+	loopback := false
+	for _, raw := range listenIPs {
+		ip := net.ParseIP(raw)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP %q", raw)
+		}
+		if ip.IsUnspecified() {
+			if len(listenIPs) > 1 {
+				return nil, fmt.Errorf("%s cannot be combined with other IPs", raw)
+			}
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port})
+			if err != nil {
+				return nil, err
+			}
+			return ice.NewUDPMuxDefault(ice.UDPMuxParams{UDPConn: conn}), nil
+		}
+		loopback = loopback || ip.IsLoopback()
+		ips = append(ips, ip)
+	}
+	opts := []ice.UDPMuxFromPortOption{ice.UDPMuxFromPortWithIPFilter(func(ip net.IP) bool {
+		for _, listen := range ips {
+			if listen.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	})}
+	if loopback {
+		opts = append(opts, ice.UDPMuxFromPortWithLoopback())
+	}
+	mux, err := ice.NewMultiUDPMuxFromPort(port, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(mux.GetListenAddresses()) < len(ips) {
+		_ = mux.Close()
+		return nil, fmt.Errorf("not every IP in %s is an up interface address", strings.Join(listenIPs, ","))
 	}
-	return urls
+	return mux, nil
 }
 
 func buildICEConfiguration(turnURLs []string, turnUser, turnPass string) *webrtc.Configuration {
@@ -60,53 +123,212 @@ func buildICEConfiguration(turnURLs []string, turnUser, turnPass string) *webrtc
 }
 
 func buildClientICEConfig(turnURLs []string, turnUser, turnPass string) clientICEConfig {
-	config := clientICEConfig{
-		ICEServers: []clientICEServer{{URLs: []string{defaultSTUNServer}}},
-	}
-	if len(turnURLs) > 0 {
+	return clientICEConfigFor(buildICEConfiguration(turnURLs, turnUser, turnPass).ICEServers)
+}
+
+// clientICEConfigFor mirrors the server's ICE servers for browsers; it follows
+// reloads of the TURN flags.
+func clientICEConfigFor(servers []webrtc.ICEServer) clientICEConfig {
+	config := clientICEConfig{ICEServers: make([]clientICEServer, 0, len(servers))}
+	for _, server := range servers {
+		credential, _ := server.Credential.(string)
 		config.ICEServers = append(config.ICEServers, clientICEServer{
-			URLs:       turnURLs,
-			Username:   turnUser,
-			Credential: turnPass,
+			URLs:       server.URLs,
+			Username:   server.Username,
+			Credential: credential,
 		})
 	}
 	return config
 }
 
-func marshalClientICEConfig(turnURLs []string, turnUser, turnPass string) ([]byte, error) {
-	config := buildClientICEConfig(turnURLs, turnUser, turnPass)
-	return json.Marshal(config)
-}
-
 var Version = "dev"
 var BuildTime = "unknown"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "director" {
+		os.Exit(runDirector(os.Args[2:]))
+	}
+
+	configPath := flag.String("config", "", "File of flag settings, one name = value per line, overridden by the command line; SIGHUP and admin action=reload re-read it (empty uses the command line only)")
 	port := flag.Int("port", 8080, "HTTP Port")
 	adminKey := flag.String("admin-key", "change-me-123", "Admin panel secret key")
 	rtcUDPPort := flag.Int("rtc-udp-port", 50000, "WebRTC ICE UDP port")
+	rtcListenIP := flag.String("rtc-listen-ip", "", "Comma-separated IPs the ICE UDP mux binds; \"::\" binds one dual-stack socket, \"0.0.0.0\" one IPv4 socket (empty binds every IPv4 and IPv6 interface address)")
 	turnServer := flag.String("turn-server", "", "Comma-separated TURN server URLs (e.g., turn:your-server.com:3478,turns:your-server.com:5349?transport=tcp)")
 	turnUser := flag.String("turn-user", "", "TURN server username")
 	turnPass := flag.String("turn-pass", "", "TURN server password")
+	logSinks := flag.String("log-sinks", "stdout,file:server.log", "Comma-separated log sinks: stdout, stderr, file:<path>, syslog[:udp://host:port], loki:<push-url>, elastic:<bulk-url>")
+	logLevel := flag.String("log-level", "info", "Log level: debug, info, warn, error (adjustable at runtime via the admin API)")
+	sentryDSN := flag.String("sentry-dsn", "", "Report ERROR logs and panics to this Sentry-compatible DSN, e.g. https://<key>@sentry.example.com/<project> (empty disables)")
+	sentrySampleRate := flag.Float64("sentry-sample-rate", 1, "Fraction of distinct errors reported to -sentry-dsn")
+	accessLog := flag.Bool("access-log", false, "Log every HTTP request (method, path, status, latency, client IP) as an HTTP_ACCESS event")
+	skipSilence := flag.Bool("skip-silence", false, "Skip forwarding silent audio (zero audio level or Opus DTX), keeping a comfort-noise packet every 400ms")
+	maxPublishBitrate := flag.Int("max-publish-bitrate", 0, "Cap each publisher's inbound audio at this many kbps; over it the server sends REMB/TMMBR and then drops packets (0 disables)")
+	jitterBuffer := flag.Duration("jitter-buffer", 0, "Reorder out-of-order RTP for up to this long before forwarding, e.g. 40ms (0 disables)")
+	chaos := flag.Bool("chaos", false, "Allow admins to inject packet loss, jitter and reordering into forwarded audio (action=impair); for testing and staging only")
+	capacityForwarders := flag.Int("capacity-forwarders", 0, "Published tracks this node can forward at once, scoring /api/load (0 leaves forwarders out of the score)")
+	capacityMbps := flag.Float64("capacity-mbps", 0, "RTP bandwidth in Mbps this node can carry in either direction, scoring /api/load (0 leaves bandwidth out of the score)")
+	directorURL := flag.String("director", "", "Base URL of the director (sigmartc director) placing rooms; WebSocket joins for rooms it placed on other nodes get a redirect message (needs -public-url; empty serves every room here)")
+	publicURL := flag.String("public-url", "", "This node's public base URL as listed in the director's -nodes, e.g. https://media1.example.com")
+	banStoreSpec := flag.String("ban-store", "", "Where bans persist: sqlite:<path> or redis://[:password@]host:port[/db][?key=<set>] to share them between nodes, or a JSON file path (empty uses banned_ips.json)")
+	banListWatch := flag.Duration("ban-list-watch", 0, "Check the ban store for changes this often and apply bans made by other tools or nodes live, e.g. 2s (0 reads it only at startup and on reload)")
+	maxPeersPerIP := flag.Int("max-peers-per-ip", 0, "Maximum peers from one IP in a single room (0 is unlimited)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "Disconnect peers with no published audio or signaling for this long (0 disables)")
+	auditLogPath := flag.String("audit-log", "audit.log", "Append-only admin audit log file")
+	usageLogPath := flag.String("usage-log", "usage.log", "Append-only room session log backing usage reports (empty disables)")
+	eventDBPath := flag.String("event-db", "", "SQLite database persisting events, the audit log and usage sessions across restarts; replaces -audit-log and -usage-log (empty keeps file-only logging)")
+	tenantsFile := flag.String("tenants", "", "JSON file of tenants, each with its own room namespace, limits, TURN secret, webhooks and admin API key (empty disables)")
+	webhookURLs := flag.String("webhook-url", "", "Comma-separated webhook URLs receiving lifecycle events")
+	webhookSecret := flag.String("webhook-secret", "", "HMAC-SHA256 secret used to sign webhook deliveries")
+	ffmpegPath := flag.String("ffmpeg-path", "ffmpeg", "ffmpeg binary used for RTMP/Icecast room egress (empty disables egress)")
+	serverGain := flag.Bool("server-gain", false, "Apply listeners' per-peer gain by re-encoding their audio with ffmpeg, one process per adjusted pair (CPU-intensive; off gates quiet packets instead)")
+	denoiseModel := flag.String("denoise-model", "", "RNNoise .rnnn model file letting hosts and admins denoise a peer's audio through ffmpeg's arnndn filter (empty disables)")
+	hlsDir := flag.String("hls-dir", "", "Directory for per-room HLS output served at /hls/{room}/{token}/index.m3u8 to rooms enabled with action=hls_enable (empty disables)")
+	soundboardDir := flag.String("soundboard-dir", "", "Directory of Ogg Opus clips admins can play into rooms (empty disables)")
+	sttSpec := flag.String("stt", "", "Speech-to-text backend for room transcription: command:<shell cmd> (WAV on stdin, text on stdout) or http:<url> (whisper.cpp or OpenAI-compatible; empty disables)")
+	sttAPIKey := flag.String("stt-api-key", "", "Bearer token sent to an http: STT backend")
+	sttModel := flag.String("stt-model", "", "Model name sent to an http: STT backend, e.g. whisper-1")
+	ttsSpec := flag.String("tts", "", "Text-to-speech backend for announcements: command:<shell cmd> or http:<url> (must return Ogg Opus; empty disables)")
+	nicknameFilter := flag.String("nickname-filter", "", "Nickname deny-list file: one word per line, or re:<regexp> (empty disables)")
+	nicknameMask := flag.Bool("nickname-mask", false, "Mask denied words in nicknames with asterisks instead of rejecting the join")
+	iceRestartDelay := flag.Duration("ice-restart-delay", 5*time.Second, "How long ICE may stay disconnected before the server offers an ICE restart")
+	iceRestartMin := flag.Duration("ice-restart-min-interval", 15*time.Second, "Minimum time between ICE restarts of one peer")
+	iceDisconnectedTimeout := flag.Duration("ice-disconnected-timeout", 8*time.Second, "ICE disconnected timeout")
+	iceFailedTimeout := flag.Duration("ice-failed-timeout", 30*time.Second, "ICE failed timeout")
+	iceKeepalive := flag.Duration("ice-keepalive-interval", 5*time.Second, "ICE keepalive (STUN binding) interval; keeps NAT mappings alive")
+	iceCandidateTypes := flag.String("ice-candidate-types", "", "Comma-separated server ICE candidate types sent to clients: host, srflx, prflx, relay, e.g. relay for relay-only (empty sends all)")
+	iceHidePrivate := flag.Bool("ice-hide-private", false, "Do not send clients server ICE candidates on private, loopback or link-local addresses")
+	dtlsCert := flag.String("dtls-cert", "dtls.pem", "PEM file holding the WebRTC DTLS certificate and key, generated on first start, so the fingerprint survives restarts (empty uses a new certificate per connection)")
+	forceRelay := flag.Bool("force-relay", false, "Make every room relay-only: the server and clients use iceTransportPolicy relay so media only flows through TURN (requires -turn-server)")
+	iceCredentialRefresh := flag.Duration("ice-credential-refresh-interval", 0, "Restart ICE on every peer this often to rotate ICE credentials on long calls; DTLS/SRTP keys are not rotated (0 disables)")
+	negotiationTimeout := flag.Duration("negotiation-timeout", 30*time.Second, "How long an offer/answer exchange may take before the peer is disconnected with negotiation_timeout")
+	roomQuota := flag.Uint64("room-quota-bytes", 0, "Monthly RTP byte quota per room, received plus forwarded; kept in memory, so a restart resets it (0 disables)")
+	roomQuotaMinutes := flag.Int("room-quota-minutes", 0, "Monthly participant-minutes quota per room; joins beyond it are refused with quota_exceeded (0 disables)")
+	roomQuotaAction := flag.String("room-quota-action", "warn", "What happens when a room exceeds -room-quota-bytes: warn, throttle (drop silent packets) or close")
+	geoIPDB := flag.String("geoip-db", "", "MaxMind GeoLite2/GeoIP2 Country database (.mmdb) for country tagging and rules (empty disables)")
+	geoIPAllow := flag.String("geoip-allow", "", "Comma-separated ISO country codes allowed to join (empty allows all; requires -geoip-db)")
+	geoIPDeny := flag.String("geoip-deny", "", "Comma-separated ISO country codes refused at join (requires -geoip-db)")
+	powDifficulty := flag.Int("pow-difficulty", 0, "Require joins to solve a proof-of-work challenge with this many leading zero bits, e.g. 18 (0 disables)")
+	captchaVerifyURL := flag.String("captcha-verify-url", "", "CAPTCHA siteverify endpoint checked at join (hCaptcha, reCAPTCHA or Turnstile; empty disables)")
+	captchaSecret := flag.String("captcha-secret", "", "Secret key sent to -captcha-verify-url")
+	trustedProxies := flag.String("trusted-proxies", server.DefaultTrustedProxies, "Comma-separated CIDRs of reverse proxies whose X-Forwarded-For/-Host/-Proto and X-Real-IP headers are trusted (empty trusts none)")
+	allowedOrigins := flag.String("allowed-origins", "", "Comma-separated extra origins allowed to open WebSockets, e.g. https://app.example.com,https://*.example.com (same origin is always allowed)")
+	jwtJWKSURL := flag.String("jwt-jwks-url", "", "Require a JWT on /ws, verified against this identity provider JWKS URL (empty disables)")
+	jwtIssuer := flag.String("jwt-issuer", "", "Required iss claim of join tokens (empty accepts any)")
+	jwtAudience := flag.String("jwt-audience", "", "Required aud claim of join tokens (empty accepts any)")
+	jwtNameClaim := flag.String("jwt-name-claim", "name", "Token claim used as the display name")
+	jwtRoleClaim := flag.String("jwt-role-claim", "role", "Token claim carrying the user's role")
+	canaryInterval := flag.Duration("canary", 0, "Join -canary-room with two internal clients this often, check media flows end to end and export the result on /metrics (0 disables)")
+	canaryRoom := flag.String("canary-room", "canary", "Room used by -canary")
+	grpcAddr := flag.String("grpc-addr", "", "Listen address for the gRPC control API, e.g. 127.0.0.1:9090 (empty disables)")
+	webTransportAddr := flag.String("webtransport-addr", "", "UDP listen address for WebTransport (HTTP/3) signaling at /wt, e.g. :4433; requires -webtransport-cert and -webtransport-key (empty disables)")
+	webTransportCert := flag.String("webtransport-cert", "", "TLS certificate (PEM) for -webtransport-addr")
+	webTransportKey := flag.String("webtransport-key", "", "TLS private key (PEM) for -webtransport-addr")
 	flag.Parse()
+	config, err := newConfigSource(flag.CommandLine, *configPath)
+	if err != nil {
+		fmt.Printf("Failed to read config: %v\n", err)
+		os.Exit(1)
+	}
 
 	turnURLs := parseICEURLs(*turnServer)
 
 	// 1. Initialize Logger
-	if err := logger.InitLogger("server.log"); err != nil {
+	sinks, err := logger.ParseSinks(*logSinks)
+	if err != nil {
+		fmt.Printf("Failed to init logger: %v\n", err)
+		os.Exit(1)
+	}
+	if *sentryDSN != "" {
+		sentry, err := logger.NewSentrySink(*sentryDSN, *sentrySampleRate)
+		if err != nil {
+			fmt.Printf("Invalid Sentry DSN: %v\n", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, sentry)
+	}
+	var eventDB *eventdb.DB
+	if *eventDBPath != "" {
+		eventDB, err = eventdb.Open(*eventDBPath)
+		if err != nil {
+			fmt.Printf("Failed to open event database: %v\n", err)
+			os.Exit(1)
+		}
+		// The logger owns its sinks and closes the database in logger.Close.
+		sinks = append(sinks, eventDB)
+	}
+	if err := logger.InitLoggerWithSinks(sinks); err != nil {
 		fmt.Printf("Failed to init logger: %v\n", err)
 		os.Exit(1)
 	}
 	defer logger.Close()
+	// Log a crash in main before it takes the process down so sinks, including
+	// -sentry-dsn, see it; the deferred logger.Close flushes them.
+	defer func() {
+		if v := recover(); v != nil {
+			slog.Error("Panic", "panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+			panic(v)
+		}
+	}()
+	if err := logger.SetLevel(*logLevel); err != nil {
+		slog.Error("Invalid log level", "level", *logLevel, "err", err)
+		logger.Exit(1)
+	}
 
 	// 2. Initialize Core Logic
 	rm := server.NewRoomManager(*adminKey, "banned_ips.json")
+	if *banStoreSpec != "" {
+		store, err := banstore.Open(*banStoreSpec)
+		if err != nil {
+			slog.Error("Failed to open ban store", "err", err)
+			logger.Exit(1)
+		}
+		defer store.Close()
+		rm.Bans = store
+		if _, _, err := rm.ReloadBanList(); err != nil {
+			slog.Error("Failed to load bans", "err", err)
+			logger.Exit(1)
+		}
+		slog.Info("Ban store opened", "bans", len(rm.BannedIPs))
+	}
+	if *tenantsFile != "" {
+		tenants, err := server.LoadTenants(*tenantsFile)
+		if err != nil {
+			slog.Error("Failed to load tenants", "err", err)
+			logger.Exit(1)
+		}
+		rm.Tenants = tenants
+		defer tenants.Close()
+		slog.Info("Tenants enabled", "path", *tenantsFile, "tenants", tenants.Len())
+	}
+	if urls := splitCommaList(*webhookURLs); len(urls) > 0 {
+		rm.Webhooks = server.NewWebhookDispatcher(urls, *webhookSecret)
+		defer rm.Webhooks.Close()
+		slog.Info("Webhooks enabled", "urls", len(urls), "signed", *webhookSecret != "")
+	}
+
+	if *roomQuota > 0 {
+		action, err := server.ParseQuotaAction(*roomQuotaAction)
+		if err != nil {
+			slog.Error("Invalid room quota action", "err", err)
+			logger.Exit(1)
+		}
+		rm.Quota = server.NewBandwidthQuota(*roomQuota, action)
+		slog.Info("Room bandwidth quota enabled", "monthly_bytes", *roomQuota, "action", action)
+	}
+	// Tenants are always metered so their usage can be reported.
+	if *roomQuotaMinutes > 0 || rm.Tenants != nil {
+		rm.Minutes = server.NewMinutesQuota(*roomQuotaMinutes)
+		if *roomQuotaMinutes > 0 {
+			slog.Info("Room minutes quota enabled", "monthly_minutes", *roomQuotaMinutes)
+		}
+	}
 
 	// 3. Setup WebRTC API with ICE UDP mux
-	udpMux, err := ice.NewMultiUDPMuxFromPort(*rtcUDPPort)
+	udpMux, err := newICEUDPMux(*rtcUDPPort, splitCommaList(*rtcListenIP))
 	if err != nil {
-		slog.Error("Failed to create ICE UDP mux", "err", err, "port", *rtcUDPPort)
-		os.Exit(1)
+		slog.Error("Failed to create ICE UDP mux", "err", err, "port", *rtcUDPPort, "listen_ip", *rtcListenIP)
+		logger.Exit(1)
 	}
 	defer func() {
 		if closeErr := udpMux.Close(); closeErr != nil {
@@ -114,45 +336,253 @@ func main() {
 		}
 	}()
 
-	m := &webrtc.MediaEngine{}
-	if err := m.RegisterDefaultCodecs(); err != nil {
+	m, err := server.NewMediaEngine()
+	if err != nil {
 		slog.Error("Failed to register codecs", "err", err)
-		os.Exit(1)
+		logger.Exit(1)
+	}
+
+	negotiation := server.NegotiationConfig{
+		ICERestartDelay:        *iceRestartDelay,
+		ICERestartMinInterval:  *iceRestartMin,
+		ICEDisconnectedTimeout: *iceDisconnectedTimeout,
+		ICEFailedTimeout:       *iceFailedTimeout,
+		ICEKeepaliveInterval:   *iceKeepalive,
+
+		ICECredentialRefreshInterval: *iceCredentialRefresh,
+		NegotiationTimeout:           *negotiationTimeout,
 	}
 
 	settings := webrtc.SettingEngine{}
 	settings.SetICEUDPMux(udpMux)
-	// ICE keepalive: send STUN binding indication every 8 seconds to maintain NAT mappings
-	// This helps prevent disconnections when ISP NAT entries expire (typically 30-60s)
-	settings.SetICETimeouts(8*time.Second, 30*time.Second, 5*time.Second)
+	negotiation.ApplyTo(&settings)
 
 	api := webrtc.NewAPI(
 		webrtc.WithMediaEngine(m),
 		webrtc.WithSettingEngine(settings),
 	)
 
-	slog.Info("ICE UDP mux enabled", "port", *rtcUDPPort)
+	iceAddrs := udpMux.GetListenAddresses()
+	families := server.AddressFamilies(iceAddrs)
+	slog.Info("ICE UDP mux enabled", "port", *rtcUDPPort, "families", families, "addresses", fmt.Sprint(iceAddrs))
+	if !slices.Contains(families, "ipv6") {
+		slog.Warn("ICE UDP mux has no IPv6 address; IPv6-only clients can only connect through TURN")
+	}
+	if !slices.Contains(families, "ipv4") {
+		slog.Warn("ICE UDP mux has no IPv4 address; IPv4-only clients can only connect through TURN")
+	}
 
 	iceConfig := buildICEConfiguration(turnURLs, *turnUser, *turnPass)
+	if *dtlsCert != "" {
+		cert, err := server.LoadDTLSCertificate(*dtlsCert)
+		if err != nil {
+			slog.Error("Failed to load DTLS certificate", "err", err, "path", *dtlsCert)
+			logger.Exit(1)
+		}
+		iceConfig.Certificates = []webrtc.Certificate{*cert}
+		fingerprint, _ := server.DTLSFingerprint(*cert)
+		slog.Info("DTLS certificate loaded", "path", *dtlsCert, "fingerprint", fingerprint, "expires", cert.Expires().Format(time.DateOnly))
+	}
 	if len(turnURLs) > 0 {
 		slog.Info("TURN server configured", "servers", turnURLs)
 	}
 
-	h := server.NewHandler(rm, api, iceConfig)
+	h := server.NewHandler(rm, api, iceConfig, &negotiation)
+	h.ICEAddresses = iceAddrs
+	candidateTypes, err := server.ParseCandidateTypes(*iceCandidateTypes)
+	if err != nil {
+		slog.Error("Invalid -ice-candidate-types", "err", err)
+		logger.Exit(1)
+	}
+	h.CandidateFilter = server.CandidateFilter{Types: candidateTypes, HidePrivate: *iceHidePrivate}
+	if h.CandidateFilter.Enabled() {
+		slog.Info("ICE candidate filter enabled", "types", *iceCandidateTypes, "hide_private", *iceHidePrivate)
+	}
+	if *forceRelay {
+		if len(turnURLs) == 0 {
+			slog.Error("-force-relay requires -turn-server")
+			logger.Exit(1)
+		}
+		h.ForceRelay = true
+		slog.Info("Relay-only mode enabled for every room")
+	}
+	proxies, err := server.ParseTrustedProxies(*trustedProxies)
+	if err != nil {
+		slog.Error("Invalid -trusted-proxies", "err", err)
+		logger.Exit(1)
+	}
+	h.TrustedProxies = proxies
+	origins, err := server.ParseAllowedOrigins(*allowedOrigins)
+	if err != nil {
+		slog.Error("Invalid -allowed-origins", "err", err)
+		logger.Exit(1)
+	}
+	h.AllowedOrigins = origins
+	h.IdleTimeout = *idleTimeout
+	h.MaxPeersPerIP = *maxPeersPerIP
+	h.LoadSettings = config.settings
+	if *directorURL != "" {
+		if *publicURL == "" {
+			slog.Error("-director needs -public-url")
+			logger.Exit(1)
+		}
+		locator, err := server.NewDirectorLocator(*directorURL)
+		if err != nil {
+			slog.Error("Invalid -director", "err", err)
+			logger.Exit(1)
+		}
+		h.Locator = locator
+		h.PublicURL = strings.TrimRight(*publicURL, "/")
+		slog.Info("Room redirects enabled", "director", *directorURL, "public_url", h.PublicURL)
+	}
+	h.LoadCapacity = server.LoadCapacity{Forwarders: *capacityForwarders, BandwidthBps: *capacityMbps * 1e6}
+	h.JitterBuffer = *jitterBuffer
+	h.Chaos = *chaos
+	if *chaos {
+		slog.Warn("Chaos mode enabled: admins can impair forwarded audio")
+	}
+	h.SkipSilence = *skipSilence
+	h.MaxPublishBitrate = *maxPublishBitrate
+	h.FFmpegPath = *ffmpegPath
+	h.ServerGain = *serverGain
+	if *denoiseModel != "" {
+		if strings.ContainsAny(*denoiseModel, `'\`) {
+			slog.Error("Invalid -denoise-model: path must not contain quotes or backslashes", "path", *denoiseModel)
+			logger.Exit(1)
+		}
+		if _, err := os.Stat(*denoiseModel); err != nil {
+			slog.Error("Invalid -denoise-model", "err", err)
+			logger.Exit(1)
+		}
+		h.DenoiseModel = *denoiseModel
+	}
+	h.HLSDir = *hlsDir
+	h.SoundboardDir = *soundboardDir
+	if *ttsSpec != "" {
+		synth, err := tts.New(*ttsSpec)
+		if err != nil {
+			slog.Error("Invalid TTS backend", "err", err)
+			logger.Exit(1)
+		}
+		h.TTS = synth
+	}
+	if *sttSpec != "" {
+		transcriber, err := stt.New(*sttSpec, *sttAPIKey, *sttModel)
+		if err != nil {
+			slog.Error("Invalid STT backend", "err", err)
+			logger.Exit(1)
+		}
+		h.STT = transcriber
+	}
+	if *nicknameFilter != "" {
+		filter, err := server.LoadNicknameFilter(*nicknameFilter)
+		if err != nil {
+			slog.Error("Failed to load nickname filter", "err", err, "path", *nicknameFilter)
+			logger.Exit(1)
+		}
+		filter.Mask = *nicknameMask
+		h.NicknameFilter = filter
+	}
+	if *geoIPDB != "" {
+		geo, err := server.OpenGeoIP(*geoIPDB)
+		if err != nil {
+			slog.Error("Failed to open GeoIP database", "err", err, "path", *geoIPDB)
+			logger.Exit(1)
+		}
+		defer geo.Close()
+		geo.Allow = server.ParseCountryList(*geoIPAllow)
+		geo.Deny = server.ParseCountryList(*geoIPDeny)
+		h.GeoIP = geo
+		slog.Info("GeoIP enabled", "allow", len(geo.Allow), "deny", len(geo.Deny))
+	} else if *geoIPAllow != "" || *geoIPDeny != "" {
+		slog.Error("-geoip-allow and -geoip-deny require -geoip-db")
+		logger.Exit(1)
+	}
+	switch {
+	case *powDifficulty > 0 && *captchaVerifyURL != "":
+		slog.Error("-pow-difficulty and -captcha-verify-url are mutually exclusive")
+		logger.Exit(1)
+	case *powDifficulty > 256:
+		slog.Error("-pow-difficulty must be at most 256", "difficulty", *powDifficulty)
+		logger.Exit(1)
+	case *powDifficulty > 0:
+		h.Challenge = server.NewProofOfWork(*powDifficulty)
+		slog.Info("Join proof of work enabled", "difficulty", *powDifficulty)
+	case *captchaVerifyURL != "":
+		h.Challenge = server.NewCaptchaVerifier(*captchaVerifyURL, *captchaSecret)
+		slog.Info("Join CAPTCHA enabled", "verify_url", *captchaVerifyURL)
+	}
+	if *jwtJWKSURL != "" {
+		auth := server.NewJWTAuth(*jwtJWKSURL, *jwtIssuer, *jwtAudience)
+		auth.NameClaim = *jwtNameClaim
+		auth.RoleClaim = *jwtRoleClaim
+		h.JWTAuth = auth
+		slog.Info("JWT join authentication enabled", "jwks_url", *jwtJWKSURL, "issuer", *jwtIssuer, "audience", *jwtAudience)
+	}
+	if *canaryInterval > 0 {
+		// The canary joins like any client, so it cannot pass JWT auth or a join challenge.
+		if h.JWTAuth != nil || h.Challenge != nil {
+			slog.Error("-canary cannot be used with -jwt-jwks-url, -pow-difficulty or -captcha-verify-url")
+			logger.Exit(1)
+		}
+		canary, err := server.NewCanary(fmt.Sprintf("http://127.0.0.1:%d", *port), *canaryRoom, *canaryInterval)
+		if err != nil {
+			slog.Error("Failed to create canary", "err", err)
+			logger.Exit(1)
+		}
+		h.Canary = canary
+	}
+	if eventDB != nil {
+		h.EventDB = eventDB
+		h.Audit = server.NewAuditLogDB(eventDB)
+		rm.Usage = server.NewUsageLogDB(eventDB)
+	} else if *auditLogPath != "" {
+		auditLog, err := server.NewAuditLog(*auditLogPath)
+		if err != nil {
+			slog.Error("Failed to open audit log", "err", err, "path", *auditLogPath)
+			logger.Exit(1)
+		}
+		defer auditLog.Close()
+		h.Audit = auditLog
+	}
+	if eventDB == nil && *usageLogPath != "" {
+		usageLog, err := server.NewUsageLog(*usageLogPath)
+		if err != nil {
+			slog.Error("Failed to open usage log", "err", err, "path", *usageLogPath)
+			logger.Exit(1)
+		}
+		defer usageLog.Close()
+		rm.Usage = usageLog
+	}
+	if err := rm.SeedMinutes(time.Now()); err != nil {
+		slog.Warn("Failed to restore minutes quotas from the usage log", "err", err)
+	}
 
 	// 4. Routing
 	mux := http.NewServeMux()
 
 	// API & Signaling
 	mux.HandleFunc("/ws", h.HandleWS)
+	mux.HandleFunc("/poll", h.HandlePoll)
+	mux.HandleFunc("/hls/", h.HandleHLS)
+	mux.Handle("/api/challenge", withSecurityHeaders(http.HandlerFunc(h.HandleChallenge)))
+	mux.Handle("/api/rooms", withSecurityHeaders(http.HandlerFunc(h.HandleRooms)))
+	mux.HandleFunc("/metrics", h.HandleMetrics)
+	mux.HandleFunc("/readyz", h.HandleReadyz)
+	mux.HandleFunc("/api/load", h.HandleLoad)
+	mux.Handle("/api/dtls", withSecurityHeaders(http.HandlerFunc(h.HandleDTLS)))
 	mux.Handle("/admin", withSecurityHeaders(http.HandlerFunc(h.HandleAdmin)))
+	mux.Handle("/admin/login", withSecurityHeaders(http.HandlerFunc(h.HandleAdminLogin)))
+	mux.Handle("/admin/logout", withSecurityHeaders(http.HandlerFunc(h.HandleAdminLogout)))
+	mux.Handle("/api/admin/logs", withSecurityHeaders(http.HandlerFunc(h.HandleAdminLogs)))
+	mux.Handle("/admin/events", withSecurityHeaders(http.HandlerFunc(h.HandleAdminEvents)))
 
 	// Dynamic config.js endpoint (must be before static file server)
 	mux.HandleFunc("/static/js/config.js", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/javascript")
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
-		clientConfig, err := marshalClientICEConfig(turnURLs, *turnUser, *turnPass)
+		clientConfig, err := json.Marshal(clientICEConfigFor(h.Settings().ICEServers))
 		if err != nil {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			slog.Error("Failed to marshal ICE config", "err", err)
@@ -163,8 +593,12 @@ func main() {
 	})
 
 	// Frontend Static Files
-	fs := http.FileServer(http.Dir("web/static"))
-	mux.Handle("/static/", withSecurityHeaders(http.StripPrefix("/static/", fs)))
+	assets, err := server.LoadStaticAssets("web/static")
+	if err != nil {
+		slog.Error("Failed to load static files", "err", err)
+		logger.Exit(1)
+	}
+	mux.Handle("/static/", withSecurityHeaders(http.StripPrefix("/static/", assets)))
 
 	// SPA Routing: All /r/* or / paths serve index.html
 	mux.Handle("/", withSecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -180,9 +614,12 @@ func main() {
 			data := struct {
 				Version   string
 				BuildTime string
+				// Assets maps static file names to their content-hashed URLs.
+				Assets map[string]string
 			}{
 				Version:   Version,
 				BuildTime: BuildTime,
+				Assets:    assets.Manifest(),
 			}
 
 			if err := tmpl.Execute(w, data); err != nil {
@@ -197,18 +634,79 @@ func main() {
 	serverAddr := fmt.Sprintf(":%d", *port)
 	slog.Info("GhostTalk Server Starting", "port", *port)
 
+	var root http.Handler = server.RecoverHTTP(mux)
+	if *accessLog {
+		root = h.AccessLog(root)
+	}
 	go func() {
-		if err := http.ListenAndServe(serverAddr, mux); err != nil {
+		if err := http.ListenAndServe(serverAddr, root); err != nil {
 			slog.Error("Server failed", "err", err)
-			os.Exit(1)
+			logger.Exit(1)
 		}
 	}()
 
+	if *banListWatch > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go rm.WatchBanList(ctx, *banListWatch)
+		slog.Info("Watching ban list", "interval", *banListWatch)
+	}
+
+	if h.Canary != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go h.Canary.Run(ctx)
+		slog.Info("Canary enabled", "room", *canaryRoom, "interval", *canaryInterval)
+	}
+
+	if *grpcAddr != "" {
+		grpcListener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			slog.Error("Failed to listen for gRPC", "addr", *grpcAddr, "err", err)
+			logger.Exit(1)
+		}
+		controlServer := server.NewControlServer(h)
+		defer controlServer.GracefulStop()
+		slog.Info("gRPC control API listening", "addr", grpcListener.Addr().String())
+		go func() {
+			if err := controlServer.Serve(grpcListener); err != nil {
+				slog.Error("gRPC server failed", "err", err)
+			}
+		}()
+	}
+
+	if *webTransportAddr != "" {
+		cert, err := tls.LoadX509KeyPair(*webTransportCert, *webTransportKey)
+		if err != nil {
+			slog.Error("Failed to load WebTransport certificate", "cert", *webTransportCert, "key", *webTransportKey, "err", err)
+			logger.Exit(1)
+		}
+		wtServer := h.NewWebTransportServer(*webTransportAddr, &tls.Config{Certificates: []tls.Certificate{cert}})
+		defer wtServer.Close()
+		slog.Info("WebTransport signaling listening", "addr", *webTransportAddr)
+		go func() {
+			if err := wtServer.ListenAndServe(); err != nil {
+				slog.Error("WebTransport server failed", "err", err)
+			}
+		}()
+	}
+
 	// Graceful Shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("SIGHUP received, reloading settings")
+			if _, err := h.Reload(); err != nil {
+				slog.Error("Reload failed", "err", err)
+			}
+		}
+	}()
 	<-stop
 	slog.Info("Shutting down...")
+	rm.Shutdown()
 }
 
 func withSecurityHeaders(next http.Handler) http.Handler {
@@ -244,7 +742,7 @@ func buildCSP(r *http.Request) string {
 		"form-action 'self'",
 		"script-src 'self'",
 		"style-src 'self' 'unsafe-inline'",
-		"img-src 'self' data:",
+		"img-src 'self' data: https:", // room avatars are arbitrary https URLs
 		"media-src 'self' blob:",
 		"connect-src " + connectSrc,
 	}, "; ")
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"net"
 	"reflect"
 	"testing"
 )
@@ -40,14 +41,14 @@ func TestBuildICEConfigurationIncludesTURNURLs(t *testing.T) {
 	}
 }
 
-func TestMarshalClientICEConfigPreservesSpecialCharacters(t *testing.T) {
-	data, err := marshalClientICEConfig(
+func TestClientICEConfigPreservesSpecialCharacters(t *testing.T) {
+	data, err := json.Marshal(buildClientICEConfig(
 		[]string{"turns:relay.example.com:5349?transport=tcp"},
 		"user'name",
 		"pa\"ss",
-	)
+	))
 	if err != nil {
-		t.Fatalf("marshalClientICEConfig() error = %v", err)
+		t.Fatalf("json.Marshal() error = %v", err)
 	}
 
 	var config clientICEConfig
@@ -64,3 +65,22 @@ func TestMarshalClientICEConfigPreservesSpecialCharacters(t *testing.T) {
 		t.Fatalf("credential = %q, want %q", config.ICEServers[1].Credential, "pa\"ss")
 	}
 }
+
+func TestNewICEUDPMuxBindsListenIPs(t *testing.T) {
+	mux, err := newICEUDPMux(0, []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mux.Close()
+	addrs := mux.GetListenAddresses()
+	if len(addrs) != 1 || !addrs[0].(*net.UDPAddr).IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("listen addresses = %v, want 127.0.0.1", addrs)
+	}
+
+	for _, bad := range [][]string{{"not-an-ip"}, {"192.0.2.123"}, {"::", "127.0.0.1"}} {
+		if mux, err := newICEUDPMux(0, bad); err == nil {
+			mux.Close()
+			t.Fatalf("newICEUDPMux(%v) succeeded", bad)
+		}
+	}
+}
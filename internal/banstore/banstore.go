@@ -0,0 +1,248 @@
+// Package banstore persists IP bans in a JSON file, a SQLite database or Redis,
+// so clustered media nodes can share one ban list.
+package banstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// Store holds bans by key: an IP, or "<tenant>/<ip>" for a tenant's ban.
+type Store interface {
+	Add(key string) error
+	Remove(key string) error
+	// Load returns every ban.
+	Load() (map[string]bool, error)
+	// Version changes whenever the bans do, including changes made by other
+	// processes, so callers can skip a Load when it is unchanged.
+	Version() (string, error)
+	Close() error
+}
+
+// Open creates a store from a spec:
+//
+//	<path> or file:<path>     JSON object of "<key>": true, rewritten on change
+//	sqlite:<path>             a bans table in a SQLite database
+//	redis://[:password@]host:port[/db][?key=<set>]   a Redis set (default key sigmartc:bans)
+func Open(spec string) (Store, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "":
+		return nil, errors.New("empty ban store")
+	case strings.HasPrefix(spec, "redis://"):
+		return OpenRedis(spec)
+	case strings.HasPrefix(spec, "sqlite:"):
+		return OpenSQLite(strings.TrimPrefix(spec, "sqlite:"))
+	default:
+		return &File{Path: strings.TrimPrefix(spec, "file:")}, nil
+	}
+}
+
+// File stores bans as one JSON object. Writes replace the file atomically, so
+// other tools can read it at any time; they should write it the same way.
+type File struct {
+	Path string
+}
+
+// Load reads the file; a missing file holds no bans.
+func (f *File) Load() (map[string]bool, error) {
+	bans := make(map[string]bool)
+	data, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return bans, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+func (f *File) Add(key string) error {
+	return f.update(func(bans map[string]bool) { bans[key] = true })
+}
+
+func (f *File) Remove(key string) error {
+	return f.update(func(bans map[string]bool) { delete(bans, key) })
+}
+
+func (f *File) update(change func(map[string]bool)) error {
+	bans, err := f.Load()
+	if err != nil {
+		return err
+	}
+	change(bans)
+	data, err := json.Marshal(bans)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.Path, data, 0644)
+}
+
+// Version is the file's size and modification time.
+func (f *File) Version() (string, error) {
+	info, err := os.Stat(f.Path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d@%d", info.Size(), info.ModTime().UnixNano()), nil
+}
+
+func (f *File) Close() error { return nil }
+
+// writeFileAtomic replaces path with data through a temporary file in the same
+// directory and a rename, so readers see the old or the new contents, never a
+// partial write. A symlinked path, such as the Docker image's link into its
+// data volume, has its target replaced and the link kept.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	path, err := resolveSymlinks(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resolveSymlinks follows path while it is a symlink. Unlike
+// filepath.EvalSymlinks it also resolves a link whose target does not exist
+// yet, as the data volume's ban file does before the first ban.
+func resolveSymlinks(path string) (string, error) {
+	for range 40 {
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return "", fmt.Errorf("%s: too many levels of symbolic links", path)
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS bans (
+	key        TEXT PRIMARY KEY,
+	created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+CREATE TABLE IF NOT EXISTS bans_version (
+	id      INTEGER PRIMARY KEY CHECK (id = 1),
+	version INTEGER NOT NULL
+);
+INSERT OR IGNORE INTO bans_version (id, version) VALUES (1, 0);
+`
+
+// SQLite stores one row per ban. Nodes sharing the database file (on one host
+// or a shared volume) share the bans.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens or creates the database at path.
+func OpenSQLite(path string) (*SQLite, error) {
+	if path == "" {
+		return nil, errors.New("missing SQLite path")
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Add(key string) error {
+	return s.change(`INSERT OR IGNORE INTO bans (key) VALUES (?)`, key)
+}
+
+func (s *SQLite) Remove(key string) error {
+	return s.change(`DELETE FROM bans WHERE key = ?`, key)
+}
+
+// change runs query and bumps the version in one transaction.
+func (s *SQLite) change(query, key string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(query, key); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE bans_version SET version = version + 1 WHERE id = 1`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) Load() (map[string]bool, error) {
+	rows, err := s.db.Query(`SELECT key FROM bans`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	bans := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		bans[key] = true
+	}
+	return bans, rows.Err()
+}
+
+func (s *SQLite) Version() (string, error) {
+	var version int64
+	if err := s.db.QueryRow(`SELECT version FROM bans_version WHERE id = 1`).Scan(&version); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(version, 10), nil
+}
+
+func (s *SQLite) Close() error { return s.db.Close() }
@@ -0,0 +1,176 @@
+package banstore
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// checkStore runs the behaviour every Store shares.
+func checkStore(t *testing.T, store Store) {
+	t.Helper()
+	before, err := store.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("acme/192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remove("192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	bans, err := store.Load()
+	if err != nil || len(bans) != 1 || !bans["acme/192.0.2.2"] {
+		t.Fatalf("bans = %v, %v", bans, err)
+	}
+	if after, err := store.Version(); err != nil || after == before {
+		t.Fatalf("version %q -> %q (%v), want a change", before, after, err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open("file:" + filepath.Join(dir, "banned.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStore(t, store)
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "banned.json" {
+		t.Fatalf("directory holds %v, want only banned.json", entries)
+	}
+}
+
+func TestFileKeepsSymlink(t *testing.T) {
+	// The Docker image links the ban file into its data volume, where it may
+	// not exist until the first ban.
+	volume, app := t.TempDir(), t.TempDir()
+	target := filepath.Join(volume, "banned.json")
+	link := filepath.Join(app, "banned.json")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+	store := &File{Path: link}
+	if err := store.Add("192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("link replaced: %v, %v", info, err)
+	}
+	if bans, err := (&File{Path: target}).Load(); err != nil || !bans["192.0.2.1"] {
+		t.Fatalf("target bans = %v, %v", bans, err)
+	}
+	entries, _ := os.ReadDir(app)
+	if len(entries) != 1 {
+		t.Fatalf("app directory holds %v, want only the link", entries)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	store, err := Open("sqlite:" + filepath.Join(t.TempDir(), "bans.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	checkStore(t, store)
+}
+
+func TestRedis(t *testing.T) {
+	addr := newFakeRedis(t, "hunter2")
+	if _, err := Open("redis://:wrong@" + addr); err == nil {
+		t.Fatal("expected an error for a wrong password")
+	}
+	store, err := Open("redis://:hunter2@" + addr + "/2?key=test:bans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	checkStore(t, store)
+}
+
+// newFakeRedis serves the commands Redis uses from memory.
+func newFakeRedis(t *testing.T, password string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var mu sync.Mutex
+	sets := make(map[string]map[string]bool)
+	counters := make(map[string]int)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				authed := false
+				for {
+					reply, err := readReply(r)
+					if err != nil {
+						return
+					}
+					var args []string
+					for _, arg := range reply.([]any) {
+						args = append(args, arg.(string))
+					}
+					mu.Lock()
+					out := "+OK\r\n"
+					switch cmd := strings.ToUpper(args[0]); {
+					case cmd == "AUTH":
+						authed = args[1] == password
+						if !authed {
+							out = "-WRONGPASS invalid password\r\n"
+						}
+					case !authed:
+						out = "-NOAUTH Authentication required.\r\n"
+					case cmd == "PING":
+						out = "+PONG\r\n"
+					case cmd == "SELECT":
+					case cmd == "SADD" || cmd == "SREM":
+						if sets[args[1]] == nil {
+							sets[args[1]] = make(map[string]bool)
+						}
+						if cmd == "SADD" {
+							sets[args[1]][args[2]] = true
+						} else {
+							delete(sets[args[1]], args[2])
+						}
+						out = ":1\r\n"
+					case cmd == "SMEMBERS":
+						out = "*" + strconv.Itoa(len(sets[args[1]])) + "\r\n"
+						for member := range sets[args[1]] {
+							out += "$" + strconv.Itoa(len(member)) + "\r\n" + member + "\r\n"
+						}
+					case cmd == "INCR":
+						counters[args[1]]++
+						out = ":" + strconv.Itoa(counters[args[1]]) + "\r\n"
+					case cmd == "GET":
+						out = "$-1\r\n"
+						if n, ok := counters[args[1]]; ok {
+							value := strconv.Itoa(n)
+							out = "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+						}
+					default:
+						out = "-ERR unknown command\r\n"
+					}
+					mu.Unlock()
+					conn.Write([]byte(out))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
@@ -0,0 +1,218 @@
+package banstore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisTimeout bounds dialing and each command.
+const redisTimeout = 5 * time.Second
+
+// DefaultRedisKey is the set holding the bans; its version counter is the same
+// key with ":version" appended.
+const DefaultRedisKey = "sigmartc:bans"
+
+// Redis stores bans in a Redis set and bumps a counter on every change as the
+// version. Nodes pointing at the same server share the bans. It speaks just
+// enough RESP for that over one connection, redialed after errors.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	key      string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// OpenRedis connects to redis://[:password@]host:port[/db][?key=<set>].
+func OpenRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q", rawURL)
+	}
+	r := &Redis{addr: u.Host, key: u.Query().Get("key")}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if r.key == "" {
+		r.key = DefaultRedisKey
+	}
+	if password, ok := u.User.Password(); ok {
+		r.password = password
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+	}
+	if _, err := r.do("PING"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Redis) Add(key string) error {
+	return r.change("SADD", key)
+}
+
+func (r *Redis) Remove(key string) error {
+	return r.change("SREM", key)
+}
+
+func (r *Redis) change(command, key string) error {
+	if _, err := r.do(command, r.key, key); err != nil {
+		return err
+	}
+	_, err := r.do("INCR", r.key+":version")
+	return err
+}
+
+func (r *Redis) Load() (map[string]bool, error) {
+	reply, err := r.do("SMEMBERS", r.key)
+	if err != nil {
+		return nil, err
+	}
+	members, _ := reply.([]any)
+	bans := make(map[string]bool, len(members))
+	for _, member := range members {
+		if key, ok := member.(string); ok {
+			bans[key] = true
+		}
+	}
+	return bans, nil
+}
+
+func (r *Redis) Version() (string, error) {
+	reply, err := r.do("GET", r.key+":version")
+	if err != nil {
+		return "", err
+	}
+	version, _ := reply.(string)
+	return version, nil
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// do sends one command and returns its reply: a string, an int64, nil or a
+// []any of those.
+func (r *Redis) do(args ...string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		if err := r.dial(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := r.roundTrip(args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection may be out of step with the protocol now.
+		r.conn.Close()
+		r.conn = nil
+	}
+	return reply, err
+}
+
+func (r *Redis) dial() error {
+	conn, err := net.DialTimeout("tcp", r.addr, redisTimeout)
+	if err != nil {
+		return err
+	}
+	r.conn, r.r = conn, bufio.NewReader(conn)
+	if r.password != "" {
+		if _, err := r.roundTrip([]string{"AUTH", r.password}); err != nil {
+			conn.Close()
+			r.conn = nil
+			return err
+		}
+	}
+	if r.db != 0 {
+		if _, err := r.roundTrip([]string{"SELECT", strconv.Itoa(r.db)}); err != nil {
+			conn.Close()
+			r.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Redis) roundTrip(args []string) (any, error) {
+	r.conn.SetDeadline(time.Now().Add(redisTimeout))
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(r.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readReply(r.r)
+}
+
+// readReply reads one RESP2 reply.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
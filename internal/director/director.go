@@ -0,0 +1,235 @@
+// Package director places rooms on a fleet of sigmartc media nodes. It polls
+// each node's /api/load and answers which node should host a room, keeping a
+// room on the node it was first placed on while that node stays healthy.
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoNodes means no node in the fleet is healthy.
+var ErrNoNodes = errors.New("no healthy media node")
+
+const (
+	// DefaultPollInterval is how often each node's load is polled.
+	DefaultPollInterval = 5 * time.Second
+	// DefaultRoomTTL is how long a placement is remembered after its last
+	// lookup, matching how long media nodes keep empty rooms.
+	DefaultRoomTTL = 2 * time.Hour
+	// maxLoadBytes bounds a node's /api/load response.
+	maxLoadBytes = 64 << 10
+)
+
+// NodeStatus is the director's view of one media node.
+type NodeStatus struct {
+	URL     string  `json:"url"`
+	Healthy bool    `json:"healthy"`
+	Score   float64 `json:"score"`
+	Rooms   int     `json:"rooms"`
+	Peers   int     `json:"peers"`
+	// Placed counts the rooms currently placed on the node by this director.
+	Placed   int       `json:"placed"`
+	LastPoll time.Time `json:"last_poll,omitzero"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type node struct {
+	base   *url.URL
+	status NodeStatus
+	// pending counts rooms placed since the last poll, which its load does not
+	// show yet.
+	pending int
+}
+
+type placement struct {
+	node *node
+	used time.Time
+}
+
+// Director tracks the fleet and places rooms. Nodes are unhealthy until their
+// first successful poll.
+type Director struct {
+	// PollInterval is how often nodes are polled, RoomTTL how long an unused
+	// placement is kept. Both are read by Run.
+	PollInterval time.Duration
+	RoomTTL      time.Duration
+
+	client *http.Client
+	mu     sync.Mutex
+	nodes  []*node
+	rooms  map[string]*placement
+}
+
+// New creates a director for the nodes' public base URLs, e.g.
+// https://media1.example.com.
+func New(nodeURLs []string) (*Director, error) {
+	if len(nodeURLs) == 0 {
+		return nil, errors.New("no media nodes")
+	}
+	d := &Director{
+		PollInterval: DefaultPollInterval,
+		RoomTTL:      DefaultRoomTTL,
+		client:       &http.Client{Timeout: 3 * time.Second},
+		rooms:        make(map[string]*placement),
+	}
+	seen := make(map[string]bool, len(nodeURLs))
+	for _, raw := range nodeURLs {
+		base, err := url.Parse(strings.TrimRight(raw, "/"))
+		if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+			return nil, fmt.Errorf("invalid node URL %q", raw)
+		}
+		if seen[base.String()] {
+			return nil, fmt.Errorf("duplicate node URL %q", raw)
+		}
+		seen[base.String()] = true
+		d.nodes = append(d.nodes, &node{base: base, status: NodeStatus{URL: base.String()}})
+	}
+	return d, nil
+}
+
+// Run polls the nodes now and every PollInterval, and forgets unused
+// placements, until ctx ends.
+func (d *Director) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.poll(ctx)
+		d.expire(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll fetches every node's load concurrently.
+func (d *Director) poll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, n := range d.nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			load, err := d.fetchLoad(ctx, n.base)
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			n.status.LastPoll = time.Now()
+			if err != nil {
+				if n.status.Healthy {
+					slog.Warn("Media node unhealthy", "node", n.status.URL, "err", err)
+				}
+				n.status.Healthy = false
+				n.status.Error = err.Error()
+				return
+			}
+			if !n.status.Healthy {
+				slog.Info("Media node healthy", "node", n.status.URL, "score", load.Score)
+			}
+			n.status.Healthy = true
+			n.status.Error = ""
+			n.status.Score, n.status.Rooms, n.status.Peers = load.Score, load.Rooms, load.Peers
+			n.pending = 0
+		}()
+	}
+	wg.Wait()
+}
+
+// nodeLoad is the part of a node's /api/load response placement uses.
+type nodeLoad struct {
+	Score float64 `json:"score"`
+	Rooms int     `json:"rooms"`
+	Peers int     `json:"peers"`
+}
+
+func (d *Director) fetchLoad(ctx context.Context, base *url.URL) (nodeLoad, error) {
+	var load nodeLoad
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.JoinPath("/api/load").String(), nil)
+	if err != nil {
+		return load, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return load, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return load, fmt.Errorf("/api/load: %s", resp.Status)
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxLoadBytes)).Decode(&load); err != nil {
+		return load, fmt.Errorf("/api/load: %w", err)
+	}
+	return load, nil
+}
+
+// expire forgets placements not looked up within RoomTTL.
+func (d *Director) expire(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for room, p := range d.rooms {
+		if now.Sub(p.used) > d.RoomTTL {
+			delete(d.rooms, room)
+			p.node.status.Placed--
+		}
+	}
+}
+
+// Place returns the base URL of the node hosting room. A room stays on its
+// node while the node is healthy; new rooms go to the healthy node with the
+// lowest load score, counting rooms placed since its last poll as a tie
+// break, and only to a node at capacity (score 1 or more) when all are.
+func (d *Director) Place(room string, now time.Time) (*url.URL, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if p := d.rooms[room]; p != nil {
+		if p.node.status.Healthy {
+			p.used = now
+			return p.node.base, nil
+		}
+		p.node.status.Placed--
+		delete(d.rooms, room)
+	}
+	var best *node
+	for _, n := range d.nodes {
+		if n.status.Healthy && (best == nil || n.less(best)) {
+			best = n
+		}
+	}
+	if best == nil {
+		return nil, ErrNoNodes
+	}
+	best.pending++
+	best.status.Placed++
+	d.rooms[room] = &placement{node: best, used: now}
+	return best.base, nil
+}
+
+// less orders nodes for placement.
+func (n *node) less(other *node) bool {
+	if full, otherFull := n.status.Score >= 1, other.status.Score >= 1; full != otherFull {
+		return otherFull
+	}
+	if n.status.Score != other.status.Score {
+		return n.status.Score < other.status.Score
+	}
+	return n.status.Rooms+n.pending < other.status.Rooms+other.pending
+}
+
+// Nodes returns the fleet's status in configuration order.
+func (d *Director) Nodes() []NodeStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	nodes := make([]NodeStatus, 0, len(d.nodes))
+	for _, n := range d.nodes {
+		nodes = append(nodes, n.status)
+	}
+	return nodes
+}
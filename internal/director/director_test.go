@@ -0,0 +1,120 @@
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeNode serves /api/load with a score that tests can change.
+type fakeNode struct {
+	*httptest.Server
+	score atomic.Value
+	down  atomic.Bool
+}
+
+func newFakeNode(t *testing.T, score float64) *fakeNode {
+	t.Helper()
+	n := &fakeNode{}
+	n.score.Store(score)
+	n.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/load" || n.down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"score": %v, "rooms": 1, "peers": 2}`, n.score.Load())
+	}))
+	t.Cleanup(n.Close)
+	return n
+}
+
+func TestNewRejectsBadNodes(t *testing.T) {
+	for _, nodes := range [][]string{nil, {"ftp://media"}, {"media1"}, {"https://a", "https://a/"}} {
+		if _, err := New(nodes); err == nil {
+			t.Fatalf("%v: expected an error", nodes)
+		}
+	}
+}
+
+func TestPlaceLeastLoadedAndSticky(t *testing.T) {
+	busy, idle := newFakeNode(t, 0.8), newFakeNode(t, 0.2)
+	d, err := New([]string{busy.URL, idle.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Place("lobby", time.Now()); err != ErrNoNodes {
+		t.Fatalf("before the first poll: err = %v, want ErrNoNodes", err)
+	}
+	d.poll(context.Background())
+
+	node, err := d.Place("lobby", time.Now())
+	if err != nil || node.String() != idle.URL {
+		t.Fatalf("placed on %v (%v), want %s", node, err, idle.URL)
+	}
+	idle.score.Store(0.9)
+	d.poll(context.Background())
+	if node, _ := d.Place("lobby", time.Now()); node.String() != idle.URL {
+		t.Fatalf("placed room moved to %v", node)
+	}
+	if node, _ := d.Place("other", time.Now()); node.String() != busy.URL {
+		t.Fatalf("new room placed on %v, want the less loaded %s", node, busy.URL)
+	}
+
+	idle.down.Store(true)
+	d.poll(context.Background())
+	if node, _ := d.Place("lobby", time.Now()); node.String() != busy.URL {
+		t.Fatalf("room on an unhealthy node stayed on %v", node)
+	}
+	if status := d.Nodes(); status[1].Healthy || status[1].Error == "" || status[0].Placed != 2 {
+		t.Fatalf("nodes = %+v", status)
+	}
+
+	d.expire(time.Now().Add(d.RoomTTL + time.Minute))
+	if status := d.Nodes(); status[0].Placed != 0 {
+		t.Fatalf("placements not expired: %+v", status)
+	}
+}
+
+func TestPlaceAvoidsFullNodes(t *testing.T) {
+	full, loaded := newFakeNode(t, 1.2), newFakeNode(t, 0.95)
+	d, _ := New([]string{full.URL, loaded.URL})
+	d.poll(context.Background())
+	if node, _ := d.Place("lobby", time.Now()); node.String() != loaded.URL {
+		t.Fatalf("placed on %v, want %s", node, loaded.URL)
+	}
+}
+
+func TestHandlerPlacesAndRedirects(t *testing.T) {
+	node := newFakeNode(t, 0.1)
+	d, _ := New([]string{node.URL})
+	d.poll(context.Background())
+	handler := d.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/place?room=lobby&tenant=acme", nil))
+	var placement Placement
+	if err := json.Unmarshal(rec.Body.Bytes(), &placement); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	wsURL := "ws" + node.URL[len("http"):] + "/ws?room=lobby&tenant=acme"
+	if placement.Node != node.URL || placement.URL != node.URL+"/r/lobby?tenant=acme" || placement.WSURL != wsURL {
+		t.Fatalf("placement = %+v", placement)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/lobby?name=alice", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != node.URL+"/r/lobby?name=alice" {
+		t.Fatalf("redirect = %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/place", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing room: status = %d", rec.Code)
+	}
+}
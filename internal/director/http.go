@@ -0,0 +1,94 @@
+package director
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Placement answers /api/place: the node hosting a room, its room page and the
+// signaling URL clients open, to which they add name and their other join
+// parameters.
+type Placement struct {
+	Room  string `json:"room"`
+	Node  string `json:"node"`
+	URL   string `json:"url"`
+	WSURL string `json:"ws_url"`
+}
+
+// Handler serves the director's HTTP API:
+//
+//	GET /api/place?room=<room>[&tenant=<id>]   Placement as JSON
+//	GET /r/<room>[?tenant=<id>]                 302 to the room's page on its node
+//	GET /api/nodes                             the fleet's NodeStatus list
+//	GET /healthz                               200 while any node is healthy
+func (d *Director) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/place", func(w http.ResponseWriter, r *http.Request) {
+		placement, status := d.place(r.URL.Query().Get("room"), r.URL.Query().Get("tenant"))
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(placement)
+	})
+	mux.HandleFunc("GET /r/{room}", func(w http.ResponseWriter, r *http.Request) {
+		placement, status := d.place(r.PathValue("room"), r.URL.Query().Get("tenant"))
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		target, _ := url.Parse(placement.URL)
+		target.RawQuery = r.URL.RawQuery
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, target.String(), http.StatusFound)
+	})
+	mux.HandleFunc("GET /api/nodes", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]any{"nodes": d.Nodes()})
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		for _, n := range d.Nodes() {
+			if n.Healthy {
+				w.Write([]byte("ok"))
+				return
+			}
+		}
+		http.Error(w, ErrNoNodes.Error(), http.StatusServiceUnavailable)
+	})
+	return mux
+}
+
+// place resolves a room, keyed by tenant like the media nodes key it, and
+// returns the HTTP status to answer with.
+func (d *Director) place(room, tenant string) (Placement, int) {
+	room = strings.TrimSpace(room)
+	if room == "" || strings.Contains(tenant, "/") {
+		return Placement{}, http.StatusBadRequest
+	}
+	key := room
+	if tenant != "" {
+		key = tenant + "/" + room
+	}
+	base, err := d.Place(key, time.Now())
+	if err != nil {
+		slog.Warn("Room placement failed", "uuid", key, "err", err)
+		return Placement{}, http.StatusServiceUnavailable
+	}
+	page := base.JoinPath("r", room)
+	query := url.Values{"room": {room}}
+	if tenant != "" {
+		page.RawQuery = url.Values{"tenant": {tenant}}.Encode()
+		query.Set("tenant", tenant)
+	}
+	ws := base.JoinPath("ws")
+	ws.Scheme = strings.Replace(ws.Scheme, "http", "ws", 1)
+	ws.RawQuery = query.Encode()
+	return Placement{Room: room, Node: base.String(), URL: page.String(), WSURL: ws.String()}, http.StatusOK
+}
@@ -0,0 +1,340 @@
+// Package egress mixes a room's audio server-side and publishes it through ffmpeg,
+// either to a live streaming endpoint or as an HLS playlist on disk.
+//
+// Each publisher's Opus RTP is repackaged as Ogg and decoded to PCM by its own
+// ffmpeg process; the PCM is mixed in Go every 20ms and piped into a single
+// encoder process that pushes to RTMP or Icecast, or writes HLS segments.
+package egress
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+const (
+	frameDuration      = 20 * time.Millisecond
+	sourceIdleTimeout  = 5 * time.Second
+	sourceQueueSize    = 128
+	defaultBitrateKbps = 128
+)
+
+// Config describes one egress session.
+type Config struct {
+	// FFmpegPath is the ffmpeg binary; "ffmpeg" resolves through PATH.
+	FFmpegPath string
+	// Target is an rtmp://, rtmps:// or icecast:// URL.
+	Target string
+	// HLSDir, when set, writes an HLS playlist into this existing directory instead of pushing to Target.
+	HLSDir string
+	// BitrateKbps is the encoded bitrate; zero uses 128.
+	BitrateKbps int
+}
+
+// Session is a running egress. All methods are safe for concurrent use.
+type Session struct {
+	cfg     Config
+	mixer   *Mixer
+	encoder *exec.Cmd
+	stdin   io.WriteCloser
+
+	mu      sync.Mutex
+	sources map[string]*Decoder
+
+	done     chan struct{}
+	stopOnce sync.Once
+	errMu    sync.Mutex
+	err      error
+}
+
+// Start launches the encoder and begins pushing the (initially silent) mix.
+func Start(cfg Config) (*Session, error) {
+	if cfg.FFmpegPath == "" {
+		cfg.FFmpegPath = "ffmpeg"
+	}
+	if cfg.BitrateKbps <= 0 {
+		cfg.BitrateKbps = defaultBitrateKbps
+	}
+	var args []string
+	if cfg.HLSDir != "" {
+		args = hlsArgs(cfg.HLSDir, cfg.BitrateKbps)
+	} else {
+		var err error
+		if args, err = encoderArgs(cfg.Target, cfg.BitrateKbps); err != nil {
+			return nil, err
+		}
+	}
+
+	encoder := exec.Command(cfg.FFmpegPath, args...)
+	stdin, err := encoder.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := encoder.Start(); err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		cfg:     cfg,
+		mixer:   NewMixer(),
+		encoder: encoder,
+		stdin:   stdin,
+		sources: make(map[string]*Decoder),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		err := encoder.Wait()
+		if err == nil {
+			err = errors.New("encoder exited")
+		}
+		s.stop(err)
+	}()
+	go s.run()
+	return s, nil
+}
+
+// Done is closed when the session stops, either via Stop or because ffmpeg exited.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the session stopped, or nil if it was stopped deliberately or is still running.
+func (s *Session) Err() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.err
+}
+
+// Stop ends the session and all ffmpeg processes.
+func (s *Session) Stop() {
+	s.stop(nil)
+}
+
+func (s *Session) stop(err error) {
+	s.stopOnce.Do(func() {
+		s.errMu.Lock()
+		s.err = err
+		s.errMu.Unlock()
+		close(s.done)
+
+		s.mu.Lock()
+		for id, src := range s.sources {
+			src.Close()
+			delete(s.sources, id)
+		}
+		s.mu.Unlock()
+
+		_ = s.stdin.Close()
+		if s.encoder.Process != nil {
+			_ = s.encoder.Process.Kill()
+		}
+	})
+}
+
+// WriteRTP feeds an Opus RTP packet from sourceID into the mix. It never blocks:
+// packets are dropped if the source's decoder falls behind.
+func (s *Session) WriteRTP(sourceID string, packet *rtp.Packet) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	s.mu.Lock()
+	src, ok := s.sources[sourceID]
+	if !ok {
+		var err error
+		src, err = NewDecoder(s.cfg.FFmpegPath, func(frame []int16) { s.mixer.Push(sourceID, frame) })
+		if err != nil {
+			s.mu.Unlock()
+			slog.Warn("Egress decoder failed to start", "source", sourceID, "err", err)
+			return
+		}
+		s.sources[sourceID] = src
+	}
+	s.mu.Unlock()
+	src.WriteRTP(packet)
+}
+
+// run paces the mix to real time and reaps sources that stopped sending.
+func (s *Session) run() {
+	ticker := time.NewTicker(frameDuration)
+	defer ticker.Stop()
+	buf := make([]byte, frameBytes)
+	lastReap := time.Now()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			for i, sample := range s.mixer.Mix() {
+				binary.LittleEndian.PutUint16(buf[i*2:], uint16(sample))
+			}
+			if _, err := s.stdin.Write(buf); err != nil {
+				s.stop(err)
+				return
+			}
+			if now.Sub(lastReap) >= time.Second {
+				s.reapIdle(now)
+				lastReap = now
+			}
+		}
+	}
+}
+
+func (s *Session) reapIdle(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, src := range s.sources {
+		if src.Idle(now) > sourceIdleTimeout {
+			src.Close()
+			delete(s.sources, id)
+			s.mixer.Remove(id)
+		}
+	}
+}
+
+// Decoder turns one publisher's Opus RTP into 20ms PCM frames (48kHz stereo,
+// interleaved int16) with a dedicated ffmpeg process.
+type Decoder struct {
+	pipe *opusPipe
+}
+
+// NewDecoder starts ffmpeg. onFrame is called from the decoder's own goroutine
+// for every frame until the decoder is closed.
+func NewDecoder(ffmpegPath string, onFrame func([]int16)) (*Decoder, error) {
+	pipe, err := startOpusPipe(ffmpegPath, decoderArgs(), func(stdout io.Reader) {
+		readPCM(stdout, onFrame)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{pipe: pipe}, nil
+}
+
+// WriteRTP queues an Opus packet for decoding. It never blocks: packets are
+// dropped if ffmpeg falls behind.
+func (d *Decoder) WriteRTP(packet *rtp.Packet) {
+	d.pipe.WriteRTP(packet)
+}
+
+// Idle reports how long ago the last packet was written.
+func (d *Decoder) Idle(now time.Time) time.Duration {
+	return d.pipe.Idle(now)
+}
+
+// Close stops ffmpeg. Frames already decoded may still be delivered.
+func (d *Decoder) Close() {
+	d.pipe.Close()
+}
+
+// opusPipe feeds Opus RTP to an ffmpeg process as an Ogg stream on its stdin
+// and hands its stdout to a reader.
+type opusPipe struct {
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	packets    chan *rtp.Packet
+	done       chan struct{}
+	closeOnce  sync.Once
+	lastPacket atomic.Int64
+}
+
+// startOpusPipe starts ffmpeg with args. consume runs on its own goroutine
+// with ffmpeg's stdout until the output ends.
+func startOpusPipe(ffmpegPath string, args []string, consume func(io.Reader)) (*opusPipe, error) {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	cmd := exec.Command(ffmpegPath, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &opusPipe{
+		cmd:     cmd,
+		stdin:   stdin,
+		packets: make(chan *rtp.Packet, sourceQueueSize),
+		done:    make(chan struct{}),
+	}
+	p.lastPacket.Store(time.Now().UnixNano())
+	go p.writeOgg()
+	go func() {
+		consume(stdout)
+		_ = cmd.Wait()
+	}()
+	return p, nil
+}
+
+func (p *opusPipe) WriteRTP(packet *rtp.Packet) {
+	p.lastPacket.Store(time.Now().UnixNano())
+	select {
+	case <-p.done:
+	case p.packets <- packet.Clone():
+	default:
+	}
+}
+
+func (p *opusPipe) Idle(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, p.lastPacket.Load()))
+}
+
+func (p *opusPipe) writeOgg() {
+	ogg, err := oggwriter.NewWith(p.stdin, sampleRate, channels)
+	if err != nil {
+		p.Close()
+		return
+	}
+	for {
+		select {
+		case <-p.done:
+			return
+		case packet := <-p.packets:
+			if err := ogg.WriteRTP(packet); err != nil {
+				p.Close()
+				return
+			}
+		}
+	}
+}
+
+func (p *opusPipe) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		_ = p.stdin.Close()
+		if p.cmd.Process != nil {
+			_ = p.cmd.Process.Kill()
+		}
+	})
+}
+
+// readPCM splits a raw PCM stream into 20ms frames until it ends.
+func readPCM(r io.Reader, push func([]int16)) {
+	buf := make([]byte, frameBytes)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return
+		}
+		frame := make([]int16, frameSamples)
+		for i := range frame {
+			frame[i] = int16(binary.LittleEndian.Uint16(buf[i*2:]))
+		}
+		push(frame)
+	}
+}
@@ -0,0 +1,83 @@
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	// HLSPlaylist is the playlist file name written into Config.HLSDir.
+	HLSPlaylist = "index.m3u8"
+	// HLSInit is the fMP4 initialization segment referenced by the playlist.
+	HLSInit = "init.mp4"
+
+	hlsSegmentSeconds = 1
+	hlsPlaylistSize   = 6
+)
+
+// ErrUnsupportedTarget is returned for targets other than RTMP(S) and Icecast URLs.
+var ErrUnsupportedTarget = errors.New("egress target must be an rtmp://, rtmps:// or icecast:// URL")
+
+// pcmFormatArgs describe the raw PCM exchanged with ffmpeg over pipes.
+var pcmFormatArgs = []string{"-f", "s16le", "-ar", strconv.Itoa(sampleRate), "-ac", strconv.Itoa(channels)}
+
+// decoderArgs turns an Ogg/Opus stream on stdin into raw PCM on stdout.
+func decoderArgs() []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-fflags", "nobuffer", "-f", "ogg", "-i", "pipe:0"}
+	args = append(args, pcmFormatArgs...)
+	return append(args, "pipe:1")
+}
+
+// reencodeArgs runs an Ogg/Opus stream on stdin through an audio filter and
+// writes Ogg/Opus to stdout one 20ms packet per page, so packets come out as
+// soon as they are encoded.
+func reencodeArgs(filter string, bitrateKbps int) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-fflags", "nobuffer", "-f", "ogg", "-i", "pipe:0"}
+	args = append(args, "-af", filter, "-ar", strconv.Itoa(sampleRate))
+	args = append(args, "-c:a", "libopus", "-b:a", fmt.Sprintf("%dk", bitrateKbps), "-application", "voip", "-frame_duration", "20")
+	return append(args, "-flush_packets", "1", "-page_duration", "20000", "-f", "ogg", "pipe:1")
+}
+
+// encoderArgs reads raw PCM from stdin and publishes it to target.
+func encoderArgs(target string, bitrateKbps int) ([]string, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Host == "" {
+		return nil, ErrUnsupportedTarget
+	}
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, pcmFormatArgs...)
+	args = append(args, "-i", "pipe:0")
+	bitrate := fmt.Sprintf("%dk", bitrateKbps)
+
+	switch parsed.Scheme {
+	case "rtmp", "rtmps":
+		args = append(args, "-c:a", "aac", "-b:a", bitrate, "-f", "flv", target)
+	case "icecast":
+		args = append(args, "-c:a", "libmp3lame", "-b:a", bitrate, "-content_type", "audio/mpeg", "-f", "mp3", target)
+	default:
+		return nil, ErrUnsupportedTarget
+	}
+	return args, nil
+}
+
+// hlsArgs reads raw PCM from stdin and writes a rolling fMP4/AAC HLS playlist into dir.
+// Short segments keep listeners a few seconds behind the live room.
+func hlsArgs(dir string, bitrateKbps int) []string {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, pcmFormatArgs...)
+	return append(args,
+		"-i", "pipe:0",
+		"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", bitrateKbps),
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(hlsSegmentSeconds),
+		"-hls_list_size", strconv.Itoa(hlsPlaylistSize),
+		"-hls_flags", "delete_segments+independent_segments+omit_endlist",
+		"-hls_segment_type", "fmp4",
+		"-hls_fmp4_init_filename", HLSInit,
+		"-hls_segment_filename", filepath.Join(dir, "seg%d.m4s"),
+		filepath.Join(dir, HLSPlaylist),
+	)
+}
@@ -0,0 +1,132 @@
+package egress
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+
+	"sigmartc/internal/soundboard"
+)
+
+func TestEncoderArgsByScheme(t *testing.T) {
+	args, err := encoderArgs("rtmp://live.example.com/app/key", 96)
+	if err != nil {
+		t.Fatalf("rtmp: %v", err)
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "-c:a aac -b:a 96k -f flv rtmp://live.example.com/app/key") {
+		t.Fatalf("unexpected rtmp args: %s", joined)
+	}
+
+	args, err = encoderArgs("icecast://source:pw@radio.example.com:8000/room.mp3", 128)
+	if err != nil {
+		t.Fatalf("icecast: %v", err)
+	}
+	if joined = strings.Join(args, " "); !strings.Contains(joined, "-f mp3 icecast://") {
+		t.Fatalf("unexpected icecast args: %s", joined)
+	}
+
+	for _, target := range []string{"http://example.com/x", "rtmp://", "not a url"} {
+		if _, err := encoderArgs(target, 128); !errors.Is(err, ErrUnsupportedTarget) {
+			t.Fatalf("%q: expected ErrUnsupportedTarget, got %v", target, err)
+		}
+	}
+}
+
+// fakeFFmpeg writes a script that records the encoder's stdin and discards decoder input.
+func fakeFFmpeg(t *testing.T) (path, output string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for ffmpeg")
+	}
+	dir := t.TempDir()
+	output = filepath.Join(dir, "encoded.raw")
+	path = filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\nfor last; do :; done\nif [ \"$last\" = pipe:1 ]; then cat >/dev/null; else cat >" + output + "; fi\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, output
+}
+
+func TestSessionPipesMixToEncoder(t *testing.T) {
+	ffmpeg, output := fakeFFmpeg(t)
+	session, err := Start(Config{FFmpegPath: ffmpeg, Target: "rtmp://127.0.0.1/live/test"})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	session.WriteRTP("peer-1", &rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 111}, Payload: []byte{0xf8, 0xff, 0xfe}})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		info, err := os.Stat(output)
+		if err == nil && info.Size() >= 2*frameBytes {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("encoder received no PCM frames")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	session.Stop()
+	select {
+	case <-session.Done():
+	case <-time.After(time.Second):
+		t.Fatal("session did not stop")
+	}
+	if session.Err() != nil {
+		t.Fatalf("expected clean stop, got %v", session.Err())
+	}
+}
+
+func TestHLSArgsWriteIntoDir(t *testing.T) {
+	joined := strings.Join(hlsArgs("/tmp/room-1", 64), " ")
+	for _, want := range []string{"-f hls", "-hls_segment_type fmp4", "-b:a 64k", "/tmp/room-1/seg%d.m4s", "/tmp/room-1/index.m3u8"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in %s", want, joined)
+		}
+	}
+}
+
+func TestReencodeArgsFilterAndReencode(t *testing.T) {
+	joined := strings.Join(reencodeArgs("volume=0.50", 48), " ")
+	for _, want := range []string{"-af volume=0.50 -ar 48000", "-c:a libopus -b:a 48k", "-page_duration 20000 -f ogg pipe:1"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in %s", want, joined)
+		}
+	}
+}
+
+func TestReencoderReadsReencodedPackets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for ffmpeg")
+	}
+	// An ffmpeg that passes the Ogg stream through unchanged.
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\ncat\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	packets := make(chan soundboard.Packet, 4)
+	reencoder, err := NewFilter(path, SpeechNormFilter, func(packet soundboard.Packet) { packets <- packet })
+	if err != nil {
+		t.Fatalf("NewFilter: %v", err)
+	}
+	defer reencoder.Close()
+	reencoder.WriteRTP(&rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 111, Timestamp: 960}, Payload: []byte{0xfc, 0xff, 0xfe}})
+
+	select {
+	case packet := <-packets:
+		if string(packet.Data) != "\xfc\xff\xfe" || packet.Samples != 960 {
+			t.Fatalf("unexpected packet: %+v", packet)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no packet came back from ffmpeg")
+	}
+}
@@ -0,0 +1,78 @@
+package egress
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	sampleRate   = 48000
+	channels     = 2
+	frameSamples = sampleRate / 50 * channels // interleaved samples per 20ms frame
+	frameBytes   = frameSamples * 2
+
+	// maxQueuedFrames bounds per-source buffering; older frames are dropped so a
+	// source that bursts after a stall does not add permanent delay to the mix.
+	maxQueuedFrames = 25
+)
+
+// Mixer sums 20ms PCM frames (48kHz stereo, interleaved int16) from any number of sources.
+type Mixer struct {
+	mu      sync.Mutex
+	sources map[string][][]int16
+}
+
+func NewMixer() *Mixer {
+	return &Mixer{sources: make(map[string][][]int16)}
+}
+
+// Push queues one frame for a source. Frames of the wrong size are ignored.
+func (m *Mixer) Push(sourceID string, frame []int16) {
+	if len(frame) != frameSamples {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queue := append(m.sources[sourceID], frame)
+	if len(queue) > maxQueuedFrames {
+		queue = queue[len(queue)-maxQueuedFrames:]
+	}
+	m.sources[sourceID] = queue
+}
+
+// Remove drops a source and any frames it has queued.
+func (m *Mixer) Remove(sourceID string) {
+	m.mu.Lock()
+	delete(m.sources, sourceID)
+	m.mu.Unlock()
+}
+
+// Mix consumes at most one frame per source and returns their clipped sum.
+// Sources with nothing queued contribute silence.
+func (m *Mixer) Mix() []int16 {
+	sum := make([]int32, frameSamples)
+	m.mu.Lock()
+	for id, queue := range m.sources {
+		if len(queue) == 0 {
+			continue
+		}
+		for i, sample := range queue[0] {
+			sum[i] += int32(sample)
+		}
+		m.sources[id] = queue[1:]
+	}
+	m.mu.Unlock()
+
+	out := make([]int16, frameSamples)
+	for i, value := range sum {
+		switch {
+		case value > math.MaxInt16:
+			out[i] = math.MaxInt16
+		case value < math.MinInt16:
+			out[i] = math.MinInt16
+		default:
+			out[i] = int16(value)
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package egress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func constantFrame(value int16) []int16 {
+	frame := make([]int16, frameSamples)
+	for i := range frame {
+		frame[i] = value
+	}
+	return frame
+}
+
+func TestMixerSumsAndClips(t *testing.T) {
+	m := NewMixer()
+	m.Push("a", constantFrame(1000))
+	m.Push("b", constantFrame(-250))
+	if got := m.Mix(); got[0] != 750 || got[frameSamples-1] != 750 {
+		t.Fatalf("expected 750, got %d", got[0])
+	}
+
+	m.Push("a", constantFrame(math.MaxInt16))
+	m.Push("b", constantFrame(math.MaxInt16))
+	if got := m.Mix(); got[0] != math.MaxInt16 {
+		t.Fatalf("expected clip to %d, got %d", math.MaxInt16, got[0])
+	}
+
+	// Nothing queued mixes to silence.
+	if got := m.Mix(); got[0] != 0 {
+		t.Fatalf("expected silence, got %d", got[0])
+	}
+}
+
+func TestMixerBoundsQueuePerSource(t *testing.T) {
+	m := NewMixer()
+	for i := 0; i < maxQueuedFrames+10; i++ {
+		m.Push("a", constantFrame(int16(i)))
+	}
+	// The oldest frames are dropped, so the first mixed frame is the 11th pushed.
+	if got := m.Mix(); got[0] != 10 {
+		t.Fatalf("expected oldest frames dropped, got %d", got[0])
+	}
+
+	m.Push("a", []int16{1, 2, 3})
+	m.Remove("a")
+	if got := m.Mix(); got[0] != 0 {
+		t.Fatalf("expected removed source to be silent, got %d", got[0])
+	}
+}
+
+func TestReadPCMSplitsFrames(t *testing.T) {
+	var raw bytes.Buffer
+	for i := 0; i < frameSamples*2+10; i++ {
+		_ = binary.Write(&raw, binary.LittleEndian, int16(-3))
+	}
+	var frames [][]int16
+	readPCM(&raw, func(frame []int16) { frames = append(frames, frame) })
+	if len(frames) != 2 || frames[1][0] != -3 {
+		t.Fatalf("expected 2 full frames, got %d", len(frames))
+	}
+}
@@ -0,0 +1,74 @@
+package egress
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/pion/rtp"
+
+	"sigmartc/internal/soundboard"
+)
+
+// reencodeBitrateKbps is the bitrate of re-encoded audio, in line with what
+// browsers send for speech.
+const reencodeBitrateKbps = 48
+
+// SpeechNormFilter evens out a speaker's level towards a common peak: quiet
+// speech is raised slowly, loud speech is compressed. ffmpeg's EBU R128
+// loudnorm filter would be the textbook choice, but it looks three seconds
+// ahead, which is no use in a live call.
+const SpeechNormFilter = "speechnorm=p=0.5:e=3:c=3:r=0.00001:l=1"
+
+// DenoiseFilter returns an RNNoise noise suppression filter (ffmpeg's arnndn)
+// using the model file at path. The path must not contain single quotes.
+func DenoiseFilter(model string) string {
+	return "arnndn=m='" + model + "'"
+}
+
+// Reencoder runs one publisher's Opus RTP through an ffmpeg audio filter and
+// re-encodes it with a dedicated ffmpeg process.
+type Reencoder struct {
+	pipe *opusPipe
+}
+
+// NewVolume starts a Reencoder that scales the audio by gain, for listeners
+// that cannot scale audio themselves.
+func NewVolume(ffmpegPath string, gain float64, onPacket func(soundboard.Packet)) (*Reencoder, error) {
+	return newReencoder(ffmpegPath, "volume="+strconv.FormatFloat(gain, 'f', 2, 64), onPacket)
+}
+
+// NewFilter starts a Reencoder that runs the audio through an ffmpeg filter
+// chain such as SpeechNormFilter or DenoiseFilter.
+func NewFilter(ffmpegPath, filter string, onPacket func(soundboard.Packet)) (*Reencoder, error) {
+	return newReencoder(ffmpegPath, filter, onPacket)
+}
+
+// newReencoder starts ffmpeg with filter. onPacket is called from the
+// reencoder's own goroutine for every re-encoded packet until it is closed.
+func newReencoder(ffmpegPath, filter string, onPacket func(soundboard.Packet)) (*Reencoder, error) {
+	pipe, err := startOpusPipe(ffmpegPath, reencodeArgs(filter, reencodeBitrateKbps), func(stdout io.Reader) {
+		reader := soundboard.NewOggReader(stdout)
+		for {
+			packet, err := reader.Next()
+			if err != nil {
+				return
+			}
+			onPacket(packet)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Reencoder{pipe: pipe}, nil
+}
+
+// WriteRTP queues an Opus packet for re-encoding. It never blocks: packets are
+// dropped if ffmpeg falls behind.
+func (r *Reencoder) WriteRTP(packet *rtp.Packet) {
+	r.pipe.WriteRTP(packet)
+}
+
+// Close stops ffmpeg. Packets already encoded may still be delivered.
+func (r *Reencoder) Close() {
+	r.pipe.Close()
+}
@@ -0,0 +1,208 @@
+// Package eventdb persists domain events, admin audit entries and room usage
+// sessions in a SQLite database so admin queries and reports survive restarts.
+package eventdb
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"sigmartc/internal/logger"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS events (
+	id      INTEGER PRIMARY KEY,
+	time    INTEGER NOT NULL,
+	level   INTEGER NOT NULL,
+	event   TEXT NOT NULL,
+	room    TEXT NOT NULL,
+	peer_id TEXT NOT NULL,
+	line    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_event_time ON events (event, time);
+CREATE INDEX IF NOT EXISTS events_room_time ON events (room, time);
+
+CREATE TABLE IF NOT EXISTS audit (
+	id     INTEGER PRIMARY KEY,
+	time   INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	entry  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_action_time ON audit (action, time);
+
+CREATE TABLE IF NOT EXISTS usage (
+	id         INTEGER PRIMARY KEY,
+	room       TEXT NOT NULL,
+	started_at INTEGER NOT NULL,
+	session    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS usage_started_at ON usage (started_at);
+`
+
+// DB is a SQLite event store. It is also a logger.Sink that keeps every log line
+// carrying an event field (see logger.LogEvent) and ignores the rest.
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens or creates the database at path.
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer; a single connection avoids SQLITE_BUSY between our own goroutines.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Write stores the event lines in p.
+func (d *DB) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		record := logger.ParseRecord(string(line))
+		if record.Event == "" {
+			continue
+		}
+		_, err := d.db.Exec(`INSERT INTO events (time, level, event, room, peer_id, line) VALUES (?, ?, ?, ?, ?, ?)`,
+			record.Time.UnixNano(), int(record.Level), record.Event, record.Room, record.PeerID, record.Line)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// QueryEvents returns stored events matching q, oldest first. Like
+// logger.QueryLogs, only the most recent Limit matches are returned.
+func (d *DB) QueryEvents(q logger.LogQuery) ([]logger.LogRecord, error) {
+	var where []string
+	var args []any
+	if q.Event != "" {
+		where, args = append(where, "event = ?"), append(args, q.Event)
+	}
+	if q.Room != "" {
+		where, args = append(where, "room = ?"), append(args, q.Room)
+	}
+	if q.PeerID != "" {
+		where, args = append(where, "peer_id = ?"), append(args, q.PeerID)
+	}
+	if q.MinLevel != nil {
+		where, args = append(where, "level >= ?"), append(args, int(*q.MinLevel))
+	}
+	if !q.Since.IsZero() {
+		where, args = append(where, "time >= ?"), append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		where, args = append(where, "time <= ?"), append(args, q.Until.UnixNano())
+	}
+	query := "SELECT line FROM events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time DESC, id DESC"
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+
+	lines, err := d.strings(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]logger.LogRecord, len(lines))
+	for i, line := range lines {
+		records[len(lines)-1-i] = logger.ParseRecord(line)
+	}
+	return records, nil
+}
+
+// AddAudit stores a JSON-encoded audit entry.
+func (d *DB) AddAudit(at time.Time, action string, entry []byte) error {
+	_, err := d.db.Exec(`INSERT INTO audit (time, action, entry) VALUES (?, ?, ?)`, at.UnixNano(), action, string(entry))
+	return err
+}
+
+// Audit returns up to limit of the most recent audit entries for action (empty
+// matches all) at or after since, oldest first.
+func (d *DB) Audit(action string, since time.Time, limit int) ([][]byte, error) {
+	var where []string
+	var args []any
+	if action != "" {
+		where, args = append(where, "action = ?"), append(args, action)
+	}
+	if !since.IsZero() {
+		where, args = append(where, "time >= ?"), append(args, since.UnixNano())
+	}
+	query := "SELECT entry FROM audit"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time DESC, id DESC"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	entries, err := d.strings(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(entries))
+	for i, entry := range entries {
+		out[len(entries)-1-i] = []byte(entry)
+	}
+	return out, nil
+}
+
+// AddUsage stores a JSON-encoded room usage session.
+func (d *DB) AddUsage(room string, startedAt time.Time, session []byte) error {
+	_, err := d.db.Exec(`INSERT INTO usage (room, started_at, session) VALUES (?, ?, ?)`, room, startedAt.UnixNano(), string(session))
+	return err
+}
+
+// Usage returns the sessions that started in [from, until), optionally for one room.
+func (d *DB) Usage(room string, from, until time.Time) ([][]byte, error) {
+	query := "SELECT session FROM usage WHERE started_at >= ? AND started_at < ?"
+	args := []any{from.UnixNano(), until.UnixNano()}
+	if room != "" {
+		query += " AND room = ?"
+		args = append(args, room)
+	}
+	sessions, err := d.strings(query+" ORDER BY started_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(sessions))
+	for i, session := range sessions {
+		out[i] = []byte(session)
+	}
+	return out, nil
+}
+
+func (d *DB) strings(query string, args ...any) ([]string, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,103 @@
+package eventdb
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sigmartc/internal/logger"
+)
+
+func openTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return db
+}
+
+func TestEventsSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	db := openTestDB(t, path)
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	line := func(offset time.Duration, level, event, room string) string {
+		if event == "" {
+			return fmt.Sprintf(`{"time":%q,"level":%q,"msg":"plain"}`+"\n", base.Add(offset).Format(time.RFC3339Nano), level)
+		}
+		return fmt.Sprintf(`{"time":%q,"level":%q,"msg":"SystemEvent","event":%q,"uuid":%q,"peer_id":"p1"}`+"\n",
+			base.Add(offset).Format(time.RFC3339Nano), level, event, room)
+	}
+	for _, l := range []string{
+		line(0, "INFO", "USER_JOIN", "room-a"),
+		line(time.Minute, "INFO", "", ""),
+		line(2*time.Minute, "WARN", "USER_LEAVE", "room-a"),
+		line(3*time.Minute, "INFO", "USER_JOIN", "room-b"),
+	} {
+		if _, err := db.Write([]byte(l)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	db.Close()
+
+	db = openTestDB(t, path)
+	defer db.Close()
+	query := func(q logger.LogQuery) []logger.LogRecord {
+		t.Helper()
+		records, err := db.QueryEvents(q)
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		return records
+	}
+	if got := query(logger.LogQuery{}); len(got) != 3 {
+		t.Fatalf("stored %d records, want the 3 events", len(got))
+	}
+	if got := query(logger.LogQuery{Event: "USER_JOIN"}); len(got) != 2 || got[0].Room != "room-a" || got[1].Room != "room-b" {
+		t.Fatalf("joins = %+v", got)
+	}
+	if got := query(logger.LogQuery{Event: "USER_JOIN", Limit: 1}); len(got) != 1 || got[0].Room != "room-b" {
+		t.Fatalf("limited joins = %+v", got)
+	}
+	warn := slog.LevelWarn
+	if got := query(logger.LogQuery{MinLevel: &warn}); len(got) != 1 || got[0].Event != "USER_LEAVE" {
+		t.Fatalf("warn events = %+v", got)
+	}
+	if got := query(logger.LogQuery{Room: "room-a", Since: base.Add(time.Minute)}); len(got) != 1 || got[0].PeerID != "p1" {
+		t.Fatalf("room-a since = %+v", got)
+	}
+}
+
+func TestAuditAndUsageRows(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "events.db"))
+	defer db.Close()
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	db.AddAudit(base, "ban", []byte(`{"n":1}`))
+	db.AddAudit(base.Add(time.Minute), "kick", []byte(`{"n":2}`))
+	db.AddAudit(base.Add(2*time.Minute), "ban", []byte(`{"n":3}`))
+
+	entries, err := db.Audit("ban", time.Time{}, 0)
+	if err != nil || len(entries) != 2 || string(entries[0]) != `{"n":1}` {
+		t.Fatalf("ban entries = %q, %v", entries, err)
+	}
+	entries, err = db.Audit("", base.Add(time.Second), 1)
+	if err != nil || len(entries) != 1 || string(entries[0]) != `{"n":3}` {
+		t.Fatalf("latest entry = %q, %v", entries, err)
+	}
+
+	db.AddUsage("room-a", base, []byte(`{"s":1}`))
+	db.AddUsage("room-b", base.Add(time.Hour), []byte(`{"s":2}`))
+	db.AddUsage("room-a", base.Add(24*time.Hour), []byte(`{"s":3}`))
+	sessions, err := db.Usage("", base, base.Add(24*time.Hour))
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("sessions = %q, %v", sessions, err)
+	}
+	sessions, err = db.Usage("room-a", base, base.Add(48*time.Hour))
+	if err != nil || len(sessions) != 2 || string(sessions[1]) != `{"s":3}` {
+		t.Fatalf("room-a sessions = %q, %v", sessions, err)
+	}
+}
@@ -0,0 +1,23 @@
+// Package limitbuf bounds how much output a child process may buffer.
+package limitbuf
+
+import "bytes"
+
+// Writer appends to Buf until it holds Max bytes and silently discards the
+// rest, so a runaway command cannot exhaust memory. Writes always report
+// success so the command is not killed by a short write.
+type Writer struct {
+	Buf *bytes.Buffer
+	Max int
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if room := w.Max - w.Buf.Len(); room > 0 {
+		if len(p) > room {
+			w.Buf.Write(p[:room])
+		} else {
+			w.Buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,19 @@
+package limitbuf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriterDiscardsBeyondMax(t *testing.T) {
+	var buf bytes.Buffer
+	w := &Writer{Buf: &buf, Max: 5}
+	for _, chunk := range []string{"abc", "defg", "hij"} {
+		if n, err := w.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v; want %d, nil", chunk, n, err, len(chunk))
+		}
+	}
+	if got := buf.String(); got != "abcde" {
+		t.Fatalf("buffered %q, want %q", got, "abcde")
+	}
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"io"
+	"sync"
+)
+
+// logQueueSize bounds the log lines waiting for the sinks and the in-memory store.
+const logQueueSize = 4096
+
+// asyncWriter hands log lines to out on a background goroutine, so slog calls
+// on media and signaling paths never wait on a slow disk, terminal or syslog.
+// Lines that arrive while the queue is full are dropped and counted, whatever
+// their level; Close writes the ones still queued.
+type asyncWriter struct {
+	out   io.Writer
+	queue chan []byte
+	done  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+func newAsyncWriter(out io.Writer, size int) *asyncWriter {
+	w := &asyncWriter{
+		out:   out,
+		queue: make(chan []byte, size),
+		done:  make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	select {
+	case w.queue <- append([]byte(nil), p...):
+	default:
+		droppedLines.Add(1)
+	}
+	return len(p), nil
+}
+
+func (w *asyncWriter) buffered() (used, capacity int) {
+	return len(w.queue), cap(w.queue)
+}
+
+// Close writes the lines still queued and stops the background goroutine.
+func (w *asyncWriter) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	w.wg.Wait()
+	return nil
+}
+
+func (w *asyncWriter) run() {
+	defer w.wg.Done()
+	for {
+		select {
+		case line := <-w.queue:
+			// The sinks count their own errors in teeWriter.
+			_, _ = w.out.Write(line)
+		case <-w.done:
+			for {
+				select {
+				case line := <-w.queue:
+					_, _ = w.out.Write(line)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
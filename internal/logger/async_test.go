@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+// lockedBuffer is a bytes.Buffer safe to read while the writer goroutine writes.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAsyncWriterFlushesOnClose(t *testing.T) {
+	out := &lockedBuffer{}
+	w := newAsyncWriter(out, 8)
+	want := ""
+	for _, line := range []string{`{"level":"INFO","msg":"one"}`, `{"level":"ERROR","msg":"two"}`, `{"level":"WARN","msg":"three"}`} {
+		w.Write([]byte(line + "\n"))
+		want += line + "\n"
+	}
+	w.Close()
+	if got := out.String(); got != want {
+		t.Fatalf("written after Close = %q, want %q", got, want)
+	}
+}
+
+func TestAsyncWriterDropsWhenFull(t *testing.T) {
+	before := droppedLines.Load()
+	// Without the writer goroutine nothing drains the queue.
+	w := &asyncWriter{queue: make(chan []byte, 1)}
+	w.Write([]byte(`{"level":"INFO","msg":"kept"}` + "\n"))
+	// ERROR lines do not wait for room either.
+	w.Write([]byte(`{"level":"ERROR","msg":"dropped"}` + "\n"))
+	if used, capacity := w.buffered(); used != 1 || capacity != 1 {
+		t.Fatalf("buffered() = %d, %d; want 1, 1", used, capacity)
+	}
+	if dropped := droppedLines.Load() - before; dropped != 1 {
+		t.Fatalf("dropped %d lines, want 1", dropped)
+	}
+}
+
+func TestLogQueueFeedsTheStore(t *testing.T) {
+	store := newEventStore(8)
+	q := newLogQueue(nil, store)
+	q.Write([]byte(`{"level":"INFO","msg":"SystemEvent","event":"USER_JOIN"}` + "\n"))
+	q.Close()
+	if got := store.query(LogQuery{Event: "USER_JOIN"}); len(got) != 1 {
+		t.Fatalf("indexed %d records after Close, want 1", len(got))
+	}
+}
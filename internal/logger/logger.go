@@ -1,50 +1,101 @@
 package logger
 
 import (
-	"bytes"
-	"io"
 	"log/slog"
 	"os"
+	"strings"
 	"sync"
 )
 
+// logStoreSize bounds the number of recent log lines kept in memory for admin queries.
+const logStoreSize = 5000
+
 var (
 	once      sync.Once
-	logBuffer *lineBuffer
-	logFile   *os.File
+	logBuffer *eventStore
+	logSinks  []Sink
+	logQueue  *asyncWriter
+	logLevel  = new(slog.LevelVar)
 )
 
 // InitLogger initializes the global logger to write JSON to stdout and a file.
 func InitLogger(filePath string) error {
-	var err error
-	once.Do(func() {
-		logBuffer = newLineBuffer(200)
-		var out io.Writer = os.Stdout
-		if filePath != "" {
-			logFile, err = os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-			if err != nil {
-				return
-			}
-			out = io.MultiWriter(os.Stdout, logFile)
+	sinks := []Sink{nopCloser{os.Stdout}}
+	if filePath != "" {
+		fileSink, err := NewFileSink(filePath)
+		if err != nil {
+			return err
 		}
-		writer := &teeWriter{out: out, buf: logBuffer}
-		jsonHandler := slog.NewJSONHandler(writer, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
+		sinks = append(sinks, fileSink)
+	}
+	return InitLoggerWithSinks(sinks)
+}
+
+// InitLoggerWithSinks initializes the global logger to write JSON lines to every sink.
+// The logger takes ownership of the sinks and closes them in Close.
+func InitLoggerWithSinks(sinks []Sink) error {
+	once.Do(func() {
+		logBuffer = newEventStore(logStoreSize)
+		logSinks = sinks
+		logQueue = newLogQueue(sinks, logBuffer)
+		jsonHandler := slog.NewJSONHandler(logQueue, &slog.HandlerOptions{
+			Level: logLevel,
 		})
 
 		logger := slog.New(jsonHandler)
 		slog.SetDefault(logger)
 	})
-	return err
+	return nil
 }
 
-// Close closes the log file.
+// newLogQueue returns the writer behind the global logger. The sinks and the
+// in-memory store are both written on its background goroutine, so a slog call
+// only copies its line into the queue; parsing and indexing the line for admin
+// queries happen there too.
+func newLogQueue(sinks []Sink, store *eventStore) *asyncWriter {
+	return newAsyncWriter(&teeWriter{sinks: sinks, buf: store}, logQueueSize)
+}
+
+// Close writes the queued lines and closes all sinks.
 func Close() {
-	if logFile != nil {
-		_ = logFile.Close()
+	if logQueue != nil {
+		_ = logQueue.Close()
+	}
+	for _, sink := range logSinks {
+		_ = sink.Close()
 	}
 }
 
+// Exit writes the queued lines, closes all sinks and exits with code. Use it
+// instead of os.Exit once the logger is initialized, which would skip a
+// deferred Close and lose the lines still queued, often the fatal error itself.
+func Exit(code int) {
+	Close()
+	os.Exit(code)
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a slog.Level.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.TrimSpace(name)))
+	return level, err
+}
+
+// SetLevel changes the minimum level of the global logger at runtime.
+func SetLevel(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+// Level returns the current minimum level name in lower case.
+func Level() string {
+	return strings.ToLower(logLevel.Level().String())
+}
+
 // GetRecentLogs returns the most recent log lines up to the limit.
 func GetRecentLogs(limit int) []string {
 	if logBuffer == nil {
@@ -60,71 +111,25 @@ func LogEvent(eventType string, fields ...any) {
 	slog.Info("SystemEvent", allFields...)
 }
 
+// teeWriter writes every line to all sinks and the in-memory buffer.
+// A failing sink does not prevent delivery to the others.
 type teeWriter struct {
-	out io.Writer
-	buf *lineBuffer
+	sinks []Sink
+	buf   *eventStore
 }
 
 func (t *teeWriter) Write(p []byte) (int, error) {
-	n, err := t.out.Write(p)
-	if n > 0 && t.buf != nil {
-		t.buf.write(p[:n])
-	}
-	return n, err
-}
-
-type lineBuffer struct {
-	mu      sync.Mutex
-	max     int
-	lines   []string
-	partial bytes.Buffer
-}
-
-func newLineBuffer(max int) *lineBuffer {
-	return &lineBuffer{max: max}
-}
-
-func (b *lineBuffer) write(p []byte) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	_, _ = b.partial.Write(p)
-	for {
-		data := b.partial.Bytes()
-		idx := bytes.IndexByte(data, '\n')
-		if idx == -1 {
-			break
+	var firstErr error
+	for _, sink := range t.sinks {
+		if _, err := sink.Write(p); err != nil {
+			writeErrors.Add(1)
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
-		line := string(data[:idx])
-		b.append(line)
-		b.partial.Next(idx + 1)
-	}
-}
-
-func (b *lineBuffer) append(line string) {
-	if b.max <= 0 {
-		return
-	}
-	if len(b.lines) >= b.max {
-		copy(b.lines, b.lines[1:])
-		b.lines[len(b.lines)-1] = line
-		return
-	}
-	b.lines = append(b.lines, line)
-}
-
-func (b *lineBuffer) recent(limit int) []string {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	if limit <= 0 || len(b.lines) == 0 {
-		return nil
 	}
-	if limit > len(b.lines) {
-		limit = len(b.lines)
+	if t.buf != nil {
+		t.buf.write(p)
 	}
-	start := len(b.lines) - limit
-	out := make([]string, limit)
-	copy(out, b.lines[start:])
-	return out
+	return len(p), firstErr
 }
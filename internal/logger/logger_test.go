@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestSetLevel(t *testing.T) {
+	defer logLevel.Set(slog.LevelInfo)
+
+	for name, want := range map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		" warn ":  slog.LevelWarn,
+		"error":   slog.LevelError,
+		"warn+2":  slog.LevelWarn + 2,
+		"Error-4": slog.LevelWarn,
+	} {
+		if err := SetLevel(name); err != nil {
+			t.Fatalf("SetLevel(%q) error = %v", name, err)
+		}
+		if got := logLevel.Level(); got != want {
+			t.Fatalf("SetLevel(%q) level = %v, want %v", name, got, want)
+		}
+	}
+
+	if err := SetLevel("warn"); err != nil {
+		t.Fatalf("SetLevel(warn) error = %v", err)
+	}
+	if err := SetLevel("verbose"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+	if got := Level(); got != "warn" {
+		t.Fatalf("Level() = %q, want level unchanged after invalid input", got)
+	}
+}
+
+func TestEventStoreKeepsMostRecent(t *testing.T) {
+	buf := newEventStore(2)
+	buf.write([]byte("one\ntwo\nthr"))
+	buf.write([]byte("ee\n"))
+
+	got := buf.recent(10)
+	if len(got) != 2 || got[0] != "two" || got[1] != "three" {
+		t.Fatalf("recent() = %#v", got)
+	}
+}
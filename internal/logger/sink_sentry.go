@@ -0,0 +1,262 @@
+package logger
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sentryQueueSize = 64
+	sentryTimeout   = 5 * time.Second
+	// sentryDedupWindow suppresses repeats of the same error for this long.
+	sentryDedupWindow = 5 * time.Minute
+	// sentryMaxPerMinute caps reports so an error storm cannot flood the endpoint.
+	sentryMaxPerMinute = 20
+	// sentryMaxFingerprints bounds the dedup table; older entries are pruned first.
+	sentryMaxFingerprints = 1000
+)
+
+// SentrySink reports ERROR-level log lines, including recovered panics, to a
+// Sentry-compatible store endpoint. Reports are sampled, rate limited and
+// deduplicated by message, event and error, and are sent in the background so
+// logging never blocks on the network.
+type SentrySink struct {
+	endpoint   string
+	auth       string
+	sampleRate float64
+	client     *http.Client
+	serverName string
+
+	mu          sync.Mutex
+	seen        map[string]*sentryFingerprint
+	windowStart time.Time
+	windowSent  int
+
+	queue chan []byte
+	done  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+type sentryFingerprint struct {
+	lastSent   time.Time
+	suppressed int
+}
+
+// NewSentrySink creates a sink for a DSN such as https://<key>@sentry.example.com/<project>.
+// sampleRate is the fraction of distinct errors reported, in (0, 1].
+func NewSentrySink(dsn string, sampleRate float64) (*SentrySink, error) {
+	endpoint, key, err := parseSentryDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("sample rate %v out of range (0, 1]", sampleRate)
+	}
+	host, _ := os.Hostname()
+	s := &SentrySink{
+		endpoint:   endpoint,
+		auth:       fmt.Sprintf("Sentry sentry_version=7, sentry_client=sigmartc/1.0, sentry_key=%s", key),
+		sampleRate: sampleRate,
+		client:     &http.Client{Timeout: sentryTimeout},
+		serverName: host,
+		seen:       make(map[string]*sentryFingerprint),
+		queue:      make(chan []byte, sentryQueueSize),
+		done:       make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// parseSentryDSN turns a DSN into the project's store endpoint and public key.
+func parseSentryDSN(dsn string) (endpoint, key string, err error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", "", fmt.Errorf("DSN must be an http(s) URL")
+	}
+	key = u.User.Username()
+	path := strings.TrimSuffix(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project := path[slash+1:]
+	if key == "" || project == "" {
+		return "", "", fmt.Errorf("DSN needs a key and a project ID")
+	}
+	return fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:slash], project), key, nil
+}
+
+func (s *SentrySink) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if event := s.capture(line, time.Now()); event != nil {
+			select {
+			case s.queue <- event:
+			default:
+				droppedLines.Add(1)
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// capture decides whether a line is reported and builds the Sentry event for it.
+func (s *SentrySink) capture(line []byte, now time.Time) []byte {
+	var fields map[string]any
+	if json.Unmarshal(line, &fields) != nil {
+		return nil
+	}
+	levelName, _ := fields["level"].(string)
+	level, err := ParseLevel(levelName)
+	if err != nil || level < slog.LevelError {
+		return nil
+	}
+	msg, _ := fields["msg"].(string)
+	event, _ := fields["event"].(string)
+	errText, _ := fields["err"].(string)
+	fingerprint := msg + "|" + event + "|" + errText
+
+	s.mu.Lock()
+	seen := s.seen[fingerprint]
+	if seen != nil && now.Sub(seen.lastSent) < sentryDedupWindow {
+		seen.suppressed++
+		s.mu.Unlock()
+		return nil
+	}
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart, s.windowSent = now, 0
+	}
+	if s.windowSent >= sentryMaxPerMinute || rand.Float64() >= s.sampleRate {
+		s.mu.Unlock()
+		return nil
+	}
+	s.windowSent++
+	suppressed := 0
+	if seen == nil {
+		if len(s.seen) >= sentryMaxFingerprints {
+			s.prune(now)
+		}
+		seen = &sentryFingerprint{}
+		s.seen[fingerprint] = seen
+	} else {
+		suppressed = seen.suppressed
+	}
+	seen.lastSent, seen.suppressed = now, 0
+	s.mu.Unlock()
+
+	extra := make(map[string]any, len(fields))
+	for k, v := range fields {
+		switch k {
+		case "time", "level", "msg":
+		default:
+			extra[k] = v
+		}
+	}
+	if suppressed > 0 {
+		extra["suppressed_duplicates"] = suppressed
+	}
+	tags := map[string]string{}
+	if event != "" {
+		tags["event"] = event
+	}
+	if room, ok := fields["uuid"].(string); ok {
+		tags["room"] = room
+	}
+	id := make([]byte, 16)
+	_, _ = crand.Read(id)
+	payload, err := json.Marshal(map[string]any{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   now.UTC().Format(time.RFC3339),
+		"level":       "error",
+		"platform":    "go",
+		"logger":      "sigmartc",
+		"server_name": s.serverName,
+		"message":     map[string]string{"formatted": msg},
+		"fingerprint": []string{fingerprint},
+		"tags":        tags,
+		"extra":       extra,
+	})
+	if err != nil {
+		return nil
+	}
+	return payload
+}
+
+// prune forgets fingerprints outside the dedup window, or all of them if none are.
+// Callers hold s.mu.
+func (s *SentrySink) prune(now time.Time) {
+	for fingerprint, seen := range s.seen {
+		if now.Sub(seen.lastSent) >= sentryDedupWindow {
+			delete(s.seen, fingerprint)
+		}
+	}
+	if len(s.seen) >= sentryMaxFingerprints {
+		clear(s.seen)
+	}
+}
+
+// Close sends queued reports and stops the background sender.
+func (s *SentrySink) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+	return nil
+}
+
+func (s *SentrySink) buffered() (used, capacity int) {
+	return len(s.queue), cap(s.queue)
+}
+
+func (s *SentrySink) run() {
+	defer s.wg.Done()
+	for {
+		select {
+		case event := <-s.queue:
+			s.send(event)
+		case <-s.done:
+			for {
+				select {
+				case event := <-s.queue:
+					s.send(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *SentrySink) send(event []byte) {
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(event))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+	resp, err := s.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		writeErrors.Add(1)
+		droppedLines.Add(1)
+		// The logger cannot log its own failures without recursing; report on stderr.
+		fmt.Fprintf(os.Stderr, "sentry sink: %v\n", err)
+	}
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSentryDSN(t *testing.T) {
+	endpoint, key, err := parseSentryDSN("https://abc@sentry.example.com/prefix/42")
+	if err != nil || endpoint != "https://sentry.example.com/prefix/api/42/store/" || key != "abc" {
+		t.Fatalf("parseSentryDSN = %q, %q, %v", endpoint, key, err)
+	}
+	for _, dsn := range []string{"sentry.example.com/42", "https://sentry.example.com/42", "https://abc@sentry.example.com/"} {
+		if _, _, err := parseSentryDSN(dsn); err == nil {
+			t.Fatalf("expected error for %q", dsn)
+		}
+	}
+}
+
+func TestSentrySinkReportsDeduplicatedErrors(t *testing.T) {
+	type report struct {
+		auth string
+		body map[string]any
+	}
+	reports := make(chan report, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		_ = json.Unmarshal(data, &body)
+		reports <- report{auth: r.Header.Get("X-Sentry-Auth"), body: body}
+	}))
+	defer srv.Close()
+
+	sink, err := NewSentrySink(strings.Replace(srv.URL, "://", "://key@", 1)+"/7", 1)
+	if err != nil {
+		t.Fatalf("NewSentrySink() error = %v", err)
+	}
+	_, _ = sink.Write([]byte(`{"level":"INFO","msg":"fine"}` + "\n" +
+		`{"level":"ERROR","msg":"Negotiation failed","err":"boom","uuid":"room-a"}` + "\n" +
+		`{"level":"ERROR","msg":"Negotiation failed","err":"boom","uuid":"room-b"}` + "\n"))
+	_ = sink.Close()
+
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1 (info skipped, duplicate suppressed)", len(reports))
+	}
+	got := <-reports
+	if !strings.Contains(got.auth, "sentry_key=key") {
+		t.Fatalf("X-Sentry-Auth = %q", got.auth)
+	}
+	message, _ := got.body["message"].(map[string]any)
+	tags, _ := got.body["tags"].(map[string]any)
+	if message["formatted"] != "Negotiation failed" || tags["room"] != "room-a" {
+		t.Fatalf("report = %v", got.body)
+	}
+}
+
+func TestSentrySinkRateLimitsAndCountsDuplicates(t *testing.T) {
+	sink := &SentrySink{sampleRate: 1, seen: make(map[string]*sentryFingerprint)}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	line := func(msg string) []byte { return []byte(`{"level":"ERROR","msg":"` + msg + `"}`) }
+
+	sent := 0
+	for i := range sentryMaxPerMinute + 5 {
+		if sink.capture(line(strings.Repeat("x", i+1)), now) != nil {
+			sent++
+		}
+	}
+	if sent != sentryMaxPerMinute {
+		t.Fatalf("sent %d in one minute, want %d", sent, sentryMaxPerMinute)
+	}
+
+	sink.capture(line("x"), now.Add(time.Minute))
+	event := sink.capture(line("x"), now.Add(sentryDedupWindow+time.Minute))
+	if event == nil {
+		t.Fatal("repeat after the dedup window was not reported")
+	}
+	var body struct {
+		Extra map[string]any `json:"extra"`
+	}
+	_ = json.Unmarshal(event, &body)
+	if body.Extra["suppressed_duplicates"] != float64(1) {
+		t.Fatalf("extra = %v", body.Extra)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// syslogSink forwards each JSON line to syslog with a severity derived from its level.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// newSyslogSink connects to the local syslog daemon, or to a remote one when target is
+// "udp://host:port" or "tcp://host:port".
+func newSyslogSink(target string) (Sink, error) {
+	network, addr := "", ""
+	if target != "" {
+		var ok bool
+		network, addr, ok = strings.Cut(target, "://")
+		if !ok || addr == "" {
+			return nil, fmt.Errorf("invalid syslog address %q", target)
+		}
+	}
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, "sigmartc")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		msg := string(line)
+		var err error
+		switch {
+		case bytes.Contains(line, []byte(`"level":"ERROR`)):
+			err = s.w.Err(msg)
+		case bytes.Contains(line, []byte(`"level":"WARN`)):
+			err = s.w.Warning(msg)
+		case bytes.Contains(line, []byte(`"level":"DEBUG`)):
+			err = s.w.Debug(msg)
+		default:
+			err = s.w.Info(msg)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
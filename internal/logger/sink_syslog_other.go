@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package logger
+
+import "errors"
+
+func newSyslogSink(target string) (Sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,261 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	httpSinkBatchSize     = 100
+	httpSinkFlushInterval = time.Second
+	httpSinkQueueSize     = 1024
+	httpSinkTimeout       = 5 * time.Second
+)
+
+// Sink receives complete JSON log lines. Implementations must be safe for concurrent use.
+type Sink interface {
+	io.Writer
+	Close() error
+}
+
+// ParseSinks builds sinks from a comma-separated list of specs.
+func ParseSinks(raw string) ([]Sink, error) {
+	var sinks []Sink
+	for _, part := range strings.Split(raw, ",") {
+		spec := strings.TrimSpace(part)
+		if spec == "" {
+			continue
+		}
+		sink, err := NewSink(spec)
+		if err != nil {
+			for _, s := range sinks {
+				_ = s.Close()
+			}
+			return nil, fmt.Errorf("log sink %q: %w", spec, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// NewSink creates a sink from a spec:
+//
+//	stdout | stderr
+//	file:<path>
+//	syslog | syslog:<udp|tcp>://<host:port>
+//	loki:<push URL>          e.g. loki:http://loki:3100/loki/api/v1/push
+//	elastic:<bulk URL>       e.g. elastic:http://es:9200/sigmartc/_bulk
+func NewSink(spec string) (Sink, error) {
+	kind, target, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	case "file":
+		if target == "" {
+			return nil, fmt.Errorf("missing file path")
+		}
+		return NewFileSink(target)
+	case "syslog":
+		return newSyslogSink(target)
+	case "loki":
+		if target == "" {
+			return nil, fmt.Errorf("missing push URL")
+		}
+		return NewHTTPSink(target, "application/json", encodeLokiBatch), nil
+	case "elastic":
+		if target == "" {
+			return nil, fmt.Errorf("missing bulk URL")
+		}
+		return NewHTTPSink(target, "application/x-ndjson", encodeElasticBatch), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", kind)
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// FileSink appends log lines to a file.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Write(p)
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// HTTPSink batches log lines and POSTs them to a log aggregator in the background.
+// Lines are dropped when the queue is full so logging never blocks on the network.
+type HTTPSink struct {
+	url         string
+	contentType string
+	encode      func(lines [][]byte) ([]byte, error)
+	client      *http.Client
+
+	queue chan []byte
+	done  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+func NewHTTPSink(url, contentType string, encode func(lines [][]byte) ([]byte, error)) *HTTPSink {
+	s := &HTTPSink{
+		url:         url,
+		contentType: contentType,
+		encode:      encode,
+		client:      &http.Client{Timeout: httpSinkTimeout},
+		queue:       make(chan []byte, httpSinkQueueSize),
+		done:        make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+func (s *HTTPSink) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		select {
+		case s.queue <- append([]byte(nil), line...):
+		default:
+			droppedLines.Add(1)
+		}
+	}
+	return len(p), nil
+}
+
+func (s *HTTPSink) buffered() (used, capacity int) {
+	return len(s.queue), cap(s.queue)
+}
+
+// Close flushes queued lines and stops the background sender.
+func (s *HTTPSink) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+	return nil
+}
+
+func (s *HTTPSink) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(httpSinkFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, httpSinkBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.post(batch); err != nil {
+			writeErrors.Add(1)
+			droppedLines.Add(uint64(len(batch)))
+			// The logger cannot log its own failures without recursing; report on stderr.
+			fmt.Fprintf(os.Stderr, "log sink %s: %v\n", s.url, err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case line := <-s.queue:
+			batch = append(batch, line)
+			if len(batch) >= httpSinkBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case line := <-s.queue:
+					batch = append(batch, line)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *HTTPSink) post(lines [][]byte) error {
+	body, err := s.encode(lines)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// encodeLokiBatch builds a Loki push API payload with a single stream labelled job=sigmartc.
+func encodeLokiBatch(lines [][]byte) ([]byte, error) {
+	values := make([][2]string, 0, len(lines))
+	now := time.Now().UnixNano()
+	for i, line := range lines {
+		// Loki rejects out-of-order entries within a stream; keep timestamps strictly increasing.
+		values = append(values, [2]string{strconv.FormatInt(now+int64(i), 10), string(line)})
+	}
+	payload := map[string]any{
+		"streams": []map[string]any{{
+			"stream": map[string]string{"job": "sigmartc"},
+			"values": values,
+		}},
+	}
+	return json.Marshal(payload)
+}
+
+// encodeElasticBatch builds an Elasticsearch _bulk body indexing each line as a document.
+func encodeElasticBatch(lines [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(`{"index":{}}` + "\n")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	sinks, err := ParseSinks(" stdout , file:" + path + ",")
+	if err != nil {
+		t.Fatalf("ParseSinks() error = %v", err)
+	}
+	if len(sinks) != 2 {
+		t.Fatalf("expected 2 sinks, got %d", len(sinks))
+	}
+	if _, err := sinks[1].Write([]byte("{\"msg\":\"hello\"}\n")); err != nil {
+		t.Fatalf("file sink write error = %v", err)
+	}
+	for _, sink := range sinks {
+		_ = sink.Close()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "hello") {
+		t.Fatalf("unexpected file contents %q", data)
+	}
+
+	for _, spec := range []string{"bogus", "file:", "loki:", "syslog:localhost"} {
+		if _, err := ParseSinks(spec); err == nil {
+			t.Fatalf("expected error for spec %q", spec)
+		}
+	}
+}
+
+func TestHTTPSinkPushesLokiBatch(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink, err := NewSink("loki:" + srv.URL)
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+	_, _ = sink.Write([]byte("{\"msg\":\"a\"}\n{\"msg\":\"b\"}\n"))
+	_ = sink.Close()
+
+	var payload struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(<-bodies, &payload); err != nil {
+		t.Fatalf("invalid loki payload: %v", err)
+	}
+	if len(payload.Streams) != 1 || len(payload.Streams[0].Values) != 2 {
+		t.Fatalf("unexpected payload: %#v", payload)
+	}
+	if payload.Streams[0].Values[1][1] != `{"msg":"b"}` {
+		t.Fatalf("unexpected line %q", payload.Streams[0].Values[1][1])
+	}
+}
+
+func TestEncodeElasticBatch(t *testing.T) {
+	body, err := encodeElasticBatch([][]byte{[]byte(`{"msg":"a"}`)})
+	if err != nil {
+		t.Fatalf("encodeElasticBatch() error = %v", err)
+	}
+	if string(body) != "{\"index\":{}}\n{\"msg\":\"a\"}\n" {
+		t.Fatalf("unexpected bulk body %q", body)
+	}
+}
@@ -0,0 +1,46 @@
+package logger
+
+import "sync/atomic"
+
+var (
+	droppedLines atomic.Uint64
+	writeErrors  atomic.Uint64
+)
+
+// Stats reports what the logger could not deliver since start and how full its
+// queues are: the one in front of every sink and those of the background sinks.
+type Stats struct {
+	// DroppedLines counts lines (or Sentry reports) discarded because a queue
+	// was full or their delivery failed.
+	DroppedLines uint64
+	// WriteErrors counts failed writes to a sink, such as a full disk under a
+	// file sink, or failed pushes to a log aggregator.
+	WriteErrors uint64
+	// BufferedLines is how many lines wait in queues, out of BufferCapacity.
+	BufferedLines  int
+	BufferCapacity int
+}
+
+// bufferedSink is a sink that queues lines before delivering them.
+type bufferedSink interface {
+	buffered() (used, capacity int)
+}
+
+// GetStats returns the logger's counters and current queue occupancy.
+func GetStats() Stats {
+	stats := Stats{
+		DroppedLines: droppedLines.Load(),
+		WriteErrors:  writeErrors.Load(),
+	}
+	if logQueue != nil {
+		stats.BufferedLines, stats.BufferCapacity = logQueue.buffered()
+	}
+	for _, sink := range logSinks {
+		if b, ok := sink.(bufferedSink); ok {
+			used, capacity := b.buffered()
+			stats.BufferedLines += used
+			stats.BufferCapacity += capacity
+		}
+	}
+	return stats
+}
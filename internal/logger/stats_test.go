@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStatsCountWriteErrorsAndDrops(t *testing.T) {
+	before := GetStats()
+
+	file, err := NewFileSink(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	tee := &teeWriter{sinks: []Sink{file}}
+	if _, err := tee.Write([]byte("{\"msg\":\"lost\"}\n")); err == nil {
+		t.Fatal("expected a write error from a closed file")
+	}
+
+	// No sender drains this queue, so the second line has no room.
+	sink := &HTTPSink{queue: make(chan []byte, 1)}
+	sink.Write([]byte("{\"msg\":\"one\"}\n{\"msg\":\"two\"}\n"))
+	if used, capacity := sink.buffered(); used != 1 || capacity != 1 {
+		t.Fatalf("buffered() = %d, %d; want 1, 1", used, capacity)
+	}
+
+	after := GetStats()
+	if after.WriteErrors-before.WriteErrors != 1 || after.DroppedLines-before.DroppedLines != 1 {
+		t.Fatalf("stats went from %+v to %+v; want one write error and one dropped line", before, after)
+	}
+}
@@ -0,0 +1,233 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// LogRecord is one JSON log line with the fields used for filtering pulled out.
+type LogRecord struct {
+	Time   time.Time
+	Level  slog.Level
+	Event  string
+	Room   string
+	PeerID string
+	Line   string
+}
+
+// LogQuery filters records in the in-memory store. Zero values match everything.
+type LogQuery struct {
+	MinLevel *slog.Level
+	Event    string
+	Room     string
+	PeerID   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// QueryLogs returns matching records from the in-memory store, oldest first.
+// When more than Limit records match, the most recent ones are returned.
+func QueryLogs(q LogQuery) []LogRecord {
+	if logBuffer == nil {
+		return nil
+	}
+	return logBuffer.query(q)
+}
+
+// eventStore is a bounded ring of parsed log records with secondary indexes on
+// event type and room so that common admin queries avoid scanning every line.
+type eventStore struct {
+	mu      sync.Mutex
+	max     int
+	records []LogRecord
+	next    uint64 // sequence number of the next record
+	partial bytes.Buffer
+
+	byEvent map[string][]uint64
+	byRoom  map[string][]uint64
+}
+
+func newEventStore(max int) *eventStore {
+	return &eventStore{
+		max:     max,
+		records: make([]LogRecord, 0, max),
+		byEvent: make(map[string][]uint64),
+		byRoom:  make(map[string][]uint64),
+	}
+}
+
+func (s *eventStore) write(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _ = s.partial.Write(p)
+	for {
+		data := s.partial.Bytes()
+		idx := bytes.IndexByte(data, '\n')
+		if idx == -1 {
+			break
+		}
+		s.append(ParseRecord(string(data[:idx])))
+		s.partial.Next(idx + 1)
+	}
+}
+
+// ParseRecord extracts the filter fields from a JSON log line. Lines that are not
+// JSON are kept with the current time.
+func ParseRecord(line string) LogRecord {
+	record := LogRecord{Line: line}
+	var fields struct {
+		Time   time.Time `json:"time"`
+		Level  string    `json:"level"`
+		Event  string    `json:"event"`
+		UUID   string    `json:"uuid"`
+		Room   string    `json:"room"`
+		PeerID string    `json:"peer_id"`
+	}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		record.Time = time.Now()
+		return record
+	}
+	record.Time = fields.Time
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if level, err := ParseLevel(fields.Level); err == nil {
+		record.Level = level
+	}
+	record.Event = fields.Event
+	record.Room = fields.UUID
+	if record.Room == "" {
+		record.Room = fields.Room
+	}
+	record.PeerID = fields.PeerID
+	return record
+}
+
+func (s *eventStore) append(record LogRecord) {
+	if s.max <= 0 {
+		return
+	}
+	seq := s.next
+	s.next++
+	if len(s.records) < s.max {
+		s.records = append(s.records, record)
+	} else {
+		slot := seq % uint64(s.max)
+		s.evict(s.records[slot], seq-uint64(s.max))
+		s.records[slot] = record
+	}
+	if record.Event != "" {
+		s.byEvent[record.Event] = append(s.byEvent[record.Event], seq)
+	}
+	if record.Room != "" {
+		s.byRoom[record.Room] = append(s.byRoom[record.Room], seq)
+	}
+}
+
+// evict drops the index entries of the record with sequence number seq, which
+// is about to be overwritten. Being the oldest record, it heads its event's and
+// room's lists, so only those two keys are touched.
+func (s *eventStore) evict(old LogRecord, seq uint64) {
+	trimIndex(s.byEvent, old.Event, seq)
+	trimIndex(s.byRoom, old.Room, seq)
+}
+
+// trimIndex removes seq from the head of key's list.
+func trimIndex(index map[string][]uint64, key string, seq uint64) {
+	seqs := index[key]
+	if key == "" || len(seqs) == 0 || seqs[0] != seq {
+		return
+	}
+	if len(seqs) == 1 {
+		delete(index, key)
+	} else {
+		index[key] = seqs[1:]
+	}
+}
+
+func (s *eventStore) oldest() uint64 {
+	return s.next - uint64(len(s.records))
+}
+
+func (s *eventStore) at(seq uint64) LogRecord {
+	return s.records[seq%uint64(s.max)]
+}
+
+func (s *eventStore) recent(limit int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit <= 0 || len(s.records) == 0 {
+		return nil
+	}
+	if limit > len(s.records) {
+		limit = len(s.records)
+	}
+	out := make([]string, 0, limit)
+	for seq := s.next - uint64(limit); seq < s.next; seq++ {
+		out = append(out, s.at(seq).Line)
+	}
+	return out
+}
+
+func (s *eventStore) query(q LogQuery) []LogRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Walk the narrowest candidate set: an index when filtering by event or room,
+	// otherwise the whole ring.
+	var candidates []uint64
+	indexed := false
+	if q.Event != "" {
+		candidates, indexed = s.byEvent[q.Event], true
+	}
+	if q.Room != "" {
+		if roomSeqs := s.byRoom[q.Room]; !indexed || len(roomSeqs) < len(candidates) {
+			candidates, indexed = roomSeqs, true
+		}
+	}
+
+	matches := make([]LogRecord, 0)
+	consider := func(seq uint64) {
+		record := s.at(seq)
+		if q.Event != "" && record.Event != q.Event {
+			return
+		}
+		if q.Room != "" && record.Room != q.Room {
+			return
+		}
+		if q.PeerID != "" && record.PeerID != q.PeerID {
+			return
+		}
+		if q.MinLevel != nil && record.Level < *q.MinLevel {
+			return
+		}
+		if !q.Since.IsZero() && record.Time.Before(q.Since) {
+			return
+		}
+		if !q.Until.IsZero() && record.Time.After(q.Until) {
+			return
+		}
+		matches = append(matches, record)
+	}
+
+	if indexed {
+		for _, seq := range candidates {
+			consider(seq)
+		}
+	} else {
+		for seq := s.oldest(); seq < s.next; seq++ {
+			consider(seq)
+		}
+	}
+
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[len(matches)-q.Limit:]
+	}
+	return matches
+}
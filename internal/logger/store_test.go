@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestEventStoreQueryFilters(t *testing.T) {
+	store := newEventStore(4)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	line := func(offset time.Duration, level, event, room string) string {
+		return fmt.Sprintf(`{"time":%q,"level":%q,"msg":"SystemEvent","event":%q,"uuid":%q}`+"\n",
+			base.Add(offset).Format(time.RFC3339Nano), level, event, room)
+	}
+	store.write([]byte(line(0, "INFO", "USER_JOIN", "room-a")))
+	store.write([]byte(line(time.Minute, "INFO", "USER_JOIN", "room-b")))
+	store.write([]byte(line(2*time.Minute, "WARN", "USER_LEAVE", "room-a")))
+	store.write([]byte(line(3*time.Minute, "INFO", "USER_JOIN", "room-a")))
+
+	if got := store.query(LogQuery{Event: "USER_JOIN", Room: "room-a"}); len(got) != 2 {
+		t.Fatalf("expected 2 joins for room-a, got %d", len(got))
+	}
+	if got := store.query(LogQuery{Event: "USER_JOIN", Since: base.Add(30 * time.Second)}); len(got) != 2 {
+		t.Fatalf("expected 2 joins since cutoff, got %d", len(got))
+	}
+	warn := slog.LevelWarn
+	if got := store.query(LogQuery{MinLevel: &warn}); len(got) != 1 || got[0].Event != "USER_LEAVE" {
+		t.Fatalf("unexpected warn results: %#v", got)
+	}
+	if got := store.query(LogQuery{Limit: 1}); len(got) != 1 || !got[0].Time.Equal(base.Add(3*time.Minute)) {
+		t.Fatalf("expected most recent record, got %#v", got)
+	}
+
+	// Overwriting the oldest record must also drop it from the indexes.
+	store.write([]byte(line(4*time.Minute, "INFO", "ROOM_CREATE", "room-c")))
+	if got := store.query(LogQuery{Event: "USER_JOIN", Room: "room-a"}); len(got) != 1 {
+		t.Fatalf("expected evicted join to disappear, got %d", len(got))
+	}
+	if _, ok := store.byRoom["room-b"]; !ok {
+		t.Fatal("expected room-b index to survive")
+	}
+}
+
+func TestEventStoreIndexesFollowTheRing(t *testing.T) {
+	store := newEventStore(3)
+	for i := range 10 {
+		store.write([]byte(fmt.Sprintf(`{"level":"INFO","event":"E%d","uuid":"room-%d"}`+"\n", i%2, i)))
+	}
+	// Only the last three records, 7 to 9, are still indexed.
+	if len(store.byRoom) != 3 || len(store.byRoom["room-7"]) != 1 || len(store.byRoom["room-6"]) != 0 {
+		t.Fatalf("byRoom = %v", store.byRoom)
+	}
+	if len(store.byEvent["E1"]) != 2 || len(store.byEvent["E0"]) != 1 {
+		t.Fatalf("byEvent = %v", store.byEvent)
+	}
+}
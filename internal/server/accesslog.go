@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"sigmartc/internal/logger"
+)
+
+// AccessLog logs every HTTP request as an HTTP_ACCESS event: method, path,
+// status, response bytes, latency and client IP (honoring TrustedProxies). kind
+// tells WebSocket upgrades ("ws", logged when the socket closes, so the latency
+// is the session length), static assets ("static", "hls") and everything else
+// ("http") apart. Query strings are left out because join URLs carry tokens.
+// Each request gets an ID (request_id, echoed in X-Request-ID) that a WebSocket
+// join keeps as the peer's session_id.
+func (h *Handler) AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, id := h.withRequestID(r)
+		w.Header().Set(requestIDHeader, id)
+		rec := &accessRecorder{ResponseWriter: w}
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			logger.LogEvent("HTTP_ACCESS",
+				slog.String("kind", requestKind(r)),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int64("bytes", rec.bytes),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("ip", h.clientIP(r)),
+				slog.String("request_id", id),
+			)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+func requestKind(r *http.Request) string {
+	switch {
+	case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"):
+		return "ws"
+	case strings.HasPrefix(r.URL.Path, "/static/"):
+		return "static"
+	case strings.HasPrefix(r.URL.Path, "/hls/"):
+		return "hls"
+	}
+	return "http"
+}
+
+// accessRecorder captures the status and size of a response. It passes through
+// Hijack for WebSocket upgrades and Flush for event streams.
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *accessRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
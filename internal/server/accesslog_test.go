@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer collects log output written from several goroutines.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAccessLogRecordsRequests(t *testing.T) {
+	var logs lockedBuffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler, _ := newTestWSServer(t)
+	srv := httptest.NewServer(handler.AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			handler.HandleWS(w, r)
+			return
+		}
+		http.Error(w, "teapot", http.StatusTeapot)
+	})))
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/static/js/app.js?token=secret")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	conn := dialTestWS(t, srv.URL, "access", "alice")
+	readUntilType(t, conn, "room_state")
+	conn.Close()
+
+	entry := func(kind string) string {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			for line := range strings.SplitSeq(logs.String(), "\n") {
+				if strings.Contains(line, `"event":"HTTP_ACCESS"`) && strings.Contains(line, `"kind":"`+kind+`"`) {
+					return line
+				}
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("no %s access entry in %s", kind, logs.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if static := entry("static"); !strings.Contains(static, `"status":418`) || strings.Contains(static, "secret") {
+		t.Fatalf("unexpected static entry: %s", static)
+	}
+	if ws := entry("ws"); !strings.Contains(ws, `"status":101`) || !strings.Contains(ws, `"path":"/ws"`) {
+		t.Fatalf("unexpected ws entry: %s", ws)
+	}
+}
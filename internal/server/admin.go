@@ -1,30 +1,715 @@
 package server
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"sigmartc/internal/logger"
 )
 
+const (
+	adminStatsInterval     = 5 * time.Second
+	adminKeepaliveInterval = 15 * time.Second
+	adminEventBuffer       = 64
+)
+
+// authorizeAdmin accepts either a session cookie issued by /admin/login or an
+// "Authorization: Bearer <admin-key>" header for scripted access.
+func (h *Handler) authorizeAdmin(r *http.Request) bool {
+	if cookie, err := r.Cookie(adminSessionCookie); err == nil && h.AdminSessions.Valid(cookie.Value) {
+		return true
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return h.checkAdminKey(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return false
+}
+
+func (h *Handler) checkAdminKey(key string) bool {
+	adminKey := h.RoomManager.AdminKey
+	return key != "" && adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1
+}
+
+// HandleAdminLogin exchanges the admin key (POSTed as a form field) for a session cookie.
+func (h *Handler) HandleAdminLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.checkAdminKey(r.PostFormValue("key")) {
+		slog.Warn("Admin login failed", "ip", h.clientIP(r))
+		h.audit(r, "login_failed", "", "")
+		h.serveAdminUI(w, http.StatusUnauthorized, false, "Invalid admin key")
+		return
+	}
+	token, err := h.AdminSessions.Create()
+	if err != nil {
+		slog.Error("Failed to create admin session", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(adminSessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.isSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	slog.Info("Admin login", "ip", h.clientIP(r))
+	h.audit(r, "login", "", "")
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// HandleAdminLogout revokes the current session cookie.
+func (h *Handler) HandleAdminLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie(adminSessionCookie); err == nil && h.AdminSessions.Valid(cookie.Value) {
+		h.audit(r, "logout", "", "")
+		h.AdminSessions.Revoke(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.isSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// isSecureRequest reports whether the client reached us over HTTPS. Only a
+// trusted proxy may vouch for that with X-Forwarded-Proto.
+func (h *Handler) isSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !h.TrustedProxies.trusts(r) {
+		return false
+	}
+	return strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
 func (h *Handler) HandleAdmin(w http.ResponseWriter, r *http.Request) {
-	key := r.URL.Query().Get("key")
-	if key == "" || key != h.RoomManager.AdminKey {
+	action := r.URL.Query().Get("action")
+	if !h.authorizeAdmin(r) {
+		if tenant := h.requestTenant(r); tenant != nil {
+			h.handleTenantAdmin(w, r, tenant, action)
+			return
+		}
+		if action == "" {
+			h.serveAdminUI(w, http.StatusOK, false, "")
+			return
+		}
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	h.adminAction(w, r, action)
+}
 
-	action := r.URL.Query().Get("action")
+// adminAction serves an authorized admin API request.
+func (h *Handler) adminAction(w http.ResponseWriter, r *http.Request, action string) {
 	switch action {
 	case "stats":
 		h.getStats(w)
 	case "logs":
 		h.getLogs(w)
-	case "ban":
+	case "audit":
+		h.getAudit(w, r)
+	case "usage_report":
+		h.getUsageReport(w, r)
+	case "kick":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		peerID := strings.TrimSpace(r.URL.Query().Get("peer_id"))
+		room, peer := h.RoomManager.FindPeer(peerID)
+		if peer == nil {
+			http.Error(w, "Peer not found", http.StatusNotFound)
+			return
+		}
+		peer.Disconnect(DisconnectKicked, "You were removed by an administrator")
+		h.audit(r, "kick", peerID, "room "+room.UUID)
+		fmt.Fprintf(w, "Kicked %s", peerID)
+	case "move_peer":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		peerID := strings.TrimSpace(r.URL.Query().Get("peer_id"))
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		if roomUUID == "" {
+			http.Error(w, "Missing room", http.StatusBadRequest)
+			return
+		}
+		if err := h.MovePeer(peerID, roomUUID); err != nil {
+			status := http.StatusConflict
+			var refused *moveRefusedError
+			switch {
+			case errors.Is(err, errPeerNotFound):
+				status = http.StatusNotFound
+			case errors.As(err, &refused):
+				status = joinErrorStatus(refused.reason)
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, peerID, "room "+roomUUID)
+		fmt.Fprintf(w, "Moved %s to %s", peerID, roomUUID)
+	case "close_room":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		if !h.RoomManager.CloseRoom(roomUUID, DisconnectRoomClosed, "The room was closed by an administrator") {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		h.audit(r, "close_room", roomUUID, "")
+		fmt.Fprintf(w, "Closed %s", roomUUID)
+	case "lock_room", "unlock_room":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		if !h.RoomManager.SetLocked(roomUUID, action == "lock_room") {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		h.audit(r, action, roomUUID, "")
+		fmt.Fprintf(w, "%s: %s", action, roomUUID)
+	case "call_next":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		h.RoomManager.Lock.RLock()
+		room := h.RoomManager.Rooms[roomUUID]
+		h.RoomManager.Lock.RUnlock()
+		if room == nil {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		peerID, ok := room.callNext()
+		if !ok {
+			http.Error(w, "No raised hands", http.StatusNotFound)
+			return
+		}
+		h.audit(r, action, roomUUID, peerID)
+		json.NewEncoder(w).Encode(map[string]any{"room": roomUUID, "peer_id": peerID})
+	case "priority_speaker":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		peerID := r.URL.Query().Get("peer_id")
+		duck := r.URL.Query().Get("duck") == "1"
+		h.RoomManager.Lock.RLock()
+		room := h.RoomManager.Rooms[roomUUID]
+		h.RoomManager.Lock.RUnlock()
+		if room == nil {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if err := room.setPrioritySpeaker(peerID, duck); err != nil {
+			http.Error(w, "Peer not found", http.StatusNotFound)
+			return
+		}
+		h.audit(r, action, roomUUID, fmt.Sprintf("peer_id=%s duck=%t", peerID, duck))
+		json.NewEncoder(w).Encode(map[string]any{"room": roomUUID, "priority_speaker": room.PrioritySpeaker()})
+	case "room_listing":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		public := r.URL.Query().Get("public") == "1"
+		if !h.RoomManager.SetListing(roomUUID, public) {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		h.audit(r, action, roomUUID, fmt.Sprintf("public=%t", public))
+		fmt.Fprintf(w, "Updated listing for %s", roomUUID)
+	case "room_e2ee":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		enabled := r.URL.Query().Get("enabled") == "1"
+		if err := h.RoomManager.SetE2EE(roomUUID, enabled); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, errRoomNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, fmt.Sprintf("enabled=%t", enabled))
+		fmt.Fprintf(w, "Updated E2EE for %s", roomUUID)
+	case "room_codecs":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		policy, err := ParseCodecPolicy(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.RoomManager.SetCodecPolicy(roomUUID, policy); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, errRoomNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, policy.String())
+		fmt.Fprintf(w, "Updated codec policy for %s", roomUUID)
+	case "room_relay":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		enabled := r.URL.Query().Get("enabled") == "1"
+		if err := h.SetForceRelay(roomUUID, enabled); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, errRoomNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, fmt.Sprintf("enabled=%t", enabled))
+		fmt.Fprintf(w, "Updated relay-only for %s", roomUUID)
+	case "room_bitrate":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		kbps, err := strconv.Atoi(r.URL.Query().Get("kbps"))
+		if err != nil {
+			http.Error(w, errInvalidBitrate.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.RoomManager.SetMaxBitrate(roomUUID, kbps); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errRoomNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, fmt.Sprintf("kbps=%d", kbps))
+		fmt.Fprintf(w, "Updated bitrate cap for %s", roomUUID)
+	case "room_leveling":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		enabled := r.URL.Query().Get("enabled") == "1"
+		if err := h.SetRoomLeveling(roomUUID, enabled); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, errRoomNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, fmt.Sprintf("enabled=%t", enabled))
+		fmt.Fprintf(w, "Updated loudness leveling for %s", roomUUID)
+	case "denoise":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		peerID := strings.TrimSpace(r.URL.Query().Get("peer_id"))
+		enabled := r.URL.Query().Get("enabled") == "1"
+		if err := h.SetPeerDenoise(peerID, enabled); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, errPeerNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, peerID, fmt.Sprintf("enabled=%t", enabled))
+		fmt.Fprintf(w, "Updated noise suppression for %s", peerID)
+	case "room_metadata":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		meta := RoomMetadata{
+			Name:   r.URL.Query().Get("name"),
+			Topic:  r.URL.Query().Get("topic"),
+			Avatar: r.URL.Query().Get("avatar"),
+		}
+		if err := h.SetRoomMetadata(roomUUID, meta); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errRoomNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, meta.Name)
+		fmt.Fprintf(w, "Updated metadata for %s", roomUUID)
+	case "schedule":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		var startsAt time.Time
+		if start := r.URL.Query().Get("start"); start != "" {
+			parsed, err := time.Parse(time.RFC3339, start)
+			if err != nil {
+				http.Error(w, "Invalid start time", http.StatusBadRequest)
+				return
+			}
+			startsAt = parsed
+		}
+		endsAt, err := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+		if roomUUID == "" || err != nil {
+			http.Error(w, "Invalid room or end time", http.StatusBadRequest)
+			return
+		}
+		token, err := h.RoomManager.ScheduleRoom(roomUUID, startsAt, endsAt)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errInvalidSchedule) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, r.URL.Query().Get("start")+" - "+r.URL.Query().Get("end"))
+		json.NewEncoder(w).Encode(map[string]any{
+			"room":       roomUUID,
+			"starts_at":  startsAt,
+			"ends_at":    endsAt,
+			"host_token": token,
+		})
+	case "hls_enable", "hls_disable":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		if action == "hls_disable" {
+			if !h.DisableHLS(roomUUID) {
+				http.Error(w, "HLS not enabled", http.StatusNotFound)
+				return
+			}
+			h.audit(r, action, roomUUID, "")
+			fmt.Fprintf(w, "Disabled HLS for %s", roomUUID)
+			return
+		}
+		path, err := h.EnableHLS(roomUUID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, errRoomNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, errHLSDisabled), errors.Is(err, errE2EERoom):
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		// The path carries the listener token; only the room is audited.
+		h.audit(r, action, roomUUID, "")
+		json.NewEncoder(w).Encode(map[string]any{"room": roomUUID, "url": path})
+	case "egress_start", "egress_stop":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		if action == "egress_stop" {
+			if !h.StopEgress(roomUUID) {
+				http.Error(w, "No egress running", http.StatusNotFound)
+				return
+			}
+			h.audit(r, action, roomUUID, "")
+			fmt.Fprintf(w, "Stopped egress for %s", roomUUID)
+			return
+		}
+		target := strings.TrimSpace(r.URL.Query().Get("url"))
+		if err := h.StartEgress(roomUUID, target); err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, errRoomNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, errEgressRunning), errors.Is(err, errE2EERoom):
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		// Stream keys are credentials; only the host is audited.
+		h.audit(r, action, roomUUID, egressHost(target))
+		fmt.Fprintf(w, "Started egress for %s", roomUUID)
+	case "transcribe_start", "transcribe_stop":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		if action == "transcribe_stop" {
+			if !h.StopTranscription(roomUUID) {
+				http.Error(w, "No transcription running", http.StatusNotFound)
+				return
+			}
+			h.audit(r, action, roomUUID, "")
+			fmt.Fprintf(w, "Stopped transcription for %s", roomUUID)
+			return
+		}
+		if err := h.StartTranscription(roomUUID); err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, errRoomNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, errSTTDisabled):
+				status = http.StatusNotImplemented
+			case errors.Is(err, errTranscriptionRunning), errors.Is(err, errE2EERoom):
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, "")
+		fmt.Fprintf(w, "Started transcription for %s", roomUUID)
+	case "transcript":
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		started, entries, err := h.Transcript(roomUUID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("format") == "json" {
+			json.NewEncoder(w).Encode(map[string]any{"room": roomUUID, "started_at": started, "entries": entries})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transcript-%s-%s.txt"`, roomUUID, started.UTC().Format("20060102-150405")))
+		io.WriteString(w, formatTranscript(entries))
+	case "soundboard":
+		clips, err := h.SoundboardClips()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"clips": clips})
+	case "soundboard_play", "soundboard_stop":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		if action == "soundboard_stop" {
+			if !h.StopSoundboard(roomUUID) {
+				http.Error(w, "Nothing playing", http.StatusNotFound)
+				return
+			}
+			h.audit(r, action, roomUUID, "")
+			fmt.Fprintf(w, "Stopped soundboard in %s", roomUUID)
+			return
+		}
+		clip := strings.TrimSpace(r.URL.Query().Get("clip"))
+		loop := r.URL.Query().Get("loop") == "1"
+		if err := h.PlaySoundboard(roomUUID, clip, loop); err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, errRoomNotFound), errors.Is(err, os.ErrNotExist):
+				status = http.StatusNotFound
+			case errors.Is(err, errE2EERoom):
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, clip)
+		fmt.Fprintf(w, "Playing %s in %s", clip, roomUUID)
+	case "announce":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		text := r.URL.Query().Get("text")
+		if err := h.Announce(r.Context(), roomUUID, text); err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, errRoomNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, errTTSDisabled):
+				status = http.StatusNotImplemented
+			case errors.Is(err, errE2EERoom):
+				status = http.StatusConflict
+			case !errors.Is(err, errInvalidAnnouncement):
+				status = http.StatusBadGateway
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		h.audit(r, action, roomUUID, text)
+		fmt.Fprintf(w, "Announced in %s", roomUUID)
+	case "room_stats":
+		roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+		h.RoomManager.Lock.RLock()
+		room := h.RoomManager.Rooms[roomUUID]
+		h.RoomManager.Lock.RUnlock()
+		if room == nil {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		stats := map[string]any{
+			"room":          roomUUID,
+			"peers":         room.Stats(),
+			"hls_listeners": room.HLSListeners(),
+			"bandwidth":     room.BandwidthStats(),
+		}
+		if limit := h.maxPublishBitrate(room); limit > 0 {
+			stats["max_bitrate_kbps"] = limit / 1000
+		}
+		if quota := h.RoomManager.Quota; quota != nil {
+			stats["quota"] = map[string]any{
+				"monthly_bytes": quota.MonthlyBytes,
+				"used_bytes":    quota.Used(roomUUID, time.Now()),
+				"action":        quota.Action,
+			}
+		}
+		json.NewEncoder(w).Encode(stats)
+	case "quota":
+		h.getQuota(w, r)
+	case "peer":
+		_, peer := h.RoomManager.FindPeer(strings.TrimSpace(r.URL.Query().Get("peer_id")))
+		if peer == nil {
+			http.Error(w, "Peer not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(peer.Diagnostics())
+	case "peer_stats":
+		peerID := strings.TrimSpace(r.URL.Query().Get("peer_id"))
+		_, peer := h.RoomManager.FindPeer(peerID)
+		if peer == nil {
+			http.Error(w, "Peer not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"peer_id":          peerID,
+			"interval_seconds": statSampleInterval.Seconds(),
+			"samples":          peer.StatHistory(),
+		})
+	case "peer_timeline":
+		timeline, ok := h.RoomManager.PeerTimeline(strings.TrimSpace(r.URL.Query().Get("peer_id")))
+		if !ok {
+			http.Error(w, "Peer not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(timeline)
+	case "ice_restart":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		peerID := strings.TrimSpace(r.URL.Query().Get("peer_id"))
+		_, peer := h.RoomManager.FindPeer(peerID)
+		if peer == nil {
+			http.Error(w, "Peer not found", http.StatusNotFound)
+			return
+		}
+		if peer.PC == nil {
+			http.Error(w, "Peer has no peer connection yet", http.StatusConflict)
+			return
+		}
+		if !h.requestICERestart(peer) {
+			http.Error(w, "ICE restart already requested recently", http.StatusTooManyRequests)
+			return
+		}
+		h.audit(r, action, peerID, "")
+		fmt.Fprintf(w, "ICE restart requested for %s", peerID)
+	case "impair":
+		if r.Method == http.MethodPost {
+			imp, err := parseImpairment(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := h.SetImpairment(imp); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			slog.Warn("Forwarding impairment changed", "event", "IMPAIRMENT", "impairment", imp.String())
+			h.audit(r, action, "", imp.String())
+		}
+		imp := h.Impairment()
+		json.NewEncoder(w).Encode(map[string]any{
+			"enabled":         h.Chaos,
+			"loss_percent":    imp.Loss,
+			"jitter_ms":       imp.Jitter.Milliseconds(),
+			"reorder_percent": imp.Reorder,
+		})
+	case "log_level":
+		if r.Method == http.MethodPost {
+			level := r.URL.Query().Get("level")
+			previous := logger.Level()
+			if err := logger.SetLevel(level); err != nil {
+				http.Error(w, "Invalid log level", http.StatusBadRequest)
+				return
+			}
+			slog.Warn("Log level changed", "from", previous, "to", logger.Level())
+			h.audit(r, "log_level", logger.Level(), "from "+previous)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"level": logger.Level()})
+	case "reload":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		changes, err := h.Reload()
+		if err != nil {
+			slog.Error("Reload failed", "err", err)
+			http.Error(w, "Reload failed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.audit(r, "reload", "", fmt.Sprintf("%d changes", len(changes)))
+		if changes == nil {
+			changes = []SettingChange{}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"changes": changes})
+	case "ban", "unban":
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -37,16 +722,53 @@ func (h *Handler) HandleAdmin(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "Invalid IP address", http.StatusBadRequest)
 				return
 			}
-			h.RoomManager.BanIP(ip)
-			fmt.Fprintf(w, "Banned %s", ip)
+			if action == "ban" {
+				h.RoomManager.BanIP(ip)
+				h.RoomManager.DisconnectIP(ip, DisconnectBanned, "You have been banned")
+				fmt.Fprintf(w, "Banned %s", ip)
+			} else {
+				h.RoomManager.UnbanIP(ip)
+				fmt.Fprintf(w, "Unbanned %s", ip)
+			}
+			h.audit(r, action, ip, "")
 		}
 	default:
-		// Serve simple Admin HTML (Embedded for simplicity, or we could load from web/templates)
-		h.serveAdminUI(w)
+		h.serveAdminUI(w, http.StatusOK, true, "")
 	}
 }
 
 func (h *Handler) getStats(w http.ResponseWriter) {
+	json.NewEncoder(w).Encode(h.collectStats())
+}
+
+// ServerStats is the server-wide snapshot served by action=stats, the admin
+// event stream, /metrics and the gRPC GetStats call.
+type ServerStats struct {
+	Rooms           int     `json:"rooms"`
+	Users           int     `json:"users"`
+	BytesIn         uint64  `json:"bytes_in"`
+	BytesOut        uint64  `json:"bytes_out"`
+	MemoryAllocMB   uint64  `json:"memory_alloc_mb"`
+	Goroutines      int     `json:"goroutines"`
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	PanicsRecovered uint64  `json:"panics_recovered"`
+}
+
+// fields returns the stats by JSON name, for statsDelta.
+func (s ServerStats) fields() map[string]any {
+	return map[string]any{
+		"rooms":            s.Rooms,
+		"users":            s.Users,
+		"bytes_in":         s.BytesIn,
+		"bytes_out":        s.BytesOut,
+		"memory_alloc_mb":  s.MemoryAllocMB,
+		"goroutines":       s.Goroutines,
+		"cpu_seconds":      s.CPUSeconds,
+		"panics_recovered": s.PanicsRecovered,
+	}
+}
+
+func (h *Handler) collectStats() ServerStats {
 	h.RoomManager.Lock.RLock()
 	roomCount := len(h.RoomManager.Rooms)
 	userCount := 0
@@ -60,13 +782,263 @@ func (h *Handler) getStats(w http.ResponseWriter) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	stats := map[string]any{
-		"rooms":           roomCount,
-		"users":           userCount,
-		"memory_alloc_mb": m.Alloc / 1024 / 1024,
-		"goroutines":      runtime.NumGoroutine(),
+	bandwidth := h.RoomManager.bandwidth.snapshot()
+	return ServerStats{
+		Rooms:           roomCount,
+		Users:           userCount,
+		BytesIn:         bandwidth.BytesIn,
+		BytesOut:        bandwidth.BytesOut,
+		MemoryAllocMB:   m.Alloc / 1024 / 1024,
+		Goroutines:      runtime.NumGoroutine(),
+		CPUSeconds:      processCPUSeconds(),
+		PanicsRecovered: panicsRecovered.Load(),
 	}
-	json.NewEncoder(w).Encode(stats)
+}
+
+// HandleAdminEvents streams lifecycle events and stats changes as Server-Sent Events.
+// The first "stats" message carries the full snapshot; later ones only changed keys.
+func (h *Handler) HandleAdminEvents(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := h.RoomManager.Events.Subscribe(adminEventBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	lastStats := h.collectStats().fields()
+	if err := writeSSE(w, "stats", lastStats); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	statsTicker := time.NewTicker(adminStatsInterval)
+	defer statsTicker.Stop()
+	keepalive := time.NewTicker(adminKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			err = writeSSE(w, event.Type, event)
+		case <-statsTicker.C:
+			current := h.collectStats().fields()
+			if delta := statsDelta(lastStats, current); len(delta) > 0 {
+				err = writeSSE(w, "stats", delta)
+			}
+			lastStats = current
+		case <-keepalive.C:
+			_, err = fmt.Fprint(w, ": keepalive\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeSSE(w io.Writer, event string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// statsDelta returns the keys of current whose values differ from previous.
+func statsDelta(previous, current map[string]any) map[string]any {
+	delta := make(map[string]any)
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || old != value {
+			delta[key] = value
+		}
+	}
+	return delta
+}
+
+// getAudit returns audit entries, optionally filtered by ?type=, ?since= (RFC 3339) and ?limit=.
+func (h *Handler) getAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 100
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	var since time.Time
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+	entries, err := h.Audit.Query(query.Get("type"), since, limit)
+	if err != nil {
+		slog.Error("Failed to query audit log", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+// getUsageReport aggregates stored room sessions that started in ?from= to ?to=
+// (RFC 3339 or YYYY-MM-DD, default the last 30 days), optionally for one ?room=,
+// as JSON or ?format=csv.
+func (h *Handler) getUsageReport(w http.ResponseWriter, r *http.Request) {
+	if h.RoomManager.Usage == nil {
+		http.Error(w, "Usage reports are disabled", http.StatusNotImplemented)
+		return
+	}
+	query := r.URL.Query()
+	until := time.Now()
+	from := until.AddDate(0, 0, -30)
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &until} {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := parseReportTime(raw)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = parsed
+		}
+	}
+	if !from.Before(until) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	room := strings.TrimSpace(query.Get("room"))
+	sessions, err := h.RoomManager.Usage.Sessions(room, from, until)
+	if err != nil {
+		slog.Error("Failed to query usage log", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	report := summarizeUsage(sessions)
+	if query.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="usage-%s-%s.csv"`, from.UTC().Format("20060102"), until.UTC().Format("20060102")))
+		writeUsageCSV(w, report)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"from": from.UTC(), "to": until.UTC(), "rooms": report})
+}
+
+// getQuota reports usage this month against the quotas: one room's with ?room=,
+// one tenant's with ?tenant=, or every tenant's.
+func (h *Handler) getQuota(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	query := r.URL.Query()
+	if room := strings.TrimSpace(query.Get("room")); room != "" {
+		json.NewEncoder(w).Encode(h.RoomManager.roomQuotaUsage(room, now))
+		return
+	}
+	if id := strings.TrimSpace(query.Get("tenant")); id != "" {
+		tenant := h.RoomManager.Tenants.get(id)
+		if tenant == nil {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(h.RoomManager.tenantUsage(tenant, now))
+		return
+	}
+	tenants := []TenantUsage{}
+	for _, tenant := range h.RoomManager.Tenants.list() {
+		tenants = append(tenants, h.RoomManager.tenantUsage(tenant, now))
+	}
+	json.NewEncoder(w).Encode(map[string]any{"month": usageMonth(now), "tenants": tenants})
+}
+
+// parseReportTime accepts an RFC 3339 timestamp or a UTC date.
+func parseReportTime(raw string) (time.Time, error) {
+	if parsed, err := time.Parse(time.DateOnly, raw); err == nil {
+		return parsed, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// HandleAdminLogs queries the in-memory log store, e.g.
+// /api/admin/logs?event=USER_JOIN&room=x&since=2024-01-01T00:00:00Z&level=warn&limit=100.
+// With an EventDB, queries for an event read the persistent store instead.
+func (h *Handler) HandleAdminLogs(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	q := logger.LogQuery{
+		Event:  query.Get("event"),
+		Room:   query.Get("room"),
+		PeerID: query.Get("peer_id"),
+		Limit:  500,
+	}
+	if raw := query.Get("level"); raw != "" {
+		level, err := logger.ParseLevel(raw)
+		if err != nil {
+			http.Error(w, "Invalid level", http.StatusBadRequest)
+			return
+		}
+		q.MinLevel = &level
+	}
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dst = parsed
+		}
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		q.Limit = limit
+	}
+
+	var records []logger.LogRecord
+	if h.EventDB != nil && q.Event != "" {
+		var err error
+		if records, err = h.EventDB.QueryEvents(q); err != nil {
+			slog.Error("Failed to query event database", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		records = logger.QueryLogs(q)
+	}
+	entries := make([]json.RawMessage, 0, len(records))
+	for _, record := range records {
+		if json.Valid([]byte(record.Line)) {
+			entries = append(entries, json.RawMessage(record.Line))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
 }
 
 func (h *Handler) getLogs(w http.ResponseWriter) {
@@ -74,20 +1046,24 @@ func (h *Handler) getLogs(w http.ResponseWriter) {
 	json.NewEncoder(w).Encode(lines)
 }
 
-func (h *Handler) serveAdminUI(w http.ResponseWriter) {
-	// For a real project, we'd use a template. For this CLI-based rapid dev,
-	// a compact embedded HTML is more reliable.
-	fmt.Fprintf(w, `
-	<html>
-	<head><title>GhostTalk Admin</title><style>body{font-family:sans-serif;background:#222;color:#eee;padding:20px;}</style></head>
-	<body>
-		<h1>GhostTalk Stats</h1>
-		<div id="stats">Loading...</div>
-		<h2>Recent Logs</h2>
-		<pre id="logs" style="background:#000;padding:10px;overflow:auto;max-height:400px;"></pre>
-		<input id="ban-ip" placeholder="IP to ban"><button id="ban-btn">Ban</button>
-		<script src="/static/js/admin.js"></script>
-	</body>
-	</html>
-	`)
+func (h *Handler) serveAdminUI(w http.ResponseWriter, status int, authenticated bool, loginError string) {
+	tmpl, err := template.ParseFiles(h.AdminTemplate)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		slog.Error("Failed to parse admin template", "err", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	data := struct {
+		Authenticated bool
+		Error         string
+	}{
+		Authenticated: authenticated,
+		Error:         loginError,
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to execute admin template", "err", err)
+	}
 }
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsDelta(t *testing.T) {
+	previous := map[string]any{"rooms": 1, "users": 2}
+	current := map[string]any{"rooms": 1, "users": 3, "goroutines": 10}
+
+	delta := statsDelta(previous, current)
+	if len(delta) != 2 || delta["users"] != 3 || delta["goroutines"] != 10 {
+		t.Fatalf("unexpected delta: %#v", delta)
+	}
+	if len(statsDelta(current, current)) != 0 {
+		t.Fatal("expected empty delta for identical stats")
+	}
+}
+
+func newTestAdminHandler(t *testing.T) *Handler {
+	t.Helper()
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, nil, nil, nil)
+	handler.AdminTemplate = filepath.Join("..", "..", "web", "templates", "admin.html")
+	return handler
+}
+
+// adminRequest calls HandleAdmin with the admin key, query being the part of
+// the /admin URL after "?".
+func adminRequest(t *testing.T, h *Handler, method, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/admin?"+query, nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	h.HandleAdmin(rec, req)
+	return rec
+}
+
+func TestHandleAdminEventsRequiresKey(t *testing.T) {
+	handler := newTestAdminHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.HandleAdminEvents(rec, httptest.NewRequest(http.MethodGet, "/admin/events?key=test-key", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d (query keys must not authenticate)", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminLoginIssuesSessionCookie(t *testing.T) {
+	handler := newTestAdminHandler(t)
+
+	form := url.Values{"key": {"wrong"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.HandleAdminLogin(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d for wrong key", rec.Code, http.StatusUnauthorized)
+	}
+
+	form.Set("key", "test-key")
+	req = httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.HandleAdminLogin(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != adminSessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("unexpected cookies: %#v", cookies)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin?action=stats", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	handler.HandleAdmin(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stats status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/logout", nil)
+	req.AddCookie(cookies[0])
+	handler.HandleAdminLogout(httptest.NewRecorder(), req)
+	if handler.AdminSessions.Valid(cookies[0].Value) {
+		t.Fatal("expected session to be revoked after logout")
+	}
+}
+
+func TestAdminSessionCookieSecureOnlyFromTrustedProxy(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	login := func(remoteAddr string) *http.Cookie {
+		form := url.Values{"key": {"test-key"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		rec := httptest.NewRecorder()
+		handler.HandleAdminLogin(rec, req)
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("unexpected cookies: %#v", cookies)
+		}
+		return cookies[0]
+	}
+
+	if login("203.0.113.9:1234").Secure {
+		t.Fatal("expected X-Forwarded-Proto from an untrusted client to be ignored")
+	}
+	if !login("10.0.0.2:1234").Secure {
+		t.Fatal("expected X-Forwarded-Proto from a trusted proxy to mark the cookie Secure")
+	}
+}
+
+func TestHandleAdminServesLoginPageWhenUnauthenticated(t *testing.T) {
+	handler := newTestAdminHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.HandleAdmin(rec, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), `action="/admin/login"`) {
+		t.Fatal("expected login form")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleAdmin(rec, httptest.NewRequest(http.MethodGet, "/admin?action=stats", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleAdminEventsStreamsLifecycleEvents(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	rm := handler.RoomManager
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandleAdminEvents))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer test-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read stream: %v", err)
+			}
+			if strings.HasPrefix(line, "event: ") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+			}
+		}
+	}
+
+	if got := readEvent(); got != "stats" {
+		t.Fatalf("first event = %q, want stats", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for rm.Events.SubscriberCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	rm.GetOrCreateRoom("room-a")
+
+	if got := readEvent(); got != EventRoomCreate {
+		t.Fatalf("event = %q, want %q", got, EventRoomCreate)
+	}
+}
+
+func TestHandleAdminLogsValidatesQuery(t *testing.T) {
+	handler := newTestAdminHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.HandleAdminLogs(rec, httptest.NewRequest(http.MethodGet, "/api/admin/logs", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	for _, query := range []string{"level=loud", "since=yesterday", "limit=-1"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/logs?"+query, nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec = httptest.NewRecorder()
+		handler.HandleAdminLogs(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/logs?event=USER_JOIN", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec = httptest.NewRecorder()
+	handler.HandleAdminLogs(rec, req)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sigmartc/internal/logger"
+	"sigmartc/internal/soundboard"
+)
+
+const (
+	// announcementSenderID is the synthetic publisher for spoken announcements; it is
+	// separate from the soundboard so announcements can play over hold music.
+	announcementSenderID = "announcement"
+	maxAnnouncementRunes = 500
+	ttsTimeout           = 15 * time.Second
+)
+
+var (
+	errTTSDisabled         = errors.New("text-to-speech is disabled")
+	errInvalidAnnouncement = errors.New("announcement text must be 1-500 characters")
+)
+
+// Announce synthesizes text with the configured TTS backend and speaks it into the room.
+func (h *Handler) Announce(ctx context.Context, roomUUID, text string) error {
+	if h.TTS == nil {
+		return errTTSDisabled
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || utf8.RuneCountInString(text) > maxAnnouncementRunes {
+		return errInvalidAnnouncement
+	}
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return errRoomNotFound
+	}
+	if room.IsE2EE() {
+		return errE2EERoom
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ttsTimeout)
+	defer cancel()
+	audio, err := h.TTS.Synthesize(ctx, text)
+	if err != nil {
+		return err
+	}
+	packets, err := soundboard.ReadOggOpus(bytes.NewReader(audio))
+	if err != nil {
+		return err
+	}
+
+	h.playPackets(room, announcementSenderID, packets, false, nil)
+	logger.LogEvent("ANNOUNCEMENT", slog.String("uuid", roomUUID), slog.String("text", text))
+	return nil
+}
+
+// announceAsync speaks an automatic announcement if TTS is configured, logging failures.
+func (h *Handler) announceAsync(roomUUID, text string) {
+	if h.TTS == nil {
+		return
+	}
+	go func() {
+		if err := h.Announce(context.Background(), roomUUID, text); err != nil {
+			slog.Warn("Announcement failed", "uuid", roomUUID, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type stubSynthesizer struct {
+	audio []byte
+	err   error
+	texts []string
+}
+
+func (s *stubSynthesizer) Synthesize(_ context.Context, text string) ([]byte, error) {
+	s.texts = append(s.texts, text)
+	return s.audio, s.err
+}
+
+func TestAdminAnnounceSpeaksIntoRoom(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	room := handler.RoomManager.GetOrCreateRoom("room-a")
+
+	announce := func(room, text string) int {
+		query := url.Values{"action": {"announce"}, "room": {room}, "text": {text}}
+		return adminRequest(t, handler, http.MethodPost, query.Encode()).Code
+	}
+
+	if got := announce("room-a", "hello"); got != http.StatusNotImplemented {
+		t.Fatalf("without TTS: status = %d", got)
+	}
+
+	clip := filepath.Join(t.TempDir(), "speech.ogg")
+	writeTestClip(t, clip, 50)
+	audio, err := os.ReadFile(clip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	synth := &stubSynthesizer{audio: audio}
+	handler.TTS = synth
+
+	for text, want := range map[string]int{
+		"   ":                    http.StatusBadRequest,
+		strings.Repeat("a", 501): http.StatusBadRequest,
+	} {
+		if got := announce("room-a", text); got != want {
+			t.Fatalf("text len %d: status = %d, want %d", len(text), got, want)
+		}
+	}
+	if got := announce("missing", "hello"); got != http.StatusNotFound {
+		t.Fatalf("missing room: status = %d", got)
+	}
+
+	if got := announce("room-a", "Room closes in 5 minutes"); got != http.StatusOK {
+		t.Fatalf("announce: status = %d", got)
+	}
+	if len(synth.texts) != 1 || synth.texts[0] != "Room closes in 5 minutes" {
+		t.Fatalf("synthesized %q", synth.texts)
+	}
+	room.ForwardersMu.RLock()
+	_, playing := room.Forwarders[announcementSenderID]
+	room.ForwardersMu.RUnlock()
+	if !playing {
+		t.Fatal("expected announcement track in room")
+	}
+
+	synth.err = errors.New("backend down")
+	if got := announce("room-a", "again"); got != http.StatusBadGateway {
+		t.Fatalf("backend error: status = %d", got)
+	}
+	handler.RoomManager.CloseRoom("room-a", DisconnectRoomClosed, "closed")
+}
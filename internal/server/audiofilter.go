@@ -0,0 +1,91 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/internal/egress"
+	"sigmartc/internal/soundboard"
+)
+
+// Audio filters: a publisher's audio can go through one ffmpeg re-encoder
+// before fan-out, which chains noise suppression (per peer, see denoise.go)
+// and loudness leveling (per room, see loudness.go). Each filtered publisher
+// costs an ffmpeg process and ~20-40ms of latency, so both are off by default.
+// Filtered audio carries no audio level extension; speech detection still runs
+// on the original packets.
+
+// filterStage is a forwarder's re-encoder and the filter chain it runs.
+type filterStage struct {
+	*reencodeStage
+	chain string
+}
+
+// audioFilters returns the ffmpeg filter chain for senderID's audio in room,
+// or "" for none.
+func (h *Handler) audioFilters(room *Room, senderID string) string {
+	var filters []string
+	room.Lock.RLock()
+	sender := room.Peers[senderID]
+	room.Lock.RUnlock()
+	if sender != nil && sender.denoise.Load() && h.DenoiseModel != "" {
+		filters = append(filters, egress.DenoiseFilter(h.DenoiseModel))
+	}
+	if room.leveling.Load() {
+		filters = append(filters, egress.SpeechNormFilter)
+	}
+	return strings.Join(filters, ",")
+}
+
+// applyAudioFilters starts, restarts or stops the forwarder's filter stage to
+// match its room and publisher.
+func (h *Handler) applyAudioFilters(room *Room, forwarder *TrackForwarder) {
+	forwarder.filterMu.Lock()
+	defer forwarder.filterMu.Unlock()
+
+	chain := ""
+	if h.FFmpegPath != "" && strings.EqualFold(forwarder.Codec().MimeType, webrtc.MimeTypeOpus) {
+		chain = h.audioFilters(room, forwarder.SenderID)
+	}
+	current := forwarder.filter.Load()
+	if current == nil && chain == "" || current != nil && current.chain == chain {
+		return
+	}
+	if chain == "" {
+		forwarder.setFilter(nil)
+		return
+	}
+	stage, err := newReencodeStage(forwarder.ctx, func(onPacket func(soundboard.Packet)) (*egress.Reencoder, error) {
+		return egress.NewFilter(h.FFmpegPath, chain, onPacket)
+	}, func(packet *rtp.Packet) {
+		packet.PayloadType = forwarder.outputPayloadType
+		if raw, err := packet.Marshal(); err == nil {
+			forwarder.fanOut(raw)
+		}
+	})
+	if err != nil {
+		forwarder.log().Warn("Audio filters failed to start", "filters", chain, "err", err)
+		forwarder.setFilter(nil)
+		return
+	}
+	forwarder.setFilter(&filterStage{reencodeStage: stage, chain: chain})
+}
+
+// setFilter replaces the forwarder's filter stage (nil fans out directly) and
+// closes the one it replaces.
+func (f *TrackForwarder) setFilter(stage *filterStage) {
+	if previous := f.filter.Swap(stage); previous != nil && previous != stage {
+		previous.close()
+	}
+}
+
+// deliver fans packet out, through the filter stage when there is one.
+func (f *TrackForwarder) deliver(packet []byte) {
+	if filter := f.filter.Load(); filter != nil {
+		filter.feed(packet)
+		return
+	}
+	f.fanOut(packet)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"sigmartc/internal/eventdb"
+)
+
+// AuditEntry records a single admin action.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	IP     string    `json:"ip"`
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// AuditLog appends admin actions as JSON lines to a dedicated file, separate from
+// server.log, or to the event database when opened with NewAuditLogDB.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	db   *eventdb.DB
+}
+
+func NewAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{path: path, file: file}, nil
+}
+
+// NewAuditLogDB stores admin actions in db. Closing the log does not close db.
+func NewAuditLogDB(db *eventdb.DB) *AuditLog {
+	return &AuditLog{db: db}
+}
+
+// Record appends an entry. It is safe to call on a nil log.
+func (a *AuditLog) Record(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("Failed to marshal audit entry", "err", err)
+		return
+	}
+	if a.db != nil {
+		if err := a.db.AddAudit(entry.Time, entry.Action, data); err != nil {
+			slog.Error("Failed to write audit entry", "action", entry.Action, "err", err)
+		}
+		return
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(data); err != nil {
+		slog.Error("Failed to write audit entry", "action", entry.Action, "err", err)
+	}
+}
+
+// Query returns up to limit of the most recent entries matching action (empty matches all)
+// recorded at or after since.
+func (a *AuditLog) Query(action string, since time.Time, limit int) ([]AuditEntry, error) {
+	if a == nil {
+		return nil, nil
+	}
+	if a.db != nil {
+		return a.queryDB(action, since, limit)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]AuditEntry, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if action != "" && entry.Action != action {
+			continue
+		}
+		if entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+func (a *AuditLog) queryDB(action string, since time.Time, limit int) ([]AuditEntry, error) {
+	rows, err := a.db.Audit(action, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		var entry AuditEntry
+		if err := json.Unmarshal(row, &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Close closes the audit file.
+func (a *AuditLog) Close() error {
+	if a == nil || a.db != nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// audit records an admin action performed by the caller of r.
+func (h *Handler) audit(r *http.Request, action, target, detail string) {
+	h.Audit.Record(AuditEntry{
+		Actor:  adminActor(r),
+		IP:     h.clientIP(r),
+		Action: action,
+		Target: target,
+		Detail: detail,
+	})
+}
+
+// adminActor identifies the admin credential without storing it: session cookies are
+// reduced to a short hash, bearer keys are reported as "api-key", or as
+// "tenant:<id>" for a tenant's key.
+func adminActor(r *http.Request) string {
+	if tenant, ok := r.Context().Value(tenantKey{}).(*Tenant); ok {
+		return "tenant:" + tenant.ID
+	}
+	if cookie, err := r.Cookie(adminSessionCookie); err == nil && cookie.Value != "" {
+		sum := sha256.Sum256([]byte(cookie.Value))
+		return "session:" + hex.EncodeToString(sum[:4])
+	}
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return "api-key"
+	}
+	return "anonymous"
+}
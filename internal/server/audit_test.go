@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sigmartc/internal/eventdb"
+)
+
+func TestAuditLogRecordAndQuery(t *testing.T) {
+	auditLog, err := NewAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	old := time.Now().Add(-time.Hour).UTC()
+	auditLog.Record(AuditEntry{Time: old, Actor: "api-key", Action: "ban", Target: "203.0.113.1"})
+	auditLog.Record(AuditEntry{Actor: "api-key", Action: "ban", Target: "203.0.113.2"})
+	auditLog.Record(AuditEntry{Actor: "api-key", Action: "unban", Target: "203.0.113.1"})
+
+	entries, err := auditLog.Query("ban", time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 ban entries, got %d", len(entries))
+	}
+
+	entries, err = auditLog.Query("", time.Now().Add(-time.Minute), 0)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Target != "203.0.113.2" {
+		t.Fatalf("unexpected entries since: %#v", entries)
+	}
+
+	entries, err = auditLog.Query("", time.Time{}, 1)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != "unban" {
+		t.Fatalf("expected most recent entry, got %#v", entries)
+	}
+}
+
+func TestAdminBanIsAudited(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	auditLog, err := NewAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
+	handler.Audit = auditLog
+
+	adminRequest(t, handler, http.MethodPost, "action=ban&ip=198.51.100.4")
+	rec := adminRequest(t, handler, http.MethodGet, "action=audit&type=ban")
+
+	var entries []AuditEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to decode audit response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Target != "198.51.100.4" || entries[0].Actor != "api-key" {
+		t.Fatalf("unexpected audit entries: %#v", entries)
+	}
+}
+
+func TestAuditLogInEventDB(t *testing.T) {
+	db, err := eventdb.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	auditLog := NewAuditLogDB(db)
+	auditLog.Record(AuditEntry{Actor: "api-key", Action: "ban", Target: "203.0.113.1"})
+	auditLog.Record(AuditEntry{Actor: "api-key", Action: "unban", Target: "203.0.113.1"})
+	auditLog.Close()
+
+	entries, err := NewAuditLogDB(db).Query("ban", time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Target != "203.0.113.1" || entries[0].Time.IsZero() {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+}
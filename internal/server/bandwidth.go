@@ -0,0 +1,213 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sigmartc/internal/logger"
+	"sigmartc/pkg/protocol"
+)
+
+// QuotaAction is what happens to a room once it exceeds its monthly byte quota.
+type QuotaAction string
+
+const (
+	// QuotaWarn only logs and emits a quota_exceeded event.
+	QuotaWarn QuotaAction = "warn"
+	// QuotaThrottle also stops forwarding silent packets for the rest of the month.
+	QuotaThrottle QuotaAction = "throttle"
+	// QuotaClose also closes the room and refuses joins until the month rolls over.
+	QuotaClose QuotaAction = "close"
+)
+
+// ParseQuotaAction validates a -room-quota-action value.
+func ParseQuotaAction(s string) (QuotaAction, error) {
+	switch action := QuotaAction(strings.ToLower(strings.TrimSpace(s))); action {
+	case QuotaWarn, QuotaThrottle, QuotaClose:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown quota action %q (want warn, throttle or close)", s)
+}
+
+// BandwidthQuota limits the RTP bytes (received plus forwarded) each room may use
+// per calendar month (UTC). Bytes are counted per packet into the room's
+// atomics and charged here once a second by chargeQuotas. Usage is kept in
+// memory only and resets on restart, unlike the minutes quota.
+type BandwidthQuota struct {
+	MonthlyBytes uint64
+	Action       QuotaAction
+
+	mu    sync.Mutex
+	usage map[string]*roomUsage
+	// month is the month last charged. Room names are client-chosen, so
+	// entries from earlier months are pruned once it rolls over.
+	month int
+}
+
+type roomUsage struct {
+	month    int
+	bytes    uint64
+	exceeded bool
+}
+
+// monthKey identifies the calendar month (UTC) of now without formatting it.
+func monthKey(now time.Time) int {
+	year, month, _ := now.UTC().Date()
+	return year*12 + int(month) - 1
+}
+
+// NewBandwidthQuota creates a quota of monthlyBytes per room.
+func NewBandwidthQuota(monthlyBytes uint64, action QuotaAction) *BandwidthQuota {
+	return &BandwidthQuota{MonthlyBytes: monthlyBytes, Action: action, usage: make(map[string]*roomUsage)}
+}
+
+// charge adds n bytes to the room's usage for the current month. It reports
+// whether the room is over quota and whether this call crossed the limit.
+func (q *BandwidthQuota) charge(roomUUID string, n uint64, now time.Time) (exceeded, crossed bool) {
+	month := monthKey(now)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if month != q.month {
+		for uuid, u := range q.usage {
+			if u.month != month {
+				delete(q.usage, uuid)
+			}
+		}
+		q.month = month
+	}
+	u := q.usage[roomUUID]
+	if u == nil || u.month != month {
+		u = &roomUsage{month: month}
+		q.usage[roomUUID] = u
+	}
+	u.bytes += n
+	if !u.exceeded && u.bytes > q.MonthlyBytes {
+		u.exceeded = true
+		crossed = true
+	}
+	return u.exceeded, crossed
+}
+
+// Used returns the room's usage this month.
+func (q *BandwidthQuota) Used(roomUUID string, now time.Time) uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if u := q.usage[roomUUID]; u != nil && u.month == monthKey(now) {
+		return u.bytes
+	}
+	return 0
+}
+
+// blocksJoin reports whether the close action is keeping the room shut this month.
+func (q *BandwidthQuota) blocksJoin(roomUUID string, now time.Time) bool {
+	if q == nil || q.Action != QuotaClose {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.usage[roomUUID]
+	return u != nil && u.exceeded && u.month == monthKey(now)
+}
+
+// bandwidthCounters are cumulative RTP byte totals.
+type bandwidthCounters struct {
+	in  atomic.Uint64
+	out atomic.Uint64
+}
+
+// BandwidthStats is a bytes in/out snapshot for stats responses.
+type BandwidthStats = protocol.BandwidthStats
+
+func (c *bandwidthCounters) snapshot() BandwidthStats {
+	return BandwidthStats{BytesIn: c.in.Load(), BytesOut: c.out.Load()}
+}
+
+// countPublished records n bytes of RTP received from a peer in room. It runs
+// per packet, so it only touches atomics.
+func (rm *RoomManager) countPublished(room *Room, n int) {
+	room.bandwidth.in.Add(uint64(n))
+	rm.bandwidth.in.Add(uint64(n))
+	if rm.Quota != nil {
+		room.unchargedBytes.Add(uint64(n))
+	}
+}
+
+// countForwarded records one n-byte packet written to each of receiverIDs. It
+// runs per packet, so it only touches atomics.
+func (rm *RoomManager) countForwarded(room *Room, receiverIDs []string, n int) {
+	total := uint64(n * len(receiverIDs))
+	room.bandwidth.out.Add(total)
+	rm.bandwidth.out.Add(total)
+	for _, id := range receiverIDs {
+		if peer, ok := room.peerIndex.Load(id); ok {
+			peer.(*Peer).bandwidth.out.Add(uint64(n))
+		}
+	}
+	if rm.Quota != nil {
+		room.unchargedBytes.Add(total)
+	}
+}
+
+// addPeer adds p to the room. The caller must hold r.Lock.
+func (r *Room) addPeer(p *Peer) {
+	r.Peers[p.ID] = p
+	r.peerIndex.Store(p.ID, p)
+}
+
+// removePeer removes the peer from the room. The caller must hold r.Lock.
+func (r *Room) removePeer(peerID string) {
+	delete(r.Peers, peerID)
+	r.peerIndex.Delete(peerID)
+}
+
+func (rm *RoomManager) startQuotaTicker() {
+	ticker := time.NewTicker(time.Second)
+	for now := range ticker.C {
+		func() {
+			defer recoverGoroutine("quota")
+			rm.chargeQuotas(now)
+		}()
+	}
+}
+
+// chargeQuotas charges each room's bytes counted since the last call to the
+// bandwidth quota.
+func (rm *RoomManager) chargeQuotas(now time.Time) {
+	if rm.Quota == nil {
+		return
+	}
+	rm.Lock.RLock()
+	rooms := make([]*Room, 0, len(rm.Rooms))
+	for _, room := range rm.Rooms {
+		rooms = append(rooms, room)
+	}
+	rm.Lock.RUnlock()
+	for _, room := range rooms {
+		if n := room.unchargedBytes.Swap(0); n > 0 {
+			rm.chargeQuota(room, n, now)
+		}
+	}
+}
+
+func (rm *RoomManager) chargeQuota(room *Room, n uint64, now time.Time) {
+	q := rm.Quota
+	exceeded, crossed := q.charge(room.UUID, n, now)
+	room.throttled.Store(exceeded && q.Action == QuotaThrottle)
+	if !crossed {
+		return
+	}
+	logger.LogEvent("QUOTA_EXCEEDED", slog.String("uuid", room.UUID), slog.String("action", string(q.Action)))
+	rm.emit(EventQuotaExceeded, map[string]any{"room": room.UUID, "action": string(q.Action), "monthly_bytes": q.MonthlyBytes})
+	if q.Action == QuotaClose {
+		go rm.CloseRoom(room.UUID, DisconnectQuotaExceeded, "This room has used its bandwidth for the month")
+	}
+}
+
+// BandwidthStats returns the room's RTP byte totals since it was created.
+func (r *Room) BandwidthStats() BandwidthStats {
+	return r.bandwidth.snapshot()
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBandwidthQuotaResetsMonthly(t *testing.T) {
+	q := NewBandwidthQuota(100, QuotaClose)
+	march := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+
+	if exceeded, crossed := q.charge("room", 100, march); exceeded || crossed {
+		t.Fatal("usage equal to the quota should not exceed it")
+	}
+	if exceeded, crossed := q.charge("room", 1, march); !exceeded || !crossed {
+		t.Fatal("expected the quota to be crossed")
+	}
+	if _, crossed := q.charge("room", 1, march); crossed {
+		t.Fatal("crossed should be reported only once per month")
+	}
+	if !q.blocksJoin("room", march) || q.blocksJoin("other", march) {
+		t.Fatal("expected only the exhausted room to refuse joins")
+	}
+	q.charge("gone", 1, march)
+
+	april := march.Add(2 * time.Hour)
+	if q.blocksJoin("room", april) || q.Used("room", april) != 0 {
+		t.Fatal("expected usage to reset in a new month")
+	}
+	if exceeded, _ := q.charge("room", 10, april); exceeded {
+		t.Fatal("expected the new month to start under quota")
+	}
+	if len(q.usage) != 1 {
+		t.Fatalf("usage holds %d rooms, want March's pruned", len(q.usage))
+	}
+}
+
+func TestThrottleQuotaMarksRoomAndCountsPeers(t *testing.T) {
+	rm := NewRoomManager("", filepath.Join(t.TempDir(), "banned.json"))
+	rm.Quota = NewBandwidthQuota(1000, QuotaThrottle)
+	room := rm.GetOrCreateRoom("busy")
+	peer := &Peer{ID: "bob"}
+	room.Lock.Lock()
+	room.addPeer(peer)
+	room.Lock.Unlock()
+
+	rm.countPublished(room, 400)
+	rm.countForwarded(room, []string{"bob", "gone"}, 200)
+	rm.chargeQuotas(time.Now())
+	if room.throttled.Load() {
+		t.Fatal("room throttled before reaching its quota")
+	}
+	if got := room.BandwidthStats(); got.BytesIn != 400 || got.BytesOut != 400 {
+		t.Fatalf("room bandwidth = %+v", got)
+	}
+	if got := peer.bandwidth.snapshot().BytesOut; got != 200 {
+		t.Fatalf("peer bytes out = %d, want 200", got)
+	}
+
+	rm.countPublished(room, 300)
+	if room.throttled.Load() {
+		t.Fatal("room throttled before its bytes were charged")
+	}
+	rm.chargeQuotas(time.Now())
+	if !room.throttled.Load() {
+		t.Fatal("expected the room to be throttled over quota")
+	}
+	if got := rm.Quota.Used("busy", time.Now()); got != 1100 {
+		t.Fatalf("quota used = %d, want 1100", got)
+	}
+}
+
+func TestParseQuotaAction(t *testing.T) {
+	if action, err := ParseQuotaAction(" Throttle "); err != nil || action != QuotaThrottle {
+		t.Fatalf("ParseQuotaAction = %q, %v", action, err)
+	}
+	if _, err := ParseQuotaAction("drop"); err == nil {
+		t.Fatal("expected an unknown action to be rejected")
+	}
+}
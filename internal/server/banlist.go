@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"sigmartc/internal/banstore"
+)
+
+// banStore returns where bans persist.
+func (rm *RoomManager) banStore() banstore.Store {
+	if rm.Bans != nil {
+		return rm.Bans
+	}
+	return &banstore.File{Path: rm.BanListPath}
+}
+
+// ReloadBanList replaces the bans with the store's, which other tools or
+// nodes sharing the store may have changed, and disconnects peers whose IP is
+// newly banned. It returns the ban keys added and removed.
+func (rm *RoomManager) ReloadBanList() (added, removed []string, err error) {
+	rm.banMu.Lock()
+	bans, err := rm.banStore().Load()
+	if err != nil {
+		rm.banMu.Unlock()
+		return nil, nil, err
+	}
+	rm.Lock.Lock()
+	for key, banned := range bans {
+		if banned && !rm.BannedIPs[key] {
+			added = append(added, key)
+		}
+	}
+	for key, banned := range rm.BannedIPs {
+		if banned && !bans[key] {
+			removed = append(removed, key)
+		}
+	}
+	rm.BannedIPs = bans
+	rm.Lock.Unlock()
+	rm.banMu.Unlock()
+
+	slices.Sort(added)
+	slices.Sort(removed)
+	for _, key := range added {
+		// Tenant bans are keyed "<tenant>/<ip>" and only apply in its rooms.
+		tenant := rm.Tenants.forRoom(key)
+		rm.disconnectIP(tenant.local(key), tenant, DisconnectBanned, "You have been banned")
+	}
+	return added, removed, nil
+}
+
+// WatchBanList reloads the bans whenever the store's version changes, checking
+// every interval until ctx is done, so bans written by other tools or other
+// nodes apply here and newly banned IPs are disconnected. A JSON file that
+// fails to parse, such as one written in place and caught half-way, keeps the
+// current bans until it changes again.
+func (rm *RoomManager) WatchBanList(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last, _ := rm.banStore().Version()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		version, err := rm.banStore().Version()
+		if err != nil {
+			slog.Warn("Failed to check ban list", "err", err)
+			continue
+		}
+		if version == last {
+			continue
+		}
+		last = version
+		added, removed, err := rm.ReloadBanList()
+		if err != nil {
+			slog.Error("Failed to reload ban list", "err", err)
+			continue
+		}
+		if len(added) > 0 || len(removed) > 0 {
+			slog.Info("Ban list changed in the store", "banned", added, "unbanned", removed)
+		}
+	}
+}
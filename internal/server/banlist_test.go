@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sigmartc/internal/banstore"
+)
+
+func TestBanStoreSharedBetweenNodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.db")
+	open := func() *RoomManager {
+		store, err := banstore.OpenSQLite(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return &RoomManager{Rooms: make(map[string]*Room), BannedIPs: make(map[string]bool), Bans: store}
+	}
+	node1, node2 := open(), open()
+
+	node1.BanIP("192.0.2.1")
+	if node2.IsBanned("192.0.2.1") {
+		t.Fatal("ban visible before the other node reloaded")
+	}
+	if added, _, err := node2.ReloadBanList(); err != nil || len(added) != 1 || !node2.IsBanned("192.0.2.1") {
+		t.Fatalf("reload added %v, %v", added, err)
+	}
+	node2.UnbanIP("192.0.2.1")
+	if _, removed, _ := node1.ReloadBanList(); len(removed) != 1 || node1.IsBanned("192.0.2.1") {
+		t.Fatalf("reload removed %v", removed)
+	}
+}
+
+func TestWatchBanListAppliesExternalEdits(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	rm := handler.RoomManager
+	alice := dialTestWS(t, srv.URL, "lobby", "alice")
+	readUntilType(t, alice, "room_state")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rm.WatchBanList(ctx, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	// A half-written file is skipped; the finished one applies.
+	if err := os.WriteFile(rm.BanListPath, []byte(`{"127.0.0.1": tr`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(rm.BanListPath, []byte(`{"127.0.0.1": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readUntilType(t, alice, "disconnect"); msg["reason"] != string(DisconnectBanned) {
+		t.Fatalf("disconnect = %v", msg)
+	}
+	if !rm.IsBanned("127.0.0.1") {
+		t.Fatal("edited ban not loaded")
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/internal/logger"
+)
+
+const (
+	// bitrateWindow is how often a publisher's inbound bitrate is measured.
+	bitrateWindow = time.Second
+	// bitrateGrace is how long a publisher may stay over the cap after the
+	// first REMB/TMMBR before the forwarder drops its excess packets.
+	bitrateGrace = 5 * time.Second
+)
+
+var errInvalidBitrate = errors.New("bitrate must be a non-negative number of kbps")
+
+// bitrateLimiter measures a forwarder's inbound bitrate and enforces a cap. It
+// is owned by the forwarder's read loop.
+type bitrateLimiter struct {
+	windowStart time.Time
+	windowBytes int
+	dropped     int
+	// overSince is when the current run of over-cap windows began.
+	overSince time.Time
+}
+
+// bitrateViolation describes a measurement window that ended over the cap.
+type bitrateViolation struct {
+	Bitrate int // measured, bits per second
+	Limit   int
+	Dropped int  // packets dropped during the window
+	Started bool // first window of a new violation
+}
+
+// push accounts for an n-byte packet against limit (bits per second) and reports
+// whether to forward it. A violation is returned when the packet closes a window
+// that was over the cap. Once a publisher has been over the cap for bitrateGrace,
+// packets beyond the window's byte budget are dropped.
+func (l *bitrateLimiter) push(n, limit int, now time.Time) (forward bool, violation *bitrateViolation) {
+	if l.windowStart.IsZero() {
+		l.windowStart = now
+	}
+	if elapsed := now.Sub(l.windowStart); elapsed >= bitrateWindow {
+		bitrate := int(float64(l.windowBytes*8) / elapsed.Seconds())
+		if bitrate > limit {
+			violation = &bitrateViolation{Bitrate: bitrate, Limit: limit, Dropped: l.dropped, Started: l.overSince.IsZero()}
+			if l.overSince.IsZero() {
+				l.overSince = l.windowStart
+			}
+		} else {
+			l.overSince = time.Time{}
+		}
+		l.windowStart, l.windowBytes, l.dropped = now, 0, 0
+	}
+	l.windowBytes += n
+	budget := int(int64(limit) * int64(bitrateWindow) / int64(time.Second) / 8)
+	if !l.overSince.IsZero() && now.Sub(l.overSince) >= bitrateGrace && l.windowBytes > budget {
+		l.dropped++
+		return false, violation
+	}
+	return true, violation
+}
+
+// maxPublishBitrate returns the room's publisher cap in bits per second: the
+// room's own setting, else the server default, lowered to the room's codec
+// policy. Zero means no cap.
+func (h *Handler) maxPublishBitrate(room *Room) int {
+	limit := h.publishBitrateKbps() * 1000
+	if room == nil {
+		return limit
+	}
+	if kbps := room.maxBitrateKbps.Load(); kbps > 0 {
+		limit = int(kbps) * 1000
+	}
+	if policy := room.CodecPolicy(); policy != nil && policy.MaxBitrateKbps > 0 {
+		if policyLimit := (policy.MaxBitrateKbps + policyHeaderKbps) * 1000; limit == 0 || policyLimit < limit {
+			limit = policyLimit
+		}
+	}
+	return limit
+}
+
+// SetMaxBitrate overrides the server's publisher bitrate cap for one room; zero
+// restores the default.
+func (rm *RoomManager) SetMaxBitrate(uuid string, kbps int) error {
+	if kbps < 0 {
+		return errInvalidBitrate
+	}
+	rm.Lock.RLock()
+	room := rm.Rooms[uuid]
+	rm.Lock.RUnlock()
+	if room == nil {
+		return errRoomNotFound
+	}
+	room.maxBitrateKbps.Store(int64(kbps))
+	return nil
+}
+
+// overBitrate records a publisher's violation and asks its encoder to slow down.
+func (h *Handler) overBitrate(sender *Peer, track *webrtc.TrackRemote, v *bitrateViolation) {
+	sender.bitrateViolations.Add(1)
+	sender.bitrateDropped.Add(uint64(v.Dropped))
+	if v.Started {
+		uuid := ""
+		if room := sender.Room(); room != nil {
+			uuid = room.UUID
+		}
+		logger.LogEvent("BITRATE_EXCEEDED", slog.String("uuid", uuid), slog.String("peer_id", sender.ID),
+			slog.String("session_id", sender.SessionID), slog.Int("bitrate", v.Bitrate), slog.Int("limit", v.Limit))
+	}
+	pc := sender.PC
+	if pc == nil || track == nil {
+		return
+	}
+	ssrc := uint32(track.SSRC())
+	err := pc.WriteRTCP([]rtcp.Packet{
+		&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: float32(v.Limit), SSRCs: []uint32{ssrc}},
+		tmmbr(ssrc, v.Limit),
+	})
+	if err != nil {
+		sender.log().Debug("Failed to send bitrate feedback", "err", err)
+	}
+}
+
+// tmmbr builds an RFC 5104 Temporary Maximum Media Stream Bit Rate Request for
+// ssrc; pion/rtcp has no type for it.
+func tmmbr(ssrc uint32, bitrate int) *rtcp.RawPacket {
+	exp, mantissa := 0, uint32(bitrate)
+	for mantissa >= 1<<17 {
+		mantissa >>= 1
+		exp++
+	}
+	packet := make([]byte, 20)
+	packet[0] = 2<<6 | 3 // V=2, FMT=3
+	packet[1] = byte(rtcp.TypeTransportSpecificFeedback)
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(packet)/4-1))
+	// Packet sender SSRC 0 (the server has no media of its own), media source unused.
+	binary.BigEndian.PutUint32(packet[12:], ssrc)
+	binary.BigEndian.PutUint32(packet[16:], uint32(exp)<<26|mantissa<<9)
+	raw := rtcp.RawPacket(packet)
+	return &raw
+}
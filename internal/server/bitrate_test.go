@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/binary"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBitrateLimiterDropsAfterGrace(t *testing.T) {
+	var l bitrateLimiter
+	const limit = 32000 // 4000 bytes per window
+	start := time.Now()
+	violations, dropped := 0, 0
+	// 100 packets of 100 bytes per second is 80 kbps.
+	for i := range 1000 {
+		forward, v := l.push(100, limit, start.Add(time.Duration(i)*10*time.Millisecond))
+		if v != nil {
+			violations++
+			if v.Started != (violations == 1) {
+				t.Fatalf("violation %d: Started = %v", violations, v.Started)
+			}
+		}
+		if !forward {
+			dropped++
+			if i < int(bitrateGrace/(10*time.Millisecond)) {
+				t.Fatalf("packet %d dropped before the grace period", i)
+			}
+		}
+	}
+	if violations != 9 {
+		t.Fatalf("violations = %d, want 9", violations)
+	}
+	if dropped == 0 {
+		t.Fatal("expected packets over the cap to be dropped after the grace period")
+	}
+
+	// Falling under the cap ends the violation.
+	at := start.Add(10 * time.Second)
+	for i := range 200 {
+		if forward, _ := l.push(10, limit, at.Add(time.Duration(i)*10*time.Millisecond)); !forward && i > 100 {
+			t.Fatalf("packet %d dropped under the cap", i)
+		}
+	}
+	if !l.overSince.IsZero() {
+		t.Fatal("expected violation cleared under the cap")
+	}
+}
+
+func TestTMMBREncoding(t *testing.T) {
+	packet := []byte(*tmmbr(0x01020304, 64000))
+	if len(packet) != 20 || packet[0] != 0x83 || packet[1] != 205 {
+		t.Fatalf("unexpected header % x", packet[:4])
+	}
+	if ssrc := binary.BigEndian.Uint32(packet[12:]); ssrc != 0x01020304 {
+		t.Fatalf("ssrc = %x", ssrc)
+	}
+	fci := binary.BigEndian.Uint32(packet[16:])
+	exp, mantissa := fci>>26, fci>>9&(1<<17-1)
+	if got := mantissa << exp; got != 64000 {
+		t.Fatalf("bitrate = %d, want 64000", got)
+	}
+}
+
+func TestAdminRoomBitrate(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	handler.MaxPublishBitrate = 64
+	room := handler.RoomManager.GetOrCreateRoom("room-a")
+
+	post := func(query string) int {
+		return adminRequest(t, handler, http.MethodPost, "action=room_bitrate&"+query).Code
+	}
+	if code := post("room=missing&kbps=32"); code != http.StatusNotFound {
+		t.Fatalf("missing room: status = %d", code)
+	}
+	if code := post("room=room-a&kbps=-1"); code != http.StatusBadRequest {
+		t.Fatalf("negative cap: status = %d", code)
+	}
+	if code := post("room=room-a&kbps=32"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if got := handler.maxPublishBitrate(room); got != 32000 {
+		t.Fatalf("room cap = %d, want 32000", got)
+	}
+	post("room=room-a&kbps=0")
+	if got := handler.maxPublishBitrate(room); got != 64000 {
+		t.Fatalf("default cap = %d, want 64000", got)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"sigmartc/internal/logger"
+)
+
+var (
+	errPeerNotFound = errors.New("peer not found")
+	errSameRoom     = errors.New("peer is already in that room")
+)
+
+// moveRefusedError refuses a move for the reason a join into the target room
+// would have been refused.
+type moveRefusedError struct {
+	reason  DisconnectReason
+	message string
+}
+
+func (e *moveRefusedError) Error() string {
+	return e.message
+}
+
+// MovePeer migrates a connected peer into another room without a reconnect, for
+// breakout rooms. The peer stops receiving the old room's audio, its own track
+// follows it, and it is subscribed to everything already playing in the new room.
+// Both rooms are notified as if the peer had left and joined. The target room's
+// capacity and the tenant's and room's quotas apply as they do to a join.
+func (h *Handler) MovePeer(peerID, targetUUID string) error {
+	from, peer := h.RoomManager.FindPeer(peerID)
+	if peer == nil {
+		return errPeerNotFound
+	}
+	if from.UUID == targetUUID {
+		return errSameRoom
+	}
+	if err := h.moveQuotaAdmission(from, targetUUID); err != nil {
+		return err
+	}
+	to := h.RoomManager.GetOrCreateRoom(targetUUID)
+	if h.relayOnly(to) && !peer.usesRelay() {
+		return errRelayRequired
+	}
+
+	to.Lock.Lock()
+	if to.full() {
+		to.Lock.Unlock()
+		return &moveRefusedError{DisconnectRoomFull, "Room full"}
+	}
+	to.addPeer(peer)
+	to.usageJoin(peer.ID, time.Now())
+	to.Lock.Unlock()
+	peer.setRoom(to)
+
+	from.Lock.Lock()
+	from.removePeer(peer.ID)
+	if len(from.Peers) == 0 {
+		from.LastEmptyTime = time.Now()
+	}
+	usage := from.usageLeave(peer.ID, time.Now())
+	formerPeers := make([]*Peer, 0, len(from.Peers))
+	for _, other := range from.Peers {
+		formerPeers = append(formerPeers, other)
+	}
+	from.Lock.Unlock()
+	h.RoomManager.recordUsage(usage)
+
+	from.ForwardersMu.Lock()
+	for _, forwarder := range from.Forwarders {
+		forwarder.Unsubscribe(peer.ID)
+	}
+	own := from.Forwarders[peer.ID]
+	delete(from.Forwarders, peer.ID)
+	from.ForwardersMu.Unlock()
+
+	// Remove the outbound tracks between the peer and its former room, as when
+	// a publisher leaves, so a later reunion creates fresh tracks.
+	for _, other := range formerPeers {
+		if own != nil {
+			own.Unsubscribe(other.ID)
+		}
+		other.removeOutTrack(peer.ID)
+		peer.removeOutTrack(other.ID)
+	}
+	from.Broadcast(peer.ID, map[string]any{
+		"type":    "peer_leave",
+		"peer_id": peer.ID,
+	})
+	from.lowerHand(peer.ID)
+	from.dropPrioritySpeaker(peer.ID)
+	from.forgetUnmute(peer.ID)
+
+	if own != nil {
+		own.RemoveTap(egressTapID)
+		own.RemoveTap(hlsTapID)
+		own.RemoveTap(transcriptTapID)
+		to.ForwardersMu.Lock()
+		previous := to.Forwarders[peer.ID]
+		to.Forwarders[peer.ID] = own
+		to.ForwardersMu.Unlock()
+		if previous != nil && previous != own {
+			previous.Stop()
+		}
+		to.attachEgress(peer.ID, own)
+	}
+
+	peer.WriteJSON(map[string]any{
+		"type": "moved",
+		"room": to.localID(),
+		"from": from.localID(),
+	})
+	h.sendRoomState(to, peer)
+
+	if own != nil {
+		to.Lock.RLock()
+		receivers := make([]*Peer, 0, len(to.Peers))
+		for _, receiver := range to.Peers {
+			receivers = append(receivers, receiver)
+		}
+		to.Lock.RUnlock()
+		for _, receiver := range receivers {
+			h.subscribeToForwarder(receiver, peer.ID, own)
+		}
+	}
+	h.addExistingTracks(to, peer)
+
+	peer.note(TimelineMoved, from.UUID+" -> "+to.UUID)
+	logger.LogEvent("PEER_MOVE", slog.String("peer_id", peer.ID), slog.String("session_id", peer.SessionID), slog.String("from", from.UUID), slog.String("to", to.UUID))
+	h.RoomManager.emit(EventPeerMove, map[string]any{"peer_id": peer.ID, "from": from.UUID, "to": to.UUID})
+	return nil
+}
+
+// moveQuotaAdmission applies checkJoin's tenant and quota checks to a move
+// from the room from into targetUUID.
+func (h *Handler) moveQuotaAdmission(from *Room, targetUUID string) error {
+	now := time.Now()
+	tenant := h.RoomManager.Tenants.forRoom(targetUUID)
+	h.RoomManager.Lock.RLock()
+	existing := h.RoomManager.Rooms[targetUUID]
+	h.RoomManager.Lock.RUnlock()
+	if existing == nil && h.RoomManager.roomLimitReached(tenant) {
+		return &moveRefusedError{DisconnectTenantLimit, "No more rooms can be opened right now"}
+	}
+	if h.RoomManager.Quota.blocksJoin(targetUUID, now) {
+		return &moveRefusedError{DisconnectQuotaExceeded, "This room has used its bandwidth for the month"}
+	}
+	if reason, message := h.RoomManager.quotaAdmission(targetUUID, tenant, from.tenant == tenant, now); reason != "" {
+		return &moveRefusedError{reason, message}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestMovePeerNotifiesBothRooms(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "main", "alice")
+	readUntilType(t, alice, "room_state")
+	bob := dialTestWS(t, srv.URL, "main", "bob")
+	state := readUntilType(t, bob, "room_state")
+	bobID, _ := state["self_id"].(string)
+	carol := dialTestWS(t, srv.URL, "breakout", "carol")
+	readUntilType(t, carol, "room_state")
+
+	if err := handler.MovePeer(bobID, "breakout"); err != nil {
+		t.Fatalf("MovePeer failed: %v", err)
+	}
+
+	if msg := readUntilType(t, alice, "peer_leave"); msg["peer_id"] != bobID {
+		t.Fatalf("peer_leave = %v, want %s", msg["peer_id"], bobID)
+	}
+	if msg := readUntilType(t, bob, "moved"); msg["room"] != "breakout" || msg["from"] != "main" {
+		t.Fatalf("moved = %v", msg)
+	}
+	state = readUntilType(t, bob, "room_state")
+	if peers, _ := state["peers"].([]any); len(peers) != 2 {
+		t.Fatalf("room_state peers = %v, want bob and carol", state["peers"])
+	}
+	joined := readUntilType(t, carol, "peer_join")
+	if peer, _ := joined["peer"].(map[string]any); peer["id"] != bobID {
+		t.Fatalf("peer_join = %v, want %s", joined["peer"], bobID)
+	}
+
+	room, peer := handler.RoomManager.FindPeer(bobID)
+	if room == nil || room.UUID != "breakout" || peer.Room() != room {
+		t.Fatal("expected bob to be in the breakout room")
+	}
+	if err := handler.MovePeer(bobID, "breakout"); err != errSameRoom {
+		t.Fatalf("err = %v, want errSameRoom", err)
+	}
+}
+
+func TestAdminMovePeerUnknownPeer(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	rec := adminRequest(t, handler, http.MethodPost, "action=move_peer&peer_id=missing&room=x")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestMovePeerAppliesJoinLimits(t *testing.T) {
+	handler := newTestTenantHandler(t)
+	home := handler.RoomManager.GetOrCreateRoom("main")
+	home.Peers["guest"] = &Peer{ID: "guest"}
+	lobby := handler.RoomManager.GetOrCreateRoom("acme/lobby")
+	lobby.Peers["alice"] = &Peer{ID: "alice"}
+	lobby.Peers["bob"] = &Peer{ID: "bob"}
+
+	var refused *moveRefusedError
+	if err := handler.MovePeer("guest", "acme/lobby"); !errors.As(err, &refused) || refused.reason != DisconnectRoomFull {
+		t.Fatalf("move into full room: err = %v, want %q", err, DisconnectRoomFull)
+	}
+	if err := handler.MovePeer("alice", "acme/breakout"); !errors.As(err, &refused) || refused.reason != DisconnectTenantLimit {
+		t.Fatalf("move past max_rooms: err = %v, want %q", err, DisconnectTenantLimit)
+	}
+	if rec := adminRequest(t, handler, http.MethodPost, "action=move_peer&peer_id=alice&room=acme/breakout"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("admin move past max_rooms: status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	handler.RoomManager.Lock.RLock()
+	_, created := handler.RoomManager.Rooms["acme/breakout"]
+	handler.RoomManager.Lock.RUnlock()
+	if created {
+		t.Fatal("refused move created the target room")
+	}
+	if room, _ := handler.RoomManager.FindPeer("guest"); room != home {
+		t.Fatal("refused move took the peer out of its room")
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/pkg/client"
+)
+
+const (
+	// canaryPackets is how many 20ms frames each check sends (one second of audio).
+	canaryPackets = 50
+	// canaryMinDelivery is the fraction of frames that must arrive for a check to pass.
+	canaryMinDelivery = 0.8
+	// canaryDrain is how long the receiver may lag the last frame sent.
+	canaryDrain = 500 * time.Millisecond
+)
+
+// CanaryResult is the outcome of one canary check.
+type CanaryResult struct {
+	At           time.Time
+	OK           bool
+	JoinLatency  time.Duration // dial to both clients ICE connected
+	MediaLatency time.Duration // first frame sent to first frame received
+	Delivery     float64       // frames received / frames sent
+	Err          string
+}
+
+// Canary periodically joins a health-check room over the public /ws endpoint with
+// two clients, sends RTP from one to the other and records whether it arrived.
+// Unlike a liveness probe it exercises signaling, ICE, DTLS and forwarding.
+type Canary struct {
+	URL      string        // base URL of this server, e.g. http://127.0.0.1:8080
+	Room     string        // room the canary joins; it is an ordinary room
+	Interval time.Duration // time between checks
+	Timeout  time.Duration // limit for one check
+
+	api *webrtc.API
+
+	mu       sync.Mutex
+	last     CanaryResult
+	runs     uint64
+	failures uint64
+}
+
+// NewCanary returns a canary checking the server at url every interval.
+func NewCanary(url, room string, interval time.Duration) (*Canary, error) {
+	api, err := client.NewAPI()
+	if err != nil {
+		return nil, err
+	}
+	return &Canary{URL: url, Room: room, Interval: interval, Timeout: 15 * time.Second, api: api}, nil
+}
+
+// Run checks every Interval until ctx ends. The first check waits one interval so
+// the server is listening by then.
+func (c *Canary) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		func() {
+			defer recoverGoroutine("canary")
+			c.record(c.check(ctx))
+		}()
+	}
+}
+
+func (c *Canary) record(result CanaryResult) {
+	c.mu.Lock()
+	c.last = result
+	c.runs++
+	if !result.OK {
+		c.failures++
+	}
+	c.mu.Unlock()
+	if result.OK {
+		slog.Debug("Canary check passed", "join", result.JoinLatency, "media_latency", result.MediaLatency, "delivery", result.Delivery)
+	} else {
+		slog.Warn("Canary check failed", "event", "CANARY_FAILED", "uuid", c.Room, "err", result.Err, "delivery", result.Delivery)
+	}
+}
+
+// Last returns the latest result and the number of checks run and failed.
+func (c *Canary) Last() (result CanaryResult, runs, failures uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last, c.runs, c.failures
+}
+
+func (c *Canary) check(ctx context.Context) CanaryResult {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	result := CanaryResult{At: time.Now()}
+	fail := func(step string, err error) CanaryResult {
+		result.Err = fmt.Sprintf("%s: %v", step, err)
+		return result
+	}
+
+	receiver, err := client.Connect(ctx, c.URL, c.Room, client.Options{Name: "canary-rx", API: c.api})
+	if err != nil {
+		return fail("receiver join", err)
+	}
+	defer receiver.Close()
+	sender, err := client.Connect(ctx, c.URL, c.Room, client.Options{Name: "canary-tx", API: c.api, Publish: true})
+	if err != nil {
+		return fail("sender join", err)
+	}
+	defer sender.Close()
+	for _, bot := range []*client.Client{receiver, sender} {
+		if err := bot.WaitConnected(ctx); err != nil {
+			return fail("ice", err)
+		}
+	}
+	result.JoinLatency = time.Since(result.At)
+
+	sent := make(chan error, 1)
+	sendStart := time.Now()
+	go func() {
+		sent <- sender.PublishSilence(ctx, canaryPackets)
+	}()
+	if err := receiver.WaitForStreams(ctx, 1); err != nil {
+		return fail("media", errors.New("no RTP received"))
+	}
+	result.MediaLatency = time.Since(sendStart)
+	if err := <-sent; err != nil {
+		return fail("send", err)
+	}
+	select {
+	case <-time.After(canaryDrain):
+	case <-ctx.Done():
+	}
+
+	result.Delivery = float64(receiver.PacketsReceived()) / float64(sender.PacketsSent())
+	if result.Delivery < canaryMinDelivery {
+		return fail("media", fmt.Errorf("delivered %.0f%% of frames", 100*result.Delivery))
+	}
+	result.OK = true
+	return result
+}
+
+// writeMetrics writes the canary's series for /metrics.
+func (c *Canary) writeMetrics(w io.Writer) {
+	last, runs, failures := c.Last()
+	writeMetric(w, "sigmartc_canary_runs_total", "counter", "Canary checks run.", runs)
+	writeMetric(w, "sigmartc_canary_failures_total", "counter", "Canary checks failed.", failures)
+	if runs == 0 {
+		return
+	}
+	up := 0
+	if last.OK {
+		up = 1
+	}
+	writeMetric(w, "sigmartc_canary_up", "gauge", "Whether the latest canary check delivered media end to end.", up)
+	writeMetric(w, "sigmartc_canary_last_run_timestamp_seconds", "gauge", "When the latest canary check started.", last.At.Unix())
+	writeMetric(w, "sigmartc_canary_join_seconds", "gauge", "Time for both canary clients to connect.", last.JoinLatency.Seconds())
+	writeMetric(w, "sigmartc_canary_media_latency_seconds", "gauge", "Time from the first canary frame sent to the first received.", last.MediaLatency.Seconds())
+	writeMetric(w, "sigmartc_canary_delivery_ratio", "gauge", "Fraction of canary frames received.", last.Delivery)
+}
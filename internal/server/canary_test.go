@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestCanaryCheckAndMetrics(t *testing.T) {
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), nil, nil)
+	handler.ICEConfig = &webrtc.Configuration{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", handler.HandleWS)
+	mux.HandleFunc("/metrics", handler.HandleMetrics)
+
+	// Force IPv4 to avoid environments where IPv6 loopback is restricted.
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &httptest.Server{Listener: ln, Config: &http.Server{Handler: mux}}
+	server.Start()
+	defer server.Close()
+
+	canary, err := NewCanary(server.URL, "canary", time.Minute)
+	if err != nil {
+		t.Fatalf("NewCanary: %v", err)
+	}
+	handler.Canary = canary
+	canary.record(canary.check(context.Background()))
+	last, runs, failures := canary.Last()
+	if !last.OK || runs != 1 || failures != 0 {
+		t.Fatalf("check = %+v, runs %d, failures %d", last, runs, failures)
+	}
+	if last.Delivery < canaryMinDelivery || last.MediaLatency <= 0 {
+		t.Fatalf("delivery %v, media latency %v", last.Delivery, last.MediaLatency)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"sigmartc_canary_up 1\n", "sigmartc_canary_runs_total 1\n", "# TYPE sigmartc_rooms gauge\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestCanaryReportsUnreachableServer(t *testing.T) {
+	canary, err := NewCanary("http://127.0.0.1:1", "canary", time.Minute)
+	if err != nil {
+		t.Fatalf("NewCanary: %v", err)
+	}
+	canary.Timeout = 2 * time.Second
+	canary.record(canary.check(context.Background()))
+	last, runs, failures := canary.Last()
+	if last.OK || runs != 1 || failures != 1 || !strings.HasPrefix(last.Err, "receiver join") {
+		t.Fatalf("check = %+v, runs %d, failures %d", last, runs, failures)
+	}
+}
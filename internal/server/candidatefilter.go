@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// CandidateFilter limits which of the server's own ICE candidates reach
+// clients, both trickled and inside the offers and answers it sends. The zero
+// value sends every candidate. Filtering hides addresses from clients; pion
+// still gathers them and answers connectivity checks on them.
+type CandidateFilter struct {
+	// Types, when non-empty, are the only candidate types sent, e.g. just
+	// relay for a server reachable only through TURN.
+	Types []webrtc.ICECandidateType
+	// HidePrivate drops candidates on private, loopback, link-local and
+	// unspecified addresses, so internal IPs never leave the server.
+	HidePrivate bool
+}
+
+// ParseCandidateTypes parses a comma-separated list of host, srflx, prflx and
+// relay. Empty means every type.
+func ParseCandidateTypes(raw string) ([]webrtc.ICECandidateType, error) {
+	var types []webrtc.ICECandidateType
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		typ, err := webrtc.NewICECandidateType(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid candidate type %q", name)
+		}
+		types = append(types, typ)
+	}
+	return types, nil
+}
+
+// Enabled reports whether the filter drops anything.
+func (f CandidateFilter) Enabled() bool {
+	return len(f.Types) > 0 || f.HidePrivate
+}
+
+// allows reports whether a candidate of typ on address may be sent.
+func (f CandidateFilter) allows(typ webrtc.ICECandidateType, address string) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, typ) {
+		return false
+	}
+	if f.HidePrivate {
+		// mDNS hostnames already hide the address and pass through.
+		if ip := net.ParseIP(address); ip != nil && isInternalIP(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func isInternalIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()
+}
+
+// allowsCandidate reports whether a gathered candidate may be trickled.
+func (f CandidateFilter) allowsCandidate(c *webrtc.ICECandidate) bool {
+	return f.allows(c.Typ, c.Address)
+}
+
+// filterSDP drops the a=candidate lines the filter does not allow. Lines it
+// cannot parse are kept: pion wrote them and the client will judge them.
+func (f CandidateFilter) filterSDP(raw string) string {
+	if !f.Enabled() || !strings.Contains(raw, "a=candidate:") {
+		return raw
+	}
+	lines := strings.SplitAfter(raw, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if value, ok := strings.CutPrefix(line, "a=candidate:"); ok {
+			// foundation component transport priority address port "typ" type ...
+			fields := strings.Fields(value)
+			if len(fields) >= 8 && fields[6] == "typ" {
+				if typ, err := webrtc.NewICECandidateType(fields[7]); err == nil && !f.allows(typ, fields[4]) {
+					continue
+				}
+			}
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "")
+}
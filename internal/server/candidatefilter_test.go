@@ -0,0 +1,76 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const candidateSDP = "v=0\r\n" +
+	"o=- 1 2 IN IP4 0.0.0.0\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"a=candidate:1 1 udp 2130706431 10.0.0.5 50000 typ host\r\n" +
+	"a=candidate:2 1 udp 2130706431 203.0.113.7 50000 typ host\r\n" +
+	"a=candidate:3 1 udp 1694498815 203.0.113.7 50000 typ srflx raddr 10.0.0.5 rport 50000\r\n" +
+	"a=candidate:4 1 udp 16777215 198.51.100.9 61000 typ relay raddr 203.0.113.7 rport 50000\r\n" +
+	"a=end-of-candidates\r\n"
+
+func TestParseCandidateTypes(t *testing.T) {
+	types, err := ParseCandidateTypes(" relay, srflx ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0] != webrtc.ICECandidateTypeRelay || types[1] != webrtc.ICECandidateTypeSrflx {
+		t.Fatalf("types = %v", types)
+	}
+	if types, err := ParseCandidateTypes(""); err != nil || types != nil {
+		t.Fatalf("empty = %v, %v", types, err)
+	}
+	if _, err := ParseCandidateTypes("host,stun"); err == nil {
+		t.Fatal("unknown type accepted")
+	}
+}
+
+func TestCandidateFilterSDP(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter CandidateFilter
+		want   []string
+	}{
+		{"all", CandidateFilter{}, []string{"a=candidate:1 ", "a=candidate:2 ", "a=candidate:3 ", "a=candidate:4 "}},
+		{"hide private", CandidateFilter{HidePrivate: true}, []string{"a=candidate:2 ", "a=candidate:3 ", "a=candidate:4 "}},
+		{"relay only", CandidateFilter{Types: []webrtc.ICECandidateType{webrtc.ICECandidateTypeRelay}}, []string{"a=candidate:4 "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.filterSDP(candidateSDP)
+			if n := strings.Count(got, "a=candidate:"); n != len(tt.want) {
+				t.Fatalf("%d candidates left, want %d:\n%s", n, len(tt.want), got)
+			}
+			for _, prefix := range tt.want {
+				if !strings.Contains(got, prefix) {
+					t.Fatalf("missing %q:\n%s", prefix, got)
+				}
+			}
+			if !strings.HasSuffix(got, "a=end-of-candidates\r\n") {
+				t.Fatalf("SDP mangled:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestCandidateFilterTrickle(t *testing.T) {
+	filter := CandidateFilter{HidePrivate: true}
+	if filter.allowsCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "192.168.1.4"}) {
+		t.Fatal("private host candidate allowed")
+	}
+	if filter.allowsCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "fe80::1"}) {
+		t.Fatal("link-local host candidate allowed")
+	}
+	if !filter.allowsCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "2001:db8::1"}) {
+		t.Fatal("public host candidate dropped")
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// maxCandidatesPerCycle caps the candidates a peer may send for one offer
+	// or answer; a bundled audio transport gathers far fewer.
+	maxCandidatesPerCycle = 64
+	// candidatesPerMinute is the sustained candidate rate, with a burst of as
+	// many, bounding clients that renegotiate in a loop.
+	candidatesPerMinute = 200
+	// maxPendingCandidates caps the candidates queued before the remote
+	// description arrives, and pendingCandidateTTL drops queued ones it never
+	// arrived for.
+	maxPendingCandidates = maxCandidatesPerCycle
+	pendingCandidateTTL  = 30 * time.Second
+)
+
+var (
+	// candidatesDropped counts candidate messages over the limits, for /metrics.
+	candidatesDropped atomic.Uint64
+	// pendingCandidatesDropped counts queued candidates dropped over
+	// maxPendingCandidates or after pendingCandidateTTL.
+	pendingCandidatesDropped atomic.Uint64
+)
+
+// candidateLimit caps one peer's candidate messages per negotiation cycle and
+// per minute, so a misbehaving client cannot make the server run
+// AddICECandidate (and log its failures) thousands of times.
+type candidateLimit struct {
+	bucket tokenBucket
+	// cycle counts candidates since the client's last offer or answer.
+	cycle  atomic.Int32
+	warned atomic.Bool
+	// queueFull is set once the first candidate is dropped over
+	// maxPendingCandidates, which is logged once per peer.
+	queueFull atomic.Bool
+}
+
+// pendingCandidate is a candidate waiting for the remote description.
+type pendingCandidate struct {
+	init   webrtc.ICECandidateInit
+	queued time.Time
+}
+
+// allowCandidate reports whether the peer's next candidate may be processed.
+// The first one dropped is logged; later ones only count.
+func (p *Peer) allowCandidate(now time.Time) bool {
+	l := &p.candidateLimit
+	if l.cycle.Add(1) <= maxCandidatesPerCycle && l.bucket.allow(now, candidatesPerMinute/60.0, candidatesPerMinute) {
+		return true
+	}
+	candidatesDropped.Add(1)
+	if !l.warned.Swap(true) {
+		p.log().Warn("Dropping ICE candidates over the limit", "per_cycle", maxCandidatesPerCycle, "per_minute", candidatesPerMinute)
+	}
+	return false
+}
+
+// startCandidateCycle resets the per-cycle count when the client sends an
+// offer or answer.
+func (p *Peer) startCandidateCycle() {
+	p.candidateLimit.cycle.Store(0)
+}
+
+// queueCandidate holds a candidate until the remote description is set. Queued
+// candidates older than pendingCandidateTTL are dropped, and so is the new one
+// when maxPendingCandidates are still queued.
+func (p *Peer) queueCandidate(candidate webrtc.ICECandidateInit, now time.Time) {
+	p.PendingCandidatesMu.Lock()
+	defer p.PendingCandidatesMu.Unlock()
+	p.expirePendingCandidates(now)
+	if len(p.PendingCandidates) >= maxPendingCandidates {
+		pendingCandidatesDropped.Add(1)
+		if !p.candidateLimit.queueFull.Swap(true) {
+			p.log().Warn("Dropping ICE candidates, too many waiting for a remote description", "max", maxPendingCandidates)
+		}
+		return
+	}
+	p.PendingCandidates = append(p.PendingCandidates, pendingCandidate{init: candidate, queued: now})
+}
+
+// takePendingCandidates empties the queue and returns the candidates that
+// have not expired.
+func (p *Peer) takePendingCandidates(now time.Time) []webrtc.ICECandidateInit {
+	p.PendingCandidatesMu.Lock()
+	defer p.PendingCandidatesMu.Unlock()
+	p.expirePendingCandidates(now)
+	candidates := make([]webrtc.ICECandidateInit, len(p.PendingCandidates))
+	for i, pending := range p.PendingCandidates {
+		candidates[i] = pending.init
+	}
+	p.PendingCandidates = nil
+	return candidates
+}
+
+// expirePendingCandidates drops queued candidates older than
+// pendingCandidateTTL. The caller must hold PendingCandidatesMu.
+func (p *Peer) expirePendingCandidates(now time.Time) {
+	n := 0
+	for n < len(p.PendingCandidates) && now.Sub(p.PendingCandidates[n].queued) > pendingCandidateTTL {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	pendingCandidatesDropped.Add(uint64(n))
+	p.log().Debug("Expired ICE candidates waiting for a remote description", "count", n)
+	p.PendingCandidates = append([]pendingCandidate(nil), p.PendingCandidates[n:]...)
+}
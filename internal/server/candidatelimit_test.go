@@ -0,0 +1,70 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestAllowCandidateLimits(t *testing.T) {
+	peer := &Peer{ID: "p1"}
+	now := time.Now()
+	dropped := candidatesDropped.Load()
+
+	for i := 0; i < maxCandidatesPerCycle; i++ {
+		if !peer.allowCandidate(now) {
+			t.Fatalf("candidate %d dropped within the cycle cap", i)
+		}
+	}
+	if peer.allowCandidate(now) {
+		t.Fatal("candidate over the cycle cap allowed")
+	}
+
+	// A new offer or answer starts a new cycle, but the per-minute budget is shared.
+	allowed := maxCandidatesPerCycle
+	for cycle := 0; cycle < 5; cycle++ {
+		peer.startCandidateCycle()
+		for i := 0; i < maxCandidatesPerCycle; i++ {
+			if peer.allowCandidate(now) {
+				allowed++
+			}
+		}
+	}
+	if allowed != candidatesPerMinute {
+		t.Fatalf("allowed %d candidates in a minute, want %d", allowed, candidatesPerMinute)
+	}
+	peer.startCandidateCycle()
+	if !peer.allowCandidate(now.Add(time.Second)) {
+		t.Fatal("budget not refilled after a second")
+	}
+	if got := candidatesDropped.Load() - dropped; got != 1+5*maxCandidatesPerCycle-(candidatesPerMinute-maxCandidatesPerCycle) {
+		t.Fatalf("dropped counter grew by %d", got)
+	}
+}
+
+func TestPendingCandidatesBoundedAndExpired(t *testing.T) {
+	peer := &Peer{ID: "p1"}
+	now := time.Now()
+	dropped := pendingCandidatesDropped.Load()
+
+	for i := 0; i < maxPendingCandidates+3; i++ {
+		peer.queueCandidate(webrtc.ICECandidateInit{Candidate: "candidate:1 1 udp 1 192.0.2.1 5000 typ host"}, now)
+	}
+	if len(peer.PendingCandidates) != maxPendingCandidates {
+		t.Fatalf("queued %d candidates, want the cap %d", len(peer.PendingCandidates), maxPendingCandidates)
+	}
+
+	// Queued candidates expire; ones queued since survive.
+	later := now.Add(pendingCandidateTTL + time.Second)
+	peer.queueCandidate(webrtc.ICECandidateInit{Candidate: "fresh"}, later)
+	if got := peer.takePendingCandidates(later); len(got) != 1 || got[0].Candidate != "fresh" {
+		t.Fatalf("taken = %+v, want only the fresh candidate", got)
+	}
+	if len(peer.PendingCandidates) != 0 {
+		t.Fatal("queue not emptied")
+	}
+	if got := pendingCandidatesDropped.Load() - dropped; got != 3+maxPendingCandidates {
+		t.Fatalf("dropped counter grew by %d", got)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/pkg/protocol"
+)
+
+// protocolVersion is the signaling protocol version reported in hello_ack.
+const protocolVersion = protocol.Version
+
+// Capabilities is what a client declared in its hello message.
+type Capabilities = protocol.Capabilities
+
+// Capabilities returns what the peer declared in hello, or nil if it has not sent one.
+func (p *Peer) Capabilities() *Capabilities {
+	return p.capabilities.Load()
+}
+
+// accepts reports whether the peer can receive a track with the given codec. Peers
+// that have not sent hello are assumed to accept everything, as before.
+func (p *Peer) accepts(codec webrtc.RTPCodecCapability) bool {
+	caps := p.Capabilities()
+	if caps == nil {
+		return true
+	}
+	if strings.HasPrefix(strings.ToLower(codec.MimeType), "video/") && !caps.Video {
+		return false
+	}
+	if len(caps.Codecs) == 0 {
+		return true
+	}
+	for _, mimeType := range caps.Codecs {
+		if strings.EqualFold(mimeType, codec.MimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleHello records the peer's capabilities, drops subscriptions it cannot use,
+// and tells the rest of the room.
+func (h *Handler) handleHello(room *Room, peer *Peer, msg map[string]any) {
+	raw, err := json.Marshal(msg["capabilities"])
+	if err != nil {
+		return
+	}
+	caps := &Capabilities{}
+	if err := json.Unmarshal(raw, caps); err != nil {
+		peer.log().Warn("Invalid hello capabilities", "err", err)
+		return
+	}
+	peer.capabilities.Store(caps)
+
+	// hello usually arrives after the peer was subscribed to the room's existing tracks.
+	room.ForwardersMu.RLock()
+	for _, forwarder := range room.Forwarders {
+		if !peer.accepts(forwarder.Codec()) {
+			forwarder.Unsubscribe(peer.ID)
+		}
+	}
+	room.ForwardersMu.RUnlock()
+
+	// The ack itself still uses the old encoding; everything after it switches.
+	encoding := "json"
+	// Long-poll sessions carry JSON text only.
+	if _, polled := peer.Conn.(*pollConn); msg["encoding"] == encodingMsgpack && !polled {
+		encoding = encodingMsgpack
+	}
+	peer.WriteJSON(map[string]any{
+		"type":             "hello_ack",
+		"protocol_version": protocolVersion,
+		"encoding":         encoding,
+	})
+	peer.msgpack.Store(encoding == encodingMsgpack)
+	room.Broadcast(peer.ID, map[string]any{
+		"type": "peer_update",
+		"peer": map[string]any{"id": peer.ID, "capabilities": caps},
+	})
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestPeerAcceptsDeclaredCodecs(t *testing.T) {
+	peer := &Peer{}
+	video := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}
+	opus := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}
+	if !peer.accepts(video) {
+		t.Fatal("peers without hello should accept everything")
+	}
+
+	peer.capabilities.Store(&Capabilities{Codecs: []string{"audio/OPUS", "video/VP8"}})
+	if !peer.accepts(opus) {
+		t.Fatal("expected declared codec to be accepted, case-insensitively")
+	}
+	if peer.accepts(video) {
+		t.Fatal("expected video to be refused when video is not declared")
+	}
+	if peer.accepts(webrtc.RTPCodecCapability{MimeType: mimeTypeRED}) {
+		t.Fatal("expected undeclared codec to be refused")
+	}
+}
+
+func TestHelloIsAcknowledgedAndShared(t *testing.T) {
+	_, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "room-hello", "alice")
+	state := readUntilType(t, alice, "room_state")
+	aliceID, _ := state["self_id"].(string)
+
+	hello := map[string]any{
+		"type": "hello",
+		"capabilities": map[string]any{
+			"protocol_version": 1,
+			"codecs":           []string{"audio/opus"},
+			"data_channels":    true,
+		},
+	}
+	if err := alice.WriteJSON(hello); err != nil {
+		t.Fatalf("failed to send hello: %v", err)
+	}
+	ack := readUntilType(t, alice, "hello_ack")
+	if ack["protocol_version"] != float64(protocolVersion) {
+		t.Fatalf("protocol_version = %v, want %d", ack["protocol_version"], protocolVersion)
+	}
+
+	bob := dialTestWS(t, srv.URL, "room-hello", "bob")
+	state = readUntilType(t, bob, "room_state")
+	peers, _ := state["peers"].([]any)
+	for _, raw := range peers {
+		info, _ := raw.(map[string]any)
+		if info["id"] != aliceID {
+			continue
+		}
+		caps, _ := info["capabilities"].(map[string]any)
+		if codecs, _ := caps["codecs"].([]any); len(codecs) != 1 || codecs[0] != "audio/opus" {
+			t.Fatalf("alice capabilities = %v", info["capabilities"])
+		}
+		return
+	}
+	t.Fatalf("alice missing from room_state: %v", peers)
+}
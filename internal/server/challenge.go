@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// powChallengeTTL is how long a minted proof-of-work challenge may be redeemed.
+	powChallengeTTL = 2 * time.Minute
+	captchaTimeout  = 5 * time.Second
+)
+
+var errChallengeFailed = errors.New("challenge failed")
+
+// JoinChallenge gates WebSocket joins behind an anti-abuse check that is costly
+// for bots flooding the server with rooms and peers but cheap for one person.
+type JoinChallenge interface {
+	// Verify checks the proof attached to a /ws request from ip.
+	Verify(ctx context.Context, r *http.Request, ip string) error
+}
+
+// ProofOfWork is a JoinChallenge that makes each join solve a hash puzzle. Clients
+// fetch a challenge from /api/challenge and look for a nonce such that
+// SHA-256(challenge + ":" + nonce) starts with Difficulty zero bits, then join
+// with pow_challenge and pow_nonce. Challenges are bound to the client IP, expire
+// after powChallengeTTL and can be redeemed once.
+type ProofOfWork struct {
+	Difficulty int
+
+	secret []byte
+	mu     sync.Mutex
+	// redeemed maps spent challenges to their expiry so they cannot be replayed.
+	redeemed map[string]time.Time
+}
+
+// NewProofOfWork creates a challenge of difficulty leading zero bits.
+func NewProofOfWork(difficulty int) *ProofOfWork {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return &ProofOfWork{Difficulty: difficulty, secret: secret, redeemed: make(map[string]time.Time)}
+}
+
+// Mint returns a new challenge for ip and when it expires.
+func (p *ProofOfWork) Mint(ip string, now time.Time) (string, time.Time) {
+	expires := now.Add(powChallengeTTL)
+	nonce := make([]byte, 12)
+	_, _ = rand.Read(nonce)
+	payload := strconv.FormatInt(expires.Unix(), 10) + "." + hex.EncodeToString(nonce)
+	return payload + "." + p.sign(payload, ip), expires
+}
+
+func (p *ProofOfWork) sign(payload, ip string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(payload + "|" + ip))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Verify implements JoinChallenge.
+func (p *ProofOfWork) Verify(_ context.Context, r *http.Request, ip string) error {
+	challenge := r.URL.Query().Get("pow_challenge")
+	nonce := r.URL.Query().Get("pow_nonce")
+	if challenge == "" || nonce == "" || len(nonce) > 64 {
+		return fmt.Errorf("%w: missing proof of work", errChallengeFailed)
+	}
+	payload, sig, ok := cutLast(challenge, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(p.sign(payload, ip))) {
+		return fmt.Errorf("%w: unknown challenge", errChallengeFailed)
+	}
+	expiresStr, _, _ := strings.Cut(payload, ".")
+	expiresUnix, err := strconv.ParseInt(expiresStr, 10, 64)
+	now := time.Now()
+	if err != nil || now.After(time.Unix(expiresUnix, 0)) {
+		return fmt.Errorf("%w: challenge expired", errChallengeFailed)
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(challenge+":"+nonce))) < p.Difficulty {
+		return fmt.Errorf("%w: insufficient work", errChallengeFailed)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for spent, expires := range p.redeemed {
+		if now.After(expires) {
+			delete(p.redeemed, spent)
+		}
+	}
+	if _, spent := p.redeemed[challenge]; spent {
+		return fmt.Errorf("%w: challenge already used", errChallengeFailed)
+	}
+	p.redeemed[challenge] = time.Unix(expiresUnix, 0)
+	return nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// CaptchaVerifier is a JoinChallenge backed by a CAPTCHA provider's siteverify
+// endpoint (hCaptcha, reCAPTCHA and Turnstile share the same form API). The
+// client joins with the widget's response token in the captcha parameter.
+type CaptchaVerifier struct {
+	VerifyURL string
+	Secret    string
+	Client    *http.Client
+}
+
+// NewCaptchaVerifier creates a verifier posting to verifyURL with secret.
+func NewCaptchaVerifier(verifyURL, secret string) *CaptchaVerifier {
+	return &CaptchaVerifier{VerifyURL: verifyURL, Secret: secret, Client: &http.Client{Timeout: captchaTimeout}}
+}
+
+// Verify implements JoinChallenge.
+func (c *CaptchaVerifier) Verify(ctx context.Context, r *http.Request, ip string) error {
+	token := r.URL.Query().Get("captcha")
+	if token == "" {
+		return fmt.Errorf("%w: missing captcha", errChallengeFailed)
+	}
+	form := url.Values{"secret": {c.Secret}, "response": {token}, "remoteip": {ip}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.VerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("captcha verify: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("captcha verify: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: captcha rejected", errChallengeFailed)
+	}
+	return nil
+}
+
+// HandleChallenge serves GET /api/challenge. It reports which challenge joins
+// need and, for proof of work, mints one for the caller. It is 404 when joins
+// are not challenged.
+func (h *Handler) HandleChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	switch challenge := h.Challenge.(type) {
+	case *ProofOfWork:
+		token, expires := challenge.Mint(h.clientIP(r), time.Now())
+		json.NewEncoder(w).Encode(map[string]any{
+			"type":       "pow",
+			"challenge":  token,
+			"difficulty": challenge.Difficulty,
+			"expires_at": expires.UTC(),
+		})
+	case nil:
+		http.NotFound(w, r)
+	default:
+		json.NewEncoder(w).Encode(map[string]any{"type": "captcha"})
+	}
+}
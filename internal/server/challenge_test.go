@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func solvePoW(challenge string, difficulty int) string {
+	for nonce := 0; ; nonce++ {
+		n := strconv.Itoa(nonce)
+		if leadingZeroBits(sha256.Sum256([]byte(challenge+":"+n))) >= difficulty {
+			return n
+		}
+	}
+}
+
+func powRequest(challenge, nonce string) *http.Request {
+	q := url.Values{"room": {"a"}, "name": {"bob"}, "pow_challenge": {challenge}, "pow_nonce": {nonce}}
+	return httptest.NewRequest(http.MethodGet, "/ws?"+q.Encode(), nil)
+}
+
+func TestProofOfWorkVerify(t *testing.T) {
+	pow := NewProofOfWork(8)
+	challenge, _ := pow.Mint("192.0.2.1", time.Now())
+	nonce := solvePoW(challenge, 8)
+	ctx := context.Background()
+
+	if err := pow.Verify(ctx, powRequest(challenge, nonce), "192.0.2.2"); !errors.Is(err, errChallengeFailed) {
+		t.Fatalf("challenge minted for another IP: err = %v", err)
+	}
+	if err := pow.Verify(ctx, powRequest(challenge, nonce), "192.0.2.1"); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+	if err := pow.Verify(ctx, powRequest(challenge, nonce), "192.0.2.1"); !errors.Is(err, errChallengeFailed) {
+		t.Fatalf("replayed proof: err = %v", err)
+	}
+
+	hard := NewProofOfWork(64)
+	challenge, _ = hard.Mint("192.0.2.1", time.Now())
+	if err := hard.Verify(ctx, powRequest(challenge, "0"), "192.0.2.1"); !errors.Is(err, errChallengeFailed) {
+		t.Fatalf("insufficient work: err = %v", err)
+	}
+
+	challenge, _ = pow.Mint("192.0.2.1", time.Now().Add(-2*powChallengeTTL))
+	if err := pow.Verify(ctx, powRequest(challenge, solvePoW(challenge, 8)), "192.0.2.1"); !errors.Is(err, errChallengeFailed) {
+		t.Fatalf("expired challenge: err = %v", err)
+	}
+}
+
+func TestCaptchaVerifier(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		ok := r.PostForm.Get("secret") == "s3cret" && r.PostForm.Get("response") == "good" && r.PostForm.Get("remoteip") == "192.0.2.1"
+		json.NewEncoder(w).Encode(map[string]bool{"success": ok})
+	}))
+	defer provider.Close()
+	captcha := NewCaptchaVerifier(provider.URL, "s3cret")
+
+	for token, want := range map[string]bool{"good": true, "bad": false, "": false} {
+		req := httptest.NewRequest(http.MethodGet, "/ws?room=a&name=bob&captcha="+token, nil)
+		if err := captcha.Verify(context.Background(), req, "192.0.2.1"); (err == nil) != want {
+			t.Errorf("token %q: err = %v", token, err)
+		}
+	}
+}
+
+func TestChallengeGatesJoin(t *testing.T) {
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), nil, nil)
+
+	rec := httptest.NewRecorder()
+	handler.HandleChallenge(rec, httptest.NewRequest(http.MethodGet, "/api/challenge", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("challenge endpoint without a challenge: status = %d", rec.Code)
+	}
+
+	handler.Challenge = NewProofOfWork(4)
+	rec = httptest.NewRecorder()
+	handler.HandleChallenge(rec, httptest.NewRequest(http.MethodGet, "/api/challenge", nil))
+	var minted struct {
+		Type       string `json:"type"`
+		Challenge  string `json:"challenge"`
+		Difficulty int    `json:"difficulty"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&minted); err != nil || minted.Type != "pow" || minted.Difficulty != 4 {
+		t.Fatalf("minted = %+v, err = %v", minted, err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleWS(rec, httptest.NewRequest(http.MethodGet, "/ws?room=a&name=bob", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("join without proof: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	var body map[string]string
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body["error"] != string(DisconnectChallengeFailed) {
+		t.Fatalf("error = %q", body["error"])
+	}
+}
+
+func TestChallengeKeptWhenJoinRefused(t *testing.T) {
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), nil, nil)
+	pow := NewProofOfWork(4)
+	handler.Challenge = pow
+	room := rm.GetOrCreateRoom("a")
+	room.Locked = true
+
+	challenge, _ := pow.Mint("192.0.2.1", time.Now())
+	req := powRequest(challenge, solvePoW(challenge, 4))
+	if _, reason, _ := handler.checkJoin(req); reason != DisconnectRoomLocked {
+		t.Fatalf("reason = %q, want %q", reason, DisconnectRoomLocked)
+	}
+
+	room.Locked = false
+	if _, reason, _ := handler.checkJoin(req); reason != "" {
+		t.Fatalf("retry after refusal: reason = %q, want the proof to still be valid", reason)
+	}
+	if _, reason, _ := handler.checkJoin(req); reason != DisconnectChallengeFailed {
+		t.Fatalf("reused proof: reason = %q, want %q", reason, DisconnectChallengeFailed)
+	}
+}
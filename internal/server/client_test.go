@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/pkg/client"
+)
+
+func newTestAPI(t *testing.T) *webrtc.API {
+	t.Helper()
+	api, err := client.NewAPI()
+	if err != nil {
+		t.Fatalf("failed to register codecs: %v", err)
+	}
+	return api
+}
+
+func newE2EClient(t *testing.T, serverURL, room, name string, api *webrtc.API, publish bool) (*client.Client, error) {
+	return client.Connect(context.Background(), serverURL, room, client.Options{
+		Name:    name,
+		API:     api,
+		Publish: publish,
+		Logf:    t.Logf,
+	})
+}
+
+func buildWSURL(serverURL, room, name string) (string, error) {
+	return client.WebSocketURL(serverURL, room, name)
+}
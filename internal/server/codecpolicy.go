@@ -0,0 +1,244 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+// Codec policy: an admin can restrict a room to some codecs and Opus
+// parameters before anyone joins (action=room_codecs), e.g. Opus mono at
+// 24 kbps for a low-bandwidth room. The server answers publisher offers with
+// the allowed codecs only and asks the encoder for the allowed channels and
+// bitrate through the Opus fmtp. Offers without an allowed codec are refused,
+// tracks in other codecs are not forwarded, and the bitrate cap (see
+// bitrate.go) drops audio from encoders that ignore the fmtp.
+
+// policyHeaderKbps is what RTP headers and extensions add to a codec's bitrate
+// at 50 packets a second.
+const policyHeaderKbps = 10
+
+var (
+	errInvalidCodecPolicy = errors.New("codec policy needs known codecs, channels of 1 or 2 and max_kbps between 6 and 510")
+	errNoPolicyCodec      = errors.New("no codec allowed by the room was negotiated")
+)
+
+// policyCodecs are the audio codecs the media engine registers (see
+// NewMediaEngine) in its order, which a policy may allow.
+var policyCodecs = []webrtc.RTPCodecCapability{
+	{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: "minptime=10;useinbandfec=1"},
+	{MimeType: webrtc.MimeTypeG722, ClockRate: 8000},
+	{MimeType: webrtc.MimeTypePCMU, ClockRate: 8000},
+	{MimeType: webrtc.MimeTypePCMA, ClockRate: 8000},
+	{MimeType: mimeTypeRED, ClockRate: 48000, Channels: 2, SDPFmtpLine: "111/111"},
+}
+
+// codecPolicyPresets are named policies for action=room_codecs&preset=.
+var codecPolicyPresets = map[string]CodecPolicy{
+	"low_bandwidth": {Codecs: []string{webrtc.MimeTypeOpus}, Channels: 1, MaxBitrateKbps: 24},
+}
+
+// CodecPolicy restricts what publishers in a room may send.
+type CodecPolicy struct {
+	// Codecs lists the allowed MIME types; empty allows every codec.
+	Codecs []string `json:"codecs,omitempty"`
+	// Channels caps Opus channels; 1 asks for mono. Zero leaves the encoder's choice.
+	Channels int `json:"channels,omitempty"`
+	// MaxBitrateKbps caps Opus's average bitrate. Zero leaves it uncapped.
+	MaxBitrateKbps int `json:"max_kbps,omitempty"`
+}
+
+// ParseCodecPolicy reads a policy from preset or codecs, channels and max_kbps
+// query parameters. It returns nil when the query sets no restriction.
+func ParseCodecPolicy(query url.Values) (*CodecPolicy, error) {
+	if name := query.Get("preset"); name != "" {
+		preset, ok := codecPolicyPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown codec preset %q", name)
+		}
+		return &preset, nil
+	}
+	policy := &CodecPolicy{}
+	for _, name := range strings.Split(query.Get("codecs"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !strings.Contains(name, "/") {
+			name = "audio/" + name
+		}
+		codec, ok := policyCodec(name)
+		if !ok {
+			return nil, errInvalidCodecPolicy
+		}
+		policy.Codecs = append(policy.Codecs, codec.MimeType)
+	}
+	var err error
+	if value := query.Get("channels"); value != "" {
+		if policy.Channels, err = strconv.Atoi(value); err != nil || policy.Channels < 1 || policy.Channels > 2 {
+			return nil, errInvalidCodecPolicy
+		}
+	}
+	if value := query.Get("max_kbps"); value != "" {
+		if policy.MaxBitrateKbps, err = strconv.Atoi(value); err != nil || policy.MaxBitrateKbps < 6 || policy.MaxBitrateKbps > 510 {
+			return nil, errInvalidCodecPolicy
+		}
+	}
+	if len(policy.Codecs) == 0 && policy.Channels == 0 && policy.MaxBitrateKbps == 0 {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// policyCodec looks up a codec the media engine registers by MIME type.
+func policyCodec(mimeType string) (webrtc.RTPCodecCapability, bool) {
+	for _, codec := range policyCodecs {
+		if strings.EqualFold(codec.MimeType, mimeType) {
+			return codec, true
+		}
+	}
+	return webrtc.RTPCodecCapability{}, false
+}
+
+// allows reports whether publishers may send mimeType. A nil policy allows
+// everything.
+func (p *CodecPolicy) allows(mimeType string) bool {
+	if p == nil || len(p.Codecs) == 0 {
+		return true
+	}
+	for _, allowed := range p.Codecs {
+		if strings.EqualFold(allowed, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the policy for the audit log.
+func (p *CodecPolicy) String() string {
+	if p == nil {
+		return "unrestricted"
+	}
+	codecs := "any"
+	if len(p.Codecs) > 0 {
+		codecs = strings.Join(p.Codecs, ",")
+	}
+	return fmt.Sprintf("codecs=%s channels=%d max_kbps=%d", codecs, p.Channels, p.MaxBitrateKbps)
+}
+
+// capabilities returns the allowed codecs as the server answers them, with the
+// policy's channels and bitrate in the Opus fmtp.
+func (p *CodecPolicy) capabilities() []webrtc.RTPCodecParameters {
+	var codecs []webrtc.RTPCodecParameters
+	for _, codec := range policyCodecs {
+		if !p.allows(codec.MimeType) {
+			continue
+		}
+		if codec.MimeType == webrtc.MimeTypeOpus {
+			if p.Channels == 1 {
+				codec.SDPFmtpLine += ";stereo=0;sprop-stereo=0"
+			}
+			if p.MaxBitrateKbps > 0 {
+				codec.SDPFmtpLine += ";maxaveragebitrate=" + strconv.Itoa(p.MaxBitrateKbps*1000)
+			}
+		}
+		codecs = append(codecs, webrtc.RTPCodecParameters{RTPCodecCapability: codec})
+	}
+	return codecs
+}
+
+// checkOffer refuses offers with an audio section the client would send on
+// without an allowed codec.
+func (p *CodecPolicy) checkOffer(raw string) error {
+	if p == nil || len(p.Codecs) == 0 {
+		return nil
+	}
+	var parsed sdp.SessionDescription
+	if err := parsed.UnmarshalString(raw); err != nil {
+		return err
+	}
+	for _, media := range parsed.MediaDescriptions {
+		if media.MediaName.Media != "audio" || media.MediaName.Port.Value == 0 {
+			continue
+		}
+		if _, recvonly := media.Attribute(webrtc.RTPTransceiverDirectionRecvonly.String()); recvonly {
+			continue
+		}
+		if _, inactive := media.Attribute(webrtc.RTPTransceiverDirectionInactive.String()); inactive {
+			continue
+		}
+		allowed := false
+		for _, format := range media.MediaName.Formats {
+			payloadType, err := strconv.ParseUint(format, 10, 8)
+			if err != nil {
+				continue
+			}
+			codec, err := parsed.GetCodecForPayloadType(uint8(payloadType))
+			if err == nil && p.allows("audio/"+codec.Name) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("offer has no codec this room allows (%s)", strings.Join(p.Codecs, ", "))
+		}
+	}
+	return nil
+}
+
+// applyCodecPolicy limits the audio transceivers of pc to what policy allows,
+// so the next answer or offer carries only those codecs.
+func applyCodecPolicy(pc *webrtc.PeerConnection, policy *CodecPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	codecs := policy.capabilities()
+	for _, transceiver := range pc.GetTransceivers() {
+		if transceiver.Kind() != webrtc.RTPCodecTypeAudio {
+			continue
+		}
+		// SetCodecPreferences refuses codecs the first offer did not carry, so
+		// keep those it takes one at a time.
+		var negotiated []webrtc.RTPCodecParameters
+		for _, codec := range codecs {
+			if transceiver.SetCodecPreferences([]webrtc.RTPCodecParameters{codec}) == nil {
+				negotiated = append(negotiated, codec)
+			}
+		}
+		if len(negotiated) == 0 {
+			// No preferences would answer with every codec again.
+			return errNoPolicyCodec
+		}
+		if err := transceiver.SetCodecPreferences(negotiated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CodecPolicy returns the room's codec policy, or nil if publishers may send anything.
+func (r *Room) CodecPolicy() *CodecPolicy {
+	return r.codecPolicy.Load()
+}
+
+// SetCodecPolicy restricts the codecs of a room, creating it if needed so the
+// policy is in place before the first publisher negotiates. A nil policy lifts
+// the restriction. Rooms with peers are refused: their sessions were negotiated
+// under the old policy.
+func (rm *RoomManager) SetCodecPolicy(uuid string, policy *CodecPolicy) error {
+	if uuid == "" {
+		return errRoomNotFound
+	}
+	room := rm.GetOrCreateRoom(uuid)
+	room.Lock.Lock()
+	defer room.Lock.Unlock()
+	if len(room.Peers) > 0 {
+		return errRoomOccupied
+	}
+	room.codecPolicy.Store(policy)
+	return nil
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestAdminRoomCodecsCreatesRestrictedRoom(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	handler.MaxPublishBitrate = 64
+	post := func(query string) int {
+		return adminRequest(t, handler, http.MethodPost, "action=room_codecs&"+query).Code
+	}
+	if code := post("room=low&codecs=opus&max_kbps=2"); code != http.StatusBadRequest {
+		t.Fatalf("tiny bitrate: status = %d", code)
+	}
+	if code := post("room=low&codecs=speex"); code != http.StatusBadRequest {
+		t.Fatalf("unknown codec: status = %d", code)
+	}
+	if code := post("room=low&preset=low_bandwidth"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	handler.RoomManager.Lock.RLock()
+	room := handler.RoomManager.Rooms["low"]
+	handler.RoomManager.Lock.RUnlock()
+	if room == nil {
+		t.Fatal("room_codecs did not create the room")
+	}
+	policy := room.CodecPolicy()
+	if policy == nil || !policy.allows(webrtc.MimeTypeOpus) || policy.allows(webrtc.MimeTypePCMU) || policy.Channels != 1 {
+		t.Fatalf("policy = %v", policy)
+	}
+	if got, want := handler.maxPublishBitrate(room), (24+policyHeaderKbps)*1000; got != want {
+		t.Fatalf("cap = %d, want %d", got, want)
+	}
+
+	room.Lock.Lock()
+	room.Peers["someone"] = &Peer{ID: "someone"}
+	room.Lock.Unlock()
+	if code := post("room=low"); code != http.StatusConflict {
+		t.Fatalf("occupied room: status = %d, want 409", code)
+	}
+}
+
+func TestCodecPolicyShapesAnswerAndRefusesOffers(t *testing.T) {
+	h, room, peer := newFuzzPeer(t)
+	room.codecPolicy.Store(&CodecPolicy{Codecs: []string{webrtc.MimeTypeOpus}, Channels: 1, MaxBitrateKbps: 24})
+	outbox := make(chan wsFrame, outboundQueueSize)
+	peer.outbox = outbox
+	// reply returns the next answer or error, skipping the server's own
+	// offers and trickled candidates, which may arrive first.
+	reply := func() map[string]any {
+		t.Helper()
+		for {
+			var frame wsFrame
+			select {
+			case frame = <-outbox:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no reply")
+			}
+			var msg map[string]any
+			if err := json.Unmarshal(frame.data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg["type"] == "answer" || msg["type"] == "error" {
+				return msg
+			}
+		}
+	}
+
+	// An offer with only G.711 has nothing the room allows.
+	pcmu := strings.NewReplacer(" 111 ", " 0 ", "a=rtpmap:111 opus/48000/2", "a=rtpmap:0 PCMU/8000").Replace(opusOnlyOffer(t))
+	h.handleSignalingMessage(room, peer, map[string]any{"type": "offer", "sdp": pcmu})
+	if msg := reply(); msg["type"] != "error" {
+		t.Fatalf("reply = %v, want an error", msg)
+	}
+	if peer.PC.RemoteDescription() != nil {
+		t.Fatal("refused offer was applied")
+	}
+
+	h.handleSignalingMessage(room, peer, map[string]any{"type": "offer", "sdp": clientOffer(t)})
+	msg := reply()
+	if msg["type"] != "answer" {
+		t.Fatalf("reply = %v, want an answer", msg)
+	}
+	answer, _ := msg["sdp"].(string)
+	if !strings.Contains(answer, "stereo=0") || !strings.Contains(answer, "maxaveragebitrate=24000") {
+		t.Fatalf("answer does not ask for mono 24 kbps Opus:\n%s", answer)
+	}
+	for _, codec := range []string{"PCMU", "PCMA", "G722"} {
+		if strings.Contains(answer, codec) {
+			t.Fatalf("answer offers %s outside the policy:\n%s", codec, answer)
+		}
+	}
+}
+
+// opusOnlyOffer returns a client audio offer that carries Opus alone.
+func opusOnlyOffer(t *testing.T) string {
+	t.Helper()
+	pc, err := newTestAPI(t).NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	transceiver, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := transceiver.SetCodecPreferences([]webrtc.RTPCodecParameters{{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: "minptime=10;useinbandfec=1"},
+		PayloadType:        111,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return offer.SDP
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SignalingConn carries one client's signaling messages, whatever the
+// transport: a WebSocket on /ws or a WebTransport stream (see webtransport.go).
+// Message types are the WebSocket ones: websocket.TextMessage for JSON and
+// websocket.BinaryMessage for MessagePack.
+type SignalingConn interface {
+	// ReadMessage blocks until the client sends a whole message.
+	ReadMessage() (messageType int, data []byte, err error)
+	// WriteMessage sends one message, giving up at deadline.
+	WriteMessage(messageType int, data []byte, deadline time.Time) error
+	// WriteClose tells the client the session is over with a WebSocket close
+	// code and reason.
+	WriteClose(code int, text string, deadline time.Time) error
+	Close() error
+}
+
+// wsConn is a SignalingConn over a WebSocket. Reads extend the read deadline
+// that the ping/pong keepalive in HandleWS also extends.
+type wsConn struct {
+	*websocket.Conn
+}
+
+func (c wsConn) ReadMessage() (int, []byte, error) {
+	messageType, data, err := c.Conn.ReadMessage()
+	if err == nil {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	}
+	return messageType, data, err
+}
+
+func (c wsConn) WriteMessage(messageType int, data []byte, deadline time.Time) error {
+	_ = c.Conn.SetWriteDeadline(deadline)
+	return c.Conn.WriteMessage(messageType, data)
+}
+
+func (c wsConn) WriteClose(code int, text string, deadline time.Time) error {
+	return c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), deadline)
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sigmartc/api"
+)
+
+// controlActor identifies gRPC callers in the audit log.
+const controlActor = "grpc"
+
+// ControlServer implements the gRPC control API on top of the same RoomManager
+// operations the admin HTTP handler uses.
+type ControlServer struct {
+	api.UnimplementedControlServiceServer
+	h *Handler
+}
+
+// NewControlServer returns a gRPC server with the control service registered.
+// Every call must carry the admin key as "authorization: Bearer <key>" metadata.
+func NewControlServer(h *Handler, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnaryInterceptor(h.authorizeControl))
+	server := grpc.NewServer(opts...)
+	api.RegisterControlServiceServer(server, &ControlServer{h: h})
+	return server
+}
+
+func (h *Handler) authorizeControl(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if key, ok := strings.CutPrefix(value, "Bearer "); ok && h.checkAdminKey(key) {
+			return next(ctx, req)
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "invalid admin key")
+}
+
+func (s *ControlServer) audit(ctx context.Context, action, target, detail string) {
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = stripPort(p.Addr.String())
+	}
+	s.h.Audit.Record(AuditEntry{
+		Actor:  controlActor,
+		IP:     ip,
+		Action: action,
+		Target: target,
+		Detail: detail,
+	})
+}
+
+func (s *ControlServer) ListRooms(context.Context, *api.ListRoomsRequest) (*api.ListRoomsResponse, error) {
+	rm := s.h.RoomManager
+	rm.Lock.RLock()
+	rooms := make([]*api.Room, 0, len(rm.Rooms))
+	for _, room := range rm.Rooms {
+		room.Lock.RLock()
+		rooms = append(rooms, &api.Room{
+			Id:        room.UUID,
+			PeerCount: int32(len(room.Peers)),
+			CreatedAt: timestamppb.New(room.CreatedAt),
+		})
+		room.Lock.RUnlock()
+	}
+	rm.Lock.RUnlock()
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Id < rooms[j].Id })
+	return &api.ListRoomsResponse{Rooms: rooms}, nil
+}
+
+func (s *ControlServer) CloseRoom(ctx context.Context, req *api.CloseRoomRequest) (*api.CloseRoomResponse, error) {
+	roomUUID := strings.TrimSpace(req.GetRoom())
+	if !s.h.RoomManager.CloseRoom(roomUUID, DisconnectRoomClosed, "The room was closed by an administrator") {
+		return nil, status.Error(codes.NotFound, "room not found")
+	}
+	s.audit(ctx, "close_room", roomUUID, "")
+	return &api.CloseRoomResponse{}, nil
+}
+
+func (s *ControlServer) ListPeers(_ context.Context, req *api.ListPeersRequest) (*api.ListPeersResponse, error) {
+	rm := s.h.RoomManager
+	var rooms []*Room
+	rm.Lock.RLock()
+	if req.GetRoom() != "" {
+		if room := rm.Rooms[req.GetRoom()]; room != nil {
+			rooms = append(rooms, room)
+		}
+	} else {
+		for _, room := range rm.Rooms {
+			rooms = append(rooms, room)
+		}
+	}
+	rm.Lock.RUnlock()
+	if req.GetRoom() != "" && len(rooms) == 0 {
+		return nil, status.Error(codes.NotFound, "room not found")
+	}
+
+	peers := make([]*api.Peer, 0)
+	for _, room := range rooms {
+		room.Lock.RLock()
+		for _, p := range room.Peers {
+			peers = append(peers, &api.Peer{
+				Id:         p.ID,
+				Name:       p.Name,
+				Ip:         p.IP,
+				Room:       room.UUID,
+				JoinedAt:   timestamppb.New(p.JoinTime),
+				TalkTimeMs: p.TalkTime().Milliseconds(),
+			})
+		}
+		room.Lock.RUnlock()
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].JoinedAt.AsTime().Before(peers[j].JoinedAt.AsTime()) })
+	return &api.ListPeersResponse{Peers: peers}, nil
+}
+
+func (s *ControlServer) KickPeer(ctx context.Context, req *api.KickPeerRequest) (*api.KickPeerResponse, error) {
+	peerID := strings.TrimSpace(req.GetPeerId())
+	room, p := s.h.RoomManager.FindPeer(peerID)
+	if p == nil {
+		return nil, status.Error(codes.NotFound, "peer not found")
+	}
+	p.Disconnect(DisconnectKicked, "You were removed by an administrator")
+	s.audit(ctx, "kick", peerID, "room "+room.UUID)
+	return &api.KickPeerResponse{Room: room.UUID}, nil
+}
+
+func (s *ControlServer) ListBans(context.Context, *api.ListBansRequest) (*api.ListBansResponse, error) {
+	rm := s.h.RoomManager
+	rm.Lock.RLock()
+	ips := make([]string, 0, len(rm.BannedIPs))
+	for ip := range rm.BannedIPs {
+		ips = append(ips, ip)
+	}
+	rm.Lock.RUnlock()
+	sort.Strings(ips)
+	return &api.ListBansResponse{Ips: ips}, nil
+}
+
+func (s *ControlServer) BanIP(ctx context.Context, req *api.BanIPRequest) (*api.BanIPResponse, error) {
+	ip := strings.TrimSpace(req.GetIp())
+	if net.ParseIP(ip) == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid IP address")
+	}
+	s.h.RoomManager.BanIP(ip)
+	disconnected := s.h.RoomManager.DisconnectIP(ip, DisconnectBanned, "You have been banned")
+	s.audit(ctx, "ban", ip, "")
+	return &api.BanIPResponse{Disconnected: int32(disconnected)}, nil
+}
+
+func (s *ControlServer) UnbanIP(ctx context.Context, req *api.UnbanIPRequest) (*api.UnbanIPResponse, error) {
+	ip := strings.TrimSpace(req.GetIp())
+	if net.ParseIP(ip) == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid IP address")
+	}
+	s.h.RoomManager.UnbanIP(ip)
+	s.audit(ctx, "unban", ip, "")
+	return &api.UnbanIPResponse{}, nil
+}
+
+func (s *ControlServer) GetStats(context.Context, *api.GetStatsRequest) (*api.GetStatsResponse, error) {
+	stats := s.h.collectStats()
+	return &api.GetStatsResponse{
+		Rooms:         int32(stats.Rooms),
+		Users:         int32(stats.Users),
+		MemoryAllocMb: stats.MemoryAllocMB,
+		Goroutines:    int32(stats.Goroutines),
+	}, nil
+}
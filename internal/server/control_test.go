@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"sigmartc/api"
+)
+
+func newTestControlClient(t *testing.T, handler *Handler) api.ControlServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := NewControlServer(handler)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return api.NewControlServiceClient(conn)
+}
+
+func TestControlServerRequiresAdminKey(t *testing.T) {
+	client := newTestControlClient(t, newTestAdminHandler(t))
+
+	_, err := client.GetStats(context.Background(), &api.GetStatsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without key, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	_, err = client.GetStats(ctx, &api.GetStatsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated with wrong key, got %v", err)
+	}
+}
+
+func TestControlServerManagesRoomsAndBans(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	client := newTestControlClient(t, handler)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer test-key")
+
+	handler.RoomManager.GetOrCreateRoom("room-a")
+	rooms, err := client.ListRooms(ctx, &api.ListRoomsRequest{})
+	if err != nil || len(rooms.GetRooms()) != 1 || rooms.GetRooms()[0].GetId() != "room-a" {
+		t.Fatalf("ListRooms = %v, %v", rooms, err)
+	}
+
+	if _, err := client.ListPeers(ctx, &api.ListPeersRequest{Room: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for unknown room, got %v", err)
+	}
+
+	if _, err := client.BanIP(ctx, &api.BanIPRequest{Ip: "not-an-ip"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if _, err := client.BanIP(ctx, &api.BanIPRequest{Ip: "203.0.113.9"}); err != nil {
+		t.Fatalf("BanIP: %v", err)
+	}
+	bans, err := client.ListBans(ctx, &api.ListBansRequest{})
+	if err != nil || len(bans.GetIps()) != 1 || bans.GetIps()[0] != "203.0.113.9" {
+		t.Fatalf("ListBans = %v, %v", bans, err)
+	}
+	if _, err := client.UnbanIP(ctx, &api.UnbanIPRequest{Ip: "203.0.113.9"}); err != nil {
+		t.Fatalf("UnbanIP: %v", err)
+	}
+	if handler.RoomManager.IsBanned("203.0.113.9") {
+		t.Fatal("expected IP to be unbanned")
+	}
+
+	if _, err := client.CloseRoom(ctx, &api.CloseRoomRequest{Room: "room-a"}); err != nil {
+		t.Fatalf("CloseRoom: %v", err)
+	}
+	stats, err := client.GetStats(ctx, &api.GetStatsRequest{})
+	if err != nil || stats.GetRooms() != 0 {
+		t.Fatalf("GetStats = %v, %v", stats, err)
+	}
+}
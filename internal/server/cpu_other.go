@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package server
+
+// processCPUSeconds is not measured on this platform.
+func processCPUSeconds() float64 {
+	return 0
+}
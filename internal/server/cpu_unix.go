@@ -0,0 +1,14 @@
+//go:build !windows && !plan9
+
+package server
+
+import "syscall"
+
+// processCPUSeconds returns the user plus system CPU time the process has used.
+func processCPUSeconds() float64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return float64(usage.Utime.Nano()+usage.Stime.Nano()) / 1e9
+}
@@ -0,0 +1,53 @@
+package server
+
+import "errors"
+
+// Noise suppression: a host, moderator or admin can turn on RNNoise for a
+// publisher in a noisy place whose client does no processing of its own. The
+// publisher's audio is then denoised by egress.DenoiseFilter before fan-out
+// (see audiofilter.go); the room is told with a denoise message.
+
+var errDenoiseUnavailable = errors.New("noise suppression needs -ffmpeg-path and -denoise-model")
+
+// SetPeerDenoise turns noise suppression on or off for a peer's audio.
+func (h *Handler) SetPeerDenoise(peerID string, enabled bool) error {
+	if enabled && (h.FFmpegPath == "" || h.DenoiseModel == "") {
+		return errDenoiseUnavailable
+	}
+	room, peer := h.RoomManager.FindPeer(peerID)
+	if peer == nil {
+		return errPeerNotFound
+	}
+	if peer.denoise.Swap(enabled) == enabled {
+		return nil
+	}
+	room.ForwardersMu.RLock()
+	forwarder := room.Forwarders[peerID]
+	room.ForwardersMu.RUnlock()
+	if forwarder != nil {
+		h.applyAudioFilters(room, forwarder)
+	}
+	room.Broadcast("", map[string]any{
+		"type":    "denoise",
+		"peer_id": peerID,
+		"enabled": enabled,
+	})
+	return nil
+}
+
+// handleSetDenoise lets hosts and moderators toggle denoising for members of
+// their room over signaling.
+func (h *Handler) handleSetDenoise(room *Room, peer *Peer, msg map[string]any) {
+	targetID, _ := msg["peer_id"].(string)
+	room.Lock.RLock()
+	_, inRoom := room.Peers[targetID]
+	room.Lock.RUnlock()
+	if !peer.Can(PermDenoise) || !inRoom {
+		peer.WriteJSON(map[string]string{"type": "error", "message": "set_denoise not allowed"})
+		return
+	}
+	enabled, _ := msg["enabled"].(bool)
+	if err := h.SetPeerDenoise(targetID, enabled); err != nil {
+		peer.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/internal/egress"
+)
+
+func TestAdminDenoiseFiltersPublisherAudio(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for ffmpeg")
+	}
+	handler := newTestAdminHandler(t)
+	room := handler.RoomManager.GetOrCreateRoom("room-a")
+	publisher := &Peer{ID: "publisher", Name: "pub", JoinTime: time.Now()}
+	room.Lock.Lock()
+	room.Peers[publisher.ID] = publisher
+	room.Lock.Unlock()
+	publisher.setRoom(room)
+	forwarder := newSyntheticForwarder(context.Background(), publisher.ID, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2})
+	defer forwarder.Stop()
+	room.ForwardersMu.Lock()
+	room.Forwarders[publisher.ID] = forwarder
+	room.ForwardersMu.Unlock()
+
+	post := func(query string) int {
+		return adminRequest(t, handler, http.MethodPost, "action=denoise&"+query).Code
+	}
+	if code := post("peer_id=publisher&enabled=1"); code != http.StatusConflict {
+		t.Fatalf("without a model: status = %d, want 409", code)
+	}
+
+	dir := t.TempDir()
+	handler.FFmpegPath = filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(handler.FFmpegPath, []byte("#!/bin/sh\ncat\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	handler.DenoiseModel = filepath.Join(dir, "std.rnnn")
+	if err := os.WriteFile(handler.DenoiseModel, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := post("peer_id=missing&enabled=1"); code != http.StatusNotFound {
+		t.Fatalf("missing peer: status = %d", code)
+	}
+	if code := post("peer_id=publisher&enabled=1"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if filter := forwarder.filter.Load(); filter == nil || !strings.Contains(filter.chain, "arnndn=m='"+handler.DenoiseModel+"'") {
+		t.Fatalf("filter = %+v, want an arnndn stage", filter)
+	}
+
+	// Leveling joins the same stage after the denoiser.
+	if err := handler.SetRoomLeveling("room-a", true); err != nil {
+		t.Fatal(err)
+	}
+	if filter := forwarder.filter.Load(); filter == nil || !strings.HasSuffix(filter.chain, ","+egress.SpeechNormFilter) {
+		t.Fatalf("filter = %+v, want denoise then leveling", filter)
+	}
+	_ = handler.SetRoomLeveling("room-a", false)
+
+	if code := post("peer_id=publisher&enabled=0"); code != http.StatusOK {
+		t.Fatalf("disable: status = %d", code)
+	}
+	if forwarder.filter.Load() != nil {
+		t.Fatal("publisher kept its denoiser after disabling it")
+	}
+}
+
+func TestSetDenoiseNeedsPermission(t *testing.T) {
+	h, room, peer := newFuzzPeer(t)
+	h.FFmpegPath = "ffmpeg"
+	h.DenoiseModel = "std.rnnn"
+	outbox := make(chan wsFrame, 1)
+	peer.outbox = outbox
+	peer.Role = RoleSpeaker
+
+	h.handleSignalingMessage(room, peer, map[string]any{"type": "set_denoise", "peer_id": peer.ID, "enabled": true})
+	var reply map[string]any
+	if err := json.Unmarshal((<-outbox).data, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply["type"] != "error" || peer.denoise.Load() {
+		t.Fatalf("speaker toggled denoising: reply %v", reply)
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// PeerDiagnostics is a snapshot of a peer's connection internals for support.
+type PeerDiagnostics struct {
+	ID                 string    `json:"id"`
+	SessionID          string    `json:"session_id"`
+	Name               string    `json:"name"`
+	IP                 string    `json:"ip"`
+	Country            string    `json:"country,omitempty"`
+	UserID             string    `json:"user_id,omitempty"`
+	Room               string    `json:"room"`
+	JoinedAt           time.Time `json:"joined_at"`
+	ConnectionState    string    `json:"connection_state"`
+	ICEConnectionState string    `json:"ice_connection_state"`
+	SignalingState     string    `json:"signaling_state"`
+	// SelectedPair is "local -> remote" for the nominated ICE candidate pair, if any.
+	SelectedPair string `json:"selected_pair,omitempty"`
+
+	NegotiationPending    bool      `json:"negotiation_pending"`
+	NegotiationInProgress bool      `json:"negotiation_in_progress"`
+	MakingOffer           bool      `json:"making_offer"`
+	IceRestartPending     bool      `json:"ice_restart_pending"`
+	LastIceRestart        time.Time `json:"last_ice_restart,omitzero"`
+
+	PendingCandidates int `json:"pending_candidates"`
+	// OutTracks maps each sender the peer receives audio from to the local track ID.
+	OutTracks map[string]string `json:"out_tracks"`
+	// Subscriptions lists senders whose forwarder currently writes to this peer.
+	Subscriptions []string `json:"subscriptions"`
+}
+
+// Diagnostics collects the peer's connection state. It only takes the peer's own
+// locks and the room's forwarder lock, so it is safe to call at any time.
+func (p *Peer) Diagnostics() PeerDiagnostics {
+	d := PeerDiagnostics{
+		ID:        p.ID,
+		SessionID: p.SessionID,
+		Name:      p.Name,
+		IP:        p.IP,
+		Country:   p.Country,
+		UserID:    p.UserID(),
+		JoinedAt:  p.JoinTime,
+	}
+	if pc := p.PC; pc != nil {
+		d.ConnectionState = pc.ConnectionState().String()
+		d.ICEConnectionState = pc.ICEConnectionState().String()
+		d.SignalingState = pc.SignalingState().String()
+		d.SelectedPair = selectedCandidatePair(pc)
+	}
+
+	p.NegotiationMu.Lock()
+	d.NegotiationPending = p.NegotiationPending
+	d.NegotiationInProgress = p.NegotiationInProgress
+	d.MakingOffer = p.MakingOffer
+	d.IceRestartPending = p.IceRestartPending
+	d.LastIceRestart = p.LastIceRestart
+	p.NegotiationMu.Unlock()
+
+	p.PendingCandidatesMu.Lock()
+	d.PendingCandidates = len(p.PendingCandidates)
+	p.PendingCandidatesMu.Unlock()
+
+	p.OutTracksMu.RLock()
+	d.OutTracks = make(map[string]string, len(p.OutTracks))
+	for senderID, track := range p.OutTracks {
+		d.OutTracks[senderID] = track.ID()
+	}
+	p.OutTracksMu.RUnlock()
+
+	d.Subscriptions = []string{}
+	if room := p.Room(); room != nil {
+		d.Room = room.UUID
+		room.ForwardersMu.RLock()
+		for senderID, forwarder := range room.Forwarders {
+			if forwarder.hasSubscriber(p.ID) {
+				d.Subscriptions = append(d.Subscriptions, senderID)
+			}
+		}
+		room.ForwardersMu.RUnlock()
+		sort.Strings(d.Subscriptions)
+	}
+	return d
+}
+
+// selectedCandidatePair describes the ICE pair carrying the peer's media, or ""
+// before ICE has nominated one.
+func selectedCandidatePair(pc *webrtc.PeerConnection) string {
+	transport := iceTransport(pc)
+	if transport == nil {
+		return ""
+	}
+	pair, err := transport.GetSelectedCandidatePair()
+	if err != nil || pair == nil || pair.Local == nil || pair.Remote == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s -> %s", describeCandidate(pair.Local), describeCandidate(pair.Remote))
+}
+
+func describeCandidate(c *webrtc.ICECandidate) string {
+	return fmt.Sprintf("%s %s:%d (%s)", c.Protocol, c.Address, c.Port, c.Typ)
+}
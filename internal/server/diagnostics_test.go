@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminPeerDiagnostics(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "diag", "alice")
+	state := readUntilType(t, conn, "room_state")
+	peerID, _ := state["self_id"].(string)
+
+	rec := adminRequest(t, handler, http.MethodGet, "action=peer&peer_id="+peerID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var diag PeerDiagnostics
+	if err := json.NewDecoder(rec.Body).Decode(&diag); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if diag.ID != peerID || diag.Room != "diag" || diag.Name != "alice" {
+		t.Fatalf("diagnostics = %+v", diag)
+	}
+	if diag.SignalingState == "" || diag.OutTracks == nil || diag.Subscriptions == nil {
+		t.Fatalf("expected connection state and empty track lists, got %+v", diag)
+	}
+
+	rec = adminRequest(t, handler, http.MethodGet, "action=peer&peer_id=missing")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAdminICERestart(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "diag", "alice")
+	state := readUntilType(t, conn, "room_state")
+	peerID, _ := state["self_id"].(string)
+	_, peer := handler.RoomManager.FindPeer(peerID)
+	if peer == nil {
+		t.Fatal("expected peer to be registered")
+	}
+
+	do := func() int {
+		return adminRequest(t, handler, http.MethodPost, "action=ice_restart&peer_id="+peerID).Code
+	}
+	if code := do(); code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+	if peer.Diagnostics().LastIceRestart.IsZero() {
+		t.Fatal("expected LastIceRestart to be recorded")
+	}
+	if code := do(); code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d for a second restart", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestICECredentialRefresh(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	handler.Negotiation.ICECredentialRefreshInterval = 50 * time.Millisecond
+	conn := dialTestWS(t, srv.URL, "diag", "alice")
+	state := readUntilType(t, conn, "room_state")
+	peerID, _ := state["self_id"].(string)
+	_, peer := handler.RoomManager.FindPeer(peerID)
+	if peer == nil {
+		t.Fatal("expected peer to be registered")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for peer.Diagnostics().LastIceRestart.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a periodic ICE restart")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// DirectoryEntry describes a room in the directory.
+type DirectoryEntry struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Topic    string `json:"topic,omitempty"`
+	Avatar   string `json:"avatar,omitempty"`
+	Peers    int    `json:"peers"`
+	MaxPeers int    `json:"max_peers"`
+	Public   bool   `json:"public"`
+}
+
+// SetListing sets whether the room appears in the public directory and reports
+// whether the room exists.
+func (rm *RoomManager) SetListing(uuid string, public bool) bool {
+	rm.Lock.RLock()
+	room := rm.Rooms[uuid]
+	rm.Lock.RUnlock()
+	if room == nil {
+		return false
+	}
+	room.Lock.Lock()
+	room.Public = public
+	room.Lock.Unlock()
+	return true
+}
+
+// Directory lists the rooms in a tenant's namespace (nil for the default one),
+// busiest first. With publicOnly set, unlisted rooms are omitted.
+func (rm *RoomManager) Directory(publicOnly bool, tenant *Tenant) []DirectoryEntry {
+	maxPeers := maxRoomPeers
+	if tenant != nil && tenant.MaxPeersPerRoom > 0 {
+		maxPeers = min(maxPeers, tenant.MaxPeersPerRoom)
+	}
+	rm.Lock.RLock()
+	entries := make([]DirectoryEntry, 0, len(rm.Rooms))
+	for _, room := range rm.Rooms {
+		if room.tenant != tenant {
+			continue
+		}
+		room.Lock.RLock()
+		if !publicOnly || room.Public {
+			entries = append(entries, DirectoryEntry{
+				ID:       tenant.local(room.UUID),
+				Name:     room.Name,
+				Topic:    room.Topic,
+				Avatar:   room.Avatar,
+				Peers:    len(room.Peers),
+				MaxPeers: maxPeers,
+				Public:   room.Public,
+			})
+		}
+		room.Lock.RUnlock()
+	}
+	rm.Lock.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Peers != entries[j].Peers {
+			return entries[i].Peers > entries[j].Peers
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// HandleRooms serves GET /api/rooms. public=true lists rooms that opted into the
+// directory and needs no credentials; listing every room requires admin access,
+// or a tenant's API key for that tenant's rooms. tenant=<id> selects a tenant's
+// namespace.
+func (h *Handler) HandleRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	publicOnly := query.Get("public") == "true"
+	var tenant *Tenant
+	if id := query.Get("tenant"); id != "" {
+		if tenant = h.RoomManager.Tenants.get(id); tenant == nil {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
+			return
+		}
+	}
+	if !publicOnly && !h.authorizeAdmin(r) {
+		owner := h.requestTenant(r)
+		if owner == nil || (tenant != nil && tenant != owner) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		tenant = owner
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(map[string]any{"rooms": h.RoomManager.Directory(publicOnly, tenant)})
+}
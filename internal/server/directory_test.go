@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleRoomsListsOnlyPublicRooms(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	rm := handler.RoomManager
+	rm.GetOrCreateRoom("hidden")
+	rm.GetOrCreateRoom("lobby")
+	if !rm.SetListing("lobby", true) {
+		t.Fatal("expected room to exist")
+	}
+	if err := handler.SetRoomMetadata("lobby", RoomMetadata{Name: "Lobby", Topic: "Say hi"}); err != nil {
+		t.Fatalf("SetRoomMetadata failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleRooms(rec, httptest.NewRequest(http.MethodGet, "/api/rooms?public=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body struct {
+		Rooms []DirectoryEntry `json:"rooms"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(body.Rooms) != 1 || body.Rooms[0].ID != "lobby" || body.Rooms[0].Name != "Lobby" || body.Rooms[0].Topic != "Say hi" {
+		t.Fatalf("rooms = %+v, want only the lobby", body.Rooms)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleRooms(rec, httptest.NewRequest(http.MethodGet, "/api/rooms", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d for unlisted rooms without admin key", rec.Code, http.StatusUnauthorized)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/rooms", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec = httptest.NewRecorder()
+	handler.HandleRooms(rec, req)
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(body.Rooms) != 2 {
+		t.Fatalf("rooms = %+v, want both rooms for admins", body.Rooms)
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"sigmartc/internal/logger"
+)
+
+// DisconnectReason is the machine-readable reason sent in a "disconnect" message
+// and as the WebSocket close frame reason when the server ends a session.
+type DisconnectReason string
+
+const (
+	DisconnectRoomFull    DisconnectReason = "room_full"
+	DisconnectBanned      DisconnectReason = "banned"
+	DisconnectKicked      DisconnectReason = "kicked"
+	DisconnectRoomClosed  DisconnectReason = "room_closed"
+	DisconnectShutdown    DisconnectReason = "shutdown"
+	DisconnectIdle        DisconnectReason = "idle_timeout"
+	DisconnectServerError DisconnectReason = "server_error"
+	DisconnectNotStarted  DisconnectReason = "room_not_started"
+	DisconnectRoomExpired DisconnectReason = "room_expired"
+	DisconnectRoomLocked  DisconnectReason = "room_locked"
+	DisconnectInvalidName DisconnectReason = "invalid_name"
+	// DisconnectSignalingOverflow means the peer's signaling queue filled up.
+	DisconnectSignalingOverflow DisconnectReason = "signaling_overflow"
+	// DisconnectSlowConsumer means the peer's outbound message queue filled up.
+	DisconnectSlowConsumer DisconnectReason = "slow_consumer"
+	// DisconnectQuotaExceeded means the room used its monthly bandwidth quota.
+	DisconnectQuotaExceeded DisconnectReason = "quota_exceeded"
+	// DisconnectGeoBlocked means the client's country is not allowed to join.
+	DisconnectGeoBlocked DisconnectReason = "geo_blocked"
+	// DisconnectChallengeFailed means the join's proof of work or CAPTCHA was missing or invalid.
+	DisconnectChallengeFailed DisconnectReason = "challenge_failed"
+	// DisconnectUnauthorized means JWT auth is on and the join had no valid token.
+	DisconnectUnauthorized DisconnectReason = "unauthorized"
+	// DisconnectIPLimit means the room already has MaxPeersPerIP peers from the client's IP.
+	DisconnectIPLimit DisconnectReason = "ip_limit"
+	// DisconnectDuplicateSession refuses a join whose device is already in the room;
+	// the client may retry with takeover=1.
+	DisconnectDuplicateSession DisconnectReason = "duplicate_session"
+	// DisconnectSessionTakeover closes a session that a newer tab of the same device took over.
+	DisconnectSessionTakeover DisconnectReason = "session_takeover"
+	// DisconnectICEFailed means the peer connection kept failing after ICE restarts.
+	DisconnectICEFailed DisconnectReason = "ice_failed"
+	// DisconnectNegotiationTimeout means an offer/answer exchange did not finish in time.
+	DisconnectNegotiationTimeout DisconnectReason = "negotiation_timeout"
+	// DisconnectRedirect follows a redirect message: the room is hosted on another node.
+	DisconnectRedirect DisconnectReason = "redirect"
+	// DisconnectTenantLimit means the join would open a room beyond its tenant's max_rooms.
+	DisconnectTenantLimit DisconnectReason = "tenant_limit"
+)
+
+// closeCode maps a reason to the WebSocket close code browsers surface in onclose.
+func (r DisconnectReason) closeCode() int {
+	switch r {
+	case DisconnectRoomFull, DisconnectNotStarted, DisconnectRoomLocked, DisconnectIPLimit, DisconnectTenantLimit:
+		return websocket.CloseTryAgainLater
+	case DisconnectBanned, DisconnectKicked, DisconnectInvalidName, DisconnectSignalingOverflow, DisconnectGeoBlocked, DisconnectChallengeFailed, DisconnectUnauthorized:
+		return websocket.ClosePolicyViolation
+	case DisconnectDuplicateSession:
+		return websocket.ClosePolicyViolation
+	case DisconnectShutdown, DisconnectSessionTakeover:
+		return websocket.CloseGoingAway
+	case DisconnectSlowConsumer, DisconnectQuotaExceeded, DisconnectICEFailed, DisconnectNegotiationTimeout:
+		return websocket.CloseTryAgainLater
+	case DisconnectServerError:
+		return websocket.CloseInternalServerErr
+	default:
+		return websocket.CloseNormalClosure
+	}
+}
+
+// Disconnect tells the client why it is being removed, sends a close frame, and
+// tears the session down with Close. Only the first call has any effect.
+func (p *Peer) Disconnect(reason DisconnectReason, message string) {
+	p.disconnectOnce.Do(func() {
+		if p.State() >= PeerLeaving {
+			// The client already left; there is no one left to tell.
+			return
+		}
+		p.disconnectMu.Lock()
+		p.disconnectReason = reason
+		p.disconnectMu.Unlock()
+		p.note(TimelineDisconnected, string(reason))
+
+		p.WriteJSON(map[string]any{
+			"type":    "disconnect",
+			"reason":  string(reason),
+			"message": message,
+		})
+
+		p.sendClose(closeFrame{code: reason.closeCode(), text: string(reason)})
+		p.Close(reason)
+	})
+}
+
+// DisconnectReason returns the reason passed to Disconnect, or "" if the client left on its own.
+func (p *Peer) DisconnectReason() DisconnectReason {
+	p.disconnectMu.Lock()
+	defer p.disconnectMu.Unlock()
+	return p.disconnectReason
+}
+
+// FindPeer locates a connected peer by ID across all rooms.
+func (rm *RoomManager) FindPeer(peerID string) (*Room, *Peer) {
+	rm.Lock.RLock()
+	defer rm.Lock.RUnlock()
+	for _, room := range rm.Rooms {
+		room.Lock.RLock()
+		peer := room.Peers[peerID]
+		room.Lock.RUnlock()
+		if peer != nil {
+			return room, peer
+		}
+	}
+	return nil, nil
+}
+
+// DisconnectIP disconnects every peer connected from ip and returns how many were removed.
+func (rm *RoomManager) DisconnectIP(ip string, reason DisconnectReason, message string) int {
+	return rm.disconnectIP(ip, nil, reason, message)
+}
+
+// disconnectIP disconnects the peers connected from ip in the tenant's rooms,
+// or in every room when tenant is nil.
+func (rm *RoomManager) disconnectIP(ip string, tenant *Tenant, reason DisconnectReason, message string) int {
+	var targets []*Peer
+	rm.Lock.RLock()
+	for _, room := range rm.Rooms {
+		if tenant != nil && room.tenant != tenant {
+			continue
+		}
+		room.Lock.RLock()
+		for _, peer := range room.Peers {
+			if peer.IP == ip {
+				targets = append(targets, peer)
+			}
+		}
+		room.Lock.RUnlock()
+	}
+	rm.Lock.RUnlock()
+
+	disconnectAll(targets, reason, message)
+	return len(targets)
+}
+
+// disconnectAll disconnects the peers concurrently, so clients slow to take
+// their close frame are waited for together rather than one after another.
+func disconnectAll(peers []*Peer, reason DisconnectReason, message string) {
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		wg.Add(1)
+		go func(peer *Peer) {
+			defer wg.Done()
+			peer.Disconnect(reason, message)
+		}(peer)
+	}
+	wg.Wait()
+}
+
+// CloseRoom disconnects everyone in the room and removes it. It reports whether the room existed.
+func (rm *RoomManager) CloseRoom(uuid string, reason DisconnectReason, message string) bool {
+	rm.Lock.Lock()
+	room, exists := rm.Rooms[uuid]
+	if exists {
+		delete(rm.Rooms, uuid)
+	}
+	rm.Lock.Unlock()
+	if !exists {
+		return false
+	}
+
+	room.Lock.RLock()
+	peers := make([]*Peer, 0, len(room.Peers))
+	for _, peer := range room.Peers {
+		peers = append(peers, peer)
+	}
+	room.Lock.RUnlock()
+
+	disconnectAll(peers, reason, message)
+	room.Lock.Lock()
+	usage := room.usageEnd(time.Now())
+	room.Lock.Unlock()
+	rm.recordUsage(usage)
+	room.stopServerMedia()
+	logger.LogEvent("ROOM_DESTROY", slog.String("uuid", uuid), slog.String("reason", string(reason)))
+	rm.emit(EventRoomDestroy, map[string]any{"room": uuid, "reason": string(reason)})
+	return true
+}
+
+// Shutdown disconnects every connected peer with the shutdown reason. Rooms
+// are closed concurrently, so shutdown takes at most about closeWait however
+// many slow clients there are.
+func (rm *RoomManager) Shutdown() {
+	rm.Lock.RLock()
+	uuids := make([]string, 0, len(rm.Rooms))
+	for uuid := range rm.Rooms {
+		uuids = append(uuids, uuid)
+	}
+	rm.Lock.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, uuid := range uuids {
+		wg.Add(1)
+		go func(uuid string) {
+			defer wg.Done()
+			rm.CloseRoom(uuid, DisconnectShutdown, "Server is shutting down")
+		}(uuid)
+	}
+	wg.Wait()
+}
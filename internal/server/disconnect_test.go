@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v3"
+)
+
+func newTestWSServer(t *testing.T) (*Handler, *httptest.Server) {
+	t.Helper()
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), &webrtc.Configuration{}, nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", handler.HandleWS)
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := &httptest.Server{Listener: ln, Config: &http.Server{Handler: mux}}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return handler, srv
+}
+
+func dialTestWS(t *testing.T, serverURL, room, name string) *websocket.Conn {
+	t.Helper()
+	wsURL, err := buildWSURL(serverURL, room, name)
+	if err != nil {
+		t.Fatalf("failed to build ws url: %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// readUntilType reads messages until one with the given type arrives.
+func readUntilType(t *testing.T, conn *websocket.Conn, msgType string) map[string]any {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg map[string]any
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("failed waiting for %q: %v", msgType, err)
+		}
+		if msg["type"] == msgType {
+			return msg
+		}
+	}
+}
+
+func TestKickSendsDisconnectReasonAndCloseCode(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "room-kick", "alice")
+
+	state := readUntilType(t, conn, "room_state")
+	peerID, _ := state["self_id"].(string)
+	_, peer := handler.RoomManager.FindPeer(peerID)
+	if peer == nil {
+		t.Fatal("expected peer to be registered")
+	}
+
+	peer.Disconnect(DisconnectKicked, "bye")
+
+	msg := readUntilType(t, conn, "disconnect")
+	if msg["reason"] != string(DisconnectKicked) {
+		t.Fatalf("reason = %v, want %q", msg["reason"], DisconnectKicked)
+	}
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != string(DisconnectKicked) {
+		t.Fatalf("expected policy violation close, got %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, p := handler.RoomManager.FindPeer(peerID); p == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("expected kicked peer to be removed from the room")
+}
+
+func TestCloseRoomDisconnectsPeers(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "room-close", "alice")
+	readUntilType(t, conn, "room_state")
+
+	if !handler.RoomManager.CloseRoom("room-close", DisconnectRoomClosed, "closed") {
+		t.Fatal("expected room to exist")
+	}
+	if handler.RoomManager.CloseRoom("room-close", DisconnectRoomClosed, "closed") {
+		t.Fatal("expected second close to report missing room")
+	}
+	msg := readUntilType(t, conn, "disconnect")
+	if msg["reason"] != string(DisconnectRoomClosed) {
+		t.Fatalf("reason = %v, want %q", msg["reason"], DisconnectRoomClosed)
+	}
+}
+
+func TestIdlePeerIsDisconnected(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	handler.IdleTimeout = time.Second
+	conn := dialTestWS(t, srv.URL, "room-idle", "alice")
+
+	msg := readUntilType(t, conn, "disconnect")
+	if msg["reason"] != string(DisconnectIdle) {
+		t.Fatalf("reason = %v, want %q", msg["reason"], DisconnectIdle)
+	}
+}
+
+func TestUnansweredNegotiationDisconnects(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	handler.Negotiation.NegotiationTimeout = 300 * time.Millisecond
+	// The client never sends an offer, so the server's negotiation cannot finish.
+	conn := dialTestWS(t, srv.URL, "room-negotiation", "alice")
+
+	if msg := readUntilType(t, conn, "error"); msg["message"] != "Negotiation timed out" {
+		t.Fatalf("error = %v, want negotiation timeout", msg["message"])
+	}
+	msg := readUntilType(t, conn, "disconnect")
+	if msg["reason"] != string(DisconnectNegotiationTimeout) {
+		t.Fatalf("reason = %v, want %q", msg["reason"], DisconnectNegotiationTimeout)
+	}
+}
+
+func TestCloseWithoutDisconnectSendsCloseFrame(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "room-close-frame", "alice")
+	state := readUntilType(t, conn, "room_state")
+	_, peer := handler.RoomManager.FindPeer(state["self_id"].(string))
+	if peer == nil {
+		t.Fatal("expected peer to be registered")
+	}
+
+	peer.Close("")
+
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseNormalClosure {
+			t.Fatalf("expected a normal close frame, got %v", err)
+		}
+		return
+	}
+}
+
+// stuckConn is a SignalingConn whose client never reads, standing in for a
+// peer whose writer goroutine is blocked.
+type stuckConn struct{}
+
+func (stuckConn) ReadMessage() (int, []byte, error)         { return 0, nil, net.ErrClosed }
+func (stuckConn) WriteMessage(int, []byte, time.Time) error { return nil }
+func (stuckConn) WriteClose(int, string, time.Time) error   { return nil }
+func (stuckConn) Close() error                              { return nil }
+
+func TestShutdownClosesSlowPeersConcurrently(t *testing.T) {
+	defer func(wait time.Duration) { closeWait = wait }(closeWait)
+	closeWait = 200 * time.Millisecond
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	for _, uuid := range []string{"room-a", "room-b"} {
+		room := rm.GetOrCreateRoom(uuid)
+		for _, id := range []string{"alice", "bob", "carol"} {
+			ctx, cancel := context.WithCancel(context.Background())
+			// The writer never finishes, so every close frame waits closeWait.
+			peer := &Peer{
+				ID:         uuid + "-" + id,
+				Conn:       stuckConn{},
+				ctx:        ctx,
+				cancel:     cancel,
+				outbox:     make(chan wsFrame, outboundQueueSize),
+				closing:    make(chan closeFrame, 1),
+				writerDone: make(chan struct{}),
+			}
+			peer.advance(PeerActive)
+			peer.setRoom(room)
+			room.Lock.Lock()
+			room.addPeer(peer)
+			room.Lock.Unlock()
+		}
+	}
+
+	start := time.Now()
+	rm.Shutdown()
+	if elapsed := time.Since(start); elapsed > 3*closeWait {
+		t.Fatalf("Shutdown took %v with six slow peers, want about %v", elapsed, closeWait)
+	}
+	if len(rm.Rooms) != 0 {
+		t.Fatalf("%d rooms left after Shutdown", len(rm.Rooms))
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// dtlsCertValidity is how long a generated DTLS certificate is valid. Peers
+// only check its fingerprint, so a long validity keeps the fingerprint stable
+// for those that pin it; pion refuses expired certificates.
+const dtlsCertValidity = 10 * 365 * 24 * time.Hour
+
+// LoadDTLSCertificate reads the server's DTLS certificate and private key from
+// a PEM file, generating an ECDSA P-256 pair there on first use. Without it pion
+// creates a new certificate per connection, so the fingerprint changes every call
+// and every restart.
+func LoadDTLSCertificate(path string) (*webrtc.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return generateDTLSCertificate(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var certDER []byte
+	var key crypto.PrivateKey
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			if certDER == nil {
+				certDER = block.Bytes
+			}
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if certDER == nil || key == nil {
+		return nil, fmt.Errorf("%s: want a CERTIFICATE and a private key PEM block", path)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, fmt.Errorf("%s: certificate expired on %s", path, cert.NotAfter.Format(time.DateOnly))
+	}
+	certificate := webrtc.CertificateFromX509(key, cert)
+	return &certificate, nil
+}
+
+// generateDTLSCertificate creates a certificate and writes it to path, readable
+// only by the server's user since it holds the private key.
+func generateDTLSCertificate(path string) (*webrtc.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "sigmartc"},
+		NotBefore:    now.Add(-24 * time.Hour),
+		NotAfter:     now.Add(dtlsCertValidity),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...)
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	certificate := webrtc.CertificateFromX509(key, cert)
+	return &certificate, nil
+}
+
+// DTLSFingerprint formats a certificate's SHA-256 fingerprint as it appears in
+// SDP: "sha-256 AB:CD:...".
+func DTLSFingerprint(cert webrtc.Certificate) (string, error) {
+	fingerprints, err := cert.GetFingerprints()
+	if err != nil {
+		return "", err
+	}
+	return fingerprints[0].Algorithm + " " + strings.ToUpper(fingerprints[0].Value), nil
+}
+
+// HandleDTLS reports the fingerprints and expiry of the server's DTLS
+// certificates so clients and monitoring can check the SDP a=fingerprint
+// against them. It is 404 when the server generates a certificate per
+// connection (no -dtls-cert).
+func (h *Handler) HandleDTLS(w http.ResponseWriter, r *http.Request) {
+	config := h.iceConfig()
+	if config == nil || len(config.Certificates) == 0 {
+		http.Error(w, "no persistent DTLS certificate", http.StatusNotFound)
+		return
+	}
+	type certificateInfo struct {
+		Fingerprint string    `json:"fingerprint"`
+		Expires     time.Time `json:"expires"`
+	}
+	certificates := make([]certificateInfo, 0, len(config.Certificates))
+	for _, cert := range config.Certificates {
+		fingerprint, err := DTLSFingerprint(cert)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		certificates = append(certificates, certificateInfo{Fingerprint: fingerprint, Expires: cert.Expires()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{"certificates": certificates})
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestLoadDTLSCertificatePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certs", "dtls.pem")
+	first, err := LoadDTLSCertificate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Fatalf("mode = %o, want 600", mode)
+	}
+	second, err := LoadDTLSCertificate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := DTLSFingerprint(*first)
+	got, _ := DTLSFingerprint(*second)
+	if !strings.HasPrefix(want, "sha-256 ") || got != want {
+		t.Fatalf("fingerprint after reload = %q, want %q", got, want)
+	}
+
+	pc, err := newTestAPI(t).NewPeerConnection(webrtc.Configuration{Certificates: []webrtc.Certificate{*second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if _, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(offer.SDP, "a=fingerprint:"+want) {
+		t.Fatalf("offer does not carry %q:\n%s", want, offer.SDP)
+	}
+
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDTLSCertificate(path); err == nil {
+		t.Fatal("garbage file accepted")
+	}
+}
+
+func TestHandleDTLSReportsFingerprint(t *testing.T) {
+	cert, err := LoadDTLSCertificate(filepath.Join(t.TempDir(), "dtls.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newTestAdminHandler(t)
+	handler.ICEConfig = &webrtc.Configuration{}
+
+	rec := httptest.NewRecorder()
+	handler.HandleDTLS(rec, httptest.NewRequest(http.MethodGet, "/api/dtls", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("without a certificate: status = %d, want 404", rec.Code)
+	}
+
+	handler.ICEConfig.Certificates = []webrtc.Certificate{*cert}
+	rec = httptest.NewRecorder()
+	handler.HandleDTLS(rec, httptest.NewRequest(http.MethodGet, "/api/dtls", nil))
+	var body struct {
+		Certificates []struct{ Fingerprint string }
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := DTLSFingerprint(*cert)
+	if len(body.Certificates) != 1 || body.Certificates[0].Fingerprint != want {
+		t.Fatalf("certificates = %+v, want %s", body.Certificates, want)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import "errors"
+
+// maxE2EEPayloadBytes bounds an e2ee_key payload; key exchange messages are small.
+const maxE2EEPayloadBytes = 8 << 10
+
+var (
+	errE2EERoom     = errors.New("not available in an end-to-end encrypted room")
+	errRoomOccupied = errors.New("room has peers")
+)
+
+// In an E2EE room clients encrypt media with insertable streams (e.g. SFrame) and
+// exchange keys through e2ee_key messages. The server relays those payloads
+// untouched and never reads them; it also cannot decode the room's media, so
+// egress, HLS, the soundboard and announcements are refused.
+
+// SetE2EE flags or unflags a room as end-to-end encrypted. Connected clients
+// could not switch encryption consistently, so the room must be empty.
+func (rm *RoomManager) SetE2EE(uuid string, enabled bool) error {
+	rm.Lock.RLock()
+	room := rm.Rooms[uuid]
+	rm.Lock.RUnlock()
+	if room == nil {
+		return errRoomNotFound
+	}
+	room.Lock.Lock()
+	defer room.Lock.Unlock()
+	if len(room.Peers) > 0 && room.E2EE != enabled {
+		return errRoomOccupied
+	}
+	room.E2EE = enabled
+	return nil
+}
+
+// IsE2EE reports whether the room is end-to-end encrypted.
+func (r *Room) IsE2EE() bool {
+	r.Lock.RLock()
+	defer r.Lock.RUnlock()
+	return r.E2EE
+}
+
+// forwardsMedia reports whether the peer's published tracks may be fanned out. In
+// E2EE rooms only clients that declared e2ee in hello are forwarded, so a client
+// that would send plaintext cannot leak it to the room.
+func (p *Peer) forwardsMedia(room *Room) bool {
+	if !p.Can(PermSpeak) {
+		return false
+	}
+	if room.IsE2EE() {
+		caps := p.Capabilities()
+		return caps != nil && caps.E2EE
+	}
+	return true
+}
+
+// handleE2EEKey relays an opaque key exchange payload to one peer (to) or to
+// the rest of the room.
+func (h *Handler) handleE2EEKey(room *Room, peer *Peer, msg map[string]any) {
+	if !room.IsE2EE() {
+		peer.WriteJSON(map[string]string{"type": "error", "message": "e2ee_key requires an end-to-end encrypted room"})
+		return
+	}
+	relay := map[string]any{
+		"type":    "e2ee_key",
+		"from":    peer.ID,
+		"payload": msg["payload"],
+	}
+	to, _ := msg["to"].(string)
+	if to == "" {
+		room.Broadcast(peer.ID, relay)
+		return
+	}
+	room.Lock.RLock()
+	target := room.Peers[to]
+	room.Lock.RUnlock()
+	if target == nil {
+		peer.log().Debug("e2ee_key target not in room", "to", to)
+		peer.WriteJSON(map[string]string{"type": "error", "message": "peer not found"})
+		return
+	}
+	target.WriteJSON(relay)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestE2EEKeyRelay(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	wsURL, err := buildWSURL(srv.URL, "room-e2ee", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, _, err := websocket.DefaultDialer.Dial(wsURL+"&e2ee=1", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer alice.Close()
+	state := readUntilType(t, alice, "room_state")
+	if state["e2ee"] != true {
+		t.Fatalf("room_state e2ee = %v, want true", state["e2ee"])
+	}
+	aliceID, _ := state["self_id"].(string)
+
+	bob := dialTestWS(t, srv.URL, "room-e2ee", "bob")
+	readUntilType(t, bob, "room_state")
+	carol := dialTestWS(t, srv.URL, "room-e2ee", "carol")
+	state = readUntilType(t, carol, "room_state")
+	carolID, _ := state["self_id"].(string)
+
+	// Targeted: only carol gets it, payload untouched.
+	if err := alice.WriteJSON(map[string]string{"type": "e2ee_key", "to": carolID, "payload": "c2ZyYW1lLWtleQ=="}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	msg := readUntilType(t, carol, "e2ee_key")
+	if msg["from"] != aliceID || msg["payload"] != "c2ZyYW1lLWtleQ==" {
+		t.Fatalf("relayed = %v", msg)
+	}
+	// Broadcast reaches bob; a payload over the limit is rejected.
+	if err := alice.WriteJSON(map[string]string{"type": "e2ee_key", "payload": "all"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if msg := readUntilType(t, bob, "e2ee_key"); msg["payload"] != "all" {
+		t.Fatalf("broadcast = %v", msg)
+	}
+	if err := alice.WriteJSON(map[string]string{"type": "e2ee_key", "payload": strings.Repeat("x", maxE2EEPayloadBytes+1)}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	readUntilType(t, alice, "error")
+
+	// The server cannot mix encrypted audio, and the flag cannot change while occupied.
+	handler.FFmpegPath = "ffmpeg"
+	if err := handler.StartEgress("room-e2ee", "rtmp://example.com/live/key"); !errors.Is(err, errE2EERoom) {
+		t.Fatalf("StartEgress err = %v, want errE2EERoom", err)
+	}
+	if err := handler.RoomManager.SetE2EE("room-e2ee", false); !errors.Is(err, errRoomOccupied) {
+		t.Fatalf("SetE2EE err = %v, want errRoomOccupied", err)
+	}
+}
+
+func TestE2EEKeyRequiresE2EERoom(t *testing.T) {
+	_, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "room-plain", "alice")
+	if state := readUntilType(t, alice, "room_state"); state["e2ee"] != false {
+		t.Fatalf("room_state e2ee = %v, want false", state["e2ee"])
+	}
+	if err := alice.WriteJSON(map[string]string{"type": "e2ee_key", "payload": "k"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	readUntilType(t, alice, "error")
+}
+
+func TestAdminRoomE2EE(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	handler.RoomManager.GetOrCreateRoom("room-a")
+
+	post := func(query string) int {
+		return adminRequest(t, handler, http.MethodPost, "action=room_e2ee&"+query).Code
+	}
+	if code := post("room=missing&enabled=1"); code != http.StatusNotFound {
+		t.Fatalf("missing room: status = %d", code)
+	}
+	if code := post("room=room-a&enabled=1"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if !handler.RoomManager.Rooms["room-a"].IsE2EE() {
+		t.Fatal("expected room to be flagged")
+	}
+}
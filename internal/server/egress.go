@@ -0,0 +1,151 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+
+	"github.com/pion/rtp"
+
+	"sigmartc/internal/egress"
+	"sigmartc/internal/logger"
+)
+
+// egressTapID is the forwarder tap used to feed a room's egress mix.
+const egressTapID = "egress"
+
+var (
+	errRoomNotFound   = errors.New("room not found")
+	errEgressRunning  = errors.New("egress already running for this room")
+	errEgressDisabled = errors.New("egress is disabled")
+)
+
+// StartEgress mixes the room's audio and streams it to target (rtmp://, rtmps:// or icecast://).
+func (h *Handler) StartEgress(roomUUID, target string) error {
+	if h.FFmpegPath == "" {
+		return errEgressDisabled
+	}
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return errRoomNotFound
+	}
+	if room.IsE2EE() {
+		return errE2EERoom
+	}
+
+	room.egressMu.Lock()
+	defer room.egressMu.Unlock()
+	if room.egress != nil {
+		return errEgressRunning
+	}
+	session, err := egress.Start(egress.Config{FFmpegPath: h.FFmpegPath, Target: target})
+	if err != nil {
+		return err
+	}
+	room.egress = session
+
+	room.ForwardersMu.RLock()
+	for senderID, forwarder := range room.Forwarders {
+		forwarder.AddTap(egressTapID, egressTap(session, senderID, forwarder))
+	}
+	room.ForwardersMu.RUnlock()
+
+	logger.LogEvent("EGRESS_START", slog.String("uuid", roomUUID))
+	h.announceAsync(roomUUID, "This room is now being live streamed.")
+	go func() {
+		<-session.Done()
+		room.detachEgress(session)
+		if err := session.Err(); err != nil {
+			slog.Warn("Egress stopped unexpectedly", "uuid", roomUUID, "err", err)
+		}
+		logger.LogEvent("EGRESS_STOP", slog.String("uuid", roomUUID))
+	}()
+	return nil
+}
+
+// StopEgress stops the room's egress and reports whether one was running.
+func (h *Handler) StopEgress(roomUUID string) bool {
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return false
+	}
+	return room.stopEgress()
+}
+
+// stopEgress stops the room's egress, if any.
+func (r *Room) stopEgress() bool {
+	r.egressMu.Lock()
+	session := r.egress
+	r.egressMu.Unlock()
+	if session == nil {
+		return false
+	}
+	session.Stop()
+	r.detachEgress(session)
+	return true
+}
+
+// detachEgress clears session from the room and removes its forwarder taps.
+func (r *Room) detachEgress(session *egress.Session) {
+	r.egressMu.Lock()
+	defer r.egressMu.Unlock()
+	if r.egress != session {
+		return
+	}
+	r.egress = nil
+	r.ForwardersMu.RLock()
+	for _, forwarder := range r.Forwarders {
+		forwarder.RemoveTap(egressTapID)
+	}
+	r.ForwardersMu.RUnlock()
+}
+
+// attachEgress taps a newly published track into the room's running egress, HLS
+// and transcription.
+func (r *Room) attachEgress(senderID string, forwarder *TrackForwarder) {
+	r.egressMu.Lock()
+	defer r.egressMu.Unlock()
+	if r.egress != nil {
+		forwarder.AddTap(egressTapID, egressTap(r.egress, senderID, forwarder))
+	}
+	if r.hls != nil {
+		forwarder.AddTap(hlsTapID, egressTap(r.hls.session, senderID, forwarder))
+	}
+	r.attachTranscription(senderID, forwarder)
+}
+
+// rtpSink consumes plain Opus packets per publisher: an egress session or a transcription.
+type rtpSink interface {
+	WriteRTP(senderID string, packet *rtp.Packet)
+}
+
+// egressTap converts forwarded packets back to plain Opus for the mix.
+func egressTap(session rtpSink, senderID string, forwarder *TrackForwarder) func([]byte) {
+	return func(raw []byte) {
+		packet := &rtp.Packet{}
+		if err := packet.Unmarshal(raw); err != nil {
+			return
+		}
+		if forwarder.redPayloadType != 0 && packet.PayloadType == forwarder.redPayloadType {
+			primary, err := unwrapRED(packet.Payload)
+			if err != nil {
+				return
+			}
+			packet.Payload = primary
+		}
+		session.WriteRTP(senderID, packet)
+	}
+}
+
+// egressHost returns the host part of an egress URL so stream keys stay out of logs.
+func egressHost(target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestAdminEgressLifecycle(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for ffmpeg")
+	}
+	handler := newTestAdminHandler(t)
+	handler.FFmpegPath = filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(handler.FFmpegPath, []byte("#!/bin/sh\ncat >/dev/null\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	handler.RoomManager.GetOrCreateRoom("room-a")
+
+	post := func(query string) int {
+		return adminRequest(t, handler, http.MethodPost, query).Code
+	}
+
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"action=egress_start&room=missing&url=rtmp://live.example.com/app/key", http.StatusNotFound},
+		{"action=egress_start&room=room-a&url=http://example.com", http.StatusBadRequest},
+		{"action=egress_start&room=room-a&url=rtmp://live.example.com/app/key", http.StatusOK},
+		{"action=egress_start&room=room-a&url=rtmp://live.example.com/app/key", http.StatusConflict},
+		{"action=egress_stop&room=room-a", http.StatusOK},
+		{"action=egress_stop&room=room-a", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if got := post(tc.query); got != tc.want {
+			t.Fatalf("%s: status = %d, want %d", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestEgressHostOmitsStreamKey(t *testing.T) {
+	if got := egressHost("rtmp://live.example.com/app/secret-key"); got != "rtmp://live.example.com" {
+		t.Fatalf("egressHost = %q", got)
+	}
+}
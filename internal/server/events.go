@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lifecycle event types.
+const (
+	EventRoomCreate  = "room_create"
+	EventRoomDestroy = "room_destroy"
+	EventUserJoin    = "user_join"
+	EventUserLeave   = "user_leave"
+	EventBan         = "ban"
+	EventPeerMove    = "peer_move"
+	// EventQuotaExceeded fires once per month when a room passes its bandwidth quota.
+	EventQuotaExceeded = "quota_exceeded"
+)
+
+var eventSeq atomic.Uint64
+
+// Event is a structured lifecycle event shared by webhooks and the admin event stream.
+type Event struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	Timestamp time.Time      `json:"timestamp"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+func newEvent(eventType string, data map[string]any) Event {
+	return Event{
+		ID:        fmt.Sprintf("%d-%d", time.Now().UnixNano(), eventSeq.Add(1)),
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// EventHub fans events out to in-process subscribers such as admin event streams.
+// Slow subscribers miss events instead of blocking publishers.
+type EventHub struct {
+	mu   sync.RWMutex
+	subs map[chan Event]struct{}
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subs: make(map[chan Event]struct{})}
+}
+
+// Publish delivers the event to every subscriber. It is safe to call on a nil hub.
+func (h *EventHub) Publish(event Event) {
+	if h == nil {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+			slog.Debug("Event subscriber lagging, dropping event", "type", event.Type)
+		}
+	}
+}
+
+// Subscribe registers a buffered channel and returns it with an unsubscribe func.
+func (h *EventHub) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// SubscriberCount returns the number of active subscribers.
+func (h *EventHub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/internal/egress"
+	"sigmartc/internal/soundboard"
+)
+
+// Per-listener gain: a receiver may set how loud it hears each publisher with
+// { "type": "gain", "peer_id": "<sender>", "value": 0.5 } for clients whose
+// audio APIs cannot scale a stream. Opus cannot be scaled without decoding, so
+// by default the server only gates: 0 drops the publisher entirely and values
+// below 1 drop packets whose RFC 6464 audio level is quieter than a threshold
+// that rises as the gain falls, leaving speech and cutting background noise.
+// With Handler.ServerGain each adjusted (receiver, publisher) pair instead gets
+// an ffmpeg process that decodes, scales and re-encodes the audio, which also
+// allows amplifying up to maxGain at the cost of CPU and ~20-40ms of latency.
+
+// maxGain is the largest accepted gain; 1 leaves audio untouched.
+const maxGain = 2
+
+var errInvalidGain = errors.New("gain needs a peer_id and a value between 0 and 2")
+
+// gainFor returns the peer's gain for senderID's audio.
+func (p *Peer) gainFor(senderID string) float64 {
+	p.gainsMu.Lock()
+	defer p.gainsMu.Unlock()
+	if gain, ok := p.gains[senderID]; ok {
+		return gain
+	}
+	return 1
+}
+
+func (h *Handler) handleGain(room *Room, peer *Peer, msg map[string]any) {
+	senderID, _ := msg["peer_id"].(string)
+	value, ok := msg["value"].(float64)
+	if senderID == "" || senderID == peer.ID || !ok || math.IsNaN(value) || value < 0 || value > maxGain {
+		peer.WriteJSON(map[string]string{"type": "error", "message": errInvalidGain.Error()})
+		return
+	}
+	peer.gainsMu.Lock()
+	if value == 1 {
+		delete(peer.gains, senderID)
+	} else {
+		if peer.gains == nil {
+			peer.gains = make(map[string]float64)
+		}
+		peer.gains[senderID] = value
+	}
+	peer.gainsMu.Unlock()
+
+	room.ForwardersMu.RLock()
+	forwarder := room.Forwarders[senderID]
+	room.ForwardersMu.RUnlock()
+	if forwarder != nil {
+		h.applyGain(peer, senderID, forwarder)
+	}
+}
+
+// applyGain installs the receiver's gain for senderID on the forwarder that
+// carries senderID's audio.
+func (h *Handler) applyGain(receiver *Peer, senderID string, forwarder *TrackForwarder) {
+	gain := receiver.gainFor(senderID)
+	if gain == 1 {
+		forwarder.setGain(receiver.ID, nil)
+		return
+	}
+	if gain > 0 && h.ServerGain && h.FFmpegPath != "" && strings.EqualFold(forwarder.Codec().MimeType, webrtc.MimeTypeOpus) {
+		receiver.OutTracksMu.RLock()
+		track := receiver.OutTracks[senderID]
+		receiver.OutTracksMu.RUnlock()
+		if track != nil {
+			stage, err := h.newVolumeStage(forwarder, gain, track)
+			if err == nil {
+				forwarder.setGain(receiver.ID, stage)
+				return
+			}
+			receiver.log().Warn("Server-side gain failed to start, gating instead", "sender_id", senderID, "err", err)
+		}
+	}
+	if gain >= 1 {
+		// Gating cannot make audio louder.
+		forwarder.setGain(receiver.ID, nil)
+		return
+	}
+	forwarder.setGain(receiver.ID, newLevelGate(gain, forwarder.levelExtensionID()))
+}
+
+// gainStage writes a receiver's copy of a publisher's audio at the receiver's
+// gain in place of the forwarder's direct write.
+type gainStage interface {
+	// write sends packet to track, or reports false if it was held back.
+	write(packet []byte, track *webrtc.TrackLocalStaticRTP) (bool, error)
+	close()
+}
+
+// levelGate drops packets below an audio level; mute drops every packet.
+type levelGate struct {
+	mute     bool
+	extID    uint8
+	quietest uint8 // in -dBov: larger is quieter
+}
+
+func newLevelGate(gain float64, extID uint8) *levelGate {
+	return &levelGate{
+		mute:     gain == 0,
+		extID:    extID,
+		quietest: speechLevelThreshold + uint8(float64(127-speechLevelThreshold)*gain),
+	}
+}
+
+func (g *levelGate) write(packet []byte, track *webrtc.TrackLocalStaticRTP) (bool, error) {
+	if g.mute {
+		return false, nil
+	}
+	if g.extID != 0 {
+		var header rtp.Header
+		if _, err := header.Unmarshal(packet); err == nil {
+			var level rtp.AudioLevelExtension
+			if ext := header.GetExtension(g.extID); ext != nil && level.Unmarshal(ext) == nil && level.Level > g.quietest {
+				return false, nil
+			}
+		}
+	}
+	_, err := track.Write(packet)
+	return err == nil, err
+}
+
+func (g *levelGate) close() {}
+
+// volumeStage scales a receiver's copy with ffmpeg and writes the result to
+// the receiver's track itself.
+type volumeStage struct {
+	*reencodeStage
+}
+
+// newVolumeStage starts a volume stage that closes when forwarder stops.
+func (h *Handler) newVolumeStage(forwarder *TrackForwarder, gain float64, track *webrtc.TrackLocalStaticRTP) (*volumeStage, error) {
+	stage, err := newReencodeStage(forwarder.ctx, func(onPacket func(soundboard.Packet)) (*egress.Reencoder, error) {
+		return egress.NewVolume(h.FFmpegPath, gain, onPacket)
+	}, func(packet *rtp.Packet) {
+		_ = track.WriteRTP(packet)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &volumeStage{stage}, nil
+}
+
+func (s *volumeStage) write(packet []byte, _ *webrtc.TrackLocalStaticRTP) (bool, error) {
+	s.feed(packet)
+	return true, nil
+}
+
+// writeSubscriber writes packet to a subscriber's track, through its gain
+// stage if it has one, and reports whether the packet went out.
+func writeSubscriber(packet []byte, track *webrtc.TrackLocalStaticRTP, gain gainStage) (bool, error) {
+	if gain != nil {
+		return gain.write(packet, track)
+	}
+	_, err := track.Write(packet)
+	return err == nil, err
+}
+
+// levelExtensionID returns the audio level extension ID of the current source.
+func (f *TrackForwarder) levelExtensionID() uint8 {
+	f.processMu.Lock()
+	defer f.processMu.Unlock()
+	return f.audioLevelExtID
+}
+
+// setGain makes stage carry receiverID's audio (nil writes it directly again)
+// on this forwarder and its low layer, and closes the stage it replaces.
+func (f *TrackForwarder) setGain(receiverID string, stage gainStage) {
+	f.mu.Lock()
+	previous := f.gains[receiverID]
+	f.putGain(receiverID, stage)
+	low := f.low
+	f.mu.Unlock()
+	if low != nil {
+		low.mu.Lock()
+		low.putGain(receiverID, stage)
+		low.mu.Unlock()
+	}
+	if previous != nil && previous != stage {
+		previous.close()
+	}
+}
+
+// putGain records stage for receiverID. Callers hold f.mu.
+func (f *TrackForwarder) putGain(receiverID string, stage gainStage) {
+	if stage == nil {
+		delete(f.gains, receiverID)
+		return
+	}
+	if f.gains == nil {
+		f.gains = make(map[string]gainStage)
+	}
+	f.gains[receiverID] = stage
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+func levelPacket(t *testing.T, level uint8) []byte {
+	t.Helper()
+	ext, err := rtp.AudioLevelExtension{Level: level, Voice: true}.Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal audio level: %v", err)
+	}
+	pkt := &rtp.Packet{Header: rtp.Header{Version: 2}, Payload: []byte{0x78, 0x01}}
+	if err := pkt.SetExtension(1, ext); err != nil {
+		t.Fatalf("failed to set extension: %v", err)
+	}
+	raw, err := pkt.Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal packet: %v", err)
+	}
+	return raw
+}
+
+func TestLevelGateDropsQuietPackets(t *testing.T) {
+	track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "sender")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gate := newLevelGate(0.5, 1)
+	for _, tc := range []struct {
+		level uint8
+		sent  bool
+	}{
+		{level: 20, sent: true},   // speech
+		{level: 70, sent: true},   // quiet, above the gate
+		{level: 110, sent: false}, // background noise
+	} {
+		sent, err := gate.write(levelPacket(t, tc.level), track)
+		if err != nil || sent != tc.sent {
+			t.Fatalf("level %d: sent = %v (%v), want %v", tc.level, sent, err, tc.sent)
+		}
+	}
+	if sent, _ := newLevelGate(0, 1).write(levelPacket(t, 0), track); sent {
+		t.Fatal("gain 0 let a packet through")
+	}
+}
+
+func TestGainMessageGatesReceiversCopy(t *testing.T) {
+	h, room, receiver := newFuzzPeer(t)
+	receiver.outbox = make(chan wsFrame, 1)
+	publisher := &Peer{ID: "publisher", Name: "pub", JoinTime: time.Now()}
+	room.Lock.Lock()
+	room.Peers[publisher.ID] = publisher
+	room.Lock.Unlock()
+	publisher.setRoom(room)
+
+	forwarder := newSyntheticForwarder(context.Background(), publisher.ID, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2})
+	forwarder.audioLevelExtID = 1
+	room.ForwardersMu.Lock()
+	room.Forwarders[publisher.ID] = forwarder
+	room.ForwardersMu.Unlock()
+	h.subscribeToForwarder(receiver, publisher.ID, forwarder)
+	var delivered []string
+	forwarder.onForward = func(receiverIDs []string, _ int) { delivered = receiverIDs }
+
+	h.handleGain(room, receiver, map[string]any{"type": "gain", "peer_id": publisher.ID, "value": 0.0})
+	delivered = nil
+	forwarder.fanOut(levelPacket(t, 20))
+	if len(delivered) != 0 {
+		t.Fatalf("muted receiver got audio: %v", delivered)
+	}
+
+	h.handleGain(room, receiver, map[string]any{"type": "gain", "peer_id": publisher.ID, "value": 1.0})
+	forwarder.fanOut(levelPacket(t, 20))
+	if len(delivered) != 1 || delivered[0] != receiver.ID {
+		t.Fatalf("delivered = %v after restoring gain, want the receiver", delivered)
+	}
+
+	h.handleGain(room, receiver, map[string]any{"type": "gain", "peer_id": publisher.ID, "value": 3.0})
+	var reply map[string]string
+	if err := json.Unmarshal((<-receiver.outbox).data, &reply); err != nil || reply["type"] != "error" {
+		t.Fatalf("reply to out-of-range gain = %v (%v), want an error", reply, err)
+	}
+}
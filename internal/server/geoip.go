@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP resolves client IPs to ISO country codes with a MaxMind GeoLite2/GeoIP2
+// Country (or City) database and applies country allow/deny lists at join.
+type GeoIP struct {
+	db *maxminddb.Reader
+	// Allow, when non-empty, admits only these countries; IPs the database does
+	// not know (including private addresses) are then refused too.
+	Allow map[string]bool
+	// Deny refuses these countries.
+	Deny map[string]bool
+}
+
+// OpenGeoIP opens a MaxMind database file.
+func OpenGeoIP(path string) (*GeoIP, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIP{db: db}, nil
+}
+
+// ParseCountryList turns "US, ca" into {"US": true, "CA": true}.
+func ParseCountryList(s string) map[string]bool {
+	countries := make(map[string]bool)
+	for _, code := range strings.Split(s, ",") {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			countries[code] = true
+		}
+	}
+	return countries
+}
+
+// Country returns the ISO 3166-1 alpha-2 code for ip, or "" if unknown. A nil
+// GeoIP knows nothing.
+func (g *GeoIP) Country(ip string) string {
+	if g == nil || g.db == nil {
+		return ""
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := g.db.Lookup(parsed, &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+// Allowed reports whether a peer from country may join. A nil GeoIP allows everyone.
+func (g *GeoIP) Allowed(country string) bool {
+	if g == nil {
+		return true
+	}
+	if g.Deny[country] {
+		return false
+	}
+	return len(g.Allow) == 0 || g.Allow[country]
+}
+
+// Close releases the database.
+func (g *GeoIP) Close() error {
+	if g == nil || g.db == nil {
+		return nil
+	}
+	return g.db.Close()
+}
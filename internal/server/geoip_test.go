@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeoIPAllowedRules(t *testing.T) {
+	var disabled *GeoIP
+	if disabled.Country("192.0.2.1") != "" || !disabled.Allowed("") {
+		t.Fatal("a nil GeoIP should know nothing and allow everyone")
+	}
+
+	geo := &GeoIP{Deny: ParseCountryList("cn, RU")}
+	if geo.Allowed("RU") || !geo.Allowed("US") || !geo.Allowed("") {
+		t.Fatal("deny list should refuse only listed countries")
+	}
+
+	geo = &GeoIP{Allow: ParseCountryList("US,CA"), Deny: ParseCountryList("CA")}
+	if !geo.Allowed("US") || geo.Allowed("CA") || geo.Allowed("DE") || geo.Allowed("") {
+		t.Fatal("allow list should admit only listed, non-denied countries")
+	}
+}
+
+func TestGeoBlockedJoinIsRefused(t *testing.T) {
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), nil, nil)
+	// Without a database every country is unknown, which an allow list refuses.
+	handler.GeoIP = &GeoIP{Allow: ParseCountryList("US")}
+
+	rec := httptest.NewRecorder()
+	handler.HandleWS(rec, httptest.NewRequest(http.MethodGet, "/ws?room=a&name=bob", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
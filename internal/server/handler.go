@@ -1,22 +1,29 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/pion/webrtc/v3"
+	"sigmartc/internal/eventdb"
 	"sigmartc/internal/logger"
+	"sigmartc/internal/stt"
+	"sigmartc/internal/tts"
 )
 
 const (
@@ -25,91 +32,199 @@ const (
 	wsWriteWait       = 5 * time.Second
 	wsPongWait        = 60 * time.Second
 	wsPingInterval    = 30 * time.Second
-	iceRestartDelay   = 5 * time.Second
-	iceRestartMin     = 15 * time.Second
 	heartbeatInterval = 5 * time.Second
 	heartbeatTimeout  = 15 * time.Second
+	// negotiationRetryDelay backs off after a failed CreateOffer/SetLocalDescription.
+	negotiationRetryDelay = 100 * time.Millisecond
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: checkWSOrigin,
-}
-
 type Handler struct {
 	RoomManager *RoomManager
 	// Webrtc API with custom settings if needed
 	WebRTCAPI *webrtc.API
 	// Optional ICE config override (useful for tests).
 	ICEConfig *webrtc.Configuration
+	// Negotiation is the ICE restart policy; NewHandler fills unset fields with defaults.
+	Negotiation NegotiationConfig
+	// CandidateFilter limits the server ICE candidates sent to clients. The zero
+	// value sends them all.
+	CandidateFilter CandidateFilter
+	// ForceRelay makes every room relay-only (see relay.go); ICEConfig needs a
+	// TURN server.
+	ForceRelay bool
+	// TrustedProxies may report the client IP, host and scheme in X-Forwarded-*
+	// and X-Real-IP headers; NewHandler sets DefaultTrustedProxies.
+	TrustedProxies TrustedProxies
+	// AllowedOrigins may open WebSockets in addition to the server's own origin.
+	AllowedOrigins AllowedOrigins
+	// AdminSessions holds session tokens issued by /admin/login.
+	AdminSessions *SessionStore
+	// AdminTemplate is the path of the admin page template.
+	AdminTemplate string
+	// Audit records admin actions. Nil disables auditing.
+	Audit *AuditLog
+	// EventDB, when set, answers admin log queries filtered by event from the
+	// persistent event store instead of the in-memory ring.
+	EventDB *eventdb.DB
+	// Canary, when set, has its latest end-to-end check exported on /metrics.
+	Canary *Canary
+	// IdleTimeout disconnects peers that publish no RTP and send no signaling
+	// (beyond heartbeats) for this long. Zero disables the check.
+	IdleTimeout time.Duration
+	// JitterBuffer reorders out-of-order RTP for up to this long before fan-out.
+	// Zero forwards packets as they arrive.
+	JitterBuffer time.Duration
+	// SkipSilence stops forwarding silent audio (zero audio level or Opus DTX)
+	// except for a comfort-noise packet every comfortNoiseInterval.
+	SkipSilence bool
+	// MaxPublishBitrate caps each publisher's inbound audio in kbps unless the
+	// room sets its own cap. Zero disables the cap.
+	MaxPublishBitrate int
+	// FFmpegPath is the ffmpeg binary used for room egress. Empty disables egress.
+	FFmpegPath string
+	// ServerGain applies listeners' per-publisher gain by re-encoding audio with
+	// FFmpegPath instead of level gating (see gain.go). CPU-intensive.
+	ServerGain bool
+	// DenoiseModel is the RNNoise model ffmpeg's arnndn filter loads for peers
+	// with noise suppression on (see denoise.go). Empty disables it.
+	DenoiseModel string
+	// HLSDir holds per-room HLS segments served under /hls/. Empty disables HLS.
+	HLSDir string
+	// SoundboardDir holds Ogg Opus clips that admins can play into rooms. Empty disables it.
+	SoundboardDir string
+	// TTS speaks announcements into rooms. Nil disables announcements.
+	TTS tts.Synthesizer
+	// STT transcribes rooms on request (see transcribe.go); it needs FFmpegPath
+	// to decode audio. Nil disables transcription.
+	STT stt.Transcriber
+	// NicknameFilter rejects or masks denied nicknames at join. Nil allows any name.
+	NicknameFilter *NicknameFilter
+	// GeoIP tags peers with their country and enforces country allow/deny lists.
+	// Nil disables lookups.
+	GeoIP *GeoIP
+	// Challenge, when set, must be passed before a join is upgraded (nil disables).
+	Challenge JoinChallenge
+	// JWTAuth, when set, requires joins to carry a token from the identity
+	// provider; its name claim replaces the name parameter.
+	JWTAuth *JWTAuth
+	// MaxPeersPerIP caps how many peers from one IP may be in a room at once, so
+	// one client cannot fill a room with ghost connections. Zero is unlimited.
+	MaxPeersPerIP int
+	// Chaos allows admins to impair forwarded audio (see impairment.go). Never
+	// enable it in production.
+	Chaos bool
+	// ICEAddresses are the addresses the ICE UDP mux listens on, reported by
+	// /readyz. Empty reports the server as not ready.
+	ICEAddresses []net.Addr
+	// LoadCapacity is what the node is sized for, scoring /api/load (see load.go).
+	LoadCapacity LoadCapacity
+	// Locator finds the node hosting a room in a cluster, and PublicURL is this
+	// node's base URL as the locator reports it. WebSocket joins for rooms
+	// hosted elsewhere are redirected (see locator.go); nil serves every room here.
+	Locator   RoomLocator
+	PublicURL string
+	// LoadSettings reads the settings a reload applies (see reload.go); nil
+	// reloads only the ban list. MaxPeersPerIP, MaxPublishBitrate, ICEConfig,
+	// NicknameFilter and GeoIP may only change through a reload once serving.
+	LoadSettings func() (Settings, error)
+
+	upgrader   websocket.Upgrader
+	polls      pollSessions
+	impairment atomic.Pointer[Impairment]
+	load       loadSampler
+	// settingsMu guards the fields a reload changes (see reload.go).
+	settingsMu sync.RWMutex
 }
 
-func NewHandler(rm *RoomManager, api *webrtc.API, iceConfig *webrtc.Configuration) *Handler {
+// NewHandler creates a handler. A nil negotiation config uses
+// DefaultNegotiationConfig; when api is nil one is built with its ICE timeouts.
+func NewHandler(rm *RoomManager, api *webrtc.API, iceConfig *webrtc.Configuration, negotiation *NegotiationConfig) *Handler {
+	var policy NegotiationConfig
+	if negotiation != nil {
+		policy = *negotiation
+	}
+	policy = policy.withDefaults()
+
 	if api == nil {
-		m := &webrtc.MediaEngine{}
-		if err := m.RegisterDefaultCodecs(); err != nil {
+		m, err := NewMediaEngine()
+		if err != nil {
 			panic(err)
 		}
+		settings := webrtc.SettingEngine{}
+		policy.ApplyTo(&settings)
 		// Add custom interceptors or settings here if needed (e.g. NACKs)
-		api = webrtc.NewAPI(webrtc.WithMediaEngine(m))
+		api = webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithSettingEngine(settings))
 	}
 
-	return &Handler{
-		RoomManager: rm,
-		WebRTCAPI:   api,
-		ICEConfig:   iceConfig,
+	h := &Handler{
+		RoomManager:    rm,
+		WebRTCAPI:      api,
+		ICEConfig:      iceConfig,
+		Negotiation:    policy,
+		TrustedProxies: mustParseTrustedProxies(DefaultTrustedProxies),
+		AdminSessions:  NewSessionStore(adminSessionTTL),
+		AdminTemplate:  "web/templates/admin.html",
 	}
+	h.upgrader = websocket.Upgrader{
+		CheckOrigin: h.checkWSOrigin,
+		// permessage-deflate is used when the client offers it; browsers always do.
+		EnableCompression: true,
+	}
+	return h
+}
+
+// joinRequest is a join that passed the checks made before the signaling
+// connection is accepted.
+type joinRequest struct {
+	room      string
+	nickname  string
+	identity  *Identity
+	ip        string
+	country   string
+	hostToken string
+	deviceID  string
+	takeover  bool
+	e2ee      bool
 }
 
 func (h *Handler) HandleWS(w http.ResponseWriter, r *http.Request) {
-	roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
-	nickname, err := normalizeNickname(r.URL.Query().Get("name"))
-	if roomUUID == "" || err != nil {
-		http.Error(w, "Invalid room or name", http.StatusBadRequest)
+	if node := h.remoteNode(r); node != "" {
+		h.redirectJoin(w, r, node)
 		return
 	}
-
-	ip := clientIP(r)
-
-	if h.RoomManager.IsBanned(ip) {
-		http.Error(w, "Banned", http.StatusForbidden)
+	join, reason, message := h.checkJoin(r)
+	if reason != "" {
+		h.rejectJoin(w, r, reason, message)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	sessionID := h.requestID(r)
+	conn, err := h.upgrader.Upgrade(w, r, http.Header{requestIDHeader: {sessionID}})
 	if err != nil {
-		slog.Error("WS Upgrade failed", "err", err)
+		slog.Error("WS Upgrade failed", "session_id", sessionID, "err", err)
 		return
 	}
 
-	peerID := uuid.New().String()
-	peer := &Peer{
-		ID:       peerID,
-		Name:     nickname,
-		IP:       ip,
-		Conn:     conn,
-		JoinTime: time.Now(),
-		Done:     make(chan struct{}),
-	}
-
+	conn.SetReadLimit(maxSignalingMessageBytes)
 	conn.SetReadDeadline(time.Now().Add(wsPongWait))
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(wsPongWait))
 		return nil
 	})
-	pingTicker := time.NewTicker(wsPingInterval)
-	defer pingTicker.Stop()
+	// A failed ping closes the socket, which ends the session's read loop.
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
+		defer recoverGoroutine("ws ping")
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
 		for {
 			select {
-			case <-peer.Done:
+			case <-done:
 				return
-			case <-pingTicker.C:
-				peer.WsMutex.Lock()
-				err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(wsWriteWait))
-				peer.WsMutex.Unlock()
-				if err != nil {
-					slog.Warn("WS ping failed", "peer_id", peer.ID, "err", err)
-					peer.SignalDone()
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(wsWriteWait)); err != nil {
+					slog.Warn("WS ping failed", "session_id", sessionID, "err", err)
 					_ = conn.Close()
 					return
 				}
@@ -117,96 +232,265 @@ func (h *Handler) HandleWS(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	room := h.RoomManager.GetOrCreateRoom(roomUUID)
+	h.serveSession(r.Context(), join, sessionID, wsConn{conn})
+}
 
-	// Check capacity
-	room.Lock.Lock()
-	if len(room.Peers) >= maxRoomPeers {
-		room.Lock.Unlock()
-		peer.WriteJSON(map[string]string{"type": "error", "message": "Room full"})
-		conn.Close()
-		return
+// checkJoin runs the checks that can refuse a join before its signaling
+// connection is accepted, and returns the join or why it was refused.
+func (h *Handler) checkJoin(r *http.Request) (*joinRequest, DisconnectReason, string) {
+	roomUUID := strings.TrimSpace(r.URL.Query().Get("room"))
+	var identity *Identity
+	if h.JWTAuth != nil {
+		var err error
+		if identity, err = h.JWTAuth.Authenticate(r.Context(), r); err != nil {
+			slog.Info("Join refused without a valid token", "ip", h.clientIP(r), "err", err)
+			return nil, DisconnectUnauthorized, "Sign in to join"
+		}
+	}
+	rawName := r.URL.Query().Get("name")
+	if identity != nil && identity.Name != "" {
+		rawName = identity.Name
+	}
+	nickname, err := normalizeNickname(rawName, h.nicknameFilter())
+	if roomUUID == "" || err != nil {
+		return nil, DisconnectInvalidName, "Invalid room or name"
+	}
+	roomUUID, tenant, ok := h.RoomManager.Tenants.joinRoom(r.URL.Query().Get("tenant"), roomUUID)
+	if !ok {
+		return nil, DisconnectInvalidName, "Invalid room or tenant"
 	}
-	room.Peers[peerID] = peer
-	room.Lock.Unlock()
 
-	logger.LogEvent("USER_JOIN", slog.String("uuid", roomUUID), slog.String("ip", ip), slog.String("name", nickname), slog.String("peer_id", peerID))
+	ip := h.clientIP(r)
 
-	// Cleanup on exit
-	defer func() {
-		peer.SignalDone()
-		// Unsubscribe this peer from all forwarders (so they stop sending to this peer)
-		room.ForwardersMu.RLock()
-		for _, forwarder := range room.Forwarders {
-			forwarder.Unsubscribe(peerID)
-		}
-		room.ForwardersMu.RUnlock()
+	if h.RoomManager.IsBanned(ip) || (tenant != nil && h.RoomManager.IsBanned(tenant.key(ip))) {
+		return nil, DisconnectBanned, "Banned"
+	}
+	geo := h.geoIP()
+	country := geo.Country(ip)
+	if !geo.Allowed(country) {
+		slog.Info("Join refused by country", "ip", ip, "country", country)
+		return nil, DisconnectGeoBlocked, "Joining from your region is not allowed"
+	}
+	if h.RoomManager.Quota.blocksJoin(roomUUID, time.Now()) {
+		return nil, DisconnectQuotaExceeded, "This room has used its bandwidth for the month"
+	}
+	if reason, message := h.RoomManager.quotaAdmission(roomUUID, tenant, false, time.Now()); reason != "" {
+		return nil, reason, message
+	}
 
-		// Stop and remove this peer's own forwarder if they were sending audio
-		room.ForwardersMu.Lock()
-		if forwarder, exists := room.Forwarders[peerID]; exists {
-			forwarder.Stop()
-			delete(room.Forwarders, peerID)
+	// Refuse before upgrading when the room already turns this peer away; the check
+	// is repeated under the room lock once the peer is admitted.
+	hostToken := r.URL.Query().Get("host_token")
+	deviceID := joinDeviceID(r)
+	takeover := r.URL.Query().Get("takeover") == "1"
+	h.RoomManager.Lock.RLock()
+	existing := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if existing == nil && h.RoomManager.roomLimitReached(tenant) {
+		return nil, DisconnectTenantLimit, "No more rooms can be opened right now"
+	}
+	if existing != nil {
+		existing.Lock.RLock()
+		var reason DisconnectReason
+		var message string
+		// A takeover frees the old session's slot, so it is only admitted under the
+		// room lock below.
+		switch duplicate := existing.peerByDevice(deviceID); {
+		case duplicate != nil && !takeover:
+			reason, message = DisconnectDuplicateSession, "Already in this room in another tab"
+		case duplicate == nil:
+			reason, message = existing.admission(joinRole(existing.Schedule.isHost(hostToken), identity), time.Now())
+			if reason == "" {
+				reason, message = h.ipAdmission(existing, ip)
+			}
 		}
-		room.ForwardersMu.Unlock()
-
-		room.Lock.Lock()
-		delete(room.Peers, peerID)
-		if len(room.Peers) == 0 {
-			room.LastEmptyTime = time.Now()
+		existing.Lock.RUnlock()
+		if reason != "" {
+			return nil, reason, message
 		}
+	}
+	// Redeem the challenge last: a proof is single-use, so a client turned away
+	// for a full or locked room keeps it for the retry.
+	if h.Challenge != nil {
+		if err := h.Challenge.Verify(r.Context(), r, ip); err != nil {
+			slog.Info("Join challenge failed", "ip", ip, "err", err)
+			return nil, DisconnectChallengeFailed, "Verification failed, please try again"
+		}
+	}
+	return &joinRequest{
+		room:      roomUUID,
+		nickname:  nickname,
+		identity:  identity,
+		ip:        ip,
+		country:   country,
+		hostToken: hostToken,
+		deviceID:  deviceID,
+		takeover:  takeover,
+		e2ee:      r.URL.Query().Get("e2ee") == "1",
+	}, "", ""
+}
+
+// serveSession admits the peer joining over conn into its room and serves its
+// signaling until conn closes. The session ends with parent.
+func (h *Handler) serveSession(parent context.Context, join *joinRequest, sessionID string, conn SignalingConn) {
+	// The session context ends when the peer is closed; everything serving the
+	// peer, down to the forwarders of its audio, stops with it.
+	ctx, cancel := context.WithCancel(parent)
+	peerID := uuid.New().String()
+	peer := &Peer{
+		ID:        peerID,
+		SessionID: sessionID,
+		logger:    slog.With("peer_id", peerID, "session_id", sessionID),
+		Name:      join.nickname,
+		IP:        join.ip,
+		Country:   join.country,
+		Identity:  join.identity,
+		DeviceID:  join.deviceID,
+		Conn:      conn,
+		JoinTime:  time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+		manager:   h.RoomManager,
+	}
+	peer.startWriter()
+
+	room := h.RoomManager.GetOrCreateRoom(join.room)
+
+	// Check schedule, lock and capacity
+	room.Lock.Lock()
+	peer.Role = joinRole(room.Schedule.isHost(join.hostToken), join.identity)
+	replaced := room.peerByDevice(join.deviceID)
+	if replaced != nil && !join.takeover {
 		room.Lock.Unlock()
-		conn.Close()
-		if peer.PC != nil {
-			peer.PC.Close()
+		peer.Disconnect(DisconnectDuplicateSession, "Already in this room in another tab")
+		return
+	}
+	if replaced != nil {
+		// Free the slot now; the old session's cleanup runs when its socket closes.
+		room.removePeer(replaced.ID)
+	}
+	reason, message := room.admission(peer.Role, time.Now())
+	if reason == "" {
+		reason, message = h.ipAdmission(room, join.ip)
+	}
+	if reason != "" {
+		if replaced != nil {
+			room.addPeer(replaced)
 		}
-		logger.LogEvent("USER_LEAVE", slog.String("uuid", roomUUID), slog.String("peer_id", peerID))
+		room.Lock.Unlock()
+		peer.Disconnect(reason, message)
+		return
+	}
+	// The peer that opens an empty room may flag it end-to-end encrypted.
+	if len(room.Peers) == 0 && join.e2ee {
+		room.E2EE = true
+	}
+	room.addPeer(peer)
+	room.usageJoin(peerID, time.Now())
+	// Set the room and state before the peer can be found in it, so a kick that
+	// races the join still cleans it up.
+	peer.setRoom(room)
+	peer.advance(PeerActive)
+	room.Lock.Unlock()
+	if replaced != nil {
+		peer.log().Info("Session taken over", "uuid", join.room, "old_peer_id", replaced.ID, "old_session_id", replaced.SessionID)
+		go replaced.Disconnect(DisconnectSessionTakeover, "This session continued in another tab")
+	}
 
-		// Notify others
-		room.Broadcast(peerID, map[string]any{
-			"type":    "peer_leave",
-			"peer_id": peerID,
-		})
-	}()
+	peer.note(TimelineJoined, "room "+join.room)
+	logger.LogEvent("USER_JOIN", slog.String("uuid", join.room), slog.String("ip", join.ip), slog.String("country", join.country), slog.String("name", join.nickname), slog.String("peer_id", peerID), slog.String("session_id", sessionID), slog.String("user_id", peer.UserID()))
+	h.RoomManager.emit(EventUserJoin, map[string]any{"room": join.room, "peer_id": peerID, "name": join.nickname})
+
+	// A socket that fails or closes ends the session the same way a kick does.
+	defer peer.Close("")
+
+	if h.IdleTimeout > 0 {
+		go h.watchIdle(ctx, peer)
+	}
 
 	// Initial signaling state: Tell the user their ID and current room peers
 	h.sendRoomState(room, peer)
 
 	// WebRTC Setup
-	if err := h.setupWebRTC(room, peer); err != nil {
-		peer.WriteJSON(map[string]string{"type": "error", "message": "WebRTC setup failed"})
+	if err := h.setupWebRTC(ctx, room, peer); err != nil {
+		peer.log().Error("WebRTC setup failed", "err", err)
+		peer.Disconnect(DisconnectServerError, "WebRTC setup failed")
+		return
+	}
+	if peer.State() >= PeerLeaving {
+		// Closed while the peer connection was being created.
+		_ = peer.PC.Close()
 		return
 	}
+	// These read peer.PC, so they start only once setupWebRTC has set it.
+	go h.sampleStats(ctx, peer)
+	if h.Negotiation.ICECredentialRefreshInterval > 0 {
+		go h.refreshICECredentials(peer)
+	}
 	h.addExistingTracks(room, peer)
 
-	// Signaling loop
+	// Signaling loop: messages are handled in order on the peer's worker.
+	signaling := newSignalingQueue()
+	go h.runSignalingWorker(ctx, peer, signaling)
 	for {
-		_, message, err := conn.ReadMessage()
+		messageType, message, err := conn.ReadMessage()
 		if err != nil {
 			var closeErr *websocket.CloseError
+			var netErr net.Error
 			switch {
 			case errors.As(err, &closeErr):
-				slog.Info("WebSocket closed", "peer_id", peer.ID, "code", closeErr.Code, "reason", closeErr.Text)
-			case errors.Is(err, net.ErrClosed):
-				slog.Info("WebSocket closed", "peer_id", peer.ID, "err", err)
+				peer.log().Info("Signaling connection closed", "code", closeErr.Code, "reason", closeErr.Text)
+			case errors.Is(err, net.ErrClosed), errors.Is(err, io.EOF):
+				peer.log().Info("Signaling connection closed", "err", err)
+			case errors.As(err, &netErr) && netErr.Timeout():
+				// The client stopped answering pings; it may still read a close frame.
+				peer.log().Warn("Signaling read timeout", "err", err)
+				peer.sendClose(closeFrame{code: websocket.CloseGoingAway, text: "timeout"})
 			default:
-				var netErr net.Error
-				if errors.As(err, &netErr) && netErr.Timeout() {
-					slog.Warn("WebSocket read timeout", "peer_id", peer.ID, "err", err)
-				} else {
-					slog.Warn("WebSocket read failed", "peer_id", peer.ID, "err", err)
-				}
+				peer.log().Warn("Signaling read failed", "err", err)
 			}
+			// The client closed the connection or it broke; the websocket library
+			// already answered any close frame or protocol error.
+			peer.closeSent.Store(true)
 			break
 		}
-		conn.SetReadDeadline(time.Now().Add(wsPongWait))
 
-		var msg map[string]interface{}
-		if err := json.Unmarshal(message, &msg); err != nil {
+		msg, err := parseSignalingMessage(message, messageType == websocket.BinaryMessage)
+		if err != nil {
+			peer.log().Warn("Rejected signaling message", "bytes", len(message), "err", err)
+			peer.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
+			continue
+		}
+
+		if msg["type"] == "heartbeat" {
 			continue
 		}
+		h.enqueueSignaling(peer, signaling, msg)
+	}
+}
 
-		h.handleSignalingMessage(room, peer, msg)
+// watchIdle disconnects the peer once it has been idle for longer than IdleTimeout.
+func (h *Handler) watchIdle(ctx context.Context, peer *Peer) {
+	interval := h.IdleTimeout / 4
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if idle := peer.IdleFor(); idle >= h.IdleTimeout {
+				peer.log().Info("Disconnecting idle peer", "idle", idle.String())
+				peer.Disconnect(DisconnectIdle, "Disconnected after a period of inactivity")
+				return
+			}
+		}
 	}
 }
 
@@ -214,45 +498,95 @@ func (h *Handler) sendRoomState(room *Room, peer *Peer) {
 	room.Lock.RLock()
 	peersInfo := make([]map[string]any, 0, len(room.Peers))
 	for _, p := range room.Peers {
-		peersInfo = append(peersInfo, map[string]any{
+		info := map[string]any{
 			"id":   p.ID,
 			"name": p.Name,
-		})
+			"role": p.Role,
+		}
+		if userID := p.UserID(); userID != "" {
+			info["user_id"] = userID
+		}
+		if caps := p.Capabilities(); caps != nil {
+			info["capabilities"] = caps
+		}
+		peersInfo = append(peersInfo, info)
 	}
+	meta := room.metadata()
+	hands := room.handQueue()
+	e2ee := room.E2EE
 	room.Lock.RUnlock()
 
-	peer.WriteJSON(map[string]any{
-		"type":    "room_state",
-		"self_id": peer.ID,
-		"peers":   peersInfo,
-	})
+	state := map[string]any{
+		"type":             "room_state",
+		"self_id":          peer.ID,
+		"role":             peer.Role,
+		"polite":           clientPolite,
+		"peers":            peersInfo,
+		"room":             meta,
+		"hands":            hands,
+		"e2ee":             e2ee,
+		"priority_speaker": room.PrioritySpeaker(),
+		"muted_all":        room.mutedAll.Load(),
+		"codec_policy":     room.CodecPolicy(),
+		"relay_only":       h.relayOnly(room) || peer.usesRelay(),
+	}
+	if servers := room.clientICEServers(time.Now()); servers != nil {
+		state["ice_servers"] = servers
+	}
+	peer.WriteJSON(state)
 
 	// Notify others about new peer
+	joined := map[string]any{"id": peer.ID, "name": peer.Name, "role": peer.Role}
+	if userID := peer.UserID(); userID != "" {
+		joined["user_id"] = userID
+	}
 	room.Broadcast(peer.ID, map[string]any{
 		"type": "peer_join",
-		"peer": map[string]any{"id": peer.ID, "name": peer.Name},
+		"peer": joined,
 	})
 }
 
-func (h *Handler) setupWebRTC(room *Room, peer *Peer) error {
+// setupWebRTC creates the peer's PeerConnection. Goroutines it starts stop when
+// ctx, the peer's session context, is done.
+func (h *Handler) setupWebRTC(ctx context.Context, room *Room, peer *Peer) error {
 	config := webrtc.Configuration{
 		ICEServers: []webrtc.ICEServer{
 			{URLs: []string{"stun:stun.l.google.com:19302"}},
 		},
 	}
-	if h.ICEConfig != nil {
-		config = *h.ICEConfig
+	if iceConfig := h.iceConfig(); iceConfig != nil {
+		config = *iceConfig
+	}
+	if servers := room.tenantICEServers(time.Now()); servers != nil {
+		config.ICEServers = append(slices.Clone(config.ICEServers), servers...)
+	}
+	if h.relayOnly(room) {
+		config.ICETransportPolicy = webrtc.ICETransportPolicyRelay
 	}
 
 	pc, err := h.WebRTCAPI.NewPeerConnection(config)
 	if err != nil {
-		slog.Error("Failed to create PeerConnection", "err", err)
+		peer.log().Error("Failed to create PeerConnection", "err", err)
 		return err
 	}
+	peer.signalingChanged = make(chan struct{}, 1)
 	peer.PC = pc
 
+	// Negotiation is event-driven: pion reports when tracks or data channels need
+	// an offer, and runNegotiation waits on signalingChanged for stable.
+	pc.OnNegotiationNeeded(func() {
+		h.requestNegotiation(peer)
+	})
+	pc.OnSignalingStateChange(func(webrtc.SignalingState) {
+		select {
+		case peer.signalingChanged <- struct{}{}:
+		default:
+		}
+	})
+
 	pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
-		slog.Info("ICE connection state changed", "peer_id", peer.ID, "state", state.String())
+		peer.log().Info("ICE connection state changed", "state", state.String())
+		peer.note(TimelineICEState, state.String())
 		switch state {
 		case webrtc.ICEConnectionStateConnected:
 			// Log the selected ICE candidate pair type (host/srflx/relay)
@@ -262,9 +596,9 @@ func (h *Handler) setupWebRTC(room *Room, peer *Peer) error {
 		case webrtc.ICEConnectionStateDisconnected:
 			go func() {
 				select {
-				case <-peer.Done:
+				case <-ctx.Done():
 					return
-				case <-time.After(iceRestartDelay):
+				case <-time.After(h.Negotiation.ICERestartDelay):
 				}
 				if peer.PC != nil && peer.PC.ICEConnectionState() == webrtc.ICEConnectionStateDisconnected {
 					h.requestICERestart(peer)
@@ -273,8 +607,17 @@ func (h *Handler) setupWebRTC(room *Room, peer *Peer) error {
 		}
 	})
 	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
-		slog.Info("Peer connection state changed", "peer_id", peer.ID, "state", state.String())
-		if state == webrtc.PeerConnectionStateFailed {
+		peer.log().Info("Peer connection state changed", "state", state.String())
+		peer.note(TimelineConnectionState, state.String())
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			peer.iceFailures.Store(0)
+		case webrtc.PeerConnectionStateFailed:
+			if peer.iceFailures.Add(1) > maxICEFailures {
+				peer.log().Warn("Peer connection keeps failing, disconnecting", "failures", maxICEFailures)
+				go peer.Disconnect(DisconnectICEFailed, "Connection lost, please rejoin")
+				return
+			}
 			h.requestICERestart(peer)
 		}
 	})
@@ -284,6 +627,10 @@ func (h *Handler) setupWebRTC(room *Room, peer *Peer) error {
 		if c == nil {
 			return
 		}
+		if !h.CandidateFilter.allowsCandidate(c) {
+			peer.log().Debug("Filtered local ICE candidate", "type", c.Typ.String(), "address", c.Address)
+			return
+		}
 		peer.WriteJSON(map[string]any{
 			"type":      "candidate",
 			"candidate": c.ToJSON(),
@@ -296,16 +643,36 @@ func (h *Handler) setupWebRTC(room *Room, peer *Peer) error {
 			return
 		}
 
-		slog.Info("Received remote track", "peer", peer.Name, "id", track.ID())
+		peer.log().Info("Received remote track", "name", peer.Name, "id", track.ID())
+		peer.note(TimelineTrackReceived, track.ID()+" "+track.Codec().MimeType)
+		if room := peer.Room(); !peer.forwardsMedia(room) {
+			peer.log().Info("Not forwarding track", "role", peer.Role, "e2ee_room", room.IsE2EE())
+			return
+		}
+		if mimeType := track.Codec().MimeType; !peer.Room().CodecPolicy().allows(mimeType) {
+			peer.log().Info("Not forwarding track outside the room's codec policy", "codec", mimeType)
+			peer.WriteJSON(map[string]string{"type": "error", "message": mimeType + " is not allowed in this room"})
+			return
+		}
+
+		if peer.isLowLayer(track) {
+			h.addLowLayer(peer.Room(), peer, track, receiver)
+			return
+		}
+
+		// A restarted track takes over the publisher's existing forwarder.
+		if h.replaceTrack(peer.Room(), peer, track, receiver) {
+			return
+		}
 
 		// Broadcast this new track to all other peers in the room
-		h.broadcastTrack(room, peer, track)
+		h.broadcastTrack(peer.Room(), peer, track, receiver)
 	})
 
 	// Create DataChannel for heartbeat keepalive
 	dc, err := pc.CreateDataChannel("heartbeat", nil)
 	if err != nil {
-		slog.Warn("Failed to create heartbeat DataChannel", "peer_id", peer.ID, "err", err)
+		peer.log().Warn("Failed to create heartbeat DataChannel", "err", err)
 		return nil
 	}
 	peer.HeartbeatDC = dc
@@ -315,13 +682,13 @@ func (h *Handler) setupWebRTC(room *Room, peer *Peer) error {
 	var lastPongMu sync.RWMutex
 
 	dc.OnOpen(func() {
-		slog.Debug("Heartbeat DataChannel opened", "peer_id", peer.ID)
+		peer.log().Debug("Heartbeat DataChannel opened")
 		go func() {
 			ticker := time.NewTicker(heartbeatInterval)
 			defer ticker.Stop()
 			for {
 				select {
-				case <-peer.Done:
+				case <-ctx.Done():
 					return
 				case <-ticker.C:
 					if peer.HeartbeatDC == nil {
@@ -333,14 +700,14 @@ func (h *Handler) setupWebRTC(room *Room, peer *Peer) error {
 					lastPongMu.RUnlock()
 
 					if timeSinceLastPong > heartbeatTimeout {
-						slog.Warn("Heartbeat timeout, connection may be dead", "peer_id", peer.ID)
+						peer.log().Warn("Heartbeat timeout, connection may be dead")
 						// Don't close connection here, let ICE handle it
 						return
 					}
 
 					// Send ping
 					if err := peer.HeartbeatDC.SendText("ping"); err != nil {
-						slog.Debug("Heartbeat send failed", "peer_id", peer.ID, "err", err)
+						peer.log().Debug("Heartbeat send failed", "err", err)
 						return
 					}
 				}
@@ -357,7 +724,7 @@ func (h *Handler) setupWebRTC(room *Room, peer *Peer) error {
 	})
 
 	dc.OnClose(func() {
-		slog.Debug("Heartbeat DataChannel closed", "peer_id", peer.ID)
+		peer.log().Debug("Heartbeat DataChannel closed")
 	})
 
 	return nil
@@ -367,38 +734,58 @@ func (h *Handler) addExistingTracks(room *Room, receiver *Peer) {
 	type forwarderEntry struct {
 		senderID  string
 		forwarder *TrackForwarder
-		track     *webrtc.TrackRemote
 	}
 
 	room.ForwardersMu.RLock()
 	forwarders := make([]forwarderEntry, 0, len(room.Forwarders))
 	for senderID, forwarder := range room.Forwarders {
-		if senderID == receiver.ID || forwarder == nil || forwarder.TrackRemote == nil {
+		if senderID == receiver.ID || forwarder == nil || forwarder.Codec().MimeType == "" {
 			continue
 		}
 		forwarders = append(forwarders, forwarderEntry{
 			senderID:  senderID,
 			forwarder: forwarder,
-			track:     forwarder.TrackRemote,
 		})
 	}
 	room.ForwardersMu.RUnlock()
 
 	for _, entry := range forwarders {
-		h.subscribeToForwarder(receiver, entry.senderID, entry.forwarder, entry.track)
+		h.subscribeToForwarder(receiver, entry.senderID, entry.forwarder)
 	}
 }
 
-func (h *Handler) broadcastTrack(room *Room, sender *Peer, track *webrtc.TrackRemote) {
+func (h *Handler) broadcastTrack(room *Room, sender *Peer, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
 	// Create a forwarder for this sender's track
-	forwarder := NewTrackForwarder(sender.ID, track)
+	forwarder := NewTrackForwarder(sender.Context(), sender.ID, track)
+	h.configureForwarder(sender, forwarder, track, receiver)
+	h.applyAudioFilters(room, forwarder)
+	forwarder.onRTP = func(packet []byte) {
+		sender.recordRTP(packet)
+		if current := sender.Room(); current != nil {
+			h.RoomManager.countPublished(current, len(packet))
+		}
+	}
+	forwarder.onSpeech = func(d time.Duration) {
+		sender.AddTalkTime(d)
+		if current := sender.Room(); current != nil {
+			current.notePrioritySpeech(sender.ID)
+		}
+	}
 	forwarder.onStop = func(err error) {
-		room.ForwardersMu.Lock()
-		current, exists := room.Forwarders[sender.ID]
+		if err != nil {
+			sender.note(TimelineForwarderError, "forwarder stopped: "+err.Error())
+		}
+		// The forwarder follows its sender when the sender is moved to another room.
+		owner := room
+		if current := sender.Room(); current != nil {
+			owner = current
+		}
+		owner.ForwardersMu.Lock()
+		current, exists := owner.Forwarders[sender.ID]
 		if exists && current == forwarder {
-			delete(room.Forwarders, sender.ID)
+			delete(owner.Forwarders, sender.ID)
 		}
-		room.ForwardersMu.Unlock()
+		owner.ForwardersMu.Unlock()
 	}
 
 	var oldForwarder *TrackForwarder
@@ -411,6 +798,10 @@ func (h *Handler) broadcastTrack(room *Room, sender *Peer, track *webrtc.TrackRe
 	if oldForwarder != nil && oldForwarder != forwarder {
 		oldForwarder.Stop()
 	}
+	room.attachEgress(sender.ID, forwarder)
+	if low := sender.lowLayerForwarder(); low != nil {
+		forwarder.setLowLayer(low)
+	}
 
 	// Add the track to all existing peers in the room
 	room.Lock.RLock()
@@ -424,27 +815,70 @@ func (h *Handler) broadcastTrack(room *Room, sender *Peer, track *webrtc.TrackRe
 	room.Lock.RUnlock()
 
 	for _, receiver := range receivers {
-		h.subscribeToForwarder(receiver, sender.ID, forwarder, track)
+		h.subscribeToForwarder(receiver, sender.ID, forwarder)
 	}
 
 	// Start forwarding immediately; no fixed sleep.
 	go forwarder.Start()
 }
 
+// configureForwarder applies the room gating, jitter buffer and payload type
+// settings shared by every forwarder of sender's audio.
+func (h *Handler) configureForwarder(sender *Peer, forwarder *TrackForwarder, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	forwarder.logger = sender.log()
+	forwarder.onWriteError = func(receiverID string, err error) {
+		if current := sender.Room(); current != nil {
+			current.Lock.RLock()
+			receiver := current.Peers[receiverID]
+			current.Lock.RUnlock()
+			if receiver != nil {
+				receiver.note(TimelineForwarderError, "write audio from "+sender.ID+": "+err.Error())
+			}
+		}
+	}
+	forwarder.onForward = func(receiverIDs []string, n int) {
+		if current := sender.Room(); current != nil {
+			h.RoomManager.countForwarded(current, receiverIDs, n)
+		}
+	}
+	forwarder.throttled = func() bool {
+		current := sender.Room()
+		return current != nil && current.throttled.Load()
+	}
+	forwarder.audioLevelExtID = audioLevelExtensionID(receiver)
+	forwarder.suppressed = func() bool {
+		current := sender.Room()
+		return current != nil && (current.ducks(sender.ID) || current.mutes(sender))
+	}
+	forwarder.impairment = h.impairment.Load
+	forwarder.maxBitrate = func() int {
+		return h.maxPublishBitrate(sender.Room())
+	}
+	forwarder.onOverBitrate = func(v *bitrateViolation) {
+		// The forwarder's track changes if the publisher restarts it.
+		h.overBitrate(sender, forwarder.TrackRemote, v)
+	}
+	forwarder.EnableJitterBuffer(h.JitterBuffer)
+	forwarder.skipSilence = h.SkipSilence
+	redPT, opusPT := negotiatedAudioPayloadTypes(receiver)
+	forwarder.SetPayloadTypes(uint8(track.PayloadType()), redPT, opusPT)
+}
+
 // subscribeToForwarder creates a local track for the receiver and subscribes it to the forwarder.
-func (h *Handler) subscribeToForwarder(receiver *Peer, senderID string, forwarder *TrackForwarder, track *webrtc.TrackRemote) {
+func (h *Handler) subscribeToForwarder(receiver *Peer, senderID string, forwarder *TrackForwarder) {
 	if receiver.PC == nil {
 		return
 	}
-	if receiver.ID == senderID {
+	if receiver.ID == senderID || !receiver.accepts(forwarder.Codec()) || !receiver.wantsAudioFrom(senderID) {
 		return
 	}
+	forwarder.setLayer(receiver.ID, receiver.wantsLowLayer(senderID))
 
 	receiver.OutTracksMu.RLock()
 	existingTrack := receiver.OutTracks[senderID]
 	receiver.OutTracksMu.RUnlock()
 	if existingTrack != nil {
-		forwarder.Subscribe(receiver.ID, existingTrack)
+		h.subscribe(receiver, senderID, forwarder, existingTrack)
 		return
 	}
 
@@ -453,24 +887,24 @@ func (h *Handler) subscribeToForwarder(receiver *Peer, senderID string, forwarde
 	receiver.OutTracksMu.Lock()
 	if existingTrack := receiver.OutTracks[senderID]; existingTrack != nil {
 		receiver.OutTracksMu.Unlock()
-		forwarder.Subscribe(receiver.ID, existingTrack)
+		h.subscribe(receiver, senderID, forwarder, existingTrack)
 		return
 	}
 
 	// Create a local track to push data to the receiver
 	// Use senderID as the StreamID so the client can map it to a user
 	trackID := fmt.Sprintf("%s-audio", senderID)
-	localTrack, err := webrtc.NewTrackLocalStaticRTP(track.Codec().RTPCodecCapability, trackID, senderID)
+	localTrack, err := webrtc.NewTrackLocalStaticRTP(forwarder.Codec(), trackID, senderID)
 	if err != nil {
 		receiver.OutTracksMu.Unlock()
-		slog.Error("Failed to create local track", "err", err)
+		receiver.log().Error("Failed to create local track", "err", err)
 		return
 	}
 
-	sender, err := receiver.PC.AddTrack(localTrack)
+	sender, err := h.addOutTrack(receiver, localTrack)
 	if err != nil {
 		receiver.OutTracksMu.Unlock()
-		slog.Error("Failed to add track to PC", "err", err)
+		receiver.log().Error("Failed to add track to PC", "err", err)
 		return
 	}
 
@@ -481,68 +915,76 @@ func (h *Handler) subscribeToForwarder(receiver *Peer, senderID string, forwarde
 	receiver.OutTracks[senderID] = localTrack
 	receiver.OutTracksMu.Unlock()
 
-	// RTCP reader: read RTCP feedback until peer disconnects
-	go func() {
-		rtcpBuf := make([]byte, 1500)
-		for {
-			select {
-			case <-receiver.Done:
-				return
-			default:
-			}
-			if _, _, rtcpErr := sender.Read(rtcpBuf); rtcpErr != nil {
-				return
-			}
-		}
-	}()
+	// RTCP reader: read receiver reports until the peer disconnects; they drive
+	// the choice of simulcast layer.
+	go h.readReceiverReports(receiver, senderID, sender)
 
-	// Subscribe to the forwarder
-	forwarder.Subscribe(receiver.ID, localTrack)
+	// Subscribe to the forwarder. AddTrack fired OnNegotiationNeeded, which
+	// renegotiates once the receiver's signaling state allows it.
+	h.subscribe(receiver, senderID, forwarder, localTrack)
+}
 
-	// Trigger renegotiation
-	h.requestNegotiation(receiver)
+// subscribe feeds the receiver's local track from the forwarder at the gain the
+// receiver set for senderID.
+func (h *Handler) subscribe(receiver *Peer, senderID string, forwarder *TrackForwarder, track *webrtc.TrackLocalStaticRTP) {
+	forwarder.Subscribe(receiver.ID, track)
+	if receiver.gainFor(senderID) != 1 {
+		h.applyGain(receiver, senderID, forwarder)
+	}
 }
 
 func (h *Handler) requestNegotiation(peer *Peer) {
 	h.requestNegotiationWithICE(peer, false)
 }
 
-func (h *Handler) requestICERestart(peer *Peer) {
-	h.requestNegotiationWithICE(peer, true)
+// requestICERestart schedules an ICE-restart offer and reports false if the peer
+// already restarted within Negotiation.ICERestartMinInterval.
+func (h *Handler) requestICERestart(peer *Peer) bool {
+	return h.requestNegotiationWithICE(peer, true)
 }
 
-func (h *Handler) requestNegotiationWithICE(peer *Peer, iceRestart bool) {
+func (h *Handler) requestNegotiationWithICE(peer *Peer, iceRestart bool) bool {
 	peer.NegotiationMu.Lock()
 	if iceRestart {
 		now := time.Now()
-		if !peer.LastIceRestart.IsZero() && now.Sub(peer.LastIceRestart) < iceRestartMin {
+		if !peer.LastIceRestart.IsZero() && now.Sub(peer.LastIceRestart) < h.Negotiation.ICERestartMinInterval {
 			peer.NegotiationMu.Unlock()
-			return
+			return false
 		}
 		peer.LastIceRestart = now
 		peer.IceRestartPending = true
+		peer.note(TimelineICERestart, "")
 	}
 	peer.NegotiationPending = true
 	if peer.NegotiationInProgress {
 		peer.NegotiationMu.Unlock()
-		return
+		return true
 	}
 	peer.NegotiationInProgress = true
 	peer.NegotiationMu.Unlock()
 
-	go h.runNegotiation(peer)
+	go h.runNegotiation(peer.Context(), peer)
+	return true
 }
 
-func (h *Handler) runNegotiation(peer *Peer) {
+// runNegotiation sends offers while negotiation is pending and waits for each
+// to be answered, until ctx is done. An exchange that takes longer than
+// Negotiation.NegotiationTimeout disconnects the peer.
+func (h *Handler) runNegotiation(ctx context.Context, peer *Peer) {
+	defer peer.recoverPanic("negotiation")
 	defer func() {
 		peer.NegotiationMu.Lock()
 		peer.NegotiationInProgress = false
 		peer.NegotiationMu.Unlock()
 	}()
 
+	// since is when the current exchange started: waiting for the client's offer
+	// or our offer's answer, or retrying a failed offer. It is zero while idle.
+	var since time.Time
+	retrying := false
 	for {
 		select {
-		case <-peer.Done:
+		case <-ctx.Done():
 			return
 		default:
 		}
@@ -551,30 +993,43 @@ func (h *Handler) runNegotiation(peer *Peer) {
 		pending := peer.NegotiationPending
 		iceRestart := peer.IceRestartPending
 		peer.NegotiationMu.Unlock()
-		if !pending {
-			return
-		}
 
 		pc := peer.PC
-		if pc == nil {
+		if pc == nil || pc.ConnectionState() == webrtc.PeerConnectionStateClosed || pc.SignalingState() == webrtc.SignalingStateClosed {
 			peer.NegotiationMu.Lock()
 			peer.NegotiationPending = false
 			peer.IceRestartPending = false
 			peer.NegotiationMu.Unlock()
 			return
 		}
-		if pc.ConnectionState() == webrtc.PeerConnectionStateClosed || pc.SignalingState() == webrtc.SignalingStateClosed {
-			peer.NegotiationMu.Lock()
-			peer.NegotiationPending = false
-			peer.IceRestartPending = false
-			peer.NegotiationMu.Unlock()
+		if !since.IsZero() && time.Since(since) >= h.Negotiation.NegotiationTimeout {
+			h.failNegotiation(peer)
 			return
 		}
 
+		// Offers can only go out from stable once the client's first offer has been
+		// answered; wait for the next signaling state change instead of polling.
 		if pc.SignalingState() != webrtc.SignalingStateStable || pc.RemoteDescription() == nil {
-			time.Sleep(50 * time.Millisecond)
+			if since.IsZero() {
+				since = time.Now()
+			}
+			timer := time.NewTimer(h.Negotiation.NegotiationTimeout - time.Since(since))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-peer.signalingChanged:
+			case <-timer.C:
+			}
+			timer.Stop()
 			continue
 		}
+		if !retrying {
+			since = time.Time{}
+		}
+		if !pending {
+			return
+		}
 
 		peer.NegotiationMu.Lock()
 		peer.NegotiationPending = false
@@ -600,62 +1055,53 @@ func (h *Handler) runNegotiation(peer *Peer) {
 		peer.NegotiationMu.Unlock()
 
 		if err != nil {
-			slog.Warn("Failed to create offer", "peer_id", peer.ID, "err", err)
-			time.Sleep(100 * time.Millisecond)
+			peer.log().Warn("Failed to create offer", "err", err)
+			peer.note(TimelineNegotiationError, "create offer: "+err.Error())
+			if since.IsZero() {
+				since = time.Now()
+			}
+			retrying = true
+			if !peer.sleep(negotiationRetryDelay) {
+				return
+			}
 			continue
 		}
 
 		localDesc := pc.LocalDescription()
 		if localDesc == nil {
-			slog.Warn("Missing local description after offer", "peer_id", peer.ID)
+			peer.log().Warn("Missing local description after offer")
 			peer.NegotiationMu.Lock()
 			peer.NegotiationPending = true
 			peer.NegotiationMu.Unlock()
-			time.Sleep(100 * time.Millisecond)
+			if since.IsZero() {
+				since = time.Now()
+			}
+			retrying = true
+			if !peer.sleep(negotiationRetryDelay) {
+				return
+			}
 			continue
 		}
 
+		// The offer is out; the loop now waits for its answer.
+		retrying = false
+		since = time.Now()
 		peer.WriteJSON(map[string]any{
 			"type": "offer",
-			"sdp":  localDesc.SDP,
+			"sdp":  h.CandidateFilter.filterSDP(localDesc.SDP),
 		})
+		if iceRestart {
+			peer.note(TimelineOfferSent, "ice restart")
+		} else {
+			peer.note(TimelineOfferSent, "")
+		}
 	}
 }
 
 func (h *Handler) flushPendingCandidates(peer *Peer) {
-	peer.PendingCandidatesMu.Lock()
-	pending := peer.PendingCandidates
-	peer.PendingCandidates = nil
-	peer.PendingCandidatesMu.Unlock()
-
-	for _, candidate := range pending {
+	for _, candidate := range peer.takePendingCandidates(time.Now()) {
 		if err := peer.PC.AddICECandidate(candidate); err != nil {
-			slog.Warn("Failed to add pending ICE candidate", "peer_id", peer.ID, "err", err)
-		}
-	}
-}
-
-func waitForNegotiationStable(pc *webrtc.PeerConnection, timeout time.Duration) bool {
-	deadline := time.NewTimer(timeout)
-	defer deadline.Stop()
-
-	ticker := time.NewTicker(50 * time.Millisecond)
-	defer ticker.Stop()
-
-	sawLocalOffer := false
-	for {
-		state := pc.SignalingState()
-		if state == webrtc.SignalingStateHaveLocalOffer {
-			sawLocalOffer = true
-		}
-		if sawLocalOffer && state == webrtc.SignalingStateStable && pc.RemoteDescription() != nil {
-			return true
-		}
-
-		select {
-		case <-deadline.C:
-			return false
-		case <-ticker.C:
+			peer.log().Warn("Failed to add pending ICE candidate", "err", err)
 		}
 	}
 }
@@ -668,111 +1114,249 @@ func (h *Handler) handleSignalingMessage(room *Room, peer *Peer, msg map[string]
 	if t == "heartbeat" {
 		return
 	}
+	peer.Touch()
+	if t == "hello" {
+		h.handleHello(room, peer, msg)
+		return
+	}
 	if peer.PC == nil {
 		return
 	}
 
 	switch t {
+	case "room_stats":
+		peer.WriteJSON(map[string]any{
+			"type":          "room_stats",
+			"peers":         room.Stats(),
+			"hls_listeners": room.HLSListeners(),
+			"bandwidth":     room.BandwidthStats(),
+		})
+
+	case "move_peer":
+		// Hosts can send members of their room to breakout rooms.
+		targetID, _ := msg["peer_id"].(string)
+		targetRoom, _ := msg["room"].(string)
+		targetRoom = strings.TrimSpace(targetRoom)
+		if !peer.Can(PermMovePeers) || targetRoom == "" {
+			peer.WriteJSON(map[string]string{"type": "error", "message": "move_peer not allowed"})
+			return
+		}
+		// The target is named as the host knows rooms, inside its own tenant's
+		// namespace, exactly as a join would resolve it.
+		tenantID := ""
+		if room.tenant != nil {
+			tenantID = room.tenant.ID
+		}
+		targetRoom, _, ok := h.RoomManager.Tenants.joinRoom(tenantID, targetRoom)
+		if !ok {
+			peer.WriteJSON(map[string]string{"type": "error", "message": "move_peer not allowed"})
+			return
+		}
+		room.Lock.RLock()
+		_, inRoom := room.Peers[targetID]
+		room.Lock.RUnlock()
+		if !inRoom {
+			return
+		}
+		if err := h.MovePeer(targetID, targetRoom); err != nil {
+			peer.log().Warn("Host move failed", "target", targetID, "err", err)
+		}
+
+	case "reaction":
+		h.handleReaction(room, peer, msg)
+
+	case "raise_hand":
+		room.raiseHand(peer.ID)
+
+	case "lower_hand":
+		// Hosts and moderators may lower anyone's hand; everyone else only their own.
+		target, _ := msg["peer_id"].(string)
+		if target == "" || !peer.Can(PermManageHands) {
+			target = peer.ID
+		}
+		room.lowerHand(target)
+
+	case "call_next":
+		if !peer.Can(PermManageHands) {
+			peer.WriteJSON(map[string]string{"type": "error", "message": "call_next not allowed"})
+			return
+		}
+		room.callNext()
+
+	case "kick":
+		h.handlePeerKick(room, peer, msg)
+
+	case "mute_all", "unmute_all", "unmute_request", "approve_unmute", "deny_unmute":
+		h.handleMuteMessage(room, peer, t, msg)
+
+	case "set_priority_speaker":
+		h.handleSetPrioritySpeaker(room, peer, msg)
+
+	case "subscribe", "unsubscribe":
+		h.handleSubscription(room, peer, msg, t == "subscribe")
+
+	case "quality":
+		h.handleQuality(room, peer, msg)
+
+	case "gain":
+		h.handleGain(room, peer, msg)
+
+	case "set_denoise":
+		h.handleSetDenoise(room, peer, msg)
+
+	case "lock_room", "unlock_room":
+		if !peer.Can(PermLock) {
+			peer.WriteJSON(map[string]string{"type": "error", "message": t + " not allowed"})
+			return
+		}
+		h.RoomManager.SetLocked(room.UUID, t == "lock_room")
+		peer.log().Info("Room lock changed by peer", "uuid", room.UUID, "locked", t == "lock_room")
+
+	case "e2ee_key":
+		h.handleE2EEKey(room, peer, msg)
+
+	case "set_room_metadata":
+		if !peer.Can(PermEditRoom) {
+			peer.WriteJSON(map[string]string{"type": "error", "message": "set_room_metadata not allowed"})
+			return
+		}
+		meta := RoomMetadata{}
+		meta.Name, _ = msg["name"].(string)
+		meta.Topic, _ = msg["topic"].(string)
+		meta.Avatar, _ = msg["avatar"].(string)
+		if err := h.SetRoomMetadata(room.UUID, meta); err != nil {
+			peer.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
+		}
+
 	case "offer":
 		sdp, ok := msg["sdp"].(string)
 		if !ok || sdp == "" {
-			slog.Warn("Invalid offer: missing or invalid SDP", "peer_id", peer.ID)
+			peer.log().Warn("Invalid offer: missing or invalid SDP")
 			return
 		}
-		state := peer.PC.SignalingState()
-		peer.NegotiationMu.Lock()
-		offerCollision := peer.MakingOffer || state == webrtc.SignalingStateHaveLocalOffer
-		if offerCollision {
-			peer.NegotiationPending = true
-			peer.MakingOffer = false
+		if err := checkOfferSDP(sdp); err != nil {
+			peer.log().Warn("Invalid offer", "err", err)
+			peer.note(TimelineNegotiationError, "invalid offer: "+err.Error())
+			return
 		}
-		peer.NegotiationMu.Unlock()
-		if state == webrtc.SignalingStateHaveRemoteOffer {
-			slog.Warn("Dropping offer while remote offer pending", "peer_id", peer.ID)
+		policy := room.CodecPolicy()
+		if err := policy.checkOffer(sdp); err != nil {
+			peer.log().Info("Offer refused by codec policy", "err", err)
+			peer.note(TimelineNegotiationError, err.Error())
+			peer.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
 			return
 		}
-		if state == webrtc.SignalingStateHaveLocalOffer {
-			// pion/webrtc does not support rollback from have-local-offer state (see issue #2133).
-			// Use "impolite" mode: ignore the incoming offer and let the client handle the collision.
-			// The client (browser) supports rollback and will handle it correctly.
-			// NegotiationPending was set above, so we'll send a new offer soon.
-			slog.Warn("Offer collision (have-local-offer), dropping incoming offer", "peer_id", peer.ID)
+		state := peer.PC.SignalingState()
+		peer.note(TimelineOfferReceived, "signaling state "+state.String())
+		// Perfect negotiation with the server as the impolite side (see
+		// clientPolite): a colliding offer is ignored, and the client rolls back
+		// its own offer and answers ours, then offers again.
+		peer.NegotiationMu.Lock()
+		offerCollision := peer.MakingOffer || state != webrtc.SignalingStateStable
+		peer.IgnoreOffer = offerCollision
+		peer.NegotiationMu.Unlock()
+		if offerCollision {
+			peer.log().Info("Offer collision, ignoring client offer", "signaling_state", state.String())
+			peer.note(TimelineOfferIgnored, "signaling state "+state.String())
 			return
 		}
 
+		peer.startCandidateCycle()
 		err := peer.PC.SetRemoteDescription(webrtc.SessionDescription{
 			Type: webrtc.SDPTypeOffer,
 			SDP:  sdp,
 		})
 		if err != nil {
-			slog.Error("SetRemoteDescription failed", "err", err)
+			peer.log().Error("SetRemoteDescription failed", "err", err)
+			peer.note(TimelineNegotiationError, "set remote offer: "+err.Error())
 			return
 		}
 		h.flushPendingCandidates(peer)
-		answer, err := peer.PC.CreateAnswer(nil)
-		if err != nil {
-			return
+		err = applyCodecPolicy(peer.PC, policy)
+		var answer webrtc.SessionDescription
+		if err == nil {
+			answer, err = peer.PC.CreateAnswer(nil)
+		}
+		if err == nil {
+			err = peer.PC.SetLocalDescription(answer)
 		}
-		if err = peer.PC.SetLocalDescription(answer); err != nil {
+		if err != nil {
+			// pion cannot roll back an applied remote offer, so the connection
+			// could never negotiate again; disconnect rather than leave it stuck.
+			peer.log().Error("Answer failed", "err", err)
+			peer.note(TimelineNegotiationError, "answer: "+err.Error())
+			go peer.Disconnect(DisconnectServerError, "Negotiation failed")
 			return
 		}
 		localDesc := peer.PC.LocalDescription()
 		if localDesc == nil {
-			slog.Warn("Missing local description after answer", "peer_id", peer.ID)
+			peer.log().Warn("Missing local description after answer")
 			return
 		}
 		peer.WriteJSON(map[string]any{
 			"type": "answer",
-			"sdp":  localDesc.SDP,
+			"sdp":  h.CandidateFilter.filterSDP(localDesc.SDP),
 		})
-		if offerCollision {
-			h.requestNegotiation(peer)
-		}
+		peer.note(TimelineAnswerSent, "")
 
 	case "answer":
 		sdp, ok := msg["sdp"].(string)
 		if !ok || sdp == "" {
-			slog.Warn("Invalid answer: missing or invalid SDP", "peer_id", peer.ID)
+			peer.log().Warn("Invalid answer: missing or invalid SDP")
+			return
+		}
+		if state := peer.PC.SignalingState(); state != webrtc.SignalingStateHaveLocalOffer {
+			// A stale answer, e.g. to an offer the client rolled back.
+			peer.log().Debug("Ignoring answer without a pending offer", "signaling_state", state.String())
 			return
 		}
+		peer.startCandidateCycle()
 		if err := peer.PC.SetRemoteDescription(webrtc.SessionDescription{
 			Type: webrtc.SDPTypeAnswer,
 			SDP:  sdp,
 		}); err != nil {
-			slog.Error("SetRemoteDescription failed", "err", err)
+			peer.log().Error("SetRemoteDescription failed", "err", err)
+			peer.note(TimelineNegotiationError, "set remote answer: "+err.Error())
 			return
 		}
+		peer.note(TimelineAnswerReceived, "")
 		h.flushPendingCandidates(peer)
 
 	case "candidate":
+		if !peer.allowCandidate(time.Now()) {
+			return
+		}
 		candidateData, ok := msg["candidate"].(map[string]any)
 		if !ok {
-			slog.Warn("Invalid candidate: not a map", "peer_id", peer.ID)
+			peer.log().Warn("Invalid candidate: not a map")
 			return
 		}
 		candidateJSON, err := json.Marshal(candidateData)
 		if err != nil {
-			slog.Warn("Failed to marshal candidate", "peer_id", peer.ID, "err", err)
+			peer.log().Warn("Failed to marshal candidate", "err", err)
 			return
 		}
 		var candidate webrtc.ICECandidateInit
 		if err := json.Unmarshal(candidateJSON, &candidate); err != nil {
-			slog.Warn("Failed to unmarshal candidate", "peer_id", peer.ID, "err", err)
+			peer.log().Warn("Failed to unmarshal candidate", "err", err)
 			return
 		}
 		if peer.PC.RemoteDescription() == nil {
-			peer.PendingCandidatesMu.Lock()
-			peer.PendingCandidates = append(peer.PendingCandidates, candidate)
-			peer.PendingCandidatesMu.Unlock()
+			peer.queueCandidate(candidate, time.Now())
 			return
 		}
 		if err := peer.PC.AddICECandidate(candidate); err != nil {
-			slog.Warn("Failed to add ICE candidate", "peer_id", peer.ID, "err", err)
+			// Candidates for an ignored offer are expected to fail.
+			if peer.ignoringOffer() {
+				peer.log().Debug("Dropped ICE candidate for ignored offer", "err", err)
+			} else {
+				peer.log().Warn("Failed to add ICE candidate", "err", err)
+			}
 		}
 	}
 }
 
-func normalizeNickname(raw string) (string, error) {
+func normalizeNickname(raw string, filter *NicknameFilter) (string, error) {
 	name := strings.TrimSpace(raw)
 	if name == "" {
 		return "", errors.New("missing name")
@@ -780,12 +1364,13 @@ func normalizeNickname(raw string) (string, error) {
 	if utf8.RuneCountInString(name) > maxNicknameRune {
 		return "", errors.New("name too long")
 	}
-	return name, nil
+	return filter.Apply(name)
 }
 
-func clientIP(r *http.Request) string {
+// clientIP is the peer's address, or the one a trusted proxy reports for it.
+func (h *Handler) clientIP(r *http.Request) string {
 	remoteIP := parseRemoteIP(r.RemoteAddr)
-	if remoteIP != nil && isTrustedProxy(remoteIP) {
+	if h.TrustedProxies.Contains(remoteIP) {
 		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
 			if ip := net.ParseIP(realIP); ip != nil {
 				return ip.String()
@@ -811,7 +1396,10 @@ func clientIP(r *http.Request) string {
 	return r.RemoteAddr
 }
 
-func checkWSOrigin(r *http.Request) bool {
+// checkWSOrigin accepts same-origin WebSocket upgrades and those from
+// AllowedOrigins. Behind a trusted proxy the public host and scheme come from
+// X-Forwarded-Host and X-Forwarded-Proto.
+func (h *Handler) checkWSOrigin(r *http.Request) bool {
 	origin := r.Header.Get("Origin")
 	if origin == "" {
 		return true
@@ -823,8 +1411,11 @@ func checkWSOrigin(r *http.Request) bool {
 	if originURL.Host == "" {
 		return false
 	}
+	if h.AllowedOrigins.allows(originURL) {
+		return true
+	}
 
-	reqHost := requestHost(r)
+	reqHost := h.requestHost(r)
 	originHost := stripPort(originURL.Host)
 	if reqHost == "" || originHost == "" {
 		return false
@@ -832,14 +1423,17 @@ func checkWSOrigin(r *http.Request) bool {
 	if !strings.EqualFold(reqHost, originHost) {
 		return false
 	}
+	if !h.TrustedProxies.trusts(r) {
+		return true
+	}
 	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
 		return strings.EqualFold(proto, originURL.Scheme)
 	}
 	return true
 }
 
-func requestHost(r *http.Request) string {
-	if xfwd := r.Header.Get("X-Forwarded-Host"); xfwd != "" {
+func (h *Handler) requestHost(r *http.Request) string {
+	if xfwd := r.Header.Get("X-Forwarded-Host"); xfwd != "" && h.TrustedProxies.trusts(r) {
 		parts := strings.Split(xfwd, ",")
 		return stripPort(strings.TrimSpace(parts[0]))
 	}
@@ -871,13 +1465,6 @@ func parseRemoteIP(remoteAddr string) net.IP {
 	return net.ParseIP(remoteAddr)
 }
 
-func isTrustedProxy(ip net.IP) bool {
-	if ip == nil {
-		return false
-	}
-	return ip.IsLoopback() || ip.IsPrivate()
-}
-
 // logICEConnectionType logs the type of ICE connection established (host/srflx/relay)
 func (h *Handler) logICEConnectionType(peer *Peer) {
 	if peer.PC == nil {
@@ -902,7 +1489,7 @@ func (h *Handler) logICEConnectionType(peer *Peer) {
 
 	selectedPair, err := iceTransport.GetSelectedCandidatePair()
 	if err != nil || selectedPair == nil {
-		slog.Debug("Could not get selected ICE candidate pair", "peer_id", peer.ID, "err", err)
+		peer.log().Debug("Could not get selected ICE candidate pair", "err", err)
 		return
 	}
 
@@ -925,6 +1512,7 @@ func (h *Handler) logICEConnectionType(peer *Peer) {
 
 	logger.LogEvent("ICE_CONNECTED",
 		slog.String("peer_id", peer.ID),
+		slog.String("session_id", peer.SessionID),
 		slog.String("peer_name", peer.Name),
 		slog.String("conn_type", connType),
 		slog.String("local_candidate", localType),
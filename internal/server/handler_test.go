@@ -1,13 +1,21 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/pkg/client"
 )
 
 func TestNormalizeNickname(t *testing.T) {
-	name, err := normalizeNickname("  alice  ")
+	name, err := normalizeNickname("  alice  ", nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -15,12 +23,12 @@ func TestNormalizeNickname(t *testing.T) {
 		t.Fatalf("expected trimmed nickname, got %q", name)
 	}
 
-	if _, err := normalizeNickname(""); err == nil {
+	if _, err := normalizeNickname("", nil); err == nil {
 		t.Fatal("expected error for empty nickname")
 	}
 
 	longName := strings.Repeat("a", maxNicknameRune+1)
-	if _, err := normalizeNickname(longName); err == nil {
+	if _, err := normalizeNickname(longName, nil); err == nil {
 		t.Fatal("expected error for too-long nickname")
 	}
 }
@@ -41,58 +49,63 @@ func TestStripPort(t *testing.T) {
 }
 
 func TestRequestHost(t *testing.T) {
+	h := NewHandler(nil, newTestAPI(t), nil, nil)
 	req := &http.Request{
-		Host: "origin.example.com:8443",
+		RemoteAddr: "10.0.0.1:1234",
+		Host:       "origin.example.com:8443",
 		Header: http.Header{
 			"X-Forwarded-Host": []string{"forwarded.example.com:443, proxy.example.com"},
 		},
 	}
-	if got := requestHost(req); got != "forwarded.example.com" {
+	if got := h.requestHost(req); got != "forwarded.example.com" {
 		t.Fatalf("expected forwarded host, got %q", got)
 	}
 
 	req.Header = http.Header{}
-	if got := requestHost(req); got != "origin.example.com" {
+	if got := h.requestHost(req); got != "origin.example.com" {
 		t.Fatalf("expected origin host, got %q", got)
 	}
 }
 
 func TestCheckWSOrigin(t *testing.T) {
+	h := NewHandler(nil, newTestAPI(t), nil, nil)
 	req := &http.Request{
-		Host: "example.com",
+		RemoteAddr: "10.0.0.1:1234",
+		Host:       "example.com",
 		Header: http.Header{
 			"Origin": []string{"https://example.com"},
 		},
 	}
-	if !checkWSOrigin(req) {
+	if !h.checkWSOrigin(req) {
 		t.Fatal("expected origin to be accepted when host matches")
 	}
 
 	req.Header.Set("Origin", "https://evil.com")
-	if checkWSOrigin(req) {
+	if h.checkWSOrigin(req) {
 		t.Fatal("expected origin to be rejected when host mismatches")
 	}
 
 	req.Header.Set("Origin", "http://example.com")
 	req.Header.Set("X-Forwarded-Proto", "https")
-	if checkWSOrigin(req) {
+	if h.checkWSOrigin(req) {
 		t.Fatal("expected origin to be rejected when proto mismatches forwarded proto")
 	}
 
 	req.Header = http.Header{}
-	if !checkWSOrigin(req) {
+	if !h.checkWSOrigin(req) {
 		t.Fatal("expected empty origin to be accepted")
 	}
 }
 
 func TestClientIP(t *testing.T) {
+	h := NewHandler(nil, newTestAPI(t), nil, nil)
 	req := &http.Request{
 		RemoteAddr: "10.0.0.1:1234",
 		Header: http.Header{
 			"X-Real-Ip": []string{"203.0.113.5"},
 		},
 	}
-	if got := clientIP(req); got != "203.0.113.5" {
+	if got := h.clientIP(req); got != "203.0.113.5" {
 		t.Fatalf("expected X-Real-IP to be used, got %q", got)
 	}
 
@@ -102,7 +115,7 @@ func TestClientIP(t *testing.T) {
 			"X-Forwarded-For": []string{"bad-ip, 198.51.100.7"},
 		},
 	}
-	if got := clientIP(req); got != "198.51.100.7" {
+	if got := h.clientIP(req); got != "198.51.100.7" {
 		t.Fatalf("expected X-Forwarded-For to be used, got %q", got)
 	}
 
@@ -113,7 +126,221 @@ func TestClientIP(t *testing.T) {
 			"X-Forwarded-For": []string{"198.51.100.9"},
 		},
 	}
-	if got := clientIP(req); got != "8.8.8.8" {
+	if got := h.clientIP(req); got != "8.8.8.8" {
 		t.Fatalf("expected remote addr to be used for untrusted proxy, got %q", got)
 	}
 }
+
+func TestTrustedProxiesFlag(t *testing.T) {
+	if _, err := ParseTrustedProxies("10.0.0.0/8, bogus"); err == nil {
+		t.Fatal("expected an invalid entry to be rejected")
+	}
+	proxies, err := ParseTrustedProxies("203.0.113.10, 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(nil, newTestAPI(t), nil, nil)
+	h.TrustedProxies = proxies
+
+	req := &http.Request{
+		RemoteAddr: "203.0.113.10:1234",
+		Host:       "internal:8080",
+		Header: http.Header{
+			"X-Forwarded-For":  []string{"198.51.100.7"},
+			"X-Forwarded-Host": []string{"example.com"},
+			"Origin":           []string{"https://example.com"},
+		},
+	}
+	if got := h.clientIP(req); got != "198.51.100.7" {
+		t.Fatalf("expected forwarded IP from listed proxy, got %q", got)
+	}
+	if !h.checkWSOrigin(req) {
+		t.Fatal("expected forwarded host from listed proxy to match origin")
+	}
+
+	// Private addresses are no longer trusted once the list is replaced.
+	req.RemoteAddr = "10.0.0.1:1234"
+	if got := h.clientIP(req); got != "10.0.0.1" {
+		t.Fatalf("expected remote addr for unlisted proxy, got %q", got)
+	}
+	if h.checkWSOrigin(req) {
+		t.Fatal("expected forwarded host from unlisted proxy to be ignored")
+	}
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	for _, bad := range []string{"https://", "https://a.*.example.com", "https://example.com/path", "*."} {
+		if _, err := ParseAllowedOrigins(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+	origins, err := ParseAllowedOrigins("https://app.example.net, https://*.example.com, *.dev.test:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(nil, newTestAPI(t), nil, nil)
+	h.AllowedOrigins = origins
+
+	cases := map[string]bool{
+		"https://app.example.net":      true,
+		"http://app.example.net":       false,
+		"https://a.b.example.com":      true,
+		"https://example.com":          false,
+		"https://evilexample.com":      false,
+		"http://web.dev.test:3000":     true,
+		"http://web.dev.test:4000":     false,
+		"https://APP.Example.NET:8443": true,
+		"https://serve.test":           true, // same host as the request
+	}
+	for origin, want := range cases {
+		req := &http.Request{Host: "serve.test", Header: http.Header{"Origin": []string{origin}}}
+		if got := h.checkWSOrigin(req); got != want {
+			t.Errorf("origin %q: allowed = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+// newFuzzPeer returns a peer with a real PeerConnection and no WebSocket, so
+// handleSignalingMessage can run in isolation.
+func newFuzzPeer(t *testing.T) (*Handler, *Room, *Peer) {
+	t.Helper()
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	h := NewHandler(rm, newTestAPI(t), &webrtc.Configuration{}, nil)
+	room := rm.GetOrCreateRoom("fuzz")
+	ctx, cancel := context.WithCancel(context.Background())
+	peer := &Peer{ID: "fuzz-peer", Name: "fuzz", JoinTime: time.Now(), ctx: ctx, cancel: cancel}
+	room.Lock.Lock()
+	room.Peers[peer.ID] = peer
+	room.Lock.Unlock()
+	if err := h.setupWebRTC(ctx, room, peer); err != nil {
+		t.Fatalf("setupWebRTC: %v", err)
+	}
+	t.Cleanup(func() {
+		peer.SignalDone()
+		_ = peer.PC.Close()
+	})
+	return h, room, peer
+}
+
+// clientOffer returns an audio offer like the browser's first message.
+func clientOffer(t testing.TB) string {
+	t.Helper()
+	api, err := client.NewAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if _, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return offer.SDP
+}
+
+// checkNegotiationState fails if a message left the peer unable to negotiate
+// yet still connected: an applied offer that was never answered, or a
+// negotiation lock still held.
+func checkNegotiationState(t *testing.T, peer *Peer) {
+	t.Helper()
+	if state := peer.PC.SignalingState(); state == webrtc.SignalingStateHaveRemoteOffer {
+		select {
+		case <-peer.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("remote offer left unanswered")
+		}
+	}
+	if !peer.NegotiationMu.TryLock() {
+		t.Fatalf("NegotiationMu still held")
+	}
+	peer.NegotiationMu.Unlock()
+	if !peer.PendingCandidatesMu.TryLock() {
+		t.Fatalf("PendingCandidatesMu still held")
+	}
+	peer.PendingCandidatesMu.Unlock()
+}
+
+func FuzzHandleSignalingMessage(f *testing.F) {
+	offer := clientOffer(f)
+	seed := func(v any) {
+		data, _ := json.Marshal(v)
+		f.Add(data, false)
+	}
+	seed(map[string]any{"type": "offer", "sdp": offer})
+	seed(map[string]any{"type": "offer", "sdp": strings.Replace(offer, "m=audio", "m=video", 1)})
+	seed(map[string]any{"type": "offer", "sdp": "v=0\r\n"})
+	seed(map[string]any{"type": "answer", "sdp": offer})
+	seed(map[string]any{"type": "candidate", "candidate": map[string]any{"candidate": "candidate:1 1 udp 2130706431 10.0.0.1 5000 typ host", "sdpMid": "0"}})
+	seed(map[string]any{"type": "hello", "capabilities": map[string]any{"codecs": []string{"opus"}}})
+	seed(map[string]any{"type": "quality", "value": "low"})
+	seed(map[string]any{"type": "subscribe", "peer_id": "fuzz-peer"})
+	seed(map[string]any{"type": "set_room_metadata", "name": "x"})
+	f.Fuzz(func(t *testing.T, data []byte, binary bool) {
+		msg, err := parseSignalingMessage(data, binary)
+		if err != nil {
+			return
+		}
+		h, room, peer := newFuzzPeer(t)
+		h.handleSignalingMessage(room, peer, msg)
+		checkNegotiationState(t, peer)
+	})
+}
+
+func FuzzCandidate(f *testing.F) {
+	offer := clientOffer(f)
+	for _, seed := range []string{
+		`{"candidate":"candidate:1 1 udp 2130706431 10.0.0.1 5000 typ host","sdpMid":"0","sdpMLineIndex":0}`,
+		`{"candidate":"candidate:1 1 tcp 1 ::1 9 typ host tcptype active","sdpMid":"0"}`,
+		`{"candidate":"candidate:1 1 udp 1 example.local 5000 typ host"}`,
+		`{"candidate":"","sdpMid":null}`,
+		`{"candidate":"candidate:1 1 udp 1 1.2.3.4 99999 typ relay raddr 0.0.0.0 rport 0","sdpMLineIndex":-1}`,
+		`{"candidate":"candidate","usernameFragment":"x"}`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, candidate string) {
+		msg, err := parseSignalingMessage([]byte(`{"type":"candidate","candidate":`+candidate+`}`), false)
+		if err != nil {
+			return
+		}
+		h, room, peer := newFuzzPeer(t)
+		// Before the offer the candidate is queued; after it, it is applied.
+		h.handleSignalingMessage(room, peer, msg)
+		h.handleSignalingMessage(room, peer, map[string]any{"type": "offer", "sdp": offer})
+		h.handleSignalingMessage(room, peer, msg)
+		checkNegotiationState(t, peer)
+	})
+}
+
+func TestCollidingClientOfferIsIgnored(t *testing.T) {
+	h, room, peer := newFuzzPeer(t)
+	offer := map[string]any{"type": "offer", "sdp": clientOffer(t)}
+	h.handleSignalingMessage(room, peer, offer)
+	if peer.ignoringOffer() {
+		t.Fatal("first offer was ignored")
+	}
+
+	// Answering adds the heartbeat data channel, so the server offers next.
+	deadline := time.Now().Add(2 * time.Second)
+	for peer.PC.SignalingState() != webrtc.SignalingStateHaveLocalOffer {
+		if time.Now().After(deadline) {
+			t.Fatalf("signaling state = %v, want have-local-offer", peer.PC.SignalingState())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	h.handleSignalingMessage(room, peer, offer)
+	if !peer.ignoringOffer() {
+		t.Fatal("colliding offer was not ignored")
+	}
+	if state := peer.PC.SignalingState(); state != webrtc.SignalingStateHaveLocalOffer {
+		t.Fatalf("signaling state = %v after collision, want have-local-offer", state)
+	}
+	checkNegotiationState(t, peer)
+}
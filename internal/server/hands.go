@@ -0,0 +1,68 @@
+package server
+
+import (
+	"log/slog"
+	"slices"
+
+	"sigmartc/internal/logger"
+)
+
+// handQueue returns a copy of the raise-hand queue. The caller must hold r.Lock.
+func (r *Room) handQueue() []string {
+	return append([]string{}, r.hands...)
+}
+
+// raiseHand appends peerID to the queue if it is not already waiting.
+func (r *Room) raiseHand(peerID string) {
+	r.Lock.Lock()
+	if slices.Contains(r.hands, peerID) {
+		r.Lock.Unlock()
+		return
+	}
+	r.hands = append(r.hands, peerID)
+	queue := r.handQueue()
+	r.Lock.Unlock()
+	r.broadcastHands(queue)
+}
+
+// lowerHand removes peerID from the queue, if present.
+func (r *Room) lowerHand(peerID string) {
+	r.Lock.Lock()
+	i := slices.Index(r.hands, peerID)
+	if i < 0 {
+		r.Lock.Unlock()
+		return
+	}
+	r.hands = slices.Delete(r.hands, i, i+1)
+	queue := r.handQueue()
+	r.Lock.Unlock()
+	r.broadcastHands(queue)
+}
+
+// callNext pops the first raised hand and announces who was called on.
+func (r *Room) callNext() (string, bool) {
+	r.Lock.Lock()
+	if len(r.hands) == 0 {
+		r.Lock.Unlock()
+		return "", false
+	}
+	peerID := r.hands[0]
+	r.hands = slices.Delete(r.hands, 0, 1)
+	queue := r.handQueue()
+	r.Lock.Unlock()
+
+	logger.LogEvent("HAND_CALLED", slog.String("uuid", r.UUID), slog.String("peer_id", peerID))
+	r.Broadcast("", map[string]any{
+		"type":    "called_on",
+		"peer_id": peerID,
+	})
+	r.broadcastHands(queue)
+	return peerID, true
+}
+
+func (r *Room) broadcastHands(queue []string) {
+	r.Broadcast("", map[string]any{
+		"type":  "hand_queue",
+		"queue": queue,
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestRaiseHandQueue(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "room-hands", "alice")
+	state := readUntilType(t, alice, "room_state")
+	aliceID, _ := state["self_id"].(string)
+	bob := dialTestWS(t, srv.URL, "room-hands", "bob")
+	state = readUntilType(t, bob, "room_state")
+	bobID, _ := state["self_id"].(string)
+
+	if err := bob.WriteJSON(map[string]string{"type": "raise_hand"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	readUntilType(t, alice, "hand_queue")
+	if err := alice.WriteJSON(map[string]string{"type": "raise_hand"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	msg := readUntilType(t, alice, "hand_queue")
+	if queue, _ := msg["queue"].([]any); len(queue) != 2 || queue[0] != bobID || queue[1] != aliceID {
+		t.Fatalf("queue = %v, want [bob alice]", msg["queue"])
+	}
+
+	carol := dialTestWS(t, srv.URL, "room-hands", "carol")
+	state = readUntilType(t, carol, "room_state")
+	if hands, _ := state["hands"].([]any); len(hands) != 2 {
+		t.Fatalf("room_state hands = %v, want both raised hands", state["hands"])
+	}
+
+	// Non-hosts cannot call on people.
+	if err := alice.WriteJSON(map[string]string{"type": "call_next"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	readUntilType(t, alice, "error")
+
+	handler.RoomManager.Lock.RLock()
+	room := handler.RoomManager.Rooms["room-hands"]
+	handler.RoomManager.Lock.RUnlock()
+	if peerID, ok := room.callNext(); !ok || peerID != bobID {
+		t.Fatalf("callNext = %q, %v; want bob", peerID, ok)
+	}
+	if msg := readUntilType(t, carol, "called_on"); msg["peer_id"] != bobID {
+		t.Fatalf("called_on = %v, want bob", msg["peer_id"])
+	}
+
+	_ = alice.Close()
+	for {
+		msg := readUntilType(t, carol, "hand_queue")
+		if queue, _ := msg["queue"].([]any); len(queue) == 0 {
+			break
+		}
+	}
+}
+
+func TestAdminCallNextWithoutHands(t *testing.T) {
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), nil, nil)
+	rm.GetOrCreateRoom("quiet")
+	rec := adminRequest(t, handler, http.MethodPost, "action=call_next&room=quiet")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"sigmartc/internal/egress"
+	"sigmartc/internal/logger"
+)
+
+const (
+	hlsTapID = "hls"
+	// hlsListenerWindow is how recently a client must have fetched the playlist to count
+	// as listening. Players refetch it roughly once per segment.
+	hlsListenerWindow = 10 * time.Second
+	// hlsIdleTimeout stops the encoder once nobody has fetched the playlist for this long.
+	hlsIdleTimeout = 30 * time.Second
+	// hlsStartupTimeout bounds how long the first playlist request waits for ffmpeg.
+	hlsStartupTimeout = 10 * time.Second
+)
+
+// errHLSDisabled means HLS output is not configured on this server.
+var errHLSDisabled = errors.New("HLS is not enabled on this server")
+
+var hlsFilePattern = regexp.MustCompile(`^(index\.m3u8|init\.mp4|seg\d+\.m4s)$`)
+
+// hlsStream is a room's on-demand HLS output and its listener bookkeeping.
+type hlsStream struct {
+	session *egress.Session
+	dir     string
+
+	mu          sync.Mutex
+	listeners   map[string]time.Time
+	lastRequest time.Time
+}
+
+func (s *hlsStream) touch(listener string, playlist bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.lastRequest = now
+	if playlist {
+		s.listeners[listener] = now
+	}
+}
+
+func (s *hlsStream) listenerCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := time.Now().Add(-hlsListenerWindow)
+	for listener, seen := range s.listeners {
+		if seen.Before(cutoff) {
+			delete(s.listeners, listener)
+		}
+	}
+	return len(s.listeners)
+}
+
+func (s *hlsStream) idleFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastRequest)
+}
+
+// EnableHLS lets the room be played over HLS and returns the playlist path,
+// which carries the room's listener token. Calling it again returns the same
+// path.
+func (h *Handler) EnableHLS(roomUUID string) (string, error) {
+	if h.HLSDir == "" || h.FFmpegPath == "" {
+		return "", errHLSDisabled
+	}
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return "", errRoomNotFound
+	}
+	if room.IsE2EE() {
+		return "", errE2EERoom
+	}
+	room.egressMu.Lock()
+	defer room.egressMu.Unlock()
+	if room.hlsToken == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		room.hlsToken = hex.EncodeToString(buf)
+	}
+	return "/hls/" + roomUUID + "/" + room.hlsToken + "/" + egress.HLSPlaylist, nil
+}
+
+// DisableHLS revokes the room's listener token and stops its HLS output. It
+// reports whether HLS was enabled.
+func (h *Handler) DisableHLS(roomUUID string) bool {
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return false
+	}
+	room.egressMu.Lock()
+	enabled := room.hlsToken != ""
+	room.hlsToken = ""
+	room.egressMu.Unlock()
+	room.stopHLS()
+	return enabled
+}
+
+// hlsTokenValid reports whether token is the room's HLS listener token.
+func (r *Room) hlsTokenValid(token string) bool {
+	r.egressMu.Lock()
+	defer r.egressMu.Unlock()
+	return r.hlsToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(r.hlsToken)) == 1
+}
+
+// HandleHLS serves /hls/{room}/{token}/index.m3u8 and its segments, starting
+// the room's encoder on the first request. Rooms are only served after
+// EnableHLS, to holders of the token, and listeners face the same ban and
+// GeoIP checks and room lock as joins. The token sits in the path so players
+// carry it to the segments, whose playlist URIs are relative.
+func (h *Handler) HandleHLS(w http.ResponseWriter, r *http.Request) {
+	if h.HLSDir == "" || h.FFmpegPath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	// Tenant room keys contain a slash, so split from the end.
+	rest, file, _ := cutLast(strings.TrimPrefix(r.URL.Path, "/hls/"), "/")
+	roomUUID, token, ok := cutLast(rest, "/")
+	if !ok || roomUUID == "" || !hlsFilePattern.MatchString(file) {
+		http.NotFound(w, r)
+		return
+	}
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil || !room.hlsTokenValid(token) {
+		http.NotFound(w, r)
+		return
+	}
+	ip := h.clientIP(r)
+	if h.RoomManager.IsBanned(ip) || (room.tenant != nil && h.RoomManager.IsBanned(room.tenant.key(ip))) {
+		http.Error(w, "Banned", http.StatusForbidden)
+		return
+	}
+	geo := h.geoIP()
+	if !geo.Allowed(geo.Country(ip)) {
+		http.Error(w, "Listening from your region is not allowed", http.StatusForbidden)
+		return
+	}
+	room.Lock.RLock()
+	locked := room.Locked
+	room.Lock.RUnlock()
+	if locked {
+		http.Error(w, "Room is locked", http.StatusLocked)
+		return
+	}
+	if room.IsE2EE() {
+		http.Error(w, "HLS is "+errE2EERoom.Error(), http.StatusConflict)
+		return
+	}
+
+	stream, err := room.startHLS(h.FFmpegPath, h.HLSDir)
+	if err != nil {
+		slog.Error("Failed to start HLS", "uuid", roomUUID, "err", err)
+		http.Error(w, "HLS unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	playlist := file == egress.HLSPlaylist
+	stream.touch(ip, playlist)
+
+	path := filepath.Join(stream.dir, file)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if playlist {
+		if !waitForFile(r, path, hlsStartupTimeout) {
+			http.Error(w, "HLS stream is starting", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "audio/mp4")
+	}
+	http.ServeFile(w, r, path)
+}
+
+func waitForFile(r *http.Request, path string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// startHLS returns the room's running HLS stream, starting one if needed.
+func (r *Room) startHLS(ffmpegPath, baseDir string) (*hlsStream, error) {
+	r.egressMu.Lock()
+	defer r.egressMu.Unlock()
+	if r.hls != nil {
+		return r.hls, nil
+	}
+
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, err
+	}
+	// Room IDs are client-chosen strings; never use them as path components.
+	dir, err := os.MkdirTemp(baseDir, "room-")
+	if err != nil {
+		return nil, err
+	}
+	session, err := egress.Start(egress.Config{FFmpegPath: ffmpegPath, HLSDir: dir})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	stream := &hlsStream{
+		session:     session,
+		dir:         dir,
+		listeners:   make(map[string]time.Time),
+		lastRequest: time.Now(),
+	}
+	r.hls = stream
+
+	r.ForwardersMu.RLock()
+	for senderID, forwarder := range r.Forwarders {
+		forwarder.AddTap(hlsTapID, egressTap(session, senderID, forwarder))
+	}
+	r.ForwardersMu.RUnlock()
+
+	logger.LogEvent("HLS_START", slog.String("uuid", r.UUID))
+	go r.superviseHLS(stream)
+	return stream, nil
+}
+
+// superviseHLS stops the stream once listeners go away and cleans up after it.
+func (r *Room) superviseHLS(stream *hlsStream) {
+	ticker := time.NewTicker(hlsIdleTimeout / 6)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stream.session.Done():
+			r.egressMu.Lock()
+			if r.hls == stream {
+				r.hls = nil
+				r.ForwardersMu.RLock()
+				for _, forwarder := range r.Forwarders {
+					forwarder.RemoveTap(hlsTapID)
+				}
+				r.ForwardersMu.RUnlock()
+			}
+			r.egressMu.Unlock()
+			if err := stream.session.Err(); err != nil {
+				slog.Warn("HLS encoder stopped unexpectedly", "uuid", r.UUID, "err", err)
+			}
+			os.RemoveAll(stream.dir)
+			logger.LogEvent("HLS_STOP", slog.String("uuid", r.UUID))
+			return
+		case <-ticker.C:
+			if stream.idleFor() > hlsIdleTimeout {
+				stream.session.Stop()
+			}
+		}
+	}
+}
+
+// stopHLS stops the room's HLS output, if any.
+func (r *Room) stopHLS() {
+	r.egressMu.Lock()
+	stream := r.hls
+	r.egressMu.Unlock()
+	if stream != nil {
+		stream.session.Stop()
+	}
+}
+
+// HLSListeners returns how many clients fetched the room's HLS playlist recently.
+func (r *Room) HLSListeners() int {
+	r.egressMu.Lock()
+	stream := r.hls
+	r.egressMu.Unlock()
+	if stream == nil {
+		return 0
+	}
+	return stream.listenerCount()
+}
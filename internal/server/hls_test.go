@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleHLSServesPlaylistAndCountsListeners(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for ffmpeg")
+	}
+	handler := newTestAdminHandler(t)
+	handler.HLSDir = filepath.Join(t.TempDir(), "hls")
+	handler.FFmpegPath = filepath.Join(t.TempDir(), "ffmpeg")
+	// The stand-in writes a playlist to its last argument, as ffmpeg's HLS muxer would.
+	script := "#!/bin/sh\nfor last; do :; done\ncase \"$last\" in *.m3u8) echo '#EXTM3U' >\"$last\";; esac\ncat >/dev/null\n"
+	if err := os.WriteFile(handler.FFmpegPath, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	room := handler.RoomManager.GetOrCreateRoom("room-a")
+
+	get := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		handler.HandleHLS(rec, req)
+		return rec
+	}
+
+	if rec := get("/hls/room-a/x/index.m3u8", "198.51.100.1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("room not enabled: status = %d, want 404", rec.Code)
+	}
+	rec := adminRequest(t, handler, http.MethodPost, "action=hls_enable&room=room-a")
+	var enabled struct{ URL string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &enabled); err != nil || !strings.HasSuffix(enabled.URL, "/index.m3u8") {
+		t.Fatalf("hls_enable: status = %d body %q", rec.Code, rec.Body.String())
+	}
+	base := strings.TrimSuffix(enabled.URL, "index.m3u8")
+
+	for _, path := range []string{"/hls/missing/x/index.m3u8", "/hls/room-a/wrong/index.m3u8", base + "../secret", base + "other.txt"} {
+		if rec := get(path, "198.51.100.1"); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: status = %d, want 404", path, rec.Code)
+		}
+	}
+	handler.RoomManager.BanIP("198.51.100.9")
+	if rec := get(enabled.URL, "198.51.100.9"); rec.Code != http.StatusForbidden {
+		t.Fatalf("banned listener: status = %d, want 403", rec.Code)
+	}
+
+	rec = get(enabled.URL, "198.51.100.1")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "#EXTM3U") {
+		t.Fatalf("playlist: status = %d body %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.apple.mpegurl" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if cors := rec.Header().Get("Access-Control-Allow-Origin"); cors != "" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want none", cors)
+	}
+	get(enabled.URL, "198.51.100.2")
+	get(enabled.URL, "198.51.100.2")
+	if got := room.HLSListeners(); got != 2 {
+		t.Fatalf("HLSListeners = %d, want 2", got)
+	}
+
+	room.egressMu.Lock()
+	dir := room.hls.dir
+	room.egressMu.Unlock()
+	handler.RoomManager.CloseRoom("room-a", DisconnectRoomClosed, "closed")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected HLS directory to be removed after the room closed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxImpairmentJitter bounds the added delay so a typo cannot stall audio.
+	maxImpairmentJitter = 2 * time.Second
+	// reorderDelay holds a reordered packet back by about two 20ms frames, so the
+	// packets after it overtake it.
+	reorderDelay = 45 * time.Millisecond
+)
+
+var (
+	errImpairmentDisabled = errors.New("impairment is disabled; start the server with -chaos")
+	errInvalidImpairment  = errors.New("loss and reorder must be 0-100 percent and jitter 0-2s")
+)
+
+// Impairment degrades forwarded audio on purpose, so NACK, jitter buffer and
+// reconnection handling can be exercised in tests and staging. It applies to
+// every packet written to a subscriber; taps such as egress are unaffected.
+type Impairment struct {
+	// Loss is the percentage of packets dropped.
+	Loss float64
+	// Jitter delays each packet by a random duration up to this long.
+	Jitter time.Duration
+	// Reorder is the percentage of packets held back by reorderDelay.
+	Reorder float64
+}
+
+// parseImpairment reads loss, jitter and reorder from admin query parameters.
+// Missing parameters are zero.
+func parseImpairment(query url.Values) (Impairment, error) {
+	var imp Impairment
+	var err error
+	percent := func(name string) float64 {
+		value := query.Get(name)
+		if value == "" || err != nil {
+			return 0
+		}
+		p, parseErr := strconv.ParseFloat(value, 64)
+		if parseErr != nil || p < 0 || p > 100 {
+			err = errInvalidImpairment
+		}
+		return p
+	}
+	imp.Loss = percent("loss")
+	imp.Reorder = percent("reorder")
+	if value := query.Get("jitter"); value != "" && err == nil {
+		imp.Jitter, err = time.ParseDuration(value)
+		if err != nil || imp.Jitter < 0 || imp.Jitter > maxImpairmentJitter {
+			err = errInvalidImpairment
+		}
+	}
+	return imp, err
+}
+
+// active reports whether the impairment changes anything.
+func (imp Impairment) active() bool {
+	return imp.Loss > 0 || imp.Jitter > 0 || imp.Reorder > 0
+}
+
+func (imp Impairment) String() string {
+	return fmt.Sprintf("loss=%g%% jitter=%v reorder=%g%%", imp.Loss, imp.Jitter, imp.Reorder)
+}
+
+// decide returns whether to send one packet and how long to hold it first.
+func (imp *Impairment) decide() (delay time.Duration, send bool) {
+	if imp.Loss > 0 && rand.Float64()*100 < imp.Loss {
+		return 0, false
+	}
+	if imp.Jitter > 0 {
+		delay = rand.N(imp.Jitter)
+	}
+	if imp.Reorder > 0 && rand.Float64()*100 < imp.Reorder {
+		delay += reorderDelay
+	}
+	return delay, true
+}
+
+// SetImpairment applies imp to all forwarded audio; an inactive one clears it.
+// It fails unless Chaos is set.
+func (h *Handler) SetImpairment(imp Impairment) error {
+	if !h.Chaos {
+		return errImpairmentDisabled
+	}
+	if !imp.active() {
+		h.impairment.Store(nil)
+		return nil
+	}
+	h.impairment.Store(&imp)
+	return nil
+}
+
+// Impairment returns the impairment in effect, the zero value when none is.
+func (h *Handler) Impairment() Impairment {
+	if imp := h.impairment.Load(); imp != nil {
+		return *imp
+	}
+	return Impairment{}
+}
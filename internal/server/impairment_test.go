@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseImpairment(t *testing.T) {
+	imp, err := parseImpairment(url.Values{"loss": {"5"}, "jitter": {"30ms"}, "reorder": {"2.5"}})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if imp.Loss != 5 || imp.Jitter != 30*time.Millisecond || imp.Reorder != 2.5 {
+		t.Fatalf("unexpected impairment %+v", imp)
+	}
+	if imp, err := parseImpairment(url.Values{}); err != nil || imp.active() {
+		t.Fatalf("empty query should clear: %+v, %v", imp, err)
+	}
+	for _, query := range []url.Values{
+		{"loss": {"101"}},
+		{"reorder": {"-1"}},
+		{"loss": {"x"}},
+		{"jitter": {"3s"}},
+		{"jitter": {"-1ms"}},
+	} {
+		if _, err := parseImpairment(query); err == nil {
+			t.Fatalf("expected %v to be rejected", query)
+		}
+	}
+}
+
+func TestImpairmentDecide(t *testing.T) {
+	drop := Impairment{Loss: 100}
+	for range 100 {
+		if _, send := drop.decide(); send {
+			t.Fatal("100% loss sent a packet")
+		}
+	}
+	reorder := Impairment{Reorder: 100, Jitter: 10 * time.Millisecond}
+	for range 100 {
+		delay, send := reorder.decide()
+		if !send || delay < reorderDelay || delay >= reorderDelay+10*time.Millisecond {
+			t.Fatalf("unexpected decision: delay=%v send=%t", delay, send)
+		}
+	}
+}
+
+func TestAdminImpairRequiresChaos(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	post := func(query string) *httptest.ResponseRecorder {
+		return adminRequest(t, handler, http.MethodPost, "action=impair&"+query)
+	}
+	if rec := post("loss=10"); rec.Code != http.StatusForbidden {
+		t.Fatalf("without -chaos: status = %d", rec.Code)
+	}
+
+	handler.Chaos = true
+	if rec := post("loss=200"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid loss: status = %d", rec.Code)
+	}
+	rec := post("loss=10&jitter=20ms")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set: status = %d: %s", rec.Code, rec.Body)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got["loss_percent"] != 10.0 || got["jitter_ms"] != 20.0 {
+		t.Fatalf("unexpected response %v", got)
+	}
+	if handler.impairment.Load() == nil {
+		t.Fatal("impairment not applied")
+	}
+	if rec := post(""); rec.Code != http.StatusOK || handler.impairment.Load() != nil {
+		t.Fatalf("clear: status = %d, impairment = %v", rec.Code, handler.impairment.Load())
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+const (
+	// jitterBufferMaxPackets bounds memory if a publisher stalls a sequence gap.
+	jitterBufferMaxPackets = 64
+	// jitterBufferResetWindow is how far behind the release point a packet may be
+	// and still count as late. Further back, the publisher restarted or jumped its
+	// sequence numbers, and the buffer follows rather than dropping everything
+	// until they catch up.
+	jitterBufferResetWindow = 2 * jitterBufferMaxPackets
+)
+
+type jitterEntry struct {
+	data    []byte
+	arrival time.Time
+}
+
+// jitterBuffer reorders RTP packets by sequence number. In-order packets are released
+// immediately; a missing packet holds later ones back for at most delay before the gap
+// is skipped, so added latency is only paid when the uplink actually reorders.
+// It is not safe for concurrent use.
+type jitterBuffer struct {
+	delay   time.Duration
+	packets map[uint16]jitterEntry
+	nextSeq uint16
+	started bool
+	// flushed holds packets released by a reset, for the next pop.
+	flushed [][]byte
+}
+
+func newJitterBuffer(delay time.Duration) *jitterBuffer {
+	return &jitterBuffer{
+		delay:   delay,
+		packets: make(map[uint16]jitterEntry),
+	}
+}
+
+// push stores a copy of packet. Packets a little older than the release point
+// are dropped; much older ones reset the buffer to their sequence number.
+func (j *jitterBuffer) push(packet []byte, now time.Time) {
+	if len(packet) < 4 {
+		return
+	}
+	seq := binary.BigEndian.Uint16(packet[2:4])
+	if !j.started {
+		j.nextSeq = seq
+		j.started = true
+	}
+	// Sequence numbers wrap; anything "behind" nextSeq is too late to reorder.
+	if behind := -int(int16(seq - j.nextSeq)); behind > jitterBufferResetWindow {
+		j.reset(seq)
+	} else if behind > 0 {
+		return
+	}
+	if _, dup := j.packets[seq]; dup {
+		return
+	}
+	j.packets[seq] = jitterEntry{data: append([]byte(nil), packet...), arrival: now}
+}
+
+// reset flushes the waiting packets in order and restarts the release point
+// at seq.
+func (j *jitterBuffer) reset(seq uint16) {
+	for len(j.packets) > 0 {
+		lowest, _ := j.lowestPending()
+		j.flushed = append(j.flushed, j.packets[lowest].data)
+		delete(j.packets, lowest)
+	}
+	j.nextSeq = seq
+}
+
+// pop returns packets ready for release, in sequence order.
+func (j *jitterBuffer) pop(now time.Time) [][]byte {
+	out := j.flushed
+	j.flushed = nil
+	for len(j.packets) > 0 {
+		if entry, ok := j.packets[j.nextSeq]; ok {
+			out = append(out, entry.data)
+			delete(j.packets, j.nextSeq)
+			j.nextSeq++
+			continue
+		}
+		// Gap: skip it once the oldest waiting packet has exceeded the delay,
+		// or when too many packets are queued behind it.
+		lowest, oldest := j.lowestPending()
+		if len(j.packets) < jitterBufferMaxPackets && now.Sub(oldest) < j.delay {
+			break
+		}
+		j.nextSeq = lowest
+	}
+	return out
+}
+
+func (j *jitterBuffer) lowestPending() (uint16, time.Time) {
+	var lowest uint16
+	var oldest time.Time
+	first := true
+	for seq, entry := range j.packets {
+		if first || seq-j.nextSeq < lowest-j.nextSeq {
+			lowest = seq
+		}
+		if first || entry.arrival.Before(oldest) {
+			oldest = entry.arrival
+		}
+		first = false
+	}
+	return lowest, oldest
+}
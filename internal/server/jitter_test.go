@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func jitterPacket(seq uint16) []byte {
+	packet := make([]byte, 12)
+	packet[0] = 0x80
+	binary.BigEndian.PutUint16(packet[2:4], seq)
+	return packet
+}
+
+func jitterSeqs(packets [][]byte) []uint16 {
+	seqs := make([]uint16, 0, len(packets))
+	for _, packet := range packets {
+		seqs = append(seqs, binary.BigEndian.Uint16(packet[2:4]))
+	}
+	return seqs
+}
+
+func TestJitterBufferReordersWithinDelay(t *testing.T) {
+	jb := newJitterBuffer(40 * time.Millisecond)
+	now := time.Now()
+
+	jb.push(jitterPacket(100), now)
+	if got := jitterSeqs(jb.pop(now)); len(got) != 1 || got[0] != 100 {
+		t.Fatalf("expected in-order packet released immediately, got %v", got)
+	}
+
+	jb.push(jitterPacket(102), now)
+	if got := jb.pop(now); len(got) != 0 {
+		t.Fatalf("expected packet after gap to be held, got %v", jitterSeqs(got))
+	}
+
+	jb.push(jitterPacket(101), now.Add(10*time.Millisecond))
+	got := jitterSeqs(jb.pop(now.Add(10 * time.Millisecond)))
+	if len(got) != 2 || got[0] != 101 || got[1] != 102 {
+		t.Fatalf("expected [101 102], got %v", got)
+	}
+}
+
+func TestJitterBufferSkipsGapAfterDelay(t *testing.T) {
+	jb := newJitterBuffer(40 * time.Millisecond)
+	now := time.Now()
+
+	jb.push(jitterPacket(65535), now)
+	jb.pop(now)
+	jb.push(jitterPacket(1), now)
+	if got := jb.pop(now.Add(20 * time.Millisecond)); len(got) != 0 {
+		t.Fatalf("expected gap to be held before delay, got %v", jitterSeqs(got))
+	}
+	got := jitterSeqs(jb.pop(now.Add(40 * time.Millisecond)))
+	if len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected gap skipped across wraparound, got %v", got)
+	}
+
+	// The lost packet arriving late is dropped rather than sent out of order.
+	jb.push(jitterPacket(0), now.Add(50*time.Millisecond))
+	if got := jb.pop(now.Add(50 * time.Millisecond)); len(got) != 0 {
+		t.Fatalf("expected late packet dropped, got %v", jitterSeqs(got))
+	}
+}
+
+func TestJitterBufferFollowsSequenceRestart(t *testing.T) {
+	jb := newJitterBuffer(40 * time.Millisecond)
+	now := time.Now()
+
+	jb.push(jitterPacket(5000), now)
+	jb.pop(now)
+	jb.push(jitterPacket(5002), now)
+
+	// A packet a little behind is late and dropped.
+	jb.push(jitterPacket(4990), now)
+	if got := jb.pop(now); len(got) != 0 {
+		t.Fatalf("expected late packet dropped and gap held, got %v", jitterSeqs(got))
+	}
+
+	// A publisher restarting its sequence numbers flushes what waits and plays on.
+	jb.push(jitterPacket(10), now)
+	jb.push(jitterPacket(11), now)
+	got := jitterSeqs(jb.pop(now))
+	if len(got) != 3 || got[0] != 5002 || got[1] != 10 || got[2] != 11 {
+		t.Fatalf("expected [5002 10 11], got %v", got)
+	}
+}
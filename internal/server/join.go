@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// deviceIDPattern bounds the client-generated device_id join parameter.
+var deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// joinErrorStatus is the HTTP status used when a join is refused before the upgrade.
+func joinErrorStatus(reason DisconnectReason) int {
+	switch reason {
+	case DisconnectInvalidName:
+		return http.StatusBadRequest
+	case DisconnectUnauthorized:
+		return http.StatusUnauthorized
+	case DisconnectBanned, DisconnectGeoBlocked, DisconnectChallengeFailed:
+		return http.StatusForbidden
+	case DisconnectRoomLocked:
+		return http.StatusLocked
+	case DisconnectIPLimit, DisconnectTenantLimit:
+		return http.StatusTooManyRequests
+	case DisconnectDuplicateSession:
+		return http.StatusConflict
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// rejectJoin refuses a WebSocket join with a machine-readable reason. Browsers hide
+// HTTP error bodies from WebSocket code, so clients that pass join_errors=ws get the
+// reason as a "disconnect" message on an upgraded socket instead of a JSON body.
+func (h *Handler) rejectJoin(w http.ResponseWriter, r *http.Request, reason DisconnectReason, message string) {
+	if r.URL.Query().Get("join_errors") == "ws" {
+		conn, err := h.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			slog.Error("WS Upgrade failed", "err", err)
+			return
+		}
+		peer := &Peer{Conn: wsConn{conn}}
+		peer.Disconnect(reason, message)
+		return
+	}
+	writeJoinError(w, reason, message)
+}
+
+// writeJoinError answers a refused join with its reason as a JSON body.
+func writeJoinError(w http.ResponseWriter, reason DisconnectReason, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(joinErrorStatus(reason))
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   string(reason),
+		"message": message,
+	})
+}
+
+// admission reports why a peer with role may not join the room right now, or ""
+// if it may. Roles with PermEnterClosed bypass the schedule and the lock but not
+// the capacity limit. The caller must hold r.Lock.
+func (r *Room) admission(role Role, now time.Time) (DisconnectReason, string) {
+	bypass := role.Can(PermEnterClosed)
+	switch {
+	case !bypass && !r.Schedule.started(now):
+		return DisconnectNotStarted, "Room opens at " + r.Schedule.StartsAt.UTC().Format(time.RFC3339)
+	case !bypass && r.Locked:
+		return DisconnectRoomLocked, "Room is locked"
+	case r.full():
+		return DisconnectRoomFull, "Room full"
+	}
+	return "", ""
+}
+
+// full reports whether the room is at the server's or its tenant's peer limit.
+// The caller must hold r.Lock.
+func (r *Room) full() bool {
+	if len(r.Peers) >= maxRoomPeers {
+		return true
+	}
+	return r.tenant != nil && r.tenant.MaxPeersPerRoom > 0 && len(r.Peers) >= r.tenant.MaxPeersPerRoom
+}
+
+// ipAdmission refuses a join from ip when the room already holds MaxPeersPerIP
+// peers from it. The caller must hold room.Lock.
+func (h *Handler) ipAdmission(room *Room, ip string) (DisconnectReason, string) {
+	limit := h.peersPerIPLimit()
+	if limit <= 0 {
+		return "", ""
+	}
+	n := 0
+	for _, peer := range room.Peers {
+		if peer.IP == ip {
+			n++
+		}
+	}
+	if n >= limit {
+		return DisconnectIPLimit, "Too many connections from your network in this room"
+	}
+	return "", ""
+}
+
+// joinDeviceID returns the request's device_id, or "" when it is missing or malformed.
+// Clients keep one random ID per browser so a second tab can be recognised.
+func joinDeviceID(r *http.Request) string {
+	id := r.URL.Query().Get("device_id")
+	if !deviceIDPattern.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// peerByDevice returns the room's peer that joined with deviceID, if any. The
+// caller must hold r.Lock.
+func (r *Room) peerByDevice(deviceID string) *Peer {
+	if deviceID == "" {
+		return nil
+	}
+	for _, peer := range r.Peers {
+		if peer.DeviceID == deviceID {
+			return peer
+		}
+	}
+	return nil
+}
+
+// SetLocked locks or unlocks a room against new joins and reports whether it exists.
+func (rm *RoomManager) SetLocked(uuid string, locked bool) bool {
+	rm.Lock.RLock()
+	room := rm.Rooms[uuid]
+	rm.Lock.RUnlock()
+	if room == nil {
+		return false
+	}
+	room.Lock.Lock()
+	room.Locked = locked
+	room.Lock.Unlock()
+	return true
+}
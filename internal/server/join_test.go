@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestJoinErrorsAreJSON(t *testing.T) {
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), nil, nil)
+	rm.BanIP("192.0.2.10")
+	rm.GetOrCreateRoom("locked")
+	rm.SetLocked("locked", true)
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   string
+	}{
+		{"invalid name", "/ws?room=a&name=", http.StatusBadRequest, "invalid_name"},
+		{"banned", "/ws?room=a&name=bob", http.StatusForbidden, "banned"},
+		{"locked", "/ws?room=locked&name=carol", http.StatusLocked, "room_locked"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.code == "banned" {
+				req.RemoteAddr = "192.0.2.10:1234"
+			}
+			rec := httptest.NewRecorder()
+			handler.HandleWS(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.status)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("invalid JSON body: %v", err)
+			}
+			if body["error"] != tc.code {
+				t.Fatalf("error = %q, want %q", body["error"], tc.code)
+			}
+		})
+	}
+}
+
+func TestJoinErrorsAsFirstWSMessage(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	handler.RoomManager.GetOrCreateRoom("locked")
+	handler.RoomManager.SetLocked("locked", true)
+
+	wsURL, err := buildWSURL(srv.URL, "locked", "alice")
+	if err != nil {
+		t.Fatalf("failed to build ws url: %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"&join_errors=ws", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	msg := readUntilType(t, conn, "disconnect")
+	if msg["reason"] != string(DisconnectRoomLocked) {
+		t.Fatalf("reason = %v, want %q", msg["reason"], DisconnectRoomLocked)
+	}
+}
+
+func TestMaxPeersPerIP(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	handler.MaxPeersPerIP = 1
+	readUntilType(t, dialTestWS(t, srv.URL, "ip-limit", "alice"), "room_state")
+
+	wsURL, err := buildWSURL(srv.URL, "ip-limit", "bob")
+	if err != nil {
+		t.Fatalf("failed to build ws url: %v", err)
+	}
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil {
+		t.Fatal("expected a second join from the same IP to be refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("response = %v, want status %d", resp, http.StatusTooManyRequests)
+	}
+
+	// Other rooms are counted separately.
+	conn := dialTestWS(t, srv.URL, "other", "bob")
+	readUntilType(t, conn, "room_state")
+}
+
+func TestSessionTakeover(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	wsURL, err := buildWSURL(srv.URL, "tabs", "alice")
+	if err != nil {
+		t.Fatalf("failed to build ws url: %v", err)
+	}
+	wsURL += "&device_id=device-0001"
+	first, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer first.Close()
+	oldID, _ := readUntilType(t, first, "room_state")["self_id"].(string)
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected duplicate_session (409), got err=%v resp=%v", err, resp)
+	}
+
+	second, _, err := websocket.DefaultDialer.Dial(wsURL+"&takeover=1", nil)
+	if err != nil {
+		t.Fatalf("takeover dial failed: %v", err)
+	}
+	defer second.Close()
+	state := readUntilType(t, second, "room_state")
+	for _, p := range state["peers"].([]any) {
+		if p.(map[string]any)["id"] == oldID {
+			t.Fatalf("peers = %v, want the old session excluded", state["peers"])
+		}
+	}
+	msg := readUntilType(t, first, "disconnect")
+	if msg["reason"] != string(DisconnectSessionTakeover) {
+		t.Fatalf("reason = %v, want %q", msg["reason"], DisconnectSessionTakeover)
+	}
+
+	room := handler.RoomManager.Rooms["tabs"]
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		room.Lock.RLock()
+		n := len(room.Peers)
+		room.Lock.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("room has %d peers, want 1", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	jwksTimeout = 5 * time.Second
+	// jwksRefreshInterval is how often keys are refetched; an unknown kid also
+	// triggers a refetch, at most once per jwksMinRefresh.
+	jwksRefreshInterval = time.Hour
+	jwksMinRefresh      = time.Minute
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+// Identity is who a verified token says the peer is.
+type Identity struct {
+	UserID string
+	Name   string
+	Role   string
+}
+
+// UserID returns the identity provider's user ID, or "" for anonymous peers.
+func (p *Peer) UserID() string {
+	if p.Identity == nil {
+		return ""
+	}
+	return p.Identity.UserID
+}
+
+// JWTAuth requires /ws joins to carry a JWT from an external identity provider,
+// verified against the provider's JWKS. The token is passed as the token query
+// parameter (browsers cannot set headers on WebSocket requests) or as a Bearer
+// Authorization header. The sub claim is the user ID; NameClaim and RoleClaim
+// name the display-name and role claims.
+type JWTAuth struct {
+	JWKSURL string
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer    string
+	Audience  string
+	NameClaim string
+	RoleClaim string
+	Client    *http.Client
+
+	mu      sync.RWMutex
+	keys    map[string]any
+	fetched time.Time
+}
+
+// NewJWTAuth creates a verifier for tokens signed by the keys at jwksURL.
+func NewJWTAuth(jwksURL, issuer, audience string) *JWTAuth {
+	return &JWTAuth{
+		JWKSURL:   jwksURL,
+		Issuer:    issuer,
+		Audience:  audience,
+		NameClaim: "name",
+		RoleClaim: "role",
+		Client:    &http.Client{Timeout: jwksTimeout},
+	}
+}
+
+// Authenticate verifies the token attached to r.
+func (a *JWTAuth) Authenticate(ctx context.Context, r *http.Request) (*Identity, error) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("%w: missing token", errUnauthorized)
+	}
+	return a.Verify(ctx, token)
+}
+
+// Verify checks the token's signature, expiry, issuer and audience and returns
+// the identity it carries.
+func (a *JWTAuth) Verify(ctx context.Context, token string) (*Identity, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if a.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.Issuer))
+	}
+	if a.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.Audience))
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.key(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errUnauthorized, err)
+	}
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no sub claim", errUnauthorized)
+	}
+	identity := &Identity{UserID: subject}
+	identity.Name, _ = claims[a.NameClaim].(string)
+	identity.Role, _ = claims[a.RoleClaim].(string)
+	return identity, nil
+}
+
+// key returns the verification key for kid, refetching the JWKS when it is stale
+// or does not know kid yet (the provider may have rotated keys).
+func (a *JWTAuth) key(ctx context.Context, kid string) (any, error) {
+	a.mu.RLock()
+	key, ok := a.keys[kid]
+	age := time.Since(a.fetched)
+	a.mu.RUnlock()
+	if ok && age < jwksRefreshInterval {
+		return key, nil
+	}
+	if !ok && age < jwksMinRefresh {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if err := a.refresh(ctx); err != nil {
+		if ok {
+			// Keep using a known key while the provider is unreachable.
+			return key, nil
+		}
+		return nil, err
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (a *JWTAuth) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.JWKSURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: %s", resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode JWKS: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys of types we do not support rather than failing the whole set.
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	a.mu.Lock()
+	a.keys = keys
+	a.fetched = time.Now()
+	a.mu.Unlock()
+	return nil
+}
+
+// jsonWebKey is the subset of RFC 7517 needed for RSA, EC and Ed25519 public keys.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC point not on curve")
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
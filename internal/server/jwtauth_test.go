@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+func newTestJWKS(t *testing.T) (*ecdsa.PrivateKey, *httptest.Server) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks := map[string]any{"keys": []map[string]string{{
+		"kty": "EC", "kid": "k1", "use": "sig", "crv": "P-256",
+		"x": encode(key.X.FillBytes(make([]byte, 32))),
+		"y": encode(key.Y.FillBytes(make([]byte, 32))),
+	}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(srv.Close)
+	return key, srv
+}
+
+func signTestToken(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJWTAuthVerify(t *testing.T) {
+	key, jwks := newTestJWKS(t)
+	auth := NewJWTAuth(jwks.URL, "https://idp.example", "sigmartc")
+	exp := time.Now().Add(time.Hour).Unix()
+	ctx := context.Background()
+
+	identity, err := auth.Verify(ctx, signTestToken(t, key, "k1", jwt.MapClaims{
+		"iss": "https://idp.example", "aud": "sigmartc", "exp": exp,
+		"sub": "user-42", "name": "Alice", "role": "moderator",
+	}))
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if *identity != (Identity{UserID: "user-42", Name: "Alice", Role: "moderator"}) {
+		t.Fatalf("identity = %+v", identity)
+	}
+
+	bad := map[string]string{
+		"expired":     signTestToken(t, key, "k1", jwt.MapClaims{"iss": "https://idp.example", "aud": "sigmartc", "exp": time.Now().Add(-time.Hour).Unix(), "sub": "u"}),
+		"audience":    signTestToken(t, key, "k1", jwt.MapClaims{"iss": "https://idp.example", "aud": "other", "exp": exp, "sub": "u"}),
+		"no subject":  signTestToken(t, key, "k1", jwt.MapClaims{"iss": "https://idp.example", "aud": "sigmartc", "exp": exp}),
+		"unknown kid": signTestToken(t, key, "k2", jwt.MapClaims{"iss": "https://idp.example", "aud": "sigmartc", "exp": exp, "sub": "u"}),
+		"garbage":     "not.a.jwt",
+	}
+	for name, token := range bad {
+		if _, err := auth.Verify(ctx, token); !errors.Is(err, errUnauthorized) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestJWTAuthJoin(t *testing.T) {
+	key, jwks := newTestJWKS(t)
+	handler, srv := newTestWSServer(t)
+	handler.JWTAuth = NewJWTAuth(jwks.URL, "", "")
+
+	rec := httptest.NewRecorder()
+	handler.HandleWS(rec, httptest.NewRequest(http.MethodGet, "/ws?room=a&name=bob", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("join without token: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	token := signTestToken(t, key, "k1", jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix(), "sub": "user-7", "name": "Carol"})
+	wsURL, err := buildWSURL(srv.URL, "jwt-room", "ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"&token="+token, nil)
+	if err != nil {
+		t.Fatalf("join with token: %v", err)
+	}
+	defer conn.Close()
+	state := readUntilType(t, conn, "room_state")
+
+	_, peer := handler.RoomManager.FindPeer(state["self_id"].(string))
+	if peer == nil || peer.Name != "Carol" || peer.UserID() != "user-7" {
+		t.Fatalf("peer = %+v", peer)
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"sigmartc/internal/logger"
+)
+
+// maxICEFailures is how many times in a row a peer connection may fail before
+// the server gives up restarting ICE and disconnects the peer.
+const maxICEFailures = 3
+
+// PeerState is where a peer is in its session. It only moves forward:
+// joining → active → leaving → closed.
+type PeerState int32
+
+const (
+	// PeerJoining peers have an upgraded socket but are not in a room yet.
+	PeerJoining PeerState = iota
+	// PeerActive peers are in a room's Peers map.
+	PeerActive
+	// PeerLeaving peers are being torn down by Close.
+	PeerLeaving
+	// PeerClosed peers have been fully torn down.
+	PeerClosed
+)
+
+func (s PeerState) String() string {
+	switch s {
+	case PeerJoining:
+		return "joining"
+	case PeerActive:
+		return "active"
+	case PeerLeaving:
+		return "leaving"
+	case PeerClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the peer's lifecycle state.
+func (p *Peer) State() PeerState {
+	return PeerState(p.state.Load())
+}
+
+// advance moves the peer to state s and reports false if the peer was already
+// there or past it.
+func (p *Peer) advance(s PeerState) bool {
+	for {
+		current := p.state.Load()
+		if current >= int32(s) {
+			return false
+		}
+		if p.state.CompareAndSwap(current, int32(s)) {
+			return true
+		}
+	}
+}
+
+// Close tears the peer's session down: it stops the peer's goroutines, closes
+// its socket and peer connection, removes it and its audio from its room, and
+// tells the room it left. Kicks, shutdown, WebSocket errors and ICE failure all
+// end here, either directly or through Disconnect. reason is recorded unless
+// Disconnect already recorded one; "" means the client left on its own. Only
+// the first call has any effect.
+func (p *Peer) Close(reason DisconnectReason) {
+	if !p.advance(PeerLeaving) {
+		return
+	}
+	p.disconnectMu.Lock()
+	if p.disconnectReason == "" {
+		p.disconnectReason = reason
+	}
+	reason = p.disconnectReason
+	p.disconnectMu.Unlock()
+	// Say goodbye before the socket closes, unless Disconnect already did or
+	// the client is gone, so the browser's onclose gets a code and reason.
+	p.sendClose(closeFrame{code: reason.closeCode(), text: string(reason)})
+	p.SignalDone()
+
+	// The peer may have been moved since joining; clean up where it is now. A
+	// peer refused after the upgrade never had a room.
+	room := p.Room()
+	if room == nil {
+		p.closeTransports()
+		p.advance(PeerClosed)
+		return
+	}
+	// Unsubscribe this peer from all forwarders (so they stop sending to this peer)
+	room.ForwardersMu.RLock()
+	for _, forwarder := range room.Forwarders {
+		forwarder.Unsubscribe(p.ID)
+	}
+	room.ForwardersMu.RUnlock()
+
+	// Stop and remove this peer's own forwarder if they were sending audio
+	room.ForwardersMu.Lock()
+	if forwarder, exists := room.Forwarders[p.ID]; exists {
+		forwarder.Stop()
+		delete(room.Forwarders, p.ID)
+	}
+	room.ForwardersMu.Unlock()
+
+	room.Lock.Lock()
+	room.removePeer(p.ID)
+	if len(room.Peers) == 0 {
+		room.LastEmptyTime = time.Now()
+	}
+	usage := room.usageLeave(p.ID, time.Now())
+	receivers := make([]*Peer, 0, len(room.Peers))
+	for _, other := range room.Peers {
+		receivers = append(receivers, other)
+	}
+	room.Lock.Unlock()
+	p.closeTransports()
+
+	// Remove the senders that carried this peer's audio. RemoveTrack renegotiates
+	// each receiver, so dead tracks are not offered for the rest of its session.
+	for _, receiver := range receivers {
+		receiver.removeOutTrack(p.ID)
+	}
+
+	reason = p.DisconnectReason()
+	p.note(TimelineLeft, string(reason))
+	logger.LogEvent("USER_LEAVE", slog.String("uuid", room.UUID), slog.String("peer_id", p.ID), slog.String("session_id", p.SessionID), slog.String("reason", string(reason)))
+	if rm := p.manager; rm != nil {
+		rm.recordUsage(usage)
+		rm.rememberTimeline(p)
+		rm.emit(EventUserLeave, map[string]any{
+			"room":             room.UUID,
+			"peer_id":          p.ID,
+			"reason":           string(reason),
+			"duration_seconds": int(time.Since(p.JoinTime).Seconds()),
+		})
+	}
+
+	// Notify others
+	room.Broadcast(p.ID, map[string]any{
+		"type":    "peer_leave",
+		"peer_id": p.ID,
+	})
+	room.lowerHand(p.ID)
+	room.dropPrioritySpeaker(p.ID)
+	room.forgetUnmute(p.ID)
+	p.advance(PeerClosed)
+}
+
+// closeTransports closes the peer's WebSocket and peer connection.
+func (p *Peer) closeTransports() {
+	if p.Conn != nil {
+		_ = p.Conn.Close()
+	}
+	if p.PC != nil {
+		_ = p.PC.Close()
+	}
+}
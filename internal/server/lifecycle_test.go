@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestPeerStateOnlyMovesForward(t *testing.T) {
+	peer := &Peer{}
+	if peer.State() != PeerJoining {
+		t.Fatalf("initial state = %v, want joining", peer.State())
+	}
+	if !peer.advance(PeerLeaving) {
+		t.Fatal("advance to leaving failed")
+	}
+	if peer.advance(PeerActive) {
+		t.Fatal("advance from leaving back to active succeeded")
+	}
+	if peer.advance(PeerLeaving) {
+		t.Fatal("second advance to leaving succeeded")
+	}
+	if peer.State() != PeerLeaving {
+		t.Fatalf("state = %v, want leaving", peer.State())
+	}
+}
+
+func TestCloseRemovesPeerOnce(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "room-close", "alice")
+	state := readUntilType(t, alice, "room_state")
+	aliceID, _ := state["self_id"].(string)
+	bob := dialTestWS(t, srv.URL, "room-close", "bob")
+	readUntilType(t, bob, "room_state")
+
+	room, peer := handler.RoomManager.FindPeer(aliceID)
+	if peer == nil {
+		t.Fatal("expected alice to be registered")
+	}
+	if peer.State() != PeerActive {
+		t.Fatalf("state = %v, want active", peer.State())
+	}
+
+	peer.Disconnect(DisconnectKicked, "bye")
+	// Every later teardown path is a no-op and keeps the first reason.
+	peer.Close("")
+	peer.Disconnect(DisconnectShutdown, "again")
+
+	if peer.State() != PeerClosed {
+		t.Fatalf("state = %v, want closed", peer.State())
+	}
+	if got := peer.DisconnectReason(); got != DisconnectKicked {
+		t.Fatalf("reason = %q, want %q", got, DisconnectKicked)
+	}
+	room.Lock.RLock()
+	_, stillThere := room.Peers[aliceID]
+	room.Lock.RUnlock()
+	if stillThere {
+		t.Fatal("closed peer is still in the room")
+	}
+	if msg := readUntilType(t, bob, "peer_leave"); msg["peer_id"] != aliceID {
+		t.Fatalf("peer_leave = %v, want alice", msg["peer_id"])
+	}
+	_ = bob.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		var msg map[string]any
+		if err := bob.ReadJSON(&msg); err != nil {
+			break
+		}
+		if msg["type"] == "peer_leave" {
+			t.Fatalf("peer_leave sent twice: %v", msg)
+		}
+	}
+}
+
+func TestCloseRemovesPublisherTracksFromReceivers(t *testing.T) {
+	h, room, receiver := newFuzzPeer(t)
+	publisher := &Peer{ID: "publisher", Name: "pub", JoinTime: time.Now()}
+	room.Lock.Lock()
+	room.Peers[publisher.ID] = publisher
+	room.Lock.Unlock()
+	publisher.setRoom(room)
+
+	forwarder := newSyntheticForwarder(context.Background(), publisher.ID, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2})
+	room.ForwardersMu.Lock()
+	room.Forwarders[publisher.ID] = forwarder
+	room.ForwardersMu.Unlock()
+	h.subscribeToForwarder(receiver, publisher.ID, forwarder)
+	if len(receiver.PC.GetSenders()) != 1 {
+		t.Fatalf("senders = %d before leave, want 1", len(receiver.PC.GetSenders()))
+	}
+
+	publisher.Close("")
+
+	receiver.OutTracksMu.RLock()
+	_, kept := receiver.OutTracks[publisher.ID]
+	receiver.OutTracksMu.RUnlock()
+	if kept {
+		t.Fatal("receiver kept the departed publisher's out track")
+	}
+	for _, sender := range receiver.PC.GetSenders() {
+		if sender.Track() != nil {
+			t.Fatalf("receiver still sends track %q", sender.Track().ID())
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// LoadCapacity is what a node is sized for. Zero components are left out of
+// the load score.
+type LoadCapacity struct {
+	// Forwarders is how many published tracks the node can forward at once.
+	Forwarders int
+	// BandwidthBps is the RTP bitrate the node can carry in either direction.
+	BandwidthBps float64
+}
+
+// loadSampleInterval is the shortest window rates are measured over; polls
+// closer together reuse the last rates.
+const loadSampleInterval = time.Second
+
+// processStart is when the server started, the baseline of the first rates.
+var processStart = time.Now()
+
+// loadSampler turns the cumulative CPU and byte counters into rates between
+// /api/load polls.
+type loadSampler struct {
+	mu         sync.Mutex
+	at         time.Time
+	cpuSeconds float64
+	bandwidth  BandwidthStats
+
+	cpu    float64
+	bpsIn  float64
+	bpsOut float64
+}
+
+// rates returns the CPU utilization (of all cores) and the bitrates since the
+// previous sample.
+func (s *loadSampler) rates(now time.Time, cpuSeconds float64, bandwidth BandwidthStats) (cpu, bpsIn, bpsOut float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.at.IsZero() {
+		s.at = processStart
+	}
+	if elapsed := now.Sub(s.at).Seconds(); elapsed >= loadSampleInterval.Seconds() {
+		s.cpu = (cpuSeconds - s.cpuSeconds) / elapsed / float64(runtime.GOMAXPROCS(0))
+		s.bpsIn = float64(bandwidth.BytesIn-s.bandwidth.BytesIn) * 8 / elapsed
+		s.bpsOut = float64(bandwidth.BytesOut-s.bandwidth.BytesOut) * 8 / elapsed
+		s.at, s.cpuSeconds, s.bandwidth = now, cpuSeconds, bandwidth
+	}
+	return s.cpu, s.bpsIn, s.bpsOut
+}
+
+// LoadReport is the /api/load response. Score is the highest of the CPU,
+// forwarder and bandwidth utilizations, each 0 when idle and 1 at capacity;
+// it exceeds 1 on an overloaded node.
+type LoadReport struct {
+	Score      float64       `json:"score"`
+	Rooms      int           `json:"rooms"`
+	Peers      int           `json:"peers"`
+	CPU        CPULoad       `json:"cpu"`
+	Forwarders ForwarderLoad `json:"forwarders"`
+	Bandwidth  BandwidthLoad `json:"bandwidth"`
+}
+
+// CPULoad is the process's CPU use as a fraction of Cores.
+type CPULoad struct {
+	Utilization float64 `json:"utilization"`
+	Cores       int     `json:"cores"`
+}
+
+// ForwarderLoad counts the published tracks being forwarded.
+type ForwarderLoad struct {
+	Count       int     `json:"count"`
+	Capacity    int     `json:"capacity,omitempty"`
+	Utilization float64 `json:"utilization,omitempty"`
+}
+
+// BandwidthLoad is the RTP bitrate received and forwarded.
+type BandwidthLoad struct {
+	InBps       float64 `json:"in_bps"`
+	OutBps      float64 `json:"out_bps"`
+	CapacityBps float64 `json:"capacity_bps,omitempty"`
+	Utilization float64 `json:"utilization,omitempty"`
+}
+
+// Load measures the node's load against h.LoadCapacity.
+func (h *Handler) Load(now time.Time) LoadReport {
+	var report LoadReport
+	h.RoomManager.Lock.RLock()
+	report.Rooms = len(h.RoomManager.Rooms)
+	for _, room := range h.RoomManager.Rooms {
+		room.Lock.RLock()
+		report.Peers += len(room.Peers)
+		room.Lock.RUnlock()
+		room.ForwardersMu.RLock()
+		report.Forwarders.Count += len(room.Forwarders)
+		room.ForwardersMu.RUnlock()
+	}
+	h.RoomManager.Lock.RUnlock()
+
+	cpu, bpsIn, bpsOut := h.load.rates(now, processCPUSeconds(), h.RoomManager.bandwidth.snapshot())
+	report.CPU.Utilization = cpu
+	report.CPU.Cores = runtime.GOMAXPROCS(0)
+	report.Bandwidth.InBps = bpsIn
+	report.Bandwidth.OutBps = bpsOut
+	report.Score = cpu
+	if capacity := h.LoadCapacity.Forwarders; capacity > 0 {
+		report.Forwarders.Capacity = capacity
+		report.Forwarders.Utilization = float64(report.Forwarders.Count) / float64(capacity)
+		report.Score = max(report.Score, report.Forwarders.Utilization)
+	}
+	if capacity := h.LoadCapacity.BandwidthBps; capacity > 0 {
+		report.Bandwidth.CapacityBps = capacity
+		report.Bandwidth.Utilization = max(bpsIn, bpsOut) / capacity
+		report.Score = max(report.Score, report.Bandwidth.Utilization)
+	}
+	return report
+}
+
+// HandleLoad serves GET /api/load, a load score for orchestrators deciding
+// where new rooms should go.
+func (h *Handler) HandleLoad(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(h.Load(time.Now()))
+}
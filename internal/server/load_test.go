@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadSamplerRates(t *testing.T) {
+	start := time.Now()
+	s := loadSampler{at: start}
+	_, in, out := s.rates(start.Add(2*time.Second), 0, BandwidthStats{BytesIn: 1000, BytesOut: 4000})
+	if in != 4000 || out != 16000 {
+		t.Fatalf("bps = %v in, %v out; want 4000, 16000", in, out)
+	}
+	// Polls within loadSampleInterval reuse the last window.
+	if _, in, _ := s.rates(start.Add(2500*time.Millisecond), 0, BandwidthStats{BytesIn: 9000}); in != 4000 {
+		t.Fatalf("bps = %v within the sample interval, want 4000", in)
+	}
+}
+
+func TestHandleLoadScoresAgainstCapacity(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	handler.LoadCapacity = LoadCapacity{Forwarders: 4}
+	for _, id := range []string{"room-a", "room-b"} {
+		room := handler.RoomManager.GetOrCreateRoom(id)
+		room.Forwarders["sender-"+id] = &TrackForwarder{}
+	}
+	handler.RoomManager.Rooms["room-b"].Forwarders["sender-2"] = &TrackForwarder{}
+
+	rec := httptest.NewRecorder()
+	handler.HandleLoad(rec, httptest.NewRequest(http.MethodGet, "/api/load", nil))
+	var report LoadReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Rooms != 2 || report.Forwarders.Count != 3 || report.Forwarders.Utilization != 0.75 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Score < 0.75 {
+		t.Fatalf("score = %v, want at least the forwarder utilization", report.Score)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Clustered nodes keep a room on one node: before accepting a WebSocket join
+// for a room it does not host yet, a node asks its RoomLocator where the room
+// lives and, when that is another node, answers with a redirect message
+// instead of opening a second copy of the room.
+
+// locateTimeout bounds a RoomLocator lookup; on failure the join is served
+// locally rather than refused.
+const locateTimeout = 2 * time.Second
+
+// RoomLocator finds the node hosting a room.
+type RoomLocator interface {
+	// Locate returns the public base URL of the node hosting room in tenant's
+	// namespace ("" for the default one).
+	Locate(ctx context.Context, room, tenant string) (string, error)
+}
+
+// DirectorLocator asks a director ("sigmartc director") where rooms live; its
+// placements are the cluster's shared room-to-node map.
+type DirectorLocator struct {
+	URL    string
+	client *http.Client
+}
+
+// NewDirectorLocator creates a locator for the director at base URL directorURL.
+func NewDirectorLocator(directorURL string) (*DirectorLocator, error) {
+	u, err := url.Parse(directorURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid director URL %q", directorURL)
+	}
+	return &DirectorLocator{URL: strings.TrimRight(directorURL, "/"), client: &http.Client{Timeout: locateTimeout}}, nil
+}
+
+// Locate asks the director's /api/place.
+func (l *DirectorLocator) Locate(ctx context.Context, room, tenant string) (string, error) {
+	query := url.Values{"room": {room}}
+	if tenant != "" {
+		query.Set("tenant", tenant)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.URL+"/api/place?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("director: %s", resp.Status)
+	}
+	var placement struct {
+		Node string `json:"node"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, 64<<10)).Decode(&placement); err != nil {
+		return "", fmt.Errorf("director: %w", err)
+	}
+	return placement.Node, nil
+}
+
+// remoteNode returns the base URL of the node hosting the join's room when
+// that is not this one. Rooms open here stay here, and lookups that fail are
+// served locally.
+func (h *Handler) remoteNode(r *http.Request) string {
+	if h.Locator == nil || h.PublicURL == "" {
+		return ""
+	}
+	query := r.URL.Query()
+	room, tenant := strings.TrimSpace(query.Get("room")), query.Get("tenant")
+	key, _, ok := h.RoomManager.Tenants.joinRoom(tenant, room)
+	if room == "" || !ok {
+		return ""
+	}
+	h.RoomManager.Lock.RLock()
+	local := h.RoomManager.Rooms[key] != nil
+	h.RoomManager.Lock.RUnlock()
+	if local {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), locateTimeout)
+	defer cancel()
+	node, err := h.Locator.Locate(ctx, room, tenant)
+	if err != nil {
+		slog.Warn("Room lookup failed, serving locally", "uuid", key, "err", err)
+		return ""
+	}
+	if node == "" || strings.TrimRight(node, "/") == h.PublicURL {
+		return ""
+	}
+	return node
+}
+
+// redirectJoin sends a WebSocket client to the node hosting its room. ws_url
+// keeps the original join parameters.
+func (h *Handler) redirectJoin(w http.ResponseWriter, r *http.Request, node string) {
+	base, err := url.Parse(node)
+	if err != nil {
+		slog.Error("Invalid node URL from room locator", "node", node, "err", err)
+		writeJoinError(w, DisconnectServerError, "Room lookup failed")
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("WS Upgrade failed", "err", err)
+		return
+	}
+	query := r.URL.Query()
+	page := base.JoinPath("r", query.Get("room"))
+	if tenant := query.Get("tenant"); tenant != "" {
+		page.RawQuery = url.Values{"tenant": {tenant}}.Encode()
+	}
+	ws := base.JoinPath("ws")
+	ws.Scheme = strings.Replace(ws.Scheme, "http", "ws", 1)
+	ws.RawQuery = r.URL.RawQuery
+
+	slog.Info("Join redirected to another node", "uuid", query.Get("room"), "node", base.String())
+	peer := &Peer{Conn: wsConn{conn}}
+	peer.WriteJSON(map[string]any{
+		"type":   "redirect",
+		"node":   base.String(),
+		"url":    page.String(),
+		"ws_url": ws.String(),
+	})
+	peer.Disconnect(DisconnectRedirect, "Room is hosted on another node")
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeLocator struct {
+	node string
+	err  error
+}
+
+func (l fakeLocator) Locate(ctx context.Context, room, tenant string) (string, error) {
+	return l.node, l.err
+}
+
+func TestHandleWSRedirectsToRoomNode(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	handler.PublicURL = "https://media1.example.com"
+	handler.Locator = fakeLocator{node: "https://media2.example.com"}
+
+	conn := dialTestWS(t, srv.URL, "elsewhere", "alice")
+	msg := readUntilType(t, conn, "redirect")
+	if msg["node"] != "https://media2.example.com" || msg["url"] != "https://media2.example.com/r/elsewhere" {
+		t.Fatalf("redirect = %v", msg)
+	}
+	if wsURL, _ := msg["ws_url"].(string); !strings.HasPrefix(wsURL, "wss://media2.example.com/ws?") || !strings.Contains(wsURL, "name=alice") {
+		t.Fatalf("ws_url = %q", wsURL)
+	}
+	if msg := readUntilType(t, conn, "disconnect"); msg["reason"] != string(DisconnectRedirect) {
+		t.Fatalf("disconnect = %v", msg)
+	}
+	if handler.RoomManager.Rooms["elsewhere"] != nil {
+		t.Fatal("redirected join opened the room locally")
+	}
+
+	// Rooms already open here stay here, and failed lookups are served locally.
+	handler.RoomManager.GetOrCreateRoom("here")
+	readUntilType(t, dialTestWS(t, srv.URL, "here", "bob"), "room_state")
+	handler.Locator = fakeLocator{err: errors.New("director down")}
+	readUntilType(t, dialTestWS(t, srv.URL, "elsewhere", "carol"), "room_state")
+}
+
+func TestDirectorLocator(t *testing.T) {
+	director := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/place" || r.URL.Query().Get("room") != "lobby" || r.URL.Query().Get("tenant") != "acme" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"room": "lobby", "node": "https://media2.example.com"}`))
+	}))
+	defer director.Close()
+
+	locator, err := NewDirectorLocator(director.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := locator.Locate(context.Background(), "lobby", "acme")
+	if err != nil || node != "https://media2.example.com" {
+		t.Fatalf("node = %q, %v", node, err)
+	}
+	if _, err := locator.Locate(context.Background(), "other", ""); err == nil {
+		t.Fatal("expected an error for a failed placement")
+	}
+	if _, err := NewDirectorLocator("director.local"); err == nil {
+		t.Fatal("expected an error for a URL without scheme")
+	}
+}
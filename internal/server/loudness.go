@@ -0,0 +1,36 @@
+package server
+
+import "errors"
+
+// Loudness leveling: with a room's leveling on (action=room_leveling), each
+// publisher's audio is evened out by egress.SpeechNormFilter before fan-out,
+// so one loud participant does not blow out everyone's ears (see
+// audiofilter.go).
+
+var errLevelingUnavailable = errors.New("loudness leveling needs -ffmpeg-path")
+
+// SetRoomLeveling turns loudness leveling on or off for a room and its current publishers.
+func (h *Handler) SetRoomLeveling(uuid string, enabled bool) error {
+	if enabled && h.FFmpegPath == "" {
+		return errLevelingUnavailable
+	}
+	rm := h.RoomManager
+	rm.Lock.RLock()
+	room := rm.Rooms[uuid]
+	rm.Lock.RUnlock()
+	if room == nil {
+		return errRoomNotFound
+	}
+	room.leveling.Store(enabled)
+
+	room.ForwardersMu.RLock()
+	forwarders := make([]*TrackForwarder, 0, len(room.Forwarders))
+	for _, forwarder := range room.Forwarders {
+		forwarders = append(forwarders, forwarder)
+	}
+	room.ForwardersMu.RUnlock()
+	for _, forwarder := range forwarders {
+		h.applyAudioFilters(room, forwarder)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+func TestAdminRoomLevelingRoutesAudioThroughLeveler(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for ffmpeg")
+	}
+	handler := newTestAdminHandler(t)
+	handler.FFmpegPath = ""
+	room := handler.RoomManager.GetOrCreateRoom("room-a")
+	forwarder := newSyntheticForwarder(context.Background(), "publisher", webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2})
+	defer forwarder.Stop()
+	room.ForwardersMu.Lock()
+	room.Forwarders["publisher"] = forwarder
+	room.ForwardersMu.Unlock()
+
+	post := func(query string) int {
+		return adminRequest(t, handler, http.MethodPost, "action=room_leveling&"+query).Code
+	}
+	if code := post("room=room-a&enabled=1"); code != http.StatusConflict {
+		t.Fatalf("without ffmpeg: status = %d, want 409", code)
+	}
+
+	// An ffmpeg that passes the Ogg stream through unchanged.
+	handler.FFmpegPath = filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(handler.FFmpegPath, []byte("#!/bin/sh\ncat\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if code := post("room=missing&enabled=1"); code != http.StatusNotFound {
+		t.Fatalf("missing room: status = %d", code)
+	}
+	if code := post("room=room-a&enabled=1"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if forwarder.filter.Load() == nil {
+		t.Fatal("publisher has no leveler after enabling leveling")
+	}
+
+	leveled := make(chan []byte, 4)
+	forwarder.AddTap("test", func(packet []byte) { leveled <- append([]byte(nil), packet...) })
+	raw, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: 500, Timestamp: 960}, Payload: []byte{0xfc, 0xff, 0xfe}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarder.deliver(raw)
+	select {
+	case packet := <-leveled:
+		var parsed rtp.Packet
+		if err := parsed.Unmarshal(packet); err != nil || string(parsed.Payload) != "\xfc\xff\xfe" {
+			t.Fatalf("leveled packet = %x (%v)", packet, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no audio came back from the leveler")
+	}
+
+	if code := post("room=room-a&enabled=0"); code != http.StatusOK {
+		t.Fatalf("disable: status = %d", code)
+	}
+	if forwarder.filter.Load() != nil {
+		t.Fatal("publisher kept its leveler after disabling leveling")
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// audioLevelURI is the RFC 6464 client-to-mixer audio level header extension.
+const audioLevelURI = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+
+const (
+	// mimeTypeRED is RFC 2198 redundant audio. Browsers use it to carry the previous
+	// Opus frame alongside the current one so single losses can be concealed.
+	mimeTypeRED = "audio/red"
+	// Payload types match what Chrome and Firefox offer, so the "opus/opus" fmtp
+	// refers to the same Opus payload type on both sides of the SFU.
+	opusPayloadType webrtc.PayloadType = 111
+	redPayloadType  webrtc.PayloadType = 63
+)
+
+var errMalformedRED = errors.New("malformed RED payload")
+
+// NewMediaEngine returns a MediaEngine with the default codecs and the header
+// extensions the SFU relies on.
+func NewMediaEngine() (*webrtc.MediaEngine, error) {
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	// Registered after Opus so plain Opus stays the default; clients opt in to RED
+	// by preferring it with setCodecPreferences.
+	if err := m.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    mimeTypeRED,
+			ClockRate:   48000,
+			Channels:    2,
+			SDPFmtpLine: "111/111",
+		},
+		PayloadType: redPayloadType,
+	}, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, err
+	}
+	// Only negotiate audio levels on publisher (recvonly) transceivers: forwarded packets keep
+	// the publisher's extension IDs, which subscribers would otherwise misinterpret.
+	if err := m.RegisterHeaderExtension(
+		webrtc.RTPHeaderExtensionCapability{URI: audioLevelURI},
+		webrtc.RTPCodecTypeAudio,
+		webrtc.RTPTransceiverDirectionRecvonly,
+	); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// audioLevelExtensionID returns the negotiated ID of the audio level extension, or 0.
+func audioLevelExtensionID(receiver *webrtc.RTPReceiver) uint8 {
+	if receiver == nil {
+		return 0
+	}
+	for _, ext := range receiver.GetParameters().HeaderExtensions {
+		if ext.URI == audioLevelURI {
+			return uint8(ext.ID)
+		}
+	}
+	return 0
+}
+
+// negotiatedAudioPayloadTypes returns the payload types the publisher negotiated for
+// RED and Opus, or 0 for codecs that were not negotiated.
+func negotiatedAudioPayloadTypes(receiver *webrtc.RTPReceiver) (red, opus uint8) {
+	if receiver == nil {
+		return 0, 0
+	}
+	for _, codec := range receiver.GetParameters().Codecs {
+		switch strings.ToLower(codec.MimeType) {
+		case mimeTypeRED:
+			red = uint8(codec.PayloadType)
+		case strings.ToLower(webrtc.MimeTypeOpus):
+			opus = uint8(codec.PayloadType)
+		}
+	}
+	return red, opus
+}
+
+// unwrapRED returns the primary (last) block of an RFC 2198 payload.
+func unwrapRED(payload []byte) ([]byte, error) {
+	offset, dataLen := 0, 0
+	for {
+		if offset >= len(payload) {
+			return nil, errMalformedRED
+		}
+		if payload[offset]&0x80 == 0 {
+			offset++ // final header: F=0 and the primary payload type
+			break
+		}
+		if offset+4 > len(payload) {
+			return nil, errMalformedRED
+		}
+		dataLen += int(payload[offset+2]&0x03)<<8 | int(payload[offset+3])
+		offset += 4
+	}
+	if offset+dataLen > len(payload) {
+		return nil, errMalformedRED
+	}
+	return payload[offset+dataLen:], nil
+}
+
+// wrapRED encapsulates payload as a RED payload with a single primary block.
+func wrapRED(payloadType uint8, payload []byte) []byte {
+	out := make([]byte, 0, len(payload)+1)
+	out = append(out, payloadType&0x7f)
+	return append(out, payload...)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"sigmartc/internal/logger"
+)
+
+const (
+	maxRoomNameRunes  = 40
+	maxRoomTopicRunes = 200
+	maxRoomAvatarLen  = 512
+)
+
+var errInvalidMetadata = errors.New("name must be at most 40 characters, topic at most 200, avatar an https URL")
+
+// RoomMetadata is the room's display header, shown to members and in the directory.
+type RoomMetadata struct {
+	Name   string `json:"name"`
+	Topic  string `json:"topic"`
+	Avatar string `json:"avatar"`
+}
+
+// validate trims the fields and checks their limits.
+func (m *RoomMetadata) validate() error {
+	m.Name = strings.TrimSpace(m.Name)
+	m.Topic = strings.TrimSpace(m.Topic)
+	m.Avatar = strings.TrimSpace(m.Avatar)
+	if utf8.RuneCountInString(m.Name) > maxRoomNameRunes || utf8.RuneCountInString(m.Topic) > maxRoomTopicRunes ||
+		strings.ContainsAny(m.Name+m.Topic, "\r\n") || len(m.Avatar) > maxRoomAvatarLen {
+		return errInvalidMetadata
+	}
+	if m.Avatar != "" {
+		parsed, err := url.Parse(m.Avatar)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return errInvalidMetadata
+		}
+	}
+	return nil
+}
+
+// metadata returns the room's display header. The caller must hold r.Lock.
+func (r *Room) metadata() RoomMetadata {
+	return RoomMetadata{Name: r.Name, Topic: r.Topic, Avatar: r.Avatar}
+}
+
+// SetRoomMetadata updates the room's header and broadcasts room_update to its members.
+func (h *Handler) SetRoomMetadata(roomUUID string, meta RoomMetadata) error {
+	if err := meta.validate(); err != nil {
+		return err
+	}
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return errRoomNotFound
+	}
+
+	room.Lock.Lock()
+	room.Name = meta.Name
+	room.Topic = meta.Topic
+	room.Avatar = meta.Avatar
+	room.Lock.Unlock()
+
+	logger.LogEvent("ROOM_UPDATE", slog.String("uuid", roomUUID), slog.String("name", meta.Name))
+	room.Broadcast("", map[string]any{
+		"type": "room_update",
+		"room": meta,
+	})
+	return nil
+}
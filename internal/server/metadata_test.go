@@ -0,0 +1,41 @@
+package server
+
+import "testing"
+
+func TestRoomMetadataIsSentAndBroadcast(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "room-meta", "alice")
+	readUntilType(t, alice, "room_state")
+
+	meta := RoomMetadata{Name: "Standup", Topic: "Daily sync", Avatar: "https://example.com/a.png"}
+	if err := handler.SetRoomMetadata("room-meta", meta); err != nil {
+		t.Fatalf("SetRoomMetadata failed: %v", err)
+	}
+	update := readUntilType(t, alice, "room_update")
+	if room, _ := update["room"].(map[string]any); room["name"] != "Standup" || room["avatar"] != meta.Avatar {
+		t.Fatalf("room_update = %v", update["room"])
+	}
+
+	bob := dialTestWS(t, srv.URL, "room-meta", "bob")
+	state := readUntilType(t, bob, "room_state")
+	if room, _ := state["room"].(map[string]any); room["topic"] != "Daily sync" {
+		t.Fatalf("room_state room = %v", state["room"])
+	}
+}
+
+func TestRoomMetadataValidation(t *testing.T) {
+	cases := []RoomMetadata{
+		{Avatar: "http://example.com/a.png"},
+		{Avatar: "javascript:alert(1)"},
+		{Name: "line\nbreak"},
+	}
+	for _, meta := range cases {
+		if err := meta.validate(); err != errInvalidMetadata {
+			t.Errorf("validate(%+v) = %v, want errInvalidMetadata", meta, err)
+		}
+	}
+	ok := RoomMetadata{Name: "  Lobby  "}
+	if err := ok.validate(); err != nil || ok.Name != "Lobby" {
+		t.Fatalf("validate trimmed = %q, %v", ok.Name, err)
+	}
+}
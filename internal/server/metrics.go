@@ -0,0 +1,38 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"time"
+
+	"sigmartc/internal/logger"
+)
+
+// HandleMetrics serves server gauges and, with a canary configured, its results
+// in the Prometheus text exposition format. It needs no credentials and only
+// carries aggregate numbers.
+func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := h.collectStats()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetric(w, "sigmartc_rooms", "gauge", "Rooms in memory.", stats.Rooms)
+	writeMetric(w, "sigmartc_users", "gauge", "Connected peers.", stats.Users)
+	writeMetric(w, "sigmartc_goroutines", "gauge", "Goroutines.", runtime.NumGoroutine())
+	writeMetric(w, "sigmartc_load_score", "gauge", "Load score reported by /api/load.", h.Load(time.Now()).Score)
+	writeMetric(w, "sigmartc_ice_candidates_dropped_total", "counter", "ICE candidate messages dropped over the per-peer limits.", candidatesDropped.Load())
+	writeMetric(w, "sigmartc_pending_candidates_dropped_total", "counter", "ICE candidates dropped while waiting for a remote description, over the queue cap or expired.", pendingCandidatesDropped.Load())
+	writeMetric(w, "sigmartc_panics_recovered_total", "counter", "Panics caught by the recovery guards.", panicsRecovered.Load())
+	logStats := logger.GetStats()
+	writeMetric(w, "sigmartc_log_lines_dropped_total", "counter", "Log lines discarded by sinks with a full queue or a failed delivery.", logStats.DroppedLines)
+	writeMetric(w, "sigmartc_log_write_errors_total", "counter", "Failed writes to log sinks, such as a full disk.", logStats.WriteErrors)
+	writeMetric(w, "sigmartc_log_buffered_lines", "gauge", "Log lines waiting in sink queues.", logStats.BufferedLines)
+	writeMetric(w, "sigmartc_log_buffer_capacity", "gauge", "Total capacity of the sink queues.", logStats.BufferCapacity)
+	if h.Canary != nil {
+		h.Canary.writeMetrics(w)
+	}
+}
+
+func writeMetric(w io.Writer, name, kind, help string, value any) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// MinutesQuota meters participant-minutes, the time each peer spends in a
+// room summed over peers, per calendar month (UTC). Rooms over
+// RoomMonthlyMinutes, and tenants over their monthly_participant_minutes,
+// refuse joins with quota_exceeded until the month rolls over; calls in
+// progress are not cut. A finished session is charged to the month it ends in.
+// Usage is kept in memory; SeedMinutes restores it at startup from the usage
+// log, so only sessions still open when the server stopped are lost.
+type MinutesQuota struct {
+	// RoomMonthlyMinutes limits each room; zero meters rooms without a limit.
+	RoomMonthlyMinutes int
+
+	mu      sync.Mutex
+	rooms   map[string]*minutesUsage
+	tenants map[string]*minutesUsage
+	// month is the month last charged; entries from earlier months are
+	// pruned once it rolls over, since room names are client-chosen.
+	month string
+}
+
+type minutesUsage struct {
+	month   string
+	seconds float64
+}
+
+// NewMinutesQuota creates a meter limiting each room to roomMonthlyMinutes.
+func NewMinutesQuota(roomMonthlyMinutes int) *MinutesQuota {
+	return &MinutesQuota{
+		RoomMonthlyMinutes: roomMonthlyMinutes,
+		rooms:              make(map[string]*minutesUsage),
+		tenants:            make(map[string]*minutesUsage),
+	}
+}
+
+// usageMonth is the calendar month quotas are counted in.
+func usageMonth(now time.Time) string {
+	return now.UTC().Format("2006-01")
+}
+
+func addMinutesUsage(usage map[string]*minutesUsage, key, month string, seconds float64) {
+	u := usage[key]
+	if u == nil || u.month != month {
+		u = &minutesUsage{month: month}
+		usage[key] = u
+	}
+	u.seconds += seconds
+}
+
+// pruneMinutesUsage drops usage from months other than month.
+func pruneMinutesUsage(usage map[string]*minutesUsage, month string) {
+	for key, u := range usage {
+		if u.month != month {
+			delete(usage, key)
+		}
+	}
+}
+
+// charge adds a finished session's participant-seconds to its room and tenant.
+func (q *MinutesQuota) charge(roomUUID string, tenant *Tenant, seconds float64, now time.Time) {
+	if q == nil || seconds <= 0 {
+		return
+	}
+	month := usageMonth(now)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if month != q.month {
+		pruneMinutesUsage(q.rooms, month)
+		pruneMinutesUsage(q.tenants, month)
+		q.month = month
+	}
+	addMinutesUsage(q.rooms, roomUUID, month, seconds)
+	if tenant != nil {
+		addMinutesUsage(q.tenants, tenant.ID, month, seconds)
+	}
+}
+
+// SeedMinutes charges the sessions in the usage log that ended this month to
+// the minutes quotas, so a restart does not reset them. It does nothing
+// without both a quota and a usage log.
+func (rm *RoomManager) SeedMinutes(now time.Time) error {
+	if rm.Minutes == nil || rm.Usage == nil {
+		return nil
+	}
+	now = now.UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	// Sessions are listed by start, and one that began last month is charged
+	// to this one if it ended here.
+	sessions, err := rm.Usage.Sessions("", monthStart.AddDate(0, -1, 0), now.Add(time.Nanosecond))
+	if err != nil {
+		return err
+	}
+	month := usageMonth(now)
+	for _, session := range sessions {
+		if usageMonth(session.EndedAt) == month {
+			rm.Minutes.charge(session.Room, rm.Tenants.forRoom(session.Room), session.PeerSeconds, session.EndedAt)
+		}
+	}
+	return nil
+}
+
+// roomSeconds and tenantSeconds return the participant-seconds of finished
+// sessions this month.
+func (q *MinutesQuota) roomSeconds(uuid string, now time.Time) float64 {
+	if q == nil {
+		return 0
+	}
+	return q.charged(q.rooms, uuid, now)
+}
+
+func (q *MinutesQuota) tenantSeconds(id string, now time.Time) float64 {
+	if q == nil {
+		return 0
+	}
+	return q.charged(q.tenants, id, now)
+}
+
+func (q *MinutesQuota) charged(usage map[string]*minutesUsage, key string, now time.Time) float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if u := usage[key]; u != nil && u.month == usageMonth(now) {
+		return u.seconds
+	}
+	return 0
+}
+
+// liveSeconds is the participant-seconds of the room's session in progress.
+func (r *Room) liveSeconds(now time.Time) float64 {
+	r.Lock.RLock()
+	defer r.Lock.RUnlock()
+	if len(r.usage.joined) == 0 {
+		return 0
+	}
+	seconds := r.usage.peerSeconds
+	for _, joinedAt := range r.usage.joined {
+		seconds += now.Sub(joinedAt).Seconds()
+	}
+	return seconds
+}
+
+// roomMinutes returns the room's participant-minutes this month.
+func (rm *RoomManager) roomMinutes(uuid string, now time.Time) float64 {
+	seconds := rm.Minutes.roomSeconds(uuid, now)
+	rm.Lock.RLock()
+	room := rm.Rooms[uuid]
+	rm.Lock.RUnlock()
+	if room != nil {
+		seconds += room.liveSeconds(now)
+	}
+	return seconds / 60
+}
+
+// TenantUsage is a tenant's usage this month against its quotas; zero limits
+// are omitted.
+type TenantUsage struct {
+	Tenant                    string  `json:"tenant"`
+	Month                     string  `json:"month"`
+	Rooms                     int     `json:"rooms"`
+	MaxRooms                  int     `json:"max_rooms,omitempty"`
+	Peers                     int     `json:"peers"`
+	MaxPeers                  int     `json:"max_peers,omitempty"`
+	ParticipantMinutes        float64 `json:"participant_minutes"`
+	MonthlyParticipantMinutes int     `json:"monthly_participant_minutes,omitempty"`
+}
+
+// tenantUsage counts the tenant's open rooms, peers and participant-minutes.
+func (rm *RoomManager) tenantUsage(t *Tenant, now time.Time) TenantUsage {
+	usage := TenantUsage{
+		Tenant:                    t.ID,
+		Month:                     usageMonth(now),
+		MaxRooms:                  t.MaxRooms,
+		MaxPeers:                  t.MaxPeers,
+		MonthlyParticipantMinutes: t.MonthlyParticipantMinutes,
+	}
+	var rooms []*Room
+	rm.Lock.RLock()
+	for _, room := range rm.Rooms {
+		if room.tenant == t {
+			rooms = append(rooms, room)
+		}
+	}
+	rm.Lock.RUnlock()
+	seconds := rm.Minutes.tenantSeconds(t.ID, now)
+	for _, room := range rooms {
+		seconds += room.liveSeconds(now)
+		room.Lock.RLock()
+		usage.Peers += len(room.Peers)
+		room.Lock.RUnlock()
+	}
+	usage.Rooms = len(rooms)
+	usage.ParticipantMinutes = seconds / 60
+	return usage
+}
+
+// RoomQuotaUsage is a room's usage this month against its quotas; zero limits
+// are omitted.
+type RoomQuotaUsage struct {
+	Room                      string  `json:"room"`
+	Month                     string  `json:"month"`
+	Peers                     int     `json:"peers"`
+	MaxPeers                  int     `json:"max_peers"`
+	ParticipantMinutes        float64 `json:"participant_minutes"`
+	MonthlyParticipantMinutes int     `json:"monthly_participant_minutes,omitempty"`
+	UsedBytes                 uint64  `json:"used_bytes"`
+	MonthlyBytes              uint64  `json:"monthly_bytes,omitempty"`
+}
+
+// roomQuotaUsage reports a room's usage, including rooms that have closed this month.
+func (rm *RoomManager) roomQuotaUsage(uuid string, now time.Time) RoomQuotaUsage {
+	tenant := rm.Tenants.forRoom(uuid)
+	usage := RoomQuotaUsage{
+		Room:               uuid,
+		Month:              usageMonth(now),
+		MaxPeers:           maxRoomPeers,
+		ParticipantMinutes: rm.roomMinutes(uuid, now),
+	}
+	if tenant != nil && tenant.MaxPeersPerRoom > 0 {
+		usage.MaxPeers = min(usage.MaxPeers, tenant.MaxPeersPerRoom)
+	}
+	if rm.Minutes != nil {
+		usage.MonthlyParticipantMinutes = rm.Minutes.RoomMonthlyMinutes
+	}
+	if rm.Quota != nil {
+		usage.UsedBytes = rm.Quota.Used(uuid, now)
+		usage.MonthlyBytes = rm.Quota.MonthlyBytes
+	}
+	rm.Lock.RLock()
+	room := rm.Rooms[uuid]
+	rm.Lock.RUnlock()
+	if room != nil {
+		room.Lock.RLock()
+		usage.Peers = len(room.Peers)
+		room.Lock.RUnlock()
+	}
+	return usage
+}
+
+// quotaAdmission refuses a join that would exceed the tenant's concurrent peers
+// or the room's or tenant's participant-minutes for the month. counted is set
+// when the peer already counts toward the tenant's peers, as when it moves
+// between the tenant's rooms.
+func (rm *RoomManager) quotaAdmission(roomUUID string, tenant *Tenant, counted bool, now time.Time) (DisconnectReason, string) {
+	if tenant != nil && (tenant.MaxPeers > 0 || tenant.MonthlyParticipantMinutes > 0) {
+		usage := rm.tenantUsage(tenant, now)
+		if tenant.MaxPeers > 0 && !counted && usage.Peers >= tenant.MaxPeers {
+			return DisconnectTenantLimit, "Too many participants right now"
+		}
+		if tenant.MonthlyParticipantMinutes > 0 && usage.ParticipantMinutes >= float64(tenant.MonthlyParticipantMinutes) {
+			return DisconnectQuotaExceeded, "This service has used its minutes for the month"
+		}
+	}
+	if q := rm.Minutes; q != nil && q.RoomMonthlyMinutes > 0 && rm.roomMinutes(roomUUID, now) >= float64(q.RoomMonthlyMinutes) {
+		return DisconnectQuotaExceeded, "This room has used its minutes for the month"
+	}
+	return "", ""
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMinutesQuotaCountsFinishedAndLiveSessions(t *testing.T) {
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	rm.Minutes = NewMinutesQuota(30)
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	rm.Minutes.charge("room-a", nil, 20*60, now)
+	room := rm.GetOrCreateRoom("room-a")
+	room.Lock.Lock()
+	room.usageJoin("p1", now.Add(-5*time.Minute))
+	room.Lock.Unlock()
+
+	if got := rm.roomMinutes("room-a", now); got != 25 {
+		t.Fatalf("minutes = %v, want 25", got)
+	}
+	if reason, _ := rm.quotaAdmission("room-a", nil, false, now); reason != "" {
+		t.Fatalf("under quota refused: %q", reason)
+	}
+	if reason, _ := rm.quotaAdmission("room-a", nil, false, now.Add(5*time.Minute)); reason != DisconnectQuotaExceeded {
+		t.Fatalf("reason = %q, want %q", reason, DisconnectQuotaExceeded)
+	}
+
+	room.Lock.Lock()
+	rm.recordUsage(room.usageLeave("p1", now.Add(5*time.Minute)))
+	room.Lock.Unlock()
+	if got := rm.roomMinutes("room-a", now.Add(5*time.Minute)); got != 30 {
+		t.Fatalf("minutes after leaving = %v, want 30", got)
+	}
+	if got := rm.roomMinutes("room-a", now.Add(24*time.Hour)); got != 0 {
+		t.Fatalf("minutes next month = %v, want 0", got)
+	}
+	rm.Minutes.charge("room-b", nil, 60, now.Add(24*time.Hour))
+	if _, ok := rm.Minutes.rooms["room-a"]; ok || len(rm.Minutes.rooms) != 1 {
+		t.Fatalf("rooms = %v, want March's usage pruned", rm.Minutes.rooms)
+	}
+}
+
+func TestSeedMinutesFromUsageLog(t *testing.T) {
+	handler := newTestTenantHandler(t)
+	rm := handler.RoomManager
+	usage, err := NewUsageLog(filepath.Join(t.TempDir(), "usage.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer usage.Close()
+	rm.Usage = usage
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 12, 0, 0, 0, time.UTC) }
+	for _, session := range []UsageSession{
+		{Room: "acme/lobby", StartedAt: day(2, 10), EndedAt: day(2, 10), PeerSeconds: 900},
+		{Room: "acme/lobby", StartedAt: day(2, 28), EndedAt: day(3, 1), PeerSeconds: 600},
+		{Room: "room-x", StartedAt: day(3, 5), EndedAt: day(3, 5), PeerSeconds: 120},
+	} {
+		usage.Record(session)
+	}
+
+	rm.Minutes = NewMinutesQuota(0)
+	if err := rm.SeedMinutes(day(3, 20)); err != nil {
+		t.Fatal(err)
+	}
+	if got := rm.Minutes.roomSeconds("acme/lobby", day(3, 20)); got != 600 {
+		t.Fatalf("acme/lobby seconds = %v, want 600 (February's session excluded)", got)
+	}
+	if got := rm.Minutes.tenantSeconds("acme", day(3, 20)); got != 600 {
+		t.Fatalf("acme seconds = %v, want 600", got)
+	}
+	if got := rm.Minutes.roomSeconds("room-x", day(3, 20)); got != 120 {
+		t.Fatalf("room-x seconds = %v, want 120", got)
+	}
+}
+
+func TestTenantQuotas(t *testing.T) {
+	handler := newTestTenantHandler(t)
+	handler.RoomManager.Minutes = NewMinutesQuota(0)
+	globex := handler.RoomManager.Tenants.get("globex")
+	globex.MaxPeers = 1
+	globex.MonthlyParticipantMinutes = 60
+
+	join := func() DisconnectReason {
+		_, reason, _ := handler.checkJoin(httptest.NewRequest(http.MethodGet, "/ws?name=alice&tenant=globex&room=lobby", nil))
+		return reason
+	}
+	if reason := join(); reason != "" {
+		t.Fatalf("first join refused: %q", reason)
+	}
+	room := handler.RoomManager.GetOrCreateRoom("globex/lobby")
+	room.Peers["p1"] = &Peer{ID: "p1"}
+	if reason := join(); reason != DisconnectTenantLimit {
+		t.Fatalf("over max_peers: reason = %q, want %q", reason, DisconnectTenantLimit)
+	}
+	delete(room.Peers, "p1")
+
+	handler.RoomManager.Minutes.charge("globex/lobby", globex, 61*60, time.Now())
+	if reason := join(); reason != DisconnectQuotaExceeded {
+		t.Fatalf("over minutes: reason = %q, want %q", reason, DisconnectQuotaExceeded)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin?action=quota", nil)
+	req.Header.Set("Authorization", "Bearer globex-key")
+	rec := httptest.NewRecorder()
+	handler.HandleAdmin(rec, req)
+	var usage TenantUsage
+	if err := json.Unmarshal(rec.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if usage.Tenant != "globex" || usage.Rooms != 1 || usage.ParticipantMinutes != 61 || usage.MonthlyParticipantMinutes != 60 {
+		t.Fatalf("usage = %+v", usage)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin?action=quota&room=lobby", nil)
+	req.Header.Set("Authorization", "Bearer globex-key")
+	rec = httptest.NewRecorder()
+	handler.HandleAdmin(rec, req)
+	var roomUsage RoomQuotaUsage
+	if err := json.Unmarshal(rec.Body.Bytes(), &roomUsage); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if roomUsage.Room != "lobby" || roomUsage.ParticipantMinutes != 61 {
+		t.Fatalf("room usage = %+v", roomUsage)
+	}
+}
@@ -1,17 +1,20 @@
 package server
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"io"
 	"log/slog"
-	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gorilla/websocket"
+	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
+	"sigmartc/internal/banstore"
+	"sigmartc/internal/egress"
 	"sigmartc/internal/logger"
+	"sigmartc/pkg/protocol"
 )
 
 // Peer represents a connected user in a room.
@@ -19,9 +22,30 @@ type Peer struct {
 	ID   string
 	Name string
 	IP   string
+	// Country is the ISO code from GeoIP, empty when unknown or disabled.
+	Country string
+	// Identity is set when the peer joined with a verified JWT.
+	Identity *Identity
+	// DeviceID is the client-generated device_id the peer joined with, if any.
+	// It identifies duplicate tabs and is never sent to other peers.
+	DeviceID string
+	// SessionID is the ID of the /ws request that created the peer (see
+	// requestid.go); logger tags every log line for the peer with it.
+	SessionID string
+	logger    *slog.Logger
 
-	Conn    *websocket.Conn
+	Conn    SignalingConn
 	WsMutex sync.Mutex
+	// outbox, closing and writerDone belong to the writer goroutine started by
+	// startWriter. When outbox is nil, WriteJSON writes synchronously.
+	outbox     chan wsFrame
+	closing    chan closeFrame
+	writerDone chan struct{}
+	// closeSent is set once a close frame went out, or once the connection can
+	// no longer take one because the client closed it or it broke.
+	closeSent atomic.Bool
+	// msgpack is set once the client negotiates MessagePack in hello.
+	msgpack atomic.Bool
 
 	PC *webrtc.PeerConnection
 
@@ -36,48 +60,254 @@ type Peer struct {
 	NegotiationPending    bool
 	NegotiationInProgress bool
 	MakingOffer           bool
-	IceRestartPending     bool
-	LastIceRestart        time.Time
+	// IgnoreOffer is set while the client's last offer was ignored because it
+	// collided with ours (see clientPolite).
+	IgnoreOffer       bool
+	IceRestartPending bool
+	LastIceRestart    time.Time
+	// signalingChanged is signalled (non-blocking, capacity 1) on every signaling
+	// state change so runNegotiation can wait for stable without polling.
+	signalingChanged chan struct{}
 
+	// PendingCandidates queues candidates received before the remote
+	// description, bounded (see queueCandidate).
 	PendingCandidatesMu sync.Mutex
-	PendingCandidates   []webrtc.ICECandidateInit
+	PendingCandidates   []pendingCandidate
 
 	Muted    bool
 	JoinTime time.Time
+	// Role is fixed at join (see joinRole) and gates actions through Can.
+	Role Role
 
-	Done     chan struct{}
-	doneOnce sync.Once
+	// room is the room the peer is currently in; MovePeer changes it.
+	room   *Room
+	roomMu sync.RWMutex
+
+	// lastActivity is the UnixNano time of the last published RTP packet or
+	// non-heartbeat signaling message.
+	lastActivity atomic.Int64
+	// talkTime accumulates nanoseconds of forwarded audio above the speech threshold.
+	talkTime atomic.Int64
+	// capabilities is set once the client sends hello.
+	capabilities atomic.Pointer[Capabilities]
+	// reactions rate-limits reaction broadcasts.
+	reactions tokenBucket
+	// candidateLimit rate-limits trickled ICE candidates (see candidatelimit.go).
+	candidateLimit candidateLimit
+	// unsubscribed holds senders whose audio the peer opted out of.
+	unsubscribed   map[string]bool
+	unsubscribedMu sync.Mutex
+	// bitrateViolations counts measurement windows the peer published over the
+	// room's bitrate cap; bitrateDropped the packets dropped for it.
+	bitrateViolations atomic.Uint64
+	bitrateDropped    atomic.Uint64
+	// lowLayer forwards the peer's low-bitrate audio copy, if it sends one.
+	// quality is the peer's layer override and lossy the senders whose audio
+	// it reports heavy loss for.
+	lowLayer *TrackForwarder
+	quality  Quality
+	lossy    map[string]bool
+	layersMu sync.Mutex
+	// gains is the peer's gain for each publisher it set one for.
+	gains   map[string]float64
+	gainsMu sync.Mutex
+	// denoise runs the peer's audio through RNNoise (see denoise.go).
+	denoise atomic.Bool
+	// timeline records the peer's session events for action=peer_timeline.
+	timeline peerTimeline
+	// rtp counts published RTP; sampleStats turns it into history.
+	rtp     rtpCounter
+	history statHistory
+	// bandwidth totals the RTP bytes the peer published and was sent.
+	bandwidth bandwidthCounters
+
+	// manager owns the peer's room; Close reports the departure to it.
+	manager *RoomManager
+	// state is the peer's PeerState (see lifecycle.go); iceFailures counts
+	// consecutive peer connection failures.
+	state       atomic.Int32
+	iceFailures atomic.Int32
+
+	// ctx is the session's context, derived from the /ws request; it is
+	// cancelled when the session ends and every goroutine serving the peer
+	// stops on it.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	disconnectOnce   sync.Once
+	disconnectMu     sync.Mutex
+	disconnectReason DisconnectReason
 }
 
+const (
+	// speechLevelThreshold is the quietest audio level (in -dBov) counted as speech.
+	speechLevelThreshold = 50
+	defaultFrameDuration = 20 * time.Millisecond
+	maxFrameDuration     = 120 * time.Millisecond
+	// comfortNoiseInterval is how often a silent packet still goes out when
+	// silence is skipped, the cadence Opus DTX itself uses.
+	comfortNoiseInterval = 400 * time.Millisecond
+	// maxDTXPayload is the largest Opus payload treated as a DTX frame (TOC byte only).
+	maxDTXPayload = 2
+)
+
 // TrackForwarder manages fan-out from one sender's TrackRemote to multiple receivers.
 // It reads RTP packets once and writes them to all subscribers.
 type TrackForwarder struct {
 	SenderID    string
 	TrackRemote *webrtc.TrackRemote
 
+	// read supplies RTP packets: the remote track for peers, or a synthetic
+	// source such as the soundboard. codec is what subscribers are bound with.
+	read  func([]byte) (int, error)
+	codec webrtc.RTPCodecCapability
+
 	mu          sync.RWMutex
 	subscribers map[string]*webrtc.TrackLocalStaticRTP // receiverID -> localTrack
-	writeErrAt  map[string]time.Time
+	// taps receive every forwarded packet in addition to subscribers (e.g. egress).
+	// They are called synchronously and must not retain the slice.
+	taps       map[string]func([]byte)
+	writeErrAt map[string]time.Time
+	// gains write the copies of receivers that set a gain for this publisher
+	// (see gain.go).
+	gains map[string]gainStage
+	// filter, when set, runs the publisher's audio through ffmpeg filters
+	// before fan-out (see audiofilter.go); filterMu serializes replacing it.
+	filter   atomic.Pointer[filterStage]
+	filterMu sync.Mutex
+	// low is the publisher's low-bitrate layer, if it sends one (see simulcast.go).
+	// Receivers in lowReceivers are subscribed to low instead of this forwarder.
+	low          *TrackForwarder
+	lowReceivers map[string]bool
+	// layers rewrites receivers' copies across layer switches; it is set on
+	// both layers once a low layer attaches.
+	layers *layerRewriters
 
-	done     chan struct{}
+	// ctx is cancelled by Stop or when the parent context passed to the
+	// constructor ends, e.g. when the publisher leaves.
+	ctx      context.Context
+	cancel   context.CancelFunc
 	stopOnce sync.Once
 	onStop   func(error)
+	// logger is the publisher's logger (see Peer.log); nil for synthetic sources.
+	logger *slog.Logger
+	// onRTP is called for every packet read from the sender.
+	onRTP func(packet []byte)
+	// onWriteError is called when writing to a subscriber fails, at most every
+	// five seconds per subscriber.
+	onWriteError func(receiverID string, err error)
+	// onForward is called after each fan-out with the receivers the packet reached.
+	onForward func(receiverIDs []string, n int)
+	// throttled reports whether silent packets should be dropped instead of forwarded.
+	throttled func() bool
+	// skipSilence forwards silent packets (zero audio level or Opus DTX) only
+	// once per comfortNoiseInterval; lastSilentForward is owned by the read loop.
+	skipSilence       bool
+	lastSilentForward time.Time
+	// suppressed reports whether every packet should be dropped, e.g. while a
+	// priority speaker is talking or the room is muted.
+	suppressed func() bool
+	// impairment returns deliberate loss, jitter and reordering to apply to
+	// subscriber writes; nil (or a nil result) forwards packets untouched.
+	impairment func() *Impairment
+	// maxBitrate returns the publisher's bitrate cap in bits per second (0 for
+	// none); onOverBitrate is called for each measurement window over it.
+	maxBitrate    func() int
+	onOverBitrate func(*bitrateViolation)
+	bitrate       bitrateLimiter
+
+	// Speech detection from the RFC 6464 audio level extension (0 disables).
+	audioLevelExtID uint8
+	onSpeech        func(time.Duration)
+	clockRate       uint32
+	lastTimestamp   uint32
+	hasTimestamp    bool
+
+	// jitter reorders packets before fan-out when enabled; jitterMu serializes
+	// release between the read loop and the release ticker.
+	jitter   *jitterBuffer
+	jitterMu sync.Mutex
+
+	// Publishers that negotiated RED may switch between RED and plain Opus mid-stream,
+	// but subscriber tracks are bound to the codec of the first packet. Packets in the
+	// other format are converted so subscribers never see a payload they did not bind.
+	outputPayloadType uint8
+	redPayloadType    uint8
+	opusPayloadType   uint8
+
+	// A publisher that restarts its track keeps its forwarder (see
+	// replaceSource). sourceGeneration counts sources and is guarded by mu;
+	// processMu serializes packet handling between the old and new source's
+	// read loops, and rewriter keeps the outgoing sequence numbers and
+	// timestamps continuous across the switch.
+	started          bool
+	sourceGeneration uint64
+	processMu        sync.Mutex
+	rewriter         seqRewriter
 }
 
-// NewTrackForwarder creates a new forwarder for the given sender's track.
-func NewTrackForwarder(senderID string, track *webrtc.TrackRemote) *TrackForwarder {
+// NewTrackForwarder creates a new forwarder for the given sender's track. It
+// stops when ctx is done.
+func NewTrackForwarder(ctx context.Context, senderID string, track *webrtc.TrackRemote) *TrackForwarder {
+	f := newForwarder(ctx, senderID, webrtc.RTPCodecCapability{})
+	if track != nil {
+		f.TrackRemote = track
+		f.codec = track.Codec().RTPCodecCapability
+		if f.codec.ClockRate != 0 {
+			f.clockRate = f.codec.ClockRate
+		}
+		f.read = func(buf []byte) (int, error) {
+			n, _, err := track.Read(buf)
+			return n, err
+		}
+	}
+	return f
+}
+
+// newSyntheticForwarder creates a forwarder for a server-generated track. The caller
+// sets read before Start; it should return an error once f.ctx is done.
+func newSyntheticForwarder(ctx context.Context, senderID string, codec webrtc.RTPCodecCapability) *TrackForwarder {
+	return newForwarder(ctx, senderID, codec)
+}
+
+func newForwarder(ctx context.Context, senderID string, codec webrtc.RTPCodecCapability) *TrackForwarder {
+	clockRate := uint32(48000)
+	if codec.ClockRate != 0 {
+		clockRate = codec.ClockRate
+	}
+	ctx, cancel := context.WithCancel(ctx)
 	return &TrackForwarder{
 		SenderID:    senderID,
-		TrackRemote: track,
+		codec:       codec,
 		subscribers: make(map[string]*webrtc.TrackLocalStaticRTP),
 		writeErrAt:  make(map[string]time.Time),
-		done:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+		clockRate:   clockRate,
 	}
 }
 
+// Codec returns the codec subscribers' local tracks are created with.
+func (f *TrackForwarder) Codec() webrtc.RTPCodecCapability {
+	return f.codec
+}
+
+// SetPayloadTypes records the publisher's negotiated RED and Opus payload types so
+// packets can be converted to the format subscribers are bound to. Call before Start.
+func (f *TrackForwarder) SetPayloadTypes(output, red, opus uint8) {
+	f.outputPayloadType = output
+	f.redPayloadType = red
+	f.opusPayloadType = opus
+}
+
 // Subscribe adds a receiver's local track to the forwarder.
 func (f *TrackForwarder) Subscribe(receiverID string, localTrack *webrtc.TrackLocalStaticRTP) {
 	f.mu.Lock()
+	if low := f.low; low != nil && f.lowReceivers[receiverID] {
+		f.mu.Unlock()
+		low.Subscribe(receiverID, localTrack)
+		return
+	}
 	f.subscribers[receiverID] = localTrack
 	f.mu.Unlock()
 }
@@ -86,6 +316,44 @@ func (f *TrackForwarder) Subscribe(receiverID string, localTrack *webrtc.TrackLo
 func (f *TrackForwarder) Unsubscribe(receiverID string) {
 	f.mu.Lock()
 	delete(f.subscribers, receiverID)
+	delete(f.lowReceivers, receiverID)
+	gain := f.gains[receiverID]
+	delete(f.gains, receiverID)
+	low := f.low
+	layers := f.layers
+	f.mu.Unlock()
+	layers.forget(receiverID)
+	if gain != nil {
+		gain.close()
+	}
+	if low != nil {
+		low.Unsubscribe(receiverID)
+	}
+}
+
+// hasSubscriber reports whether receiverID gets this forwarder's audio on either layer.
+func (f *TrackForwarder) hasSubscriber(receiverID string) bool {
+	f.mu.RLock()
+	_, ok := f.subscribers[receiverID]
+	low := f.low
+	f.mu.RUnlock()
+	return ok || low != nil && low.hasSubscriber(receiverID)
+}
+
+// AddTap registers fn to observe every packet sent to subscribers, replacing any tap with the same id.
+func (f *TrackForwarder) AddTap(id string, fn func([]byte)) {
+	f.mu.Lock()
+	if f.taps == nil {
+		f.taps = make(map[string]func([]byte))
+	}
+	f.taps[id] = fn
+	f.mu.Unlock()
+}
+
+// RemoveTap unregisters a tap.
+func (f *TrackForwarder) RemoveTap(id string) {
+	f.mu.Lock()
+	delete(f.taps, id)
 	f.mu.Unlock()
 }
 
@@ -96,54 +364,312 @@ func (f *TrackForwarder) SubscriberCount() int {
 	return len(f.subscribers)
 }
 
+// EnableJitterBuffer reorders packets for up to delay before fan-out. Call before Start.
+func (f *TrackForwarder) EnableJitterBuffer(delay time.Duration) {
+	if delay > 0 {
+		f.jitter = newJitterBuffer(delay)
+	}
+}
+
 // Start begins the forwarding loop. It reads from TrackRemote and writes to all subscribers.
-// This method blocks until the track ends or Stop is called.
+// This method blocks until the track ends, Stop is called or the forwarder's context is done.
 func (f *TrackForwarder) Start() {
+	defer f.recoverPanic()
+	if f.jitter != nil {
+		go f.releaseJitter()
+	}
+	f.mu.Lock()
+	f.started = true
+	read, generation := f.read, f.sourceGeneration
+	f.mu.Unlock()
+	if read == nil {
+		f.stopWithError(errors.New("forwarder has no source"))
+		return
+	}
+	f.pump(read, generation)
+}
+
+// pump reads packets from one source until it fails, the forwarder stops or
+// replaceSource supersedes it. Only the current source's failure stops the
+// forwarder.
+func (f *TrackForwarder) pump(read func([]byte) (int, error), generation uint64) {
 	rtpBuf := make([]byte, 1500)
 	for {
 		select {
-		case <-f.done:
+		case <-f.ctx.Done():
 			return
 		default:
 		}
 
-		n, _, err := f.TrackRemote.Read(rtpBuf)
-		if err != nil {
-			f.stopWithError(err)
+		n, err := read(rtpBuf)
+		if !f.handle(generation, rtpBuf[:n], err) {
 			return
 		}
+	}
+}
+
+// handle processes one read from the source of the given generation and
+// reports whether that source should keep being read.
+func (f *TrackForwarder) handle(generation uint64, packet []byte, err error) bool {
+	f.processMu.Lock()
+	defer f.processMu.Unlock()
+	if f.currentGeneration() != generation {
+		return false
+	}
+	if err != nil {
+		f.stopWithError(err)
+		return false
+	}
+	f.process(packet)
+	return true
+}
 
-		type subscriberEntry struct {
-			id    string
-			track *webrtc.TrackLocalStaticRTP
+// process forwards one packet from the current source. Callers hold processMu.
+func (f *TrackForwarder) process(raw []byte) {
+	n := len(raw)
+	f.rewriter.rewrite(raw, f.clockRate, time.Now())
+	if f.onRTP != nil {
+		f.onRTP(raw)
+	}
+	if f.maxBitrate != nil {
+		if limit := f.maxBitrate(); limit > 0 {
+			forward, violation := f.bitrate.push(n, limit, time.Now())
+			if violation != nil && f.onOverBitrate != nil {
+				f.onOverBitrate(violation)
+			}
+			if !forward {
+				return
+			}
 		}
-		f.mu.RLock()
-		subscribers := make([]subscriberEntry, 0, len(f.subscribers))
-		for receiverID, localTrack := range f.subscribers {
-			subscribers = append(subscribers, subscriberEntry{id: receiverID, track: localTrack})
+	}
+	speech := true
+	if f.audioLevelExtID != 0 {
+		speech = f.detectSpeech(raw)
+	}
+	if !speech && f.throttled != nil && f.throttled() {
+		return
+	}
+	if f.skipSilence && (!speech || isOpusDTX(raw)) {
+		now := time.Now()
+		if now.Sub(f.lastSilentForward) < comfortNoiseInterval {
+			return
+		}
+		f.lastSilentForward = now
+	}
+	if f.suppressed != nil && f.suppressed() {
+		return
+	}
+	packet := f.convertPayloadType(raw)
+	if packet == nil {
+		return
+	}
+
+	if f.jitter != nil {
+		f.jitterMu.Lock()
+		f.jitter.push(packet, time.Now())
+		for _, packet := range f.jitter.pop(time.Now()) {
+			f.deliver(packet)
 		}
-		f.mu.RUnlock()
+		f.jitterMu.Unlock()
+		return
+	}
+	f.deliver(packet)
+}
+
+// convertPayloadType rewraps RED packets as Opus (or Opus as RED) when they differ
+// from the subscribers' bound codec. It returns nil for packets that cannot be converted.
+func (f *TrackForwarder) convertPayloadType(raw []byte) []byte {
+	if f.redPayloadType == 0 || len(raw) < 2 {
+		return raw
+	}
+	payloadType := raw[1] & 0x7f
+	if payloadType == f.outputPayloadType {
+		return raw
+	}
+
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(raw); err != nil {
+		return nil
+	}
+	switch {
+	case payloadType == f.redPayloadType && f.outputPayloadType == f.opusPayloadType:
+		primary, err := unwrapRED(packet.Payload)
+		if err != nil {
+			return nil
+		}
+		packet.Payload = primary
+	case payloadType == f.opusPayloadType && f.outputPayloadType == f.redPayloadType:
+		packet.Payload = wrapRED(f.opusPayloadType, packet.Payload)
+	default:
+		// Comfort noise, DTMF and the like pass through untouched.
+		return raw
+	}
+	packet.PayloadType = f.outputPayloadType
+	out, err := packet.Marshal()
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// releaseJitter flushes packets whose reorder window has expired while the read loop
+// is blocked waiting for the next packet.
+func (f *TrackForwarder) releaseJitter() {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.ctx.Done():
+			return
+		case <-ticker.C:
+			f.jitterMu.Lock()
+			for _, packet := range f.jitter.pop(time.Now()) {
+				f.deliver(packet)
+			}
+			f.jitterMu.Unlock()
+		}
+	}
+}
+
+// fanOut writes one RTP packet to every subscriber.
+func (f *TrackForwarder) fanOut(packet []byte) {
+	type subscriberEntry struct {
+		id    string
+		track *webrtc.TrackLocalStaticRTP
+		gain  gainStage
+	}
+	f.mu.RLock()
+	subscribers := make([]subscriberEntry, 0, len(f.subscribers))
+	for receiverID, localTrack := range f.subscribers {
+		subscribers = append(subscribers, subscriberEntry{id: receiverID, track: localTrack, gain: f.gains[receiverID]})
+	}
+	taps := make([]func([]byte), 0, len(f.taps))
+	for _, tap := range f.taps {
+		taps = append(taps, tap)
+	}
+	layers := f.layers
+	f.mu.RUnlock()
 
-		for _, sub := range subscribers {
-			if _, writeErr := sub.track.Write(rtpBuf[:n]); writeErr != nil {
-				f.recordWriteError(sub.id, writeErr)
+	for _, tap := range taps {
+		tap(packet)
+	}
+
+	var impairment *Impairment
+	if f.impairment != nil {
+		impairment = f.impairment()
+	}
+	delivered := make([]string, 0, len(subscribers))
+	now := time.Now()
+	for _, sub := range subscribers {
+		var saved [6]byte
+		if layers != nil {
+			var current bool
+			if saved, current = layers.rewrite(sub.id, f, packet, now); !current {
+				continue
 			}
 		}
+		if f.send(packet, sub.id, sub.track, sub.gain, impairment) {
+			delivered = append(delivered, sub.id)
+		}
+		if layers != nil {
+			layers.restore(packet, saved)
+		}
+	}
+	if f.onForward != nil && len(delivered) > 0 {
+		f.onForward(delivered, len(packet))
+	}
+}
+
+// send writes packet to one subscriber, through the room's impairment if any,
+// and reports whether it went out.
+func (f *TrackForwarder) send(packet []byte, receiverID string, track *webrtc.TrackLocalStaticRTP, gain gainStage, impairment *Impairment) bool {
+	if impairment != nil {
+		delay, send := impairment.decide()
+		if !send {
+			return false
+		}
+		if delay > 0 {
+			held := append([]byte(nil), packet...)
+			time.AfterFunc(delay, func() { _, _ = writeSubscriber(held, track, gain) })
+			return true
+		}
+	}
+	sent, writeErr := writeSubscriber(packet, track, gain)
+	if writeErr != nil {
+		f.recordWriteError(receiverID, writeErr)
+		return false
+	}
+	return sent
+}
+
+// detectSpeech reports the packet's duration to onSpeech when its audio level is above
+// the speech threshold. Duration comes from the RTP timestamp delta, falling back to a
+// standard 20ms Opus frame across gaps. It returns false only for packets whose
+// audio level marks them as silent.
+func (f *TrackForwarder) detectSpeech(packet []byte) bool {
+	var header rtp.Header
+	if _, err := header.Unmarshal(packet); err != nil {
+		return true
+	}
+	duration := defaultFrameDuration
+	if f.hasTimestamp {
+		delta := time.Duration(header.Timestamp-f.lastTimestamp) * time.Second / time.Duration(f.clockRate)
+		if delta > 0 && delta <= maxFrameDuration {
+			duration = delta
+		}
+	}
+	f.lastTimestamp = header.Timestamp
+	f.hasTimestamp = true
+
+	ext := header.GetExtension(f.audioLevelExtID)
+	if ext == nil {
+		return true
+	}
+	var level rtp.AudioLevelExtension
+	if err := level.Unmarshal(ext); err != nil {
+		return true
+	}
+	// Level is -dBov: 0 is the loudest, 127 is silence.
+	if level.Level > speechLevelThreshold {
+		return false
+	}
+	if f.onSpeech != nil {
+		f.onSpeech(duration)
+	}
+	return true
+}
+
+// isOpusDTX reports whether the packet carries an Opus DTX frame, which has no
+// audio data beyond the TOC byte. RED packets are never that small.
+func isOpusDTX(packet []byte) bool {
+	var header rtp.Header
+	n, err := header.Unmarshal(packet)
+	if err != nil {
+		return false
 	}
+	payload := len(packet) - n
+	if header.Padding && payload > 0 {
+		payload -= int(packet[len(packet)-1])
+	}
+	return payload <= maxDTXPayload
 }
 
 // Stop signals the forwarder to stop reading.
 func (f *TrackForwarder) Stop() {
-	f.stopOnce.Do(func() {
-		close(f.done)
-	})
+	f.stopOnce.Do(f.cancel)
 }
 
+// stopWithError stops the forwarder after its source failed and runs onStop.
+// A read that fails because the parent context ended is a plain stop: whoever
+// cancelled it is already tearing the forwarder down.
 func (f *TrackForwarder) stopWithError(err error) {
 	f.stopOnce.Do(func() {
-		close(f.done)
+		if f.ctx.Err() != nil {
+			return
+		}
+		f.cancel()
 		if err != nil {
-			slog.Warn("Forwarder stopped", "sender_id", f.SenderID, "err", err)
+			f.log().Warn("Forwarder stopped", "err", err)
 		}
 		if f.onStop != nil {
 			f.onStop(err)
@@ -162,14 +688,26 @@ func (f *TrackForwarder) recordWriteError(receiverID string, err error) {
 		f.writeErrAt[receiverID] = now
 		shouldLog = true
 	}
+	var gain gainStage
+	var layers *layerRewriters
 	if removeSubscriber {
 		delete(f.subscribers, receiverID)
 		delete(f.writeErrAt, receiverID)
+		gain = f.gains[receiverID]
+		delete(f.gains, receiverID)
+		layers = f.layers
 	}
 	f.mu.Unlock()
+	layers.forget(receiverID)
+	if gain != nil {
+		gain.close()
+	}
 
 	if shouldLog {
-		slog.Warn("Failed to write RTP to subscriber", "sender_id", f.SenderID, "receiver_id", receiverID, "err", err)
+		f.log().Warn("Failed to write RTP to subscriber", "receiver_id", receiverID, "err", err)
+		if f.onWriteError != nil {
+			f.onWriteError(receiverID, err)
+		}
 	}
 }
 
@@ -178,6 +716,8 @@ type Room struct {
 	UUID  string
 	Peers map[string]*Peer
 	Lock  sync.RWMutex
+	// tenant owns the room's namespace (see tenant.go); nil is the default one.
+	tenant *Tenant
 
 	// Forwarders maps senderID to the forwarder handling that sender's audio
 	Forwarders   map[string]*TrackForwarder
@@ -185,6 +725,68 @@ type Room struct {
 
 	LastEmptyTime time.Time
 	CreatedAt     time.Time
+
+	// Schedule limits when the room is open and Locked refuses new non-host peers;
+	// both are guarded by Lock.
+	Schedule Schedule
+	Locked   bool
+
+	// Public lists the room in /api/rooms?public=true; rooms are unlisted by default.
+	// Name, Topic and Avatar are the display header (see RoomMetadata). Guarded by Lock.
+	Public bool
+	Name   string
+	Topic  string
+	Avatar string
+
+	// E2EE marks the room end-to-end encrypted (see e2ee.go); guarded by Lock.
+	E2EE bool
+
+	// hands is the ordered raise-hand queue of peer IDs, guarded by Lock.
+	hands []string
+
+	// mutedAll is set by mute_all (see muteall.go). unmuted holds peers a host
+	// let speak since, and unmuteRequests the pending requests; both are guarded by Lock.
+	mutedAll       atomic.Bool
+	unmuted        map[string]bool
+	unmuteRequests []string
+
+	// egress streams the room mix to an external endpoint while set; hls serves it
+	// to passive listeners who present hlsToken, set by the hls_enable admin
+	// action; transcript is the current or last transcription (see
+	// transcribe.go). All are guarded by egressMu.
+	egress     *egress.Session
+	hls        *hlsStream
+	hlsToken   string
+	transcript *transcription
+	egressMu   sync.Mutex
+
+	// bandwidth totals RTP bytes received from and forwarded to the room's peers;
+	// unchargedBytes are those not yet charged to the quota by chargeQuotas;
+	// throttled is set while the room is over a QuotaThrottle quota.
+	bandwidth      bandwidthCounters
+	unchargedBytes atomic.Uint64
+	throttled      atomic.Bool
+	// peerIndex mirrors Peers (peer ID -> *Peer) for countForwarded, which
+	// runs per packet and must not take Lock. addPeer and removePeer keep it.
+	peerIndex sync.Map
+
+	// priority is the room's priority speaker (see priority.go); prioritySpokeAt
+	// is the UnixNano time its forwarder last detected speech.
+	priority        atomic.Pointer[PrioritySpeaker]
+	prioritySpokeAt atomic.Int64
+
+	// maxBitrateKbps overrides Handler.MaxPublishBitrate when set (see bitrate.go).
+	maxBitrateKbps atomic.Int64
+	// leveling evens out publishers' loudness (see loudness.go).
+	leveling atomic.Bool
+	// codecPolicy restricts what publishers may send (see codecpolicy.go).
+	codecPolicy atomic.Pointer[CodecPolicy]
+	// forceRelay makes peers connect through TURN only (see relay.go).
+	forceRelay atomic.Bool
+
+	// usage follows the current occupied session for usage reports (see usage.go);
+	// guarded by Lock.
+	usage usageTracker
 }
 
 // RoomManager manages the lifecycle of rooms.
@@ -194,6 +796,33 @@ type RoomManager struct {
 	AdminKey    string
 	BanListPath string
 	Lock        sync.RWMutex
+
+	// Bans persists BannedIPs, which caches it (see banlist.go). Nil uses the
+	// JSON file at BanListPath.
+	Bans banstore.Store
+
+	// Events fans lifecycle events out to admin streams.
+	Events *EventHub
+	// Webhooks receives room and peer lifecycle events. Nil disables delivery.
+	Webhooks *WebhookDispatcher
+	// Tenants maps tenant room namespaces to their limits and webhooks. Nil
+	// disables tenancy.
+	Tenants *Tenants
+	// Quota limits each room's monthly RTP bytes. Nil disables quotas.
+	Quota *BandwidthQuota
+	// Minutes meters monthly participant-minutes per room and tenant. Nil
+	// disables metering and the minutes quotas.
+	Minutes *MinutesQuota
+	// Usage stores finished room sessions for usage reports. Nil disables them.
+	Usage *UsageLog
+
+	// bandwidth totals RTP bytes across all rooms since startup.
+	bandwidth bandwidthCounters
+	// departed keeps the timelines of recently departed peers.
+	departed departedPeers
+	// banMu orders ban store writes and reloads, so a reload cannot replace
+	// BannedIPs with bans read before a concurrent BanIP.
+	banMu sync.Mutex
 }
 
 func NewRoomManager(adminKey string, banListPath string) *RoomManager {
@@ -202,42 +831,49 @@ func NewRoomManager(adminKey string, banListPath string) *RoomManager {
 		BannedIPs:   make(map[string]bool),
 		AdminKey:    adminKey,
 		BanListPath: banListPath,
+		Events:      NewEventHub(),
 	}
 	rm.loadBanList()
 	go rm.startCleanupTicker()
+	go rm.startScheduleTicker()
+	go rm.startQuotaTicker()
 	return rm
 }
 
 func (rm *RoomManager) loadBanList() {
-	data, err := os.ReadFile(rm.BanListPath)
+	bans, err := rm.banStore().Load()
 	if err != nil {
-		if !os.IsNotExist(err) {
-			slog.Warn("Failed to read ban list", "err", err)
-		}
+		slog.Error("Failed to load ban list", "err", err)
 		return
 	}
-	if err := json.Unmarshal(data, &rm.BannedIPs); err != nil {
-		slog.Error("Failed to parse ban list", "err", err)
-	}
-}
-
-func (rm *RoomManager) saveBanList() error {
-	data, err := json.Marshal(rm.BannedIPs)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(rm.BanListPath, data, 0644)
+	rm.BannedIPs = bans
 }
 
 func (rm *RoomManager) BanIP(ip string) {
+	rm.banMu.Lock()
+	saveErr := rm.banStore().Add(ip)
 	rm.Lock.Lock()
 	rm.BannedIPs[ip] = true
-	saveErr := rm.saveBanList()
 	rm.Lock.Unlock()
+	rm.banMu.Unlock()
 	if saveErr != nil {
 		slog.Error("Failed to save ban list", "err", saveErr)
 	}
 	logger.LogEvent("ADMIN_BAN", slog.String("ip", ip))
+	rm.emit(EventBan, map[string]any{"ip": ip})
+}
+
+func (rm *RoomManager) UnbanIP(ip string) {
+	rm.banMu.Lock()
+	saveErr := rm.banStore().Remove(ip)
+	rm.Lock.Lock()
+	delete(rm.BannedIPs, ip)
+	rm.Lock.Unlock()
+	rm.banMu.Unlock()
+	if saveErr != nil {
+		slog.Error("Failed to save ban list", "err", saveErr)
+	}
+	logger.LogEvent("ADMIN_UNBAN", slog.String("ip", ip))
 }
 
 func (rm *RoomManager) IsBanned(ip string) bool {
@@ -258,19 +894,32 @@ func (rm *RoomManager) GetOrCreateRoom(uuid string) *Room {
 	room = &Room{
 		UUID:          uuid,
 		Peers:         make(map[string]*Peer),
+		tenant:        rm.Tenants.forRoom(uuid),
 		Forwarders:    make(map[string]*TrackForwarder),
 		CreatedAt:     time.Now(),
 		LastEmptyTime: time.Now(),
 	}
 	rm.Rooms[uuid] = room
 	logger.LogEvent("ROOM_CREATE", slog.String("uuid", uuid))
+	rm.emit(EventRoomCreate, map[string]any{"room": uuid})
 	return room
 }
 
+// emit publishes a lifecycle event to admin streams and webhooks.
+func (rm *RoomManager) emit(eventType string, data map[string]any) {
+	event := newEvent(eventType, data)
+	rm.Events.Publish(event)
+	rm.Webhooks.Send(event)
+	rm.sendTenantEvent(event, data)
+}
+
 func (rm *RoomManager) startCleanupTicker() {
 	ticker := time.NewTicker(1 * time.Minute)
 	for range ticker.C {
-		rm.cleanup()
+		func() {
+			defer recoverGoroutine("cleanup")
+			rm.cleanup()
+		}()
 	}
 }
 
@@ -283,15 +932,28 @@ func (rm *RoomManager) cleanup() {
 		room.Lock.RLock()
 		peerCount := len(room.Peers)
 		lastEmpty := room.LastEmptyTime
+		scheduled := !room.Schedule.EndsAt.IsZero()
 		room.Lock.RUnlock()
 
-		if peerCount == 0 && now.Sub(lastEmpty) > 2*time.Hour {
+		// Scheduled rooms live until their end time, however long they sit empty.
+		if peerCount == 0 && !scheduled && now.Sub(lastEmpty) > 2*time.Hour {
 			delete(rm.Rooms, uuid)
+			room.stopServerMedia()
 			logger.LogEvent("ROOM_DESTROY", slog.String("uuid", uuid), slog.String("reason", "expired"))
+			rm.emit(EventRoomDestroy, map[string]any{"room": uuid, "reason": "expired"})
 		}
 	}
 }
 
+// stopServerMedia stops server-side outputs and injected tracks when the room is destroyed.
+func (r *Room) stopServerMedia() {
+	r.stopEgress()
+	r.stopHLS()
+	r.stopTranscription()
+	r.stopSynthetic(soundboardSenderID)
+	r.stopSynthetic(announcementSenderID)
+}
+
 func (r *Room) Broadcast(senderID string, msg any) {
 	r.Lock.RLock()
 	peers := make([]*Peer, 0, len(r.Peers))
@@ -308,20 +970,119 @@ func (r *Room) Broadcast(senderID string, msg any) {
 	}
 }
 
+// WriteJSON sends v to the client as JSON, or as MessagePack if the client
+// negotiated it. With a writer running it only enqueues.
 func (p *Peer) WriteJSON(v any) {
+	frame, err := p.encode(v)
+	if err != nil {
+		p.log().Warn("WS message encode failed", "err", err)
+		return
+	}
+	if p.outbox != nil {
+		p.enqueue(frame)
+		return
+	}
 	p.WsMutex.Lock()
 	defer p.WsMutex.Unlock()
 	if p.Conn != nil {
-		if err := p.Conn.WriteJSON(v); err != nil {
-			slog.Warn("WS write failed", "peer_id", p.ID, "err", err)
+		if err := p.Conn.WriteMessage(frame.messageType, frame.data, time.Now().Add(wsWriteWait)); err != nil {
+			p.log().Warn("WS write failed", "err", err)
 		}
 	}
 }
 
+// Touch records activity from the peer.
+func (p *Peer) Touch() {
+	p.lastActivity.Store(time.Now().UnixNano())
+}
+
+// IdleFor returns how long the peer has been without activity, measured from
+// join time if it has never been active.
+func (p *Peer) IdleFor() time.Duration {
+	last := p.lastActivity.Load()
+	if last == 0 {
+		return time.Since(p.JoinTime)
+	}
+	return time.Since(time.Unix(0, last))
+}
+
+// AddTalkTime adds speaking time detected by the peer's forwarder.
+func (p *Peer) AddTalkTime(d time.Duration) {
+	p.talkTime.Add(int64(d))
+}
+
+// TalkTime returns the peer's accumulated speaking time.
+func (p *Peer) TalkTime() time.Duration {
+	return time.Duration(p.talkTime.Load())
+}
+
+// PeerStats summarizes a peer's participation for room_stats and the admin API.
+type PeerStats = protocol.PeerStats
+
+// Stats returns per-peer participation statistics.
+func (r *Room) Stats() []PeerStats {
+	r.Lock.RLock()
+	defer r.Lock.RUnlock()
+	stats := make([]PeerStats, 0, len(r.Peers))
+	for _, peer := range r.Peers {
+		bandwidth := peer.bandwidth.snapshot()
+		stats = append(stats, PeerStats{
+			ID:         peer.ID,
+			Name:       peer.Name,
+			JoinedAt:   peer.JoinTime,
+			TalkTimeMS: peer.TalkTime().Milliseconds(),
+			BytesIn:    bandwidth.BytesIn,
+			BytesOut:   bandwidth.BytesOut,
+
+			BitrateViolations:     peer.bitrateViolations.Load(),
+			BitrateDroppedPackets: peer.bitrateDropped.Load(),
+		})
+	}
+	return stats
+}
+
+// Room returns the room the peer is currently in.
+func (p *Peer) Room() *Room {
+	p.roomMu.RLock()
+	defer p.roomMu.RUnlock()
+	return p.room
+}
+
+func (p *Peer) setRoom(room *Room) {
+	p.roomMu.Lock()
+	p.room = room
+	p.roomMu.Unlock()
+}
+
+// sleep waits for d and reports false if the peer disconnected first.
+func (p *Peer) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-p.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Context returns the peer's session context. Peers built without one, such as
+// those refused right after the upgrade, get a context that is never done.
+func (p *Peer) Context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// Done is closed when the peer's session ends.
+func (p *Peer) Done() <-chan struct{} {
+	return p.Context().Done()
+}
+
+// SignalDone cancels the peer's context, stopping the goroutines serving it.
 func (p *Peer) SignalDone() {
-	p.doneOnce.Do(func() {
-		if p.Done != nil {
-			close(p.Done)
-		}
-	})
+	if p.cancel != nil {
+		p.cancel()
+	}
 }
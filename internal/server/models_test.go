@@ -1,14 +1,19 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
 )
 
@@ -131,7 +136,7 @@ func TestTrackForwarderRecordWriteErrorRemovesSubscriberOnClosed(t *testing.T) {
 		t.Fatalf("failed to create local track: %v", err)
 	}
 
-	forwarder := NewTrackForwarder("sender", nil)
+	forwarder := NewTrackForwarder(context.Background(), "sender", nil)
 	forwarder.Subscribe("receiver", localTrack)
 	if forwarder.SubscriberCount() != 1 {
 		t.Fatal("expected subscriber to be added")
@@ -153,7 +158,7 @@ func TestTrackForwarderRecordWriteErrorKeepsSubscriberOnGenericError(t *testing.
 		t.Fatalf("failed to create local track: %v", err)
 	}
 
-	forwarder := NewTrackForwarder("sender", nil)
+	forwarder := NewTrackForwarder(context.Background(), "sender", nil)
 	forwarder.Subscribe("receiver", localTrack)
 
 	forwarder.recordWriteError("receiver", errors.New("write failed"))
@@ -164,7 +169,7 @@ func TestTrackForwarderRecordWriteErrorKeepsSubscriberOnGenericError(t *testing.
 
 func TestTrackForwarderStopWithErrorCallsOnStopOnce(t *testing.T) {
 	var calls int32
-	forwarder := NewTrackForwarder("sender", nil)
+	forwarder := NewTrackForwarder(context.Background(), "sender", nil)
 	forwarder.onStop = func(err error) {
 		atomic.AddInt32(&calls, 1)
 	}
@@ -176,3 +181,169 @@ func TestTrackForwarderStopWithErrorCallsOnStopOnce(t *testing.T) {
 		t.Fatalf("expected onStop to be called once, got %d", got)
 	}
 }
+
+func TestPeerIdleForTracksActivity(t *testing.T) {
+	peer := &Peer{JoinTime: time.Now().Add(-time.Hour)}
+	if peer.IdleFor() < time.Hour {
+		t.Fatal("expected idle time to count from join when never active")
+	}
+	peer.Touch()
+	if peer.IdleFor() > time.Second {
+		t.Fatalf("expected activity to reset idle time, got %v", peer.IdleFor())
+	}
+}
+
+func TestTrackForwarderDetectSpeechUsesAudioLevel(t *testing.T) {
+	var total time.Duration
+	forwarder := NewTrackForwarder(context.Background(), "sender", nil)
+	forwarder.audioLevelExtID = 1
+	forwarder.onSpeech = func(d time.Duration) { total += d }
+
+	packet := func(ts uint32, level uint8) []byte {
+		ext, err := rtp.AudioLevelExtension{Level: level, Voice: true}.Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal audio level: %v", err)
+		}
+		pkt := &rtp.Packet{Header: rtp.Header{Version: 2, Timestamp: ts}, Payload: []byte{0x01}}
+		if err := pkt.SetExtension(1, ext); err != nil {
+			t.Fatalf("failed to set extension: %v", err)
+		}
+		raw, err := pkt.Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal packet: %v", err)
+		}
+		return raw
+	}
+
+	forwarder.detectSpeech(packet(0, 30))     // speech, first packet assumes 20ms
+	forwarder.detectSpeech(packet(1920, 30))  // speech, 40ms by timestamp
+	forwarder.detectSpeech(packet(2880, 127)) // silence
+
+	if total != 60*time.Millisecond {
+		t.Fatalf("talk time = %v, want 60ms", total)
+	}
+}
+
+func TestRoomStatsReportsTalkTime(t *testing.T) {
+	peer := &Peer{ID: "peer", Name: "alice", JoinTime: time.Now()}
+	peer.AddTalkTime(1500 * time.Millisecond)
+	room := &Room{Peers: map[string]*Peer{"peer": peer}}
+
+	stats := room.Stats()
+	if len(stats) != 1 || stats[0].TalkTimeMS != 1500 || stats[0].Name != "alice" {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+}
+
+func TestTrackForwarderConvertsBetweenREDAndOpus(t *testing.T) {
+	opus := []byte{0xde, 0xad, 0xbe, 0xef}
+	marshal := func(payloadType uint8, payload []byte) []byte {
+		raw, err := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: payloadType, SequenceNumber: 7, Timestamp: 960},
+			Payload: payload,
+		}).Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return raw
+	}
+	unmarshal := func(raw []byte) *rtp.Packet {
+		packet := &rtp.Packet{}
+		if err := packet.Unmarshal(raw); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return packet
+	}
+
+	// Subscribers bound to Opus receive the primary block of a RED packet.
+	toOpus := &TrackForwarder{}
+	toOpus.SetPayloadTypes(111, 63, 111)
+	redPayload := append([]byte{0x80 | 111, 0x00, 0x3c, 0x02, 111}, 0x01, 0x02)
+	redPayload = append(redPayload, opus...)
+	got := unmarshal(toOpus.convertPayloadType(marshal(63, redPayload)))
+	if got.PayloadType != 111 || !bytes.Equal(got.Payload, opus) {
+		t.Fatalf("expected opus primary, got pt=%d payload=%x", got.PayloadType, got.Payload)
+	}
+	if toOpus.convertPayloadType(marshal(63, []byte{0x80 | 111, 0x00})) != nil {
+		t.Fatal("expected malformed RED packet to be dropped")
+	}
+
+	// Subscribers bound to RED receive plain Opus wrapped as a single primary block.
+	toRED := &TrackForwarder{}
+	toRED.SetPayloadTypes(63, 63, 111)
+	got = unmarshal(toRED.convertPayloadType(marshal(111, opus)))
+	primary, err := unwrapRED(got.Payload)
+	if got.PayloadType != 63 || err != nil || !bytes.Equal(primary, opus) {
+		t.Fatalf("expected RED-wrapped opus, got pt=%d payload=%x err=%v", got.PayloadType, got.Payload, err)
+	}
+
+	// Without RED negotiated, packets pass through unchanged.
+	plain := marshal(111, opus)
+	if out := (&TrackForwarder{}).convertPayloadType(plain); !bytes.Equal(out, plain) {
+		t.Fatal("expected passthrough without RED")
+	}
+}
+
+func TestTrackForwarderSkipsSilence(t *testing.T) {
+	packets := [][]byte{}
+	add := func(payload []byte) {
+		raw, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: uint16(len(packets))}, Payload: payload}).Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal packet: %v", err)
+		}
+		packets = append(packets, raw)
+	}
+	add([]byte{0x78, 0x01, 0x02}) // speech
+	add([]byte{0x78})             // DTX: forwarded as comfort noise
+	add([]byte{0x78})             // DTX within comfortNoiseInterval: skipped
+	add([]byte{0x78, 0x01, 0x02}) // speech
+
+	forwarder := newSyntheticForwarder(context.Background(), "sender", webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000})
+	forwarder.skipSilence = true
+	forwarder.read = func(buf []byte) (int, error) {
+		if len(packets) == 0 {
+			return 0, io.EOF
+		}
+		n := copy(buf, packets[0])
+		packets = packets[1:]
+		return n, nil
+	}
+	var forwarded []uint16
+	forwarder.AddTap("test", func(packet []byte) {
+		var header rtp.Header
+		if _, err := header.Unmarshal(packet); err == nil {
+			forwarded = append(forwarded, header.SequenceNumber)
+		}
+	})
+	forwarder.Start()
+
+	if !slices.Equal(forwarded, []uint16{0, 1, 3}) {
+		t.Fatalf("forwarded sequence numbers = %v, want [0 1 3]", forwarded)
+	}
+}
+
+func TestTrackForwarderStopsWithParentContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	forwarder := newSyntheticForwarder(ctx, "sender", webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus})
+	forwarder.read = func([]byte) (int, error) {
+		<-forwarder.ctx.Done()
+		return 0, io.EOF
+	}
+	stopped := false
+	forwarder.onStop = func(error) { stopped = true }
+
+	done := make(chan struct{})
+	go func() {
+		forwarder.Start()
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("forwarder still running after its parent context was cancelled")
+	}
+	if stopped {
+		t.Fatal("onStop ran for a cancelled forwarder")
+	}
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Signaling frames are JSON in text frames and, for clients that negotiate
+// encoding "msgpack" in hello, MessagePack in binary frames. Only the subset of
+// MessagePack that mirrors JSON is supported: nil, bool, numbers, str/bin,
+// arrays and maps with string keys.
+
+const encodingMsgpack = "msgpack"
+
+// maxMsgpackDepth bounds nesting so a hostile frame cannot exhaust the stack.
+const maxMsgpackDepth = 32
+
+var errMsgpack = errors.New("invalid msgpack")
+
+// marshalMsgpack encodes v as MessagePack. Values go through encoding/json first
+// so struct tags apply exactly as they do for JSON clients.
+func marshalMsgpack(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree any
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		return nil, err
+	}
+	return appendMsgpack(nil, tree), nil
+}
+
+func appendMsgpack(buf []byte, v any) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case bool:
+		if v {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return appendMsgpackInt(buf, int64(v))
+		}
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			buf = append(buf, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			buf = append(buf, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+		default:
+			buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+		}
+		return append(buf, v...)
+	case []any:
+		buf = appendMsgpackHeader(buf, len(v), 0x90, 0xdc, 0xdd)
+		for _, item := range v {
+			buf = appendMsgpack(buf, item)
+		}
+		return buf
+	case map[string]any:
+		buf = appendMsgpackHeader(buf, len(v), 0x80, 0xde, 0xdf)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			buf = appendMsgpack(buf, key)
+			buf = appendMsgpack(buf, v[key])
+		}
+		return buf
+	default:
+		// json.Unmarshal into any produces only the types above.
+		panic(fmt.Sprintf("msgpack: unexpected %T", v))
+	}
+}
+
+func appendMsgpackHeader(buf []byte, n int, fix, b16, b32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, b16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, b32), uint32(n))
+	}
+}
+
+func appendMsgpackInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n < 128:
+		return append(buf, byte(n))
+	case n < 0 && n >= -32:
+		return append(buf, byte(n))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		return append(buf, 0xd0, byte(n))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(n))
+	}
+}
+
+// unmarshalMsgpack decodes a MessagePack document into the same types
+// encoding/json produces for any: numbers become float64 and bin becomes string.
+func unmarshalMsgpack(data []byte) (any, error) {
+	d := msgpackDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%w: trailing bytes", errMsgpack)
+	}
+	return v, nil
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) take(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, fmt.Errorf("%w: truncated", errMsgpack)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.take(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func (d *msgpackDecoder) value(depth int) (any, error) {
+	if depth > maxMsgpackDepth {
+		return nil, fmt.Errorf("%w: nested too deeply", errMsgpack)
+	}
+	head, err := d.take(1)
+	if err != nil {
+		return nil, err
+	}
+	c := head[0]
+	switch {
+	case c <= 0x7f:
+		return float64(c), nil
+	case c >= 0xe0:
+		return float64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return d.object(int(c&0x0f), depth)
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+		return float64(n), err
+	case 0xd0:
+		n, err := d.uint(1)
+		return float64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return float64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return float64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return float64(int64(n)), err
+	case 0xd9, 0xc4:
+		return d.sized(1, d.str)
+	case 0xda, 0xc5:
+		return d.sized(2, d.str)
+	case 0xdb, 0xc6:
+		return d.sized(4, d.str)
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(int(n), depth)
+	}
+	return nil, fmt.Errorf("%w: unsupported type 0x%02x", errMsgpack, c)
+}
+
+func (d *msgpackDecoder) sized(size int, read func(int) (any, error)) (any, error) {
+	n, err := d.uint(size)
+	if err != nil {
+		return nil, err
+	}
+	return read(int(n))
+}
+
+func (d *msgpackDecoder) str(n int) (any, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) array(n, depth int) (any, error) {
+	// Every element takes at least one byte, which bounds the allocation.
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("%w: truncated", errMsgpack)
+	}
+	items := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		item, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (d *msgpackDecoder) object(n, depth int) (any, error) {
+	if n > (len(d.data)-d.pos)/2 {
+		return nil, fmt.Errorf("%w: truncated", errMsgpack)
+	}
+	obj := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: map keys must be strings", errMsgpack)
+		}
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		obj[name] = value
+	}
+	return obj, nil
+}
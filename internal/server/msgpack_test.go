@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	in := map[string]any{
+		"type":   "room_state",
+		"count":  float64(300),
+		"neg":    float64(-40000),
+		"ratio":  0.25,
+		"ok":     true,
+		"none":   nil,
+		"long":   strings.Repeat("x", 300),
+		"peers":  []any{map[string]any{"id": "a"}, "b"},
+		"nested": map[string]any{"n": float64(1 << 40)},
+	}
+	data, err := marshalMsgpack(in)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	out, err := unmarshalMsgpack(data)
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("round trip = %#v, want %#v", out, in)
+	}
+
+	for _, bad := range [][]byte{{0xdc, 0xff, 0xff}, {0x81, 0x01, 0x01}, {0xc1}, append(data, 0xc0)} {
+		if _, err := unmarshalMsgpack(bad); !errors.Is(err, errMsgpack) {
+			t.Fatalf("unmarshal(%x) err = %v, want errMsgpack", bad, err)
+		}
+	}
+}
+
+func TestHelloNegotiatesMsgpack(t *testing.T) {
+	_, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "room-msgpack", "alice")
+	readUntilType(t, conn, "room_state")
+
+	if err := conn.WriteJSON(map[string]any{"type": "hello", "capabilities": map[string]any{}, "encoding": "msgpack"}); err != nil {
+		t.Fatalf("failed to send hello: %v", err)
+	}
+	if ack := readUntilType(t, conn, "hello_ack"); ack["encoding"] != encodingMsgpack {
+		t.Fatalf("hello_ack = %v, want msgpack encoding", ack)
+	}
+
+	request, _ := marshalMsgpack(map[string]any{"type": "room_stats"})
+	if err := conn.WriteMessage(websocket.BinaryMessage, request); err != nil {
+		t.Fatalf("failed to send msgpack frame: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed waiting for room_stats: %v", err)
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Fatalf("got text frame %s after negotiating msgpack", data)
+		}
+		decoded, err := unmarshalMsgpack(data)
+		if err != nil {
+			t.Fatalf("invalid msgpack from server: %v", err)
+		}
+		if msg, _ := decoded.(map[string]any); msg["type"] == "room_stats" {
+			return
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"log/slog"
+	"slices"
+
+	"sigmartc/internal/logger"
+)
+
+// While a room is muted, the forwarders of everyone without PermMuteOthers drop
+// their audio. Muted peers may send unmute_request; hosts and moderators see the
+// queue and answer with approve_unmute or deny_unmute. An approved peer is
+// forwarded again until the next mute_all.
+
+// setMutedAll mutes or unmutes the whole room, forgetting earlier approvals and
+// pending requests either way.
+func (r *Room) setMutedAll(muted bool) {
+	r.Lock.Lock()
+	r.mutedAll.Store(muted)
+	r.unmuted = nil
+	r.unmuteRequests = nil
+	r.Lock.Unlock()
+
+	logger.LogEvent("MUTE_ALL", slog.String("uuid", r.UUID), slog.Bool("muted", muted))
+	r.Broadcast("", map[string]any{"type": "mute_all", "muted": muted})
+	r.broadcastUnmuteRequests(nil)
+}
+
+// mutes reports whether the peer's audio is held back by mute_all.
+func (r *Room) mutes(peer *Peer) bool {
+	if !r.mutedAll.Load() || peer.Can(PermMuteOthers) {
+		return false
+	}
+	r.Lock.RLock()
+	defer r.Lock.RUnlock()
+	return !r.unmuted[peer.ID]
+}
+
+// requestUnmute queues the peer's unmute request if it is muted and not already waiting.
+func (r *Room) requestUnmute(peer *Peer) {
+	if !r.mutes(peer) {
+		return
+	}
+	r.Lock.Lock()
+	if slices.Contains(r.unmuteRequests, peer.ID) {
+		r.Lock.Unlock()
+		return
+	}
+	r.unmuteRequests = append(r.unmuteRequests, peer.ID)
+	queue := append([]string{}, r.unmuteRequests...)
+	r.Lock.Unlock()
+	r.broadcastUnmuteRequests(queue)
+}
+
+// answerUnmute removes peerID's request and, when approved, lets it speak. It
+// reports whether the request existed.
+func (r *Room) answerUnmute(peerID string, approve bool) bool {
+	r.Lock.Lock()
+	i := slices.Index(r.unmuteRequests, peerID)
+	if i < 0 {
+		r.Lock.Unlock()
+		return false
+	}
+	r.unmuteRequests = slices.Delete(r.unmuteRequests, i, i+1)
+	if approve {
+		if r.unmuted == nil {
+			r.unmuted = make(map[string]bool)
+		}
+		r.unmuted[peerID] = true
+	}
+	queue := append([]string{}, r.unmuteRequests...)
+	target := r.Peers[peerID]
+	r.Lock.Unlock()
+
+	if target != nil {
+		target.WriteJSON(map[string]any{"type": "unmute_answer", "approved": approve})
+	}
+	r.broadcastUnmuteRequests(queue)
+	return true
+}
+
+// forgetUnmute drops peerID's request and approval, e.g. when it leaves.
+func (r *Room) forgetUnmute(peerID string) {
+	r.Lock.Lock()
+	delete(r.unmuted, peerID)
+	i := slices.Index(r.unmuteRequests, peerID)
+	if i >= 0 {
+		r.unmuteRequests = slices.Delete(r.unmuteRequests, i, i+1)
+	}
+	queue := append([]string{}, r.unmuteRequests...)
+	r.Lock.Unlock()
+	if i >= 0 {
+		r.broadcastUnmuteRequests(queue)
+	}
+}
+
+// broadcastUnmuteRequests sends the pending queue to the peers who can answer it.
+func (r *Room) broadcastUnmuteRequests(queue []string) {
+	if queue == nil {
+		queue = []string{}
+	}
+	msg := map[string]any{"type": "unmute_requests", "queue": queue}
+	r.Lock.RLock()
+	managers := make([]*Peer, 0, len(r.Peers))
+	for _, peer := range r.Peers {
+		if peer.Can(PermMuteOthers) {
+			managers = append(managers, peer)
+		}
+	}
+	r.Lock.RUnlock()
+	for _, peer := range managers {
+		peer.WriteJSON(msg)
+	}
+}
+
+// handleMuteMessage handles mute_all, unmute_all, unmute_request, approve_unmute
+// and deny_unmute.
+func (h *Handler) handleMuteMessage(room *Room, peer *Peer, t string, msg map[string]any) {
+	if t == "unmute_request" {
+		room.requestUnmute(peer)
+		return
+	}
+	if !peer.Can(PermMuteOthers) {
+		peer.WriteJSON(map[string]string{"type": "error", "message": t + " not allowed"})
+		return
+	}
+	switch t {
+	case "mute_all", "unmute_all":
+		room.setMutedAll(t == "mute_all")
+		peer.log().Info("Room mute changed by peer", "uuid", room.UUID, "muted", t == "mute_all")
+	case "approve_unmute", "deny_unmute":
+		target, _ := msg["peer_id"].(string)
+		if !room.answerUnmute(target, t == "approve_unmute") {
+			peer.WriteJSON(map[string]string{"type": "error", "message": "no unmute request from that peer"})
+		}
+	}
+}
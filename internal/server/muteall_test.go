@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+func TestMuteAllAndUnmuteRequests(t *testing.T) {
+	key, jwks := newTestJWKS(t)
+	handler, srv := newTestWSServer(t)
+	handler.JWTAuth = NewJWTAuth(jwks.URL, "", "")
+	dial := func(sub, role string) (*websocket.Conn, string) {
+		token := signTestToken(t, key, "k1", jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix(), "sub": sub, "role": role})
+		wsURL, _ := buildWSURL(srv.URL, "room-mute", sub)
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+"&token="+token, nil)
+		if err != nil {
+			t.Fatalf("dial %s: %v", sub, err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		id, _ := readUntilType(t, conn, "room_state")["self_id"].(string)
+		return conn, id
+	}
+	send := func(conn *websocket.Conn, msg map[string]string) {
+		t.Helper()
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	mod, modID := dial("mod", "moderator")
+	speaker, speakerID := dial("speaker", "")
+	room := handler.RoomManager.Rooms["room-mute"]
+	_, modPeer := handler.RoomManager.FindPeer(modID)
+	_, speakerPeer := handler.RoomManager.FindPeer(speakerID)
+
+	send(speaker, map[string]string{"type": "mute_all"})
+	readUntilType(t, speaker, "error")
+
+	send(mod, map[string]string{"type": "mute_all"})
+	if msg := readUntilType(t, speaker, "mute_all"); msg["muted"] != true {
+		t.Fatalf("mute_all = %v", msg)
+	}
+	if !room.mutes(speakerPeer) || room.mutes(modPeer) {
+		t.Fatal("expected the speaker, not the moderator, to be muted")
+	}
+
+	send(speaker, map[string]string{"type": "unmute_request"})
+	for {
+		msg := readUntilType(t, mod, "unmute_requests")
+		if queue, _ := msg["queue"].([]any); len(queue) == 1 && queue[0] == speakerID {
+			break
+		}
+	}
+	send(mod, map[string]string{"type": "approve_unmute", "peer_id": speakerID})
+	if msg := readUntilType(t, speaker, "unmute_answer"); msg["approved"] != true {
+		t.Fatalf("unmute_answer = %v", msg)
+	}
+	if room.mutes(speakerPeer) {
+		t.Fatal("expected the approved speaker to be forwarded")
+	}
+
+	send(mod, map[string]string{"type": "unmute_all"})
+	if msg := readUntilType(t, speaker, "mute_all"); msg["muted"] != false {
+		t.Fatalf("mute_all = %v", msg)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"sigmartc/internal/logger"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+// NegotiationConfig holds the ICE timers and restart policy shared by the
+// SettingEngine and the handler's restart logic.
+type NegotiationConfig struct {
+	// ICERestartDelay is how long ICE may stay disconnected before the server
+	// offers an ICE restart.
+	ICERestartDelay time.Duration
+	// ICERestartMinInterval is the minimum time between two restarts of one peer.
+	ICERestartMinInterval time.Duration
+	// ICEDisconnectedTimeout, ICEFailedTimeout and ICEKeepaliveInterval are passed
+	// to SettingEngine.SetICETimeouts.
+	ICEDisconnectedTimeout time.Duration
+	ICEFailedTimeout       time.Duration
+	ICEKeepaliveInterval   time.Duration
+	// ICECredentialRefreshInterval, when positive, restarts ICE on every peer
+	// this often so long calls rotate their ICE ufrag/password. It does not
+	// re-key DTLS or SRTP. Zero disables it and withDefaults leaves it unset.
+	ICECredentialRefreshInterval time.Duration
+	// NegotiationTimeout is how long an offer/answer exchange may take, from
+	// waiting for the client's first offer or an answer to our offer to retrying
+	// a failed offer, before the peer is disconnected.
+	NegotiationTimeout time.Duration
+}
+
+// DefaultNegotiationConfig returns the built-in policy. The 5s keepalive keeps
+// NAT mappings alive, since ISP NAT entries typically expire after 30-60s.
+func DefaultNegotiationConfig() NegotiationConfig {
+	return NegotiationConfig{
+		ICERestartDelay:        5 * time.Second,
+		ICERestartMinInterval:  15 * time.Second,
+		ICEDisconnectedTimeout: 8 * time.Second,
+		ICEFailedTimeout:       30 * time.Second,
+		ICEKeepaliveInterval:   5 * time.Second,
+		NegotiationTimeout:     30 * time.Second,
+	}
+}
+
+// withDefaults fills zero fields from DefaultNegotiationConfig.
+func (c NegotiationConfig) withDefaults() NegotiationConfig {
+	d := DefaultNegotiationConfig()
+	if c.ICERestartDelay <= 0 {
+		c.ICERestartDelay = d.ICERestartDelay
+	}
+	if c.ICERestartMinInterval <= 0 {
+		c.ICERestartMinInterval = d.ICERestartMinInterval
+	}
+	if c.ICEDisconnectedTimeout <= 0 {
+		c.ICEDisconnectedTimeout = d.ICEDisconnectedTimeout
+	}
+	if c.ICEFailedTimeout <= 0 {
+		c.ICEFailedTimeout = d.ICEFailedTimeout
+	}
+	if c.ICEKeepaliveInterval <= 0 {
+		c.ICEKeepaliveInterval = d.ICEKeepaliveInterval
+	}
+	if c.NegotiationTimeout <= 0 {
+		c.NegotiationTimeout = d.NegotiationTimeout
+	}
+	return c
+}
+
+// ApplyTo sets the ICE timeouts on a SettingEngine.
+func (c NegotiationConfig) ApplyTo(settings *webrtc.SettingEngine) {
+	c = c.withDefaults()
+	settings.SetICETimeouts(c.ICEDisconnectedTimeout, c.ICEFailedTimeout, c.ICEKeepaliveInterval)
+}
+
+// refreshICECredentials restarts the peer's ICE every
+// ICECredentialRefreshInterval until it leaves. Each wait is jittered by ±10%
+// so peers that joined together do not renegotiate in lockstep. An ICE restart
+// issues fresh ICE credentials through the normal offer/answer path. It gives
+// no forward secrecy: the DTLS association, and so the SRTP keys derived from
+// it, survive an ICE restart in pion. It starts after setupWebRTC.
+func (h *Handler) refreshICECredentials(peer *Peer) {
+	interval := h.Negotiation.ICECredentialRefreshInterval
+	for {
+		jitter := rand.N(interval/5+1) - interval/10
+		if !peer.sleep(interval + jitter) {
+			return
+		}
+		if !h.requestICERestart(peer) {
+			peer.log().Debug("ICE credential refresh throttled")
+			continue
+		}
+		logger.LogEvent("ICE_CREDENTIAL_REFRESH", slog.String("peer_id", peer.ID), slog.String("session_id", peer.SessionID))
+	}
+}
+
+// clientPolite is the perfect-negotiation role sent to every client in
+// room_state. pion cannot roll back a local offer, so the server is always the
+// impolite peer: it ignores a client offer that collides with its own, and the
+// polite client rolls back instead.
+const clientPolite = true
+
+// ignoringOffer reports whether the client's last offer was ignored in a collision.
+func (p *Peer) ignoringOffer() bool {
+	p.NegotiationMu.Lock()
+	defer p.NegotiationMu.Unlock()
+	return p.IgnoreOffer
+}
+
+// failNegotiation disconnects a peer whose offer/answer exchange did not finish
+// within NegotiationTimeout, e.g. because its client ignores our offers.
+func (h *Handler) failNegotiation(peer *Peer) {
+	timeout := h.Negotiation.NegotiationTimeout
+	peer.log().Warn("Negotiation timed out", "timeout", timeout.String(), "signaling_state", peer.PC.SignalingState().String())
+	peer.note(TimelineNegotiationError, "timed out after "+timeout.String())
+	peer.WriteJSON(map[string]string{"type": "error", "message": "Negotiation timed out"})
+	peer.Disconnect(DisconnectNegotiationTimeout, "Connection setup timed out, please rejoin")
+}
+
+// checkOfferSDP rejects client offers that pion accepts in SetRemoteDescription
+// but cannot answer, which would leave the peer in have-remote-offer for good.
+func checkOfferSDP(raw string) error {
+	var parsed sdp.SessionDescription
+	if err := parsed.UnmarshalString(raw); err != nil {
+		return err
+	}
+	if len(parsed.MediaDescriptions) == 0 {
+		return errors.New("no media sections")
+	}
+	return nil
+}
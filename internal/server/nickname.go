@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var errNicknameDenied = errors.New("name not allowed")
+
+// NicknameFilter rejects or masks nicknames matching a deny-list. A nil filter allows everything.
+type NicknameFilter struct {
+	rules []*regexp.Regexp
+	// Mask replaces matches with asterisks instead of rejecting the name.
+	Mask bool
+}
+
+// LoadNicknameFilter reads a deny-list with one rule per line. Plain lines match
+// case-insensitively anywhere in the name; lines starting with "re:" are regular
+// expressions. Blank lines and lines starting with "#" are ignored.
+func LoadNicknameFilter(path string) (*NicknameFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	filter := &NicknameFilter{}
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern := "(?i)" + regexp.QuoteMeta(line)
+		if expr, ok := strings.CutPrefix(line, "re:"); ok {
+			pattern = expr
+		}
+		rule, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		filter.rules = append(filter.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// describe summarizes the filter for reload diffs.
+func (f *NicknameFilter) describe() string {
+	if f == nil {
+		return "off"
+	}
+	rules := make([]string, len(f.rules))
+	for i, rule := range f.rules {
+		rules[i] = rule.String()
+	}
+	mode := "reject"
+	if f.Mask {
+		mode = "mask"
+	}
+	return fmt.Sprintf("%d rules, %s (%08x)", len(f.rules), mode, crc32.ChecksumIEEE([]byte(strings.Join(rules, "\n"))))
+}
+
+// Apply returns name with matches masked, or errNicknameDenied if it matches and
+// masking is off.
+func (f *NicknameFilter) Apply(name string) (string, error) {
+	if f == nil {
+		return name, nil
+	}
+	for _, rule := range f.rules {
+		if !rule.MatchString(name) {
+			continue
+		}
+		if !f.Mask {
+			return "", errNicknameDenied
+		}
+		name = rule.ReplaceAllStringFunc(name, func(match string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(match))
+		})
+	}
+	return name, nil
+}
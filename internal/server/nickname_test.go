@@ -0,0 +1,57 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeNicknameFilter(t *testing.T, content string) *NicknameFilter {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write deny-list: %v", err)
+	}
+	filter, err := LoadNicknameFilter(path)
+	if err != nil {
+		t.Fatalf("LoadNicknameFilter failed: %v", err)
+	}
+	return filter
+}
+
+func TestNicknameFilterRejects(t *testing.T) {
+	filter := writeNicknameFilter(t, "# words\nbadword\n\nre:^admin\n")
+
+	if _, err := normalizeNickname("xBADWORDx", filter); err != errNicknameDenied {
+		t.Fatalf("err = %v, want errNicknameDenied", err)
+	}
+	if _, err := normalizeNickname("admin1", filter); err != errNicknameDenied {
+		t.Fatalf("err = %v, want errNicknameDenied", err)
+	}
+	if name, err := normalizeNickname("myadmin", filter); err != nil || name != "myadmin" {
+		t.Fatalf("got %q, %v; want myadmin allowed", name, err)
+	}
+}
+
+func TestNicknameFilterMasks(t *testing.T) {
+	filter := writeNicknameFilter(t, "坏蛋\n")
+	filter.Mask = true
+
+	name, err := normalizeNickname("我是坏蛋", filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "我是**" {
+		t.Fatalf("name = %q, want 我是**", name)
+	}
+}
+
+func TestLoadNicknameFilterReportsBadRegexp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("re:(\n"), 0o644); err != nil {
+		t.Fatalf("failed to write deny-list: %v", err)
+	}
+	if _, err := LoadNicknameFilter(path); err == nil {
+		t.Fatal("expected invalid regexp to fail loading")
+	}
+}
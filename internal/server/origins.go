@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AllowedOrigins lists origins besides the server's own that may open WebSockets,
+// for frontends hosted elsewhere. A pattern is "[scheme://]host[:port]"; a host
+// of "*.example.com" matches any subdomain of example.com but not example.com
+// itself. Without a scheme any scheme matches, and without a port any port does.
+type AllowedOrigins []originPattern
+
+type originPattern struct {
+	scheme string
+	host   string
+	port   string
+	// suffix is set for wildcard patterns and holds ".example.com".
+	suffix string
+}
+
+// ParseAllowedOrigins parses a comma-separated list of origin patterns.
+func ParseAllowedOrigins(s string) (AllowedOrigins, error) {
+	var origins AllowedOrigins
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		var p originPattern
+		if scheme, rest, ok := strings.Cut(item, "://"); ok {
+			p.scheme, item = scheme, rest
+		}
+		u, err := url.Parse("//" + item)
+		if err != nil || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+			return nil, fmt.Errorf("invalid allowed origin %q", item)
+		}
+		p.host, p.port = u.Hostname(), u.Port()
+		if rest, ok := strings.CutPrefix(p.host, "*."); ok {
+			if rest == "" || strings.Contains(rest, "*") {
+				return nil, fmt.Errorf("invalid allowed origin %q", item)
+			}
+			p.host, p.suffix = "", "."+rest
+		} else if strings.Contains(p.host, "*") {
+			return nil, fmt.Errorf("invalid allowed origin %q: only a leading *. wildcard is supported", item)
+		}
+		origins = append(origins, p)
+	}
+	return origins, nil
+}
+
+// allows reports whether the Origin header value u matches any pattern.
+func (a AllowedOrigins) allows(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	for _, p := range a {
+		if p.scheme != "" && p.scheme != scheme {
+			continue
+		}
+		if p.port != "" && p.port != port {
+			continue
+		}
+		if p.suffix != "" {
+			if strings.HasSuffix(host, p.suffix) && len(host) > len(p.suffix) {
+				return true
+			}
+			continue
+		}
+		if p.host == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// outboundQueueSize bounds the messages waiting to be written to one client.
+const outboundQueueSize = 256
+
+// closeWait is how long sendClose waits for the writer to flush and send the
+// close frame. It is a variable so tests can shorten it.
+var closeWait = 2 * wsWriteWait
+
+// wsFrame is an encoded message waiting in a peer's outbound queue.
+type wsFrame struct {
+	messageType int
+	data        []byte
+}
+
+// closeFrame is the close code and reason the writer sends last.
+type closeFrame struct {
+	code int
+	text string
+}
+
+// encode serializes v for the peer's negotiated encoding.
+func (p *Peer) encode(v any) (wsFrame, error) {
+	if p.msgpack.Load() {
+		data, err := marshalMsgpack(v)
+		return wsFrame{websocket.BinaryMessage, data}, err
+	}
+	data, err := json.Marshal(v)
+	return wsFrame{websocket.TextMessage, data}, err
+}
+
+// startWriter moves the peer's WebSocket writes onto a dedicated goroutine fed by
+// a buffered queue, so WriteJSON and Room.Broadcast never wait on a slow client's
+// TCP backpressure. Call it once, before the peer is visible to other goroutines.
+func (p *Peer) startWriter() {
+	p.outbox = make(chan wsFrame, outboundQueueSize)
+	p.closing = make(chan closeFrame, 1)
+	p.writerDone = make(chan struct{})
+	go p.runWriter()
+}
+
+func (p *Peer) runWriter() {
+	defer close(p.writerDone)
+	for {
+		select {
+		case frame := <-p.outbox:
+			if err := p.Conn.WriteMessage(frame.messageType, frame.data, time.Now().Add(wsWriteWait)); err != nil {
+				p.log().Warn("WS write failed", "err", err)
+				p.SignalDone()
+				_ = p.Conn.Close()
+				return
+			}
+		case closing := <-p.closing:
+			p.flushAndClose(closing)
+			return
+		case <-p.Done():
+			return
+		}
+	}
+}
+
+// flushAndClose writes whatever is still queued, then the close frame, all within
+// one write deadline.
+func (p *Peer) flushAndClose(closing closeFrame) {
+	deadline := time.Now().Add(wsWriteWait)
+drain:
+	for {
+		select {
+		case frame := <-p.outbox:
+			if err := p.Conn.WriteMessage(frame.messageType, frame.data, deadline); err != nil {
+				return
+			}
+		default:
+			break drain
+		}
+	}
+	_ = p.Conn.WriteClose(closing.code, closing.text, deadline)
+}
+
+// sendClose sends frame as the session's close frame, after the messages still
+// queued, unless one was already sent or the connection is gone.
+func (p *Peer) sendClose(frame closeFrame) {
+	if p.Conn == nil || !p.closeSent.CompareAndSwap(false, true) {
+		return
+	}
+	if p.outbox != nil {
+		// The writer may already have stopped; closing is buffered for that.
+		p.closing <- frame
+		select {
+		case <-p.writerDone:
+		case <-time.After(closeWait):
+		}
+		return
+	}
+	p.WsMutex.Lock()
+	_ = p.Conn.WriteClose(frame.code, frame.text, time.Now().Add(wsWriteWait))
+	p.WsMutex.Unlock()
+}
+
+// enqueue hands an encoded message to the writer. A client that lets its queue
+// fill up is too slow to keep in the room and is disconnected.
+func (p *Peer) enqueue(frame wsFrame) {
+	select {
+	case <-p.Done():
+		return
+	case p.outbox <- frame:
+	default:
+		p.log().Warn("WS outbound queue full, disconnecting peer", "size", outboundQueueSize)
+		go p.Disconnect(DisconnectSlowConsumer, "Connection too slow")
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFullOutboundQueueDisconnectsSlowPeer(t *testing.T) {
+	writerDone := make(chan struct{})
+	close(writerDone)
+	ctx, cancel := context.WithCancel(context.Background())
+	peer := &Peer{
+		ID:         "slow",
+		ctx:        ctx,
+		cancel:     cancel,
+		outbox:     make(chan wsFrame, 1),
+		closing:    make(chan closeFrame, 1),
+		writerDone: writerDone,
+	}
+
+	peer.WriteJSON(map[string]string{"type": "first"})
+	if peer.DisconnectReason() != "" {
+		t.Fatal("peer disconnected before its queue was full")
+	}
+	peer.WriteJSON(map[string]string{"type": "second"})
+
+	select {
+	case <-peer.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the slow peer to be disconnected")
+	}
+	if reason := peer.DisconnectReason(); reason != DisconnectSlowConsumer {
+		t.Fatalf("reason = %q, want %q", reason, DisconnectSlowConsumer)
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// statSampleInterval and statHistorySize keep five minutes of samples per peer.
+	statSampleInterval = 5 * time.Second
+	statHistorySize    = 60
+	// maxSeqJump treats larger sequence gaps as a stream restart rather than loss.
+	maxSeqJump = 1000
+)
+
+// StatSample is one point of a peer's connection history.
+type StatSample struct {
+	At time.Time `json:"at"`
+	// BitrateKbps is the rate of RTP the peer published during the interval.
+	BitrateKbps float64 `json:"bitrate_kbps"`
+	// LossPercent is the share of the peer's packets missing by sequence number.
+	LossPercent float64 `json:"loss_percent"`
+	// RTTMs is the selected ICE candidate pair's current round-trip time, 0 if unknown.
+	RTTMs float64 `json:"rtt_ms"`
+}
+
+// rtpCounter accumulates totals for the RTP a peer publishes.
+type rtpCounter struct {
+	mu      sync.Mutex
+	bytes   uint64
+	packets uint64
+	lost    uint64
+	lastSeq uint16
+	started bool
+}
+
+// record counts one packet and any sequence numbers skipped before it. Like the
+// RFC 3550 cumulative count, a late packet cancels out one earlier loss.
+func (c *rtpCounter) record(packet []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bytes += uint64(len(packet))
+	c.packets++
+	if len(packet) < 4 {
+		return
+	}
+	seq := binary.BigEndian.Uint16(packet[2:4])
+	if !c.started {
+		c.started = true
+		c.lastSeq = seq
+		return
+	}
+	gap := seq - c.lastSeq
+	if gap == 0 {
+		return
+	}
+	if gap > 0x8000 {
+		if c.lost > 0 {
+			c.lost--
+		}
+		return
+	}
+	if gap < maxSeqJump {
+		c.lost += uint64(gap - 1)
+	}
+	c.lastSeq = seq
+}
+
+func (c *rtpCounter) totals() (bytes, packets, lost uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes, c.packets, c.lost
+}
+
+// statHistory is a fixed-size window of the most recent samples.
+type statHistory struct {
+	mu      sync.Mutex
+	samples []StatSample
+}
+
+func (h *statHistory) add(s StatSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) == statHistorySize {
+		copy(h.samples, h.samples[1:])
+		h.samples[len(h.samples)-1] = s
+		return
+	}
+	h.samples = append(h.samples, s)
+}
+
+// StatHistory returns the peer's samples, oldest first.
+func (p *Peer) StatHistory() []StatSample {
+	p.history.mu.Lock()
+	defer p.history.mu.Unlock()
+	return append([]StatSample{}, p.history.samples...)
+}
+
+// recordRTP is the forwarder callback for every packet the peer publishes.
+func (p *Peer) recordRTP(packet []byte) {
+	p.Touch()
+	p.rtp.record(packet)
+	p.bandwidth.in.Add(uint64(len(packet)))
+}
+
+// sampleStats appends a StatSample to the peer's history every statSampleInterval
+// until the peer leaves. It reads peer.PC, so it starts after setupWebRTC.
+func (h *Handler) sampleStats(ctx context.Context, peer *Peer) {
+	ticker := time.NewTicker(statSampleInterval)
+	defer ticker.Stop()
+	prevBytes, prevPackets, prevLost := peer.rtp.totals()
+	prevAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			bytes, packets, lost := peer.rtp.totals()
+			sample := StatSample{At: now}
+			if elapsed := now.Sub(prevAt).Seconds(); elapsed > 0 {
+				sample.BitrateKbps = float64(bytes-prevBytes) * 8 / 1000 / elapsed
+			}
+			// A late packet can lower the loss total below the previous sample's.
+			lostDelta := max(int64(lost)-int64(prevLost), 0)
+			if expected := int64(packets-prevPackets) + lostDelta; expected > 0 {
+				sample.LossPercent = float64(lostDelta) * 100 / float64(expected)
+			}
+			if pc := peer.PC; pc != nil {
+				if transport := iceTransport(pc); transport != nil {
+					if pair, ok := transport.GetSelectedCandidatePairStats(); ok {
+						sample.RTTMs = pair.CurrentRoundTripTime * 1000
+					}
+				}
+			}
+			peer.history.add(sample)
+			prevBytes, prevPackets, prevLost, prevAt = bytes, packets, lost, now
+		}
+	}
+}
+
+// iceTransport returns the peer connection's ICE transport, or nil before the
+// SCTP/DTLS transports exist.
+func iceTransport(pc *webrtc.PeerConnection) *webrtc.ICETransport {
+	sctp := pc.SCTP()
+	if sctp == nil || sctp.Transport() == nil {
+		return nil
+	}
+	return sctp.Transport().ICETransport()
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func rtpWithSeq(seq uint16) []byte {
+	packet := make([]byte, 12)
+	packet[0] = 0x80
+	binary.BigEndian.PutUint16(packet[2:4], seq)
+	return packet
+}
+
+func TestRTPCounterCountsGapsAsLoss(t *testing.T) {
+	var c rtpCounter
+	for _, seq := range []uint16{65534, 65535, 2, 1, 3, 3, 5000} {
+		c.record(rtpWithSeq(seq))
+	}
+	bytes, packets, lost := c.totals()
+	if packets != 7 || bytes != 7*12 {
+		t.Fatalf("packets = %d, bytes = %d", packets, bytes)
+	}
+	// 0 and 1 are skipped across the wrap, then 1 arrives late; 5000 is a restart, not loss.
+	if lost != 1 {
+		t.Fatalf("lost = %d, want 1", lost)
+	}
+}
+
+func TestStatHistoryKeepsNewestSamples(t *testing.T) {
+	peer := &Peer{}
+	start := time.Now()
+	for i := 0; i < statHistorySize+5; i++ {
+		peer.history.add(StatSample{At: start.Add(time.Duration(i) * statSampleInterval)})
+	}
+	samples := peer.StatHistory()
+	if len(samples) != statHistorySize {
+		t.Fatalf("len = %d, want %d", len(samples), statHistorySize)
+	}
+	if !samples[0].At.Equal(start.Add(5 * statSampleInterval)) {
+		t.Fatalf("oldest sample at %v, want the 6th", samples[0].At)
+	}
+}
+
+func TestAdminPeerStats(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "room-history", "alice")
+	state := readUntilType(t, conn, "room_state")
+	peerID, _ := state["self_id"].(string)
+
+	rec := adminRequest(t, handler, http.MethodGet, "action=peer_stats&peer_id="+peerID)
+	var body struct {
+		PeerID  string       `json:"peer_id"`
+		Samples []StatSample `json:"samples"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.PeerID != peerID || body.Samples == nil {
+		t.Fatalf("body = %+v, want an empty sample list for %s", body, peerID)
+	}
+}
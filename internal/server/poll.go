@@ -0,0 +1,390 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Long-poll signaling: for networks whose middleboxes block WebSockets, /poll
+// carries the /ws messages over plain HTTP requests.
+//
+//   - POST /poll?room=...&name=... joins with the /ws query parameters and
+//     returns { token }. Refused joins get the /ws JSON error body.
+//   - POST /poll?token=... sends one JSON message in the body.
+//   - GET /poll?token=...&after=N waits up to pollWait for messages after
+//     sequence number N and returns { seq, messages[, close] }. With
+//     Accept: text/event-stream it streams them as Server-Sent Events instead,
+//     one "message" event per message with the sequence number as its id, and a
+//     "close" event carrying { code, reason } last.
+//   - DELETE /poll?token=... leaves.
+//
+// The resume token is the session: any request carrying it reaches the same
+// peer, and messages stay buffered until a later poll's after (or an
+// EventSource's Last-Event-ID) shows they arrived, so a dropped response or
+// stream loses nothing. Behind several servers, route /poll by the token query
+// parameter. A session nobody polls for pollIdleTimeout is closed.
+
+const (
+	// pollWait is how long a long-poll waits for messages before returning none.
+	pollWait = 25 * time.Second
+	// pollIdleTimeout closes sessions whose client stopped polling.
+	pollIdleTimeout = wsPongWait
+	// pollBacklog bounds the messages buffered for a client that is not polling.
+	pollBacklog = outboundQueueSize
+	// pollInboundSize bounds the sent messages waiting for the read loop.
+	pollInboundSize = 16
+)
+
+var (
+	errPollBacklog = errors.New("poll: client is not collecting its messages")
+	errPollIdle    = errors.New("poll: client stopped polling")
+	errPollBinary  = errors.New("poll: only JSON messages can be polled")
+)
+
+// pollMessage is a message waiting to be collected.
+type pollMessage struct {
+	seq  uint64
+	data json.RawMessage
+}
+
+// pollConn is a SignalingConn whose messages are collected by polls.
+type pollConn struct {
+	inbound  chan []byte
+	done     chan struct{}
+	lastSeen atomic.Int64
+
+	mu      sync.Mutex
+	pending []pollMessage
+	seq     uint64 // sequence number of the newest message
+	sent    uint64 // newest sequence number handed to a poll
+	closing *closeFrame
+	closed  bool
+	notify  chan struct{} // closed and replaced when messages or closing change
+}
+
+func newPollConn() *pollConn {
+	c := &pollConn{
+		inbound: make(chan []byte, pollInboundSize),
+		done:    make(chan struct{}),
+		notify:  make(chan struct{}),
+	}
+	c.touch()
+	return c
+}
+
+// touch records that the client polled.
+func (c *pollConn) touch() {
+	c.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (c *pollConn) ReadMessage() (int, []byte, error) {
+	for {
+		idle := time.Until(time.Unix(0, c.lastSeen.Load()).Add(pollIdleTimeout))
+		if idle <= 0 {
+			return 0, nil, errPollIdle
+		}
+		timer := time.NewTimer(idle)
+		select {
+		case data := <-c.inbound:
+			timer.Stop()
+			return websocket.TextMessage, data, nil
+		case <-c.done:
+			timer.Stop()
+			return 0, nil, net.ErrClosed
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *pollConn) WriteMessage(messageType int, data []byte, _ time.Time) error {
+	if messageType != websocket.TextMessage {
+		return errPollBinary
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.closing != nil {
+		return net.ErrClosed
+	}
+	if len(c.pending) >= pollBacklog {
+		// Messages already handed to a poll may be dropped to make room; they
+		// are only kept in case that response never arrived.
+		if c.pending[0].seq > c.sent {
+			return errPollBacklog
+		}
+		c.pending = c.pending[1:]
+	}
+	c.seq++
+	c.pending = append(c.pending, pollMessage{seq: c.seq, data: append(json.RawMessage(nil), data...)})
+	c.wake()
+	return nil
+}
+
+func (c *pollConn) WriteClose(code int, text string, _ time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing == nil {
+		c.closing = &closeFrame{code: code, text: text}
+		c.wake()
+	}
+	return nil
+}
+
+func (c *pollConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+		c.wake()
+	}
+	return nil
+}
+
+// wake tells waiting polls something changed. Callers hold c.mu.
+func (c *pollConn) wake() {
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// collect drops messages up to after, which the client has, and returns the
+// rest, the close frame once everything before it is returned, whether the
+// session is over, and a channel closed on the next change.
+func (c *pollConn) collect(after uint64) ([]pollMessage, *closeFrame, bool, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.pending) > 0 && c.pending[0].seq <= after {
+		c.pending = c.pending[1:]
+	}
+	messages := append([]pollMessage(nil), c.pending...)
+	if len(messages) > 0 {
+		c.sent = max(c.sent, messages[len(messages)-1].seq)
+	}
+	return messages, c.closing, c.closed, c.notify
+}
+
+// pollSessions maps resume tokens to their connections.
+type pollSessions struct {
+	mu    sync.Mutex
+	conns map[string]*pollConn
+}
+
+func (s *pollSessions) get(token string) *pollConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns[token]
+}
+
+func (s *pollSessions) add(conn *pollConn) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns == nil {
+		s.conns = make(map[string]*pollConn)
+	}
+	s.conns[token] = conn
+	return token, nil
+}
+
+func (s *pollSessions) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, token)
+}
+
+// HandlePoll serves long-poll signaling (see the top of poll.go).
+func (h *Handler) HandlePoll(w http.ResponseWriter, r *http.Request) {
+	if !h.checkWSOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.joinPoll(w, r)
+		return
+	}
+	conn := h.polls.get(token)
+	if conn == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "session_not_found", "message": "Session expired, join again"})
+		return
+	}
+	conn.touch()
+	defer conn.touch()
+	switch r.Method {
+	case http.MethodGet:
+		after, _ := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64)
+		if r.Header.Get("Accept") == "text/event-stream" {
+			if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+				after = id
+			}
+			h.streamPoll(w, r, conn, after)
+			return
+		}
+		h.longPoll(w, r, conn, after)
+	case http.MethodPost:
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignalingMessageBytes))
+		if err != nil {
+			http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		select {
+		case conn.inbound <- data:
+			w.WriteHeader(http.StatusNoContent)
+		case <-conn.done:
+			http.Error(w, "Session closed", http.StatusGone)
+		case <-r.Context().Done():
+		}
+	case http.MethodDelete:
+		_ = conn.Close()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// joinPoll runs the /ws join checks and starts a session polled with the
+// returned token.
+func (h *Handler) joinPoll(w http.ResponseWriter, r *http.Request) {
+	join, reason, message := h.checkJoin(r)
+	if reason != "" {
+		writeJoinError(w, reason, message)
+		return
+	}
+	conn := newPollConn()
+	token, err := h.polls.add(conn)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	sessionID := h.requestID(r)
+	go func() {
+		defer recoverGoroutine("poll session")
+		h.serveSession(context.WithoutCancel(r.Context()), join, sessionID, conn)
+		_ = conn.Close()
+		// Leave the client time to collect the disconnect message and close frame.
+		time.AfterFunc(pollWait, func() { h.polls.remove(token) })
+	}()
+	slog.Debug("Poll session started", "session_id", sessionID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(requestIDHeader, sessionID)
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+// longPoll answers with the messages after after, waiting up to pollWait for some.
+func (h *Handler) longPoll(w http.ResponseWriter, r *http.Request, conn *pollConn, after uint64) {
+	timeout := time.NewTimer(pollWait)
+	defer timeout.Stop()
+	for {
+		messages, closing, closed, notify := conn.collect(after)
+		if len(messages) > 0 || closing != nil || closed {
+			writePollResponse(w, after, messages, closing, closed)
+			return
+		}
+		select {
+		case <-notify:
+		case <-timeout.C:
+			writePollResponse(w, after, nil, nil, false)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writePollResponse(w http.ResponseWriter, after uint64, messages []pollMessage, closing *closeFrame, closed bool) {
+	resp := map[string]any{"seq": after, "messages": []json.RawMessage{}}
+	if len(messages) > 0 {
+		data := make([]json.RawMessage, len(messages))
+		for i, message := range messages {
+			data[i] = message.data
+		}
+		resp["seq"] = messages[len(messages)-1].seq
+		resp["messages"] = data
+	}
+	switch {
+	case closing != nil:
+		resp["close"] = map[string]any{"code": closing.code, "reason": closing.text}
+	case closed:
+		resp["close"] = map[string]any{"code": websocket.CloseAbnormalClosure, "reason": ""}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// streamPoll streams the messages after after as Server-Sent Events until the
+// session closes or the client goes away.
+func (h *Handler) streamPoll(w http.ResponseWriter, r *http.Request, conn *pollConn, after uint64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Messages stay buffered after they are streamed: the stream may break
+	// before they arrive, and the reconnect's Last-Event-ID acknowledges them.
+	cursor := after
+	keepalive := time.NewTicker(pollWait)
+	defer keepalive.Stop()
+	for {
+		messages, closing, closed, notify := conn.collect(after)
+		for _, message := range messages {
+			if message.seq <= cursor {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", message.seq, message.data); err != nil {
+				return
+			}
+			cursor = message.seq
+		}
+		if closing != nil || closed {
+			frame := closeFrame{code: websocket.CloseAbnormalClosure}
+			if closing != nil {
+				frame = *closing
+			}
+			_ = writeSSE(w, "close", map[string]any{"code": frame.code, "reason": frame.text})
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-notify:
+		case <-keepalive.C:
+			conn.touch()
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
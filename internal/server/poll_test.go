@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v3"
+)
+
+type pollResponse struct {
+	Seq      uint64            `json:"seq"`
+	Messages []json.RawMessage `json:"messages"`
+	Close    *struct {
+		Code   int    `json:"code"`
+		Reason string `json:"reason"`
+	} `json:"close"`
+}
+
+func newTestPollServer(t *testing.T) (*Handler, *httptest.Server) {
+	t.Helper()
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), &webrtc.Configuration{}, nil)
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandlePoll))
+	t.Cleanup(srv.Close)
+	return handler, srv
+}
+
+func joinTestPoll(t *testing.T, srvURL, room, name string) string {
+	t.Helper()
+	resp, err := http.Post(srvURL+"/poll?"+url.Values{"room": {room}, "name": {name}}.Encode(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var joined struct{ Token string }
+	if err := json.NewDecoder(resp.Body).Decode(&joined); err != nil || joined.Token == "" {
+		t.Fatalf("join: status %d, %v", resp.StatusCode, err)
+	}
+	return joined.Token
+}
+
+func longPoll(t *testing.T, srvURL, token string, after uint64) pollResponse {
+	t.Helper()
+	resp, err := http.Get(srvURL + "/poll?token=" + token + "&after=" + strconv.FormatUint(after, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var polled pollResponse
+	if err := json.NewDecoder(resp.Body).Decode(&polled); err != nil {
+		t.Fatalf("poll: status %d, %v", resp.StatusCode, err)
+	}
+	return polled
+}
+
+// pollUntilType polls until a message of msgType arrives and returns it with
+// the sequence number to poll after next.
+func pollUntilType(t *testing.T, srvURL, token string, after uint64, msgType string) (map[string]any, uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		polled := longPoll(t, srvURL, token, after)
+		for _, raw := range polled.Messages {
+			var msg map[string]any
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg["type"] == msgType {
+				return msg, polled.Seq
+			}
+		}
+		after = polled.Seq
+	}
+	t.Fatalf("no %q message", msgType)
+	return nil, 0
+}
+
+func TestPollSessionJoinsSendsAndDisconnects(t *testing.T) {
+	handler, srv := newTestPollServer(t)
+	token := joinTestPoll(t, srv.URL, "room-poll", "alice")
+
+	state, after := pollUntilType(t, srv.URL, token, 0, "room_state")
+	peerID, _ := state["self_id"].(string)
+	_, peer := handler.RoomManager.FindPeer(peerID)
+	if peer == nil {
+		t.Fatal("polled peer was not registered")
+	}
+
+	// A poll that does not acknowledge the last response gets it again.
+	if again := longPoll(t, srv.URL, token, 0); again.Seq < after {
+		t.Fatalf("unacknowledged poll seq = %d, want at least %d", again.Seq, after)
+	}
+
+	resp, err := http.Post(srv.URL+"/poll?token="+token, "application/json", strings.NewReader(`{"type":"hello","encoding":"msgpack"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("send: status %d", resp.StatusCode)
+	}
+	ack, after := pollUntilType(t, srv.URL, token, after, "hello_ack")
+	if ack["encoding"] != "json" {
+		t.Fatalf("hello_ack = %v, want JSON kept for a polled session", ack)
+	}
+
+	peer.Disconnect(DisconnectKicked, "bye")
+	var closed *pollResponse
+	for closed == nil {
+		polled := longPoll(t, srv.URL, token, after)
+		if polled.Close != nil {
+			closed = &polled
+		}
+		after = polled.Seq
+	}
+	if closed.Close.Code != websocket.ClosePolicyViolation || closed.Close.Reason != string(DisconnectKicked) {
+		t.Fatalf("close = %+v", closed.Close)
+	}
+}
+
+func TestPollUnknownTokenIsNotFound(t *testing.T) {
+	_, srv := newTestPollServer(t)
+	resp, err := http.Get(srv.URL + "/poll?token=nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestPollEventStreamResumesAfterLastEventID(t *testing.T) {
+	_, srv := newTestPollServer(t)
+	token := joinTestPoll(t, srv.URL, "room-sse", "bob")
+
+	stream := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/poll?token="+token, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, bufio.NewReader(resp.Body)
+	}
+	firstID := func(reader *bufio.Reader) string {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id, ok := strings.CutPrefix(strings.TrimSpace(line), "id: "); ok {
+				return id
+			}
+		}
+	}
+
+	resp, reader := stream("")
+	first := firstID(reader)
+	resp.Body.Close()
+
+	// Without an acknowledgement the same message is streamed again.
+	resp, reader = stream("")
+	if id := firstID(reader); id != first {
+		t.Fatalf("replayed id = %s, want %s", id, first)
+	}
+	resp.Body.Close()
+
+	send, err := http.Post(srv.URL+"/poll?token="+token, "application/json", strings.NewReader(`{"type":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send.Body.Close()
+	resp, reader = stream(first)
+	defer resp.Body.Close()
+	next, _ := strconv.Atoi(firstID(reader))
+	if want, _ := strconv.Atoi(first); next != want+1 {
+		t.Fatalf("resumed id = %d, want %d", next, want+1)
+	}
+}
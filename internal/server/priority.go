@@ -0,0 +1,96 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"sigmartc/internal/logger"
+	"sigmartc/pkg/protocol"
+)
+
+// priorityHold is how long after the priority speaker's last detected speech
+// other publishers stay ducked, so pauses between words do not let them through.
+const priorityHold = time.Second
+
+// PrioritySpeaker is a room's priority speaker. With Duck set, the forwarders
+// of everyone else drop their audio while the priority speaker is talking.
+type PrioritySpeaker = protocol.PrioritySpeaker
+
+// PrioritySpeaker returns the room's priority speaker, or nil.
+func (r *Room) PrioritySpeaker() *PrioritySpeaker {
+	return r.priority.Load()
+}
+
+// setPrioritySpeaker marks peerID as the priority speaker ("" clears it) and
+// tells the room. The peer must be in the room.
+func (r *Room) setPrioritySpeaker(peerID string, duck bool) error {
+	var next *PrioritySpeaker
+	if peerID != "" {
+		r.Lock.RLock()
+		_, inRoom := r.Peers[peerID]
+		r.Lock.RUnlock()
+		if !inRoom {
+			return errPeerNotFound
+		}
+		next = &PrioritySpeaker{PeerID: peerID, Duck: duck}
+	}
+	r.priority.Store(next)
+	r.prioritySpokeAt.Store(0)
+	logger.LogEvent("PRIORITY_SPEAKER", slog.String("uuid", r.UUID), slog.String("peer_id", peerID), slog.Bool("duck", duck))
+	r.broadcastPrioritySpeaker(next)
+	return nil
+}
+
+// dropPrioritySpeaker clears the priority speaker if it is peerID, e.g. on leave.
+func (r *Room) dropPrioritySpeaker(peerID string) {
+	current := r.priority.Load()
+	if current == nil || current.PeerID != peerID {
+		return
+	}
+	if r.priority.CompareAndSwap(current, nil) {
+		r.broadcastPrioritySpeaker(nil)
+	}
+}
+
+func (r *Room) broadcastPrioritySpeaker(p *PrioritySpeaker) {
+	msg := map[string]any{"type": "priority_speaker", "peer_id": "", "duck": false}
+	if p != nil {
+		msg["peer_id"] = p.PeerID
+		msg["duck"] = p.Duck
+	}
+	r.Broadcast("", msg)
+}
+
+// notePrioritySpeech records speech detected on senderID's forwarder when it is
+// the priority speaker.
+func (r *Room) notePrioritySpeech(senderID string) {
+	if p := r.priority.Load(); p != nil && p.PeerID == senderID {
+		r.prioritySpokeAt.Store(time.Now().UnixNano())
+	}
+}
+
+// ducks reports whether senderID's audio is suppressed right now: ducking is on
+// and the priority speaker, someone else, spoke within priorityHold. Speech is
+// detected from the audio level extension, so publishers without it never
+// activate ducking.
+func (r *Room) ducks(senderID string) bool {
+	p := r.priority.Load()
+	if p == nil || !p.Duck || p.PeerID == senderID {
+		return false
+	}
+	spokeAt := r.prioritySpokeAt.Load()
+	return spokeAt != 0 && time.Since(time.Unix(0, spokeAt)) < priorityHold
+}
+
+// handleSetPrioritySpeaker lets hosts choose the priority speaker over signaling.
+func (h *Handler) handleSetPrioritySpeaker(room *Room, peer *Peer, msg map[string]any) {
+	if !peer.Can(PermPrioritySpeaker) {
+		peer.WriteJSON(map[string]string{"type": "error", "message": "set_priority_speaker not allowed"})
+		return
+	}
+	targetID, _ := msg["peer_id"].(string)
+	duck, _ := msg["duck"].(bool)
+	if err := room.setPrioritySpeaker(targetID, duck); err != nil {
+		peer.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPrioritySpeakerDucking(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "priority", "alice")
+	aliceID, _ := readUntilType(t, alice, "room_state")["self_id"].(string)
+	bob := dialTestWS(t, srv.URL, "priority", "bob")
+	bobID, _ := readUntilType(t, bob, "room_state")["self_id"].(string)
+	room := handler.RoomManager.Rooms["priority"]
+
+	// Speakers cannot pick the priority speaker.
+	if err := bob.WriteJSON(map[string]any{"type": "set_priority_speaker", "peer_id": bobID, "duck": true}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	readUntilType(t, bob, "error")
+
+	rec := adminRequest(t, handler, http.MethodPost, "action=priority_speaker&room=priority&duck=1&peer_id="+aliceID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if msg := readUntilType(t, bob, "priority_speaker"); msg["peer_id"] != aliceID || msg["duck"] != true {
+		t.Fatalf("priority_speaker = %v", msg)
+	}
+
+	if room.ducks(bobID) {
+		t.Fatal("bob ducked before alice spoke")
+	}
+	room.notePrioritySpeech(bobID)
+	if room.ducks(aliceID) || room.ducks(bobID) {
+		t.Fatal("only the priority speaker's speech activates ducking")
+	}
+	room.notePrioritySpeech(aliceID)
+	if !room.ducks(bobID) || room.ducks(aliceID) {
+		t.Fatal("expected bob, not alice, to be ducked while alice speaks")
+	}
+	room.prioritySpokeAt.Store(time.Now().Add(-2 * priorityHold).UnixNano())
+	if room.ducks(bobID) {
+		t.Fatal("ducking should end after priorityHold")
+	}
+
+	// The priority speaker leaving clears it.
+	_ = alice.Close()
+	if msg := readUntilType(t, bob, "priority_speaker"); msg["peer_id"] != "" {
+		t.Fatalf("priority_speaker after leave = %v", msg)
+	}
+}
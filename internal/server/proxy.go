@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultTrustedProxies are the networks whose X-Forwarded-* and X-Real-IP
+// headers are believed by default: loopback and private addresses.
+const DefaultTrustedProxies = "127.0.0.0/8,::1/128,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,fc00::/7"
+
+// TrustedProxies lists the reverse proxies allowed to report the client address,
+// host and scheme in forwarding headers. An empty list trusts no one.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs; bare IPs are
+// treated as single-host networks.
+func ParseTrustedProxies(s string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+func mustParseTrustedProxies(s string) TrustedProxies {
+	proxies, err := ParseTrustedProxies(s)
+	if err != nil {
+		panic(err)
+	}
+	return proxies
+}
+
+// Contains reports whether ip belongs to a trusted proxy.
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trusts reports whether r came directly from a trusted proxy.
+func (t TrustedProxies) trusts(r *http.Request) bool {
+	return t.Contains(parseRemoteIP(r.RemoteAddr))
+}
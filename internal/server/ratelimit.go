@@ -0,0 +1,30 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a small token-bucket rate limiter. The zero value starts full.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token if one is available, refilling perSecond tokens per second up to burst.
+func (b *tokenBucket) allow(now time.Time, perSecond, burst float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,32 @@
+package server
+
+import "time"
+
+const (
+	// Each peer may send a burst of reactionBurst reactions, refilled at reactionRate per second.
+	reactionRate  = 1
+	reactionBurst = 5
+)
+
+// allowedReactions are the emoji clients may broadcast; anything else is dropped so the
+// channel cannot be used for free-form chat.
+var allowedReactions = map[string]bool{
+	"👍": true, "👏": true, "😂": true, "❤️": true, "🎉": true, "😮": true, "🙌": true, "🤔": true,
+}
+
+// handleReaction fans a reaction out to the rest of the room, subject to the peer's rate limit.
+func (h *Handler) handleReaction(room *Room, peer *Peer, msg map[string]any) {
+	emoji, _ := msg["emoji"].(string)
+	if !allowedReactions[emoji] {
+		return
+	}
+	if !peer.reactions.allow(time.Now(), reactionRate, reactionBurst) {
+		peer.log().Debug("Reaction rate limited")
+		return
+	}
+	room.Broadcast(peer.ID, map[string]any{
+		"type":    "reaction",
+		"peer_id": peer.ID,
+		"emoji":   emoji,
+	})
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var bucket tokenBucket
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !bucket.allow(now, 1, 3) {
+			t.Fatalf("call %d refused within burst", i)
+		}
+	}
+	if bucket.allow(now, 1, 3) {
+		t.Fatal("expected bucket to be empty")
+	}
+	if !bucket.allow(now.Add(time.Second), 1, 3) {
+		t.Fatal("expected one token after a second")
+	}
+}
+
+func TestReactionsAreBroadcastAndRateLimited(t *testing.T) {
+	_, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "room-react", "alice")
+	state := readUntilType(t, alice, "room_state")
+	aliceID, _ := state["self_id"].(string)
+	bob := dialTestWS(t, srv.URL, "room-react", "bob")
+	readUntilType(t, bob, "room_state")
+
+	if err := alice.WriteJSON(map[string]string{"type": "reaction", "emoji": "not-an-emoji"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	for i := 0; i < reactionBurst+2; i++ {
+		if err := alice.WriteJSON(map[string]string{"type": "reaction", "emoji": "👏"}); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	received := 0
+	_ = bob.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	for {
+		var msg map[string]any
+		if err := bob.ReadJSON(&msg); err != nil {
+			break
+		}
+		if msg["type"] != "reaction" {
+			continue
+		}
+		if msg["peer_id"] != aliceID || msg["emoji"] != "👏" {
+			t.Fatalf("unexpected reaction %v", msg)
+		}
+		received++
+	}
+	if received != reactionBurst {
+		t.Fatalf("received %d reactions, want %d", received, reactionBurst)
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// AddressFamilies returns "ipv4" and/or "ipv6" for the families among addrs,
+// in that order.
+func AddressFamilies(addrs []net.Addr) []string {
+	var ipv4, ipv6 bool
+	for _, addr := range addrs {
+		udp, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		if udp.IP.To4() != nil {
+			ipv4 = true
+		} else {
+			ipv6 = true
+		}
+	}
+	families := []string{}
+	if ipv4 {
+		families = append(families, "ipv4")
+	}
+	if ipv6 {
+		families = append(families, "ipv6")
+	}
+	return families
+}
+
+// HandleReadyz reports whether the server can carry calls: 200 with the ICE
+// addresses and their families, or 503 when media has nowhere to go. A load
+// balancer can keep IPv6-only clients away from a node without "ipv6".
+func (h *Handler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	addresses := make([]string, 0, len(h.ICEAddresses))
+	for _, addr := range h.ICEAddresses {
+		addresses = append(addresses, addr.String())
+	}
+	status := "ready"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if len(addresses) == 0 {
+		status = "no ICE addresses"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": status,
+		"ice": map[string]any{
+			"families":  AddressFamilies(h.ICEAddresses),
+			"addresses": addresses,
+		},
+	})
+}
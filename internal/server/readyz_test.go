@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReadyzReportsICEAddressFamilies(t *testing.T) {
+	h := newTestAdminHandler(t)
+	get := func() (int, map[string]any) {
+		rec := httptest.NewRecorder()
+		h.HandleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, body
+	}
+	if code, _ := get(); code != http.StatusServiceUnavailable {
+		t.Fatalf("without ICE addresses: status = %d, want 503", code)
+	}
+
+	h.ICEAddresses = []net.Addr{
+		&net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000},
+		&net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 50000},
+	}
+	code, body := get()
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	ice, _ := body["ice"].(map[string]any)
+	if got := ice["families"]; !reflect.DeepEqual(got, []any{"ipv4", "ipv6"}) {
+		t.Fatalf("families = %v", got)
+	}
+	if got := ice["addresses"]; !reflect.DeepEqual(got, []any{"192.0.2.1:50000", "[2001:db8::1]:50000"}) {
+		t.Fatalf("addresses = %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// panicsRecovered counts panics caught by the guards below; admin stats report it
+// as panics_recovered.
+var panicsRecovered atomic.Uint64
+
+// logPanic counts a recovered panic and logs it at ERROR, with its stack, as a
+// PANIC_RECOVERED event.
+func logPanic(log *slog.Logger, where string, v any, attrs ...any) {
+	panicsRecovered.Add(1)
+	attrs = append([]any{"event", "PANIC_RECOVERED", "where", where, "panic", fmt.Sprint(v), "stack", string(debug.Stack())}, attrs...)
+	log.Error("Panic recovered", attrs...)
+}
+
+// recoverGoroutine is deferred by server-wide goroutines so a panic is logged
+// instead of killing the process.
+func recoverGoroutine(where string) {
+	if v := recover(); v != nil {
+		logPanic(slog.Default(), where, v)
+	}
+}
+
+// recoverPanic is deferred by a peer's goroutines. The peer is disconnected
+// because its state can no longer be trusted; other peers are unaffected.
+func (p *Peer) recoverPanic(where string) {
+	if v := recover(); v != nil {
+		logPanic(p.log(), where, v)
+		go p.Disconnect(DisconnectServerError, "Internal server error")
+	}
+}
+
+// recoverPanic is deferred by Start: the forwarder stops as if its source failed,
+// which its owner already handles.
+func (f *TrackForwarder) recoverPanic() {
+	if v := recover(); v != nil {
+		logPanic(f.log(), "forwarder", v)
+		f.stopWithError(fmt.Errorf("panic: %v", v))
+	}
+}
+
+// RecoverHTTP answers 500 when a handler panics and logs it like the goroutine
+// guards. http.ErrAbortHandler is re-raised so net/http can abort the response.
+func RecoverHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				logPanic(slog.Default(), "http", v, "method", r.Method, "path", r.URL.Path)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
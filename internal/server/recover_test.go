@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestRecoverHTTPAnswers500(t *testing.T) {
+	before := panicsRecovered.Load()
+	handler := RecoverHTTP(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/rooms", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if got := panicsRecovered.Load() - before; got != 1 {
+		t.Fatalf("panicsRecovered grew by %d, want 1", got)
+	}
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler re-raised", v)
+		}
+	}()
+	RecoverHTTP(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestForwarderPanicStopsForwarder(t *testing.T) {
+	f := newSyntheticForwarder(context.Background(), "sender", webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus})
+	f.read = func([]byte) (int, error) { panic("bad packet") }
+	var stopErr error
+	f.onStop = func(err error) { stopErr = err }
+
+	f.Start()
+	select {
+	case <-f.ctx.Done():
+	default:
+		t.Fatal("forwarder still running after panic")
+	}
+	if stopErr == nil || !strings.Contains(stopErr.Error(), "bad packet") {
+		t.Fatalf("onStop err = %v", stopErr)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pion/rtp"
+
+	"sigmartc/internal/egress"
+	"sigmartc/internal/soundboard"
+)
+
+// reencodeStage runs packets through an ffmpeg re-encoder (server-side gain,
+// loudness leveling) and hands the results to out as RTP with their own
+// sequence numbers and timestamps. It closes when ctx is done.
+type reencodeStage struct {
+	reencoder *egress.Reencoder
+	done      chan struct{}
+	closeOnce sync.Once
+	// Owned by the reencoder's output goroutine.
+	seq uint16
+	ts  uint32
+}
+
+func newReencodeStage(ctx context.Context, start func(onPacket func(soundboard.Packet)) (*egress.Reencoder, error), out func(*rtp.Packet)) (*reencodeStage, error) {
+	s := &reencodeStage{done: make(chan struct{})}
+	reencoder, err := start(func(packet soundboard.Packet) {
+		s.seq++
+		out(&rtp.Packet{
+			Header:  rtp.Header{Version: 2, SequenceNumber: s.seq, Timestamp: s.ts},
+			Payload: packet.Data,
+		})
+		s.ts += packet.Samples
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.reencoder = reencoder
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.close()
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// feed queues a raw RTP packet for re-encoding.
+func (s *reencodeStage) feed(packet []byte) {
+	var parsed rtp.Packet
+	if err := parsed.Unmarshal(packet); err != nil {
+		return
+	}
+	s.reencoder.WriteRTP(&parsed)
+}
+
+func (s *reencodeStage) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.reencoder.Close()
+	})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Relay-only rooms: with Handler.ForceRelay, or per room through
+// action=room_relay, the server's PeerConnections use ICETransportPolicyRelay
+// and room_state tells clients to set iceTransportPolicy to relay too. Media
+// then only flows through TURN, so neither side learns the other's addresses
+// from a working candidate pair. pion fixes the policy when it creates a
+// connection, so the setting applies from the next join.
+
+var (
+	errRelayNeedsTURN = errors.New("relay-only needs a TURN server (-turn-server)")
+	errRelayRequired  = errors.New("target room is relay-only and the peer's connection is not")
+)
+
+// relayOnly reports whether peers joining room must connect through TURN.
+func (h *Handler) relayOnly(room *Room) bool {
+	return h.ForceRelay || (room != nil && room.forceRelay.Load())
+}
+
+// hasTURN reports whether the server's ICE configuration can gather relay candidates.
+func (h *Handler) hasTURN() bool {
+	config := h.iceConfig()
+	return config != nil && hasTURNServer(config.ICEServers)
+}
+
+// usesRelay reports whether the peer's connection was created relay-only.
+func (p *Peer) usesRelay() bool {
+	return p.PC != nil && p.PC.GetConfiguration().ICETransportPolicy == webrtc.ICETransportPolicyRelay
+}
+
+// ForceRelay reports whether the room was made relay-only with SetForceRelay.
+func (r *Room) ForceRelay() bool {
+	return r.forceRelay.Load()
+}
+
+// SetForceRelay makes a room relay-only or lifts it, creating the room if
+// needed so the policy is in place before the first peer connects. Rooms with
+// peers are refused: their connections were created under the old policy.
+func (h *Handler) SetForceRelay(uuid string, enabled bool) error {
+	if uuid == "" {
+		return errRoomNotFound
+	}
+	if enabled && !h.hasTURN() {
+		return errRelayNeedsTURN
+	}
+	room := h.RoomManager.GetOrCreateRoom(uuid)
+	room.Lock.Lock()
+	defer room.Lock.Unlock()
+	if len(room.Peers) > 0 && room.forceRelay.Load() != enabled {
+		return errRoomOccupied
+	}
+	room.forceRelay.Store(enabled)
+	return nil
+}
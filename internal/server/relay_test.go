@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func testTURNConfig() *webrtc.Configuration {
+	return &webrtc.Configuration{ICEServers: []webrtc.ICEServer{{
+		URLs:       []string{"turn:turn.example.com:3478"},
+		Username:   "user",
+		Credential: "pass",
+	}}}
+}
+
+func TestAdminRoomRelayNeedsTURN(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	post := func(query string) int {
+		return adminRequest(t, handler, http.MethodPost, "action=room_relay&"+query).Code
+	}
+	if code := post("room=room-a&enabled=1"); code != http.StatusConflict {
+		t.Fatalf("without TURN: status = %d, want 409", code)
+	}
+	handler.ICEConfig = testTURNConfig()
+	if code := post("room=room-a&enabled=1"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if !handler.RoomManager.Rooms["room-a"].ForceRelay() {
+		t.Fatal("expected room to be relay-only")
+	}
+}
+
+func TestRelayOnlyRoomConfiguresPeersAndClients(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	handler.ICEConfig = testTURNConfig()
+	if err := handler.SetForceRelay("relayed", true); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := dialTestWS(t, srv.URL, "relayed", "alice")
+	state := readUntilType(t, alice, "room_state")
+	if state["relay_only"] != true {
+		t.Fatalf("relay_only = %v, want true", state["relay_only"])
+	}
+	aliceID, _ := state["self_id"].(string)
+	bob := dialTestWS(t, srv.URL, "open", "bob")
+	state = readUntilType(t, bob, "room_state")
+	if state["relay_only"] != false {
+		t.Fatalf("relay_only = %v in an open room", state["relay_only"])
+	}
+	bobID, _ := state["self_id"].(string)
+
+	_, peer := handler.RoomManager.FindPeer(aliceID)
+	if peer == nil || !peer.usesRelay() {
+		t.Fatal("relay-only peer connection does not use ICETransportPolicyRelay")
+	}
+	if err := handler.SetForceRelay("relayed", false); err != errRoomOccupied {
+		t.Fatalf("err = %v, want errRoomOccupied", err)
+	}
+	if err := handler.MovePeer(bobID, "relayed"); err != errRelayRequired {
+		t.Fatalf("move into relay-only room: err = %v, want errRelayRequired", err)
+	}
+	if err := handler.MovePeer(aliceID, "open"); err != nil {
+		t.Fatalf("move out of relay-only room: %v", err)
+	}
+	if state := readUntilType(t, alice, "room_state"); state["relay_only"] != true {
+		t.Fatalf("relay_only = %v after moving a relayed peer", state["relay_only"])
+	}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+	"sigmartc/internal/logger"
+)
+
+// Reloading (SIGHUP or admin action=reload) applies new Settings and re-reads
+// the ban list while the server runs: joins after it see the new values, and
+// connected peers stay connected unless their IP was newly banned.
+
+// Settings are the options a reload can change without a restart.
+type Settings struct {
+	LogLevel          string
+	MaxPeersPerIP     int
+	MaxPublishBitrate int
+	// ICEServers replace ICEConfig's servers; its certificates and the rest of
+	// the configuration are kept. Peers connected before keep the old servers.
+	ICEServers []webrtc.ICEServer
+	// NicknameFilter replaces the nickname deny-list; nil allows any name.
+	NicknameFilter *NicknameFilter
+	// GeoAllow and GeoDeny replace the GeoIP country lists, which need a GeoIP
+	// database.
+	GeoAllow map[string]bool
+	GeoDeny  map[string]bool
+}
+
+// SettingChange is one line of a reload's diff. Credentials never appear in it.
+type SettingChange struct {
+	Setting string `json:"setting"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// Settings returns the current reloadable settings.
+func (h *Handler) Settings() Settings {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	s := Settings{
+		LogLevel:          logger.Level(),
+		MaxPeersPerIP:     h.MaxPeersPerIP,
+		MaxPublishBitrate: h.MaxPublishBitrate,
+		NicknameFilter:    h.NicknameFilter,
+	}
+	if h.ICEConfig != nil {
+		s.ICEServers = h.ICEConfig.ICEServers
+	}
+	if h.GeoIP != nil {
+		s.GeoAllow, s.GeoDeny = h.GeoIP.Allow, h.GeoIP.Deny
+	}
+	return s
+}
+
+// Reload re-reads the settings with LoadSettings (the current ones when it is
+// nil), applies them and reloads the ban list. It logs and returns what changed.
+func (h *Handler) Reload() ([]SettingChange, error) {
+	s := h.Settings()
+	if h.LoadSettings != nil {
+		var err error
+		if s, err = h.LoadSettings(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := h.ApplySettings(s)
+	if err != nil {
+		return nil, err
+	}
+	added, removed, err := h.RoomManager.ReloadBanList()
+	if err != nil {
+		return changes, fmt.Errorf("ban list: %w", err)
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		change := SettingChange{Setting: "ban_list", From: fmt.Sprintf("-%d", len(removed)), To: fmt.Sprintf("+%d", len(added))}
+		slog.Info("Setting reloaded", "setting", change.Setting, "banned", added, "unbanned", removed)
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		slog.Info("Settings reloaded, nothing changed")
+	}
+	return changes, nil
+}
+
+// ApplySettings replaces the reloadable settings, refusing s as a whole when
+// any of it is invalid here. It logs and returns what changed.
+func (h *Handler) ApplySettings(s Settings) ([]SettingChange, error) {
+	level, err := logger.ParseLevel(s.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+	if h.ForceRelay && !hasTURNServer(s.ICEServers) {
+		return nil, errors.New("relay-only mode needs a TURN server")
+	}
+
+	h.settingsMu.Lock()
+	if h.GeoIP == nil && (len(s.GeoAllow) > 0 || len(s.GeoDeny) > 0) {
+		h.settingsMu.Unlock()
+		return nil, errors.New("country lists need a GeoIP database")
+	}
+	var changes []SettingChange
+	diff := func(setting, from, to string) {
+		if from != to {
+			changes = append(changes, SettingChange{Setting: setting, From: from, To: to})
+		}
+	}
+	diff("log_level", logger.Level(), strings.ToLower(level.String()))
+	diff("max_peers_per_ip", strconv.Itoa(h.MaxPeersPerIP), strconv.Itoa(s.MaxPeersPerIP))
+	diff("max_publish_bitrate", strconv.Itoa(h.MaxPublishBitrate), strconv.Itoa(s.MaxPublishBitrate))
+	var servers []webrtc.ICEServer
+	if h.ICEConfig != nil {
+		servers = h.ICEConfig.ICEServers
+	}
+	diff("ice_servers", describeICEServers(servers), describeICEServers(s.ICEServers))
+	if !slices.Equal(iceCredentials(servers), iceCredentials(s.ICEServers)) {
+		changes = append(changes, SettingChange{Setting: "ice_credentials", From: "(hidden)", To: "(changed)"})
+	}
+	diff("nickname_filter", h.NicknameFilter.describe(), s.NicknameFilter.describe())
+	if h.GeoIP != nil {
+		diff("geoip_allow", describeCountries(h.GeoIP.Allow), describeCountries(s.GeoAllow))
+		diff("geoip_deny", describeCountries(h.GeoIP.Deny), describeCountries(s.GeoDeny))
+	}
+
+	logger.SetLevel(s.LogLevel)
+	h.MaxPeersPerIP = s.MaxPeersPerIP
+	h.MaxPublishBitrate = s.MaxPublishBitrate
+	if h.ICEConfig != nil || s.ICEServers != nil {
+		config := webrtc.Configuration{}
+		if h.ICEConfig != nil {
+			config = *h.ICEConfig
+		}
+		config.ICEServers = s.ICEServers
+		h.ICEConfig = &config
+	}
+	h.NicknameFilter = s.NicknameFilter
+	if h.GeoIP != nil {
+		h.GeoIP = &GeoIP{db: h.GeoIP.db, Allow: s.GeoAllow, Deny: s.GeoDeny}
+	}
+	h.settingsMu.Unlock()
+
+	for _, change := range changes {
+		slog.Info("Setting reloaded", "setting", change.Setting, "from", change.From, "to", change.To)
+	}
+	return changes, nil
+}
+
+// The reloadable Handler fields are read through these while serving.
+
+func (h *Handler) iceConfig() *webrtc.Configuration {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.ICEConfig
+}
+
+func (h *Handler) peersPerIPLimit() int {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.MaxPeersPerIP
+}
+
+func (h *Handler) publishBitrateKbps() int {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.MaxPublishBitrate
+}
+
+func (h *Handler) nicknameFilter() *NicknameFilter {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.NicknameFilter
+}
+
+func (h *Handler) geoIP() *GeoIP {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.GeoIP
+}
+
+// describeICEServers lists the servers' URLs, prefixed with their username
+// when they have one.
+func describeICEServers(servers []webrtc.ICEServer) string {
+	parts := make([]string, 0, len(servers))
+	for _, server := range servers {
+		part := strings.Join(server.URLs, ",")
+		if server.Username != "" {
+			part = server.Username + "@" + part
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// iceCredentials returns the servers' credentials, which diffs only report as
+// changed.
+func iceCredentials(servers []webrtc.ICEServer) []string {
+	credentials := make([]string, len(servers))
+	for i, server := range servers {
+		credentials[i] = fmt.Sprint(server.Credential)
+	}
+	return credentials
+}
+
+func describeCountries(countries map[string]bool) string {
+	return strings.Join(slices.Sorted(maps.Keys(countries)), ",")
+}
+
+// hasTURNServer reports whether servers include a TURN server.
+func hasTURNServer(servers []webrtc.ICEServer) bool {
+	for _, server := range servers {
+		for _, url := range server.URLs {
+			if strings.HasPrefix(url, "turn:") || strings.HasPrefix(url, "turns:") {
+				return true
+			}
+		}
+	}
+	return false
+}
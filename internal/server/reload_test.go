@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestReloadAppliesSettingsAndBanList(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "lobby", "alice")
+	readUntilType(t, alice, "room_state")
+
+	settings := handler.Settings()
+	settings.MaxPeersPerIP = 1
+	settings.ICEServers = []webrtc.ICEServer{{URLs: []string{"turn:relay.example.com:3478"}, Username: "u", Credential: "secret"}}
+	handler.LoadSettings = func() (Settings, error) { return settings, nil }
+	if err := os.WriteFile(handler.RoomManager.BanListPath, []byte(`{"127.0.0.1": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := adminRequest(t, handler, http.MethodPost, "action=reload")
+	var body struct {
+		Changes []SettingChange `json:"changes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	got := make(map[string]SettingChange)
+	for _, change := range body.Changes {
+		got[change.Setting] = change
+	}
+	if got["max_peers_per_ip"].To != "1" || got["ice_servers"].To != "u@turn:relay.example.com:3478" || got["ban_list"].To != "+1" {
+		t.Fatalf("changes = %+v", body.Changes)
+	}
+	if change, ok := got["ice_credentials"]; !ok || change.To == "secret" {
+		t.Fatalf("ice_credentials change = %+v", change)
+	}
+	if msg := readUntilType(t, alice, "disconnect"); msg["reason"] != string(DisconnectBanned) {
+		t.Fatalf("disconnect = %v", msg)
+	}
+	if handler.peersPerIPLimit() != 1 || !handler.hasTURN() {
+		t.Fatal("settings not applied")
+	}
+
+	// Reloading again changes nothing.
+	if changes, err := handler.Reload(); err != nil || len(changes) != 0 {
+		t.Fatalf("second reload = %+v, %v", changes, err)
+	}
+}
+
+func TestApplySettingsRefusesInvalid(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	handler.MaxPeersPerIP = 2
+	for _, s := range []Settings{
+		{LogLevel: "loud", MaxPeersPerIP: 5},
+		{LogLevel: "info", MaxPeersPerIP: 5, GeoDeny: ParseCountryList("US")},
+	} {
+		if _, err := handler.ApplySettings(s); err == nil {
+			t.Fatalf("%+v: expected an error", s)
+		}
+	}
+	if handler.MaxPeersPerIP != 2 {
+		t.Fatalf("refused settings were partly applied: MaxPeersPerIP = %d", handler.MaxPeersPerIP)
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+// requestIDHeader carries a request's ID; a trusted proxy may set it to have its
+// own ID used, and responses echo it.
+const requestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+type requestIDKey struct{}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID of r: the one AccessLog assigned, else a valid
+// X-Request-ID from a trusted proxy, else a new one. A WebSocket join keeps it as
+// the peer's SessionID.
+func (h *Handler) requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	if id := r.Header.Get(requestIDHeader); requestIDPattern.MatchString(id) && h.TrustedProxies.Contains(parseRemoteIP(r.RemoteAddr)) {
+		return id
+	}
+	return newRequestID()
+}
+
+func (h *Handler) withRequestID(r *http.Request) (*http.Request, string) {
+	id := h.requestID(r)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)), id
+}
+
+// log returns a logger that tags lines with the peer's ID and session ID, so one
+// session's signaling, negotiation and forwarding lines can be found together.
+func (p *Peer) log() *slog.Logger {
+	if p.logger != nil {
+		return p.logger
+	}
+	return slog.With("peer_id", p.ID)
+}
+
+// log returns the logger of the forwarder's publisher, or one tagged with the
+// sender ID for synthetic sources.
+func (f *TrackForwarder) log() *slog.Logger {
+	if f.logger != nil {
+		return f.logger
+	}
+	return slog.With("sender_id", f.SenderID)
+}
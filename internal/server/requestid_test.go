@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSessionIDFromUpgrade(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	wsURL, err := buildWSURL(srv.URL, "session", "alice")
+	if err != nil {
+		t.Fatalf("failed to build ws url: %v", err)
+	}
+
+	dial := func(header http.Header) (string, *Peer) {
+		t.Helper()
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		selfID, _ := readUntilType(t, conn, "room_state")["self_id"].(string)
+		_, peer := handler.RoomManager.FindPeer(selfID)
+		return resp.Header.Get(requestIDHeader), peer
+	}
+
+	id, peer := dial(nil)
+	if !requestIDPattern.MatchString(id) || peer.SessionID != id {
+		t.Fatalf("session ID %q, response header %q", peer.SessionID, id)
+	}
+	if peer.Diagnostics().SessionID != id {
+		t.Fatal("expected diagnostics to report the session ID")
+	}
+
+	// The test client connects over loopback, which is a trusted proxy by default.
+	id, peer = dial(http.Header{requestIDHeader: {"proxy-request-1234"}})
+	if id != "proxy-request-1234" || peer.SessionID != id {
+		t.Fatalf("expected the proxy's request ID, got %q / %q", id, peer.SessionID)
+	}
+	id, _ = dial(http.Header{requestIDHeader: {"bad id!"}})
+	if id == "bad id!" {
+		t.Fatal("expected an invalid request ID to be replaced")
+	}
+}
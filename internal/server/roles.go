@@ -0,0 +1,94 @@
+package server
+
+import (
+	"slices"
+	"strings"
+)
+
+// Role is what a peer may do in its room.
+type Role string
+
+const (
+	// RoleHost runs the room and may do everything.
+	RoleHost Role = "host"
+	// RoleModerator keeps order: kick, lock and manage hands, but not edit the room.
+	RoleModerator Role = "moderator"
+	// RoleSpeaker is the default: publish audio and take part.
+	RoleSpeaker Role = "speaker"
+	// RoleListener only receives audio; published tracks are not forwarded.
+	RoleListener Role = "listener"
+)
+
+// Permission is an action gated by role. Signaling handlers check
+// Peer.Can instead of testing roles directly.
+type Permission string
+
+const (
+	PermSpeak       Permission = "speak"
+	PermEnterClosed Permission = "enter_closed" // join before the schedule starts or while locked
+	PermManageHands Permission = "manage_hands" // call_next, lower others' hands
+	PermMovePeers   Permission = "move_peers"
+	PermEditRoom    Permission = "edit_room"
+	PermMuteOthers  Permission = "mute_others"
+	PermKick        Permission = "kick"
+	PermLock        Permission = "lock"
+	PermRecord      Permission = "record"
+	// PermPrioritySpeaker chooses the room's priority speaker.
+	PermPrioritySpeaker Permission = "priority_speaker"
+	// PermDenoise toggles server-side noise suppression for others.
+	PermDenoise Permission = "denoise"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleHost:      {PermSpeak, PermEnterClosed, PermManageHands, PermMovePeers, PermEditRoom, PermMuteOthers, PermKick, PermLock, PermRecord, PermPrioritySpeaker, PermDenoise},
+	RoleModerator: {PermSpeak, PermManageHands, PermMuteOthers, PermKick, PermLock, PermDenoise},
+	RoleSpeaker:   {PermSpeak},
+	RoleListener:  {},
+}
+
+// ParseRole validates a role name, e.g. from a JWT role claim.
+func ParseRole(s string) (Role, bool) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	_, ok := rolePermissions[role]
+	return role, ok
+}
+
+// Can reports whether the role grants perm.
+func (r Role) Can(perm Permission) bool {
+	return slices.Contains(rolePermissions[r], perm)
+}
+
+// Can reports whether the peer's role grants perm.
+func (p *Peer) Can(perm Permission) bool {
+	return p.Role.Can(perm)
+}
+
+// joinRole picks a joining peer's role: the schedule's host token makes a host,
+// otherwise a valid role claim from the identity provider applies, and everyone
+// else is a speaker.
+func joinRole(host bool, identity *Identity) Role {
+	if host {
+		return RoleHost
+	}
+	if identity != nil {
+		if role, ok := ParseRole(identity.Role); ok {
+			return role
+		}
+	}
+	return RoleSpeaker
+}
+
+// handlePeerKick lets hosts and moderators remove a member of their own room.
+// Hosts cannot be kicked over signaling.
+func (h *Handler) handlePeerKick(room *Room, peer *Peer, msg map[string]any) {
+	targetID, _ := msg["peer_id"].(string)
+	room.Lock.RLock()
+	target := room.Peers[targetID]
+	room.Lock.RUnlock()
+	if !peer.Can(PermKick) || target == nil || target == peer || target.Role == RoleHost {
+		peer.WriteJSON(map[string]string{"type": "error", "message": "kick not allowed"})
+		return
+	}
+	target.log().Info("Peer kicked by peer", "uuid", room.UUID, "by", peer.ID, "role", peer.Role)
+	target.Disconnect(DisconnectKicked, "You were removed by a "+string(peer.Role))
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+func TestRolePermissions(t *testing.T) {
+	if !RoleHost.Can(PermEditRoom) || RoleModerator.Can(PermEditRoom) {
+		t.Fatal("only hosts may edit the room")
+	}
+	if !RoleModerator.Can(PermKick) || RoleSpeaker.Can(PermKick) {
+		t.Fatal("moderators but not speakers may kick")
+	}
+	if !RoleSpeaker.Can(PermSpeak) || RoleListener.Can(PermSpeak) {
+		t.Fatal("listeners may not speak")
+	}
+	if Role("").Can(PermSpeak) {
+		t.Fatal("an unknown role has no permissions")
+	}
+
+	if role, ok := ParseRole(" Moderator "); !ok || role != RoleModerator {
+		t.Fatalf("ParseRole = %q, %v", role, ok)
+	}
+	if _, ok := ParseRole("admin"); ok {
+		t.Fatal("expected unknown role to be rejected")
+	}
+
+	cases := []struct {
+		host     bool
+		identity *Identity
+		want     Role
+	}{
+		{false, nil, RoleSpeaker},
+		{true, &Identity{Role: "listener"}, RoleHost},
+		{false, &Identity{Role: "listener"}, RoleListener},
+		{false, &Identity{Role: "superuser"}, RoleSpeaker},
+	}
+	for _, c := range cases {
+		if got := joinRole(c.host, c.identity); got != c.want {
+			t.Errorf("joinRole(%v, %+v) = %q, want %q", c.host, c.identity, got, c.want)
+		}
+	}
+}
+
+func TestModeratorKickAndLock(t *testing.T) {
+	key, jwks := newTestJWKS(t)
+	handler, srv := newTestWSServer(t)
+	handler.JWTAuth = NewJWTAuth(jwks.URL, "", "")
+	dial := func(sub, role string) (*websocket.Conn, map[string]any) {
+		token := signTestToken(t, key, "k1", jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix(), "sub": sub, "role": role})
+		wsURL, _ := buildWSURL(srv.URL, "room-roles", sub)
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+"&token="+token, nil)
+		if err != nil {
+			t.Fatalf("dial %s: %v", sub, err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn, readUntilType(t, conn, "room_state")
+	}
+
+	mod, state := dial("mod", "moderator")
+	if state["role"] != string(RoleModerator) {
+		t.Fatalf("room_state role = %v", state["role"])
+	}
+	modID, _ := state["self_id"].(string)
+	speaker, state := dial("speaker", "")
+	speakerID, _ := state["self_id"].(string)
+
+	// Speakers cannot kick.
+	if err := speaker.WriteJSON(map[string]string{"type": "kick", "peer_id": modID}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	readUntilType(t, speaker, "error")
+
+	if err := mod.WriteJSON(map[string]string{"type": "lock_room"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := mod.WriteJSON(map[string]string{"type": "kick", "peer_id": speakerID}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if msg := readUntilType(t, speaker, "disconnect"); msg["reason"] != string(DisconnectKicked) {
+		t.Fatalf("disconnect = %v", msg)
+	}
+
+	// lock_room was handled before kick, so the room now refuses new joins.
+	token := signTestToken(t, key, "k1", jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix(), "sub": "late"})
+	rec := httptest.NewRecorder()
+	handler.HandleWS(rec, httptest.NewRequest(http.MethodGet, "/ws?room=room-roles&name=late&token="+token, nil))
+	if rec.Code != http.StatusLocked {
+		t.Fatalf("join after lock: status = %d, want %d", rec.Code, http.StatusLocked)
+	}
+}
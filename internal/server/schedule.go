@@ -0,0 +1,119 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"sigmartc/internal/logger"
+)
+
+// expiryWarnings are the remaining times at which members of a scheduled room are
+// warned that it is about to close, largest first.
+var expiryWarnings = []time.Duration{5 * time.Minute, time.Minute}
+
+var errInvalidSchedule = errors.New("end must be in the future and after start")
+
+// Schedule is a room's scheduled window. The zero value means the room is unscheduled.
+type Schedule struct {
+	StartsAt  time.Time
+	EndsAt    time.Time
+	HostToken string
+
+	// warned is the smallest expiry warning already sent.
+	warned time.Duration
+}
+
+// started reports whether non-host peers may join at now.
+func (s *Schedule) started(now time.Time) bool {
+	return s.StartsAt.IsZero() || !now.Before(s.StartsAt)
+}
+
+// ended reports whether the room's hard end time has passed.
+func (s *Schedule) ended(now time.Time) bool {
+	return !s.EndsAt.IsZero() && !now.Before(s.EndsAt)
+}
+
+// isHost reports whether token matches the room's host token.
+func (s *Schedule) isHost(token string) bool {
+	return token != "" && s.HostToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.HostToken)) == 1
+}
+
+// ScheduleRoom creates (or reschedules) a room that opens at startsAt and closes at
+// endsAt, and returns the token the host uses to enter before it opens. A zero
+// startsAt opens the room immediately.
+func (rm *RoomManager) ScheduleRoom(uuid string, startsAt, endsAt time.Time) (string, error) {
+	if !endsAt.After(time.Now()) || (!startsAt.IsZero() && !endsAt.After(startsAt)) {
+		return "", errInvalidSchedule
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	room := rm.GetOrCreateRoom(uuid)
+	room.Lock.Lock()
+	room.Schedule = Schedule{StartsAt: startsAt, EndsAt: endsAt, HostToken: token}
+	room.Lock.Unlock()
+
+	logger.LogEvent("ROOM_SCHEDULE", slog.String("uuid", uuid), slog.Time("starts_at", startsAt), slog.Time("ends_at", endsAt))
+	return token, nil
+}
+
+func (rm *RoomManager) startScheduleTicker() {
+	ticker := time.NewTicker(time.Second)
+	for now := range ticker.C {
+		func() {
+			defer recoverGoroutine("schedule")
+			rm.enforceSchedules(now)
+		}()
+	}
+}
+
+// enforceSchedules warns members of rooms nearing their end time and closes rooms
+// whose end time has passed.
+func (rm *RoomManager) enforceSchedules(now time.Time) {
+	rm.Lock.RLock()
+	rooms := make([]*Room, 0, len(rm.Rooms))
+	for _, room := range rm.Rooms {
+		rooms = append(rooms, room)
+	}
+	rm.Lock.RUnlock()
+
+	for _, room := range rooms {
+		room.Lock.Lock()
+		schedule := &room.Schedule
+		if schedule.EndsAt.IsZero() {
+			room.Lock.Unlock()
+			continue
+		}
+		if schedule.ended(now) {
+			room.Lock.Unlock()
+			rm.CloseRoom(room.UUID, DisconnectRoomExpired, "The scheduled room has ended")
+			continue
+		}
+		remaining := schedule.EndsAt.Sub(now)
+		warn := false
+		for _, threshold := range expiryWarnings {
+			if remaining <= threshold && (schedule.warned == 0 || threshold < schedule.warned) {
+				schedule.warned = threshold
+				warn = true
+			}
+		}
+		endsAt := schedule.EndsAt
+		room.Lock.Unlock()
+
+		if warn {
+			logger.LogEvent("ROOM_EXPIRING", slog.String("uuid", room.UUID), slog.Int("seconds_left", int(remaining.Seconds())))
+			room.Broadcast("", map[string]any{
+				"type":         "room_expiring",
+				"ends_at":      endsAt.UTC().Format(time.RFC3339),
+				"seconds_left": int(remaining.Seconds()),
+			})
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestScheduledRoomAdmitsOnlyHostBeforeStart(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	start := time.Now().Add(time.Hour)
+	token, err := handler.RoomManager.ScheduleRoom("room-sched", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ScheduleRoom failed: %v", err)
+	}
+
+	wsURL, err := buildWSURL(srv.URL, "room-sched", "guest")
+	if err != nil {
+		t.Fatalf("failed to build ws url: %v", err)
+	}
+	guest, _, err := websocket.DefaultDialer.Dial(wsURL+"&join_errors=ws", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer guest.Close()
+	msg := readUntilType(t, guest, "disconnect")
+	if msg["reason"] != string(DisconnectNotStarted) {
+		t.Fatalf("reason = %v, want %q", msg["reason"], DisconnectNotStarted)
+	}
+
+	wsURL, err = buildWSURL(srv.URL, "room-sched", "host")
+	if err != nil {
+		t.Fatalf("failed to build ws url: %v", err)
+	}
+	host, _, err := websocket.DefaultDialer.Dial(wsURL+"&host_token="+url.QueryEscape(token), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer host.Close()
+	state := readUntilType(t, host, "room_state")
+	peerID, _ := state["self_id"].(string)
+	if _, peer := handler.RoomManager.FindPeer(peerID); peer == nil || peer.Role != RoleHost {
+		t.Fatal("expected host peer to be admitted")
+	}
+}
+
+func TestScheduledRoomWarnsThenCloses(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	end := time.Now().Add(time.Hour)
+	if _, err := handler.RoomManager.ScheduleRoom("room-end", time.Time{}, end); err != nil {
+		t.Fatalf("ScheduleRoom failed: %v", err)
+	}
+	conn := dialTestWS(t, srv.URL, "room-end", "alice")
+	readUntilType(t, conn, "room_state")
+
+	handler.RoomManager.enforceSchedules(end.Add(-4 * time.Minute))
+	msg := readUntilType(t, conn, "room_expiring")
+	if msg["seconds_left"] != float64(240) {
+		t.Fatalf("seconds_left = %v, want 240", msg["seconds_left"])
+	}
+
+	handler.RoomManager.enforceSchedules(end)
+	msg = readUntilType(t, conn, "disconnect")
+	if msg["reason"] != string(DisconnectRoomExpired) {
+		t.Fatalf("reason = %v, want %q", msg["reason"], DisconnectRoomExpired)
+	}
+	handler.RoomManager.Lock.RLock()
+	_, exists := handler.RoomManager.Rooms["room-end"]
+	handler.RoomManager.Lock.RUnlock()
+	if exists {
+		t.Fatal("expected expired room to be removed")
+	}
+}
+
+func TestScheduleRoomRejectsPastEnd(t *testing.T) {
+	rm := NewRoomManager("", "")
+	if _, err := rm.ScheduleRoom("room", time.Time{}, time.Now().Add(-time.Minute)); err != errInvalidSchedule {
+		t.Fatalf("err = %v, want errInvalidSchedule", err)
+	}
+}
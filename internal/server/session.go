@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	adminSessionCookie = "sigmartc_admin"
+	adminSessionTTL    = 12 * time.Hour
+)
+
+// SessionStore keeps admin session tokens in memory. Sessions do not survive restarts.
+type SessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]time.Time // token -> expiry
+}
+
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	return &SessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]time.Time),
+	}
+}
+
+// Create issues a new random session token.
+func (s *SessionStore) Create() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(time.Now())
+	s.sessions[token] = time.Now().Add(s.ttl)
+	return token, nil
+}
+
+// Valid reports whether the token belongs to an unexpired session.
+func (s *SessionStore) Valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, ok := s.sessions[token]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiry) {
+		delete(s.sessions, token)
+		return false
+	}
+	return true
+}
+
+// Revoke invalidates the token.
+func (s *SessionStore) Revoke(token string) {
+	s.mu.Lock()
+	delete(s.sessions, token)
+	s.mu.Unlock()
+}
+
+func (s *SessionStore) pruneLocked(now time.Time) {
+	for token, expiry := range s.sessions {
+		if now.After(expiry) {
+			delete(s.sessions, token)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import "context"
+
+// signalingQueueSize bounds the messages waiting for a peer's signaling worker.
+// A browser sends a handful per negotiation, so a full queue means the client is
+// flooding or the worker is wedged.
+const signalingQueueSize = 64
+
+// signalingQueue feeds one peer's signaling messages, in arrival order, to a
+// dedicated worker. The WS read loop only enqueues, so a slow SetRemoteDescription
+// never delays reading pongs or close frames.
+type signalingQueue struct {
+	messages chan map[string]any
+}
+
+func newSignalingQueue() *signalingQueue {
+	return &signalingQueue{messages: make(chan map[string]any, signalingQueueSize)}
+}
+
+// push enqueues msg without blocking and reports false if the queue is full.
+func (q *signalingQueue) push(msg map[string]any) bool {
+	select {
+	case q.messages <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// runSignalingWorker handles queued messages one at a time until ctx, the peer's
+// session context, is done.
+func (h *Handler) runSignalingWorker(ctx context.Context, peer *Peer, q *signalingQueue) {
+	defer peer.recoverPanic("signaling")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-q.messages:
+			h.handleSignalingMessage(peer.Room(), peer, msg)
+		}
+	}
+}
+
+// enqueueSignaling hands msg to the peer's worker, disconnecting the peer if its
+// queue has overflowed.
+func (h *Handler) enqueueSignaling(peer *Peer, q *signalingQueue, msg map[string]any) {
+	if q.push(msg) {
+		return
+	}
+	peer.log().Warn("Signaling queue full, disconnecting peer", "size", signalingQueueSize)
+	peer.Disconnect(DisconnectSignalingOverflow, "Too many signaling messages")
+}
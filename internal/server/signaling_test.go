@@ -0,0 +1,20 @@
+package server
+
+import "testing"
+
+func TestSignalingQueueIsBoundedFIFO(t *testing.T) {
+	q := newSignalingQueue()
+	for i := 0; i < signalingQueueSize; i++ {
+		if !q.push(map[string]any{"seq": i}) {
+			t.Fatalf("push %d rejected before the queue was full", i)
+		}
+	}
+	if q.push(map[string]any{"seq": signalingQueueSize}) {
+		t.Fatal("expected push to fail once the queue is full")
+	}
+	for i := 0; i < signalingQueueSize; i++ {
+		if msg := <-q.messages; msg["seq"] != i {
+			t.Fatalf("message %d has seq %v, want FIFO order", i, msg["seq"])
+		}
+	}
+}
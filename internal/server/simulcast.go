@@ -0,0 +1,383 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+)
+
+// Audio simulcast: a publisher may send a second, low-bitrate copy of its audio
+// as a separate track and name it in hello (capabilities.low_layer_track). The
+// copy gets its own forwarder hanging off the publisher's main forwarder, and
+// each receiver is fed from one of the two. In auto mode the layer follows the
+// loss the receiver reports in RTCP receiver reports for that publisher; a
+// quality message pins it. Receivers keep their local track across switches.
+// The two layers' RTP sequence numbers and timestamps are unrelated, so each
+// receiver's copy is rewritten (layerRewriters) to continue where the layer it
+// left stopped, as replaceSource does for a restarted track.
+
+const (
+	// Receivers move to the low layer at lowLayerLoss and back at highLayerLoss
+	// (RTCP fraction lost, out of 256); the gap keeps them from flapping.
+	lowLayerLoss  = 26 // ~10%
+	highLayerLoss = 5  // ~2%
+)
+
+// Quality is a receiver's layer choice for simulcast publishers.
+type Quality string
+
+const (
+	QualityAuto Quality = "auto"
+	QualityLow  Quality = "low"
+	QualityHigh Quality = "high"
+)
+
+var errInvalidQuality = errors.New("quality must be auto, low or high")
+
+// isLowLayer reports whether track is the publisher's declared low-bitrate copy.
+func (p *Peer) isLowLayer(track *webrtc.TrackRemote) bool {
+	caps := p.Capabilities()
+	return caps != nil && caps.LowLayerTrack != "" && track.ID() == caps.LowLayerTrack
+}
+
+// wantsLowLayer reports whether the peer should receive senderID's low layer.
+func (p *Peer) wantsLowLayer(senderID string) bool {
+	p.layersMu.Lock()
+	defer p.layersMu.Unlock()
+	switch p.quality {
+	case QualityLow:
+		return true
+	case QualityHigh:
+		return false
+	}
+	return p.lossy[senderID]
+}
+
+// lowLayerForwarder returns the low layer the peer publishes, or nil.
+func (p *Peer) lowLayerForwarder() *TrackForwarder {
+	p.layersMu.Lock()
+	defer p.layersMu.Unlock()
+	return p.lowLayer
+}
+
+// layerRewriters keeps each receiver's sequence numbers and timestamps
+// continuous as it moves between a publisher's layers. The main forwarder and
+// its low layer share one; both fan out concurrently, hence mu.
+type layerRewriters struct {
+	mu        sync.Mutex
+	receivers map[string]*layerRewriter
+}
+
+type layerRewriter struct {
+	// source is the layer the receiver is fed from; packets from the other
+	// layer still in flight after a switch are dropped.
+	source   *TrackForwarder
+	rewriter seqRewriter
+}
+
+func newLayerRewriters() *layerRewriters {
+	return &layerRewriters{receivers: make(map[string]*layerRewriter)}
+}
+
+// switchTo makes the next packet receiverID gets from source the first of a
+// new stream.
+func (l *layerRewriters) switchTo(receiverID string, source *TrackForwarder) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := l.receivers[receiverID]
+	if r == nil {
+		r = &layerRewriter{}
+		l.receivers[receiverID] = r
+	}
+	r.source = source
+	r.rewriter.rebase()
+}
+
+// forget drops receiverID's state once it no longer receives either layer.
+func (l *layerRewriters) forget(receiverID string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	delete(l.receivers, receiverID)
+	l.mu.Unlock()
+}
+
+// rewrite rewrites packet in place as source sends it to receiverID and
+// returns the original header fields for restore. It reports false for a
+// packet from a layer the receiver has left.
+func (l *layerRewriters) rewrite(receiverID string, source *TrackForwarder, packet []byte, now time.Time) ([6]byte, bool) {
+	var saved [6]byte
+	if len(packet) < 8 {
+		return saved, true
+	}
+	copy(saved[:], packet[2:8])
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := l.receivers[receiverID]
+	if r == nil {
+		r = &layerRewriter{source: source}
+		l.receivers[receiverID] = r
+	}
+	if r.source != source {
+		return saved, false
+	}
+	r.rewriter.rewrite(packet, source.clockRate, now)
+	return saved, true
+}
+
+// restore puts back the header fields rewrite replaced, for the next receiver.
+func (l *layerRewriters) restore(packet []byte, saved [6]byte) {
+	if len(packet) >= 8 {
+		copy(packet[2:8], saved[:])
+	}
+}
+
+// setLayer records which layer receiverID should get and moves its local track
+// if the low layer is present.
+func (f *TrackForwarder) setLayer(receiverID string, low bool) {
+	f.mu.Lock()
+	if f.lowReceivers[receiverID] == low {
+		f.mu.Unlock()
+		return
+	}
+	if f.lowReceivers == nil {
+		f.lowReceivers = make(map[string]bool)
+	}
+	if low {
+		f.lowReceivers[receiverID] = true
+	} else {
+		delete(f.lowReceivers, receiverID)
+	}
+	lowLayer := f.low
+	if lowLayer == nil {
+		f.mu.Unlock()
+		return
+	}
+	layers := f.layers
+	if low {
+		track := f.subscribers[receiverID]
+		delete(f.subscribers, receiverID)
+		f.mu.Unlock()
+		if track != nil {
+			layers.switchTo(receiverID, lowLayer)
+			lowLayer.Subscribe(receiverID, track)
+		}
+		return
+	}
+	f.mu.Unlock()
+	if track := lowLayer.takeSubscriber(receiverID); track != nil {
+		layers.switchTo(receiverID, f)
+		f.mu.Lock()
+		f.subscribers[receiverID] = track
+		f.mu.Unlock()
+	}
+}
+
+// setLowLayer attaches the publisher's low layer (nil detaches it) and moves the
+// receivers that want it.
+func (f *TrackForwarder) setLowLayer(low *TrackForwarder) {
+	f.mu.Lock()
+	previous := f.low
+	f.low = low
+	if low != nil && f.layers == nil {
+		f.layers = newLayerRewriters()
+	}
+	layers := f.layers
+	moved := make(map[string]*webrtc.TrackLocalStaticRTP)
+	gains := make(map[string]gainStage, len(f.gains))
+	for receiverID, stage := range f.gains {
+		gains[receiverID] = stage
+	}
+	if low != nil {
+		for receiverID := range f.lowReceivers {
+			if track := f.subscribers[receiverID]; track != nil {
+				moved[receiverID] = track
+				delete(f.subscribers, receiverID)
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	if low != nil {
+		// Receivers keep their gain and stream continuity on either layer.
+		low.mu.Lock()
+		low.gains = gains
+		low.layers = layers
+		low.mu.Unlock()
+	}
+	for receiverID, track := range moved {
+		layers.switchTo(receiverID, low)
+		low.Subscribe(receiverID, track)
+	}
+	if previous != nil && previous != low {
+		// Take back receivers that were on the old layer.
+		previous.mu.Lock()
+		back := previous.subscribers
+		previous.subscribers = make(map[string]*webrtc.TrackLocalStaticRTP)
+		previous.mu.Unlock()
+		for receiverID, track := range back {
+			layers.switchTo(receiverID, f)
+			f.Subscribe(receiverID, track)
+		}
+	}
+}
+
+// takeSubscriber removes and returns receiverID's local track.
+func (f *TrackForwarder) takeSubscriber(receiverID string) *webrtc.TrackLocalStaticRTP {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	track := f.subscribers[receiverID]
+	delete(f.subscribers, receiverID)
+	return track
+}
+
+// addLowLayer forwards a publisher's low-bitrate copy. It attaches to the main
+// forwarder now or, if that track has not arrived yet, when broadcastTrack
+// creates it.
+func (h *Handler) addLowLayer(room *Room, sender *Peer, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	low := NewTrackForwarder(sender.Context(), sender.ID, track)
+	h.configureForwarder(sender, low, track, receiver)
+	// Talk time and speech detection come from the main layer.
+	low.audioLevelExtID = 0
+	low.onSpeech = nil
+	low.onRTP = func(packet []byte) {
+		if current := sender.Room(); current != nil {
+			h.RoomManager.countPublished(current, len(packet))
+		}
+	}
+	low.onStop = func(error) {
+		sender.layersMu.Lock()
+		if sender.lowLayer == low {
+			sender.lowLayer = nil
+		}
+		sender.layersMu.Unlock()
+		if current := sender.Room(); current != nil {
+			current.ForwardersMu.RLock()
+			main := current.Forwarders[sender.ID]
+			current.ForwardersMu.RUnlock()
+			if main != nil {
+				main.detachLowLayer(low)
+			}
+		}
+	}
+
+	sender.layersMu.Lock()
+	previous := sender.lowLayer
+	sender.lowLayer = low
+	sender.layersMu.Unlock()
+	if previous != nil {
+		previous.Stop()
+	}
+
+	room.ForwardersMu.RLock()
+	main := room.Forwarders[sender.ID]
+	room.ForwardersMu.RUnlock()
+	if main != nil {
+		main.setLowLayer(low)
+	}
+	sender.log().Info("Receiving low audio layer", "track", track.ID())
+	go low.Start()
+}
+
+// detachLowLayer drops low if it is still the attached layer.
+func (f *TrackForwarder) detachLowLayer(low *TrackForwarder) {
+	f.mu.RLock()
+	current := f.low
+	f.mu.RUnlock()
+	if current == low {
+		f.setLowLayer(nil)
+	}
+}
+
+// readReceiverReports drains RTCP for the receiver's copy of senderID's audio and
+// switches layers when the reported loss crosses the thresholds.
+func (h *Handler) readReceiverReports(receiver *Peer, senderID string, rtpSender *webrtc.RTPSender) {
+	for {
+		select {
+		case <-receiver.Done():
+			return
+		default:
+		}
+		packets, _, err := rtpSender.ReadRTCP()
+		if err != nil {
+			return
+		}
+		for _, packet := range packets {
+			report, ok := packet.(*rtcp.ReceiverReport)
+			if !ok {
+				continue
+			}
+			for _, block := range report.Reports {
+				if receiver.noteLoss(senderID, block.FractionLost) {
+					h.applyLayer(receiver, senderID)
+				}
+			}
+		}
+	}
+}
+
+// noteLoss records the loss reported for senderID's audio and reports whether
+// the receiver crossed into or out of the lossy state.
+func (p *Peer) noteLoss(senderID string, fractionLost uint8) bool {
+	p.layersMu.Lock()
+	defer p.layersMu.Unlock()
+	lossy := p.lossy[senderID]
+	switch {
+	case !lossy && fractionLost >= lowLayerLoss:
+		if p.lossy == nil {
+			p.lossy = make(map[string]bool)
+		}
+		p.lossy[senderID] = true
+		return true
+	case lossy && fractionLost <= highLayerLoss:
+		delete(p.lossy, senderID)
+		return true
+	}
+	return false
+}
+
+// applyLayer moves the receiver to the layer it should get from senderID.
+func (h *Handler) applyLayer(receiver *Peer, senderID string) {
+	room := receiver.Room()
+	if room == nil {
+		return
+	}
+	room.ForwardersMu.RLock()
+	forwarder := room.Forwarders[senderID]
+	room.ForwardersMu.RUnlock()
+	if forwarder != nil {
+		forwarder.setLayer(receiver.ID, receiver.wantsLowLayer(senderID))
+	}
+}
+
+// handleQuality applies a receiver's quality override to every publisher in the room.
+func (h *Handler) handleQuality(room *Room, peer *Peer, msg map[string]any) {
+	value, _ := msg["value"].(string)
+	quality := Quality(value)
+	switch quality {
+	case QualityAuto, QualityLow, QualityHigh:
+	default:
+		peer.WriteJSON(map[string]string{"type": "error", "message": errInvalidQuality.Error()})
+		return
+	}
+	peer.layersMu.Lock()
+	peer.quality = quality
+	peer.layersMu.Unlock()
+
+	room.ForwardersMu.RLock()
+	senders := make([]string, 0, len(room.Forwarders))
+	for senderID := range room.Forwarders {
+		senders = append(senders, senderID)
+	}
+	room.ForwardersMu.RUnlock()
+	for _, senderID := range senders {
+		h.applyLayer(peer, senderID)
+	}
+}
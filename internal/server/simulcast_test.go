@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+func TestTrackForwarderLayerSwitching(t *testing.T) {
+	localTrack, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "track-id", "stream-id")
+	if err != nil {
+		t.Fatalf("failed to create local track: %v", err)
+	}
+	high := NewTrackForwarder(context.Background(), "sender", nil)
+	low := NewTrackForwarder(context.Background(), "sender", nil)
+
+	// A preference recorded before the low layer arrives applies once it does.
+	high.setLayer("receiver", true)
+	high.Subscribe("receiver", localTrack)
+	if high.SubscriberCount() != 1 {
+		t.Fatal("expected receiver on the high layer without a low layer")
+	}
+	high.setLowLayer(low)
+	if high.SubscriberCount() != 0 || low.SubscriberCount() != 1 {
+		t.Fatalf("expected receiver moved to the low layer, high=%d low=%d", high.SubscriberCount(), low.SubscriberCount())
+	}
+	if !high.hasSubscriber("receiver") {
+		t.Fatal("expected hasSubscriber to see the low layer")
+	}
+
+	high.setLayer("receiver", false)
+	if high.SubscriberCount() != 1 || low.SubscriberCount() != 0 {
+		t.Fatalf("expected receiver back on the high layer, high=%d low=%d", high.SubscriberCount(), low.SubscriberCount())
+	}
+
+	high.setLayer("receiver", true)
+	high.detachLowLayer(low)
+	if high.SubscriberCount() != 1 || low.SubscriberCount() != 0 {
+		t.Fatal("expected receivers taken back when the low layer goes away")
+	}
+
+	high.setLowLayer(low)
+	high.Unsubscribe("receiver")
+	if high.hasSubscriber("receiver") {
+		t.Fatal("expected unsubscribe to cover both layers")
+	}
+}
+
+// recordingStage records the sequence numbers and timestamps a receiver gets.
+type recordingStage struct {
+	seqs []uint16
+	tss  []uint32
+}
+
+func (r *recordingStage) write(packet []byte, _ *webrtc.TrackLocalStaticRTP) (bool, error) {
+	var header rtp.Header
+	if _, err := header.Unmarshal(packet); err != nil {
+		return false, err
+	}
+	r.seqs = append(r.seqs, header.SequenceNumber)
+	r.tss = append(r.tss, header.Timestamp)
+	return true, nil
+}
+
+func (r *recordingStage) close() {}
+
+func TestLayerSwitchKeepsReceiverStreamContinuous(t *testing.T) {
+	localTrack, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "track-id", "stream-id")
+	if err != nil {
+		t.Fatalf("failed to create local track: %v", err)
+	}
+	high := NewTrackForwarder(context.Background(), "sender", nil)
+	low := NewTrackForwarder(context.Background(), "sender", nil)
+	high.Subscribe("receiver", localTrack)
+	received := &recordingStage{}
+	high.setGain("receiver", received)
+	high.setLowLayer(low)
+
+	high.fanOut(rtpPacket(t, 100, 1000))
+	high.fanOut(rtpPacket(t, 101, 1960))
+	high.setLayer("receiver", true)
+	low.fanOut(rtpPacket(t, 40000, 900000))
+	high.fanOut(rtpPacket(t, 102, 2920)) // the layer it left
+	low.fanOut(rtpPacket(t, 40001, 900960))
+	high.setLayer("receiver", false)
+	low.fanOut(rtpPacket(t, 40002, 901920))
+	high.fanOut(rtpPacket(t, 103, 3880))
+	high.fanOut(rtpPacket(t, 104, 4840))
+
+	if len(received.seqs) != 6 {
+		t.Fatalf("received seqs %v, want 6 packets", received.seqs)
+	}
+	for i := 1; i < len(received.seqs); i++ {
+		if received.seqs[i] != received.seqs[i-1]+1 {
+			t.Fatalf("seqs %v are not continuous", received.seqs)
+		}
+		if delta := received.tss[i] - received.tss[i-1]; delta < 960 || delta > 48000 {
+			t.Fatalf("timestamps %v jump by %d", received.tss, delta)
+		}
+	}
+	if received.seqs[0] != 100 || received.tss[0] != 1000 {
+		t.Fatalf("first packet rewritten to seq %d ts %d", received.seqs[0], received.tss[0])
+	}
+}
+
+func TestPeerLossHysteresis(t *testing.T) {
+	peer := &Peer{}
+	if peer.noteLoss("sender", highLayerLoss+1) {
+		t.Fatal("moderate loss should not switch layers")
+	}
+	if !peer.noteLoss("sender", lowLayerLoss) || !peer.wantsLowLayer("sender") {
+		t.Fatal("expected heavy loss to select the low layer")
+	}
+	if peer.noteLoss("sender", highLayerLoss+1) || !peer.wantsLowLayer("sender") {
+		t.Fatal("expected the low layer kept until loss clears")
+	}
+	if !peer.noteLoss("sender", 0) || peer.wantsLowLayer("sender") {
+		t.Fatal("expected recovery to select the high layer")
+	}
+
+	peer.quality = QualityLow
+	if !peer.wantsLowLayer("sender") {
+		t.Fatal("expected quality override to pin the low layer")
+	}
+}
+
+func TestQualityRejectsUnknownValue(t *testing.T) {
+	_, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "quality", "alice")
+	readUntilType(t, conn, "room_state")
+	if err := conn.WriteJSON(map[string]string{"type": "quality", "value": "ultra"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if msg := readUntilType(t, conn, "error"); msg["message"] != errInvalidQuality.Error() {
+		t.Fatalf("unexpected error: %v", msg)
+	}
+}
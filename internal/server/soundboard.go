@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/internal/logger"
+	"sigmartc/internal/soundboard"
+)
+
+// soundboardSenderID is the synthetic publisher ID used for injected clips. It is
+// stable so subscribers reuse one outbound track across plays.
+const soundboardSenderID = "soundboard"
+
+var (
+	errSoundboardDisabled = errors.New("soundboard is disabled")
+	errInvalidClip        = errors.New("invalid clip name")
+)
+
+// soundboardCodec matches the Opus codec registered by NewMediaEngine.
+var soundboardCodec = webrtc.RTPCodecCapability{
+	MimeType:    webrtc.MimeTypeOpus,
+	ClockRate:   48000,
+	Channels:    2,
+	SDPFmtpLine: "minptime=10;useinbandfec=1",
+}
+
+// SoundboardClips lists the Ogg Opus files available in SoundboardDir.
+func (h *Handler) SoundboardClips() ([]string, error) {
+	if h.SoundboardDir == "" {
+		return nil, errSoundboardDisabled
+	}
+	entries, err := os.ReadDir(h.SoundboardDir)
+	if err != nil {
+		return nil, err
+	}
+	clips := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && isClipName(entry.Name()) {
+			clips = append(clips, entry.Name())
+		}
+	}
+	sort.Strings(clips)
+	return clips, nil
+}
+
+func isClipName(name string) bool {
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".ogg" || ext == ".opus"
+}
+
+// PlaySoundboard publishes clip into the room as a synthetic track, replacing any
+// clip already playing. With loop set it repeats until StopSoundboard.
+func (h *Handler) PlaySoundboard(roomUUID, clip string, loop bool) error {
+	if h.SoundboardDir == "" {
+		return errSoundboardDisabled
+	}
+	if !isClipName(clip) {
+		return errInvalidClip
+	}
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return errRoomNotFound
+	}
+	if room.IsE2EE() {
+		return errE2EERoom
+	}
+
+	file, err := os.Open(filepath.Join(h.SoundboardDir, clip))
+	if err != nil {
+		return err
+	}
+	packets, err := soundboard.ReadOggOpus(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	h.playPackets(room, soundboardSenderID, packets, loop, func() {
+		logger.LogEvent("SOUNDBOARD_STOP", slog.String("uuid", roomUUID), slog.String("clip", clip))
+	})
+	logger.LogEvent("SOUNDBOARD_PLAY", slog.String("uuid", roomUUID), slog.String("clip", clip), slog.Bool("loop", loop))
+	return nil
+}
+
+// playPackets publishes packets into the room under senderID, replacing whatever that
+// synthetic sender was playing. onDone runs when playback ends or is stopped.
+func (h *Handler) playPackets(room *Room, senderID string, packets []soundboard.Packet, loop bool, onDone func()) {
+	forwarder := newSyntheticForwarder(context.Background(), senderID, soundboardCodec)
+	forwarder.read = soundboard.NewPlayer(packets, loop, uint8(opusPayloadType), forwarder.ctx.Done()).Read
+	forwarder.onStop = func(error) {
+		room.ForwardersMu.Lock()
+		if room.Forwarders[senderID] == forwarder {
+			delete(room.Forwarders, senderID)
+		}
+		room.ForwardersMu.Unlock()
+		if onDone != nil {
+			onDone()
+		}
+	}
+	h.publishSynthetic(room, forwarder)
+}
+
+// StopSoundboard stops the clip playing in the room and reports whether one was.
+func (h *Handler) StopSoundboard(roomUUID string) bool {
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return false
+	}
+	return room.stopSynthetic(soundboardSenderID)
+}
+
+// stopSynthetic stops a server-generated track and reports whether it was playing.
+func (r *Room) stopSynthetic(senderID string) bool {
+	r.ForwardersMu.RLock()
+	forwarder := r.Forwarders[senderID]
+	r.ForwardersMu.RUnlock()
+	if forwarder == nil {
+		return false
+	}
+	// stopWithError runs onStop, which removes the forwarder from the room.
+	forwarder.stopWithError(nil)
+	return true
+}
+
+// publishSynthetic registers a server-generated forwarder in the room, subscribes every
+// peer to it, and starts it, the same way broadcastTrack does for a peer's track.
+func (h *Handler) publishSynthetic(room *Room, forwarder *TrackForwarder) {
+	room.ForwardersMu.Lock()
+	previous := room.Forwarders[forwarder.SenderID]
+	room.Forwarders[forwarder.SenderID] = forwarder
+	room.ForwardersMu.Unlock()
+	if previous != nil {
+		previous.stopWithError(nil)
+	}
+	room.attachEgress(forwarder.SenderID, forwarder)
+
+	room.Lock.RLock()
+	receivers := make([]*Peer, 0, len(room.Peers))
+	for _, receiver := range room.Peers {
+		receivers = append(receivers, receiver)
+	}
+	room.Lock.RUnlock()
+	for _, receiver := range receivers {
+		h.subscribeToForwarder(receiver, forwarder.SenderID, forwarder)
+	}
+	go forwarder.Start()
+}
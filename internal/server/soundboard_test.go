@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+func writeTestClip(t *testing.T, path string, packets int) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer, err := oggwriter.NewWith(file, 48000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < packets; i++ {
+		packet := &rtp.Packet{
+			Header:  rtp.Header{SequenceNumber: uint16(i), Timestamp: uint32(i * 960)},
+			Payload: []byte{0xfc, byte(i)}, // CELT 20ms
+		}
+		if err := writer.WriteRTP(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAdminSoundboardPlaysAndStopsClip(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	handler.SoundboardDir = t.TempDir()
+	writeTestClip(t, filepath.Join(handler.SoundboardDir, "chime.ogg"), 3)
+	room := handler.RoomManager.GetOrCreateRoom("room-a")
+
+	rec := adminRequest(t, handler, http.MethodGet, "action=soundboard")
+	var listing struct{ Clips []string }
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil || len(listing.Clips) != 1 || listing.Clips[0] != "chime.ogg" {
+		t.Fatalf("listing = %+v, %v", listing, err)
+	}
+
+	for query, want := range map[string]int{
+		"action=soundboard_play&room=room-a&clip=../chime.ogg": http.StatusBadRequest,
+		"action=soundboard_play&room=room-a&clip=missing.ogg":  http.StatusNotFound,
+		"action=soundboard_play&room=missing&clip=chime.ogg":   http.StatusNotFound,
+		"action=soundboard_stop&room=room-a":                   http.StatusNotFound,
+	} {
+		if got := adminRequest(t, handler, http.MethodPost, query).Code; got != want {
+			t.Fatalf("%s: status = %d, want %d", query, got, want)
+		}
+	}
+
+	if rec := adminRequest(t, handler, http.MethodPost, "action=soundboard_play&room=room-a&clip=chime.ogg&loop=1"); rec.Code != http.StatusOK {
+		t.Fatalf("play: status = %d %s", rec.Code, rec.Body.String())
+	}
+	room.ForwardersMu.RLock()
+	forwarder := room.Forwarders[soundboardSenderID]
+	room.ForwardersMu.RUnlock()
+	if forwarder == nil {
+		t.Fatal("expected soundboard forwarder in room")
+	}
+	received := make(chan struct{}, 16)
+	forwarder.AddTap("test", func([]byte) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+	})
+	// A three-packet clip only lasts 60ms; looping keeps packets coming.
+	for i := 0; i < 5; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatalf("expected looping packets, got %d", i)
+		}
+	}
+
+	if rec := adminRequest(t, handler, http.MethodPost, "action=soundboard_stop&room=room-a"); rec.Code != http.StatusOK {
+		t.Fatalf("stop: status = %d", rec.Code)
+	}
+	room.ForwardersMu.RLock()
+	_, still := room.Forwarders[soundboardSenderID]
+	room.ForwardersMu.RUnlock()
+	if still {
+		t.Fatal("expected soundboard forwarder to be removed")
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// staticHashLen is how many hex digits of the content hash go into asset names.
+	staticHashLen = 10
+	// immutableCacheControl is sent for content-hashed names, which change
+	// whenever the file does; plain names are revalidated with their ETag.
+	immutableCacheControl  = "public, max-age=31536000, immutable"
+	revalidateCacheControl = "no-cache"
+)
+
+// compressibleTypes are the extensions gzipped at load time.
+var compressibleTypes = map[string]bool{
+	".css": true, ".html": true, ".js": true, ".json": true, ".svg": true, ".txt": true, ".wav": true,
+}
+
+// StaticAssets serves the web client's static files from memory. Every file is
+// reachable under its plain name and under a content-hashed name
+// (js/app.js -> js/app.<hash>.js) that is cached for a year; Manifest maps one
+// to the other for templates. Compressible files are gzipped once at load, and a
+// precompressed name.br next to a file is served to clients accepting brotli.
+// Files are read once, so changes need a restart.
+type StaticAssets struct {
+	files    map[string]*staticFile // by plain and hashed name
+	manifest map[string]string
+}
+
+type staticFile struct {
+	name      string
+	hash      string
+	modTime   time.Time
+	body      []byte
+	gzip      []byte
+	brotli    []byte
+	immutable bool
+}
+
+// LoadStaticAssets reads every file under dir.
+func LoadStaticAssets(dir string) (*StaticAssets, error) {
+	a := &StaticAssets{files: make(map[string]*staticFile), manifest: make(map[string]string)}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(p, ".br") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		body, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		sum := sha256.Sum256(body)
+		file := &staticFile{name: name, hash: hex.EncodeToString(sum[:])[:staticHashLen], modTime: info.ModTime(), body: body}
+		if compressibleTypes[path.Ext(name)] {
+			file.gzip = gzipIfSmaller(body)
+		}
+		if br, err := os.ReadFile(p + ".br"); err == nil {
+			file.brotli = br
+		}
+		a.add(file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *StaticAssets) add(file *staticFile) {
+	a.files[file.name] = file
+	hashed := *file
+	hashed.immutable = true
+	ext := path.Ext(file.name)
+	hashedName := strings.TrimSuffix(file.name, ext) + "." + file.hash + ext
+	a.files[hashedName] = &hashed
+	a.manifest[file.name] = "/static/" + hashedName
+}
+
+func gzipIfSmaller(body []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(body)
+	zw.Close()
+	if buf.Len() >= len(body) {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// Manifest maps plain asset names (e.g. "js/app.js") to their content-hashed
+// URLs for templates.
+func (a *StaticAssets) Manifest() map[string]string {
+	return a.manifest
+}
+
+// ServeHTTP serves a file by the path left after stripping the /static/ prefix.
+func (a *StaticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	file := a.files[strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")]
+	if file == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if ctype := mime.TypeByExtension(path.Ext(file.name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+	if file.immutable {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", revalidateCacheControl)
+	}
+
+	body, etag := file.body, file.hash
+	if file.gzip != nil || file.brotli != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+		accepted := r.Header.Get("Accept-Encoding")
+		switch {
+		case file.brotli != nil && acceptsEncoding(accepted, "br"):
+			body, etag = file.brotli, file.hash+"-br"
+			w.Header().Set("Content-Encoding", "br")
+		case file.gzip != nil && acceptsEncoding(accepted, "gzip"):
+			body, etag = file.gzip, file.hash+"-gz"
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+	}
+	w.Header().Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, file.name, file.modTime, bytes.NewReader(body))
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows coding.
+func acceptsEncoding(header, coding string) bool {
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
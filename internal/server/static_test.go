@@ -0,0 +1,91 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStaticAssetsHashedNamesAndCaching(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "js"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Repeat("console.log('hello');\n", 100)
+	if err := os.WriteFile(filepath.Join(dir, "js", "app.js"), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assets, err := LoadStaticAssets(dir)
+	if err != nil {
+		t.Fatalf("LoadStaticAssets: %v", err)
+	}
+	hashedURL := assets.Manifest()["js/app.js"]
+	if !strings.HasPrefix(hashedURL, "/static/js/app.") || !strings.HasSuffix(hashedURL, ".js") || hashedURL == "/static/js/app.js" {
+		t.Fatalf("unexpected hashed URL %q", hashedURL)
+	}
+
+	get := func(p string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		http.StripPrefix("/static/", assets).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(hashedURL, nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("hashed name: status %d", rec.Code)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != immutableCacheControl {
+		t.Fatalf("hashed Cache-Control = %q", cc)
+	}
+
+	rec = get("/static/js/app.js", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+	if cc := rec.Header().Get("Cache-Control"); cc != revalidateCacheControl {
+		t.Fatalf("plain Cache-Control = %q", cc)
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("expected a gzip response")
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if decoded, _ := io.ReadAll(zr); string(decoded) != body {
+		t.Fatal("gzip body does not match the file")
+	}
+
+	etag := rec.Header().Get("ETag")
+	rec = get("/static/js/app.js", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("revalidation: status %d, want 304", rec.Code)
+	}
+
+	if rec := get("/static/js/missing.js", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing file: status %d", rec.Code)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	cases := []struct {
+		header, coding string
+		want           bool
+	}{
+		{"gzip, deflate, br", "br", true},
+		{"gzip;q=0", "gzip", false},
+		{"GZIP; q=0.5", "gzip", true},
+		{"deflate", "gzip", false},
+	}
+	for _, c := range cases {
+		if got := acceptsEncoding(c.header, c.coding); got != c.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", c.header, c.coding, got, c.want)
+		}
+	}
+}
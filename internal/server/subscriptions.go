@@ -0,0 +1,82 @@
+package server
+
+// A receiver may opt out of individual publishers ("mute for me"). Opting out
+// removes the receiver from the publisher's forwarder and drops the outbound
+// track, so no audio is sent for it; opting back in adds a fresh track. Both
+// renegotiate through OnNegotiationNeeded.
+
+// wantsAudioFrom reports whether the peer has not unsubscribed from senderID.
+func (p *Peer) wantsAudioFrom(senderID string) bool {
+	p.unsubscribedMu.Lock()
+	defer p.unsubscribedMu.Unlock()
+	return !p.unsubscribed[senderID]
+}
+
+// setSubscribed records whether the peer wants senderID's audio and reports
+// whether that changed.
+func (p *Peer) setSubscribed(senderID string, subscribed bool) bool {
+	p.unsubscribedMu.Lock()
+	defer p.unsubscribedMu.Unlock()
+	if !p.unsubscribed[senderID] == subscribed {
+		return false
+	}
+	if subscribed {
+		delete(p.unsubscribed, senderID)
+	} else {
+		if p.unsubscribed == nil {
+			p.unsubscribed = make(map[string]bool)
+		}
+		p.unsubscribed[senderID] = true
+	}
+	return true
+}
+
+// handleSubscription applies a subscribe or unsubscribe message for peer_id.
+func (h *Handler) handleSubscription(room *Room, peer *Peer, msg map[string]any, subscribe bool) {
+	senderID, _ := msg["peer_id"].(string)
+	room.Lock.RLock()
+	_, inRoom := room.Peers[senderID]
+	room.Lock.RUnlock()
+	if !inRoom || senderID == peer.ID {
+		peer.WriteJSON(map[string]string{"type": "error", "message": "peer not found"})
+		return
+	}
+	if !peer.setSubscribed(senderID, subscribe) {
+		return
+	}
+	peer.log().Debug("Subscription changed", "sender", senderID, "subscribed", subscribe)
+
+	room.ForwardersMu.RLock()
+	forwarder := room.Forwarders[senderID]
+	room.ForwardersMu.RUnlock()
+	if subscribe {
+		if forwarder != nil && forwarder.Codec().MimeType != "" {
+			h.subscribeToForwarder(peer, senderID, forwarder)
+		}
+		return
+	}
+	if forwarder != nil {
+		forwarder.Unsubscribe(peer.ID)
+	}
+	peer.removeOutTrack(senderID)
+}
+
+// removeOutTrack stops sending senderID's track to the peer and forgets it.
+// RemoveTrack fires OnNegotiationNeeded, so the client learns the track ended.
+func (p *Peer) removeOutTrack(senderID string) {
+	p.OutTracksMu.Lock()
+	track := p.OutTracks[senderID]
+	delete(p.OutTracks, senderID)
+	p.OutTracksMu.Unlock()
+	if track == nil || p.PC == nil {
+		return
+	}
+	for _, sender := range p.PC.GetSenders() {
+		if sender.Track() == track {
+			if err := p.PC.RemoveTrack(sender); err != nil {
+				p.log().Warn("Failed to remove track", "sender", senderID, "err", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscriptionControl(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	alice := dialTestWS(t, srv.URL, "subs", "alice")
+	aliceID, _ := readUntilType(t, alice, "room_state")["self_id"].(string)
+	bob := dialTestWS(t, srv.URL, "subs", "bob")
+	bobID, _ := readUntilType(t, bob, "room_state")["self_id"].(string)
+	_, peer := handler.RoomManager.FindPeer(aliceID)
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for peer.wantsAudioFrom(bobID) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("wantsAudioFrom(bob) = %v, want %v", !want, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := alice.WriteJSON(map[string]string{"type": "unsubscribe", "peer_id": bobID}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	waitFor(false)
+	if err := alice.WriteJSON(map[string]string{"type": "subscribe", "peer_id": bobID}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	waitFor(true)
+
+	if err := alice.WriteJSON(map[string]string{"type": "unsubscribe", "peer_id": aliceID}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	readUntilType(t, alice, "error")
+}
@@ -0,0 +1,407 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"sigmartc/pkg/protocol"
+)
+
+// Tenants let one server host several applications. A client joins a tenant's
+// room with tenant=<id>; the room is keyed "<id>/<room>", so its rooms, bans
+// (stored as "<id>/<ip>" in the ban list) and events never mix with another
+// tenant's. Each tenant has its own limits, TURN secret and webhooks, and its
+// API key, sent as a Bearer token, opens the room- and peer-scoped admin
+// actions for its own rooms only.
+
+// tenantSeparator joins a tenant ID and a name into a namespaced key. With
+// tenants configured it is refused in room names, so keys cannot be forged.
+const tenantSeparator = "/"
+
+// defaultTURNCredentialTTL is how long minted TURN credentials stay valid
+// when the tenant sets no ttl_seconds.
+const defaultTURNCredentialTTL = 24 * time.Hour
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Tenant is one application sharing the server.
+type Tenant struct {
+	ID string `json:"id"`
+	// APIKey authenticates the tenant's admin requests.
+	APIKey string `json:"api_key"`
+	// MaxRooms caps the tenant's open rooms, MaxPeers its peers across them and
+	// MaxPeersPerRoom the peers in each; zero leaves only the server's own limits.
+	MaxRooms        int `json:"max_rooms"`
+	MaxPeers        int `json:"max_peers"`
+	MaxPeersPerRoom int `json:"max_peers_per_room"`
+	// MonthlyParticipantMinutes caps the minutes peers spend in the tenant's
+	// rooms per calendar month (see MinutesQuota); zero is unlimited.
+	MonthlyParticipantMinutes int `json:"monthly_participant_minutes"`
+	// TURN, when set, is added to the ICE servers of the tenant's peers, on the
+	// server and in room_state for clients.
+	TURN *TenantTURN `json:"turn"`
+	// WebhookURLs receive the lifecycle events of the tenant's rooms, signed with
+	// WebhookSecret, in addition to the server's -webhook-url.
+	WebhookURLs   []string `json:"webhook_urls"`
+	WebhookSecret string   `json:"webhook_secret"`
+
+	webhooks *WebhookDispatcher
+}
+
+// TenantTURN is a TURN server using the TURN REST API shared secret scheme
+// (coturn's use-auth-secret).
+type TenantTURN struct {
+	URLs       []string `json:"urls"`
+	Secret     string   `json:"secret"`
+	TTLSeconds int      `json:"ttl_seconds"`
+}
+
+// iceServer mints credentials valid until now plus the TTL: the username is
+// "<expiry unix time>:<tenant>" and the password the base64 HMAC-SHA1 of the
+// username under the shared secret.
+func (t *TenantTURN) iceServer(tenantID string, now time.Time) webrtc.ICEServer {
+	ttl := defaultTURNCredentialTTL
+	if t.TTLSeconds > 0 {
+		ttl = time.Duration(t.TTLSeconds) * time.Second
+	}
+	username := strconv.FormatInt(now.Add(ttl).Unix(), 10) + ":" + tenantID
+	mac := hmac.New(sha1.New, []byte(t.Secret))
+	mac.Write([]byte(username))
+	return webrtc.ICEServer{
+		URLs:           t.URLs,
+		Username:       username,
+		Credential:     base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		CredentialType: webrtc.ICECredentialTypePassword,
+	}
+}
+
+// key namespaces a room name or IP for the tenant.
+func (t *Tenant) key(name string) string {
+	return t.ID + tenantSeparator + name
+}
+
+// local returns a key's name within the tenant's namespace. A nil tenant is
+// the default namespace, whose keys are their own names.
+func (t *Tenant) local(key string) string {
+	if t == nil {
+		return key
+	}
+	return strings.TrimPrefix(key, t.ID+tenantSeparator)
+}
+
+// Tenants holds the configured tenants by ID. A nil *Tenants means tenancy is off.
+type Tenants struct {
+	byID map[string]*Tenant
+}
+
+// LoadTenants reads {"tenants": [...]} from a JSON file and starts each
+// tenant's webhook dispatcher.
+func LoadTenants(path string) (*Tenants, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Tenants []*Tenant `json:"tenants"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	ts := &Tenants{byID: make(map[string]*Tenant, len(file.Tenants))}
+	keys := make(map[string]bool, len(file.Tenants))
+	for _, t := range file.Tenants {
+		switch {
+		case !tenantIDPattern.MatchString(t.ID):
+			return nil, fmt.Errorf("%s: invalid tenant id %q", path, t.ID)
+		case ts.byID[t.ID] != nil:
+			return nil, fmt.Errorf("%s: duplicate tenant id %q", path, t.ID)
+		case t.APIKey == "" || keys[t.APIKey]:
+			return nil, fmt.Errorf("%s: tenant %q needs its own api_key", path, t.ID)
+		case t.MaxRooms < 0 || t.MaxPeers < 0 || t.MaxPeersPerRoom < 0 || t.MonthlyParticipantMinutes < 0:
+			return nil, fmt.Errorf("%s: tenant %q has a negative limit", path, t.ID)
+		case t.TURN != nil && (len(t.TURN.URLs) == 0 || t.TURN.Secret == ""):
+			return nil, fmt.Errorf("%s: tenant %q turn needs urls and a secret", path, t.ID)
+		}
+		keys[t.APIKey] = true
+		ts.byID[t.ID] = t
+	}
+	for _, t := range ts.byID {
+		t.webhooks = NewWebhookDispatcher(t.WebhookURLs, t.WebhookSecret)
+	}
+	return ts, nil
+}
+
+// Len reports how many tenants are configured.
+func (ts *Tenants) Len() int {
+	if ts == nil {
+		return 0
+	}
+	return len(ts.byID)
+}
+
+// Close stops the tenants' webhook dispatchers. They drain concurrently, so
+// shutdown waits for the slowest rather than the sum.
+func (ts *Tenants) Close() {
+	if ts == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, t := range ts.byID {
+		wg.Add(1)
+		go func(d *WebhookDispatcher) {
+			defer wg.Done()
+			d.Close()
+		}(t.webhooks)
+	}
+	wg.Wait()
+}
+
+// list returns the tenants ordered by ID.
+func (ts *Tenants) list() []*Tenant {
+	if ts == nil {
+		return nil
+	}
+	tenants := make([]*Tenant, 0, len(ts.byID))
+	for _, t := range ts.byID {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// get returns the tenant with the ID, or nil.
+func (ts *Tenants) get(id string) *Tenant {
+	if ts == nil {
+		return nil
+	}
+	return ts.byID[id]
+}
+
+// byAPIKey returns the tenant owning key, or nil.
+func (ts *Tenants) byAPIKey(key string) *Tenant {
+	if ts == nil || key == "" {
+		return nil
+	}
+	var found *Tenant
+	for _, t := range ts.byID {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(t.APIKey)) == 1 {
+			found = t
+		}
+	}
+	return found
+}
+
+// forRoom returns the tenant whose namespace holds the room key, or nil for
+// the default namespace.
+func (ts *Tenants) forRoom(uuid string) *Tenant {
+	if ts == nil {
+		return nil
+	}
+	id, _, ok := strings.Cut(uuid, tenantSeparator)
+	if !ok {
+		return nil
+	}
+	return ts.byID[id]
+}
+
+// joinRoom resolves a join's tenant and room parameters to a room key. It
+// fails for unknown tenants and, with tenants configured, for room names
+// containing tenantSeparator.
+func (ts *Tenants) joinRoom(tenantID, room string) (string, *Tenant, bool) {
+	if ts == nil {
+		return room, nil, tenantID == ""
+	}
+	if strings.Contains(room, tenantSeparator) {
+		return "", nil, false
+	}
+	if tenantID == "" {
+		return room, nil, true
+	}
+	t := ts.get(tenantID)
+	if t == nil {
+		return "", nil, false
+	}
+	return t.key(room), t, true
+}
+
+// roomLimitReached reports whether the tenant may not open another room.
+func (rm *RoomManager) roomLimitReached(t *Tenant) bool {
+	if t == nil || t.MaxRooms == 0 {
+		return false
+	}
+	rm.Lock.RLock()
+	defer rm.Lock.RUnlock()
+	n := 0
+	for _, room := range rm.Rooms {
+		if room.tenant == t {
+			n++
+		}
+	}
+	return n >= t.MaxRooms
+}
+
+// localID is the room's name within its tenant's namespace, as clients know it.
+func (r *Room) localID() string {
+	return r.tenant.local(r.UUID)
+}
+
+// tenantICEServers returns the ICE servers added for the room's tenant.
+func (r *Room) tenantICEServers(now time.Time) []webrtc.ICEServer {
+	if r.tenant == nil || r.tenant.TURN == nil {
+		return nil
+	}
+	return []webrtc.ICEServer{r.tenant.TURN.iceServer(r.tenant.ID, now)}
+}
+
+// clientICEServers is tenantICEServers as room_state sends them.
+func (r *Room) clientICEServers(now time.Time) []protocol.ICEServer {
+	var servers []protocol.ICEServer
+	for _, server := range r.tenantICEServers(now) {
+		credential, _ := server.Credential.(string)
+		servers = append(servers, protocol.ICEServer{URLs: server.URLs, Username: server.Username, Credential: credential})
+	}
+	return servers
+}
+
+// sendTenantEvent delivers an event about one of a tenant's rooms to the
+// tenant's webhooks.
+func (rm *RoomManager) sendTenantEvent(event Event, data map[string]any) {
+	room, _ := data["room"].(string)
+	if room == "" {
+		room, _ = data["to"].(string)
+	}
+	if t := rm.Tenants.forRoom(room); t != nil {
+		t.webhooks.Send(event)
+	}
+}
+
+type tenantKey struct{}
+
+// requestTenant returns the tenant whose API key the request carries as a
+// Bearer token, or nil.
+func (h *Handler) requestTenant(r *http.Request) *Tenant {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+	return h.RoomManager.Tenants.byAPIKey(key)
+}
+
+// tenantAdminActions are the admin actions a tenant's API key may call. Each
+// names one room or peer, which must belong to the tenant.
+var tenantAdminActions = map[string]bool{
+	"kick": true, "move_peer": true, "close_room": true, "lock_room": true, "unlock_room": true,
+	"call_next": true, "priority_speaker": true, "room_listing": true, "room_e2ee": true,
+	"room_codecs": true, "room_relay": true, "room_bitrate": true, "room_leveling": true,
+	"denoise": true, "room_metadata": true, "schedule": true, "egress_start": true,
+	"egress_stop": true, "transcribe_start": true, "transcribe_stop": true, "transcript": true,
+	"soundboard_play": true, "soundboard_stop": true, "announce": true, "room_stats": true,
+	"peer": true, "peer_stats": true, "peer_timeline": true, "ice_restart": true,
+}
+
+// handleTenantAdmin serves an admin request authenticated with a tenant's API
+// key. Room names are mapped into the tenant's namespace and peers outside it
+// are reported missing before the request is handled like an admin's; stats,
+// ban and unban only see the tenant's rooms.
+func (h *Handler) handleTenantAdmin(w http.ResponseWriter, r *http.Request, tenant *Tenant, action string) {
+	r = r.Clone(context.WithValue(r.Context(), tenantKey{}, tenant))
+	query := r.URL.Query()
+	switch {
+	case action == "stats":
+		json.NewEncoder(w).Encode(h.tenantStats(tenant))
+		return
+	case action == "quota":
+		h.tenantQuota(w, r, tenant)
+		return
+	case action == "ban" || action == "unban":
+		h.tenantBan(w, r, tenant, action)
+		return
+	case !tenantAdminActions[action]:
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if room := strings.TrimSpace(query.Get("room")); room != "" {
+		if strings.Contains(room, tenantSeparator) {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		query.Set("room", tenant.key(room))
+	}
+	if peerID := strings.TrimSpace(query.Get("peer_id")); peerID != "" {
+		if room, _ := h.RoomManager.FindPeer(peerID); room == nil || room.tenant != tenant {
+			http.Error(w, "Peer not found", http.StatusNotFound)
+			return
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+	h.adminAction(w, r, action)
+}
+
+func (h *Handler) tenantStats(tenant *Tenant) map[string]any {
+	h.RoomManager.Lock.RLock()
+	defer h.RoomManager.Lock.RUnlock()
+	rooms, users := 0, 0
+	for _, room := range h.RoomManager.Rooms {
+		if room.tenant != tenant {
+			continue
+		}
+		rooms++
+		room.Lock.RLock()
+		users += len(room.Peers)
+		room.Lock.RUnlock()
+	}
+	return map[string]any{"tenant": tenant.ID, "rooms": rooms, "users": users}
+}
+
+// tenantQuota reports the tenant's usage, or one of its rooms' with room=.
+func (h *Handler) tenantQuota(w http.ResponseWriter, r *http.Request, tenant *Tenant) {
+	room := strings.TrimSpace(r.URL.Query().Get("room"))
+	if room == "" {
+		json.NewEncoder(w).Encode(h.RoomManager.tenantUsage(tenant, time.Now()))
+		return
+	}
+	if strings.Contains(room, tenantSeparator) {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	usage := h.RoomManager.roomQuotaUsage(tenant.key(room), time.Now())
+	usage.Room = room
+	json.NewEncoder(w).Encode(usage)
+}
+
+// tenantBan bans an IP from the tenant's rooms only, disconnecting it there.
+func (h *Handler) tenantBan(w http.ResponseWriter, r *http.Request, tenant *Tenant, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ip := strings.TrimSpace(r.URL.Query().Get("ip"))
+	if net.ParseIP(ip) == nil {
+		http.Error(w, "Invalid IP address", http.StatusBadRequest)
+		return
+	}
+	if action == "ban" {
+		h.RoomManager.BanIP(tenant.key(ip))
+		h.RoomManager.disconnectIP(ip, tenant, DisconnectBanned, "You have been banned")
+		fmt.Fprintf(w, "Banned %s", ip)
+	} else {
+		h.RoomManager.UnbanIP(tenant.key(ip))
+		fmt.Fprintf(w, "Unbanned %s", ip)
+	}
+	h.audit(r, action, tenant.key(ip), "")
+}
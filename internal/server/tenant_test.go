@@ -0,0 +1,244 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testTenantsJSON = `{"tenants": [
+	{"id": "acme", "api_key": "acme-key", "max_rooms": 1, "max_peers_per_room": 2,
+	 "turn": {"urls": ["turn:turn.acme.test:3478"], "secret": "acme-secret", "ttl_seconds": 600}},
+	{"id": "globex", "api_key": "globex-key"}
+]}`
+
+func newTestTenants(t *testing.T) *Tenants {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(testTenantsJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := LoadTenants(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tenants.Close)
+	return tenants
+}
+
+func newTestTenantHandler(t *testing.T) *Handler {
+	t.Helper()
+	handler := newTestAdminHandler(t)
+	handler.RoomManager.Tenants = newTestTenants(t)
+	return handler
+}
+
+func TestLoadTenantsValidates(t *testing.T) {
+	tests := map[string]string{
+		"bad id":        `{"tenants": [{"id": "Acme/1", "api_key": "k"}]}`,
+		"duplicate id":  `{"tenants": [{"id": "a", "api_key": "k1"}, {"id": "a", "api_key": "k2"}]}`,
+		"shared key":    `{"tenants": [{"id": "a", "api_key": "k"}, {"id": "b", "api_key": "k"}]}`,
+		"missing key":   `{"tenants": [{"id": "a"}]}`,
+		"negative":      `{"tenants": [{"id": "a", "api_key": "k", "max_rooms": -1}]}`,
+		"turn unsigned": `{"tenants": [{"id": "a", "api_key": "k", "turn": {"urls": ["turn:x"]}}]}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tenants.json")
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadTenants(path); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestTenantJoinNamespacesRooms(t *testing.T) {
+	handler := newTestTenantHandler(t)
+	join := func(query string) (*joinRequest, DisconnectReason) {
+		req, reason, _ := handler.checkJoin(httptest.NewRequest(http.MethodGet, "/ws?name=alice&"+query, nil))
+		return req, reason
+	}
+
+	if req, reason := join("room=lobby&tenant=acme"); reason != "" || req.room != "acme/lobby" {
+		t.Fatalf("acme join = %+v, %q", req, reason)
+	}
+	if req, reason := join("room=lobby&tenant=globex"); reason != "" || req.room != "globex/lobby" {
+		t.Fatalf("globex join = %+v, %q", req, reason)
+	}
+	if req, reason := join("room=lobby"); reason != "" || req.room != "lobby" {
+		t.Fatalf("default join = %+v, %q", req, reason)
+	}
+	for _, query := range []string{"room=acme/lobby", "room=lobby&tenant=initech"} {
+		if _, reason := join(query); reason != DisconnectInvalidName {
+			t.Fatalf("%s: reason = %q, want %q", query, reason, DisconnectInvalidName)
+		}
+	}
+
+	handler.RoomManager.GetOrCreateRoom("acme/lobby")
+	if _, reason := join("room=other&tenant=acme"); reason != DisconnectTenantLimit {
+		t.Fatalf("second acme room: reason = %q, want %q", reason, DisconnectTenantLimit)
+	}
+	if _, reason := join("room=lobby&tenant=acme"); reason != "" {
+		t.Fatalf("existing acme room refused: %q", reason)
+	}
+}
+
+func TestTenantBanIsScoped(t *testing.T) {
+	handler := newTestTenantHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/admin?action=ban&ip=192.0.2.1", nil)
+	req.Header.Set("Authorization", "Bearer acme-key")
+	rec := httptest.NewRecorder()
+	handler.HandleAdmin(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ban status = %d: %s", rec.Code, rec.Body)
+	}
+
+	for query, want := range map[string]DisconnectReason{
+		"room=lobby&tenant=acme":   DisconnectBanned,
+		"room=lobby&tenant=globex": "",
+		"room=lobby":               "",
+	} {
+		_, reason, _ := handler.checkJoin(httptest.NewRequest(http.MethodGet, "/ws?name=alice&"+query, nil))
+		if reason != want {
+			t.Fatalf("%s: reason = %q, want %q", query, reason, want)
+		}
+	}
+}
+
+func TestTenantAdminIsScoped(t *testing.T) {
+	handler := newTestTenantHandler(t)
+	acmeRoom := handler.RoomManager.GetOrCreateRoom("acme/lobby")
+	globexRoom := handler.RoomManager.GetOrCreateRoom("globex/lobby")
+	acmeRoom.Peers["acme-peer"] = &Peer{ID: "acme-peer", Name: "alice"}
+	globexRoom.Peers["globex-peer"] = &Peer{ID: "globex-peer", Name: "bob"}
+
+	do := func(method, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin?"+query, nil)
+		req.Header.Set("Authorization", "Bearer acme-key")
+		rec := httptest.NewRecorder()
+		handler.HandleAdmin(rec, req)
+		return rec
+	}
+	if rec := do(http.MethodPost, "action=log_level&level=debug"); rec.Code != http.StatusForbidden {
+		t.Fatalf("log_level status = %d, want 403", rec.Code)
+	}
+	if rec := do(http.MethodGet, "action=peer&peer_id=globex-peer"); rec.Code != http.StatusNotFound {
+		t.Fatalf("other tenant's peer status = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodGet, "action=peer&peer_id=acme-peer"); rec.Code != http.StatusOK {
+		t.Fatalf("own peer status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := do(http.MethodGet, "action=stats")
+	var stats map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats["tenant"] != "acme" || stats["rooms"] != 1.0 || stats["users"] != 1.0 {
+		t.Fatalf("stats = %v", stats)
+	}
+
+	delete(acmeRoom.Peers, "acme-peer")
+	if rec := do(http.MethodPost, "action=room_listing&room=lobby&public=1"); rec.Code != http.StatusOK {
+		t.Fatalf("room_listing status = %d: %s", rec.Code, rec.Body)
+	}
+	if !acmeRoom.Public || globexRoom.Public {
+		t.Fatal("room_listing did not map the room into the tenant's namespace")
+	}
+
+	rooms := httptest.NewRecorder()
+	handler.HandleRooms(rooms, httptest.NewRequest(http.MethodGet, "/api/rooms?public=true&tenant=acme", nil))
+	if !strings.Contains(rooms.Body.String(), `"id":"lobby"`) {
+		t.Fatalf("tenant directory = %s", rooms.Body)
+	}
+	rooms = httptest.NewRecorder()
+	handler.HandleRooms(rooms, httptest.NewRequest(http.MethodGet, "/api/rooms?public=true", nil))
+	if strings.Contains(rooms.Body.String(), "lobby") {
+		t.Fatalf("default directory lists tenant rooms: %s", rooms.Body)
+	}
+}
+
+func TestTenantHostMovesOnlyWithinNamespace(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	handler.RoomManager.Tenants = newTestTenants(t)
+	token, err := handler.RoomManager.ScheduleRoom("globex/lobby", time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dial := func(name, extra string) *websocket.Conn {
+		wsURL, err := buildWSURL(srv.URL, "lobby", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+"&tenant=globex"+extra, nil)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+	host := dial("host", "&host_token="+url.QueryEscape(token))
+	readUntilType(t, host, "room_state")
+	guest := dial("guest", "")
+	guestID, _ := readUntilType(t, guest, "room_state")["self_id"].(string)
+
+	for _, target := range []string{"acme/lobby", "globex/breakout"} {
+		if err := host.WriteJSON(map[string]any{"type": "move_peer", "peer_id": guestID, "room": target}); err != nil {
+			t.Fatal(err)
+		}
+		if msg := readUntilType(t, host, "error"); msg["message"] != "move_peer not allowed" {
+			t.Fatalf("%s: error = %v", target, msg["message"])
+		}
+	}
+	if err := host.WriteJSON(map[string]any{"type": "move_peer", "peer_id": guestID, "room": "breakout"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readUntilType(t, guest, "moved"); msg["room"] != "breakout" {
+		t.Fatalf("moved = %v", msg)
+	}
+	if room, _ := handler.RoomManager.FindPeer(guestID); room == nil || room.UUID != "globex/breakout" {
+		t.Fatalf("guest is in %v, want globex/breakout", room)
+	}
+	handler.RoomManager.Lock.RLock()
+	defer handler.RoomManager.Lock.RUnlock()
+	for _, uuid := range []string{"acme/lobby", "breakout"} {
+		if handler.RoomManager.Rooms[uuid] != nil {
+			t.Fatalf("move created room %q outside the tenant", uuid)
+		}
+	}
+}
+
+func TestTenantTURNCredentials(t *testing.T) {
+	handler := newTestTenantHandler(t)
+	room := handler.RoomManager.GetOrCreateRoom("acme/lobby")
+	now := time.Unix(1_700_000_000, 0)
+	servers := room.clientICEServers(now)
+	if len(servers) != 1 || servers[0].URLs[0] != "turn:turn.acme.test:3478" {
+		t.Fatalf("servers = %+v", servers)
+	}
+	if servers[0].Username != "1700000600:acme" {
+		t.Fatalf("username = %q", servers[0].Username)
+	}
+	mac := hmac.New(sha1.New, []byte("acme-secret"))
+	mac.Write([]byte(servers[0].Username))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); servers[0].Credential != want {
+		t.Fatalf("credential = %q, want %q", servers[0].Credential, want)
+	}
+	if servers := handler.RoomManager.GetOrCreateRoom("globex/lobby").clientICEServers(now); servers != nil {
+		t.Fatalf("tenant without TURN got %+v", servers)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// peerTimelineSize bounds the events kept per peer; older ones are dropped.
+	peerTimelineSize = 200
+	// departedTimelines is how many timelines of peers that left are kept for
+	// action=peer_timeline, so a failed session can still be looked at.
+	departedTimelines = 100
+)
+
+// Timeline event names.
+const (
+	TimelineJoined           = "joined"
+	TimelineOfferSent        = "offer_sent"
+	TimelineOfferReceived    = "offer_received"
+	TimelineOfferIgnored     = "offer_ignored"
+	TimelineAnswerSent       = "answer_sent"
+	TimelineAnswerReceived   = "answer_received"
+	TimelineNegotiationError = "negotiation_error"
+	TimelineICEState         = "ice_state"
+	TimelineConnectionState  = "connection_state"
+	TimelineICERestart       = "ice_restart"
+	TimelineTrackReceived    = "track_received"
+	TimelineTrackReplaced    = "track_replaced"
+	TimelineForwarderError   = "forwarder_error"
+	TimelineMoved            = "moved"
+	TimelineDisconnected     = "disconnected"
+	TimelineLeft             = "left"
+)
+
+// TimelineEvent is one step in a peer's session.
+type TimelineEvent struct {
+	At     time.Time `json:"at"`
+	Event  string    `json:"event"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// peerTimeline is a bounded, ordered record of a peer's session events.
+type peerTimeline struct {
+	mu      sync.Mutex
+	events  []TimelineEvent
+	dropped int
+}
+
+// note appends an event to the peer's timeline.
+func (p *Peer) note(event, detail string) {
+	t := &p.timeline
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.events) == peerTimelineSize {
+		copy(t.events, t.events[1:])
+		t.events = t.events[:peerTimelineSize-1]
+		t.dropped++
+	}
+	t.events = append(t.events, TimelineEvent{At: time.Now(), Event: event, Detail: detail})
+}
+
+// PeerTimeline is a peer's timeline as returned by the admin API.
+type PeerTimeline struct {
+	PeerID    string `json:"peer_id"`
+	SessionID string `json:"session_id"`
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	// Dropped counts early events discarded to stay within peerTimelineSize.
+	Dropped int             `json:"dropped"`
+	Events  []TimelineEvent `json:"events"`
+}
+
+// Timeline returns a snapshot of the peer's timeline.
+func (p *Peer) Timeline() PeerTimeline {
+	p.timeline.mu.Lock()
+	defer p.timeline.mu.Unlock()
+	return PeerTimeline{
+		PeerID:    p.ID,
+		SessionID: p.SessionID,
+		Name:      p.Name,
+		Dropped:   p.timeline.dropped,
+		Events:    append([]TimelineEvent{}, p.timeline.events...),
+	}
+}
+
+// departedPeers keeps the final timelines of recently departed peers.
+type departedPeers struct {
+	mu    sync.Mutex
+	order []string
+	byID  map[string]PeerTimeline
+}
+
+// rememberTimeline keeps a departing peer's timeline, evicting the oldest.
+func (rm *RoomManager) rememberTimeline(peer *Peer) {
+	d := &rm.departed
+	timeline := peer.Timeline()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.byID == nil {
+		d.byID = make(map[string]PeerTimeline)
+	}
+	if len(d.order) == departedTimelines {
+		delete(d.byID, d.order[0])
+		d.order = d.order[1:]
+	}
+	d.order = append(d.order, peer.ID)
+	d.byID[peer.ID] = timeline
+}
+
+// PeerTimeline returns the timeline of a connected or recently departed peer.
+func (rm *RoomManager) PeerTimeline(peerID string) (PeerTimeline, bool) {
+	if _, peer := rm.FindPeer(peerID); peer != nil {
+		timeline := peer.Timeline()
+		timeline.Connected = true
+		return timeline, true
+	}
+	rm.departed.mu.Lock()
+	defer rm.departed.mu.Unlock()
+	timeline, ok := rm.departed.byID[peerID]
+	return timeline, ok
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPeerTimelineIsBounded(t *testing.T) {
+	peer := &Peer{ID: "p"}
+	for range peerTimelineSize + 5 {
+		peer.note(TimelineICEState, "checking")
+	}
+	peer.note(TimelineLeft, "")
+	timeline := peer.Timeline()
+	if len(timeline.Events) != peerTimelineSize || timeline.Dropped != 6 {
+		t.Fatalf("events = %d, dropped = %d", len(timeline.Events), timeline.Dropped)
+	}
+	if last := timeline.Events[len(timeline.Events)-1]; last.Event != TimelineLeft {
+		t.Fatalf("last event = %q, want the newest", last.Event)
+	}
+
+	rm := &RoomManager{Rooms: make(map[string]*Room)}
+	for i := range departedTimelines + 1 {
+		rm.rememberTimeline(&Peer{ID: string(rune('A' + i))})
+	}
+	if _, ok := rm.PeerTimeline("A"); ok {
+		t.Fatal("expected the oldest departed timeline to be evicted")
+	}
+	if _, ok := rm.PeerTimeline(string(rune('A' + departedTimelines))); !ok {
+		t.Fatal("expected the newest departed timeline to be kept")
+	}
+}
+
+func TestAdminPeerTimeline(t *testing.T) {
+	handler, srv := newTestWSServer(t)
+	conn := dialTestWS(t, srv.URL, "timeline", "alice")
+	peerID, _ := readUntilType(t, conn, "room_state")["self_id"].(string)
+
+	get := func() (int, PeerTimeline) {
+		rec := adminRequest(t, handler, http.MethodGet, "action=peer_timeline&peer_id="+peerID)
+		var timeline PeerTimeline
+		_ = json.Unmarshal(rec.Body.Bytes(), &timeline)
+		return rec.Code, timeline
+	}
+
+	code, timeline := get()
+	if code != http.StatusOK || !timeline.Connected || len(timeline.Events) == 0 || timeline.Events[0].Event != TimelineJoined {
+		t.Fatalf("status %d, timeline %+v", code, timeline)
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		code, timeline = get()
+		if code == http.StatusOK && !timeline.Connected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeline not kept after leaving: status %d", code)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if last := timeline.Events[len(timeline.Events)-1]; last.Event != TimelineLeft {
+		t.Fatalf("last event = %+v, want left", last)
+	}
+}
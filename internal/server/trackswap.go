@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Mid-call track replacement: a publisher that restarts its audio (a device
+// switch done with removeTrack/addTrack rather than replaceTrack) arrives as a
+// new remote track with a new SSRC and sequence space. Instead of a second
+// forwarder, the publisher's forwarder switches to reading the new track, and
+// subscribers keep their local tracks, so nobody renegotiates and receivers
+// see one continuous stream.
+
+// replaceTrack moves sender's forwarder in room onto track and reports false if
+// there is no live forwarder to reuse or it is bound to a different codec.
+func (h *Handler) replaceTrack(room *Room, sender *Peer, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) bool {
+	if room == nil {
+		return false
+	}
+	room.ForwardersMu.RLock()
+	forwarder := room.Forwarders[sender.ID]
+	room.ForwardersMu.RUnlock()
+	if forwarder == nil || forwarder.ctx.Err() != nil || !sameCodec(forwarder.Codec(), track.Codec().RTPCodecCapability) {
+		return false
+	}
+	if !forwarder.replaceSource(track, receiver) {
+		return false
+	}
+	sender.log().Info("Replaced remote track", "id", track.ID(), "ssrc", uint32(track.SSRC()))
+	sender.note(TimelineTrackReplaced, track.ID())
+	return true
+}
+
+// sameCodec reports whether packets of b can go out on tracks bound to a.
+func sameCodec(a, b webrtc.RTPCodecCapability) bool {
+	return a.MimeType != "" && a.MimeType == b.MimeType && a.ClockRate == b.ClockRate && a.Channels == b.Channels
+}
+
+// replaceSource makes track the forwarder's source and reports false if the
+// forwarder has no remote track to replace.
+func (f *TrackForwarder) replaceSource(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) bool {
+	f.processMu.Lock()
+	if f.TrackRemote == nil {
+		f.processMu.Unlock()
+		return false
+	}
+	f.TrackRemote = track
+	f.audioLevelExtID = audioLevelExtensionID(receiver)
+	red, opus := negotiatedAudioPayloadTypes(receiver)
+	// Subscribers stay bound to the original payload type.
+	f.SetPayloadTypes(f.outputPayloadType, red, opus)
+	f.processMu.Unlock()
+
+	f.switchSource(func(buf []byte) (int, error) {
+		n, _, err := track.Read(buf)
+		return n, err
+	})
+	return true
+}
+
+// switchSource starts reading packets from read. The previous source's read
+// loop exits at its next packet or error without stopping the forwarder.
+func (f *TrackForwarder) switchSource(read func([]byte) (int, error)) {
+	f.processMu.Lock()
+	f.rewriter.rebase()
+	f.mu.Lock()
+	f.read = read
+	f.sourceGeneration++
+	generation, started := f.sourceGeneration, f.started
+	f.mu.Unlock()
+	f.processMu.Unlock()
+
+	if started {
+		go func() {
+			defer f.recoverPanic()
+			f.pump(read, generation)
+		}()
+	}
+}
+
+// currentGeneration returns the generation of the forwarder's current source.
+func (f *TrackForwarder) currentGeneration() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.sourceGeneration
+}
+
+// seqRewriter offsets RTP sequence numbers and timestamps so that a new source
+// continues where the previous one left off. Until the first rebase it leaves
+// packets untouched. It is owned by the forwarder's read loop.
+type seqRewriter struct {
+	started   bool
+	rebasing  bool
+	seqOffset uint16
+	tsOffset  uint32
+	lastSeq   uint16
+	lastTS    uint32
+	lastAt    time.Time
+}
+
+// rebase makes the next packet the first of a new source.
+func (r *seqRewriter) rebase() {
+	r.rebasing = r.started
+}
+
+// rewrite applies the offsets to packet in place. clockRate converts the time
+// since the previous source's last packet into a timestamp gap, so receivers
+// play the switch as the pause it was.
+func (r *seqRewriter) rewrite(packet []byte, clockRate uint32, now time.Time) {
+	if len(packet) < 8 {
+		return
+	}
+	seq := binary.BigEndian.Uint16(packet[2:4])
+	ts := binary.BigEndian.Uint32(packet[4:8])
+	if r.rebasing {
+		gap := uint32(now.Sub(r.lastAt) * time.Duration(clockRate) / time.Second)
+		if frame := uint32(defaultFrameDuration * time.Duration(clockRate) / time.Second); gap < frame {
+			gap = frame
+		}
+		r.seqOffset = r.lastSeq + 1 - seq
+		r.tsOffset = r.lastTS + gap - ts
+		r.rebasing = false
+	}
+	seq += r.seqOffset
+	ts += r.tsOffset
+	binary.BigEndian.PutUint16(packet[2:4], seq)
+	binary.BigEndian.PutUint32(packet[4:8], ts)
+	// Late packets must not pull the next source's base backwards.
+	if !r.started || int16(seq-r.lastSeq) > 0 {
+		r.lastSeq, r.lastTS, r.lastAt = seq, ts, now
+		r.started = true
+	}
+}
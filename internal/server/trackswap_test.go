@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+func rtpPacket(t *testing.T, seq uint16, ts uint32) []byte {
+	t.Helper()
+	raw, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: seq, Timestamp: ts}, Payload: []byte{0x78, 0x01}}).Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal packet: %v", err)
+	}
+	return raw
+}
+
+// chanSource reads packets from a channel and fails once it is closed.
+func chanSource(packets <-chan []byte) func([]byte) (int, error) {
+	return func(buf []byte) (int, error) {
+		packet, ok := <-packets
+		if !ok {
+			return 0, io.EOF
+		}
+		return copy(buf, packet), nil
+	}
+}
+
+func TestSeqRewriterContinuesAcrossSources(t *testing.T) {
+	var r seqRewriter
+	start := time.Now()
+	first := rtpPacket(t, 65535, 4000)
+	r.rewrite(first, 48000, start)
+	var header rtp.Header
+	if _, err := header.Unmarshal(first); err != nil || header.SequenceNumber != 65535 || header.Timestamp != 4000 {
+		t.Fatalf("first source was rewritten: seq %d ts %d", header.SequenceNumber, header.Timestamp)
+	}
+
+	r.rebase()
+	next := rtpPacket(t, 17, 90000)
+	r.rewrite(next, 48000, start.Add(100*time.Millisecond))
+	if _, err := header.Unmarshal(next); err != nil {
+		t.Fatal(err)
+	}
+	if header.SequenceNumber != 0 {
+		t.Fatalf("seq = %d, want 0 (wrapped from 65535)", header.SequenceNumber)
+	}
+	if header.Timestamp != 4000+4800 {
+		t.Fatalf("ts = %d, want %d (100ms later)", header.Timestamp, 4000+4800)
+	}
+
+	later := rtpPacket(t, 18, 90960)
+	r.rewrite(later, 48000, start.Add(120*time.Millisecond))
+	if _, err := header.Unmarshal(later); err != nil || header.SequenceNumber != 1 || header.Timestamp != 4000+4800+960 {
+		t.Fatalf("second packet of new source: seq %d ts %d", header.SequenceNumber, header.Timestamp)
+	}
+}
+
+func TestTrackForwarderSwitchSourceKeepsForwarding(t *testing.T) {
+	forwarder := newSyntheticForwarder(context.Background(), "sender", webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000})
+	defer forwarder.Stop()
+	stopped := make(chan struct{})
+	forwarder.onStop = func(error) { close(stopped) }
+	oldSource, newSource := make(chan []byte), make(chan []byte)
+	forwarder.read = chanSource(oldSource)
+	forwarded := make(chan uint16, 8)
+	forwarder.AddTap("test", func(packet []byte) {
+		var header rtp.Header
+		if _, err := header.Unmarshal(packet); err == nil {
+			forwarded <- header.SequenceNumber
+		}
+	})
+	go forwarder.Start()
+
+	expect := func(want uint16) {
+		t.Helper()
+		select {
+		case got := <-forwarded:
+			if got != want {
+				t.Fatalf("forwarded seq %d, want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("packet %d was not forwarded", want)
+		}
+	}
+
+	oldSource <- rtpPacket(t, 1000, 0)
+	expect(1000)
+	forwarder.switchSource(chanSource(newSource))
+	newSource <- rtpPacket(t, 7, 555)
+	expect(1001)
+
+	// The old track ending is not the forwarder ending.
+	close(oldSource)
+	newSource <- rtpPacket(t, 8, 1515)
+	expect(1002)
+	select {
+	case <-stopped:
+		t.Fatal("forwarder stopped when its replaced source ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(newSource)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("forwarder kept running after its current source ended")
+	}
+}
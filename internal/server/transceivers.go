@@ -0,0 +1,75 @@
+package server
+
+import (
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+// addOutTrack sends track to the receiver on an idle audio transceiver when
+// there is one, and on a new one only otherwise. removeOutTrack leaves a
+// transceiver idle whenever a publisher leaves, so in a long-lived room the
+// receiver's SDP stays as large as its busiest moment instead of growing by an
+// m-section per join.
+func (h *Handler) addOutTrack(receiver *Peer, track *webrtc.TrackLocalStaticRTP) (*webrtc.RTPSender, error) {
+	pc := receiver.PC
+	if transceiver := idleTransceiver(pc); transceiver != nil {
+		if sctp := pc.SCTP(); sctp != nil && sctp.Transport() != nil {
+			sender, err := h.WebRTCAPI.NewRTPSender(track, sctp.Transport())
+			if err != nil {
+				return nil, err
+			}
+			if err := transceiver.SetSender(sender, track); err != nil {
+				_ = sender.Stop()
+				return nil, err
+			}
+			// SetSender does not fire OnNegotiationNeeded the way AddTrack does.
+			h.requestNegotiation(receiver)
+			return sender, nil
+		}
+	}
+	return pc.AddTrack(track)
+}
+
+// idleTransceiver returns an audio transceiver with no track whose removal the
+// client has already accepted, or nil. A transceiver whose last negotiated
+// direction still sends must not be reused: the client would not see the
+// m-section stop and start again, so it would not fire ontrack for the new
+// stream and would play the new sender's audio as the old one's.
+func idleTransceiver(pc *webrtc.PeerConnection) *webrtc.RTPTransceiver {
+	current := pc.CurrentLocalDescription()
+	if current == nil {
+		return nil
+	}
+	parsed, err := current.Unmarshal()
+	if err != nil {
+		return nil
+	}
+	for _, transceiver := range pc.GetTransceivers() {
+		if transceiver.Kind() != webrtc.RTPCodecTypeAudio || transceiver.Sender() != nil || transceiver.Mid() == "" {
+			continue
+		}
+		switch transceiver.Direction() {
+		case webrtc.RTPTransceiverDirectionRecvonly, webrtc.RTPTransceiverDirectionInactive:
+		default:
+			continue
+		}
+		if !negotiatedSending(parsed, transceiver.Mid()) {
+			return transceiver
+		}
+	}
+	return nil
+}
+
+// negotiatedSending reports whether the m-section with mid was negotiated with
+// us sending on it.
+func negotiatedSending(desc *sdp.SessionDescription, mid string) bool {
+	for _, media := range desc.MediaDescriptions {
+		if value, ok := media.Attribute(sdp.AttrKeyMID); !ok || value != mid {
+			continue
+		}
+		_, sendrecv := media.Attribute(webrtc.RTPTransceiverDirectionSendrecv.String())
+		_, sendonly := media.Attribute(webrtc.RTPTransceiverDirectionSendonly.String())
+		return sendrecv || sendonly
+	}
+	return false
+}
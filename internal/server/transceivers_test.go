@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// negotiateFromServer runs one server-offered exchange between server and client.
+func negotiateFromServer(t *testing.T, server, client *webrtc.PeerConnection) {
+	t.Helper()
+	offer, err := server.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("create offer: %v", err)
+	}
+	if err := server.SetLocalDescription(offer); err != nil {
+		t.Fatalf("set server offer: %v", err)
+	}
+	if err := client.SetRemoteDescription(offer); err != nil {
+		t.Fatalf("client accept offer: %v", err)
+	}
+	answer, err := client.CreateAnswer(nil)
+	if err != nil {
+		t.Fatalf("create answer: %v", err)
+	}
+	if err := client.SetLocalDescription(answer); err != nil {
+		t.Fatalf("set client answer: %v", err)
+	}
+	if err := server.SetRemoteDescription(answer); err != nil {
+		t.Fatalf("server accept answer: %v", err)
+	}
+}
+
+func TestOutTracksReuseNegotiatedIdleTransceivers(t *testing.T) {
+	h, _, peer := newFuzzPeer(t)
+	// The test drives every exchange itself.
+	peer.NegotiationMu.Lock()
+	peer.NegotiationInProgress = true
+	peer.NegotiationMu.Unlock()
+
+	clientPC, err := newTestAPI(t).NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientPC.Close()
+	if _, err := clientPC.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := clientPC.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clientPC.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.PC.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := peer.PC.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.PC.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	if err := clientPC.SetRemoteDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+
+	newTrack := func(i int) *webrtc.TrackLocalStaticRTP {
+		track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, "audio", fmt.Sprintf("publisher-%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return track
+	}
+
+	// Publishers joining and leaving one at a time keep using the client's own
+	// audio m-section, which the server was only receiving on.
+	for i := 0; i < 5; i++ {
+		sender, err := h.addOutTrack(peer, newTrack(i))
+		if err != nil {
+			t.Fatalf("add track %d: %v", i, err)
+		}
+		negotiateFromServer(t, peer.PC, clientPC)
+		if err := peer.PC.RemoveTrack(sender); err != nil {
+			t.Fatalf("remove track %d: %v", i, err)
+		}
+		negotiateFromServer(t, peer.PC, clientPC)
+	}
+	if got := len(peer.PC.GetTransceivers()); got != 1 {
+		t.Fatalf("transceivers = %d after five join/leave cycles, want 1", got)
+	}
+
+	// A removal the client has not accepted yet must not be reused.
+	sender, err := h.addOutTrack(peer, newTrack(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	negotiateFromServer(t, peer.PC, clientPC)
+	if err := peer.PC.RemoveTrack(sender); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.addOutTrack(peer, newTrack(6)); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(peer.PC.GetTransceivers()); got != 2 {
+		t.Fatalf("transceivers = %d after reusing an unnegotiated removal, want 2", got)
+	}
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+
+	"sigmartc/internal/egress"
+	"sigmartc/internal/logger"
+	"sigmartc/internal/stt"
+	"sigmartc/pkg/protocol"
+)
+
+const (
+	transcriptTapID = "transcript"
+	// sttSampleRate is what utterances are resampled to; speech models expect 16kHz mono.
+	sttSampleRate = 16000
+	// speechRMS is the frame energy (int16 RMS) treated as speech.
+	speechRMS = 400
+	// utteranceSilence ends an utterance after this much trailing silence.
+	utteranceSilence = 700 * time.Millisecond
+	// minUtterance drops clicks and coughs shorter than this.
+	minUtterance = 300 * time.Millisecond
+	// maxUtterance cuts long monologues so captions keep up.
+	maxUtterance = 15 * time.Second
+	// transcriptQueueSize bounds utterances waiting for the backend; more are dropped.
+	transcriptQueueSize = 32
+	sttTimeout          = 30 * time.Second
+	sttFrameDuration    = 20 * time.Millisecond
+	// speakerIdleTimeout closes the decoder of a publisher that stopped sending,
+	// e.g. because it left, matching the egress mixer.
+	speakerIdleTimeout = 5 * time.Second
+	// maxTranscriptEntries bounds a session's stored transcript.
+	maxTranscriptEntries = 10000
+)
+
+var (
+	errSTTDisabled          = errors.New("transcription is disabled")
+	errTranscriptionRunning = errors.New("transcription already running for this room")
+	errNoTranscript         = errors.New("room has no transcript")
+)
+
+// TranscriptEntry is one transcribed utterance.
+type TranscriptEntry = protocol.TranscriptEntry
+
+// transcription feeds each publisher's decoded audio to the STT backend, one
+// utterance at a time, and keeps the session's transcript. The last session stays
+// on the room after it stops so its transcript can still be downloaded.
+type transcription struct {
+	room        *Room
+	backend     stt.Transcriber
+	ffmpegPath  string
+	startedAt   time.Time
+	ctx         context.Context
+	cancel      context.CancelFunc
+	utterances  chan utterance
+	stoppedOnce sync.Once
+
+	mu       sync.Mutex
+	speakers map[string]*speakerStream
+	entries  []TranscriptEntry
+	stopped  bool
+}
+
+type utterance struct {
+	senderID string
+	at       time.Time
+	pcm      []int16
+}
+
+// speakerStream decodes one publisher and cuts its audio into utterances.
+type speakerStream struct {
+	decoder *egress.Decoder
+	segmenter
+}
+
+// StartTranscription begins transcribing the room's publishers.
+func (h *Handler) StartTranscription(roomUUID string) error {
+	if h.STT == nil || h.FFmpegPath == "" {
+		return errSTTDisabled
+	}
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return errRoomNotFound
+	}
+	if room.IsE2EE() {
+		return errE2EERoom
+	}
+
+	room.egressMu.Lock()
+	if room.transcript != nil && !room.transcript.isStopped() {
+		room.egressMu.Unlock()
+		return errTranscriptionRunning
+	}
+	t := newTranscription(room, h.STT, h.FFmpegPath)
+	room.transcript = t
+	room.ForwardersMu.RLock()
+	for senderID, forwarder := range room.Forwarders {
+		t.attach(senderID, forwarder)
+	}
+	room.ForwardersMu.RUnlock()
+	room.egressMu.Unlock()
+
+	go t.run()
+	go t.reap()
+	logger.LogEvent("TRANSCRIPTION_START", slog.String("uuid", roomUUID))
+	h.announceAsync(roomUUID, "This room is now being transcribed.")
+	return nil
+}
+
+// StopTranscription stops the room's transcription and reports whether one was running.
+func (h *Handler) StopTranscription(roomUUID string) bool {
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return false
+	}
+	return room.stopTranscription()
+}
+
+// Transcript returns the room's current or most recent transcription session.
+func (h *Handler) Transcript(roomUUID string) (started time.Time, entries []TranscriptEntry, err error) {
+	h.RoomManager.Lock.RLock()
+	room := h.RoomManager.Rooms[roomUUID]
+	h.RoomManager.Lock.RUnlock()
+	if room == nil {
+		return time.Time{}, nil, errRoomNotFound
+	}
+	room.egressMu.Lock()
+	t := room.transcript
+	room.egressMu.Unlock()
+	if t == nil {
+		return time.Time{}, nil, errNoTranscript
+	}
+	return t.startedAt, t.Entries(), nil
+}
+
+func (r *Room) stopTranscription() bool {
+	r.egressMu.Lock()
+	t := r.transcript
+	r.egressMu.Unlock()
+	if t == nil || t.isStopped() {
+		return false
+	}
+	t.stop()
+	r.ForwardersMu.RLock()
+	for _, forwarder := range r.Forwarders {
+		forwarder.RemoveTap(transcriptTapID)
+	}
+	r.ForwardersMu.RUnlock()
+	logger.LogEvent("TRANSCRIPTION_STOP", slog.String("uuid", r.UUID))
+	return true
+}
+
+// attachTranscription taps a newly published track into the room's running transcription.
+// Callers hold egressMu.
+func (r *Room) attachTranscription(senderID string, forwarder *TrackForwarder) {
+	if r.transcript != nil && !r.transcript.isStopped() {
+		r.transcript.attach(senderID, forwarder)
+	}
+}
+
+func newTranscription(room *Room, backend stt.Transcriber, ffmpegPath string) *transcription {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &transcription{
+		room:       room,
+		backend:    backend,
+		ffmpegPath: ffmpegPath,
+		startedAt:  time.Now(),
+		ctx:        ctx,
+		cancel:     cancel,
+		utterances: make(chan utterance, transcriptQueueSize),
+		speakers:   make(map[string]*speakerStream),
+	}
+}
+
+// attach taps a publisher. Soundboard clips and announcements are not speech
+// from the room, so they are left out.
+func (t *transcription) attach(senderID string, forwarder *TrackForwarder) {
+	if senderID == soundboardSenderID || senderID == announcementSenderID {
+		return
+	}
+	forwarder.AddTap(transcriptTapID, egressTap(t, senderID, forwarder))
+}
+
+// WriteRTP feeds an Opus packet from a publisher, starting its decoder on first use.
+func (t *transcription) WriteRTP(senderID string, packet *rtp.Packet) {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	speaker := t.speakers[senderID]
+	if speaker == nil {
+		decoder, err := egress.NewDecoder(t.ffmpegPath, func(frame []int16) { t.pushFrame(senderID, frame) })
+		if err != nil {
+			t.mu.Unlock()
+			slog.Warn("Transcription decoder failed to start", "uuid", t.room.UUID, "sender_id", senderID, "err", err)
+			return
+		}
+		speaker = &speakerStream{decoder: decoder}
+		t.speakers[senderID] = speaker
+	}
+	t.mu.Unlock()
+	speaker.decoder.WriteRTP(packet)
+}
+
+func (t *transcription) pushFrame(senderID string, frame []int16) {
+	t.mu.Lock()
+	speaker := t.speakers[senderID]
+	if speaker == nil {
+		t.mu.Unlock()
+		return
+	}
+	pcm := speaker.push(downsampleFrame(frame))
+	t.mu.Unlock()
+	if pcm != nil {
+		t.enqueue(senderID, pcm)
+	}
+}
+
+func (t *transcription) enqueue(senderID string, pcm []int16) {
+	at := time.Now().Add(-time.Duration(len(pcm)) * time.Second / sttSampleRate)
+	select {
+	case t.utterances <- utterance{senderID: senderID, at: at, pcm: pcm}:
+	default:
+		slog.Warn("Transcription backlog full, dropping utterance", "uuid", t.room.UUID, "sender_id", senderID)
+	}
+}
+
+// run sends utterances to the backend in order, so captions arrive in the order spoken.
+func (t *transcription) run() {
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case u := <-t.utterances:
+			t.transcribe(u)
+		}
+	}
+}
+
+func (t *transcription) transcribe(u utterance) {
+	ctx, cancel := context.WithTimeout(t.ctx, sttTimeout)
+	defer cancel()
+	text, err := t.backend.Transcribe(ctx, stt.EncodeWAV(u.pcm, sttSampleRate))
+	if err != nil {
+		if t.ctx.Err() == nil {
+			slog.Warn("Transcription failed", "uuid", t.room.UUID, "sender_id", u.senderID, "err", err)
+		}
+		return
+	}
+	if text = strings.TrimSpace(text); text == "" {
+		return
+	}
+	entry := TranscriptEntry{At: u.at, PeerID: u.senderID, Text: text}
+	t.room.Lock.RLock()
+	if peer := t.room.Peers[u.senderID]; peer != nil {
+		entry.Name = peer.Name
+	}
+	t.room.Lock.RUnlock()
+
+	t.mu.Lock()
+	if len(t.entries) < maxTranscriptEntries {
+		t.entries = append(t.entries, entry)
+	}
+	t.mu.Unlock()
+	t.room.Broadcast("", map[string]any{
+		"type":    "transcript",
+		"peer_id": entry.PeerID,
+		"name":    entry.Name,
+		"text":    entry.Text,
+		"at":      entry.At,
+	})
+}
+
+// reap closes decoders of publishers that stopped sending, flushing whatever
+// they were saying.
+func (t *transcription) reap() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.mu.Lock()
+			for senderID, speaker := range t.speakers {
+				if speaker.decoder.Idle(now) > speakerIdleTimeout {
+					speaker.decoder.Close()
+					delete(t.speakers, senderID)
+					if pcm := speaker.flush(); pcm != nil {
+						t.enqueue(senderID, pcm)
+					}
+				}
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+func (t *transcription) stop() {
+	t.stoppedOnce.Do(func() {
+		t.mu.Lock()
+		t.stopped = true
+		for senderID, speaker := range t.speakers {
+			speaker.decoder.Close()
+			delete(t.speakers, senderID)
+		}
+		t.mu.Unlock()
+		t.cancel()
+	})
+}
+
+func (t *transcription) isStopped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stopped
+}
+
+// Entries returns a copy of the transcript so far.
+func (t *transcription) Entries() []TranscriptEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TranscriptEntry(nil), t.entries...)
+}
+
+// formatTranscript renders entries as plain text, one line per utterance.
+func formatTranscript(entries []TranscriptEntry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		name := e.Name
+		if name == "" {
+			name = e.PeerID
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s\n", e.At.UTC().Format(time.TimeOnly), name, e.Text)
+	}
+	return b.String()
+}
+
+// downsampleFrame turns a 20ms 48kHz stereo frame into 16kHz mono by averaging
+// each three stereo samples.
+func downsampleFrame(frame []int16) []int16 {
+	out := make([]int16, len(frame)/6)
+	for i := range out {
+		var sum int32
+		for _, s := range frame[i*6 : i*6+6] {
+			sum += int32(s)
+		}
+		out[i] = int16(sum / 6)
+	}
+	return out
+}
+
+// segmenter accumulates 20ms 16kHz mono frames into utterances using a simple
+// energy threshold: speech starts an utterance, which ends after utteranceSilence
+// of quiet or at maxUtterance.
+type segmenter struct {
+	pcm     []int16
+	prev    []int16 // last quiet frame, kept so word onsets are not clipped
+	speech  time.Duration
+	silence time.Duration
+}
+
+// push adds a frame and returns a finished utterance, if any.
+func (s *segmenter) push(frame []int16) []int16 {
+	loud := frameRMS(frame) >= speechRMS
+	if s.pcm == nil {
+		if !loud {
+			s.prev = frame
+			return nil
+		}
+		s.pcm = append(append([]int16(nil), s.prev...), frame...)
+		s.speech, s.silence = sttFrameDuration, 0
+		return nil
+	}
+	s.pcm = append(s.pcm, frame...)
+	if loud {
+		s.speech += sttFrameDuration
+		s.silence = 0
+	} else {
+		s.silence += sttFrameDuration
+	}
+	if s.silence >= utteranceSilence || time.Duration(len(s.pcm))*time.Second/sttSampleRate >= maxUtterance {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush ends the current utterance, returning nil if it held too little speech.
+func (s *segmenter) flush() []int16 {
+	pcm, speech := s.pcm, s.speech
+	s.pcm, s.prev, s.speech, s.silence = nil, nil, 0, 0
+	if speech < minUtterance {
+		return nil
+	}
+	return pcm
+}
+
+func frameRMS(frame []int16) float64 {
+	if len(frame) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range frame {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(frame)))
+}
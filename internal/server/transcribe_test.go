@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubTranscriber struct {
+	text string
+}
+
+func (s *stubTranscriber) Transcribe(context.Context, []byte) (string, error) {
+	return s.text, nil
+}
+
+func TestAdminTranscriptionLifecycle(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	handler.FFmpegPath = "ffmpeg"
+	room := handler.RoomManager.GetOrCreateRoom("room-a")
+
+	if got := adminRequest(t, handler, http.MethodPost, "action=transcribe_start&room=room-a").Code; got != http.StatusNotImplemented {
+		t.Fatalf("without STT: status = %d", got)
+	}
+	handler.STT = &stubTranscriber{text: " hello everyone "}
+
+	cases := []struct {
+		method, query string
+		want          int
+	}{
+		{http.MethodGet, "action=transcribe_start&room=room-a", http.StatusMethodNotAllowed},
+		{http.MethodGet, "action=transcript&room=room-a", http.StatusNotFound},
+		{http.MethodPost, "action=transcribe_start&room=missing", http.StatusNotFound},
+		{http.MethodPost, "action=transcribe_start&room=room-a", http.StatusOK},
+		{http.MethodPost, "action=transcribe_start&room=room-a", http.StatusConflict},
+	}
+	for _, tc := range cases {
+		if got := adminRequest(t, handler, tc.method, tc.query).Code; got != tc.want {
+			t.Fatalf("%s %s: status = %d, want %d", tc.method, tc.query, got, tc.want)
+		}
+	}
+
+	room.egressMu.Lock()
+	session := room.transcript
+	room.egressMu.Unlock()
+	session.transcribe(utterance{senderID: "peer-1", at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), pcm: make([]int16, sttSampleRate)})
+
+	if got := adminRequest(t, handler, http.MethodPost, "action=transcribe_stop&room=room-a").Code; got != http.StatusOK {
+		t.Fatalf("stop: status = %d", got)
+	}
+	if got := adminRequest(t, handler, http.MethodPost, "action=transcribe_stop&room=room-a").Code; got != http.StatusNotFound {
+		t.Fatalf("second stop: status = %d", got)
+	}
+
+	// The stopped session's transcript stays downloadable.
+	rec := adminRequest(t, handler, http.MethodGet, "action=transcript&room=room-a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("transcript: status = %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != "[03:04:05] peer-1: hello everyone\n" {
+		t.Fatalf("transcript = %q", got)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "transcript-room-a-") {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+
+	rec = adminRequest(t, handler, http.MethodGet, "action=transcript&room=room-a&format=json")
+	var body struct {
+		Entries []TranscriptEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Entries) != 1 || body.Entries[0].Text != "hello everyone" {
+		t.Fatalf("entries = %+v", body.Entries)
+	}
+
+	// A new session starts with an empty transcript.
+	if got := adminRequest(t, handler, http.MethodPost, "action=transcribe_start&room=room-a").Code; got != http.StatusOK {
+		t.Fatalf("restart: status = %d", got)
+	}
+	if got := adminRequest(t, handler, http.MethodGet, "action=transcript&room=room-a").Body.String(); got != "" {
+		t.Fatalf("restarted transcript = %q", got)
+	}
+	room.stopTranscription()
+}
+
+func TestSegmenterCutsUtterancesOnSilence(t *testing.T) {
+	frame := func(amplitude int16) []int16 {
+		f := make([]int16, sttSampleRate/50)
+		for i := range f {
+			if i%2 == 0 {
+				f[i] = amplitude
+			} else {
+				f[i] = -amplitude
+			}
+		}
+		return f
+	}
+	quiet, loud := frame(0), frame(2000)
+
+	var s segmenter
+	var got [][]int16
+	push := func(f []int16, n int) {
+		for range n {
+			if pcm := s.push(f); pcm != nil {
+				got = append(got, pcm)
+			}
+		}
+	}
+
+	// A short click is dropped once the silence ends it.
+	push(quiet, 5)
+	push(loud, 5)
+	push(quiet, 40)
+	if len(got) != 0 {
+		t.Fatalf("click produced %d utterances", len(got))
+	}
+
+	// One second of speech followed by enough silence is one utterance,
+	// including the quiet frame before the onset.
+	push(loud, 50)
+	push(quiet, int(utteranceSilence/sttFrameDuration))
+	if len(got) != 1 {
+		t.Fatalf("got %d utterances, want 1", len(got))
+	}
+	if want := (1 + 50 + int(utteranceSilence/sttFrameDuration)) * len(loud); len(got[0]) != want {
+		t.Fatalf("utterance has %d samples, want %d", len(got[0]), want)
+	}
+
+	// Continuous speech is cut at maxUtterance.
+	push(loud, int(maxUtterance/sttFrameDuration))
+	if len(got) != 2 {
+		t.Fatalf("got %d utterances after long speech, want 2", len(got))
+	}
+}
+
+func TestDownsampleFrame(t *testing.T) {
+	frame := make([]int16, 960*2)
+	for i := range frame {
+		frame[i] = 600
+	}
+	out := downsampleFrame(frame)
+	if len(out) != sttSampleRate/50 {
+		t.Fatalf("len = %d, want %d", len(out), sttSampleRate/50)
+	}
+	if out[0] != 600 {
+		t.Fatalf("sample = %d, want 600", out[0])
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"sigmartc/internal/eventdb"
+)
+
+// UsageSession is one stretch of a room being occupied, from the first peer
+// joining an empty room until the last one leaves or the room is closed.
+type UsageSession struct {
+	Room        string    `json:"room"`
+	StartedAt   time.Time `json:"started_at"`
+	EndedAt     time.Time `json:"ended_at"`
+	PeakPeers   int       `json:"peak_peers"`
+	PeerSeconds float64   `json:"peer_seconds"`
+	BytesIn     uint64    `json:"bytes_in"`
+	BytesOut    uint64    `json:"bytes_out"`
+}
+
+// RoomUsage aggregates a room's sessions over a report range.
+type RoomUsage struct {
+	Room               string  `json:"room"`
+	Sessions           int     `json:"sessions"`
+	DurationSeconds    float64 `json:"duration_seconds"`
+	PeakPeers          int     `json:"peak_peers"`
+	ParticipantMinutes float64 `json:"participant_minutes"`
+	BytesIn            uint64  `json:"bytes_in"`
+	BytesOut           uint64  `json:"bytes_out"`
+}
+
+// UsageLog appends finished room sessions as JSON lines, or stores them in the
+// event database when opened with NewUsageLogDB, so usage reports survive restarts
+// and are not limited to the live counters.
+type UsageLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	db   *eventdb.DB
+}
+
+func NewUsageLog(path string) (*UsageLog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &UsageLog{path: path, file: file}, nil
+}
+
+// NewUsageLogDB stores sessions in db. Closing the log does not close db.
+func NewUsageLogDB(db *eventdb.DB) *UsageLog {
+	return &UsageLog{db: db}
+}
+
+// Record appends a session. It is safe to call on a nil log.
+func (u *UsageLog) Record(session UsageSession) {
+	if u == nil {
+		return
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		slog.Error("Failed to marshal usage session", "err", err)
+		return
+	}
+	if u.db != nil {
+		if err := u.db.AddUsage(session.Room, session.StartedAt, data); err != nil {
+			slog.Error("Failed to write usage session", "uuid", session.Room, "err", err)
+		}
+		return
+	}
+	data = append(data, '\n')
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, err := u.file.Write(data); err != nil {
+		slog.Error("Failed to write usage session", "uuid", session.Room, "err", err)
+	}
+}
+
+// Sessions returns the sessions that started in [from, until), optionally for one room.
+func (u *UsageLog) Sessions(room string, from, until time.Time) ([]UsageSession, error) {
+	if u == nil {
+		return nil, nil
+	}
+	if u.db != nil {
+		return u.sessionsDB(room, from, until)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	file, err := os.Open(u.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sessions []UsageSession
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var session UsageSession
+		if err := json.Unmarshal(scanner.Bytes(), &session); err != nil {
+			continue
+		}
+		if room != "" && session.Room != room {
+			continue
+		}
+		if session.StartedAt.Before(from) || !session.StartedAt.Before(until) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, scanner.Err()
+}
+
+func (u *UsageLog) sessionsDB(room string, from, until time.Time) ([]UsageSession, error) {
+	rows, err := u.db.Usage(room, from, until)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []UsageSession
+	for _, row := range rows {
+		var session UsageSession
+		if err := json.Unmarshal(row, &session); err == nil {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+// Close closes the usage file.
+func (u *UsageLog) Close() error {
+	if u == nil || u.db != nil {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.file.Close()
+}
+
+// summarizeUsage aggregates sessions per room, busiest (most participant-minutes) first.
+func summarizeUsage(sessions []UsageSession) []RoomUsage {
+	byRoom := make(map[string]*RoomUsage)
+	for _, s := range sessions {
+		usage := byRoom[s.Room]
+		if usage == nil {
+			usage = &RoomUsage{Room: s.Room}
+			byRoom[s.Room] = usage
+		}
+		usage.Sessions++
+		usage.DurationSeconds += s.EndedAt.Sub(s.StartedAt).Seconds()
+		usage.PeakPeers = max(usage.PeakPeers, s.PeakPeers)
+		usage.ParticipantMinutes += s.PeerSeconds / 60
+		usage.BytesIn += s.BytesIn
+		usage.BytesOut += s.BytesOut
+	}
+	report := make([]RoomUsage, 0, len(byRoom))
+	for _, usage := range byRoom {
+		report = append(report, *usage)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].ParticipantMinutes != report[j].ParticipantMinutes {
+			return report[i].ParticipantMinutes > report[j].ParticipantMinutes
+		}
+		return report[i].Room < report[j].Room
+	})
+	return report
+}
+
+// writeUsageCSV writes a report with a header row.
+func writeUsageCSV(w io.Writer, report []RoomUsage) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"room", "sessions", "duration_seconds", "peak_peers", "participant_minutes", "bytes_in", "bytes_out"})
+	for _, u := range report {
+		out.Write([]string{
+			u.Room,
+			strconv.Itoa(u.Sessions),
+			strconv.FormatFloat(u.DurationSeconds, 'f', 0, 64),
+			strconv.Itoa(u.PeakPeers),
+			strconv.FormatFloat(u.ParticipantMinutes, 'f', 1, 64),
+			strconv.FormatUint(u.BytesIn, 10),
+			strconv.FormatUint(u.BytesOut, 10),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// usageTracker follows the room's current session; it is guarded by Room.Lock.
+type usageTracker struct {
+	startedAt    time.Time
+	joined       map[string]time.Time
+	peak         int
+	peerSeconds  float64
+	bytesAtStart BandwidthStats
+}
+
+// usageJoin starts counting a peer that entered the room, opening a session if
+// it was empty. Callers hold r.Lock.
+func (r *Room) usageJoin(peerID string, now time.Time) {
+	u := &r.usage
+	if u.joined == nil {
+		u.joined = make(map[string]time.Time)
+	}
+	if len(u.joined) == 0 {
+		u.startedAt = now
+		u.peak = 0
+		u.peerSeconds = 0
+		u.bytesAtStart = r.bandwidth.snapshot()
+	}
+	if _, ok := u.joined[peerID]; !ok {
+		u.joined[peerID] = now
+	}
+	u.peak = max(u.peak, len(u.joined))
+}
+
+// usageLeave stops counting a peer and returns the finished session when it
+// was the last one. Callers hold r.Lock.
+func (r *Room) usageLeave(peerID string, now time.Time) *UsageSession {
+	u := &r.usage
+	joinedAt, ok := u.joined[peerID]
+	if !ok {
+		return nil
+	}
+	delete(u.joined, peerID)
+	u.peerSeconds += now.Sub(joinedAt).Seconds()
+	if len(u.joined) > 0 {
+		return nil
+	}
+	return r.usageSession(now)
+}
+
+// usageEnd closes the current session, if any, e.g. when the room is closed
+// before its peers' sockets have been cleaned up. Callers hold r.Lock.
+func (r *Room) usageEnd(now time.Time) *UsageSession {
+	u := &r.usage
+	if len(u.joined) == 0 {
+		return nil
+	}
+	for peerID, joinedAt := range u.joined {
+		u.peerSeconds += now.Sub(joinedAt).Seconds()
+		delete(u.joined, peerID)
+	}
+	return r.usageSession(now)
+}
+
+func (r *Room) usageSession(now time.Time) *UsageSession {
+	u := &r.usage
+	bytes := r.bandwidth.snapshot()
+	return &UsageSession{
+		Room:        r.UUID,
+		StartedAt:   u.startedAt.UTC(),
+		EndedAt:     now.UTC(),
+		PeakPeers:   u.peak,
+		PeerSeconds: u.peerSeconds,
+		BytesIn:     bytes.BytesIn - u.bytesAtStart.BytesIn,
+		BytesOut:    bytes.BytesOut - u.bytesAtStart.BytesOut,
+	}
+}
+
+// recordUsage stores a finished session and charges it to the minutes quotas;
+// nil sessions are ignored.
+func (rm *RoomManager) recordUsage(session *UsageSession) {
+	if session != nil {
+		rm.Usage.Record(*session)
+		rm.Minutes.charge(session.Room, rm.Tenants.forRoom(session.Room), session.PeerSeconds, session.EndedAt)
+	}
+}
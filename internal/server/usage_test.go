@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoomUsageSessionTracksOccupancy(t *testing.T) {
+	room := &Room{UUID: "room-a"}
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	room.usageJoin("a", start)
+	room.usageJoin("b", start.Add(time.Minute))
+	room.bandwidth.in.Add(100)
+	room.bandwidth.out.Add(300)
+	if s := room.usageLeave("a", start.Add(2*time.Minute)); s != nil {
+		t.Fatalf("session ended while b was still in the room: %+v", s)
+	}
+	room.usageJoin("c", start.Add(3*time.Minute))
+	if s := room.usageLeave("missing", start.Add(4*time.Minute)); s != nil {
+		t.Fatalf("unknown peer ended the session: %+v", s)
+	}
+	room.usageLeave("b", start.Add(4*time.Minute))
+	s := room.usageLeave("c", start.Add(5*time.Minute))
+	if s == nil {
+		t.Fatal("last leave did not end the session")
+	}
+	want := UsageSession{Room: "room-a", StartedAt: start, EndedAt: start.Add(5 * time.Minute), PeakPeers: 2, PeerSeconds: 7 * 60, BytesIn: 100, BytesOut: 300}
+	if *s != want {
+		t.Fatalf("session = %+v, want %+v", *s, want)
+	}
+
+	// The next session counts only its own bytes.
+	room.usageJoin("d", start.Add(time.Hour))
+	room.bandwidth.out.Add(50)
+	s = room.usageEnd(start.Add(time.Hour + time.Minute))
+	if s == nil || s.PeakPeers != 1 || s.PeerSeconds != 60 || s.BytesIn != 0 || s.BytesOut != 50 {
+		t.Fatalf("second session = %+v", s)
+	}
+	if room.usageEnd(start.Add(2*time.Hour)) != nil {
+		t.Fatal("ending an empty room produced a session")
+	}
+}
+
+func TestAdminUsageReport(t *testing.T) {
+	handler := newTestAdminHandler(t)
+	get := func(query string) *httptest.ResponseRecorder {
+		return adminRequest(t, handler, http.MethodGet, query)
+	}
+	if got := get("action=usage_report").Code; got != http.StatusNotImplemented {
+		t.Fatalf("without usage log: status = %d", got)
+	}
+
+	usageLog, err := NewUsageLog(filepath.Join(t.TempDir(), "usage.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer usageLog.Close()
+	handler.RoomManager.Usage = usageLog
+
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	usageLog.Record(UsageSession{Room: "room-a", StartedAt: day, EndedAt: day.Add(time.Hour), PeakPeers: 3, PeerSeconds: 7200, BytesIn: 10, BytesOut: 20})
+	usageLog.Record(UsageSession{Room: "room-a", StartedAt: day.Add(2 * time.Hour), EndedAt: day.Add(3 * time.Hour), PeakPeers: 5, PeerSeconds: 3600, BytesIn: 1, BytesOut: 2})
+	usageLog.Record(UsageSession{Room: "room-b", StartedAt: day, EndedAt: day.Add(time.Minute), PeakPeers: 1, PeerSeconds: 60})
+	usageLog.Record(UsageSession{Room: "room-a", StartedAt: day.AddDate(0, 0, 1), EndedAt: day.AddDate(0, 0, 1).Add(time.Hour), PeakPeers: 9, PeerSeconds: 60})
+
+	for _, query := range []string{"from=yesterday", "to=2026-13-01", "from=2026-03-02&to=2026-03-01"} {
+		if got := get("action=usage_report&" + query).Code; got != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d", query, got)
+		}
+	}
+
+	rec := get("action=usage_report&from=2026-03-01&to=2026-03-02")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var body struct {
+		Rooms []RoomUsage `json:"rooms"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := []RoomUsage{
+		{Room: "room-a", Sessions: 2, DurationSeconds: 7200, PeakPeers: 5, ParticipantMinutes: 180, BytesIn: 11, BytesOut: 22},
+		{Room: "room-b", Sessions: 1, DurationSeconds: 60, PeakPeers: 1, ParticipantMinutes: 1},
+	}
+	if len(body.Rooms) != len(want) || body.Rooms[0] != want[0] || body.Rooms[1] != want[1] {
+		t.Fatalf("rooms = %+v, want %+v", body.Rooms, want)
+	}
+
+	rec = get("action=usage_report&from=2026-03-01&to=2026-03-02&room=room-b&format=csv")
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	wantCSV := "room,sessions,duration_seconds,peak_peers,participant_minutes,bytes_in,bytes_out\nroom-b,1,60,1,1.0,0,0\n"
+	if got := rec.Body.String(); got != wantCSV {
+		t.Fatalf("csv = %q, want %q", got, wantCSV)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"sigmartc/pkg/protocol"
+)
+
+const (
+	// maxSignalingMessageBytes is the WebSocket read limit; larger frames close
+	// the connection with 1009 (message too big).
+	maxSignalingMessageBytes = 64 << 10
+	maxSDPBytes              = 48 << 10
+	maxCandidateBytes        = 1024
+)
+
+var errMalformedMessage = errors.New("malformed signaling message")
+
+// signalingFields lists the fields each client message type may carry besides
+// "type", from the message types in pkg/protocol.
+var signalingFields = clientMessageFields()
+
+// candidateFields are the RTCIceCandidateInit members browsers send.
+var candidateFields = protocol.FieldNames(protocol.ICECandidate{})
+
+func clientMessageFields() map[string][]string {
+	fields := make(map[string][]string)
+	for _, spec := range protocol.Messages {
+		if spec.Direction&protocol.ClientToServer != 0 {
+			fields[spec.Type] = spec.Fields()
+		}
+	}
+	return fields
+}
+
+// parseSignalingMessage decodes a client message, JSON from text frames or
+// MessagePack from binary ones, and checks it against signalingFields and the
+// SDP/candidate size limits.
+func parseSignalingMessage(data []byte, binary bool) (map[string]any, error) {
+	var msg map[string]any
+	if binary {
+		decoded, err := unmarshalMsgpack(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errMalformedMessage, err)
+		}
+		msg, _ = decoded.(map[string]any)
+	} else if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, errMalformedMessage
+	}
+	if msg == nil {
+		return nil, errMalformedMessage
+	}
+	t, ok := msg["type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing type", errMalformedMessage)
+	}
+	allowed, known := signalingFields[t]
+	if !known {
+		return nil, fmt.Errorf("%w: unknown type %q", errMalformedMessage, t)
+	}
+	if err := checkFields(msg, append([]string{"type"}, allowed...)); err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case "offer", "answer":
+		sdp, ok := msg["sdp"].(string)
+		if !ok || sdp == "" {
+			return nil, fmt.Errorf("%w: missing sdp", errMalformedMessage)
+		}
+		if len(sdp) > maxSDPBytes {
+			return nil, fmt.Errorf("%w: sdp exceeds %d bytes", errMalformedMessage, maxSDPBytes)
+		}
+	case "candidate":
+		candidate, ok := msg["candidate"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: candidate must be an object", errMalformedMessage)
+		}
+		if err := checkFields(candidate, candidateFields); err != nil {
+			return nil, err
+		}
+		if s, _ := candidate["candidate"].(string); len(s) > maxCandidateBytes {
+			return nil, fmt.Errorf("%w: candidate exceeds %d bytes", errMalformedMessage, maxCandidateBytes)
+		}
+	case "e2ee_key":
+		// The payload is opaque to the server; only its type and size are checked.
+		payload, ok := msg["payload"].(string)
+		if !ok || payload == "" {
+			return nil, fmt.Errorf("%w: payload must be a non-empty string", errMalformedMessage)
+		}
+		if len(payload) > maxE2EEPayloadBytes {
+			return nil, fmt.Errorf("%w: payload exceeds %d bytes", errMalformedMessage, maxE2EEPayloadBytes)
+		}
+	}
+	return msg, nil
+}
+
+func checkFields(obj map[string]any, allowed []string) error {
+	for key := range obj {
+		if !slices.Contains(allowed, key) {
+			return fmt.Errorf("%w: unexpected field %q", errMalformedMessage, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseSignalingMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		ok   bool
+	}{
+		{"offer", `{"type":"offer","sdp":"v=0"}`, true},
+		{"candidate", `{"type":"candidate","candidate":{"candidate":"candidate:1 1 udp 1 1.2.3.4 5 typ host","sdpMid":"0","sdpMLineIndex":0,"usernameFragment":null}}`, true},
+		{"heartbeat", `{"type":"heartbeat","ts":1}`, true},
+		{"gain", `{"type":"gain","peer_id":"p","value":0.5}`, true},
+		{"set_denoise", `{"type":"set_denoise","peer_id":"p","enabled":true}`, true},
+		{"server-only type", `{"type":"room_state"}`, false},
+		{"not json", `{"type":`, false},
+		{"missing type", `{"sdp":"v=0"}`, false},
+		{"unknown type", `{"type":"shutdown"}`, false},
+		{"unexpected field", `{"type":"raise_hand","peer_id":"x"}`, false},
+		{"empty sdp", `{"type":"answer","sdp":""}`, false},
+		{"oversized sdp", `{"type":"offer","sdp":"` + strings.Repeat("a", maxSDPBytes+1) + `"}`, false},
+		{"candidate string", `{"type":"candidate","candidate":"x"}`, false},
+		{"candidate field", `{"type":"candidate","candidate":{"candidate":"x","extra":1}}`, false},
+		{"oversized candidate", `{"type":"candidate","candidate":{"candidate":"` + strings.Repeat("a", maxCandidateBytes+1) + `"}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSignalingMessage([]byte(tt.raw), false)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, errMalformedMessage) {
+				t.Fatalf("err = %v, want errMalformedMessage", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	webhookQueueSize      = 256
+	webhookTimeout        = 5 * time.Second
+	webhookMaxAttempts    = 5
+	webhookInitialBackoff = 500 * time.Millisecond
+	webhookMaxBackoff     = 30 * time.Second
+	webhookDrainTimeout   = 10 * time.Second
+)
+
+// WebhookDispatcher delivers lifecycle events to external HTTP endpoints.
+// Deliveries are asynchronous; a full queue drops events rather than blocking callers.
+// Each URL has its own queue and worker, so an endpoint that is down or slow
+// delays and drops only its own events.
+type WebhookDispatcher struct {
+	URLs   []string
+	Secret []byte
+	Client *http.Client
+
+	endpoints []chan Event
+	// draining is closed by Close; workers then deliver what is queued and
+	// exit. ctx is cancelled drainTimeout later, abandoning the rest.
+	draining     chan struct{}
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	closing      sync.Once
+	drainTimeout time.Duration
+
+	// backoff is overridable in tests.
+	backoff func(attempt int) time.Duration
+}
+
+// NewWebhookDispatcher starts a dispatcher for the given URLs. It returns nil when no URLs are set.
+func NewWebhookDispatcher(urls []string, secret string) *WebhookDispatcher {
+	if len(urls) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &WebhookDispatcher{
+		URLs:         urls,
+		Secret:       []byte(secret),
+		Client:       &http.Client{Timeout: webhookTimeout},
+		draining:     make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+		drainTimeout: webhookDrainTimeout,
+		backoff:      webhookBackoff,
+	}
+	for _, url := range urls {
+		queue := make(chan Event, webhookQueueSize)
+		d.endpoints = append(d.endpoints, queue)
+		d.wg.Add(1)
+		go d.run(url, queue)
+	}
+	return d
+}
+
+// Send queues an event for delivery to every URL. It is safe to call on a nil
+// dispatcher, and drops the event once the dispatcher is closing.
+func (d *WebhookDispatcher) Send(event Event) {
+	if d == nil {
+		return
+	}
+	select {
+	case <-d.draining:
+		return
+	default:
+	}
+	for i, queue := range d.endpoints {
+		select {
+		case queue <- event:
+		default:
+			slog.Warn("Webhook queue full, dropping event", "url", d.URLs[i], "type", event.Type)
+		}
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be delivered,
+// for at most drainTimeout; deliveries still pending then are abandoned.
+func (d *WebhookDispatcher) Close() {
+	if d == nil {
+		return
+	}
+	d.closing.Do(func() {
+		close(d.draining)
+		timer := time.AfterFunc(d.drainTimeout, d.cancel)
+		d.wg.Wait()
+		timer.Stop()
+		d.cancel()
+	})
+	d.wg.Wait()
+}
+
+// run delivers url's events in order until the dispatcher is closed and its
+// queue is drained.
+func (d *WebhookDispatcher) run(url string, queue chan Event) {
+	defer d.wg.Done()
+	for {
+		select {
+		case event := <-queue:
+			d.deliver(url, event)
+		case <-d.draining:
+			for {
+				select {
+				case event := <-queue:
+					if d.ctx.Err() != nil {
+						slog.Warn("Webhook dispatcher closed, dropping event", "url", url, "type", event.Type)
+						continue
+					}
+					d.deliver(url, event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(url string, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to marshal webhook event", "type", event.Type, "err", err)
+		return
+	}
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		err := d.post(url, event, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookMaxAttempts || d.ctx.Err() != nil {
+			slog.Error("Webhook delivery failed", "url", url, "type", event.Type, "attempts", attempt, "err", err)
+			return
+		}
+		slog.Warn("Webhook delivery failed, retrying", "url", url, "type", event.Type, "attempt", attempt, "err", err)
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(d.backoff(attempt)):
+		}
+	}
+}
+
+func (d *WebhookDispatcher) post(url string, event Event, body []byte) error {
+	ctx, cancel := context.WithTimeout(d.ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(event.Timestamp.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sigmartc-Event", event.Type)
+	req.Header.Set("X-Sigmartc-Delivery", event.ID)
+	req.Header.Set("X-Sigmartc-Timestamp", timestamp)
+	if len(d.Secret) > 0 {
+		req.Header.Set("X-Sigmartc-Signature", "sha256="+signWebhook(d.Secret, timestamp, body))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhook computes the hex HMAC-SHA256 of "timestamp.body".
+// Including the timestamp lets receivers reject replayed deliveries.
+func signWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempt int) time.Duration {
+	delay := webhookInitialBackoff << (attempt - 1)
+	if delay > webhookMaxBackoff || delay <= 0 {
+		return webhookMaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookDispatcherSignsAndRetries(t *testing.T) {
+	var attempts int32
+	received := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Sigmartc-Timestamp")
+		want := "sha256=" + signWebhook([]byte("secret"), timestamp, body)
+		if got := r.Header.Get("X-Sigmartc-Signature"); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+		received <- event
+	}))
+	defer srv.Close()
+
+	d := NewWebhookDispatcher([]string{srv.URL}, "secret")
+	d.backoff = func(int) time.Duration { return time.Millisecond }
+	defer d.Close()
+
+	d.Send(newEvent(EventUserJoin, map[string]any{"room": "room-a"}))
+
+	select {
+	case event := <-received:
+		if event.Type != EventUserJoin {
+			t.Fatalf("event type = %q, want %q", event.Type, EventUserJoin)
+		}
+		if event.Data["room"] != "room-a" {
+			t.Fatalf("event room = %v, want room-a", event.Data["room"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestWebhookDispatcherNilIsNoop(t *testing.T) {
+	if d := NewWebhookDispatcher(nil, "secret"); d != nil {
+		t.Fatal("expected nil dispatcher without URLs")
+	}
+	var d *WebhookDispatcher
+	d.Send(newEvent(EventBan, nil))
+	d.Close()
+}
+
+func TestWebhookBackoffCaps(t *testing.T) {
+	if got := webhookBackoff(1); got != webhookInitialBackoff {
+		t.Fatalf("first backoff = %v, want %v", got, webhookInitialBackoff)
+	}
+	if got := webhookBackoff(40); got != webhookMaxBackoff {
+		t.Fatalf("large backoff = %v, want %v", got, webhookMaxBackoff)
+	}
+}
+
+func TestWebhookDeadEndpointDoesNotDelayOthers(t *testing.T) {
+	hang := make(chan struct{})
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	defer dead.Close()
+	defer close(hang)
+	received := make(chan string, 4)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Sigmartc-Event")
+	}))
+	defer healthy.Close()
+
+	d := NewWebhookDispatcher([]string{dead.URL, healthy.URL}, "")
+	d.drainTimeout = 50 * time.Millisecond
+	defer d.Close()
+	d.Send(newEvent(EventUserJoin, nil))
+	d.Send(newEvent(EventUserLeave, nil))
+	for _, want := range []string{EventUserJoin, EventUserLeave} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("event = %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s was held up by the dead endpoint", want)
+		}
+	}
+}
+
+func TestWebhookCloseDrainsWithinDeadline(t *testing.T) {
+	var delivered int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&delivered, 1)
+	}))
+	defer srv.Close()
+	d := NewWebhookDispatcher([]string{srv.URL}, "")
+	for i := 0; i < 3; i++ {
+		d.Send(newEvent(EventUserJoin, nil))
+	}
+	d.Close()
+	if got := atomic.LoadInt32(&delivered); got != 3 {
+		t.Fatalf("delivered %d queued events on close, want 3", got)
+	}
+
+	hang := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer hung.Close()
+	defer close(hang)
+	d = NewWebhookDispatcher([]string{hung.URL}, "")
+	d.drainTimeout = 50 * time.Millisecond
+	d.Send(newEvent(EventUserJoin, nil))
+	d.Send(newEvent(EventUserLeave, nil))
+	start := time.Now()
+	d.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Close took %v against a hung endpoint", elapsed)
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+)
+
+// WebTransport signaling: clients whose networks make WebSocket over TCP suffer
+// from head-of-line blocking can join over HTTP/3 instead, at /wt with the same
+// query parameters as /ws. The client opens one bidirectional stream right after
+// the session is established and exchanges the /ws messages on it, each framed
+// as a 1-byte type (1 text, 2 binary, 8 close: the WebSocket opcodes), a 4-byte
+// big-endian length and the payload. A close payload is a 2-byte close code and
+// the reason, as in a WebSocket close frame. Joins that are refused get the
+// disconnect message and close frame on the stream, as ?join_errors=ws does.
+
+// wtStreamWait bounds how long a new session may take to open its signaling stream.
+const wtStreamWait = 10 * time.Second
+
+// wtHeaderSize is the length of a frame's type and length prefix.
+const wtHeaderSize = 5
+
+var errWTFrameTooLarge = errors.New("webtransport: signaling frame too large")
+
+// wtConn is a SignalingConn over a WebTransport session's signaling stream.
+type wtConn struct {
+	session   *webtransport.Session
+	stream    *webtransport.Stream
+	closeOnce sync.Once
+}
+
+func (c *wtConn) ReadMessage() (int, []byte, error) {
+	var header [wtHeaderSize]byte
+	if _, err := io.ReadFull(c.stream, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxSignalingMessageBytes {
+		return 0, nil, errWTFrameTooLarge
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.stream, data); err != nil {
+		return 0, nil, err
+	}
+	if header[0] == websocket.CloseMessage {
+		code := websocket.CloseNoStatusReceived
+		if len(data) >= 2 {
+			code = int(binary.BigEndian.Uint16(data))
+			data = data[2:]
+		}
+		return 0, nil, &websocket.CloseError{Code: code, Text: string(data)}
+	}
+	return int(header[0]), data, nil
+}
+
+func (c *wtConn) WriteMessage(messageType int, data []byte, deadline time.Time) error {
+	frame := make([]byte, wtHeaderSize+len(data))
+	frame[0] = byte(messageType)
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	copy(frame[wtHeaderSize:], data)
+	_ = c.stream.SetWriteDeadline(deadline)
+	_, err := c.stream.Write(frame)
+	return err
+}
+
+func (c *wtConn) WriteClose(code int, text string, deadline time.Time) error {
+	return c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), deadline)
+}
+
+// Close stops reading at once but gives the client until wsWriteWait to receive
+// what was written, such as a close frame, before the session is torn down.
+func (c *wtConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stream.CancelRead(0)
+		_ = c.stream.Close()
+		go func() {
+			defer recoverGoroutine("webtransport close")
+			select {
+			case <-c.session.Context().Done():
+			case <-time.After(wsWriteWait):
+			}
+			_ = c.session.CloseWithError(0, "")
+		}()
+	})
+	return nil
+}
+
+// NewWebTransportServer returns an HTTP/3 server on addr serving WebTransport
+// signaling at /wt. tlsConfig must carry the server's certificate.
+func (h *Handler) NewWebTransportServer(addr string, tlsConfig *tls.Config) *webtransport.Server {
+	server := &webtransport.Server{
+		H3: &http3.Server{
+			Addr:      addr,
+			TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+			QUICConfig: &quic.Config{
+				// QUIC keepalives stand in for the WebSocket ping/pong.
+				KeepAlivePeriod: wsPingInterval,
+				MaxIdleTimeout:  wsPongWait,
+			},
+		},
+		CheckOrigin: h.checkWSOrigin,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wt", func(w http.ResponseWriter, r *http.Request) {
+		h.handleWebTransport(server, w, r)
+	})
+	server.H3.Handler = RecoverHTTP(mux)
+	webtransport.ConfigureHTTP3Server(server.H3)
+	return server
+}
+
+// handleWebTransport upgrades a /wt request and serves the session like HandleWS.
+func (h *Handler) handleWebTransport(server *webtransport.Server, w http.ResponseWriter, r *http.Request) {
+	sessionID := h.requestID(r)
+	w.Header().Set(requestIDHeader, sessionID)
+	session, err := server.Upgrade(w, r)
+	if err != nil {
+		slog.Warn("WebTransport upgrade failed", "session_id", sessionID, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(session.Context(), wtStreamWait)
+	stream, err := session.AcceptStream(ctx)
+	cancel()
+	if err != nil {
+		slog.Warn("WebTransport session opened no signaling stream", "session_id", sessionID, "err", err)
+		_ = session.CloseWithError(0, fmt.Sprintf("no signaling stream within %s", wtStreamWait))
+		return
+	}
+	conn := &wtConn{session: session, stream: stream}
+
+	join, reason, message := h.checkJoin(r)
+	if reason != "" {
+		peer := &Peer{Conn: conn}
+		peer.Disconnect(reason, message)
+		return
+	}
+	h.serveSession(session.Context(), join, sessionID, conn)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v3"
+	"github.com/quic-go/webtransport-go"
+)
+
+// selfSignedTLS returns a server config and a pool trusting its certificate for localhost.
+func selfSignedTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+// readWTFrame reads one signaling frame from a WebTransport stream.
+func readWTFrame(t *testing.T, stream *webtransport.Stream) (byte, []byte) {
+	t.Helper()
+	_ = stream.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var header [wtHeaderSize]byte
+	if _, err := io.ReadFull(stream, header[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(stream, data); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	return header[0], data
+}
+
+func TestWebTransportJoinsAndDisconnectsLikeWS(t *testing.T) {
+	rm := NewRoomManager("test-key", filepath.Join(t.TempDir(), "banned.json"))
+	handler := NewHandler(rm, newTestAPI(t), &webrtc.Configuration{}, nil)
+	serverTLS, pool := selfSignedTLS(t)
+	server := handler.NewWebTransportServer("", serverTLS)
+	udp, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(udp)
+	t.Cleanup(func() { _ = server.Close() })
+
+	query := url.Values{"room": {"room-wt"}, "name": {"alice"}}
+	target := "https://localhost:" + strconv.Itoa(udp.LocalAddr().(*net.UDPAddr).Port) + "/wt?" + query.Encode()
+	dialer := webtransport.Dialer{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	t.Cleanup(func() { _ = dialer.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, session, err := dialer.Dial(ctx, target, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Streams reach the server with their first bytes, so say something.
+	hello, _ := json.Marshal(map[string]string{"type": "lower_hand"})
+	frame := append([]byte{websocket.TextMessage, 0, 0, 0, 0}, hello...)
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(hello)))
+	if _, err := stream.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	var peerID string
+	for peerID == "" {
+		opcode, data := readWTFrame(t, stream)
+		var msg map[string]any
+		if opcode != websocket.TextMessage || json.Unmarshal(data, &msg) != nil {
+			t.Fatalf("frame %d %q is not a JSON message", opcode, data)
+		}
+		if msg["type"] == "room_state" {
+			peerID, _ = msg["self_id"].(string)
+		}
+	}
+	_, peer := rm.FindPeer(peerID)
+	if peer == nil {
+		t.Fatal("WebTransport peer was not registered")
+	}
+
+	peer.Disconnect(DisconnectKicked, "bye")
+	for {
+		opcode, data := readWTFrame(t, stream)
+		if opcode != websocket.CloseMessage {
+			continue
+		}
+		if code := binary.BigEndian.Uint16(data); code != websocket.ClosePolicyViolation || string(data[2:]) != string(DisconnectKicked) {
+			t.Fatalf("close frame = %d %q", code, data[2:])
+		}
+		break
+	}
+}
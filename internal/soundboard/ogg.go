@@ -0,0 +1,169 @@
+// Package soundboard reads Ogg Opus files and replays them as paced RTP so a
+// server-stored clip can be published into a room like any other track.
+package soundboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	oggPageHeaderLen  = 27
+	oggCapturePattern = "OggS"
+)
+
+var (
+	errNotOgg  = errors.New("not an Ogg file")
+	errNotOpus = errors.New("not an Ogg Opus stream")
+	errNoAudio = errors.New("file contains no audio packets")
+)
+
+// Packet is one Opus packet and its duration in 48kHz samples.
+type Packet struct {
+	Data    []byte
+	Samples uint32
+}
+
+// ReadOggOpus parses every audio packet of the first logical stream in an Ogg Opus file.
+// Unlike a page-level reader it honours the lacing table, so pages carrying several
+// packets (as written by opusenc and ffmpeg) are split correctly.
+func ReadOggOpus(r io.Reader) ([]Packet, error) {
+	reader := NewOggReader(r)
+	var packets []Packet
+	for {
+		packet, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+	}
+	if len(packets) == 0 {
+		return nil, errNoAudio
+	}
+	return packets, nil
+}
+
+// OggReader reads the audio packets of the first logical stream of an Ogg Opus
+// stream one at a time, e.g. from a pipe that is still being written.
+type OggReader struct {
+	r       io.Reader
+	header  []byte
+	pages   int
+	serial  uint32
+	index   int // packet index within the stream; 0 is OpusHead, 1 is OpusTags
+	pending []byte
+	queued  []Packet
+}
+
+// NewOggReader returns a reader for the Ogg Opus stream r.
+func NewOggReader(r io.Reader) *OggReader {
+	return &OggReader{r: r, header: make([]byte, oggPageHeaderLen)}
+}
+
+// Next returns the next audio packet, or io.EOF at the end of the stream.
+func (o *OggReader) Next() (Packet, error) {
+	for len(o.queued) == 0 {
+		if err := o.readPage(); err != nil {
+			return Packet{}, err
+		}
+	}
+	packet := o.queued[0]
+	o.queued = o.queued[1:]
+	return packet, nil
+}
+
+// readPage reads one page and queues the audio packets it completes.
+func (o *OggReader) readPage() error {
+	if _, err := io.ReadFull(o.r, o.header); err != nil {
+		if errors.Is(err, io.EOF) && o.pages > 0 {
+			return io.EOF
+		}
+		if o.pages == 0 {
+			return errNotOgg
+		}
+		return err
+	}
+	if string(o.header[:4]) != oggCapturePattern {
+		return errNotOgg
+	}
+	pageSerial := binary.LittleEndian.Uint32(o.header[14:18])
+	if o.pages == 0 {
+		o.serial = pageSerial
+	}
+	o.pages++
+	lacing := make([]byte, o.header[26])
+	if _, err := io.ReadFull(o.r, lacing); err != nil {
+		return err
+	}
+	size := 0
+	for _, l := range lacing {
+		size += int(l)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(o.r, body); err != nil {
+		return err
+	}
+	if pageSerial != o.serial {
+		return nil
+	}
+
+	offset := 0
+	for _, l := range lacing {
+		o.pending = append(o.pending, body[offset:offset+int(l)]...)
+		offset += int(l)
+		if l == 255 {
+			continue // packet continues in the next segment
+		}
+		switch o.index {
+		case 0:
+			if !bytes.HasPrefix(o.pending, []byte("OpusHead")) {
+				return errNotOpus
+			}
+		case 1:
+			// OpusTags: metadata only.
+		default:
+			if len(o.pending) > 0 {
+				o.queued = append(o.queued, Packet{Data: o.pending, Samples: opusPacketSamples(o.pending)})
+			}
+		}
+		o.index++
+		o.pending = nil
+	}
+	return nil
+}
+
+// opusPacketSamples returns the packet duration at 48kHz from its TOC byte (RFC 6716 §3.1).
+func opusPacketSamples(packet []byte) uint32 {
+	if len(packet) == 0 {
+		return 0
+	}
+	toc := packet[0]
+	config := toc >> 3
+
+	var frameSamples uint32
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60 ms
+		frameSamples = []uint32{480, 960, 1920, 2880}[config%4]
+	case config < 16: // Hybrid: 10, 20 ms
+		frameSamples = []uint32{480, 960}[config%2]
+	default: // CELT: 2.5, 5, 10, 20 ms
+		frameSamples = []uint32{120, 240, 480, 960}[config%4]
+	}
+
+	frames := uint32(1)
+	switch toc & 0x03 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = uint32(packet[1] & 0x3f)
+	}
+	return frameSamples * frames
+}
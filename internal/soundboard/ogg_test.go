@@ -0,0 +1,101 @@
+package soundboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// oggPage builds a page carrying whole packets (checksums are not verified by the reader).
+func oggPage(serial uint32, packets ...[]byte) []byte {
+	var lacing, body []byte
+	for _, packet := range packets {
+		size := len(packet)
+		for size >= 255 {
+			lacing = append(lacing, 255)
+			size -= 255
+		}
+		lacing = append(lacing, byte(size))
+		body = append(body, packet...)
+	}
+	header := make([]byte, oggPageHeaderLen)
+	copy(header, oggCapturePattern)
+	binary.LittleEndian.PutUint32(header[14:], serial)
+	header[26] = byte(len(lacing))
+	return append(append(header, lacing...), body...)
+}
+
+func TestReadOggOpusSplitsPackets(t *testing.T) {
+	long := append([]byte{0xfc}, bytes.Repeat([]byte{7}, 300)...) // spans two lacing segments
+	var file bytes.Buffer
+	file.Write(oggPage(1, []byte("OpusHead\x01\x02")))
+	file.Write(oggPage(1, []byte("OpusTags")))
+	file.Write(oggPage(1, []byte{0xfc, 1}, long, []byte{0x00, 2}))
+	file.Write(oggPage(2, []byte{0xfc, 9})) // other logical stream is ignored
+
+	packets, err := ReadOggOpus(&file)
+	if err != nil {
+		t.Fatalf("ReadOggOpus: %v", err)
+	}
+	if len(packets) != 3 || len(packets[1].Data) != 301 {
+		t.Fatalf("unexpected packets: %d", len(packets))
+	}
+	if packets[0].Samples != 960 || packets[2].Samples != 480 {
+		t.Fatalf("unexpected durations: %d %d", packets[0].Samples, packets[2].Samples)
+	}
+}
+
+func TestReadOggOpusRejectsOtherFiles(t *testing.T) {
+	if _, err := ReadOggOpus(bytes.NewReader([]byte("RIFF....WAVE"))); err != errNotOgg {
+		t.Fatalf("expected errNotOgg, got %v", err)
+	}
+	vorbis := oggPage(1, []byte("\x01vorbis"))
+	if _, err := ReadOggOpus(bytes.NewReader(vorbis)); err != errNotOpus {
+		t.Fatalf("expected errNotOpus, got %v", err)
+	}
+	headersOnly := append(oggPage(1, []byte("OpusHead")), oggPage(1, []byte("OpusTags"))...)
+	if _, err := ReadOggOpus(bytes.NewReader(headersOnly)); err != errNoAudio {
+		t.Fatalf("expected errNoAudio, got %v", err)
+	}
+}
+
+func TestOpusPacketSamples(t *testing.T) {
+	cases := []struct {
+		packet []byte
+		want   uint32
+	}{
+		{[]byte{0x08}, 960},        // SILK NB 20ms, one frame
+		{[]byte{0x18}, 2880},       // SILK NB 60ms
+		{[]byte{0x78}, 960},        // Hybrid FB 20ms
+		{[]byte{0xf0}, 480},        // CELT FB 10ms
+		{[]byte{0xfc}, 960},        // CELT FB 20ms
+		{[]byte{0xfd}, 1920},       // two 20ms frames
+		{[]byte{0xf3, 0x03}, 1440}, // three 10ms frames (code 3)
+		{nil, 0},
+	}
+	for _, tc := range cases {
+		if got := opusPacketSamples(tc.packet); got != tc.want {
+			t.Fatalf("opusPacketSamples(%x) = %d, want %d", tc.packet, got, tc.want)
+		}
+	}
+}
+
+func TestOggReaderReturnsPacketsBeforeStreamEnds(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		_, _ = w.Write(oggPage(1, []byte("OpusHead\x01\x02")))
+		_, _ = w.Write(oggPage(1, []byte("OpusTags")))
+		_, _ = w.Write(oggPage(1, []byte{0xfc, 1}))
+		// The writer stays open, like ffmpeg between frames.
+	}()
+	defer w.Close()
+
+	packet, err := NewOggReader(r).Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if !bytes.Equal(packet.Data, []byte{0xfc, 1}) || packet.Samples != 960 {
+		t.Fatalf("unexpected packet: %+v", packet)
+	}
+}
@@ -0,0 +1,92 @@
+package soundboard
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// Player replays packets as RTP in real time. It implements the read side of a
+// forwarder: each Read blocks until the next packet is due.
+type Player struct {
+	packets     []Packet
+	loop        bool
+	payloadType uint8
+	done        <-chan struct{}
+
+	index     int
+	ssrc      uint32
+	sequence  uint16
+	timestamp uint32
+	next      time.Time
+}
+
+// NewPlayer returns a player for packets. Read returns io.EOF after the last packet
+// (unless loop is set) or once done is closed.
+func NewPlayer(packets []Packet, loop bool, payloadType uint8, done <-chan struct{}) *Player {
+	var seed [8]byte
+	_, _ = rand.Read(seed[:])
+	return &Player{
+		packets:     packets,
+		loop:        loop,
+		payloadType: payloadType,
+		done:        done,
+		ssrc:        binary.BigEndian.Uint32(seed[:4]),
+		sequence:    binary.BigEndian.Uint16(seed[4:6]),
+		timestamp:   binary.BigEndian.Uint32(seed[4:8]),
+	}
+}
+
+// Read writes the next RTP packet into buf once it is due.
+func (p *Player) Read(buf []byte) (int, error) {
+	if p.index >= len(p.packets) {
+		if !p.loop || len(p.packets) == 0 {
+			return 0, io.EOF
+		}
+		p.index = 0
+	}
+	if p.next.IsZero() {
+		p.next = time.Now()
+	}
+	if wait := time.Until(p.next); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-p.done:
+			timer.Stop()
+			return 0, io.EOF
+		case <-timer.C:
+		}
+	} else {
+		select {
+		case <-p.done:
+			return 0, io.EOF
+		default:
+		}
+	}
+
+	packet := p.packets[p.index]
+	out := rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         p.index == 0,
+			PayloadType:    p.payloadType,
+			SequenceNumber: p.sequence,
+			Timestamp:      p.timestamp,
+			SSRC:           p.ssrc,
+		},
+		Payload: packet.Data,
+	}
+	n, err := out.MarshalTo(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	p.index++
+	p.sequence++
+	p.timestamp += packet.Samples
+	p.next = p.next.Add(time.Duration(packet.Samples) * time.Second / 48000)
+	return n, nil
+}
@@ -0,0 +1,50 @@
+package soundboard
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+func TestPlayerPacesRTPAndEnds(t *testing.T) {
+	packets := []Packet{{Data: []byte{0xf8, 1}, Samples: 480}, {Data: []byte{0xf8, 2}, Samples: 480}}
+	player := NewPlayer(packets, false, 111, make(chan struct{}))
+	buf := make([]byte, 1500)
+
+	start := time.Now()
+	var first, second rtp.Packet
+	n, err := player.Read(buf)
+	if err != nil || first.Unmarshal(buf[:n]) != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	n, err = player.Read(buf)
+	if err != nil || second.Unmarshal(buf[:n]) != nil {
+		t.Fatalf("second read: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 8*time.Millisecond {
+		t.Fatalf("second packet sent after %v, want ~10ms pacing", elapsed)
+	}
+	if !first.Marker || first.PayloadType != 111 || second.SequenceNumber != first.SequenceNumber+1 || second.Timestamp-first.Timestamp != 480 {
+		t.Fatalf("unexpected headers: %+v then %+v", first.Header, second.Header)
+	}
+	if _, err := player.Read(buf); err != io.EOF {
+		t.Fatalf("expected io.EOF at end, got %v", err)
+	}
+}
+
+func TestPlayerLoopsUntilDone(t *testing.T) {
+	done := make(chan struct{})
+	player := NewPlayer([]Packet{{Data: []byte{0xf8}, Samples: 48}}, true, 111, done)
+	buf := make([]byte, 1500)
+	for i := 0; i < 5; i++ {
+		if _, err := player.Read(buf); err != nil {
+			t.Fatalf("loop read %d: %v", i, err)
+		}
+	}
+	close(done)
+	if _, err := player.Read(buf); err != io.EOF {
+		t.Fatalf("expected io.EOF after done, got %v", err)
+	}
+}
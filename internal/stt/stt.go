@@ -0,0 +1,157 @@
+// Package stt turns speech into text using a pluggable backend.
+package stt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"sigmartc/internal/limitbuf"
+)
+
+// maxTextBytes bounds how much text a backend may return for one utterance.
+const maxTextBytes = 64 << 10
+
+// Transcriber converts a mono 16-bit WAV file to text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, wav []byte) (string, error)
+}
+
+// New creates a transcriber from a spec:
+//
+//	command:<shell command>   WAV on stdin, text on stdout
+//	http:<url>               multipart POST with the WAV as "file"; JSON {"text": "..."} or plain text response
+//
+// The HTTP form speaks both the whisper.cpp server (/inference) and
+// OpenAI-compatible /v1/audio/transcriptions endpoints. apiKey, if set, is sent
+// as a Bearer token and model, if set, as the "model" form field.
+func New(spec, apiKey, model string) (Transcriber, error) {
+	kind, target, _ := strings.Cut(spec, ":")
+	target = strings.TrimSpace(target)
+	switch kind {
+	case "command":
+		if target == "" {
+			return nil, errors.New("missing command")
+		}
+		return &CommandTranscriber{Command: target}, nil
+	case "http":
+		if target == "" {
+			return nil, errors.New("missing URL")
+		}
+		return &HTTPTranscriber{URL: target, APIKey: apiKey, Model: model, Client: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown STT backend %q", kind)
+	}
+}
+
+// CommandTranscriber runs a shell command per utterance, e.g.
+//
+//	whisper-cli -m ggml-base.en.bin -f - -nt -np
+type CommandTranscriber struct {
+	Command string
+}
+
+func (t *CommandTranscriber) Transcribe(ctx context.Context, wav []byte) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", t.Command)
+	cmd.Stdin = bytes.NewReader(wav)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &limitbuf.Writer{Buf: &stdout, Max: maxTextBytes}
+	cmd.Stderr = &limitbuf.Writer{Buf: &stderr, Max: 4096}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// HTTPTranscriber uploads each utterance to a transcription service.
+type HTTPTranscriber struct {
+	URL    string
+	APIKey string
+	Model  string
+	Client *http.Client
+}
+
+func (t *HTTPTranscriber) Transcribe(ctx context.Context, wav []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return "", err
+	}
+	part.Write(wav)
+	if t.Model != "" {
+		form.WriteField("model", t.Model)
+	}
+	form.WriteField("response_format", "json")
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if t.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.APIKey)
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	text, err := io.ReadAll(io.LimitReader(resp.Body, maxTextBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(text) > maxTextBytes {
+		return "", errors.New("transcription response too large")
+	}
+	var result struct {
+		Text *string `json:"text"`
+	}
+	if json.Unmarshal(text, &result) == nil && result.Text != nil {
+		return strings.TrimSpace(*result.Text), nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return "", errors.New("response has no text field")
+	}
+	return strings.TrimSpace(string(text)), nil
+}
+
+// EncodeWAV wraps mono 16-bit PCM samples in a WAV header.
+func EncodeWAV(samples []int16, sampleRate int) []byte {
+	const headerBytes = 44
+	dataBytes := len(samples) * 2
+	buf := make([]byte, headerBytes+dataBytes)
+	copy(buf[0:], "RIFF")
+	binary.LittleEndian.PutUint32(buf[4:], uint32(headerBytes-8+dataBytes))
+	copy(buf[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(buf[16:], 16) // fmt chunk size
+	binary.LittleEndian.PutUint16(buf[20:], 1)  // PCM
+	binary.LittleEndian.PutUint16(buf[22:], 1)  // mono
+	binary.LittleEndian.PutUint32(buf[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(buf[28:], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(buf[32:], 2)  // block align
+	binary.LittleEndian.PutUint16(buf[34:], 16) // bits per sample
+	copy(buf[36:], "data")
+	binary.LittleEndian.PutUint32(buf[40:], uint32(dataBytes))
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(buf[headerBytes+i*2:], uint16(sample))
+	}
+	return buf
+}
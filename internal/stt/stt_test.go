@@ -0,0 +1,95 @@
+package stt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestNewParsesSpecs(t *testing.T) {
+	if tr, err := New("command:cat", "", ""); err != nil || tr.(*CommandTranscriber).Command != "cat" {
+		t.Fatalf("command spec: %v %v", tr, err)
+	}
+	tr, err := New("http:http://whisper.local/inference", "key", "base.en")
+	if err != nil {
+		t.Fatalf("http spec: %v", err)
+	}
+	if h := tr.(*HTTPTranscriber); h.URL != "http://whisper.local/inference" || h.APIKey != "key" || h.Model != "base.en" {
+		t.Fatalf("http spec parsed as %+v", h)
+	}
+	for _, spec := range []string{"command:", "http:", "vosk:model", ""} {
+		if _, err := New(spec, "", ""); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestCommandTranscriberReadsStdout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	tr := &CommandTranscriber{Command: `head -c 4; echo " said hi"`}
+	text, err := tr.Transcribe(context.Background(), EncodeWAV([]int16{1, 2}, 16000))
+	if err != nil || text != "RIFF said hi" {
+		t.Fatalf("Transcribe = %q, %v", text, err)
+	}
+
+	tr = &CommandTranscriber{Command: "echo boom >&2; exit 3"}
+	if _, err := tr.Transcribe(context.Background(), nil); err == nil || err.Error() != "exit status 3: boom" {
+		t.Fatalf("expected stderr in error, got %v", err)
+	}
+}
+
+func TestHTTPTranscriberUploadsWAV(t *testing.T) {
+	wav := EncodeWAV([]int16{100, -100}, 16000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.FormValue("model") != "whisper-1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		got, _ := io.ReadAll(file)
+		if !bytes.Equal(got, wav) {
+			http.Error(w, "wrong audio", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":" hello room\n"}`))
+	}))
+	defer server.Close()
+
+	tr := &HTTPTranscriber{URL: server.URL, APIKey: "secret", Model: "whisper-1", Client: server.Client()}
+	text, err := tr.Transcribe(context.Background(), wav)
+	if err != nil || text != "hello room" {
+		t.Fatalf("Transcribe = %q, %v", text, err)
+	}
+	tr.APIKey = ""
+	if _, err := tr.Transcribe(context.Background(), wav); err == nil {
+		t.Fatal("expected error for non-2xx response")
+	}
+}
+
+func TestEncodeWAVHeader(t *testing.T) {
+	wav := EncodeWAV([]int16{1, -1, 3}, 16000)
+	if len(wav) != 44+6 || string(wav[:4]) != "RIFF" || string(wav[8:12]) != "WAVE" || string(wav[36:40]) != "data" {
+		t.Fatalf("bad header: %q", wav[:44])
+	}
+	if rate := binary.LittleEndian.Uint32(wav[24:]); rate != 16000 {
+		t.Fatalf("sample rate = %d", rate)
+	}
+	if size := binary.LittleEndian.Uint32(wav[40:]); size != 6 {
+		t.Fatalf("data size = %d", size)
+	}
+	if s := int16(binary.LittleEndian.Uint16(wav[46:])); s != -1 {
+		t.Fatalf("second sample = %d", s)
+	}
+}
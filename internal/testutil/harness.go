@@ -0,0 +1,177 @@
+// Package testutil runs a sigmartc server in-process for integration tests.
+// Media flows over pion's virtual network (vnet) and signaling over in-memory
+// pipes, so tests open no sockets and do not depend on how the host's loopback
+// interfaces are configured.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/ice/v2"
+	"github.com/pion/logging"
+	"github.com/pion/transport/v2/vnet"
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/internal/server"
+	"sigmartc/pkg/client"
+)
+
+const (
+	// subnet is the virtual LAN; the server is its first host.
+	subnet   = "10.0.0.0/24"
+	serverIP = "10.0.0.1"
+	// maxHosts is how many clients fit in subnet alongside the server.
+	maxHosts = 253
+)
+
+// Harness is a server and a virtual network for its clients. Handler fields may
+// be changed before clients connect.
+type Harness struct {
+	Handler *server.Handler
+	Rooms   *server.RoomManager
+	// Mux serves Handler with /ws registered; tests may add routes.
+	Mux *http.ServeMux
+	// Router carries all media. Chunk filters added to it see every packet.
+	Router *vnet.Router
+	// URL is the server's base URL. It only resolves through Connect and
+	// HTTPClient.
+	URL string
+
+	tb       testing.TB
+	listener *pipeListener
+	server   *http.Server
+
+	mu    sync.Mutex
+	hosts int
+}
+
+// New starts a server whose ICE timeouts follow negotiation (nil uses
+// server.DefaultNegotiationConfig). Everything is torn down when the test ends.
+func New(tb testing.TB, negotiation *server.NegotiationConfig) *Harness {
+	tb.Helper()
+	router, err := vnet.NewRouter(&vnet.RouterConfig{
+		CIDR:          subnet,
+		LoggerFactory: logging.NewDefaultLoggerFactory(),
+	})
+	if err != nil {
+		tb.Fatalf("testutil: vnet router: %v", err)
+	}
+	h := &Harness{
+		Router:   router,
+		URL:      "http://" + serverIP,
+		tb:       tb,
+		listener: newPipeListener(&net.TCPAddr{IP: net.ParseIP(serverIP), Port: 80}),
+	}
+
+	rm := server.NewRoomManager("test-key", filepath.Join(tb.TempDir(), "banned.json"))
+	m, err := server.NewMediaEngine()
+	if err != nil {
+		tb.Fatalf("testutil: media engine: %v", err)
+	}
+	policy := server.DefaultNegotiationConfig()
+	if negotiation != nil {
+		policy = *negotiation
+	}
+	api, err := h.newAPI(serverIP, m, &policy)
+	if err != nil {
+		tb.Fatalf("testutil: server network: %v", err)
+	}
+	h.Rooms = rm
+	h.Handler = server.NewHandler(rm, api, &webrtc.Configuration{}, &policy)
+	h.Mux = http.NewServeMux()
+	h.Mux.HandleFunc("/ws", h.Handler.HandleWS)
+
+	if err := router.Start(); err != nil {
+		tb.Fatalf("testutil: vnet start: %v", err)
+	}
+	h.server = &http.Server{Handler: h.Mux}
+	go func() {
+		_ = h.server.Serve(h.listener)
+	}()
+	tb.Cleanup(h.close)
+	return h
+}
+
+func (h *Harness) close() {
+	_ = h.server.Close()
+	_ = h.listener.Close()
+	_ = h.Router.Stop()
+}
+
+// newAPI attaches a host at ip to the virtual network and returns an API that
+// gathers candidates only there.
+func (h *Harness) newAPI(ip string, m *webrtc.MediaEngine, negotiation *server.NegotiationConfig) (*webrtc.API, error) {
+	nic, err := vnet.NewNet(&vnet.NetConfig{StaticIPs: []string{ip}})
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Router.AddNet(nic); err != nil {
+		return nil, err
+	}
+	settings := webrtc.SettingEngine{}
+	if negotiation != nil {
+		negotiation.ApplyTo(&settings)
+	}
+	settings.SetVNet(nic)
+	// mDNS would need multicast, which vnet does not route.
+	settings.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithSettingEngine(settings)), nil
+}
+
+// nextHost reserves the next client address.
+func (h *Harness) nextHost() (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hosts == maxHosts {
+		return "", fmt.Errorf("testutil: more than %d clients", maxHosts)
+	}
+	h.hosts++
+	return fmt.Sprintf("10.0.0.%d", h.hosts+1), nil
+}
+
+// Connect joins room as a client on its own virtual host, which is also the
+// address the server sees for its signaling connection. opts.API and
+// opts.Dialer are replaced. The client is closed when the test ends.
+func (h *Harness) Connect(ctx context.Context, room string, opts client.Options) (*client.Client, error) {
+	ip, err := h.nextHost()
+	if err != nil {
+		return nil, err
+	}
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	if opts.API, err = h.newAPI(ip, m, nil); err != nil {
+		return nil, err
+	}
+	opts.Dialer = &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return h.listener.dial(ctx, net.ParseIP(ip))
+		},
+	}
+	c, err := client.Connect(ctx, h.URL, room, opts)
+	if err != nil {
+		return nil, err
+	}
+	h.tb.Cleanup(func() { _ = c.Close() })
+	return c, nil
+}
+
+// HTTPClient returns a client for Mux's other routes, e.g. /admin. Its requests
+// come from 127.0.0.1.
+func (h *Harness) HTTPClient() *http.Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return h.listener.dial(ctx, net.IPv4(127, 0, 0, 1))
+		},
+	}
+	h.tb.Cleanup(transport.CloseIdleConnections)
+	return &http.Client{Transport: transport}
+}
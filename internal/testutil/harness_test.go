@@ -0,0 +1,64 @@
+package testutil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"sigmartc/pkg/client"
+)
+
+func TestHarnessConnectUsesVirtualHost(t *testing.T) {
+	h := New(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := h.Connect(ctx, "room", client.Options{Name: "alice"})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := c.WaitConnected(ctx); err != nil {
+		t.Fatalf("ice: %v", err)
+	}
+	room := h.Rooms.GetOrCreateRoom("room")
+	room.Lock.RLock()
+	defer room.Lock.RUnlock()
+	if len(room.Peers) != 1 {
+		t.Fatalf("expected 1 peer, got %d", len(room.Peers))
+	}
+	for _, peer := range room.Peers {
+		if peer.IP != "10.0.0.2" {
+			t.Fatalf("expected peer IP 10.0.0.2, got %q", peer.IP)
+		}
+	}
+	receivers := c.PeerConnection().GetReceivers()
+	if len(receivers) == 0 {
+		t.Fatal("expected a receiver")
+	}
+	pair, err := receivers[0].Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil {
+		t.Fatalf("no selected candidate pair: %v", err)
+	}
+	if pair.Remote.Address != serverIP {
+		t.Fatalf("expected media to the server at %s, got %s", serverIP, pair.Remote.Address)
+	}
+}
+
+func TestHarnessHTTPClient(t *testing.T) {
+	h := New(t, nil)
+	h.Mux.HandleFunc("/addr", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.RemoteAddr)
+	})
+	resp, err := h.HTTPClient().Get(h.URL + "/addr")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if got := string(body); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Fatalf("expected a loopback remote address, got %q", got)
+	}
+}
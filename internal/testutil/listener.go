@@ -0,0 +1,65 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// pipeListener is an in-memory net.Listener: dial hands the server end of a
+// net.Pipe to Accept, so HTTP and WebSocket signaling need no sockets.
+type pipeListener struct {
+	addr      net.Addr
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+	port      atomic.Int32
+}
+
+func newPipeListener(addr net.Addr) *pipeListener {
+	return &pipeListener{addr: addr, conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return l.addr
+}
+
+// dial connects to the listener as a client at ip; the server sees that address
+// as the connection's RemoteAddr.
+func (l *pipeListener) dial(ctx context.Context, ip net.IP) (net.Conn, error) {
+	remote := &net.TCPAddr{IP: ip, Port: 40000 + int(l.port.Add(1))}
+	clientEnd, serverEnd := net.Pipe()
+	select {
+	case l.conns <- &addrConn{Conn: serverEnd, local: l.addr, remote: remote}:
+		return &addrConn{Conn: clientEnd, local: remote, remote: l.addr}, nil
+	case <-l.closed:
+		return nil, errors.New("testutil: server closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// addrConn reports TCP addresses for a pipe, which has none of its own.
+type addrConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c *addrConn) LocalAddr() net.Addr  { return c.local }
+func (c *addrConn) RemoteAddr() net.Addr { return c.remote }
@@ -0,0 +1,109 @@
+// Package tts turns announcement text into Ogg Opus audio using a pluggable backend.
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"sigmartc/internal/limitbuf"
+)
+
+// maxAudioBytes bounds how much audio a backend may return (~10 minutes at 64kbps).
+const maxAudioBytes = 5 << 20
+
+// Synthesizer converts text to an Ogg Opus file.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) ([]byte, error)
+}
+
+// New creates a synthesizer from a spec:
+//
+//	command:<shell command>   text on stdin and in $TTS_TEXT, Ogg Opus on stdout
+//	http:<url>               POST {"text": "..."}, Ogg Opus response body
+func New(spec string) (Synthesizer, error) {
+	kind, target, _ := strings.Cut(spec, ":")
+	target = strings.TrimSpace(target)
+	switch kind {
+	case "command":
+		if target == "" {
+			return nil, errors.New("missing command")
+		}
+		return &CommandSynthesizer{Command: target}, nil
+	case "http":
+		if target == "" {
+			return nil, errors.New("missing URL")
+		}
+		return &HTTPSynthesizer{URL: target, Client: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown TTS backend %q", kind)
+	}
+}
+
+// CommandSynthesizer runs a shell command per announcement, e.g.
+//
+//	espeak-ng --stdout | ffmpeg -loglevel error -i - -c:a libopus -f ogg -
+type CommandSynthesizer struct {
+	Command string
+}
+
+func (s *CommandSynthesizer) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", s.Command)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Env = append(os.Environ(), "TTS_TEXT="+text)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &limitbuf.Writer{Buf: &stdout, Max: maxAudioBytes}
+	cmd.Stderr = &limitbuf.Writer{Buf: &stderr, Max: 4096}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	if stdout.Len() == 0 {
+		return nil, errors.New("command produced no audio")
+	}
+	return stdout.Bytes(), nil
+}
+
+// HTTPSynthesizer posts the text to a TTS service that answers with Ogg Opus.
+type HTTPSynthesizer struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *HTTPSynthesizer) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "audio/ogg")
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	audio, err := io.ReadAll(io.LimitReader(resp.Body, maxAudioBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(audio) > maxAudioBytes {
+		return nil, errors.New("audio response too large")
+	}
+	return audio, nil
+}
@@ -0,0 +1,62 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestNewParsesSpecs(t *testing.T) {
+	if s, err := New("command:cat"); err != nil || s.(*CommandSynthesizer).Command != "cat" {
+		t.Fatalf("command spec: %v %v", s, err)
+	}
+	if s, err := New("http:http://tts.local/speak"); err != nil || s.(*HTTPSynthesizer).URL != "http://tts.local/speak" {
+		t.Fatalf("http spec: %v %v", s, err)
+	}
+	for _, spec := range []string{"command:", "http:", "polly:voice", ""} {
+		if _, err := New(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestCommandSynthesizerPassesText(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	s := &CommandSynthesizer{Command: `printf '%s|' "$TTS_TEXT"; cat`}
+	audio, err := s.Synthesize(context.Background(), "hello")
+	if err != nil || string(audio) != "hello|hello" {
+		t.Fatalf("Synthesize = %q, %v", audio, err)
+	}
+
+	s = &CommandSynthesizer{Command: "echo boom >&2; exit 3"}
+	if _, err := s.Synthesize(context.Background(), "x"); err == nil || err.Error() != "exit status 3: boom" {
+		t.Fatalf("expected stderr in error, got %v", err)
+	}
+}
+
+func TestHTTPSynthesizerPostsJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Text != "room closes soon" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "audio/ogg")
+		w.Write([]byte("OggS-audio"))
+	}))
+	defer server.Close()
+
+	s := &HTTPSynthesizer{URL: server.URL, Client: server.Client()}
+	audio, err := s.Synthesize(context.Background(), "room closes soon")
+	if err != nil || string(audio) != "OggS-audio" {
+		t.Fatalf("Synthesize = %q, %v", audio, err)
+	}
+	if _, err := s.Synthesize(context.Background(), "other"); err == nil {
+		t.Fatal("expected error for non-2xx response")
+	}
+}
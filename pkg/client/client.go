@@ -0,0 +1,522 @@
+// Package client is a headless participant for a sigmartc server. It speaks the
+// /ws signaling protocol and runs a WebRTC peer connection, so bots, monitors and
+// integration tests can join rooms, publish Opus and receive audio and events
+// without a browser.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+
+	"sigmartc/internal/soundboard"
+	"sigmartc/pkg/protocol"
+)
+
+// opusSilence is a 20ms Opus frame that decodes to silence.
+var opusSilence = []byte{0xf8, 0xff, 0xfe}
+
+// ErrNotPublishing is returned by the publish methods of a client connected
+// without Options.Publish.
+var ErrNotPublishing = errors.New("client has no published track")
+
+// Options configures Connect. Only Name is required.
+type Options struct {
+	// Name is the nickname shown to the room.
+	Name string
+	// Query adds join parameters such as host_token, device_id or token.
+	Query url.Values
+	// Header is sent with the WebSocket handshake, e.g. Authorization or Origin.
+	Header http.Header
+	// Dialer dials the signaling WebSocket. Nil uses websocket.DefaultDialer.
+	Dialer *websocket.Dialer
+	// API creates the peer connection. Nil uses one with the default codecs.
+	API *webrtc.API
+	// Config is the peer connection configuration, e.g. ICE servers.
+	Config webrtc.Configuration
+	// Publish adds an Opus track for the Publish and WriteOpus methods; without it
+	// the client only listens.
+	Publish bool
+
+	// OnEvent receives every signaling message other than offer, answer and
+	// candidate, which the client handles itself.
+	OnEvent func(Event)
+	// OnRTP receives each RTP packet from the room, tagged with the sending
+	// stream's ID. It is called from the track's read goroutine.
+	OnRTP func(streamID string, packet *rtp.Packet)
+	// Logf reports signaling errors the client recovers from. Nil discards them.
+	Logf func(format string, args ...any)
+}
+
+// Event is a signaling message from the server.
+type Event struct {
+	Type string
+	// Raw is the whole JSON message, for decoding type-specific fields.
+	Raw json.RawMessage
+}
+
+// Decode unmarshals the message into v, usually the pkg/protocol type for
+// e.Type, e.g. *protocol.RoomState for "room_state".
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// Client is a connected participant. Its methods are safe for concurrent use.
+type Client struct {
+	opts Options
+	ws   *websocket.Conn
+	wsMu sync.Mutex
+	pc   *webrtc.PeerConnection
+
+	localTrack  *webrtc.TrackLocalStaticRTP
+	payloadType uint8
+	writeMu     sync.Mutex
+	sequence    uint16
+	timestamp   uint32
+
+	pendingMu sync.Mutex
+	pending   []webrtc.ICECandidateInit
+	// heldOffer is only touched by readLoop.
+	heldOffer string
+
+	dialedAt    time.Time
+	connectedAt time.Time
+	connected   chan struct{}
+	connectOnce sync.Once
+	done        chan struct{}
+
+	streamsMu       sync.Mutex
+	streams         map[string]struct{}
+	streamsChanged  chan struct{}
+	packetsSent     atomic.Uint64
+	packetsReceived atomic.Uint64
+}
+
+// Connect joins room on the server at serverURL (http, https, ws or wss) and
+// sends the initial offer. It returns once signaling has started; use
+// WaitConnected to wait for media.
+func Connect(ctx context.Context, serverURL, room string, opts Options) (*Client, error) {
+	wsURL, err := WebSocketURL(serverURL, room, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Query) > 0 {
+		u, _ := url.Parse(wsURL)
+		query := u.Query()
+		for key, values := range opts.Query {
+			query[key] = values
+		}
+		u.RawQuery = query.Encode()
+		wsURL = u.String()
+	}
+	api := opts.API
+	if api == nil {
+		if api, err = NewAPI(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &Client{
+		opts:           opts,
+		dialedAt:       time.Now(),
+		connected:      make(chan struct{}),
+		done:           make(chan struct{}),
+		streams:        make(map[string]struct{}),
+		streamsChanged: make(chan struct{}),
+	}
+	dialer := opts.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	ws, resp, err := dialer.DialContext(ctx, wsURL, opts.Header)
+	if err != nil {
+		// Refused joins carry a JSON reason in the handshake response.
+		if resp != nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			if len(body) > 0 {
+				return nil, fmt.Errorf("%w: %s", err, bytes.TrimSpace(body))
+			}
+		}
+		return nil, err
+	}
+	c.ws = ws
+	if c.pc, err = api.NewPeerConnection(opts.Config); err != nil {
+		_ = c.ws.Close()
+		return nil, err
+	}
+
+	c.pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate != nil {
+			_ = c.Send(protocol.Candidate{Type: "candidate", Candidate: protocol.ICECandidate(candidate.ToJSON())})
+		}
+	})
+	c.pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+		if state == webrtc.ICEConnectionStateConnected || state == webrtc.ICEConnectionStateCompleted {
+			c.connectOnce.Do(func() {
+				c.connectedAt = time.Now()
+				close(c.connected)
+			})
+		}
+	})
+	c.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		go c.readTrack(track)
+	})
+
+	if opts.Publish {
+		err = c.addLocalTrack()
+	} else {
+		_, err = c.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly})
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	go c.readLoop()
+	if err := c.sendOffer(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewAPI returns a WebRTC API with the default codecs registered.
+func NewAPI() (*webrtc.API, error) {
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m)), nil
+}
+
+// WebSocketURL returns the /ws URL joining room as name on the server at serverURL.
+func WebSocketURL(serverURL, room, name string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	u.Path = "/ws"
+	query := u.Query()
+	query.Set("room", room)
+	query.Set("name", name)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func (c *Client) addLocalTrack() error {
+	track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, c.opts.Name+"-audio", c.opts.Name)
+	if err != nil {
+		return err
+	}
+	sender, err := c.pc.AddTrack(track)
+	if err != nil {
+		return err
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := sender.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	c.localTrack = track
+	c.payloadType = 111
+	if params := sender.GetParameters(); len(params.Codecs) > 0 {
+		c.payloadType = uint8(params.Codecs[0].PayloadType)
+	}
+	return nil
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.opts.Logf != nil {
+		c.opts.Logf(format, args...)
+	}
+}
+
+// Send writes a signaling message, e.g. protocol.RaiseHand{Type: "raise_hand"}.
+func (c *Client) Send(msg any) error {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+	return c.ws.WriteJSON(msg)
+}
+
+func (c *Client) sendOffer() error {
+	offer, err := c.pc.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err := c.pc.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	return c.Send(protocol.Offer{Type: "offer", SDP: c.pc.LocalDescription().SDP})
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		_, message, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg struct {
+			Type      string                  `json:"type"`
+			SDP       string                  `json:"sdp"`
+			Candidate webrtc.ICECandidateInit `json:"candidate"`
+		}
+		if err := json.Unmarshal(message, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case "offer":
+			// The server may write a renegotiation offer just before the answer
+			// to ours; hold it until the answer is applied.
+			if c.pc.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+				c.heldOffer = msg.SDP
+				continue
+			}
+			c.answerOffer(msg.SDP)
+		case "answer":
+			if err := c.pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: msg.SDP}); err != nil {
+				c.logf("SetRemoteDescription answer failed: %v", err)
+				continue
+			}
+			c.flushPending()
+			if offer := c.heldOffer; offer != "" {
+				c.heldOffer = ""
+				c.answerOffer(offer)
+			}
+		case "candidate":
+			if c.pc.RemoteDescription() == nil {
+				c.pendingMu.Lock()
+				c.pending = append(c.pending, msg.Candidate)
+				c.pendingMu.Unlock()
+				continue
+			}
+			if err := c.pc.AddICECandidate(msg.Candidate); err != nil {
+				c.logf("AddICECandidate failed: %v", err)
+			}
+		default:
+			if c.opts.OnEvent != nil {
+				c.opts.OnEvent(Event{Type: msg.Type, Raw: message})
+			}
+		}
+	}
+}
+
+// answerOffer applies a server offer and sends the answer.
+func (c *Client) answerOffer(sdp string) {
+	if err := c.pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: sdp}); err != nil {
+		c.logf("SetRemoteDescription offer failed: %v", err)
+		return
+	}
+	c.flushPending()
+	answer, err := c.pc.CreateAnswer(nil)
+	if err != nil {
+		c.logf("CreateAnswer failed: %v", err)
+		return
+	}
+	if err := c.pc.SetLocalDescription(answer); err != nil {
+		c.logf("SetLocalDescription answer failed: %v", err)
+		return
+	}
+	_ = c.Send(protocol.Answer{Type: "answer", SDP: c.pc.LocalDescription().SDP})
+}
+
+func (c *Client) flushPending() {
+	c.pendingMu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.pendingMu.Unlock()
+	for _, candidate := range pending {
+		if err := c.pc.AddICECandidate(candidate); err != nil {
+			c.logf("AddICECandidate failed: %v", err)
+		}
+	}
+}
+
+func (c *Client) readTrack(track *webrtc.TrackRemote) {
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		c.packetsReceived.Add(1)
+		c.streamsMu.Lock()
+		if _, ok := c.streams[track.StreamID()]; !ok {
+			c.streams[track.StreamID()] = struct{}{}
+			close(c.streamsChanged)
+			c.streamsChanged = make(chan struct{})
+		}
+		c.streamsMu.Unlock()
+		if c.opts.OnRTP != nil {
+			c.opts.OnRTP(track.StreamID(), packet)
+		}
+	}
+}
+
+// WaitConnected blocks until ICE connects.
+func (c *Client) WaitConnected(ctx context.Context) error {
+	select {
+	case <-c.connected:
+		return nil
+	case <-c.done:
+		return errors.New("signaling closed before connecting")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// JoinLatency is the time from dialing to ICE connected, or zero before then.
+func (c *Client) JoinLatency() time.Duration {
+	select {
+	case <-c.connected:
+		return c.connectedAt.Sub(c.dialedAt)
+	default:
+		return 0
+	}
+}
+
+// WaitForStreams blocks until RTP has arrived from n distinct streams.
+func (c *Client) WaitForStreams(ctx context.Context, n int) error {
+	for {
+		c.streamsMu.Lock()
+		count, changed := len(c.streams), c.streamsChanged
+		c.streamsMu.Unlock()
+		if count >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// PacketsReceived counts RTP packets received from all streams.
+func (c *Client) PacketsReceived() uint64 {
+	return c.packetsReceived.Load()
+}
+
+// PacketsSent counts RTP packets published.
+func (c *Client) PacketsSent() uint64 {
+	return c.packetsSent.Load()
+}
+
+// WriteOpus publishes one Opus packet lasting samples at 48kHz (960 for 20ms).
+// Callers pace the writes themselves.
+func (c *Client) WriteOpus(payload []byte, samples uint32) error {
+	if c.localTrack == nil {
+		return ErrNotPublishing
+	}
+	c.writeMu.Lock()
+	packet := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    c.payloadType,
+			SequenceNumber: c.sequence,
+			Timestamp:      c.timestamp,
+		},
+		Payload: payload,
+	}
+	c.sequence++
+	c.timestamp += samples
+	err := c.localTrack.WriteRTP(packet)
+	c.writeMu.Unlock()
+	if err == nil {
+		c.packetsSent.Add(1)
+	}
+	return err
+}
+
+// PublishSilence publishes count 20ms silent frames in real time, or frames until
+// ctx ends if count is zero.
+func (c *Client) PublishSilence(ctx context.Context, count int) error {
+	if c.localTrack == nil {
+		return ErrNotPublishing
+	}
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; count == 0 || i < count; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if err := c.WriteOpus(opusSilence, 960); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishFile plays an Ogg Opus file in real time, repeating it if loop is set,
+// until it ends or ctx does.
+func (c *Client) PublishFile(ctx context.Context, path string, loop bool) error {
+	if c.localTrack == nil {
+		return ErrNotPublishing
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	packets, err := soundboard.ReadOggOpus(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	player := soundboard.NewPlayer(packets, loop, c.payloadType, ctx.Done())
+	buf := make([]byte, 1500)
+	for {
+		n, err := player.Read(buf)
+		if err != nil {
+			return ctx.Err()
+		}
+		if _, err := c.localTrack.Write(buf[:n]); err != nil {
+			return err
+		}
+		c.packetsSent.Add(1)
+	}
+}
+
+// PeerConnection exposes the underlying peer connection, e.g. for stats.
+func (c *Client) PeerConnection() *webrtc.PeerConnection {
+	return c.pc
+}
+
+// Done is closed when the server closes the signaling connection.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close leaves the room.
+func (c *Client) Close() error {
+	err := c.ws.Close()
+	if pcErr := c.pc.Close(); err == nil {
+		err = pcErr
+	}
+	return err
+}
@@ -0,0 +1,22 @@
+package client
+
+import "testing"
+
+func TestWebSocketURL(t *testing.T) {
+	tests := []struct {
+		server, want string
+	}{
+		{"http://localhost:8080", "ws://localhost:8080/ws?name=bot&room=lobby"},
+		{"https://voice.example.com/", "wss://voice.example.com/ws?name=bot&room=lobby"},
+		{"wss://voice.example.com", "wss://voice.example.com/ws?name=bot&room=lobby"},
+	}
+	for _, tt := range tests {
+		got, err := WebSocketURL(tt.server, "lobby", "bot")
+		if err != nil || got != tt.want {
+			t.Errorf("WebSocketURL(%q) = %q, %v; want %q", tt.server, got, err, tt.want)
+		}
+	}
+	if _, err := WebSocketURL("ftp://example.com", "lobby", "bot"); err == nil {
+		t.Error("ftp URL accepted")
+	}
+}
@@ -0,0 +1,3 @@
+package protocol
+
+//go:generate go run sigmartc/cmd/protocol-schema -o schema.json
@@ -0,0 +1,562 @@
+// Package protocol defines the sigmartc signaling messages exchanged over /ws
+// (and the /wt and /poll transports) as Go types. The server validates client
+// messages against them, pkg/client sends them, and Schema describes them as
+// JSON Schema for clients in other languages (see schema.json).
+//
+// Every message is a JSON object whose "type" field names it. Fields tagged
+// omitempty are optional; the server rejects client messages carrying fields
+// that are not listed here, while clients should ignore fields they do not know.
+package protocol
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Version is the protocol version the server reports in hello_ack.
+const Version = 1
+
+// Direction says which side sends a message.
+type Direction int
+
+const (
+	ClientToServer Direction = 1 << iota
+	ServerToClient
+	Bidirectional = ClientToServer | ServerToClient
+)
+
+func (d Direction) String() string {
+	switch d {
+	case ClientToServer:
+		return "client"
+	case ServerToClient:
+		return "server"
+	case Bidirectional:
+		return "both"
+	}
+	return "none"
+}
+
+// Spec describes one message.
+type Spec struct {
+	Type      string
+	Direction Direction
+	// Message is a zero value of the message's Go type.
+	Message     any
+	Description string
+}
+
+// Fields returns the JSON names of the message's fields other than "type".
+func (s Spec) Fields() []string {
+	return FieldNames(s.Message)
+}
+
+// Client messages.
+
+// Heartbeat keeps an otherwise quiet connection from being reported idle.
+type Heartbeat struct {
+	Type string `json:"type"`
+	// TS is the client's clock in Unix milliseconds.
+	TS float64 `json:"ts,omitempty"`
+}
+
+// Hello declares the client's capabilities and message encoding.
+type Hello struct {
+	Type         string        `json:"type"`
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	// Encoding "msgpack" switches later server messages to MessagePack.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Capabilities is what a client declared in its hello message.
+type Capabilities struct {
+	ProtocolVersion int `json:"protocol_version"`
+	// Codecs lists the MIME types the client can receive; empty means no restriction.
+	Codecs       []string `json:"codecs,omitempty"`
+	Video        bool     `json:"video"`
+	DataChannels bool     `json:"data_channels"`
+	// E2EE means the client encrypts its media with insertable streams.
+	E2EE bool `json:"e2ee,omitempty"`
+	// LowLayerTrack is the ID of a second, low-bitrate audio track the client
+	// publishes for receivers on poor links.
+	LowLayerTrack string `json:"low_layer_track,omitempty"`
+}
+
+// Offer carries an SDP offer, from the client or a server renegotiation.
+type Offer struct {
+	Type string `json:"type"`
+	SDP  string `json:"sdp"`
+}
+
+// Answer carries an SDP answer.
+type Answer struct {
+	Type string `json:"type"`
+	SDP  string `json:"sdp"`
+}
+
+// Candidate carries a trickled ICE candidate.
+type Candidate struct {
+	Type      string       `json:"type"`
+	Candidate ICECandidate `json:"candidate"`
+}
+
+// ICECandidate is an RTCIceCandidateInit.
+type ICECandidate struct {
+	Candidate        string  `json:"candidate"`
+	SDPMid           *string `json:"sdpMid,omitempty"`
+	SDPMLineIndex    *uint16 `json:"sdpMLineIndex,omitempty"`
+	UsernameFragment *string `json:"usernameFragment,omitempty"`
+}
+
+// RoomStatsRequest asks for a room_stats reply.
+type RoomStatsRequest struct {
+	Type string `json:"type"`
+}
+
+// MovePeer sends a member of the room to a breakout room (hosts).
+type MovePeer struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+	Room   string `json:"room"`
+}
+
+// SendReaction sends an allow-listed emoji to the room.
+type SendReaction struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji"`
+}
+
+// RaiseHand joins the raise-hand queue.
+type RaiseHand struct {
+	Type string `json:"type"`
+}
+
+// LowerHand leaves the queue, or with PeerID removes someone else (moderators).
+type LowerHand struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id,omitempty"`
+}
+
+// CallNext pops the first raised hand (moderators).
+type CallNext struct {
+	Type string `json:"type"`
+}
+
+// SetRoomMetadata updates the room header (hosts).
+type SetRoomMetadata struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Topic  string `json:"topic"`
+	Avatar string `json:"avatar"`
+}
+
+// Kick disconnects a member of the room (moderators).
+type Kick struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+}
+
+// LockRoom refuses new joins (moderators).
+type LockRoom struct {
+	Type string `json:"type"`
+}
+
+// UnlockRoom allows new joins again (moderators).
+type UnlockRoom struct {
+	Type string `json:"type"`
+}
+
+// SendE2EEKey relays an opaque key exchange payload in an E2EE room.
+type SendE2EEKey struct {
+	Type string `json:"type"`
+	// To is the recipient's peer ID; empty sends to the rest of the room.
+	To      string `json:"to,omitempty"`
+	Payload string `json:"payload"`
+}
+
+// Subscribe opts in to one publisher's audio.
+type Subscribe struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+}
+
+// Unsubscribe opts out of one publisher's audio.
+type Unsubscribe struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+}
+
+// SetPrioritySpeaker marks the priority speaker; an empty PeerID clears it (hosts).
+type SetPrioritySpeaker struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+	Duck   bool   `json:"duck"`
+}
+
+// MuteAll mutes everyone without the mute-others permission (moderators).
+type MuteAll struct {
+	Type string `json:"type"`
+}
+
+// UnmuteAll lifts MuteAll (moderators).
+type UnmuteAll struct {
+	Type string `json:"type"`
+}
+
+// UnmuteRequest asks to speak while the room is muted.
+type UnmuteRequest struct {
+	Type string `json:"type"`
+}
+
+// ApproveUnmute lets a requester speak (moderators).
+type ApproveUnmute struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+}
+
+// DenyUnmute refuses a request to speak (moderators).
+type DenyUnmute struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+}
+
+// Quality picks the simulcast layer: "auto", "low" or "high".
+type Quality struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Gain sets how loud the client hears one publisher, from 0 to 2.
+type Gain struct {
+	Type   string  `json:"type"`
+	PeerID string  `json:"peer_id"`
+	Value  float64 `json:"value"`
+}
+
+// SetDenoise toggles noise suppression for a member of the room (moderators).
+type SetDenoise struct {
+	Type    string `json:"type"`
+	PeerID  string `json:"peer_id"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Server messages.
+
+// HelloAck answers hello.
+type HelloAck struct {
+	Type            string `json:"type"`
+	ProtocolVersion int    `json:"protocol_version"`
+	Encoding        string `json:"encoding"`
+}
+
+// RoomState is the room as the peer finds it on joining or moving.
+type RoomState struct {
+	Type   string `json:"type"`
+	SelfID string `json:"self_id"`
+	// Role is host, moderator, speaker or listener.
+	Role string `json:"role"`
+	// Polite tells the client to roll back its offer on a glare.
+	Polite          bool             `json:"polite"`
+	Peers           []PeerInfo       `json:"peers"`
+	Room            RoomMetadata     `json:"room"`
+	Hands           []string         `json:"hands"`
+	E2EE            bool             `json:"e2ee"`
+	PrioritySpeaker *PrioritySpeaker `json:"priority_speaker"`
+	MutedAll        bool             `json:"muted_all"`
+	CodecPolicy     *CodecPolicy     `json:"codec_policy"`
+	// RelayOnly asks the client to set iceTransportPolicy to relay, as the
+	// server does, so media only flows through TURN.
+	RelayOnly bool `json:"relay_only"`
+	// ICEServers are added to the client's iceServers: the TURN server of the
+	// room's tenant, with short-lived credentials.
+	ICEServers []ICEServer `json:"ice_servers,omitempty"`
+}
+
+// ICEServer is an RTCIceServer.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// PeerInfo describes a member of the room.
+type PeerInfo struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Role         string        `json:"role"`
+	UserID       string        `json:"user_id,omitempty"`
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+}
+
+// RoomMetadata is the room header.
+type RoomMetadata struct {
+	Name   string `json:"name"`
+	Topic  string `json:"topic"`
+	Avatar string `json:"avatar"`
+}
+
+// PrioritySpeaker is the peer whose speech ducks everyone else.
+type PrioritySpeaker struct {
+	PeerID string `json:"peer_id"`
+	Duck   bool   `json:"duck"`
+}
+
+// CodecPolicy restricts what publishers in a room may send.
+type CodecPolicy struct {
+	// Codecs lists the allowed MIME types; empty allows every codec.
+	Codecs []string `json:"codecs,omitempty"`
+	// Channels caps Opus channels; 1 asks for mono. Zero leaves the encoder's choice.
+	Channels int `json:"channels,omitempty"`
+	// MaxBitrateKbps caps Opus's average bitrate. Zero leaves it uncapped.
+	MaxBitrateKbps int `json:"max_kbps,omitempty"`
+}
+
+// PeerJoin announces a new member.
+type PeerJoin struct {
+	Type string   `json:"type"`
+	Peer PeerInfo `json:"peer"`
+}
+
+// PeerLeave announces a member leaving.
+type PeerLeave struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+}
+
+// PeerUpdate announces a member's capabilities.
+type PeerUpdate struct {
+	Type string           `json:"type"`
+	Peer PeerCapabilities `json:"peer"`
+}
+
+// PeerCapabilities is a member's declared capabilities.
+type PeerCapabilities struct {
+	ID           string        `json:"id"`
+	Capabilities *Capabilities `json:"capabilities"`
+}
+
+// RoomUpdate announces a new room header.
+type RoomUpdate struct {
+	Type string       `json:"type"`
+	Room RoomMetadata `json:"room"`
+}
+
+// HandQueue is the raise-hand queue after a change.
+type HandQueue struct {
+	Type  string   `json:"type"`
+	Queue []string `json:"queue"`
+}
+
+// CalledOn tells a peer a moderator called on it.
+type CalledOn struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+}
+
+// Reaction is an emoji from a member.
+type Reaction struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+	Emoji  string `json:"emoji"`
+}
+
+// E2EEKey is a relayed key exchange payload.
+type E2EEKey struct {
+	Type    string `json:"type"`
+	From    string `json:"from"`
+	Payload string `json:"payload"`
+}
+
+// PrioritySpeakerUpdate announces the priority speaker; an empty PeerID means none.
+type PrioritySpeakerUpdate struct {
+	Type   string `json:"type"`
+	PeerID string `json:"peer_id"`
+	Duck   bool   `json:"duck"`
+}
+
+// MutedAll announces mute_all or unmute_all.
+type MutedAll struct {
+	Type  string `json:"type"`
+	Muted bool   `json:"muted"`
+}
+
+// UnmuteRequests is the queue of unmute requests, sent to moderators.
+type UnmuteRequests struct {
+	Type  string   `json:"type"`
+	Queue []string `json:"queue"`
+}
+
+// UnmuteAnswer answers the peer's unmute request.
+type UnmuteAnswer struct {
+	Type     string `json:"type"`
+	Approved bool   `json:"approved"`
+}
+
+// Denoise announces a member's noise suppression changing.
+type Denoise struct {
+	Type    string `json:"type"`
+	PeerID  string `json:"peer_id"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Moved tells the peer it was moved to another room; a room_state follows.
+type Moved struct {
+	Type string `json:"type"`
+	Room string `json:"room"`
+	From string `json:"from"`
+}
+
+// Transcript is one transcribed utterance.
+type Transcript struct {
+	Type string `json:"type"`
+	TranscriptEntry
+}
+
+// TranscriptEntry is one transcribed utterance.
+type TranscriptEntry struct {
+	At     time.Time `json:"at"`
+	PeerID string    `json:"peer_id"`
+	Name   string    `json:"name"`
+	Text   string    `json:"text"`
+}
+
+// RoomExpiring warns that a scheduled room closes soon.
+type RoomExpiring struct {
+	Type string `json:"type"`
+	// EndsAt is an RFC 3339 time.
+	EndsAt      string `json:"ends_at"`
+	SecondsLeft int    `json:"seconds_left"`
+}
+
+// RoomStats answers room_stats.
+type RoomStats struct {
+	Type         string         `json:"type"`
+	Peers        []PeerStats    `json:"peers"`
+	HLSListeners int            `json:"hls_listeners"`
+	Bandwidth    BandwidthStats `json:"bandwidth"`
+}
+
+// PeerStats is one member's participation.
+type PeerStats struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	JoinedAt   time.Time `json:"joined_at"`
+	TalkTimeMS int64     `json:"talk_time_ms"`
+	BytesIn    uint64    `json:"bytes_in"`
+	BytesOut   uint64    `json:"bytes_out"`
+	// BitrateViolations counts seconds published over the room's bitrate cap.
+	BitrateViolations     uint64 `json:"bitrate_violations"`
+	BitrateDroppedPackets uint64 `json:"bitrate_dropped_packets"`
+}
+
+// BandwidthStats counts RTP bytes in and out.
+type BandwidthStats struct {
+	BytesIn  uint64 `json:"bytes_in"`
+	BytesOut uint64 `json:"bytes_out"`
+}
+
+// Disconnect tells the client why the server is closing the connection; the
+// close frame carries the same reason.
+type Disconnect struct {
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// Redirect tells the client its room is hosted on another node. The
+// connection closes with a "redirect" disconnect; clients reconnect to WSURL,
+// which carries the original join parameters, or open URL in a browser.
+type Redirect struct {
+	Type  string `json:"type"`
+	Node  string `json:"node"`
+	URL   string `json:"url"`
+	WSURL string `json:"ws_url"`
+}
+
+// Error reports a failed or rejected request.
+type Error struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Messages lists every signaling message.
+var Messages = []Spec{
+	{"heartbeat", ClientToServer, Heartbeat{}, "Keeps the connection from being reported idle."},
+	{"hello", ClientToServer, Hello{}, "Declares capabilities; optional, sent on open."},
+	{"offer", Bidirectional, Offer{}, "SDP offer."},
+	{"answer", Bidirectional, Answer{}, "SDP answer."},
+	{"candidate", Bidirectional, Candidate{}, "Trickled ICE candidate."},
+	{"room_stats", ClientToServer, RoomStatsRequest{}, "Asks for room statistics."},
+	{"move_peer", ClientToServer, MovePeer{}, "Hosts: move a member to a breakout room."},
+	{"reaction", ClientToServer, SendReaction{}, "Emoji reaction to the room."},
+	{"raise_hand", ClientToServer, RaiseHand{}, "Join the raise-hand queue."},
+	{"lower_hand", ClientToServer, LowerHand{}, "Leave the raise-hand queue."},
+	{"call_next", ClientToServer, CallNext{}, "Moderators: pop the first raised hand."},
+	{"set_room_metadata", ClientToServer, SetRoomMetadata{}, "Hosts: update the room header."},
+	{"kick", ClientToServer, Kick{}, "Moderators: disconnect a member."},
+	{"lock_room", ClientToServer, LockRoom{}, "Moderators: refuse new joins."},
+	{"unlock_room", ClientToServer, UnlockRoom{}, "Moderators: allow new joins."},
+	{"e2ee_key", ClientToServer, SendE2EEKey{}, "E2EE rooms: relay a key exchange payload."},
+	{"subscribe", ClientToServer, Subscribe{}, "Opt in to a publisher's audio."},
+	{"unsubscribe", ClientToServer, Unsubscribe{}, "Opt out of a publisher's audio."},
+	{"set_priority_speaker", ClientToServer, SetPrioritySpeaker{}, "Hosts: mark the priority speaker."},
+	{"mute_all", ClientToServer, MuteAll{}, "Moderators: mute the room."},
+	{"unmute_all", ClientToServer, UnmuteAll{}, "Moderators: unmute the room."},
+	{"unmute_request", ClientToServer, UnmuteRequest{}, "Ask to speak while muted."},
+	{"approve_unmute", ClientToServer, ApproveUnmute{}, "Moderators: approve an unmute request."},
+	{"deny_unmute", ClientToServer, DenyUnmute{}, "Moderators: deny an unmute request."},
+	{"quality", ClientToServer, Quality{}, "Pick the simulcast layer."},
+	{"gain", ClientToServer, Gain{}, "Set how loud a publisher is heard."},
+	{"set_denoise", ClientToServer, SetDenoise{}, "Moderators: toggle a member's noise suppression."},
+
+	{"hello_ack", ServerToClient, HelloAck{}, "Answers hello; later messages use the encoding."},
+	{"room_state", ServerToClient, RoomState{}, "The room on joining or moving."},
+	{"peer_join", ServerToClient, PeerJoin{}, "A member joined."},
+	{"peer_leave", ServerToClient, PeerLeave{}, "A member left."},
+	{"peer_update", ServerToClient, PeerUpdate{}, "A member declared capabilities."},
+	{"room_update", ServerToClient, RoomUpdate{}, "The room header changed."},
+	{"hand_queue", ServerToClient, HandQueue{}, "The raise-hand queue changed."},
+	{"called_on", ServerToClient, CalledOn{}, "A moderator called on this peer."},
+	{"reaction", ServerToClient, Reaction{}, "A member's emoji reaction."},
+	{"e2ee_key", ServerToClient, E2EEKey{}, "A relayed key exchange payload."},
+	{"priority_speaker", ServerToClient, PrioritySpeakerUpdate{}, "The priority speaker changed."},
+	{"mute_all", ServerToClient, MutedAll{}, "The room was muted or unmuted."},
+	{"unmute_requests", ServerToClient, UnmuteRequests{}, "Pending unmute requests, for moderators."},
+	{"unmute_answer", ServerToClient, UnmuteAnswer{}, "Answer to this peer's unmute request."},
+	{"denoise", ServerToClient, Denoise{}, "A member's noise suppression changed."},
+	{"moved", ServerToClient, Moved{}, "This peer was moved to another room."},
+	{"transcript", ServerToClient, Transcript{}, "A transcribed utterance."},
+	{"room_expiring", ServerToClient, RoomExpiring{}, "A scheduled room closes soon."},
+	{"room_stats", ServerToClient, RoomStats{}, "Room statistics."},
+	{"redirect", ServerToClient, Redirect{}, "The room is hosted on another node; reconnect there."},
+	{"disconnect", ServerToClient, Disconnect{}, "Sent before the server closes the connection."},
+	{"error", ServerToClient, Error{}, "A request failed or was rejected."},
+}
+
+// Lookup returns the spec of the message of msgType that direction sends.
+func Lookup(msgType string, direction Direction) (Spec, bool) {
+	for _, spec := range Messages {
+		if spec.Type == msgType && spec.Direction&direction != 0 {
+			return spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// FieldNames returns the JSON names of the fields of struct v other than
+// "type", with embedded structs flattened.
+func FieldNames(v any) []string {
+	var names []string
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(v)) {
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "type" {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
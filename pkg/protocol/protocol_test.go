@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSchemaFileIsCurrent(t *testing.T) {
+	schema, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.ReadFile("schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(file), schema) {
+		t.Fatal("schema.json is stale; run go generate ./pkg/protocol")
+	}
+}
+
+func TestMessagesAreUniqueAndTyped(t *testing.T) {
+	seen := map[string]Direction{}
+	for _, spec := range Messages {
+		if seen[spec.Type]&spec.Direction != 0 {
+			t.Errorf("%s is listed twice for %s", spec.Type, spec.Direction)
+		}
+		seen[spec.Type] |= spec.Direction
+		field, ok := reflect.TypeOf(spec.Message).FieldByName("Type")
+		if !ok || field.Tag.Get("json") != "type" {
+			t.Errorf("%T has no type field", spec.Message)
+		}
+	}
+}
+
+func TestLookupAndFieldNames(t *testing.T) {
+	spec, ok := Lookup("reaction", ClientToServer)
+	if !ok || !slices.Equal(spec.Fields(), []string{"emoji"}) {
+		t.Fatalf("client reaction = %+v, %v", spec, spec.Fields())
+	}
+	spec, ok = Lookup("reaction", ServerToClient)
+	if !ok || !slices.Equal(spec.Fields(), []string{"peer_id", "emoji"}) {
+		t.Fatalf("server reaction = %+v, %v", spec, spec.Fields())
+	}
+	if _, ok := Lookup("room_state", ClientToServer); ok {
+		t.Fatal("room_state is not a client message")
+	}
+	if got := FieldNames(Transcript{}); !slices.Equal(got, []string{"at", "peer_id", "name", "text"}) {
+		t.Fatalf("embedded fields = %v", got)
+	}
+}
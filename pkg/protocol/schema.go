@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SchemaID identifies the schema Schema generates.
+const SchemaID = "urn:sigmartc:signaling"
+
+// Schema returns a JSON Schema (draft 2020-12) accepting any signaling message.
+// Each message is a $defs entry named after its Go type, with a "type" const and
+// an "x-direction" of client, server or both. Client messages forbid unknown
+// fields, as the server does; server messages may gain fields.
+func Schema() ([]byte, error) {
+	g := &schemaGenerator{defs: map[string]any{}}
+	oneOf := make([]any, 0, len(Messages))
+	for _, spec := range Messages {
+		t := reflect.TypeOf(spec.Message)
+		def := g.object(t, spec.Direction&ClientToServer != 0)
+		properties := def["properties"].(map[string]any)
+		properties["type"] = map[string]any{"const": spec.Type}
+		def["description"] = spec.Description
+		def["x-direction"] = spec.Direction.String()
+		g.defs[t.Name()] = def
+		oneOf = append(oneOf, ref(t))
+	}
+	return json.MarshalIndent(map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     SchemaID,
+		"title":   "sigmartc signaling message",
+		"oneOf":   oneOf,
+		"$defs":   g.defs,
+	}, "", "  ")
+}
+
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+func ref(t reflect.Type) map[string]any {
+	return map[string]any{"$ref": "#/$defs/" + t.Name()}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// object describes a struct; strict objects forbid unknown fields.
+func (g *schemaGenerator) object(t reflect.Type, strict bool) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, field := range reflect.VisibleFields(t) {
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		optional := strings.Contains(options, "omitempty")
+		properties[name] = g.value(field.Type, optional)
+		if !optional {
+			required = append(required, name)
+		}
+	}
+	obj := map[string]any{"type": "object", "properties": properties, "required": required}
+	if strict {
+		obj["additionalProperties"] = false
+	}
+	return obj
+}
+
+// value describes a field of type t. Pointers that are always present may be
+// null. Nested objects allow unknown fields: the server only checks top-level ones.
+func (g *schemaGenerator) value(t reflect.Type, optional bool) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := g.value(t.Elem(), optional)
+		if optional {
+			return elem
+		}
+		return map[string]any{"anyOf": []any{elem, map[string]any{"type": "null"}}}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		// Go encodes nil slices as null.
+		return map[string]any{"type": []string{"array", "null"}, "items": g.value(t.Elem(), false)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.value(t.Elem(), false)}
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // reserve against recursion
+			g.defs[t.Name()] = g.object(t, false)
+		}
+		return ref(t)
+	}
+	return map[string]any{}
+}
@@ -8,10 +8,13 @@ RTC_UDP_PORT="${RTC_UDP_PORT:-50000}"
 TURN_SERVER="${TURN_SERVER:-}"
 TURN_USER="${TURN_USER:-}"
 TURN_PASS="${TURN_PASS:-}"
+GRPC_ADDR="${GRPC_ADDR:-}"
 
 mkdir -p "$DATA_DIR"
 ln -sf "$DATA_DIR/server.log" /app/server.log
 ln -sf "$DATA_DIR/banned_ips.json" /app/banned_ips.json
+ln -sf "$DATA_DIR/audit.log" /app/audit.log
+ln -sf "$DATA_DIR/dtls.pem" /app/dtls.pem
 
 args="/app/sigmartc -port $PORT -admin-key $ADMIN_KEY -rtc-udp-port $RTC_UDP_PORT"
 if [ -n "$TURN_SERVER" ]; then
@@ -23,5 +26,8 @@ fi
 if [ -n "$TURN_PASS" ]; then
   args="$args -turn-pass $TURN_PASS"
 fi
+if [ -n "$GRPC_ADDR" ]; then
+  args="$args -grpc-addr $GRPC_ADDR"
+fi
 
 exec $args
@@ -1,15 +1,22 @@
 (() => {
-    const params = new URLSearchParams(window.location.search);
-    const key = params.get('key') || '';
-
     const statsEl = document.getElementById('stats');
     const logsEl = document.getElementById('logs');
     const banInput = document.getElementById('ban-ip');
     const banBtn = document.getElementById('ban-btn');
 
+    const MAX_LOG_LINES = 200;
+    const LIFECYCLE_EVENTS = ['room_create', 'room_destroy', 'user_join', 'user_leave', 'ban'];
+    let stats = {};
+
     function fetchJSON(url, fallbackEl) {
-        return fetch(url)
-            .then((res) => res.json())
+        return fetch(url, { credentials: 'same-origin' })
+            .then((res) => {
+                if (res.status === 401) {
+                    location.href = '/admin';
+                    return null;
+                }
+                return res.json();
+            })
             .catch(() => {
                 if (fallbackEl) {
                     fallbackEl.textContent = '加载失败';
@@ -18,17 +25,25 @@
             });
     }
 
-    if (statsEl) {
-        fetchJSON(`/admin?action=stats&key=${encodeURIComponent(key)}`, statsEl)
-            .then((data) => {
-                if (data) {
-                    statsEl.textContent = JSON.stringify(data, null, 2);
-                }
-            });
+    function renderStats() {
+        if (statsEl) {
+            statsEl.textContent = JSON.stringify(stats, null, 2);
+        }
+    }
+
+    function appendLogLine(line) {
+        if (!logsEl) return;
+        const lines = logsEl.textContent ? logsEl.textContent.split('\n') : [];
+        lines.push(line);
+        if (lines.length > MAX_LOG_LINES) {
+            lines.splice(0, lines.length - MAX_LOG_LINES);
+        }
+        logsEl.textContent = lines.join('\n');
+        logsEl.scrollTop = logsEl.scrollHeight;
     }
 
     if (logsEl) {
-        fetchJSON(`/admin?action=logs&key=${encodeURIComponent(key)}`, logsEl)
+        fetchJSON('/admin?action=logs', logsEl)
             .then((data) => {
                 if (Array.isArray(data)) {
                     logsEl.textContent = data.join('\n');
@@ -36,12 +51,113 @@
             });
     }
 
+    if (window.EventSource) {
+        const source = new EventSource('/admin/events');
+        source.addEventListener('stats', (e) => {
+            stats = Object.assign(stats, JSON.parse(e.data));
+            renderStats();
+        });
+        LIFECYCLE_EVENTS.forEach((type) => {
+            source.addEventListener(type, (e) => appendLogLine(e.data));
+        });
+        source.onerror = () => {
+            if (statsEl && !Object.keys(stats).length) {
+                statsEl.textContent = '加载失败';
+            }
+        };
+    } else if (statsEl) {
+        fetchJSON('/admin?action=stats', statsEl)
+            .then((data) => {
+                if (data) {
+                    stats = data;
+                    renderStats();
+                }
+            });
+    }
+
+    const PEER_SERIES = [
+        ['bitrate_kbps', 'Bitrate (kbps)'],
+        ['loss_percent', 'Loss (%)'],
+        ['rtt_ms', 'RTT (ms)']
+    ];
+
+    // Draws one SVG sparkline per metric from action=peer_stats samples.
+    function renderPeerCharts(container, samples) {
+        container.textContent = '';
+        if (!samples.length) {
+            container.textContent = '暂无数据';
+            return;
+        }
+        const width = 240;
+        const height = 60;
+        const svgNS = 'http://www.w3.org/2000/svg';
+        PEER_SERIES.forEach(([key, label]) => {
+            const values = samples.map((s) => s[key] || 0);
+            const peak = Math.max(...values, 1e-9);
+            const step = values.length > 1 ? width / (values.length - 1) : 0;
+            const points = values
+                .map((v, i) => `${(i * step).toFixed(1)},${(height - (v / peak) * height).toFixed(1)}`)
+                .join(' ');
+
+            const figure = document.createElement('figure');
+            figure.className = 'peer-chart';
+            const svg = document.createElementNS(svgNS, 'svg');
+            svg.setAttribute('width', width);
+            svg.setAttribute('height', height);
+            const line = document.createElementNS(svgNS, 'polyline');
+            line.setAttribute('points', points);
+            svg.appendChild(line);
+            const caption = document.createElement('figcaption');
+            caption.textContent = `${label}: ${values[values.length - 1].toFixed(1)} (max ${Math.max(...values).toFixed(1)})`;
+            figure.append(svg, caption);
+            container.appendChild(figure);
+        });
+    }
+
+    // One line per action=peer_timeline event: time, event, detail.
+    function renderPeerTimeline(container, timeline) {
+        const lines = (timeline.events || []).map((e) =>
+            `${new Date(e.at).toLocaleTimeString()}  ${e.event}${e.detail ? '  ' + e.detail : ''}`);
+        if (timeline.dropped) {
+            lines.unshift(`(${timeline.dropped} earlier events dropped)`);
+        }
+        const state = timeline.connected ? '在线' : '已离开';
+        lines.unshift(`${timeline.name} · session ${timeline.session_id} · ${state}`);
+        container.textContent = lines.join('\n');
+    }
+
+    const peerInput = document.getElementById('peer-id');
+    const peerBtn = document.getElementById('peer-btn');
+    const peerCharts = document.getElementById('peer-charts');
+    const peerTimeline = document.getElementById('peer-timeline');
+    if (peerBtn && peerInput && peerCharts) {
+        peerBtn.addEventListener('click', () => {
+            const peerId = peerInput.value.trim();
+            if (!peerId) return;
+            fetchJSON(`/admin?action=peer_stats&peer_id=${encodeURIComponent(peerId)}`, peerCharts)
+                .then((data) => {
+                    if (data) {
+                        renderPeerCharts(peerCharts, data.samples || []);
+                    }
+                });
+            if (peerTimeline) {
+                fetchJSON(`/admin?action=peer_timeline&peer_id=${encodeURIComponent(peerId)}`, peerTimeline)
+                    .then((data) => {
+                        if (data) {
+                            renderPeerTimeline(peerTimeline, data);
+                        }
+                    });
+            }
+        });
+    }
+
     if (banBtn && banInput) {
         banBtn.addEventListener('click', () => {
             const ip = banInput.value.trim();
             if (!ip) return;
-            fetch(`/admin?action=ban&ip=${encodeURIComponent(ip)}&key=${encodeURIComponent(key)}`, {
-                method: 'POST'
+            fetch(`/admin?action=ban&ip=${encodeURIComponent(ip)}`, {
+                method: 'POST',
+                credentials: 'same-origin'
             }).then(() => location.reload());
         });
     }
@@ -183,6 +183,11 @@ let pc;
 let ws;
 let myId;
 let peers = new Map(); // peerId -> { name, volumePercent, gainNode, audioEl, sourceNode, stream }
+let handQueue = []; // peer IDs with raised hands, in server order
+let myRole = '';
+let roomMutedAll = false; // while set, unmuting needs a host's approval
+let unmuteApproved = false;
+let promptedUnmute = new Set(); // unmute requests already shown to this host
 let isMuted = false;
 let mixerOpen = false;
 let localName = '';
@@ -195,9 +200,14 @@ let isUnloading = false;
 let vadAudioContext;
 let pendingSelfVAD = null;
 const vadState = new Map();
+// Perfect negotiation state. The server announces our role in room_state; it
+// cannot roll back its own offers, so it always makes us the polite peer.
 let makingOffer = false;
 let ignoreOffer = false;
-const isPolite = true;
+let isSettingRemoteAnswerPending = false;
+let isPolite = true;
+let relayOnly = false; // room_state asks for TURN-only connections
+let tenantIceServers = []; // room_state adds the tenant's TURN server
 let pendingIceCandidates = [];
 let iceRestartTimer = null;
 let lastIceRestartAt = 0;
@@ -353,7 +363,13 @@ function getSignalUrl(name) {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
     const nicknameInput = document.getElementById('nickname');
     const nickname = name || localName || (nicknameInput ? nicknameInput.value.trim() : '') || 'unknown';
-    return `${protocol}//${window.location.host}/ws?room=${encodeURIComponent(roomUUID)}&name=${encodeURIComponent(nickname)}`;
+    return `${protocol}//${window.location.host}/ws?room=${encodeURIComponent(roomUUID)}&name=${encodeURIComponent(nickname)}${tenantQuery()}`;
+}
+
+// Rooms of a tenant other than the server's own are opened as ?tenant=<id>.
+function tenantQuery() {
+    const tenant = new URLSearchParams(window.location.search).get('tenant');
+    return tenant ? `&tenant=${encodeURIComponent(tenant)}` : '';
 }
 
 function buildConnectionDiagnosticReport(message, details = {}) {
@@ -561,6 +577,22 @@ if (!roomUUID) {
 document.getElementById('room-info').innerText = `即将进入房间: ${roomUUID}`;
 document.getElementById('display-room-id').innerText = `房间: ${roomUUID}`;
 
+// Shows the room's name, topic and avatar in the header, falling back to the room ID.
+function applyRoomMetadata(room) {
+    const meta = room || {};
+    document.getElementById('display-room-id').innerText = `房间: ${meta.name || roomUUID}`;
+    const topicEl = document.getElementById('room-topic');
+    topicEl.innerText = meta.topic || '';
+    topicEl.classList.toggle('hidden', !meta.topic);
+    const avatarEl = document.getElementById('room-avatar');
+    if (meta.avatar) {
+        avatarEl.src = meta.avatar;
+    } else {
+        avatarEl.removeAttribute('src');
+    }
+    avatarEl.classList.toggle('hidden', !meta.avatar);
+}
+
 // 2. Interaction Handlers
 document.getElementById('btn-join').onclick = async () => {
     const name = document.getElementById('nickname').value.trim();
@@ -639,6 +671,7 @@ function cleanupSession({ redirect, showJoin }) {
         localStream.getTracks().forEach(track => track.stop());
         localStream = null;
     }
+    stopLowLayer();
     if (localRawStream) {
         localRawStream.getTracks().forEach(track => track.stop());
         localRawStream = null;
@@ -668,6 +701,7 @@ function cleanupSession({ redirect, showJoin }) {
     myId = null;
     makingOffer = false;
     ignoreOffer = false;
+    isSettingRemoteAnswerPending = false;
     lastIceRestartAt = 0;
 
     if (userList) userList.innerHTML = '';
@@ -690,6 +724,23 @@ function cleanupSession({ redirect, showJoin }) {
 }
 
 document.getElementById('btn-mute').onclick = toggleMute;
+document.getElementById('btn-hand').onclick = () => {
+    if (!ws || ws.readyState !== WebSocket.OPEN) return;
+    ws.send(JSON.stringify({ type: handQueue.includes(myId) ? 'lower_hand' : 'raise_hand' }));
+};
+const btnCallNext = document.getElementById('btn-call-next');
+// Only hosts and moderators may call on the next raised hand; room_state carries our role.
+const HAND_MANAGER_ROLES = ['host', 'moderator'];
+btnCallNext.classList.add('hidden');
+btnCallNext.onclick = () => {
+    if (!ws || ws.readyState !== WebSocket.OPEN) return;
+    ws.send(JSON.stringify({ type: 'call_next' }));
+};
+document.getElementById('btn-react').onclick = () => {
+    if (!ws || ws.readyState !== WebSocket.OPEN) return;
+    ws.send(JSON.stringify({ type: 'reaction', emoji: '👍' }));
+    showReaction(myId, '👍');
+};
 if (btnMixer) {
     btnMixer.onclick = () => {
         setMixerOpen(!mixerOpen);
@@ -1163,6 +1214,60 @@ async function applyNoiseSettingsToCurrentStream() {
     }
 }
 
+// Redundant audio (RFC 2198) trades extra bandwidth for resilience on lossy links.
+// Opt in with localStorage.setItem('redundantAudio', 'true').
+function preferRedundantAudio(peerConnection) {
+    if (localStorage.getItem('redundantAudio') !== 'true') return;
+    if (!window.RTCRtpSender?.getCapabilities || !window.RTCRtpTransceiver?.prototype.setCodecPreferences) return;
+    const codecs = RTCRtpSender.getCapabilities('audio')?.codecs || [];
+    const red = codecs.filter((codec) => codec.mimeType.toLowerCase() === 'audio/red');
+    if (red.length === 0) return;
+    const others = codecs.filter((codec) => codec.mimeType.toLowerCase() !== 'audio/red');
+    peerConnection.getTransceivers().forEach((transceiver) => {
+        if (transceiver.sender.track?.kind !== 'audio') return;
+        try {
+            transceiver.setCodecPreferences([...red, ...others]);
+        } catch (err) {
+            Logger.warn('Failed to prefer RED codec:', err);
+        }
+    });
+}
+
+// Audio simulcast: with ?simulcast=1 a low-bitrate copy of the microphone is
+// published as a second track, and the server sends it to peers on lossy links.
+const simulcastEnabled = new URLSearchParams(window.location.search).get('simulcast') === '1';
+const LOW_LAYER_MAX_BITRATE = 16000;
+let lowLayerTrack = null;
+let lowLayerSender = null;
+
+// The copy is created before hello so its ID can be declared in the capabilities.
+function ensureLowLayerTrack() {
+    if (!simulcastEnabled || lowLayerTrack || !localStream) return lowLayerTrack;
+    const track = localStream.getAudioTracks()[0];
+    if (track) {
+        lowLayerTrack = track.clone();
+        lowLayerTrack.enabled = !isMuted;
+    }
+    return lowLayerTrack;
+}
+
+function publishLowLayer(peerConnection) {
+    const track = ensureLowLayerTrack();
+    if (!track) return;
+    const transceiver = peerConnection.addTransceiver(track, {
+        direction: 'sendonly',
+        streams: [localStream],
+        sendEncodings: [{ maxBitrate: LOW_LAYER_MAX_BITRATE }]
+    });
+    lowLayerSender = transceiver.sender;
+}
+
+function stopLowLayer() {
+    if (lowLayerTrack) lowLayerTrack.stop();
+    lowLayerTrack = null;
+    lowLayerSender = null;
+}
+
 async function switchInputDevice(deviceId) {
     if (isTestMode) return;
     if (!navigator.mediaDevices || !navigator.mediaDevices.getUserMedia) {
@@ -1190,11 +1295,19 @@ async function switchInputDevice(deviceId) {
     if (pc) {
         const newTrack = localStream.getAudioTracks()[0];
         if (newTrack) {
-            const senders = pc.getSenders().filter((sender) => sender.track && sender.track.kind === 'audio');
+            const senders = pc.getSenders().filter((sender) => sender.track && sender.track.kind === 'audio' && sender !== lowLayerSender);
             if (senders.length > 0) {
                 await Promise.all(senders.map((sender) => sender.replaceTrack(newTrack)));
+                if (lowLayerSender && lowLayerTrack) {
+                    const oldLowLayer = lowLayerTrack;
+                    lowLayerTrack = newTrack.clone();
+                    lowLayerTrack.enabled = !isMuted;
+                    await lowLayerSender.replaceTrack(lowLayerTrack);
+                    oldLowLayer.stop();
+                }
             } else {
                 pc.addTrack(newTrack, localStream);
+                preferRedundantAudio(pc);
             }
         }
     }
@@ -1310,6 +1423,46 @@ function ensureAvatar(id, name, isSelf) {
     avatarGrid.appendChild(wrapper);
 }
 
+// Marks raised hands on avatars and reflects our own state on the hand button.
+function renderHands(queue) {
+    handQueue = Array.isArray(queue) ? queue : [];
+    document.querySelectorAll('.avatar-wrapper').forEach(el => {
+        const id = el.id.replace('avatar-wrap-', '');
+        el.classList.toggle('hand-raised', handQueue.includes(id));
+    });
+    document.getElementById('btn-hand').classList.toggle('active', handQueue.includes(myId));
+}
+
+// Marks the priority speaker's avatar; speaker is { peer_id, duck } or null.
+function renderPrioritySpeaker(speaker) {
+    const id = speaker && speaker.peer_id;
+    document.querySelectorAll('.avatar-wrapper').forEach(el => {
+        el.classList.toggle('priority-speaker', !!id && el.id === `avatar-wrap-${id}`);
+    });
+}
+
+// Floats an emoji over the peer's avatar for a couple of seconds.
+function showReaction(peerId, emoji) {
+    const wrapper = document.getElementById(`avatar-wrap-${peerId}`);
+    if (!wrapper) return;
+    const bubble = document.createElement('div');
+    bubble.className = 'reaction-bubble';
+    bubble.textContent = emoji;
+    bubble.addEventListener('animationend', () => bubble.remove());
+    wrapper.appendChild(bubble);
+}
+
+let transcriptTimer = null;
+
+function showTranscript(name, text) {
+    const caption = document.getElementById('transcript-caption');
+    if (!caption) return;
+    caption.textContent = `${name}: ${text}`;
+    caption.classList.remove('hidden');
+    clearTimeout(transcriptTimer);
+    transcriptTimer = setTimeout(() => caption.classList.add('hidden'), 6000);
+}
+
 function cleanupVAD(peerId) {
     const state = vadState.get(peerId);
     if (!state) return;
@@ -1337,14 +1490,146 @@ function seedTestPeers() {
 }
 
 // 3. Signaling & WebRTC
-function startSignaling(name) {
+const PROTOCOL_VERSION = 1;
+
+// Declared in the hello message so the server only forwards what this browser can play.
+function getClientCapabilities() {
+    const receiverCaps = window.RTCRtpReceiver && RTCRtpReceiver.getCapabilities
+        ? RTCRtpReceiver.getCapabilities('audio')
+        : null;
+    const codecs = receiverCaps
+        ? [...new Set(receiverCaps.codecs.map(c => c.mimeType.toLowerCase()))]
+        : [];
+    return {
+        protocol_version: PROTOCOL_VERSION,
+        codecs,
+        video: false,
+        data_channels: true,
+        ...(ensureLowLayerTrack() ? { low_layer_track: lowLayerTrack.id } : {})
+    };
+}
+
+// SHA-256 of an ASCII string. crypto.subtle is async and missing outside secure
+// contexts, so the proof-of-work solver hashes with this instead.
+const SHA256_K = new Uint32Array([
+    0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+    0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+    0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+    0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+    0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+    0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+    0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+    0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2
+]);
+
+function sha256Words(text) {
+    const len = text.length;
+    const blocks = ((len + 8) >> 6) + 1;
+    const words = new Uint32Array(blocks * 16);
+    for (let i = 0; i < len; i++) words[i >> 2] |= (text.charCodeAt(i) & 0xff) << (24 - (i & 3) * 8);
+    words[len >> 2] |= 0x80 << (24 - (len & 3) * 8);
+    words[blocks * 16 - 1] = len * 8;
+    const h = new Uint32Array([
+        0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19
+    ]);
+    const w = new Uint32Array(64);
+    const rotr = (x, n) => (x >>> n) | (x << (32 - n));
+    for (let b = 0; b < blocks; b++) {
+        for (let i = 0; i < 64; i++) {
+            if (i < 16) {
+                w[i] = words[b * 16 + i];
+            } else {
+                const s0 = rotr(w[i - 15], 7) ^ rotr(w[i - 15], 18) ^ (w[i - 15] >>> 3);
+                const s1 = rotr(w[i - 2], 17) ^ rotr(w[i - 2], 19) ^ (w[i - 2] >>> 10);
+                w[i] = w[i - 16] + s0 + w[i - 7] + s1;
+            }
+        }
+        let [a, bb, c, d, e, f, g, hh] = h;
+        for (let i = 0; i < 64; i++) {
+            const t1 = hh + (rotr(e, 6) ^ rotr(e, 11) ^ rotr(e, 25)) + ((e & f) ^ (~e & g)) + SHA256_K[i] + w[i];
+            const t2 = (rotr(a, 2) ^ rotr(a, 13) ^ rotr(a, 22)) + ((a & bb) ^ (a & c) ^ (bb & c));
+            hh = g; g = f; f = e; e = (d + t1) >>> 0;
+            d = c; c = bb; bb = a; a = (t1 + t2) >>> 0;
+        }
+        h[0] += a; h[1] += bb; h[2] += c; h[3] += d; h[4] += e; h[5] += f; h[6] += g; h[7] += hh;
+    }
+    return h;
+}
+
+function hasLeadingZeroBits(hash, difficulty) {
+    for (let i = 0; difficulty > 0; i++, difficulty -= 32) {
+        const mask = difficulty >= 32 ? 0xffffffff : ~(0xffffffff >>> difficulty);
+        if ((hash[i] & mask) !== 0) return false;
+    }
+    return true;
+}
+
+// Fetches and solves the server's join challenge, if any, and returns the query
+// parameters proving it. Work is done in slices so the page stays responsive.
+async function solveJoinChallenge() {
+    let res;
+    try {
+        res = await fetch('/api/challenge', { cache: 'no-store' });
+    } catch (err) {
+        Logger.warn('Join challenge unavailable:', err);
+        return '';
+    }
+    if (res.status === 404) return '';
+    const challenge = await res.json();
+    if (challenge.type !== 'pow') {
+        Logger.warn('Unsupported join challenge:', challenge.type);
+        return '';
+    }
+    const started = performance.now();
+    for (let nonce = 0; ; ) {
+        for (const end = nonce + 5000; nonce < end; nonce++) {
+            if (hasLeadingZeroBits(sha256Words(`${challenge.challenge}:${nonce}`), challenge.difficulty)) {
+                Logger.info('Join challenge solved in', Math.round(performance.now() - started), 'ms');
+                return `&pow_challenge=${encodeURIComponent(challenge.challenge)}&pow_nonce=${nonce}`;
+            }
+        }
+        await new Promise(resolve => setTimeout(resolve, 0));
+    }
+}
+
+// getDeviceId returns a random ID kept per browser, so the server can tell a
+// second tab in the same room apart from a different person.
+function getDeviceId() {
+    let id = null;
+    try {
+        id = localStorage.getItem('deviceId');
+        if (!id) {
+            id = crypto.randomUUID();
+            localStorage.setItem('deviceId', id);
+        }
+    } catch (e) {
+        Logger.warn('Device ID unavailable:', e);
+    }
+    return id;
+}
+
+async function startSignaling(name, takeover = false) {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws?room=${encodeURIComponent(roomUUID)}&name=${encodeURIComponent(name)}`;
+    // join_errors=ws: browsers hide HTTP error bodies, so ask for join refusals as a disconnect message.
+    let wsUrl = `${protocol}//${window.location.host}/ws?room=${encodeURIComponent(roomUUID)}&name=${encodeURIComponent(name)}&join_errors=ws${tenantQuery()}`;
     Logger.info('Connecting to signaling server:', wsUrl);
+    // Hosts of scheduled rooms receive a link carrying host_token so they can enter early.
+    const pageParams = new URLSearchParams(window.location.search);
+    const hostToken = pageParams.get('host_token');
+    if (hostToken) wsUrl += `&host_token=${encodeURIComponent(hostToken)}`;
+    // Products embedding the client pass their identity provider's JWT as ?token=.
+    const authToken = pageParams.get('token');
+    if (authToken) wsUrl += `&token=${encodeURIComponent(authToken)}`;
+    const deviceId = getDeviceId();
+    if (deviceId) wsUrl += `&device_id=${encodeURIComponent(deviceId)}`;
+    if (takeover) wsUrl += '&takeover=1';
+    wsUrl += await solveJoinChallenge();
+    if (didCleanup) return;
     ws = new WebSocket(wsUrl);
 
     ws.onopen = () => {
         Logger.info('WebSocket connected');
+        ws.send(JSON.stringify({ type: 'hello', capabilities: getClientCapabilities() }));
         startWebSocketKeepalive();
     };
 
@@ -1355,7 +1640,7 @@ function startSignaling(name) {
             return;
         }
         if (!notifiedDisconnect) {
-            handleSocketFailure('连接已断开', {
+            handleSocketFailure(DISCONNECT_MESSAGES[e.reason] || '连接已断开', {
                 source: 'ws.onclose',
                 eventType: 'close',
                 closeCode: e.code,
@@ -1387,10 +1672,65 @@ function startSignaling(name) {
         switch (msg.type) {
             case 'room_state':
                 myId = msg.self_id;
+                myRole = msg.role;
+                isPolite = msg.polite !== false;
+                relayOnly = !!msg.relay_only;
+                tenantIceServers = msg.ice_servers || [];
+                roomMutedAll = !!msg.muted_all;
+                btnCallNext.classList.toggle('hidden', !HAND_MANAGER_ROLES.includes(msg.role));
+                // This client has no insertable-streams encryption, so the server will not forward it.
+                if (msg.e2ee) alert('此房间启用了端到端加密，当前客户端不支持，你的声音不会被转发');
                 Logger.info('Room state received, myId:', myId, 'peers:', msg.peers.length);
                 maybeStartSelfVAD();
+                applyRoomMetadata(msg.room);
                 msg.peers.forEach(p => addPeer(p.id, p.name, false));
-                initWebRTC();
+                renderHands(msg.hands);
+                renderPrioritySpeaker(msg.priority_speaker);
+                // After a server-side move the existing connection is reused.
+                if (!pc) initWebRTC();
+                break;
+            case 'hand_queue':
+                renderHands(msg.queue);
+                break;
+            case 'priority_speaker':
+                renderPrioritySpeaker(msg);
+                break;
+            case 'mute_all':
+                roomMutedAll = !!msg.muted;
+                unmuteApproved = false;
+                if (roomMutedAll && !HAND_MANAGER_ROLES.includes(myRole)) {
+                    alert('主持人已将所有人静音，取消静音需要申请');
+                }
+                break;
+            case 'unmute_answer':
+                unmuteApproved = !!msg.approved;
+                alert(msg.approved ? '主持人已允许你发言' : '主持人拒绝了你的发言申请');
+                break;
+            case 'unmute_requests':
+                answerUnmuteRequests(msg.queue);
+                break;
+            case 'called_on':
+                Logger.info('Called on:', msg.peer_id);
+                showReaction(msg.peer_id, '🎤');
+                break;
+            case 'reaction':
+                showReaction(msg.peer_id, msg.emoji);
+                break;
+            case 'transcript':
+                showTranscript(msg.name || msg.peer_id, msg.text);
+                break;
+            case 'room_update':
+                applyRoomMetadata(msg.room);
+                break;
+            case 'hello_ack':
+                Logger.debug('Server protocol version:', msg.protocol_version);
+                break;
+            case 'moved':
+                Logger.info('Moved to room:', msg.room, 'from:', msg.from);
+                Array.from(peers.keys()).forEach(id => removePeer(id));
+                roomUUID = msg.room;
+                window.history.replaceState(null, '', `/r/${encodeURIComponent(roomUUID)}`);
+                document.getElementById('display-room-id').innerText = `房间: ${roomUUID}`;
                 break;
             case 'peer_join':
                 Logger.info('Peer joined:', msg.peer.id, msg.peer.name);
@@ -1404,7 +1744,9 @@ function startSignaling(name) {
                 Logger.debug('Received offer');
                 {
                     const offer = new RTCSessionDescription({ type: 'offer', sdp: msg.sdp });
-                    const offerCollision = makingOffer || pc.signalingState !== 'stable';
+                    const readyForOffer = !makingOffer &&
+                        (pc.signalingState === 'stable' || isSettingRemoteAnswerPending);
+                    const offerCollision = !readyForOffer;
                     ignoreOffer = !isPolite && offerCollision;
                     if (ignoreOffer) {
                         Logger.debug('Ignoring offer due to collision');
@@ -1423,18 +1765,60 @@ function startSignaling(name) {
                 }
                 break;
             case 'answer':
-                if (ignoreOffer) {
-                    Logger.debug('Ignoring answer');
+                if (pc.signalingState !== 'have-local-offer') {
+                    // Our offer was rolled back in a collision; its answer is stale.
+                    Logger.debug('Ignoring answer in state', pc.signalingState);
                     return;
                 }
                 Logger.debug('Received answer, setting remote description');
-                await pc.setRemoteDescription(new RTCSessionDescription({ type: 'answer', sdp: msg.sdp }));
+                isSettingRemoteAnswerPending = true;
+                try {
+                    await pc.setRemoteDescription(new RTCSessionDescription({ type: 'answer', sdp: msg.sdp }));
+                } finally {
+                    isSettingRemoteAnswerPending = false;
+                }
                 await flushPendingIceCandidates();
                 break;
             case 'candidate':
                 Logger.debug('Received ICE candidate');
                 await addIceCandidateSafely(msg.candidate);
                 break;
+            case 'room_expiring':
+                Logger.warn('Room closes in', msg.seconds_left, 'seconds');
+                document.getElementById('display-room-id').innerText =
+                    `房间: ${roomUUID}（${Math.max(1, Math.round(msg.seconds_left / 60))} 分钟后关闭）`;
+                break;
+            case 'redirect': {
+                // The room lives on another node: reopen this page there, keeping its path and query.
+                Logger.info('Room is hosted on another node:', msg.node);
+                const redirected = ws;
+                redirected.onclose = null;
+                redirected.onerror = null;
+                redirected.onmessage = null;
+                redirected.close();
+                window.location.href = msg.node + window.location.pathname + window.location.search;
+                return;
+            }
+            case 'disconnect':
+                Logger.warn('Disconnected by server:', msg.reason, msg.message);
+                if (msg.reason === 'duplicate_session' && confirm('你已在其他标签页中加入此房间，是否在此继续？')) {
+                    // Drop this refused socket quietly and rejoin, closing the other tab's session.
+                    const refused = ws;
+                    refused.onclose = null;
+                    refused.onerror = null;
+                    refused.onmessage = null;
+                    refused.close();
+                    startSignaling(name, true);
+                    return;
+                }
+                handleSocketFailure(DISCONNECT_MESSAGES[msg.reason] || msg.message || '连接已断开', {
+                    source: 'server-disconnect',
+                    eventType: 'server-message',
+                    disconnectReason: msg.reason,
+                    serverMessage: msg.message,
+                    readyState: ws ? ws.readyState : undefined
+                });
+                break;
             case 'error':
                 Logger.error('Server error:', msg.message);
                 handleSocketFailure(msg.message || '连接已断开', {
@@ -1466,6 +1850,32 @@ function stopWebSocketKeepalive() {
     wsKeepaliveTimer = null;
 }
 
+const DISCONNECT_MESSAGES = {
+    room_full: '房间已满',
+    banned: '你已被封禁',
+    kicked: '你已被管理员移出房间',
+    room_closed: '房间已被关闭',
+    shutdown: '服务器正在维护，请稍后重试',
+    idle_timeout: '长时间无活动，已自动断开',
+    server_error: '服务器错误',
+    timeout: '连接超时',
+    room_not_started: '房间尚未开放，请在预定时间后进入',
+    room_expired: '预定时间已到，房间已关闭',
+    room_locked: '房间已锁定，暂时无法加入',
+    invalid_name: '房间号或昵称无效',
+    signaling_overflow: '发送的信令消息过多，连接已断开',
+    slow_consumer: '网络过慢，连接已断开',
+    quota_exceeded: '本房间本月流量已用完',
+    geo_blocked: '你所在的地区无法加入',
+    challenge_failed: '人机验证失败，请刷新后重试',
+    unauthorized: '请先登录后再加入',
+    ip_limit: '同一网络在本房间的连接数已达上限',
+    duplicate_session: '你已在其他标签页中加入此房间',
+    session_takeover: '通话已在其他标签页中继续',
+    ice_failed: '网络连接失败，请重新加入',
+    negotiation_timeout: '连接建立超时，请重新加入'
+};
+
 function handleSocketFailure(message, details = {}) {
     Logger.error('Socket failure:', message, details);
     if (notifiedDisconnect) return;
@@ -1484,7 +1894,8 @@ async function addIceCandidateSafely(candidate) {
     try {
         await pc.addIceCandidate(new RTCIceCandidate(candidate));
     } catch (e) {
-        Logger.warn('Failed to add ICE candidate:', e);
+        // Candidates for an offer we ignored are expected to fail.
+        if (!ignoreOffer) Logger.warn('Failed to add ICE candidate:', e);
     }
 }
 
@@ -1499,9 +1910,12 @@ async function flushPendingIceCandidates() {
 
 function initWebRTC() {
     Logger.info('Initializing WebRTC with config:', config);
-    pc = new RTCPeerConnection(config);
+    const rtcConfig = { ...config, iceServers: [...config.iceServers, ...tenantIceServers] };
+    if (relayOnly) rtcConfig.iceTransportPolicy = 'relay';
+    pc = new RTCPeerConnection(rtcConfig);
     makingOffer = false;
     ignoreOffer = false;
+    isSettingRemoteAnswerPending = false;
     pendingIceCandidates = [];
     if (window.__E2E__) {
         window.__pc = pc;
@@ -1650,6 +2064,8 @@ function initWebRTC() {
 
     // Add local tracks after handlers are set to avoid missing negotiationneeded.
     localStream.getTracks().forEach(track => pc.addTrack(track, localStream));
+    publishLowLayer(pc);
+    preferRedundantAudio(pc);
 
     // Safety net: trigger initial negotiation if the event was missed.
     queueMicrotask(async () => {
@@ -1792,7 +2208,31 @@ const ICONS = {
     micOff: `<svg viewBox="0 0 24 24" width="24" height="24" stroke="currentColor" stroke-width="2" fill="none" stroke-linecap="round" stroke-linejoin="round"><line x1="1" y1="1" x2="23" y2="23"></line><path d="M9 9v3a3 3 0 0 0 5.12 2.12M15 9.34V4a3 3 0 0 0-5.94-.6"></path><path d="M17 16.95A7 7 0 0 1 5 12v-2m14 0v2a7 7 0 0 1-.11 1.23"></path><line x1="12" y1="19" x2="12" y2="23"></line><line x1="8" y1="23" x2="16" y2="23"></line></svg>`
 };
 
+// answerUnmuteRequests asks this host about each new request in the queue.
+function answerUnmuteRequests(queue) {
+    queue = queue || [];
+    // Forget answered requests so the same peer can ask again later.
+    promptedUnmute = new Set([...promptedUnmute].filter(id => queue.includes(id)));
+    queue.forEach(id => {
+        if (promptedUnmute.has(id)) return;
+        promptedUnmute.add(id);
+        const name = peers.get(id)?.name || id;
+        const approve = confirm(`${name} 申请发言，是否允许？`);
+        if (ws && ws.readyState === WebSocket.OPEN) {
+            ws.send(JSON.stringify({ type: approve ? 'approve_unmute' : 'deny_unmute', peer_id: id }));
+        }
+    });
+}
+
 function toggleMute() {
+    // While the room is muted the server drops our audio; ask a host instead.
+    if (isMuted && roomMutedAll && !unmuteApproved && !HAND_MANAGER_ROLES.includes(myRole)) {
+        if (ws && ws.readyState === WebSocket.OPEN) {
+            ws.send(JSON.stringify({ type: 'unmute_request' }));
+        }
+        Logger.info('Unmute requested while the room is muted');
+        return;
+    }
     isMuted = !isMuted;
     Logger.info('Mute toggled:', isMuted);
     if (!localStream) return;
@@ -1800,6 +2240,7 @@ function toggleMute() {
     if (tracks.length > 0) {
         tracks[0].enabled = !isMuted;
     }
+    if (lowLayerTrack) lowLayerTrack.enabled = !isMuted;
 
     const btn = document.getElementById('btn-mute');
     btn.innerHTML = isMuted ? ICONS.micOff : ICONS.micOn;
@@ -1838,6 +2279,7 @@ function addPeerVolumeControl(peerId, name) {
 
     slider.addEventListener('input', () => {
         setPeerVolume(peerId, slider.value, value);
+        updatePeerSubscription(peerId, slider.value);
     });
 
     row.append(label, slider, value);
@@ -1868,6 +2310,21 @@ function setPeerVolume(peerId, percent, valueEl) {
     }
 }
 
+// updatePeerSubscription stops the server sending a peer's audio while their
+// slider is at 0%, so muting someone for yourself also saves bandwidth.
+function updatePeerSubscription(peerId, percent) {
+    const peer = peers.get(peerId);
+    if (!peer || !ws || ws.readyState !== WebSocket.OPEN) return;
+    const wanted = clampPercent(percent) > 0;
+    if (wanted === !peer.unsubscribed) return;
+    peer.unsubscribed = !wanted;
+    if (!wanted) {
+        // The track ends; forget the stream so the next one is bound afresh.
+        peer.stream = null;
+    }
+    ws.send(JSON.stringify({ type: wanted ? 'subscribe' : 'unsubscribe', peer_id: peerId }));
+}
+
 function attachRemoteAudio(peerId, stream, audioEl) {
     Logger.debug('Attaching remote audio for peer:', peerId);
     let peer = peers.get(peerId);